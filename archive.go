@@ -0,0 +1,185 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// isArchive 根据扩展名判断是否为JAR/WAR归档
+func isArchive(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".jar" || ext == ".war"
+}
+
+// archiveEntryName 返回归档中配置文件的条目路径: 指定了-entry时使用该值，
+// 否则JAR使用BOOT-INF/classes/application.properties，WAR使用WEB-INF/classes/application.properties
+func archiveEntryName(archive string) string {
+	if archiveEntry != "" {
+		return archiveEntry
+	}
+	if strings.ToLower(filepath.Ext(archive)) == ".war" {
+		return "WEB-INF/classes/application.properties"
+	}
+	return "BOOT-INF/classes/application.properties"
+}
+
+// readConfigLines 读取配置内容，归档文件读取其中的配置条目，返回各行、使用的换行符与原始编码
+func readConfigLines(filename string) ([]string, string, string, error) {
+	if !isArchive(filename) {
+		lines, err := propmerge.ReadFile(filename)
+		return lines, propmerge.DetectLineSeparator(filename), propmerge.DetectFileEncoding(filename), err
+	}
+
+	r, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, "", "", fmt.Errorf(tr("打开归档失败: %w"), err)
+	}
+	defer r.Close()
+
+	entry := archiveEntryName(filename)
+	for _, f := range r.File {
+		if f.Name != entry {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, "", "", fmt.Errorf(tr("读取归档条目 %s 失败: %w"), entry, err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, "", "", fmt.Errorf(tr("读取归档条目 %s 失败: %w"), entry, err)
+		}
+		lines, err := propmerge.ReadLines(bytes.NewReader(data))
+		sep := propmerge.LineSeparator
+		if i := bytes.IndexByte(data, '\n'); i > 0 && data[i-1] == '\r' {
+			sep = "\r\n"
+		}
+		return lines, sep, propmerge.DetectEncoding(data), err
+	}
+	return nil, "", "", fmt.Errorf(tr("归档 %s 中不存在条目 %s"), filename, entry)
+}
+
+// writeArchiveEntry 将合并结果写回归档中的配置条目: 其余条目按原始压缩数据原样复制，
+// 经propmerge.AtomicWrite写入同目录下的临时文件后重命名覆盖原归档
+func writeArchiveEntry(archive string, lines []string, sep, encoding string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf(tr("打开归档失败: %w"), err)
+	}
+	defer r.Close()
+
+	entry := archiveEntryName(archive)
+	return propmerge.AtomicWrite(archive, func(out io.Writer) error {
+		w := zip.NewWriter(out)
+		w.SetComment(r.Comment)
+		for _, f := range r.File {
+			if f.Name != entry {
+				if err := w.Copy(f); err != nil {
+					return fmt.Errorf(tr("复制归档条目 %s 失败: %w"), f.Name, err)
+				}
+				continue
+			}
+
+			header := f.FileHeader
+			header.Modified = time.Now()
+			header.CRC32, header.CompressedSize64, header.UncompressedSize64 = 0, 0, 0
+			header.CompressedSize, header.UncompressedSize = 0, 0
+			dst, err := w.CreateHeader(&header)
+			if err != nil {
+				return fmt.Errorf(tr("写入归档条目 %s 失败: %w"), entry, err)
+			}
+			data, err := propmerge.EncodeLines(lines, sep, encoding)
+			if err != nil {
+				return fmt.Errorf(tr("编码归档条目 %s 失败: %w"), entry, err)
+			}
+			if _, err := dst.Write(data); err != nil {
+				return fmt.Errorf(tr("写入归档条目 %s 失败: %w"), entry, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf(tr("写入归档失败: %w"), err)
+		}
+		return nil
+	})
+}
+
+// runArchiveMerge 在旧文件和/或新文件为JAR/WAR归档时执行合并: 从归档中读取配置条目，
+// 合并后写回新文件(或新归档中的条目)，写入前备份原始文件
+func runArchiveMerge(merger *propmerge.Merger, oldFile, newFile string) error {
+	oldLines, _, _, err := readConfigLines(oldFile)
+	if err != nil {
+		return fmt.Errorf(tr("读取旧配置失败: %w"), err)
+	}
+	newLines, sep, encoding, err := readConfigLines(newFile)
+	if err != nil {
+		return fmt.Errorf(tr("读取新配置失败: %w"), err)
+	}
+
+	merge := merger.MergeLines
+	if isArchive(newFile) {
+		entry := archiveEntryName(newFile)
+		if m := pathMerge(merger, fileFormat(entry, entry)); m != nil {
+			merge = m
+		}
+	}
+	result, err := merge(oldLines, newLines)
+	if err != nil {
+		return fmt.Errorf(tr("合并失败: %w"), err)
+	}
+	recordResult(result)
+
+	if dryRun {
+		printPlan(result.Keys)
+		printCollisions(result.Keys)
+		printConflicts(result.Keys)
+		printDuplicates(result.Duplicates)
+		if showDiff {
+			printDiff(newFile, newLines, result.Lines)
+		}
+		return nil
+	}
+
+	oldBackup, newBackup, err := createBackups(backupDir, oldFile, newFile)
+	if err != nil {
+		return err
+	}
+	if isArchive(newFile) {
+		if outputEncoding != "" {
+			encoding = outputEncoding
+		}
+		err = writeArchiveEntry(newFile, result.Lines, sep, encoding)
+	} else {
+		err = propmerge.WriteFileEncoding(newFile, result.Lines, outputEncoding)
+	}
+	if err != nil {
+		return fmt.Errorf(tr("写入更新文件失败: %w"), err)
+	}
+	if err := verifyWrite(oldFile, newFile, oldBackup, newBackup, result); err != nil {
+		return err
+	}
+
+	fmt.Println(tr("配置更新完成!已完全使用新文件内容,并保留以下参数在原位置:"))
+	if changedOnly {
+		printChangedParams(result.Keys)
+	} else {
+		printKeptResults(result.Keys)
+	}
+	printCollisions(result.Keys)
+	printConflicts(result.Keys)
+	printDiverged(result.Keys)
+	printDuplicates(result.Duplicates)
+	printObsolete(result.Keys)
+	printBackupPaths(oldBackup, newBackup)
+	if showDiff {
+		printDiff(newFile, newLines, result.Lines)
+	}
+	return nil
+}
@@ -0,0 +1,526 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// backupDir 为备份目录(-backup-dir)，相对路径相对于当前目录
+var backupDir = "./config_backup"
+
+// defaultBackupTemplate 为默认的备份文件命名模板
+const defaultBackupTemplate = "{name}.bak.{ts}"
+
+var (
+	backupTemplate = defaultBackupTemplate // 备份文件的命名模板(-backup-template)
+	noBackup       bool                    // 为true时合并前不创建任何备份(-no-backup)
+)
+
+// registerBackupFlags 注册读写备份目录的各子命令共用的-backup-dir与-backup-template
+func registerBackupFlags(fs *flag.FlagSet) {
+	fs.StringVar(&backupDir, "backup-dir", backupDir, "备份目录，相对路径相对于当前目录")
+	fs.StringVar(&backupTemplate, "backup-template", defaultBackupTemplate, "备份文件的命名模板: {name}为原文件名，{ts}为时间戳，{host}为主机名；须包含{name}与{ts}")
+}
+
+// checkBackupFlags 校验-backup-dir与-backup-template
+func checkBackupFlags() error {
+	if backupDir == "" {
+		return errors.New(tr("-backup-dir不能为空"))
+	}
+	if !strings.Contains(backupTemplate, "{name}") || !strings.Contains(backupTemplate, "{ts}") {
+		return fmt.Errorf(tr("-backup-template必须包含{name}与{ts}: %s"), backupTemplate)
+	}
+	if strings.ContainsAny(backupTemplate, `/\`) {
+		return fmt.Errorf(tr("-backup-template只能是文件名，不能包含路径分隔符: %s"), backupTemplate)
+	}
+	return checkGitBackupEncryption()
+}
+
+// backupHost 返回{host}使用的主机名
+func backupHost() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "localhost"
+	}
+	return host
+}
+
+// backupName 按命名模板返回文件base的备份文件名，kind为new、repair等时{name}为原文件名加.kind，
+// 默认模板下即为base.kind.bak.ts
+func backupName(base, kind, ts string) string {
+	if kind != "" && kind != "bak" {
+		base += "." + kind
+	}
+	return strings.NewReplacer("{name}", base, "{ts}", ts, "{host}", backupHost()).Replace(backupTemplate)
+}
+
+// backupPattern 缓存backupNamePattern按当前模板编译的正则表达式，serve中可能被并发访问
+var backupPattern struct {
+	sync.Mutex
+	template string
+	re       *regexp.Regexp
+}
+
+// backupNamePattern 将命名模板转换为匹配备份文件名的正则表达式，{name}与{ts}为同名的分组
+func backupNamePattern() *regexp.Regexp {
+	backupPattern.Lock()
+	defer backupPattern.Unlock()
+	if backupPattern.re != nil && backupPattern.template == backupTemplate {
+		return backupPattern.re
+	}
+	// 同一占位符再次出现时不再作为分组
+	groups := map[string]string{"{name}": "(?P<name>.+)", "{ts}": `(?P<ts>\d{14})`}
+	again := map[string]string{"{name}": ".+", "{ts}": `\d{14}`, "{host}": regexp.QuoteMeta(backupHost())}
+	var b strings.Builder
+	b.WriteString("^")
+	rest := backupTemplate
+	for rest != "" {
+		i := strings.Index(rest, "{")
+		if i == -1 {
+			b.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		b.WriteString(regexp.QuoteMeta(rest[:i]))
+		rest = rest[i:]
+		placeholder := ""
+		for p := range again {
+			if strings.HasPrefix(rest, p) {
+				placeholder = p
+			}
+		}
+		switch {
+		case placeholder == "":
+			b.WriteString(regexp.QuoteMeta("{"))
+			rest = rest[1:]
+			continue
+		case groups[placeholder] != "":
+			b.WriteString(groups[placeholder])
+			delete(groups, placeholder)
+		default:
+			b.WriteString(again[placeholder])
+		}
+		rest = rest[len(placeholder):]
+	}
+	b.WriteString("$")
+	backupPattern.template, backupPattern.re = backupTemplate, regexp.MustCompile(b.String())
+	return backupPattern.re
+}
+
+// createBackups 在备份目录dir中为旧文件和新文件创建带时间戳的备份，返回两个备份文件路径。
+// 使用Git备份时同时将新文件合并前的状态提交到备份仓库；只使用Git备份时不创建文件副本，
+// 旧文件备份路径为空，新文件备份路径为备份仓库中该文件的路径
+func createBackups(dir, oldFile, newFile string) (oldBackup, newBackup string, err error) {
+	if noBackup {
+		debugf(tr("已指定-no-backup，不创建备份"))
+		return "", "", nil
+	}
+	// 创建备份目录
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", fmt.Errorf(tr("创建备份目录失败: %w"), err)
+	}
+
+	if copyBackups() {
+		// 生成备份文件
+		ts := time.Now().Format("20060102150405")
+		debugf(tr("创建备份文件..."))
+		oldBackup = filepath.Join(dir, backupName(filepath.Base(oldFile), "bak", ts))
+		newBackup = filepath.Join(dir, backupName(filepath.Base(newFile), "new", ts))
+		if oldBackup, err = writeBackup(oldFile, oldBackup); err != nil {
+			return "", "", fmt.Errorf(tr("备份旧文件失败: %w"), err)
+		}
+		if newBackup, err = writeBackup(newFile, newBackup); err != nil {
+			return "", "", fmt.Errorf(tr("备份新文件失败: %w"), err)
+		}
+		if err := replicateBackups(oldBackup, newBackup); err != nil {
+			return "", "", err
+		}
+	}
+	if gitBackups() {
+		repoFile, err := gitBackupPreMerge(dir, newFile)
+		if err != nil {
+			return "", "", err
+		}
+		if newBackup == "" {
+			newBackup = repoFile
+		}
+	}
+	batchMu.Lock()
+	lastOldBackup, lastNewBackup = oldBackup, newBackup
+	noteBackups(oldBackup, newBackup)
+	batchMu.Unlock()
+	return oldBackup, newBackup, nil
+}
+
+// backupFile 将src复制为新的备份文件dst，备份沿用源文件的权限，避免含密码的配置文件备份对其他用户可读。
+// 覆盖正在使用的配置文件(恢复备份)时使用restoreFile
+func backupFile(src, dst string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf(tr("打开源文件失败: %w"), err)
+	}
+	defer srcFile.Close()
+
+	info, err := srcFile.Stat()
+	if err != nil {
+		return fmt.Errorf(tr("打开源文件失败: %w"), err)
+	}
+	dstFile, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf(tr("创建目标文件失败: %w"), err)
+	}
+
+	buf := make([]byte, bufferSize)
+	if _, err := io.CopyBuffer(dstFile, srcFile, buf); err != nil {
+		dstFile.Close()
+		return fmt.Errorf(tr("复制文件内容失败: %w"), err)
+	}
+	if err := dstFile.Close(); err != nil {
+		return fmt.Errorf(tr("关闭目标文件失败: %w"), err)
+	}
+
+	debugf(tr("成功创建备份文件: %s"), dst, slog.String("file", dst))
+	return nil
+}
+
+// restoreFile 用src的内容原子地替换配置文件dst: 写入同目录下的临时文件后重命名，中途失败时dst保持不变，
+// 并与合并写入相同沿用dst原有的权限、属主与SELinux上下文；dst不存在时使用src的权限
+func restoreFile(src, dst string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf(tr("打开源文件失败: %w"), err)
+	}
+	defer srcFile.Close()
+
+	_, statErr := os.Stat(dst)
+	err = propmerge.AtomicWrite(dst, func(w io.Writer) error {
+		buf := make([]byte, bufferSize)
+		if _, err := io.CopyBuffer(w, srcFile, buf); err != nil {
+			return fmt.Errorf(tr("复制文件内容失败: %w"), err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if os.IsNotExist(statErr) {
+		if info, err := srcFile.Stat(); err == nil {
+			if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+				return fmt.Errorf(tr("设置文件权限失败: %w"), err)
+			}
+		}
+	}
+
+	debugf(tr("已用 %s 恢复 %s"), src, dst, slog.String("file", dst))
+	return nil
+}
+
+// printBackupPaths 输出本次运行创建的备份文件路径，便于迁移出错时恢复
+func printBackupPaths(oldBackup, newBackup string) {
+	if noBackup {
+		fmt.Println(tr("\n已指定-no-backup，未创建备份"))
+		return
+	}
+	if !copyBackups() {
+		fmt.Printf(tr("\n合并前后的新文件已提交到备份仓库: %s\n"), absPath(gitBackupDir()))
+		return
+	}
+	fmt.Println(tr("\n本次创建的备份文件:"))
+	fmt.Printf(tr("  旧文件备份: %s\n"), absPath(oldBackup))
+	fmt.Printf(tr("  新文件备份: %s\n"), absPath(newBackup))
+	if gitBackups() {
+		fmt.Printf(tr("  备份仓库: %s\n"), absPath(gitBackupDir()))
+	}
+}
+
+// backupEntry 描述备份目录中的一个备份文件
+type backupEntry struct {
+	path string
+	kind string // bak: 旧文件备份, new: 合并前的新文件, repair: 修复前的文件, rollback: 回滚前的文件, undo: 撤销前的文件
+	ts   string
+}
+
+// findBackups 查找指定文件在备份目录中的所有备份，按时间戳从新到旧排序
+func findBackups(filename string) ([]backupEntry, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf(tr("读取备份目录失败: %w"), err)
+	}
+
+	base := filepath.Base(filename)
+	var backups []backupEntry
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if name, kind, ts, ok := parseBackupName(e.Name()); ok && name == base {
+			backups = append(backups, backupEntry{path: filepath.Join(backupDir, e.Name()), kind: kind, ts: ts})
+		}
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].ts > backups[j].ts })
+	return backups, nil
+}
+
+// isBackupTimestamp 判断是否为备份文件名中使用的时间戳格式
+func isBackupTimestamp(ts string) bool {
+	_, err := time.Parse("20060102150405", ts)
+	return err == nil
+}
+
+// latestPreMergeBackup 返回文件最近一次被修改前的备份
+func latestPreMergeBackup(filename string) (backupEntry, error) {
+	backups, err := findBackups(filename)
+	if err != nil {
+		return backupEntry{}, err
+	}
+	for _, b := range backups {
+		if b.kind != "bak" {
+			return b, nil
+		}
+	}
+	if len(backups) > 0 {
+		return backups[0], nil
+	}
+	return backupEntry{}, fmt.Errorf(tr("备份目录 %s 中未找到 %s 的备份"), backupDir, filepath.Base(filename))
+}
+
+// backupKindNames 备份类型的显示名称
+var backupKindNames = map[string]string{"bak": "旧文件备份", "new": "合并前", "repair": "修复前", "rollback": "回滚前", "undo": "撤销前"}
+
+// runRollback 实现rollback子命令: 列出文件的可用备份，或将指定时间戳(默认最新)的备份恢复到当前文件，
+// 恢复前先备份当前状态
+func runRollback(args []string) error {
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	registerLangFlag(fs)
+	registerLogFlags(fs)
+	registerBackupFlags(fs)
+	list := fs.Bool("list", false, "仅列出可用备份")
+	ts := fs.String("ts", "", "要恢复的备份时间戳(格式20060102150405)，默认恢复最新的备份")
+	yes := fs.Bool("y", false, "跳过确认提示")
+	fs.StringVar(&backupIdentity, "identity", "", "解密age加密的备份使用的私钥文件，默认使用config-matcher.json中backupEncryption的identity")
+	fs.BoolVar(&verbose, "v", false, "启用详细输出模式")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), tr("用法: %s rollback [选项] 配置文件路径\n\n选项:\n"), os.Args[0])
+		printDefaults(fs)
+	}
+	parseFlags(fs, args)
+	if err := checkBackupFlags(); err != nil {
+		fatalf(tr("参数错误: %v"), err)
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	filename := fs.Arg(0)
+
+	backups, err := findBackups(filename)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		return fmt.Errorf(tr("备份目录 %s 中未找到 %s 的备份"), backupDir, filepath.Base(filename))
+	}
+
+	if *list {
+		printBackupList(filename, backups)
+		return nil
+	}
+
+	target, err := selectRollbackBackup(backups, *ts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(tr("将使用备份 %s (%s, %s) 覆盖 %s\n"), target.path, tr(backupKindNames[target.kind]), target.ts, filename)
+	if !*yes && !confirm("确认回滚?") {
+		fmt.Println(tr("已取消回滚"))
+		return nil
+	}
+
+	current := filepath.Join(backupDir, backupName(filepath.Base(filename), "rollback", time.Now().Format("20060102150405")))
+	if current, err = writeBackup(filename, current); err != nil {
+		return fmt.Errorf(tr("备份当前文件失败: %w"), err)
+	}
+	if err := restoreBackup(target.path, filename); err != nil {
+		return fmt.Errorf(tr("恢复备份失败: %w"), err)
+	}
+	recordJournal("rollback", "", filename, current, current)
+
+	fmt.Println(tr("回滚完成!"))
+	fmt.Printf(tr("回滚前的文件已备份至: %s\n"), current)
+	return nil
+}
+
+// printBackupList 输出一个文件的所有备份
+func printBackupList(filename string, backups []backupEntry) {
+	fmt.Printf(tr("%s 的可用备份:\n"), filename)
+	fmt.Println("----------------------------")
+	for _, b := range backups {
+		fmt.Printf("%s  %-8s  %s\n", b.ts, tr(backupKindNames[b.kind]), b.path)
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共 %d 个备份\n"), len(backups))
+}
+
+// runBackups 实现backups子命令: backups list列出指定文件的备份，未指定文件时列出备份目录中
+// (含批量模式的子目录)按原文件分组的所有备份
+func runBackups(args []string) error {
+	fs := flag.NewFlagSet("backups list", flag.ContinueOnError)
+	registerLangFlag(fs)
+	registerLogFlags(fs)
+	registerBackupFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), tr("用法: %s backups list [配置文件路径]\n       %s backups log [选项] [配置文件路径]\n\n列出备份目录 %s 中的备份，指定配置文件时只列出该文件的备份；log显示Git备份仓库的提交历史\n"), os.Args[0], os.Args[0], backupDir)
+		printDefaults(fs)
+	}
+	if len(args) > 0 && args[0] == "log" {
+		return runBackupsLog(args[1:])
+	}
+	if len(args) == 0 || args[0] != "list" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	parseFlags(fs, args[1:])
+	if err := checkBackupFlags(); err != nil {
+		fatalf(tr("参数错误: %v"), err)
+	}
+
+	if fs.NArg() > 0 {
+		backups, err := findBackups(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			return fmt.Errorf(tr("备份目录 %s 中未找到 %s 的备份"), backupDir, filepath.Base(fs.Arg(0)))
+		}
+		printBackupList(fs.Arg(0), backups)
+		return nil
+	}
+
+	groups, err := groupBackups(backupDir)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		fmt.Printf(tr("备份目录 %s 中没有备份\n"), backupDir)
+		return nil
+	}
+	names := make([]string, 0, len(groups))
+	total := 0
+	for name, backups := range groups {
+		names = append(names, name)
+		total += len(backups)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// 分组名为备份所在目录加原文件名，显示为相对于备份目录的路径
+		rel, err := filepath.Rel(backupDir, name)
+		if err != nil {
+			rel = name
+		}
+		printBackupList(rel, groups[name])
+		fmt.Println()
+	}
+	fmt.Printf(tr("共 %d 个文件, %d 个备份\n"), len(names), total)
+	return nil
+}
+
+// selectRollbackBackup 按时间戳选择要恢复的备份；未指定时选择最新的非旧文件备份。
+// 同一时间戳存在多个备份时优先选择合并前的新文件备份
+func selectRollbackBackup(backups []backupEntry, ts string) (backupEntry, error) {
+	priority := map[string]int{"new": 0, "repair": 1, "rollback": 2, "undo": 3, "bak": 4}
+	var chosen *backupEntry
+	for i := range backups {
+		b := &backups[i]
+		if ts == "" && b.kind == "bak" {
+			continue
+		}
+		if ts != "" && b.ts != ts {
+			continue
+		}
+		if chosen == nil || (b.ts == chosen.ts && priority[b.kind] < priority[chosen.kind]) {
+			chosen = b
+		}
+	}
+	if chosen == nil {
+		if ts != "" {
+			return backupEntry{}, fmt.Errorf(tr("未找到时间戳为 %s 的备份"), ts)
+		}
+		return backups[0], nil
+	}
+	return *chosen, nil
+}
+
+// confirm 在终端提示用户确认，输入y或yes时返回true
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N]: ", tr(prompt))
+	answer, err := stdin.ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// auditAgainstBackup 对比文件与其最近一次备份，还原上次运行所做的修改
+func auditAgainstBackup(filename string) error {
+	backup, err := latestPreMergeBackup(filename)
+	if err != nil {
+		return err
+	}
+
+	plain, cleanup, err := openBackup(backup.path)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	before, err := propmerge.ReadFile(plain)
+	if err != nil {
+		return fmt.Errorf(tr("读取备份文件失败: %w"), err)
+	}
+	after, err := propmerge.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf(tr("读取当前文件失败: %w"), err)
+	}
+
+	config, _, err := propmerge.LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	if masker, err = newMasker(config); err != nil {
+		return err
+	}
+
+	changes := propmerge.DiffProperties(before, after)
+	fmt.Printf(tr("审计文件: %s\n"), filename)
+	fmt.Printf(tr("对比备份: %s\n"), backup.path)
+	fmt.Println("----------------------------")
+	for _, c := range changes {
+		switch c.Op {
+		case "+":
+			fmt.Printf("+ %s=%s\n", c.Key, masker.Value(c.Key, c.After))
+		case "-":
+			fmt.Printf("- %s=%s\n", c.Key, masker.Value(c.Key, c.Before))
+		default:
+			fmt.Printf("~ %s: %s -> %s\n", c.Key, masker.Value(c.Key, c.Before), masker.Value(c.Key, c.After))
+		}
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共 %d 处键级变更\n"), len(changes))
+	return nil
+}
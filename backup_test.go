@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRestoreFileAtomic 恢复备份时原子地替换配置文件，沿用其权限，目标为符号链接时替换其指向的文件
+func TestRestoreFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"backup.properties": "a=1\n",
+		"live.properties":   "a=2\n",
+	})
+	backup := filepath.Join(dir, "backup.properties")
+	live := filepath.Join(dir, "live.properties")
+	link := filepath.Join(dir, "link.properties")
+	if err := os.Chmod(live, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("live.properties", link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := restoreFile(backup, link); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, live); got != "a=1\n" {
+		t.Errorf("live.properties = %q, want %q", got, "a=1\n")
+	}
+	if info, err := os.Stat(live); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("live.properties mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("link.properties is no longer a symlink (%v)", err)
+	}
+
+	// 读取备份失败时配置文件保持不变
+	if err := restoreFile(filepath.Join(dir, "missing.properties"), live); err == nil {
+		t.Fatal("restoreFile from a missing backup succeeded")
+	}
+	if got := readFile(t, live); got != "a=1\n" {
+		t.Errorf("live.properties = %q after a failed restore, want %q", got, "a=1\n")
+	}
+}
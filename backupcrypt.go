@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// backupIdentity 为解密age备份使用的私钥文件(-identity)，为空时使用config-matcher.json中backupEncryption的identity
+var backupIdentity string
+
+// backupCipherSuffixes 为加密的备份在备份文件名后追加的后缀
+var backupCipherSuffixes = map[string]string{".age": propmerge.BackupCipherAge, ".gpg": propmerge.BackupCipherGPG}
+
+// loadBackupEncryption 返回config-matcher.json中的backupEncryption
+func loadBackupEncryption() (propmerge.BackupEncryption, error) {
+	config, _, err := propmerge.LoadConfig(configFile)
+	if err != nil {
+		return propmerge.BackupEncryption{}, err
+	}
+	return config.BackupEncryption, nil
+}
+
+// backupCipher 按文件名后缀返回备份的加密方式，未加密时为空
+func backupCipher(path string) string {
+	return backupCipherSuffixes[filepath.Ext(path)]
+}
+
+// trimCipherSuffix 去掉备份文件名中加密方式的后缀
+func trimCipherSuffix(name string) string {
+	if backupCipher(name) != "" {
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name
+}
+
+// backupExists 判断备份文件(含加密后的文件)是否已存在
+func backupExists(path string) bool {
+	for _, p := range []string{path, path + ".age", path + ".gpg"} {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// writeBackup 将src备份到dst，返回备份文件的路径。config-matcher.json中配置了backupEncryption时
+// 由age或gpg加密后写入，文件名追加.age或.gpg，明文不写入备份目录
+func writeBackup(src, dst string) (string, error) {
+	enc, err := loadBackupEncryption()
+	if err != nil {
+		return "", err
+	}
+	if enc.Type == "" {
+		return dst, backupFile(src, dst)
+	}
+	return writeEncrypted(enc, dst, func(w io.Writer) error {
+		f, err := os.Open(src)
+		if err != nil {
+			return fmt.Errorf(tr("打开源文件失败: %w"), err)
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+}
+
+// writeBackupStream 将write生成的内容作为备份写入dst(权限0600)，返回备份文件的路径。配置了backupEncryption时
+// 内容经管道直接交给age或gpg加密，明文不落盘。失败时删除已写入的部分
+func writeBackupStream(dst string, write func(w io.Writer) error) (string, error) {
+	enc, err := loadBackupEncryption()
+	if err != nil {
+		return "", err
+	}
+	if enc.Type != "" {
+		return writeEncrypted(enc, dst, write)
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", fmt.Errorf(tr("创建目标文件失败: %w"), err)
+	}
+	err = write(out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return "", err
+	}
+	debugf(tr("成功创建备份文件: %s"), dst, slog.String("file", dst))
+	return dst, nil
+}
+
+// writeEncrypted 将write生成的内容经管道交给age或gpg加密后写入dst加上.age或.gpg后缀的文件
+func writeEncrypted(enc propmerge.BackupEncryption, dst string, write func(w io.Writer) error) (string, error) {
+	var args []string
+	switch enc.Type {
+	case propmerge.BackupCipherAge:
+		args = []string{"--encrypt"}
+		for _, r := range enc.Recipients {
+			args = append(args, "--recipient", r)
+		}
+	case propmerge.BackupCipherGPG:
+		args = []string{"--batch", "--yes", "--trust-model", "always", "--output", "-", "--encrypt"}
+		for _, r := range enc.Recipients {
+			args = append(args, "--recipient", r)
+		}
+	}
+	dst += "." + enc.Type
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", fmt.Errorf(tr("创建目标文件失败: %w"), err)
+	}
+	pr, pw := io.Pipe()
+	written := make(chan error, 1)
+	go func() {
+		err := write(pw)
+		pw.CloseWithError(err)
+		written <- err
+	}()
+	err = pipeCipher(enc.Type, pr, out, args...)
+	// 加密命令提前退出时让write不再阻塞在管道上
+	pr.Close()
+	if writeErr := <-written; err == nil {
+		err = writeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return "", fmt.Errorf(tr("加密备份失败: %w"), err)
+	}
+	debugf(tr("成功创建加密的备份文件: %s"), dst, slog.String("file", dst))
+	return dst, nil
+}
+
+// openBackup 返回可直接读取的备份文件: 未加密时为备份本身；加密时解密到仅当前用户可读的临时文件，
+// 调用方使用后调用cleanup删除。age备份需要私钥文件，GPG备份使用gpg密钥环中的私钥
+func openBackup(path string) (plain string, cleanup func(), err error) {
+	cipher := backupCipher(path)
+	if cipher == "" {
+		return path, func() {}, nil
+	}
+
+	var args []string
+	switch cipher {
+	case propmerge.BackupCipherAge:
+		identity := backupIdentity
+		if identity == "" {
+			enc, err := loadBackupEncryption()
+			if err != nil {
+				return "", nil, err
+			}
+			identity = enc.Identity
+		}
+		if identity == "" {
+			return "", nil, fmt.Errorf(tr("解密备份 %s 需要age私钥，请通过-identity或config-matcher.json中backupEncryption的identity指定"), path)
+		}
+		args = []string{"--decrypt", "--identity", identity}
+	case propmerge.BackupCipherGPG:
+		args = []string{"--quiet", "--yes", "--output", "-", "--decrypt"}
+	}
+	tmp, err := os.CreateTemp("", "update_config-backup-*")
+	if err != nil {
+		return "", nil, fmt.Errorf(tr("创建临时文件失败: %w"), err)
+	}
+	cleanup = trackTemp(tmp.Name())
+	err = runCipher(cipher, path, tmp, args...)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf(tr("解密备份 %s 失败: %w"), path, err)
+	}
+	debugf(tr("已解密备份 %s"), path, slog.String("file", path))
+	return tmp.Name(), cleanup, nil
+}
+
+// restoreBackup 用备份原子地替换dst，加密的备份先解密
+func restoreBackup(backup, dst string) error {
+	plain, cleanup, err := openBackup(backup)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	return restoreFile(plain, dst)
+}
+
+// runCipher 执行age或gpg命令处理文件input，结果写入out
+func runCipher(name, input string, out io.Writer, args ...string) error {
+	return pipeCipher(name, nil, out, append(args, input)...)
+}
+
+// pipeCipher 执行age或gpg命令，in不为nil时作为命令的标准输入，结果写入out
+func pipeCipher(name string, in io.Reader, out io.Writer, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(runCtx, name, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = in, out, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
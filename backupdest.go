@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// backupTargets 为config-matcher.json中backupDir指定的备份目的地，由newMerger设置，读写时持有batchMu
+var backupTargets propmerge.BackupDestinations
+
+// replicateBackups 将本次创建的备份文件复制到backupDir中的每个目的地，目的地下的路径为
+// 主机名/备份文件相对于备份目录的路径，多台主机共用一个目的地时互不覆盖。
+// 复制失败时按目的地的onError输出警告或返回错误，返回错误时合并中止
+func replicateBackups(files ...string) error {
+	batchMu.Lock()
+	targets := backupTargets
+	batchMu.Unlock()
+	if len(targets) == 0 {
+		return nil
+	}
+
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	for _, d := range targets {
+		for _, local := range files {
+			if local == "" {
+				continue
+			}
+			name, err := filepath.Rel(backupDir, local)
+			if err != nil || strings.HasPrefix(name, "..") {
+				name = filepath.Base(local)
+			}
+			name = path.Join(host, filepath.ToSlash(name))
+			if err := copyToDestination(d, local, name); err != nil {
+				err = fmt.Errorf(tr("复制备份 %s 到 %s 失败: %w"), local, redactURL(d.URL), err)
+				if d.OnError == propmerge.BackupOnErrorAbort {
+					return err
+				}
+				warnf("%v", err)
+				continue
+			}
+			debugf(tr("已复制备份 %s 到 %s"), local, redactURL(d.URL))
+		}
+	}
+	return nil
+}
+
+// redactURL 去掉地址中可能带有的密码，用于日志与错误信息
+func redactURL(raw string) string {
+	scheme, rest, ok := strings.Cut(raw, "://")
+	if !ok {
+		return raw
+	}
+	authority, p := rest, ""
+	if slash := strings.Index(rest, "/"); slash != -1 {
+		authority, p = rest[:slash], rest[slash:]
+	}
+	if at := strings.LastIndex(authority, "@"); at != -1 {
+		if user, _, hasPassword := strings.Cut(authority[:at], ":"); hasPassword {
+			authority = user + ":****" + authority[at:]
+		}
+	}
+	return scheme + "://" + authority + p
+}
+
+// copyToDestination 将本地文件复制到目的地下的name
+func copyToDestination(d propmerge.BackupDestination, local, name string) error {
+	switch d.Scheme() {
+	case "file":
+		dst := filepath.Join(strings.TrimPrefix(d.URL, "file://"), filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return err
+		}
+		return backupFile(local, dst)
+	case "sftp":
+		r, err := parseSSH(d.URL)
+		if err != nil {
+			return err
+		}
+		r.identity = d.IdentityFile
+		dst := path.Join(r.path, name)
+		// 逐级创建目录，已存在时忽略mkdir的失败
+		var commands []string
+		for dir := path.Dir(dst); dir != "/" && dir != "."; dir = path.Dir(dir) {
+			commands = append([]string{"-mkdir " + sftpQuote(dir)}, commands...)
+		}
+		commands = append(commands, "put -p "+sftpQuote(local)+" "+sftpQuote(dst))
+		return r.sftp(commands...)
+	case "s3":
+		return putS3(d, local, name)
+	case "oss":
+		return putOSS(d, local, name)
+	}
+	return fmt.Errorf(tr("不支持的备份目的地: %s"), d.URL)
+}
+
+// bucketKey 将s3://桶/前缀 形式的地址拆分为桶名与对象键(前缀/name)
+func bucketKey(raw, name string) (string, string, error) {
+	_, rest, _ := strings.Cut(raw, "://")
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf(tr("无效的备份目的地 %s，格式应为 %s://桶/前缀"), raw, strings.SplitN(raw, ":", 2)[0])
+	}
+	return bucket, strings.TrimPrefix(path.Join(prefix, name), "/"), nil
+}
+
+// endpointURL 返回endpoint的scheme与主机，endpoint未写scheme时使用HTTPS
+func endpointURL(endpoint string) (string, string) {
+	if scheme, host, ok := strings.Cut(endpoint, "://"); ok {
+		return scheme, strings.TrimSuffix(host, "/")
+	}
+	return "https", strings.TrimSuffix(endpoint, "/")
+}
+
+// awsEscape 按SigV4的规则编码路径: 除字母、数字与-._~外一律编码，/保持不变
+func awsEscape(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-._~/", c) != -1 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSum(h func() hash.Hash, key []byte, data string) []byte {
+	mac := hmac.New(h, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// putS3 以AWS Signature V4签名的PUT请求上传对象。未指定endpoint时使用AWS的虚拟主机式地址，
+// 指定时(如MinIO)使用路径式地址
+func putS3(d propmerge.BackupDestination, local, name string) error {
+	bucket, key, err := bucketKey(d.URL, name)
+	if err != nil {
+		return err
+	}
+	region := firstNonEmpty(d.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1")
+	accessKey := firstNonEmpty(d.AccessKeyID, os.Getenv("AWS_ACCESS_KEY_ID"))
+	secretKey := firstNonEmpty(d.SecretAccessKey, os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	token := firstNonEmpty(d.SessionToken, os.Getenv("AWS_SESSION_TOKEN"))
+	if accessKey == "" || secretKey == "" {
+		return errors.New(tr("未指定S3凭据(accessKeyId与secretAccessKey或AWS_ACCESS_KEY_ID与AWS_SECRET_ACCESS_KEY)"))
+	}
+	body, err := os.ReadFile(local)
+	if err != nil {
+		return err
+	}
+
+	scheme, host := "https", bucket+".s3."+region+".amazonaws.com"
+	uri := "/" + awsEscape(key)
+	if d.Endpoint != "" {
+		scheme, host = endpointURL(d.Endpoint)
+		uri = "/" + awsEscape(bucket) + uri
+	}
+	now := time.Now().UTC()
+	amzDate, day := now.Format("20060102T150405Z"), now.Format("20060102")
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	headers := map[string]string{"host": host, "x-amz-content-sha256": payloadHash, "x-amz-date": amzDate}
+	if token != "" {
+		headers["x-amz-security-token"] = token
+	}
+	names := make([]string, 0, len(headers))
+	for h := range headers {
+		names = append(names, h)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, h := range names {
+		canonicalHeaders.WriteString(h + ":" + headers[h] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{http.MethodPut, uri, "", canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	signingKey := []byte("AWS4" + secretKey)
+	for _, part := range []string{day, region, "s3", "aws4_request"} {
+		signingKey = hmacSum(sha256.New, signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSum(sha256.New, signingKey, stringToSign))
+
+	req, err := http.NewRequestWithContext(runCtx, http.MethodPut, scheme+"://"+host+uri, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf(tr("创建请求失败: %w"), err)
+	}
+	for _, h := range names {
+		if h != "host" {
+			req.Header.Set(h, headers[h])
+		}
+	}
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return sendObject(req)
+}
+
+// putOSS 以阿里云OSS的签名(HMAC-SHA1)上传对象，endpoint为地域的访问地址
+func putOSS(d propmerge.BackupDestination, local, name string) error {
+	bucket, key, err := bucketKey(d.URL, name)
+	if err != nil {
+		return err
+	}
+	endpoint := firstNonEmpty(d.Endpoint, os.Getenv("OSS_ENDPOINT"))
+	accessKey := firstNonEmpty(d.AccessKeyID, os.Getenv("OSS_ACCESS_KEY_ID"))
+	secretKey := firstNonEmpty(d.SecretAccessKey, os.Getenv("OSS_ACCESS_KEY_SECRET"))
+	token := firstNonEmpty(d.SessionToken, os.Getenv("OSS_SESSION_TOKEN"))
+	if endpoint == "" {
+		return errors.New(tr("未指定OSS地址(endpoint或OSS_ENDPOINT)"))
+	}
+	if accessKey == "" || secretKey == "" {
+		return errors.New(tr("未指定OSS凭据(accessKeyId与secretAccessKey或OSS_ACCESS_KEY_ID与OSS_ACCESS_KEY_SECRET)"))
+	}
+	body, err := os.ReadFile(local)
+	if err != nil {
+		return err
+	}
+
+	const contentType = "application/octet-stream"
+	date := time.Now().UTC().Format(http.TimeFormat)
+	var ossHeaders string
+	if token != "" {
+		ossHeaders = "x-oss-security-token:" + token + "\n"
+	}
+	stringToSign := http.MethodPut + "\n\n" + contentType + "\n" + date + "\n" + ossHeaders + "/" + bucket + "/" + key
+	signature := base64.StdEncoding.EncodeToString(hmacSum(sha1.New, []byte(secretKey), stringToSign))
+
+	scheme, host := endpointURL(endpoint)
+	req, err := http.NewRequestWithContext(runCtx, http.MethodPut, scheme+"://"+bucket+"."+host+"/"+awsEscape(key), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf(tr("创建请求失败: %w"), err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Date", date)
+	if token != "" {
+		req.Header.Set("x-oss-security-token", token)
+	}
+	req.Header.Set("Authorization", "OSS "+accessKey+":"+signature)
+	return sendObject(req)
+}
+
+// sendObject 发送上传请求，失败时返回状态与S3/OSS错误响应中的Code与Message
+func sendObject(req *http.Request) error {
+	req.Header.Set("User-Agent", "update_config/"+version)
+	client := &http.Client{Timeout: httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	payload, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(payload, &e) == nil && e.Code != "" {
+		return fmt.Errorf(tr("HTTP状态 %s: %s"), resp.Status, e.Code+": "+e.Message)
+	}
+	return fmt.Errorf(tr("HTTP状态 %s"), resp.Status)
+}
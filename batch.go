@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// batchResult 记录批量模式下一对文件的处理结果
+type batchResult struct {
+	rel     string // 相对于旧/新目录的路径
+	status  string
+	kept    int
+	changed int
+	keys    []propmerge.KeyResult // 各保留参数的处理结果，用于汇总报告
+	err     error
+}
+
+// batchMu 在并行批量处理时保护各文件共用的全局状态: newMerger依据配置设置的掩码、重复键策略与格式插件，
+// 最近一次的备份路径以及合并结果的汇总
+var batchMu sync.Mutex
+
+// runBatch 按相对路径配对旧发布目录与新发布目录中匹配batchGlob的文件，逐对合并并输出汇总。
+// batchParallel大于1时由同样数量的worker并行处理，汇总按相对路径排序输出，与处理完成的先后无关。
+// 任一文件处理失败时继续处理其余文件，最后返回错误
+func runBatch(oldDir, newDir string) error {
+	for _, dir := range []string{oldDir, newDir} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf(tr("读取目录失败: %w"), err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf(tr("%s 不是目录"), dir)
+		}
+	}
+
+	oldFiles, err := globFiles(oldDir, batchGlob)
+	if err != nil {
+		return err
+	}
+	newFiles, err := globFiles(newDir, batchGlob)
+	if err != nil {
+		return err
+	}
+	debugf(tr("旧目录匹配%d个文件, 新目录匹配%d个文件 (规则: %s)"), len(oldFiles), len(newFiles), batchGlob)
+
+	rels := make(map[string]bool, len(oldFiles)+len(newFiles))
+	for rel := range oldFiles {
+		rels[rel] = true
+	}
+	for rel := range newFiles {
+		rels[rel] = true
+	}
+	sorted := make([]string, 0, len(rels))
+	for rel := range rels {
+		sorted = append(sorted, rel)
+	}
+	sort.Strings(sorted)
+
+	results := make([]batchResult, len(sorted))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(batchParallel, len(sorted)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				rel := sorted[i]
+				switch {
+				case !newFiles[rel]:
+					results[i] = batchResult{rel: rel, status: "跳过(新目录中不存在)"}
+				case !oldFiles[rel]:
+					results[i] = batchResult{rel: rel, status: "跳过(旧目录中不存在)"}
+				default:
+					debugf(tr("处理文件: %s"), rel, slog.String("file", rel))
+					results[i] = mergePair(rel, filepath.Join(oldDir, filepath.FromSlash(rel)), filepath.Join(newDir, filepath.FromSlash(rel)))
+				}
+			}
+		}()
+	}
+	for i := range sorted {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return printBatchSummary(results)
+}
+
+// globFiles 返回目录下所有相对路径匹配pattern的普通文件，路径以/分隔
+func globFiles(root, pattern string) (map[string]bool, error) {
+	files := make(map[string]bool)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if matchGlob(pattern, rel) {
+			files[rel] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf(tr("扫描目录 %s 失败: %w"), root, err)
+	}
+	return files, nil
+}
+
+// matchGlob 判断以/分隔的相对路径是否匹配glob规则，**匹配零个或多个目录层级，
+// 其余部分按path.Match的规则逐段匹配
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// mergePair 合并一对文件；备份写入备份目录下与相对路径对应的子目录，避免同名文件的备份相互覆盖。
+// 每个文件都重新加载配置并编译自己的合并器，并行处理时互不共享规则状态
+func mergePair(rel, oldFile, newFile string) batchResult {
+	batchMu.Lock()
+	merger, err := newMerger(oldFile, newFile)
+	format := fileFormat(oldFile, newFile)
+	batchMu.Unlock()
+	if err != nil {
+		return batchResult{rel: rel, status: "失败", err: fmt.Errorf(tr("加载配置失败: %w"), err)}
+	}
+	return mergeInto(rel, merger, format, oldFile, newFile, "", filepath.Join(backupDir, filepath.FromSlash(path.Dir(rel))))
+}
+
+// mergeInto 按格式用已有的合并器合并一对文件: output为空时备份到backups目录后写回新文件，
+// 否则将合并结果写入output，新文件保持不变，也不创建备份
+func mergeInto(rel string, merger *propmerge.Merger, format, oldFile, newFile, output, backups string) batchResult {
+	result := batchResult{rel: rel}
+	fail := func(err error) batchResult {
+		result.status = "失败"
+		result.err = err
+		return result
+	}
+
+	if err := canceled(); err != nil {
+		return fail(err)
+	}
+	if err := checkOverlap(merger, format, oldFile, newFile); err != nil {
+		return fail(err)
+	}
+	batchMu.Lock()
+	structured := pathMerge(merger, format)
+	batchMu.Unlock()
+	target := newFile
+	if output != "" {
+		target = output
+	}
+	if !dryRun {
+		lock, err := lockTarget(target)
+		if err != nil {
+			return fail(err)
+		}
+		defer lock.release()
+	}
+
+	var merged propmerge.Result
+	if dryRun || structured != nil || output != "" {
+		oldLines, err := propmerge.ReadFile(oldFile)
+		if err != nil {
+			return fail(fmt.Errorf(tr("读取旧文件失败: %w"), err))
+		}
+		newLines, err := propmerge.ReadFile(newFile)
+		if err != nil {
+			return fail(fmt.Errorf(tr("读取新文件失败: %w"), err))
+		}
+		merge := merger.MergeLines
+		if structured != nil {
+			merge = structured
+		}
+		merged, err = merge(oldLines, newLines)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := canceled(); err != nil {
+		return fail(err)
+	}
+	switch {
+	case dryRun:
+	case output != "":
+		if err := propmerge.WriteFileEncoding(output, merged.Lines, outputEncoding); err != nil {
+			return fail(fmt.Errorf(tr("写入输出文件失败: %w"), err))
+		}
+		if _, err := verifyMerged(output, "", merged); err != nil {
+			return fail(err)
+		}
+		if err := checkMerged(output, ""); err != nil {
+			return fail(err)
+		}
+	default:
+		oldBackup, newBackup, err := createBackups(backups, oldFile, newFile)
+		if err != nil {
+			return fail(err)
+		}
+		if structured != nil {
+			err = propmerge.WriteFileEncoding(newFile, merged.Lines, outputEncoding)
+		} else {
+			merged, err = merger.MergeFileContext(runCtx, oldFile, newFile)
+		}
+		if err != nil {
+			return fail(fmt.Errorf(tr("更新新文件失败: %w"), err))
+		}
+		if err := verifyWrite(oldFile, newFile, oldBackup, newBackup, merged); err != nil {
+			return fail(err)
+		}
+		if err := checkMerged(newFile, newBackup); err != nil {
+			return fail(err)
+		}
+		if err := gitBackupMerged(newFile); err != nil {
+			return fail(err)
+		}
+	}
+
+	batchMu.Lock()
+	recordResult(merged)
+	batchMu.Unlock()
+	result.keys = merged.Keys
+	for _, k := range merged.Keys {
+		if k.Written() {
+			result.kept++
+		}
+		if k.Changed() {
+			result.changed++
+		}
+	}
+	result.status = "已合并"
+	if dryRun {
+		result.status = "预览"
+	}
+	return result
+}
+
+// printBatchSummary 输出每个文件的处理结果，存在失败时返回错误
+func printBatchSummary(results []batchResult) error {
+	if dryRun {
+		fmt.Println(tr("预览模式，未写入任何文件。"))
+	}
+	fmt.Println(tr("批量处理结果:"))
+	fmt.Println("----------------------------")
+	merged, skipped, failed := 0, 0, 0
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			fmt.Printf("%s %s: %v\n", red("["+tr(r.status)+"]"), r.rel, r.err)
+			failed++
+		case strings.HasPrefix(r.status, "跳过"):
+			fmt.Printf("%s %s\n", yellow("["+tr(r.status)+"]"), r.rel)
+			skipped++
+		default:
+			fmt.Printf(tr("%s %s: 保留%d个参数, %d个值发生变化\n"), green("["+tr(r.status)+"]"), r.rel, r.kept, r.changed)
+			merged++
+		}
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共 %d 个文件: 合并 %d, 跳过 %d, 失败 %d\n"), len(results), merged, skipped, failed)
+	if !dryRun && merged > 0 && !noBackup {
+		fmt.Printf(tr("备份文件位于: %s\n"), absPath(backupDir))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf(tr("%d 个文件处理失败"), failed)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// checkRulesFile 为合并结果的校验规则文件(-check-rules)
+var checkRulesFile string
+
+// checkFile 按校验规则检查配置文件(归档文件检查其中的配置条目)，输出每处未通过的参数并返回其数量
+func checkFile(rules propmerge.CheckRules, filename string) (int, error) {
+	lines, _, _, err := readConfigLines(filename)
+	if err != nil {
+		return 0, err
+	}
+	return checkLines(rules, filename, fileFormat(filename, filename), lines)
+}
+
+// checkLines 按校验规则检查指定格式的配置内容，filename用于输出未通过的参数。
+// 提供了Jasypt密码时先解密加密值，按明文检查，无法解密的值同样视为未通过
+func checkLines(rules propmerge.CheckRules, filename, format string, lines []string) (int, error) {
+	entries, err := parseConfig(filename, format, lines)
+	if err != nil {
+		return 0, err
+	}
+	j, err := jasyptCodec()
+	if err != nil {
+		return 0, err
+	}
+	var found []propmerge.Violation
+	if j != nil {
+		entries, found = propmerge.DecryptEntries(entries, j)
+	}
+
+	found = append(found, rules.Check(entries)...)
+	var b strings.Builder
+	for _, v := range found {
+		if v.Line > 0 {
+			fmt.Fprintf(&b, "%s:%d: %s: %s\n", filename, v.Line, v.Key, v.Message)
+		} else {
+			fmt.Fprintf(&b, "%s: %s: %s\n", filename, v.Key, v.Message)
+		}
+	}
+	// 一次性输出，批量模式并行处理时各文件的结果不会交错
+	fmt.Print(b.String())
+	return len(found), nil
+}
+
+// parseConfig 按格式(含插件格式)解析配置内容中的键值，filename用于错误信息
+func parseConfig(filename, format string, lines []string) ([]propmerge.Entry, error) {
+	var entries []propmerge.Entry
+	var err error
+	if p, ok := findPlugin(format); ok {
+		entries, err = p.ParseContext(runCtx, lines)
+	} else {
+		entries, err = propmerge.ParseEntries(format, lines)
+	}
+	if err != nil {
+		return nil, fmt.Errorf(tr("解析 %s 失败: %w"), filename, err)
+	}
+	return entries, nil
+}
+
+// checkMerged 按-check-rules校验合并后的文件，未通过时用合并前的备份恢复该文件并返回校验错误，
+// 保证不会留下未通过校验的配置
+func checkMerged(filename, backup string) error {
+	if checkRulesFile == "" {
+		return nil
+	}
+	rules, err := propmerge.LoadCheckRules(checkRulesFile)
+	if err != nil {
+		return invalid(err)
+	}
+	failed, err := checkFile(rules, filename)
+	if err != nil {
+		return fmt.Errorf(tr("校验合并结果失败: %w"), err)
+	}
+	if failed == 0 {
+		debugf(tr("合并结果通过校验: %s"), filename)
+		return nil
+	}
+
+	if backup != "" {
+		if err := restoreBackup(backup, filename); err != nil {
+			return fmt.Errorf(tr("合并结果未通过校验(%d处)，且恢复备份失败: %w"), failed, err)
+		}
+		warnf(tr("已从备份 %s 恢复 %s"), backup, filename)
+	}
+	return invalid(fmt.Errorf(tr("合并结果未通过校验: %d处错误"), failed))
+}
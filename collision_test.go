@@ -0,0 +1,53 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestOnCollisionPolicies 重命名后的键与新文件中已有键冲突时，各-on-collision策略的写入内容、冲突汇总与退出码
+func TestOnCollisionPolicies(t *testing.T) {
+	const config = `{"patternKeys": "^web\\.", "renames": {"web.back.upLoadPath": "web.back.uploadPath"}}`
+	tests := []struct {
+		name    string
+		policy  string
+		newFile string
+		want    string // 合并后的新文件
+		report  string // 冲突汇总中的处理方式，为空表示没有冲突
+		code    int
+	}{
+		{"old-wins", "old-wins", "web.back.uploadPath=/new\nserver.port=8080\n", "web.back.uploadPath=/old\nserver.port=8080\n", "(已写入旧值)", exitConflict},
+		{"new-wins", "new-wins", "web.back.uploadPath=/new\nserver.port=8080\n", "web.back.uploadPath=/new\nserver.port=8080\n", "(未写入旧值)", exitConflict},
+		{"fail", "fail", "web.back.uploadPath=/new\nserver.port=8080\n", "web.back.uploadPath=/new\nserver.port=8080\n", "(已中止)", exitConflict},
+		// 新文件中的值与旧值相同时不算冲突，fail也不中止
+		{"same value", "fail", "web.back.uploadPath=/old\nserver.port=8080\n", "web.back.uploadPath=/old\nserver.port=8080\n", "", exitUnchanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				configFile:       config,
+				"old.properties": "web.back.upLoadPath=/old\n",
+				"new.properties": tt.newFile,
+			})
+			stdout, stderr, code := runMain(t, dir, "-no-backup", "-on-collision", tt.policy, "old.properties", "new.properties")
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d\nstdout:\n%s\nstderr:\n%s", code, tt.code, stdout, stderr)
+			}
+			if got := readFile(t, filepath.Join(dir, "new.properties")); got != tt.want {
+				t.Errorf("new.properties =\n%s\nwant\n%s", got, tt.want)
+			}
+			if tt.report == "" {
+				if strings.Contains(stdout, "重命名冲突") {
+					t.Errorf("unexpected collision report:\n%s", stdout)
+				}
+				return
+			}
+			line := "web.back.upLoadPath -> web.back.uploadPath: 新文件中已存在 web.back.uploadPath=/new " + tt.report
+			if !strings.Contains(stdout, line) || !strings.Contains(stdout, "共 1 处重命名冲突") {
+				t.Errorf("stdout is missing the collision report %q:\n%s", line, stdout)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"unicode"
+)
+
+// noColor 为true时不输出ANSI颜色(-no-color)
+var noColor bool
+
+// colorEnabled 为true时汇总与diff使用ANSI颜色，由setupColor根据标准输出是否为终端设置
+var colorEnabled bool
+
+// ANSI颜色代码
+const (
+	colorRed    = "31"
+	colorGreen  = "32"
+	colorYellow = "33"
+	colorCyan   = "36"
+	colorBold   = "1"
+)
+
+// setupColor 决定是否使用颜色: 标准输出为终端，且未指定-no-color、未设置NO_COLOR环境变量(见no-color.org)。
+// 标准输出被改为标准错误(标准输入输出模式)后需要重新调用
+func setupColor() {
+	colorEnabled = !noColor && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && isTerminal(os.Stdout)
+}
+
+// isTerminal 判断文件是否为终端(字符设备)，重定向到文件或管道时返回false
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize 在启用颜色时用指定的ANSI颜色包裹文本
+func colorize(code, s string) string {
+	if !colorEnabled || code == "" || s == "" {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+func green(s string) string  { return colorize(colorGreen, s) }
+func red(s string) string    { return colorize(colorRed, s) }
+func yellow(s string) string { return colorize(colorYellow, s) }
+
+// diffColor 返回unified diff中一行使用的颜色: 新增为绿色，删除为红色，块头为青色
+func diffColor(line string) string {
+	switch {
+	case strings.HasPrefix(line, "---") || strings.HasPrefix(line, "+++"):
+		return colorBold
+	case strings.HasPrefix(line, "@@"):
+		return colorCyan
+	case strings.HasPrefix(line, "+"):
+		return colorGreen
+	case strings.HasPrefix(line, "-"):
+		return colorRed
+	}
+	return ""
+}
+
+// displayWidth 返回文本在终端中占用的列数，中日韩等全角字符占两列
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) ||
+			unicode.Is(unicode.Hangul, r) || r >= 0x3000 && r <= 0x303F || r >= 0xFF00 && r <= 0xFF60 || r >= 0xFFE0 && r <= 0xFFE6:
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+// padRight 用空格将文本补齐到指定的显示宽度
+func padRight(s string, width int) string {
+	if n := width - displayWidth(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// subcommand 描述一个子命令
+type subcommand struct {
+	name    string
+	summary string
+	action  string // 失败时日志中的动作名称
+	run     func(args []string) error
+}
+
+// subcommands 为所有子命令；第一个参数不是子命令时按原有的平铺参数执行合并，保持旧脚本可用
+var subcommands = []subcommand{
+	{"merge", "将旧文件中的保留参数合并到新文件(与不带子命令的调用相同)", "合并", mergeCommand("merge", "将旧文件中的保留参数合并到新文件", nil)},
+	{"diff", "输出合并结果与新文件的unified diff，不写入任何文件", "预览", mergeCommand("diff", "输出合并结果与新文件的unified diff，不写入任何文件", func() { dryRun, showDiff = true, true })},
+	{"dry-run", "预览合并计划，不写入任何文件", "预览", mergeCommand("dry-run", "预览合并计划，不写入任何文件", func() { dryRun = true })},
+	{"rollback", "列出或恢复配置文件的备份", "回滚", runRollback},
+	{"undo", "按操作日志撤销对配置文件的最近一次操作", "撤销", runUndo},
+	{"backups", "查看备份: backups list|log [配置文件]", "查看备份", runBackups},
+	{"prune", "按保留策略清理备份", "清理备份", runPrune},
+	{"snapshot", "查看或恢复批量模式创建的目录快照: snapshot list|restore 快照ID", "恢复快照", runSnapshot},
+	{"validate", "校验config-matcher.json与配置文件", "校验", runValidate},
+	{"rules", "调试保留规则: rules test 键[=值]或配置文件...", "测试规则", runRules},
+	{"watch", "监视模板目录，新模板落地后自动合并", "监视", runWatch},
+	{"serve", "提供合并、规则与备份查询的HTTP API", "API服务", runServe},
+	{"daemon", "按任务清单中的cron表达式定时执行合并任务", "定时任务", runDaemon},
+}
+
+// findSubcommand 按名称查找子命令
+func findSubcommand(name string) (subcommand, bool) {
+	for _, cmd := range subcommands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return subcommand{}, false
+}
+
+// printSubcommands 输出子命令列表
+func printSubcommands(w io.Writer) {
+	fmt.Fprintln(w, tr("子命令:"))
+	for _, cmd := range subcommands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, tr(cmd.summary))
+	}
+	fmt.Fprintf(w, tr("使用 %s <子命令> -h 查看子命令的选项\n"), os.Args[0])
+}
+
+// mergeCommand 返回merge、diff、dry-run子命令的入口: 使用与平铺调用相同的选项，
+// preset在解析参数后设置该子命令固定的行为
+func mergeCommand(name, summary string, preset func()) func(args []string) error {
+	return func(args []string) error {
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		registerMergeFlags(fs)
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), tr("用法: %s %s [选项] 旧配置文件路径 新配置文件路径\n\n%s\n\n选项:\n"), os.Args[0], name, tr(summary))
+			printDefaults(fs)
+		}
+		parseFlags(fs, args)
+		if preset != nil {
+			preset()
+		}
+		code := run(fs)
+		writeRunSummary(code, nil)
+		if code != exitChanged {
+			os.Exit(code)
+		}
+		return nil
+	}
+}
+
+// registerMergeFlags 在fs上注册合并相关的选项
+func registerMergeFlags(fs *flag.FlagSet) {
+	registerLangFlag(fs)
+	registerLogFlags(fs)
+	fs.BoolVar(&verbose, "v", false, "启用详细输出模式")
+	fs.BoolVar(&quiet, "quiet", false, "不输出汇总等信息，只输出警告与错误；与-output-format json同时使用时标准输出中只有JSON汇总")
+	fs.StringVar(&outputFormat, "output-format", outputText, "运行结束时汇总的格式: text|json，json时在标准输出写入一个包含状态、退出码、各动作统计与备份路径的JSON对象，其余信息写入标准错误")
+	fs.BoolVar(&noColor, "no-color", false, "不使用颜色输出(标准输出不是终端或设置了NO_COLOR环境变量时自动关闭)")
+	fs.BoolVar(&showVersion, "version", false, "显示版本信息")
+	fs.BoolVar(&repairMode, "repair", false, "修复模式: 根据旧文件的保留参数修复被错误合并的新文件，第三个参数可指定新模板，此时以模板与保留参数重建文件")
+	fs.StringVar(&auditFile, "audit", "", "审计模式: 对比指定文件与其最近一次备份，显示键级变更")
+	fs.BoolVar(&springRelaxed, "spring-relaxed", false, "按Spring Boot宽松绑定规则匹配键(kebab/驼峰/下划线/大写环境变量形式等价)，写回时使用新文件中的键名写法")
+	fs.BoolVar(&changedOnly, "changed-only", false, "汇总中仅列出合并后值实际发生变化的参数")
+	fs.StringVar(&traceFile, "trace", "", "将每个处理决策以JSONL格式记录到指定文件")
+	fs.StringVar(&collisionPolicy, "on-collision", propmerge.CollisionOldWins, "重命名后的键与新文件中已有键冲突时的处理策略: old-wins|new-wins|fail")
+	fs.BoolVar(&autoPreserve, "auto-preserve", false, "忽略匹配规则，自动保留两个文件中都存在且值不同的键")
+	fs.BoolVar(&autoPreserveOldOnly, "auto-preserve-old-only", false, "与-auto-preserve同时使用，额外保留仅存在于旧文件中的键")
+	fs.BoolVar(&splitMode, "split", false, "拆分模式: 将旧文件拆分为保留参数覆盖文件和模板文件，参数为 旧文件 覆盖文件 模板文件")
+	fs.StringVar(&convertTo, "convert-to", "", "导出模式: 将旧文件中的保留参数以指定格式(env|json|yaml)写入第二个参数指定的文件")
+	fs.StringVar(&defaultsFile, "defaults-file", "", "key=default格式的默认值文件，旧值等于默认值的参数不予保留")
+	fs.BoolVar(&renderTemplates, "templates", false, "将合并结果中含有{{的值作为Go模板渲染，可引用环境变量(如{{ .DB_HOST }})与主机名({{ .Hostname }})，引用不存在的变量时不写入任何修改")
+	fs.StringVar(&valuesFile, "values", "", "渲染值模板使用的变量文件(yaml|json|toml|properties|env)，指定时同时启用-templates")
+	fs.BoolVar(&provenance, "provenance", false, "在每个保留参数上方写入来源注释，重复运行时替换而不累加")
+	fs.StringVar(&provenanceFormat, "provenance-format", "# source={file}:{line} run={run}", "来源注释格式，支持{file}、{line}、{run}占位符")
+	fs.StringVar(&lineOriginsFile, "line-origins", "", "将合并结果中每一行的来源(template新模板、replaced以旧值替换、inserted从旧文件插入)以JSON格式写入指定文件，供审阅混合来源的文件")
+	fs.BoolVar(&showDiff, "diff", false, "合并后输出新文件原始内容与合并结果的unified diff")
+	fs.StringVar(&diffFile, "diff-file", "", "将新文件原始内容与合并结果的unified diff写入指定文件而不是标准输出(不带颜色)，批量模式下依次写入各文件的差异")
+	fs.StringVar(&metricsFile, "metrics-file", "", "运行结束时将本次运行的合并结果、保留参数、冲突、校验失败与耗时以Prometheus文本格式写入指定文件，可供node_exporter的textfile收集器读取")
+	fs.BoolVar(&batchMode, "batch", false, "批量模式: 参数为 旧发布目录 新发布目录，按相对路径配对并逐对合并")
+	fs.BoolVar(&profileMode, "profiles", false, "Profile模式: 参数为 旧目录 新目录，按application-{profile}.properties约定逐对合并基础文件与各profile文件，profile文件使用env与profile同名的envRules")
+	fs.StringVar(&batchGlob, "glob", "**/application*.properties", "批量模式下选择文件的相对路径规则，**匹配任意层级目录")
+	fs.StringVar(&checkRulesFile, "check-rules", "", "合并后按校验规则文件检查结果(必需的键、非空、整数、布尔值、地址)，未通过时恢复合并前的备份并以非零状态退出")
+	fs.IntVar(&batchParallel, "parallel", 1, "批量模式下同时处理的文件数，每个文件使用独立加载的规则")
+	fs.BoolVar(&snapshotMode, "snapshot", false, "批量模式下合并前将新发布目录整体打包为快照，可用snapshot restore一次恢复所有文件")
+	fs.BoolVar(&interactiveMode, "interactive", false, "逐项确认模式: 替换或插入每个参数前显示新旧值并询问[y/n/a/q]")
+	fs.BoolVar(&tuiMode, "tui", false, "终端界面: 合并前并排显示新模板与合并结果，逐项切换是否写入后应用或放弃")
+	fs.StringVar(&responsesFile, "responses", "", "从应答文件回放逐项确认的决定(key=y/n)")
+	fs.StringVar(&saveResponsesFile, "save-responses", "", "将逐项确认的决定保存到应答文件，供-responses回放")
+	fs.StringVar(&baseFile, "base", "", "三方合并: 上一版本的原始模板文件，作为旧文件与新文件的共同基线")
+	fs.StringVar(&conflictPolicy, "on-conflict", "", "保留参数的旧值与新值不同时的处理策略: old|new|newer-file(取修改时间较新的文件中的值)|prompt(逐个询问)|fail(不写入任何修改) (默认写入旧值且不视为冲突；三方合并中只处理旧值与新值都相对基线修改的参数，默认为old)")
+	fs.StringVar(&duplicatePolicy, "on-duplicate", "", "旧文件或新文件中存在重复键时的处理策略: first-wins|last-wins|error (默认读取config-matcher.json中的onDuplicate，均未指定时仅报告)")
+	fs.StringVar(&inputEncoding, "input-encoding", "", "读取配置文件使用的编码: utf-8|utf-8-bom|gbk|iso-8859-1 (默认自动识别: 非UTF-8的内容能按GBK解码时视为GBK，否则按ISO-8859-1原样读写)")
+	fs.StringVar(&outputEncoding, "output-encoding", "", "写入配置文件使用的编码: utf-8|utf-8-bom|gbk|iso-8859-1 (默认沿用目标文件原有的编码)")
+	fs.StringVar(&unicodeMode, "unicode", propmerge.UnicodeKeep, "properties合并结果中非ASCII字符的写法: keep(保持原样)|ascii(写作\\uXXXX转义)|utf8(将\\uXXXX转义还原为UTF-8原文)")
+	fs.StringVar(&fileModeFlag, "file-mode", "", "写入后将配置文件的权限设置为指定值，如0640 (默认沿用原文件的权限)")
+	fs.StringVar(&fileOwnerFlag, "file-owner", "", "写入后将配置文件的属主与属组设置为指定值，格式为 用户[:组]，可使用名称或数字ID (默认沿用原文件的属主与属组)")
+	fs.StringVar(&fileContextFlag, "file-context", "", "写入后将配置文件的SELinux安全上下文设置为指定值，如system_u:object_r:etc_t:s0 (默认沿用原文件的上下文)")
+	fs.StringVar(&reportFile, "report-json", "", "将所有修改、备份路径、时间与校验和以JSON格式写入指定文件")
+	fs.StringVar(&reportHTMLFile, "report-html", "", "生成包含并排差异、保留参数、规则命中与备份文件的HTML报告，可作为变更审批附件")
+	fs.StringVar(&jobsFile, "jobs", "", "任务清单模式: 依次执行YAML或JSON任务清单中的全部合并任务(旧文件、新文件、环境、格式、输出文件)，输出一份汇总")
+	fs.StringVar(&manifestFile, "manifest", "", "将输入、备份与输出文件的SHA-256校验和写入JSON清单文件(如merge-manifest.json)")
+	fs.StringVar(&mergeMode, "mode", "line", "替换已有参数的方式: line(整行使用旧文件的内容)|value(只写入旧值，保留新文件的格式、位置与行尾注释)")
+	fs.StringVar(&insertStrategy, "insert-strategy", propmerge.InsertLine, "新文件中不存在的保留参数的插入位置: line(旧文件中的行号)|anchor(与之共享最长点分前缀的最后一个参数之后，如spring.redis.*参数块)|append(文件末尾)")
+	fs.StringVar(&obsoletePolicy, "obsolete", propmerge.ObsoleteInsert, "新文件中已不存在(可能已被上游删除)的保留参数的处理方式: insert(按-insert-strategy插入)|append(追加到文件末尾)|comment(注释掉后插入并注明)|drop(不写入，只在汇总与报告中列出)|ask(逐个询问)")
+	fs.StringVar(&archiveEntry, "entry", "", "旧文件或新文件为JAR/WAR归档时配置文件的条目路径 (默认JAR为BOOT-INF/classes/application.properties，WAR为WEB-INF/classes/application.properties)")
+	fs.StringVar(&formatFlag, "format", "", "配置文件格式: properties|yaml|toml|ini|json|hocon|env|xml (默认按扩展名自动识别，.env与.env.*为env，.ini与.cnf为ini，.conf与.hocon为hocon)")
+	fs.StringVar(&outputFile, "output", "", "将合并结果写入指定文件(-为标准输出)，不修改新文件、不创建备份；新文件为HTTP/HTTPS地址时为下载并写入合并结果的本地文件 (默认为当前目录下与地址同名的文件)")
+	fs.StringVar(&httpUser, "http-user", "", "下载HTTP/HTTPS地址时使用的Basic认证，格式为 用户名:密码")
+	fs.StringVar(&httpToken, "http-token", "", "下载HTTP/HTTPS地址时使用的Bearer令牌")
+	fs.DurationVar(&httpTimeout, "http-timeout", 60*time.Second, "下载HTTP/HTTPS地址的超时时间")
+	fs.StringVar(&consulToken, "consul-token", "", "访问consul://路径使用的ACL令牌 (默认读取CONSUL_HTTP_TOKEN)")
+	fs.StringVar(&etcdUser, "etcd-user", "", "访问etcd://路径使用的用户名与密码，格式为 用户名:密码 (默认读取ETCDCTL_USER)")
+	fs.DurationVar(&etcdTTL, "etcd-ttl", 0, "写入etcd://路径的键附加一个新申请的租约，租约到期(未续约)时这些键被删除，如10m；0为不使用租约")
+	fs.Int64Var(&etcdLease, "etcd-lease", 0, "写入etcd://路径的键附加已有的租约ID(十进制)，由其他进程负责续约")
+	fs.BoolVar(&remoteBackup, "remote-backup", false, "推送到ssh://远程文件前，在远程主机上以硬链接保留原文件(同目录下的.bak.<时间戳>)")
+	fs.DurationVar(&runTimeout, "timeout", 0, "整次运行的时限，如5m；到期后中止下载、钩子、外部命令与文件合并，删除临时文件并以非零状态退出，不写入未完成的结果；0为不限制")
+	fs.Func("min-overlap", "旧文件的键在新文件中仍然存在的最低比例(百分比)，显式指定时低于该比例判定为传错了文件对并拒绝合并，0为不检查(旧文件少于5个键时不检查；未指定时按50只输出警告)", setMinOverlap)
+	fs.BoolVar(&forceMerge, "force", false, "确认文件对无误: 键重合比例低于-min-overlap时仍然合并，未指定-min-overlap时不再输出重合比例过低的警告")
+	fs.DurationVar(&lockTimeout, "lock-timeout", 0, "目标文件正被其他进程处理时等待锁(目标文件旁的.lock文件)的最长时间，超时仍未获得锁时不做任何修改并退出，0为不等待")
+	fs.StringVar(&backupMode, "backup-mode", backupCopy, "备份方式: copy(带时间戳的文件副本)|git(将合并前后的新文件提交到备份目录下的Git仓库)|both")
+	registerBackupFlags(fs)
+	fs.BoolVar(&noBackup, "no-backup", false, "合并前不创建任何备份，写入结果核对或-check-rules校验失败时无法恢复")
+	fs.IntVar(&backupKeep, "backup-keep", 0, "运行结束后每个文件只保留最近几次运行的备份，0为不清理")
+	fs.StringVar(&backupMaxAge, "backup-max-age", "", "运行结束后删除早于该时间的备份，如30d、12h")
+	fs.BoolVar(&preserveEncrypted, "preserve-encrypted", false, "将值为ENC(...)的Jasypt加密值视为不透明的值，无论是否命中保留规则都予以保留")
+	registerJasyptFlags(fs)
+	fs.BoolVar(&noMask, "no-mask", false, "不隐藏敏感参数的值(仅用于调试)，默认在日志与控制台输出中显示为****")
+	fs.StringVar(&preMergeHook, "pre-merge", "", "合并前执行的shell命令，失败时不执行合并 (默认读取config-matcher.json中的hooks.preMerge)")
+	fs.StringVar(&postMergeHook, "post-merge", "", "合并后执行的shell命令，通过MERGE_STATUS等环境变量获知结果 (默认读取config-matcher.json中的hooks.postMerge)")
+	fs.BoolVar(&dryRun, "dry-run", false, "仅预览修改，不写入任何文件")
+	fs.StringVar(&activeEnv, "env", "", "当前环境名称，用于选择config-matcher.json中的envRules (默认读取APP_ENV)")
+	fs.StringVar(&ruleProfile, "profile", "", "选用config-matcher.json中profiles下的规则组，如dev、test、prod (默认读取UPDATE_CONFIG_PROFILE)")
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// isConfigServer 判断文件参数是否为configserver://或configserver+https://形式的Spring Cloud Config Server地址
+func isConfigServer(s string) bool {
+	return strings.HasPrefix(s, "configserver://") || strings.HasPrefix(s, "configserver+https://")
+}
+
+// configServer 描述Config Server上一个应用在某个profile与label下的配置
+type configServer struct {
+	api     *url.URL // Config Server地址，含部署时的上下文路径
+	app     string
+	profile string
+	label   string // 为空时使用服务端的默认label
+}
+
+// parseConfigServer 解析configserver://主机[:端口][/上下文路径]/应用/profile[?label=分支] 形式的地址，
+// configserver+https://使用HTTPS。label以查询参数给出，避免与上下文路径混淆
+func parseConfigServer(raw string) (configServer, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return configServer{}, fmt.Errorf(tr("无效的Config Server地址 %s: %w"), raw, err)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Host == "" || len(parts) < 2 || parts[len(parts)-2] == "" || parts[len(parts)-1] == "" {
+		return configServer{}, fmt.Errorf(tr("无效的Config Server地址 %s，格式应为 configserver://主机:端口/应用/profile[?label=分支]"), raw)
+	}
+	scheme := "http"
+	if u.Scheme == "configserver+https" {
+		scheme = "https"
+	}
+	return configServer{
+		api:     &url.URL{Scheme: scheme, Host: u.Host, User: u.User, Path: "/" + strings.Join(parts[:len(parts)-2], "/")},
+		app:     parts[len(parts)-2],
+		profile: parts[len(parts)-1],
+		label:   u.Query().Get("label"),
+	}, nil
+}
+
+// endpoint 返回{app}/{profile}[/{label}]端点的地址，label中的/按Config Server的约定写作(_)
+func (c configServer) endpoint() *url.URL {
+	u := *c.api
+	u.Path = path.Join(u.Path, c.app, c.profile)
+	if c.label != "" {
+		u.Path = path.Join(u.Path, strings.ReplaceAll(c.label, "/", "(_)"))
+	}
+	return &u
+}
+
+// localName 返回未指定-output时下载到当前目录的文件名
+func (c configServer) localName() string {
+	return c.app + "-" + c.profile + ".properties"
+}
+
+// configEnvironment 是Config Server端点返回的Environment，propertySources按优先级从高到低排列
+type configEnvironment struct {
+	Name            string
+	Profiles        []string
+	Label           string
+	Version         string
+	PropertySources []struct {
+		Name   string
+		Source json.RawMessage
+	}
+}
+
+// fetch 读取端点返回的配置，按优先级合并各propertySource后写入本地properties文件:
+// 同名键取优先级最高的值，键按首次出现的顺序排列(优先级高的来源在前)
+func (c configServer) fetch(filename string) error {
+	u := c.endpoint()
+	debugf(tr("下载文件: %s -> %s"), u.Redacted(), filename, slog.String("file", filename))
+	resp, err := httpGet(u, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var env configEnvironment
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return fmt.Errorf(tr("解析 %s 的响应失败: %w"), u.Redacted(), err)
+	}
+	if len(env.PropertySources) == 0 {
+		return fmt.Errorf(tr("%s 没有返回任何propertySource，请检查应用名、profile与label"), u.Redacted())
+	}
+
+	var keys, sources []string
+	values := make(map[string]string)
+	for _, ps := range env.PropertySources {
+		sources = append(sources, ps.Name)
+		entries, err := orderedSource(ps.Source)
+		if err != nil {
+			return fmt.Errorf(tr("解析 %s 的响应失败: %s: %w"), u.Redacted(), ps.Name, err)
+		}
+		for _, e := range entries {
+			if _, ok := values[e[0]]; ok {
+				continue
+			}
+			keys = append(keys, e[0])
+			values[e[0]] = e[1]
+		}
+	}
+
+	lines := []string{"# " + fmt.Sprintf(tr("来自Spring Cloud Config Server: %s"), u.Redacted())}
+	if env.Version != "" {
+		lines = append(lines, "# version: "+env.Version)
+	}
+	for _, s := range sources {
+		lines = append(lines, "# propertySource: "+s)
+	}
+	escape := strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+	for _, key := range keys {
+		lines = append(lines, key+"="+escape.Replace(values[key]))
+	}
+	if err := propmerge.WriteFile(filename, lines); err != nil {
+		return err
+	}
+	debugf(tr("从 %s 读取%d个键(%d个propertySource)"), u.Redacted(), len(keys), len(sources))
+	return nil
+}
+
+// orderedSource 按JSON中的顺序返回propertySource的键值，非字符串值(数字、布尔值)使用其JSON文本，null为空值
+func orderedSource(raw json.RawMessage) ([][2]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, errors.New(tr("source不是JSON对象"))
+	}
+	var entries [][2]string
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		text := string(value)
+		var s string
+		switch {
+		case json.Unmarshal(value, &s) == nil:
+			text = s
+		case text == "null":
+			text = ""
+		}
+		entries = append(entries, [2]string{t.(string), text})
+	}
+	return entries, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// -on-conflict中由命令行处理、不直接交给合并器的策略
+const (
+	conflictNewerFile = "newer-file" // 取修改时间较新的文件中的值
+	conflictPrompt    = "prompt"     // 逐个询问有冲突的参数
+)
+
+// parseConflictPolicy 校验-on-conflict并将old、new统一为合并器使用的old-wins、new-wins，空值原样返回
+func parseConflictPolicy(policy string) (string, error) {
+	switch policy {
+	case "old", propmerge.CollisionOldWins:
+		return propmerge.CollisionOldWins, nil
+	case "new", propmerge.CollisionNewWins:
+		return propmerge.CollisionNewWins, nil
+	case "", propmerge.CollisionFail, conflictNewerFile, conflictPrompt:
+		return policy, nil
+	}
+	return "", fmt.Errorf(tr("无效的冲突处理策略: %s (可选old、new、newer-file、prompt、fail)"), policy)
+}
+
+// effectiveConflictPolicy 返回合并一对文件时交给合并器的冲突策略: newer-file比较两个文件的修改时间，
+// 新文件较新时取新值，否则取旧值；prompt取旧值，由逐项确认询问冲突的参数
+func effectiveConflictPolicy(oldFile, newFile string) (string, error) {
+	switch conflictPolicy {
+	case conflictPrompt:
+		return propmerge.CollisionOldWins, nil
+	case conflictNewerFile:
+		oldInfo, err := os.Stat(oldFile)
+		if err != nil {
+			return "", fmt.Errorf(tr("-on-conflict newer-file需要比较文件的修改时间: %w"), err)
+		}
+		newInfo, err := os.Stat(newFile)
+		if err != nil {
+			return "", fmt.Errorf(tr("-on-conflict newer-file需要比较文件的修改时间: %w"), err)
+		}
+		if newInfo.ModTime().After(oldInfo.ModTime()) {
+			debugf(tr("新文件 %s 较新，冲突时取新值"), newFile)
+			return propmerge.CollisionNewWins, nil
+		}
+		debugf(tr("旧文件 %s 较新，冲突时取旧值"), oldFile)
+		return propmerge.CollisionOldWins, nil
+	}
+	return conflictPolicy, nil
+}
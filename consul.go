@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// consulToken 为访问Consul使用的ACL令牌(-consul-token)，为空时读取CONSUL_HTTP_TOKEN
+var consulToken string
+
+// consulTxnLimit 为Consul单个事务允许的最大操作数
+const consulTxnLimit = 64
+
+// isConsul 判断文件参数是否为consul://形式的Consul KV路径
+func isConsul(s string) bool {
+	return strings.HasPrefix(s, "consul://")
+}
+
+// consulKV 描述Consul KV中的一个前缀，前缀下的每个键是一个配置参数
+type consulKV struct {
+	api    *url.URL // Consul HTTP API地址
+	prefix string   // KV前缀，不含首尾的/
+}
+
+// parseConsul 解析consul://[主机[:端口]]/前缀 形式的路径。省略主机时使用CONSUL_HTTP_ADDR，默认为127.0.0.1:8500；
+// CONSUL_HTTP_ADDR带https://或CONSUL_HTTP_SSL=true时使用HTTPS
+func parseConsul(raw string) (consulKV, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return consulKV{}, fmt.Errorf(tr("无效的Consul路径 %s: %w"), raw, err)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if prefix == "" {
+		return consulKV{}, fmt.Errorf(tr("无效的Consul路径 %s，格式应为 consul://主机:端口/前缀"), raw)
+	}
+
+	addr := u.Host
+	if addr == "" {
+		addr = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if addr == "" {
+		addr = "127.0.0.1:8500"
+	}
+	scheme := "http"
+	if s, rest, ok := strings.Cut(addr, "://"); ok {
+		scheme, addr = s, rest
+	} else if os.Getenv("CONSUL_HTTP_SSL") == "true" {
+		scheme = "https"
+	}
+	return consulKV{api: &url.URL{Scheme: scheme, Host: addr}, prefix: prefix}, nil
+}
+
+// String 返回consul://形式的地址
+func (c consulKV) String() string {
+	return "consul://" + c.api.Host + "/" + c.prefix
+}
+
+// base 返回前缀的最后一段，用作本地文件名
+func (c consulKV) base() string {
+	return path.Base(c.prefix)
+}
+
+// dottedKey 将前缀下的Consul键名转换为点分形式的参数名，如config/app/spring/redis/host转换为spring.redis.host
+func (c consulKV) dottedKey(key string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(key, c.prefix+"/"), "/", ".")
+}
+
+// consulKey 将参数名转换为前缀下的Consul键名
+func (c consulKV) consulKey(key string) string {
+	return c.prefix + "/" + strings.ReplaceAll(key, ".", "/")
+}
+
+// do 发送Consul HTTP API请求，附加ACL令牌
+func (c consulKV) do(method, apiPath string, query url.Values, body []byte) (*http.Response, error) {
+	u := *c.api
+	u.Path = apiPath
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(runCtx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf(tr("创建请求失败: %w"), err)
+	}
+	req.Header.Set("User-Agent", "update_config/"+version)
+	token := consulToken
+	if token == "" {
+		token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	if token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+	client := &http.Client{Timeout: httpTimeout}
+	return client.Do(req)
+}
+
+// list 读取前缀下的全部键值，返回以点分参数名为键的映射；目录键(以/结尾)被忽略
+func (c consulKV) list() (map[string]kvEntry, error) {
+	resp, err := c.do(http.MethodGet, "/v1/kv/"+c.prefix+"/", url.Values{"recurse": {"true"}}, nil)
+	if err != nil {
+		return nil, fmt.Errorf(tr("读取 %s 失败: %w"), c, err)
+	}
+	defer resp.Body.Close()
+	entries := make(map[string]kvEntry)
+	if resp.StatusCode == http.StatusNotFound {
+		return entries, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(tr("读取 %s 失败: %s"), c, resp.Status)
+	}
+
+	var pairs []struct {
+		Key         string
+		Value       []byte
+		ModifyIndex uint64
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&pairs); err != nil {
+		return nil, fmt.Errorf(tr("读取 %s 失败: %w"), c, err)
+	}
+	for _, p := range pairs {
+		if strings.HasSuffix(p.Key, "/") {
+			continue
+		}
+		entries[c.dottedKey(p.Key)] = kvEntry{key: p.Key, value: string(p.Value), index: p.ModifyIndex}
+	}
+	return entries, nil
+}
+
+// consulTxnOp 是Consul事务中的一个KV操作
+type consulTxnOp struct {
+	KV struct {
+		Verb  string
+		Key   string
+		Value string `json:",omitempty"`
+		Index uint64
+	}
+}
+
+// commit 以Consul事务的cas与delete-cas写入与删除键，按读取时的修改索引检查并发修改。
+// 超过单个事务的操作数上限时分多个事务提交
+func (c consulKV) commit(puts []kvPut, deletes []kvEntry) error {
+	var ops []consulTxnOp
+	for _, p := range puts {
+		var op consulTxnOp
+		op.KV.Verb, op.KV.Key, op.KV.Value = "cas", c.consulKey(p.name), base64.StdEncoding.EncodeToString([]byte(p.value))
+		if p.old != nil {
+			op.KV.Key, op.KV.Index = p.old.key, p.old.index
+		}
+		ops = append(ops, op)
+	}
+	for _, old := range deletes {
+		var op consulTxnOp
+		op.KV.Verb, op.KV.Key, op.KV.Index = "delete-cas", old.key, old.index
+		ops = append(ops, op)
+	}
+
+	if len(ops) > consulTxnLimit {
+		warnf(tr("需要修改%d个键，超过Consul单个事务的上限%d，将分多个事务提交"), len(ops), consulTxnLimit)
+	}
+	for len(ops) > 0 {
+		n := min(len(ops), consulTxnLimit)
+		if err := c.txn(ops[:n]); err != nil {
+			return err
+		}
+		ops = ops[n:]
+	}
+	return nil
+}
+
+// txn 以一个事务提交KV操作，任一CAS检查失败时整个事务回滚
+func (c consulKV) txn(ops []consulTxnOp) error {
+	body, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(http.MethodPut, "/v1/txn", nil, body)
+	if err != nil {
+		return fmt.Errorf(tr("写入 %s 失败: %w"), c, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusConflict:
+		var result struct {
+			Errors []struct {
+				OpIndex int
+				What    string
+			}
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		var keys []string
+		for _, e := range result.Errors {
+			if e.OpIndex >= 0 && e.OpIndex < len(ops) {
+				keys = append(keys, ops[e.OpIndex].KV.Key)
+			}
+		}
+		return fmt.Errorf(tr("写入 %s 失败: 以下键在合并期间被修改，本次事务未写入: %s"), c, strings.Join(keys, ", "))
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if s := strings.TrimSpace(string(msg)); s != "" {
+		return fmt.Errorf(tr("写入 %s 失败: %s: %s"), c, resp.Status, s)
+	}
+	return fmt.Errorf(tr("写入 %s 失败: %s"), c, resp.Status)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule 是解析后的cron表达式，各字段为允许取值的位集合
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// 日与星期都有限制时满足其一即可(与cron相同)，其中一个为*时只看另一个
+	domAny, dowAny bool
+}
+
+// cronMacros 为cron表达式的简写
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cronMonths   = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	cronWeekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// parseCron 解析"分 时 日 月 星期"五个字段的cron表达式或@daily等简写，按本地时间计算。
+// 每个字段支持*、数字、a-b范围、/n步长与逗号分隔的列表，月与星期可使用英文缩写，星期中的7与0都表示星期日
+func parseCron(expr string) (*cronSchedule, error) {
+	spec := strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf(tr("无效的cron表达式 %q: 应为\"分 时 日 月 星期\"五个字段或@daily等简写"), expr)
+	}
+	c := &cronSchedule{}
+	var err error
+	if c.minute, _, err = parseCronField(fields[0], 0, 59, nil); err == nil {
+		if c.hour, _, err = parseCronField(fields[1], 0, 23, nil); err == nil {
+			if c.dom, c.domAny, err = parseCronField(fields[2], 1, 31, nil); err == nil {
+				if c.month, _, err = parseCronField(fields[3], 1, 12, cronMonths); err == nil {
+					c.dow, c.dowAny, err = parseCronField(fields[4], 0, 7, cronWeekdays)
+				}
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf(tr("无效的cron表达式 %q: %w"), expr, err)
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+// parseCronField 解析cron表达式的一个字段，返回允许取值的位集合以及该字段是否以*开头(不限制)。
+// names为从min开始依次对应的英文缩写
+func parseCronField(field string, min, max int, names []string) (uint64, bool, error) {
+	value := func(s string) (int, error) {
+		for i, name := range names {
+			if strings.EqualFold(s, name) {
+				return min + i, nil
+			}
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf(tr("%s 不是%d到%d之间的值"), s, min, max)
+		}
+		return n, nil
+	}
+
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, false, fmt.Errorf(tr("无效的步长: %s"), part)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			from, to, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = value(from); err != nil {
+				return 0, false, err
+			}
+			if hi, err = value(to); err != nil {
+				return 0, false, err
+			}
+			if lo > hi {
+				return 0, false, fmt.Errorf(tr("无效的范围: %s"), rng)
+			}
+		default:
+			n, err := value(rng)
+			if err != nil {
+				return 0, false, err
+			}
+			// 5/15表示从5开始每15个
+			lo, hi = n, n
+			if hasStep {
+				hi = max
+			}
+		}
+		for n := lo; n <= hi; n += step {
+			bits |= 1 << n
+		}
+	}
+	// 与Vixie cron相同，以*开头的字段(含*/2等步长)视为不限制，日与星期按"且"组合
+	return bits, strings.HasPrefix(field, "*"), nil
+}
+
+// dayMatches 判断日期是否满足日与星期字段
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<t.Weekday()) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next 返回t之后(不含t所在的分钟)第一个满足表达式的时间，五年内没有满足的时间(如2月30日)时返回零值
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	bits := func(ns ...int) uint64 {
+		var b uint64
+		for _, n := range ns {
+			b |= 1 << n
+		}
+		return b
+	}
+	tests := []struct {
+		field    string
+		min, max int
+		names    []string
+		want     uint64
+		any      bool
+		err      bool
+	}{
+		{"*", 0, 5, nil, bits(0, 1, 2, 3, 4, 5), true, false},
+		{"*/2", 0, 5, nil, bits(0, 2, 4), true, false},
+		{"3", 0, 59, nil, bits(3), false, false},
+		{"1-4", 0, 59, nil, bits(1, 2, 3, 4), false, false},
+		{"1-10/3", 0, 59, nil, bits(1, 4, 7, 10), false, false},
+		{"50/5", 0, 59, nil, bits(50, 55), false, false},
+		{"1,15,30", 1, 31, nil, bits(1, 15, 30), false, false},
+		{"jan,Mar-may", 1, 12, cronMonths, bits(1, 3, 4, 5), false, false},
+		{"mon-fri", 0, 7, cronWeekdays, bits(1, 2, 3, 4, 5), false, false},
+		{"5-7", 0, 7, cronWeekdays, bits(5, 6, 7), false, false},
+		{"60", 0, 59, nil, 0, false, true},
+		{"0", 1, 31, nil, 0, false, true},
+		{"5-1", 0, 59, nil, 0, false, true},
+		{"*/0", 0, 59, nil, 0, false, true},
+		{"foo", 1, 12, cronMonths, 0, false, true},
+	}
+	for _, tt := range tests {
+		got, any, err := parseCronField(tt.field, tt.min, tt.max, tt.names)
+		if (err != nil) != tt.err {
+			t.Errorf("parseCronField(%q) error = %v, want error %v", tt.field, err, tt.err)
+			continue
+		}
+		if !tt.err && (got != tt.want || any != tt.any) {
+			t.Errorf("parseCronField(%q) = %b, %v; want %b, %v", tt.field, got, any, tt.want, tt.any)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 2024-05-20为星期一
+	from := time.Date(2024, 5, 20, 10, 30, 0, 0, time.Local)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", at(5, 20, 10, 31)},
+		{"*/15 * * * *", at(5, 20, 10, 45)},
+		{"0 */6 * * *", at(5, 20, 12, 0)},
+		{"30 10 * * *", at(5, 21, 10, 30)},
+		{"0 9-17/4 * * *", at(5, 20, 13, 0)},
+		{"@daily", at(5, 21, 0, 0)},
+		{"@monthly", at(6, 1, 0, 0)},
+		{"0 0 1 jan *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)},
+		{"0 2 * * sat", at(5, 25, 2, 0)},
+		{"0 2 * * 7", at(5, 26, 2, 0)},
+		{"0 2 * * 0", at(5, 26, 2, 0)},
+		{"0 2 * * fri-7", at(5, 24, 2, 0)},
+		// 日与星期都有限制时满足其一即可: 25日或星期三
+		{"0 0 25 * wed", at(5, 22, 0, 0)},
+		{"0 0 21 * sun", at(5, 21, 0, 0)},
+		// 其中一个以*开头(含步长)时两者都要满足: 奇数日中的星期五，24日为偶数，31日为星期五
+		{"0 0 */2 * fri", at(5, 31, 0, 0)},
+		{"0 0 1-31/2 * fri", at(5, 21, 0, 0)},
+		// 星期日、二、四、六
+		{"0 0 * * */2", at(5, 21, 0, 0)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := c.next(from); !got.Equal(tt.want) {
+			t.Errorf("next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "* * * * * *", "61 * * * *", "* * * * mon-xyz"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
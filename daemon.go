@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// jobState 是daemon模式下一个任务的调度与最近一次运行的状态，由GET /status输出
+type jobState struct {
+	Name       string         `json:"name"`
+	Schedule   string         `json:"schedule"`
+	NextRun    string         `json:"nextRun"`
+	Runs       int            `json:"runs"`     // 启动以来的运行次数
+	Failures   int            `json:"failures"` // 启动以来失败的次数
+	LastStart  string         `json:"lastStart,omitempty"`
+	LastFinish string         `json:"lastFinish,omitempty"`
+	Status     string         `json:"status,omitempty"` // 最近一次运行的结果: merged、preview或failed，尚未运行时为空
+	Error      string         `json:"error,omitempty"`
+	Summary    *reportSummary `json:"summary,omitempty"`
+
+	job      mergeJob
+	schedule *cronSchedule
+	next     time.Time
+}
+
+// daemonStatus 是GET /status的响应
+type daemonStatus struct {
+	Tool      string     `json:"tool"`
+	StartedAt string     `json:"startedAt"`
+	Jobs      []jobState `json:"jobs"`
+}
+
+// scheduler 按cron表达式定时执行任务清单中的任务，状态接口在另一个goroutine中读取，由mu保护
+type scheduler struct {
+	mu        sync.Mutex
+	startedAt time.Time
+	jobs      []jobState
+}
+
+// runDaemon 实现daemon子命令: 按任务清单中各任务的schedule定时执行合并，直到收到中断信号。
+// 每次运行的结果写入操作日志，指定-status-addr时通过HTTP提供各任务最近一次运行的状态
+func runDaemon(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	registerLangFlag(fs)
+	registerLogFlags(fs)
+	registerBackupFlags(fs)
+	statusAddr := fs.String("status-addr", "", "在指定地址上提供任务状态(/status)与监控指标(/metrics)，为空时不提供")
+	fs.StringVar(&checkRulesFile, "check-rules", "", "合并后按校验规则文件检查结果，未通过时恢复合并前的备份")
+	fs.StringVar(&backupMode, "backup-mode", backupCopy, "备份方式: copy|git|both")
+	fs.DurationVar(&lockTimeout, "lock-timeout", 0, "目标文件正被其他进程处理时等待锁的最长时间，0为不等待")
+	fs.StringVar(&activeEnv, "env", "", "当前环境名称，用于选择config-matcher.json中的envRules (默认读取APP_ENV)")
+	fs.StringVar(&ruleProfile, "profile", "", "选用config-matcher.json中profiles下的规则组，如dev、test、prod (默认读取UPDATE_CONFIG_PROFILE)")
+	fs.StringVar(&httpUser, "http-user", "", "下载任务的source时使用的Basic认证，格式为 用户名:密码")
+	fs.StringVar(&httpToken, "http-token", "", "下载任务的source时使用的Bearer令牌")
+	fs.DurationVar(&httpTimeout, "http-timeout", 60*time.Second, "下载任务的source的超时时间")
+	fs.BoolVar(&dryRun, "dry-run", false, "仅预览修改，不写入任何文件")
+	fs.BoolVar(&verbose, "v", false, "启用详细输出模式")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), tr("用法: %s daemon [选项] 任务清单\n\n按任务清单中各任务的schedule(cron表达式)定时执行合并，直到收到中断信号\n\n选项:\n"), os.Args[0])
+		printDefaults(fs)
+	}
+	parseFlags(fs, args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err := checkBackupFlags(); err != nil {
+		fatalf(tr("参数错误: %v"), err)
+	}
+	if !validBackupMode(backupMode) {
+		fatalf(tr("参数错误: 无效的备份方式: %s"), backupMode)
+	}
+
+	filename := fs.Arg(0)
+	jobs, err := loadJobs(filename)
+	if err != nil {
+		return invalid(err)
+	}
+	s := &scheduler{startedAt: time.Now()}
+	for _, job := range jobs {
+		if job.Schedule == "" {
+			return invalid(fmt.Errorf(tr("任务清单 %s 中的任务 %s 缺少schedule"), filename, job.Name))
+		}
+		schedule, err := parseCron(job.Schedule)
+		if err != nil {
+			return invalid(fmt.Errorf(tr("任务 %s: %w"), job.Name, err))
+		}
+		state := jobState{Name: job.Name, Schedule: job.Schedule, job: job, schedule: schedule}
+		state.setNext(schedule.next(s.startedAt))
+		if state.next.IsZero() {
+			return invalid(fmt.Errorf(tr("任务 %s 的schedule %q 永远不会触发"), job.Name, job.Schedule))
+		}
+		s.jobs = append(s.jobs, state)
+	}
+	if *statusAddr != "" {
+		s.serveStatus(*statusAddr)
+	}
+	infof(tr("从 %s 加载%d个定时任务，按 Ctrl+C 停止"), filename, len(s.jobs))
+	for _, j := range s.jobs {
+		infof(tr("任务 %s (%s) 下次运行: %s"), j.Name, j.Schedule, j.NextRun)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	for {
+		next := s.earliest()
+		if next.IsZero() {
+			infof(tr("没有需要再次运行的任务"))
+			return nil
+		}
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-stop:
+			timer.Stop()
+			infof(tr("停止定时任务"))
+			return nil
+		case <-timer.C:
+		}
+		s.runDue(time.Now())
+	}
+}
+
+// setNext 设置任务的下次运行时间
+func (j *jobState) setNext(next time.Time) {
+	j.next = next
+	j.NextRun = ""
+	if !next.IsZero() {
+		j.NextRun = next.Format(time.RFC3339)
+	}
+}
+
+// earliest 返回所有任务中最早的下次运行时间
+func (s *scheduler) earliest() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var first time.Time
+	for _, j := range s.jobs {
+		if !j.next.IsZero() && (first.IsZero() || j.next.Before(first)) {
+			first = j.next
+		}
+	}
+	return first
+}
+
+// runDue 依次执行已到运行时间的任务。每轮重新加载保留规则，使config-matcher.json的修改在下次运行时生效；
+// 下次运行时间从任务结束时算起，运行期间错过的时间点不再补执行
+func (s *scheduler) runDue(now time.Time) {
+	cache := make(map[string]compiledRules)
+	for i := range s.jobs {
+		s.mu.Lock()
+		due := !s.jobs[i].next.IsZero() && !s.jobs[i].next.After(now)
+		job := s.jobs[i].job
+		s.mu.Unlock()
+		if !due {
+			continue
+		}
+
+		start := time.Now()
+		infof(tr("开始执行任务: %s"), job.Name, slog.String("file", job.New))
+		result, entry := runJob(job, cache)
+		metrics.observe(start, result.keys, result.err)
+		changed, conflicts := keyCounts(result.keys)
+		notifyMerge(newNotice(job.New, changed, conflicts, result.err))
+		journalJob(job, entry)
+		if result.err != nil {
+			errorf(tr("任务 %s 失败: %v"), job.Name, result.err, slog.String("file", job.New))
+		} else {
+			infof(tr("任务 %s 完成: 保留%d个参数, %d个值发生变化"), job.Name, result.kept, result.changed, slog.String("file", job.New))
+		}
+
+		s.mu.Lock()
+		j := &s.jobs[i]
+		j.Runs++
+		if result.err != nil {
+			j.Failures++
+		}
+		j.LastStart, j.LastFinish = start.Format(time.RFC3339), time.Now().Format(time.RFC3339)
+		j.Status, j.Error, j.Summary = entry.Status, entry.Error, &entry.Summary
+		j.setNext(j.schedule.next(time.Now()))
+		debugf(tr("任务 %s 下次运行: %s"), j.Name, j.NextRun)
+		s.mu.Unlock()
+	}
+}
+
+// serveStatus 在单独的地址上提供GET /status与/metrics；启动失败时输出错误，不影响定时任务
+func (s *scheduler) serveStatus(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf(tr("不支持的请求方法: %s"), r.Method))
+			return
+		}
+		s.mu.Lock()
+		status := daemonStatus{
+			Tool:      "update_config v" + version,
+			StartedAt: s.startedAt.Format(time.RFC3339),
+			Jobs:      append([]jobState(nil), s.jobs...),
+		}
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, status)
+	})
+	mux.Handle("/metrics", metrics)
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	infof(tr("在 %s/status 提供任务状态"), addr)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errorf(tr("启动任务状态服务失败: %v"), err)
+		}
+	}()
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// iconv 调用系统中的iconv命令转换编码，作为合并库的Transcoder，保持零第三方依赖。
+// 系统中没有iconv(如Windows)时返回错误，合并库随之按ISO-8859-1原样读写非UTF-8的文件
+func iconv(data []byte, from, to string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("iconv", "-f", from, "-t", to)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf(tr("%s与%s之间的转换需要系统中的iconv命令: %w"), from, to, err)
+		}
+		return nil, fmt.Errorf(tr("%s转换为%s失败: %s"), from, to, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestMergeLatin1 ISO-8859-1的properties文件(java.util.Properties的默认编码)可以直接合并，其余字节原样保留
+func TestMergeLatin1(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		configFile:       `{"patternKeys": "^ftp\\.host"}`,
+		"old.properties": "ftp.host=caf\xe9.example\n",
+		"new.properties": "# r\xe9sum\xe9\nftp.host=localhost\ntitle=\xa9 2024\n",
+	})
+	stdout, stderr, code := runMain(t, dir, "-no-backup", "old.properties", "new.properties")
+	if code != exitChanged {
+		t.Fatalf("exit code = %d, want %d\nstdout:\n%s\nstderr:\n%s", code, exitChanged, stdout, stderr)
+	}
+	want := "# r\xe9sum\xe9\nftp.host=caf\xe9.example\ntitle=\xa9 2024\n"
+	if got := readFile(t, filepath.Join(dir, "new.properties")); got != want {
+		t.Errorf("new.properties = %q, want %q", got, want)
+	}
+}
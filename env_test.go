@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvRulesSelection(t *testing.T) {
+	dir := t.TempDir()
+	config := `{
+  "patternKeys": "^ftp\\.",
+  "envRules": [
+    {"env": "prod", "keys": ["^spring\\.redis\\."]},
+    {"env": "test", "keys": ["^mock\\.", "^spring\\.datasource\\."]}
+  ]
+}`
+	if err := os.WriteFile(filepath.Join(dir, configFile), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, dir)
+	savedEnv, savedProfile := activeEnv, ruleProfile
+	defer func() { activeEnv, ruleProfile = savedEnv, savedProfile }()
+
+	lines := []string{"ftp.host=10.0.0.1", "spring.redis.host=redis", "mock.enabled=true", "spring.datasource.url=jdbc:h2:mem", "server.port=8080"}
+	tests := []struct {
+		name   string
+		flag   string // -env
+		appEnv string // APP_ENV
+		env    string // 生效的环境
+		keep   []bool // lines中各行是否保留
+	}{
+		{"no env", "", "", "", []bool{true, false, false, false, false}},
+		{"-env prod", "prod", "", "prod", []bool{true, true, false, false, false}},
+		{"-env test", "test", "", "test", []bool{true, false, true, true, false}},
+		{"APP_ENV prod", "", "prod", "prod", []bool{true, true, false, false, false}},
+		{"APP_ENV test", "", "test", "test", []bool{true, false, true, true, false}},
+		{"-env overrides APP_ENV", "test", "prod", "test", []bool{true, false, true, true, false}},
+		{"unknown env keeps global rules only", "staging", "", "staging", []bool{true, false, false, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_ENV", tt.appEnv)
+			t.Setenv("UPDATE_CONFIG_PROFILE", "")
+			activeEnv, ruleProfile = tt.flag, ""
+			envDefaults()
+			if activeEnv != tt.env {
+				t.Fatalf("activeEnv = %q, want %q", activeEnv, tt.env)
+			}
+			merger, err := newMerger("", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, line := range lines {
+				if got := merger.Matches(line); got != tt.keep[i] {
+					t.Errorf("Matches(%q) = %v, want %v", line, got, tt.keep[i])
+				}
+			}
+		})
+	}
+}
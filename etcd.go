@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 访问etcd的选项: -etcd-user为"用户名:密码"(默认读取ETCDCTL_USER)，
+// -etcd-ttl与-etcd-lease为写入的键附加的租约
+var (
+	etcdUser  string
+	etcdTTL   time.Duration
+	etcdLease int64
+)
+
+// isEtcd 判断文件参数是否为etcd://形式的etcd前缀
+func isEtcd(s string) bool {
+	return strings.HasPrefix(s, "etcd://")
+}
+
+// etcdKV 描述etcd中的一个前缀，前缀下的每个键是一个配置参数，通过etcd v3的HTTP/JSON网关访问
+type etcdKV struct {
+	api    *url.URL // etcd客户端地址
+	prefix string   // 键前缀，不含末尾的/
+	authed bool     // 是否已按-etcd-user认证
+	token  string   // 认证后取得的令牌，未认证时为空
+}
+
+// parseEtcd 解析etcd://[主机[:端口]]/前缀 形式的路径，前缀以/开头时写作etcd://主机//前缀。
+// 省略主机时使用ETCDCTL_ENDPOINTS中的第一个地址，默认为127.0.0.1:2379；地址带https://时使用HTTPS
+func parseEtcd(raw string) (*etcdKV, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf(tr("无效的etcd路径 %s: %w"), raw, err)
+	}
+	prefix := strings.TrimSuffix(strings.TrimPrefix(u.Path, "/"), "/")
+	if strings.Trim(prefix, "/") == "" {
+		return nil, fmt.Errorf(tr("无效的etcd路径 %s，格式应为 etcd://主机:端口/前缀"), raw)
+	}
+
+	addr := u.Host
+	if addr == "" {
+		addr, _, _ = strings.Cut(os.Getenv("ETCDCTL_ENDPOINTS"), ",")
+	}
+	if addr == "" {
+		addr = "127.0.0.1:2379"
+	}
+	scheme := "http"
+	if s, rest, ok := strings.Cut(addr, "://"); ok {
+		scheme, addr = s, rest
+	}
+	return &etcdKV{api: &url.URL{Scheme: scheme, Host: addr}, prefix: prefix}, nil
+}
+
+// String 返回etcd://形式的地址
+func (e *etcdKV) String() string {
+	return "etcd://" + e.api.Host + "/" + e.prefix
+}
+
+// base 返回前缀的最后一段，用作本地文件名
+func (e *etcdKV) base() string {
+	return path.Base(e.prefix)
+}
+
+// dottedKey 将前缀下的etcd键名转换为点分形式的参数名，如config/app/spring/redis/host转换为spring.redis.host
+func (e *etcdKV) dottedKey(key string) string {
+	return strings.ReplaceAll(strings.TrimPrefix(key, e.prefix+"/"), "/", ".")
+}
+
+// etcdKey 将参数名转换为前缀下的etcd键名
+func (e *etcdKV) etcdKey(key string) string {
+	return e.prefix + "/" + strings.ReplaceAll(key, ".", "/")
+}
+
+// b64 将键或值编码为网关要求的base64
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// call 以POST调用etcd v3网关的API并解析JSON响应，首次调用时按-etcd-user认证
+func (e *etcdKV) call(api string, in, out interface{}) error {
+	if !e.authed && api != "/v3/auth/authenticate" {
+		e.authed = true
+		if err := e.authenticate(); err != nil {
+			return err
+		}
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	u := *e.api
+	u.Path = api
+	req, err := http.NewRequestWithContext(runCtx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf(tr("创建请求失败: %w"), err)
+	}
+	req.Header.Set("User-Agent", "update_config/"+version)
+	req.Header.Set("Content-Type", "application/json")
+	if e.token != "" {
+		req.Header.Set("Authorization", e.token)
+	}
+	client := &http.Client{Timeout: httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Message string `json:"message"`
+			Error   string `json:"error"`
+		}
+		json.Unmarshal(payload, &failure)
+		if msg := firstNonEmpty(failure.Message, failure.Error); msg != "" {
+			return fmt.Errorf(tr("HTTP状态 %s: %s"), resp.Status, msg)
+		}
+		return fmt.Errorf(tr("HTTP状态 %s"), resp.Status)
+	}
+	if err := json.Unmarshal(payload, out); err != nil {
+		return fmt.Errorf(tr("解析响应失败: %w"), err)
+	}
+	return nil
+}
+
+// authenticate 按-etcd-user或ETCDCTL_USER取得令牌，均未指定时不认证
+func (e *etcdKV) authenticate() error {
+	user := firstNonEmpty(etcdUser, os.Getenv("ETCDCTL_USER"))
+	if user == "" {
+		return nil
+	}
+	name, password, ok := strings.Cut(user, ":")
+	if !ok {
+		return errors.New(tr("etcd用户的格式应为 用户名:密码"))
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := e.call("/v3/auth/authenticate", map[string]string{"name": name, "password": password}, &resp); err != nil {
+		return fmt.Errorf(tr("etcd认证失败: %w"), err)
+	}
+	e.token = resp.Token
+	return nil
+}
+
+// list 读取前缀下的全部键值，返回以点分参数名为键的映射
+func (e *etcdKV) list() (map[string]kvEntry, error) {
+	start := e.prefix + "/"
+	// range_end为前缀最后一个字节加一，即前缀下的全部键
+	end := []byte(start)
+	end[len(end)-1]++
+	var resp struct {
+		Kvs []struct {
+			Key         []byte `json:"key"`
+			Value       []byte `json:"value"`
+			ModRevision string `json:"mod_revision"`
+		} `json:"kvs"`
+	}
+	if err := e.call("/v3/kv/range", map[string]string{"key": b64(start), "range_end": base64.StdEncoding.EncodeToString(end)}, &resp); err != nil {
+		return nil, fmt.Errorf(tr("读取 %s 失败: %w"), e, err)
+	}
+	entries := make(map[string]kvEntry, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		key := string(kv.Key)
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+		rev, _ := strconv.ParseUint(kv.ModRevision, 10, 64)
+		entries[e.dottedKey(key)] = kvEntry{key: key, value: string(kv.Value), index: rev}
+	}
+	return entries, nil
+}
+
+// lease 返回写入的键附加的租约ID: -etcd-lease指定已有的租约，-etcd-ttl按时长申请新的租约，均未指定时为0
+func (e *etcdKV) lease() (int64, error) {
+	if etcdLease != 0 {
+		return etcdLease, nil
+	}
+	if etcdTTL <= 0 {
+		return 0, nil
+	}
+	var resp struct {
+		ID string `json:"ID"`
+	}
+	ttl := int64((etcdTTL + time.Second - 1) / time.Second)
+	if err := e.call("/v3/lease/grant", map[string]string{"TTL": strconv.FormatInt(ttl, 10)}, &resp); err != nil {
+		return 0, fmt.Errorf(tr("申请etcd租约失败: %w"), err)
+	}
+	id, err := strconv.ParseInt(resp.ID, 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf(tr("申请etcd租约失败: 无效的租约ID %q"), resp.ID)
+	}
+	debugf(tr("已申请etcd租约 %d (TTL %d秒)"), id, ttl)
+	return id, nil
+}
+
+// commit 以一个etcd事务写入与删除键: 读取时已存在的键要求mod_revision未变，新增的键要求仍不存在，
+// 任一条件不满足时事务整体不生效，合并结果要么全部写入、要么全部不写入
+func (e *etcdKV) commit(puts []kvPut, deletes []kvEntry) error {
+	lease, err := e.lease()
+	if err != nil {
+		return err
+	}
+	var compare, success []map[string]interface{}
+	var keys []string
+	for _, p := range puts {
+		key := e.etcdKey(p.name)
+		if p.old != nil {
+			key = p.old.key
+			compare = append(compare, map[string]interface{}{"key": b64(key), "target": "MOD", "result": "EQUAL", "mod_revision": strconv.FormatUint(p.old.index, 10)})
+		} else {
+			compare = append(compare, map[string]interface{}{"key": b64(key), "target": "CREATE", "result": "EQUAL", "create_revision": "0"})
+		}
+		put := map[string]string{"key": b64(key), "value": b64(p.value)}
+		if lease != 0 {
+			put["lease"] = strconv.FormatInt(lease, 10)
+		}
+		success = append(success, map[string]interface{}{"request_put": put})
+		keys = append(keys, key)
+	}
+	for _, old := range deletes {
+		compare = append(compare, map[string]interface{}{"key": b64(old.key), "target": "MOD", "result": "EQUAL", "mod_revision": strconv.FormatUint(old.index, 10)})
+		success = append(success, map[string]interface{}{"request_delete_range": map[string]string{"key": b64(old.key)}})
+		keys = append(keys, old.key)
+	}
+
+	var resp struct {
+		Succeeded bool `json:"succeeded"`
+	}
+	if err := e.call("/v3/kv/txn", map[string]interface{}{"compare": compare, "success": success}, &resp); err != nil {
+		return fmt.Errorf(tr("写入 %s 失败: %w"), e, err)
+	}
+	if !resp.Succeeded {
+		return fmt.Errorf(tr("写入 %s 失败: 以下键中有键在合并期间被修改，本次事务未写入: %s"), e, strings.Join(keys, ", "))
+	}
+	return nil
+}
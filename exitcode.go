@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// 退出码约定，供部署脚本按结果分支而无需解析控制台输出
+const (
+	exitChanged   = 0 // 合并完成且有修改，其他命令成功时同样为0
+	exitUsage     = 1 // 参数错误
+	exitUnchanged = 2 // 合并完成，但没有需要修改的值
+	exitConflict  = 3 // 检测到重命名冲突、三方合并冲突或按error策略处理的重复键
+	exitInvalid   = 4 // 校验失败: 规则文件或输入文件无效、validate未通过、值模板无法渲染、postMerge钩子失败
+	exitIOError   = 5 // 读写错误及其他运行时错误
+)
+
+// 本次运行中各次合并的结果汇总，用于确定成功结束时的退出码
+var (
+	mergeRan       bool
+	mergeChanged   bool
+	mergeConflicts bool
+	changedKeys    int                   // 各次合并中值发生变化的参数总数，用于合并通知
+	conflictKeys   int                   // 各次合并中的冲突总数，用于合并通知
+	runKeys        []propmerge.KeyResult // 各次合并中保留参数的处理结果，用于-metrics-file
+)
+
+// recordResult 记录一次合并(含预览)的结果
+func recordResult(result propmerge.Result) {
+	mergeRan = true
+	if result.Changed() {
+		mergeChanged = true
+	}
+	if len(result.Collisions()) > 0 || len(result.Conflicts()) > 0 {
+		mergeConflicts = true
+	}
+	changed, conflicts := keyCounts(result.Keys)
+	changedKeys += changed
+	conflictKeys += conflicts
+	summary, _ := summarizeKeys(result.Keys)
+	runKeySummary.add(summary)
+	if metricsFile != "" {
+		runKeys = append(runKeys, result.Keys...)
+	}
+}
+
+// resultCode 返回运行成功结束时的退出码: 冲突按策略解决后仍返回exitConflict，
+// 执行了合并但没有任何值需要修改时返回exitUnchanged
+func resultCode() int {
+	switch {
+	case !mergeRan:
+		return exitChanged
+	case mergeConflicts:
+		return exitConflict
+	case !mergeChanged:
+		return exitUnchanged
+	}
+	return exitChanged
+}
+
+// validationError 标记校验失败的错误
+type validationError struct {
+	err error
+}
+
+func (e validationError) Error() string { return e.err.Error() }
+func (e validationError) Unwrap() error { return e.err }
+
+// invalid 将错误标记为校验失败
+func invalid(err error) error {
+	return validationError{err: err}
+}
+
+// exitCode 按错误类型返回退出码
+func exitCode(err error) int {
+	var validation validationError
+	var syntax *json.SyntaxError
+	var unmarshal *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, propmerge.ErrCollision), errors.Is(err, propmerge.ErrConflict), errors.Is(err, propmerge.ErrDuplicate):
+		return exitConflict
+	case errors.As(err, &validation), errors.As(err, &syntax), errors.As(err, &unmarshal), errors.Is(err, propmerge.ErrTemplate):
+		return exitInvalid
+	}
+	return exitIOError
+}
+
+// fail 输出错误并以对应的退出码退出
+func fail(err error) {
+	err = timeoutHint(err)
+	cleanupTemps()
+	errorf("%v", err)
+	writeRunSummary(exitCode(err), err)
+	os.Exit(exitCode(err))
+}
+
+// parseFlags 解析参数并按日志选项创建日志: -h时以0退出，参数错误时以exitUsage退出(flag包默认以2退出，与exitUnchanged冲突)
+func parseFlags(fs *flag.FlagSet, args []string) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitChanged)
+		}
+		os.Exit(exitUsage)
+	}
+	if err := setupLogger(); err != nil {
+		fatalf(tr("参数错误: %v"), err)
+	}
+	setupColor()
+}
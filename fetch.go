@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// isURL 判断文件参数是否为HTTP/HTTPS地址
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// downloadTemp 将旧文件(或以过滤器方式运行时的新文件)下载到临时文件并返回其路径，临时文件保留原地址的扩展名以便识别文件格式
+func downloadTemp(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf(tr("无效的地址 %s: %w"), rawURL, err)
+	}
+	tmp, err := os.CreateTemp("", "update_config-old-*"+path.Ext(u.Path))
+	if err != nil {
+		return "", fmt.Errorf(tr("创建临时文件失败: %w"), err)
+	}
+	tmp.Close()
+	if err := fetchURL(u, tmp.Name()); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// downloadTarget 返回新文件下载到本地的路径: 指定了-output时使用该文件，否则为当前目录下与地址同名的文件。
+// 合并结果随后写回该本地文件
+func downloadTarget(rawURL string) (string, error) {
+	if outputFile != "" {
+		return outputFile, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf(tr("无效的地址 %s: %w"), rawURL, err)
+	}
+	filename := path.Base(u.Path)
+	if filename == "/" || filename == "." {
+		return "", fmt.Errorf(tr("无法从地址 %s 推断文件名，请使用-output指定"), u.Redacted())
+	}
+	return filename, nil
+}
+
+// downloadNew 将新文件下载到downloadTarget返回的本地路径
+func downloadNew(rawURL, filename string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf(tr("无效的地址 %s: %w"), rawURL, err)
+	}
+	return fetchURL(u, filename)
+}
+
+// httpGet 发送GET请求，按命令行参数附加Basic认证或Bearer令牌；状态码不是200时返回错误
+func httpGet(u *url.URL, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(runCtx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf(tr("创建请求失败: %w"), err)
+	}
+	req.Header.Set("User-Agent", "update_config/"+version)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if httpUser != "" {
+		user, password, _ := strings.Cut(httpUser, ":")
+		req.SetBasicAuth(user, password)
+	}
+	if httpToken != "" {
+		req.Header.Set("Authorization", "Bearer "+httpToken)
+	}
+
+	client := &http.Client{Timeout: httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf(tr("下载 %s 失败: %w"), u.Redacted(), err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf(tr("下载 %s 失败: %s"), u.Redacted(), resp.Status)
+	}
+	return resp, nil
+}
+
+// fetchURL 下载地址内容并原子地写入filename
+func fetchURL(u *url.URL, filename string) error {
+	debugf(tr("下载文件: %s -> %s"), u.Redacted(), filename, slog.String("file", filename))
+	resp, err := httpGet(u, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = propmerge.AtomicWrite(filename, func(w io.Writer) error {
+		if _, err := io.CopyBuffer(w, resp.Body, make([]byte, bufferSize)); err != nil {
+			return fmt.Errorf(tr("下载 %s 失败: %w"), u.Redacted(), err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	debugf(tr("下载完成: %s"), filename, slog.String("file", filename))
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// 显式指定的写入文件属性(-file-mode、-file-owner、-file-context)，为空时沿用原文件的属性
+var (
+	fileModeFlag    string
+	fileOwnerFlag   string
+	fileContextFlag string
+)
+
+// fileAttrs 是解析后的显式文件属性
+type fileAttrs struct {
+	mode    os.FileMode
+	setMode bool
+	uid     int // -1为不修改
+	gid     int // -1为不修改
+	context string
+}
+
+// outputAttrs 为本次运行写入配置文件后设置的属性
+var outputAttrs = fileAttrs{uid: -1, gid: -1}
+
+// parseFileAttrs 解析-file-mode、-file-owner与-file-context
+func parseFileAttrs() (fileAttrs, error) {
+	attrs := fileAttrs{uid: -1, gid: -1, context: fileContextFlag}
+	if fileModeFlag != "" {
+		mode, err := strconv.ParseUint(fileModeFlag, 8, 32)
+		if err != nil || mode > 0o777 {
+			return attrs, fmt.Errorf(tr("无效的文件权限: %s，应为八进制数如0640"), fileModeFlag)
+		}
+		attrs.mode, attrs.setMode = os.FileMode(mode), true
+	}
+	if fileOwnerFlag != "" {
+		owner, group, _ := strings.Cut(fileOwnerFlag, ":")
+		var err error
+		if owner != "" {
+			if attrs.uid, err = lookupID(owner, false); err != nil {
+				return attrs, err
+			}
+		}
+		if group != "" {
+			if attrs.gid, err = lookupID(group, true); err != nil {
+				return attrs, err
+			}
+		}
+	}
+	return attrs, nil
+}
+
+// lookupID 将用户名或组名解析为数字ID，本身为数字时直接使用
+func lookupID(name string, group bool) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil && id >= 0 {
+		return id, nil
+	}
+	var id string
+	if group {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return -1, fmt.Errorf(tr("无效的属组: %w"), err)
+		}
+		id = g.Gid
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return -1, fmt.Errorf(tr("无效的属主: %w"), err)
+		}
+		id = u.Uid
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return -1, fmt.Errorf(tr("当前平台不支持数字形式的用户或组ID: %s"), id)
+	}
+	return n, nil
+}
+
+// applyFileAttrs 按-file-mode、-file-owner与-file-context设置写入后的配置文件，未指定时不做任何事
+func applyFileAttrs(path string) error {
+	a := outputAttrs
+	if a.setMode {
+		if err := os.Chmod(path, a.mode); err != nil {
+			return fmt.Errorf(tr("设置文件权限失败: %w"), err)
+		}
+	}
+	if a.uid != -1 || a.gid != -1 {
+		if err := os.Chown(path, a.uid, a.gid); err != nil {
+			return fmt.Errorf(tr("设置属主失败: %w"), err)
+		}
+	}
+	if a.context != "" {
+		if err := propmerge.SetSecurityContext(path, a.context); err != nil {
+			return fmt.Errorf(tr("设置SELinux安全上下文失败: %w"), err)
+		}
+	}
+	if a.setMode || a.uid != -1 || a.gid != -1 || a.context != "" {
+		debugf(tr("已设置文件属性: %s"), path, slog.String("file", path))
+	}
+	return nil
+}
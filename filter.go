@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// stdio 为文件参数中表示标准输入或标准输出的"-"
+const stdio = "-"
+
+// resultOut 为写入合并结果的标准输出；合并结果写入标准输出时，汇总信息改为写入标准错误
+var resultOut = os.Stdout
+
+// isFilter 判断是否以过滤器方式运行: 旧文件或新文件从标准输入读取，或者指定了-output，
+// 此时合并结果写入-output指定的文件或标准输出，不修改新文件，也不创建备份
+func isFilter(oldFile, newFile string) bool {
+	if oldFile == stdio || newFile == stdio {
+		return true
+	}
+	return outputFile != "" && ((!isURL(newFile) && !isConfigServer(newFile)) || outputFile == stdio)
+}
+
+// filterOutput 返回过滤器方式下合并结果的去向，未指定-output时写入标准输出
+func filterOutput() string {
+	if outputFile == "" {
+		return stdio
+	}
+	return outputFile
+}
+
+// readInput 读取文件参数的内容，"-"时读取标准输入
+func readInput(filename string) ([]string, error) {
+	if filename == stdio {
+		return propmerge.ReadLines(os.Stdin)
+	}
+	return propmerge.ReadFile(filename)
+}
+
+// formatName 返回用于识别文件格式的文件名: 从标准输入读取的一方按另一方的扩展名识别
+func formatName(filename, other string) string {
+	if filename == stdio {
+		return other
+	}
+	return filename
+}
+
+// runFilter 以过滤器方式合并: 从文件或标准输入读取旧文件与新文件，在内存中合并后
+// 写入-output指定的文件或标准输出，不修改输入文件，不创建备份
+func runFilter(merger *propmerge.Merger, oldFile, newFile string) error {
+	oldLines, err := readInput(oldFile)
+	if err != nil {
+		return fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+	newLines, err := readInput(newFile)
+	if err != nil {
+		return fmt.Errorf(tr("读取新文件失败: %w"), err)
+	}
+
+	format := fileFormat(formatName(oldFile, newFile), formatName(newFile, oldFile))
+	merge := pathMerge(merger, format)
+	if merge == nil {
+		merge = merger.MergeLines
+	}
+	result, err := merge(oldLines, newLines)
+	if err != nil {
+		if errors.Is(err, propmerge.ErrCollision) {
+			printCollisions(result.Keys)
+		}
+		if errors.Is(err, propmerge.ErrConflict) {
+			printConflicts(result.Keys)
+		}
+		printDuplicates(result.Duplicates)
+		return fmt.Errorf(tr("合并失败: %w"), err)
+	}
+	recordResult(result)
+
+	if dryRun {
+		printPlan(result.Keys)
+	} else {
+		printKeptResults(result.Keys)
+	}
+	printCollisions(result.Keys)
+	printConflicts(result.Keys)
+	printDiverged(result.Keys)
+	printDuplicates(result.Duplicates)
+	printSkippedDefaults(result.SkippedDefaults)
+	if showDiff {
+		printDiff(formatName(newFile, "stdin"), newLines, result.Lines)
+	}
+	if dryRun {
+		return nil
+	}
+
+	if checkRulesFile != "" {
+		// 结果写出后无法撤回，写入前校验
+		rules, err := propmerge.LoadCheckRules(checkRulesFile)
+		if err != nil {
+			return invalid(err)
+		}
+		failed, err := checkLines(rules, filterOutput(), format, result.Lines)
+		if err != nil {
+			return fmt.Errorf(tr("校验合并结果失败: %w"), err)
+		}
+		if failed > 0 {
+			return invalid(fmt.Errorf(tr("合并结果未通过校验: %d处错误，未写入合并结果"), failed))
+		}
+	}
+
+	output := filterOutput()
+	if output == stdio {
+		encoding := outputEncoding
+		if encoding == "" {
+			encoding = propmerge.EncodingUTF8
+		}
+		data, err := propmerge.EncodeLines(result.Lines, propmerge.LineSeparator, encoding)
+		if err != nil {
+			return err
+		}
+		if _, err := resultOut.Write(data); err != nil {
+			return fmt.Errorf(tr("写入标准输出失败: %w"), err)
+		}
+		return nil
+	}
+	if err := propmerge.WriteFileEncoding(output, result.Lines, outputEncoding); err != nil {
+		return fmt.Errorf(tr("写入输出文件失败: %w"), err)
+	}
+	fmt.Printf(tr("合并结果已写入: %s\n"), output)
+	return nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// 备份方式(-backup-mode)
+const (
+	backupCopy = "copy" // 带时间戳的文件副本
+	backupGit  = "git"  // 提交到备份目录下的Git仓库
+	backupBoth = "both" // 两者都使用
+)
+
+// backupMode 为备份方式，默认只创建文件副本
+var backupMode = backupCopy
+
+var (
+	// gitMu 串行化对备份仓库的操作，批量模式并行处理时各文件的提交不会互相干扰
+	gitMu sync.Mutex
+	// gitPending 记录已提交合并前状态、等待提交合并结果的文件，值为其在仓库中的路径
+	gitPending = make(map[string]string)
+)
+
+// validBackupMode 判断是否为支持的备份方式
+func validBackupMode(mode string) bool {
+	return mode == backupCopy || mode == backupGit || mode == backupBoth
+}
+
+// copyBackups 是否创建带时间戳的文件副本
+func copyBackups() bool {
+	return backupMode != backupGit
+}
+
+// gitBackups 是否将文件状态提交到备份仓库
+func gitBackups() bool {
+	return backupMode == backupGit || backupMode == backupBoth
+}
+
+// checkGitBackupEncryption 在config-matcher.json中配置了backupEncryption时拒绝Git备份: 提交到仓库的文件无法加密保存，
+// 会在备份目录中留下明文。config-matcher.json无法解析时不在此处报错，由加载规则时报告
+func checkGitBackupEncryption() error {
+	if !gitBackups() {
+		return nil
+	}
+	if enc, err := loadBackupEncryption(); err == nil && enc.Type != "" {
+		return fmt.Errorf(tr("config-matcher.json中配置了backupEncryption时不能使用-backup-mode %s: Git备份仓库中的文件无法加密保存，请使用-backup-mode copy"), backupMode)
+	}
+	return nil
+}
+
+// gitBackupDir 返回备份仓库的目录
+func gitBackupDir() string {
+	return filepath.Join(backupDir, "git")
+}
+
+// git 在备份仓库中执行git命令，返回标准输出
+func git(stdin string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(runCtx, "git", append([]string{"-C", gitBackupDir()}, args...)...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf(tr("Git备份需要系统中的git命令: %w"), err)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf(tr("git %s 执行失败: %w: %s"), args[0], err, msg)
+		}
+		return "", fmt.Errorf(tr("git %s 执行失败: %w"), args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// initGitBackup 在备份目录下初始化Git仓库，仓库已存在时不做任何操作
+func initGitBackup() error {
+	if _, err := os.Stat(filepath.Join(gitBackupDir(), ".git")); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(gitBackupDir(), 0755); err != nil {
+		return fmt.Errorf(tr("创建备份仓库失败: %w"), err)
+	}
+	if _, err := git("", "init", "-q"); err != nil {
+		return fmt.Errorf(tr("创建备份仓库失败: %w"), err)
+	}
+	debugf(tr("已创建备份仓库: %s"), gitBackupDir())
+	return nil
+}
+
+// operator 返回执行合并的操作人，通过sudo执行时使用原用户名
+func operator() string {
+	if name := os.Getenv("SUDO_USER"); name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// rulesHash 返回config-matcher.json的SHA-256校验和，文件不存在时返回空字符串
+func rulesHash() string {
+	sum, err := checksumFile(configFile)
+	if err != nil {
+		return ""
+	}
+	return sum.SHA256
+}
+
+// commitGitBackup 将文件的当前内容复制到备份仓库中的repoPath并提交，stage为"合并前"或"合并后"。
+// 内容与仓库中的上一次提交相同时不产生新的提交，返回提交的短哈希(未提交时为空)
+func commitGitBackup(filename, repoPath, stage string) (string, error) {
+	gitMu.Lock()
+	defer gitMu.Unlock()
+
+	if err := checkGitBackupEncryption(); err != nil {
+		return "", err
+	}
+	if err := initGitBackup(); err != nil {
+		return "", err
+	}
+	dst := filepath.Join(gitBackupDir(), repoPath)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", fmt.Errorf(tr("创建备份仓库失败: %w"), err)
+	}
+	if err := backupFile(filename, dst); err != nil {
+		return "", err
+	}
+	if _, err := git("", "add", "--", repoPath); err != nil {
+		return "", err
+	}
+	if _, err := git("", "diff", "--cached", "--quiet", "--", repoPath); err == nil {
+		debugf(tr("%s 与备份仓库中的上一次提交相同，未提交"), filename, slog.String("file", filename))
+		return "", nil
+	}
+
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		abs = filename
+	}
+	message := fmt.Sprintf(tr("%s: %s\n\n文件: %s\n工具版本: %s\n规则哈希: %s\n操作人: %s\n"),
+		tr(stage), repoPath, abs, version, rulesHash(), operator())
+	name := operator()
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "localhost"
+	}
+	if _, err := git(message, "-c", "user.name="+name, "-c", "user.email="+name+"@"+host,
+		"commit", "-q", "--no-verify", "-F", "-", "--", repoPath); err != nil {
+		return "", err
+	}
+	hash, err := git("", "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	hash = strings.TrimSpace(hash)
+	debugf(tr("已提交到备份仓库: %s %s (%s)"), hash, repoPath, tr(stage), slog.String("file", filename))
+	return hash, nil
+}
+
+// gitBackupPreMerge 提交目标文件合并前的状态，dir为该文件的备份目录，其相对于backupDir的路径即仓库中的目录。
+// 返回仓库中保存该文件的路径，可用于在合并结果提交前恢复合并前的内容
+func gitBackupPreMerge(dir, filename string) (string, error) {
+	rel, err := filepath.Rel(backupDir, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = "."
+	}
+	repoPath := filepath.ToSlash(filepath.Join(rel, filepath.Base(filename)))
+	if _, err := commitGitBackup(filename, repoPath, "合并前"); err != nil {
+		return "", fmt.Errorf(tr("提交合并前的文件失败: %w"), err)
+	}
+	gitMu.Lock()
+	gitPending[filename] = repoPath
+	gitMu.Unlock()
+	return filepath.Join(gitBackupDir(), filepath.FromSlash(repoPath)), nil
+}
+
+// gitBackupMerged 提交目标文件合并后的状态；文件没有提交过合并前的状态时不做任何操作
+func gitBackupMerged(filename string) error {
+	gitMu.Lock()
+	repoPath, ok := gitPending[filename]
+	delete(gitPending, filename)
+	gitMu.Unlock()
+	if !ok {
+		return nil
+	}
+	if _, err := commitGitBackup(filename, repoPath, "合并后"); err != nil {
+		return fmt.Errorf(tr("提交合并结果失败: %w"), err)
+	}
+	return nil
+}
+
+// runBackupsLog 实现backups log子命令: 通过git log输出备份仓库中全部或指定文件的提交历史
+func runBackupsLog(args []string) error {
+	fs := flag.NewFlagSet("backups log", flag.ContinueOnError)
+	registerLangFlag(fs)
+	registerLogFlags(fs)
+	registerBackupFlags(fs)
+	limit := fs.Int("n", 0, "最多显示的提交数，0为不限制")
+	patch := fs.Bool("p", false, "同时显示每次提交的修改内容")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), tr("用法: %s backups log [选项] [配置文件路径]\n\n显示备份仓库 %s 中的提交历史，指定配置文件时只显示该文件的历史\n\n选项:\n"), os.Args[0], gitBackupDir())
+		printDefaults(fs)
+	}
+	parseFlags(fs, args)
+	if err := checkBackupFlags(); err != nil {
+		fatalf(tr("参数错误: %v"), err)
+	}
+
+	if _, err := os.Stat(filepath.Join(gitBackupDir(), ".git")); err != nil {
+		return fmt.Errorf(tr("备份仓库 %s 不存在，使用-backup-mode git或both合并后创建"), gitBackupDir())
+	}
+	logArgs := []string{"log", "--date=format:%Y-%m-%d %H:%M:%S", "--format=%h  %ad  %an  %s%n%b"}
+	if *limit > 0 {
+		logArgs = append(logArgs, fmt.Sprintf("-n%d", *limit))
+	}
+	if *patch {
+		logArgs = append(logArgs, "-p")
+	}
+	if fs.NArg() > 0 {
+		// 批量模式的文件位于以相对路径命名的子目录中，按文件名匹配任意目录下的同名文件
+		logArgs = append(logArgs, "--", ":(glob)**/"+filepath.Base(fs.Arg(0)))
+	}
+	out := ""
+	if _, err := git("", "rev-parse", "--verify", "-q", "HEAD"); err == nil {
+		if out, err = git("", logArgs...); err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(out) == "" {
+		if fs.NArg() > 0 {
+			return fmt.Errorf(tr("备份仓库 %s 中未找到 %s 的提交"), gitBackupDir(), filepath.Base(fs.Arg(0)))
+		}
+		fmt.Printf(tr("备份仓库 %s 中没有提交\n"), gitBackupDir())
+		return nil
+	}
+	fmt.Print(out)
+	return nil
+}
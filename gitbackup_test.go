@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestGitBackupRefusedWithEncryption(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	saved := backupMode
+	defer func() { backupMode = saved }()
+
+	tests := []struct {
+		config string
+		mode   string
+		ok     bool
+	}{
+		{`{"backupEncryption": {"type": "age", "recipients": ["age1example"]}}`, backupGit, false},
+		{`{"backupEncryption": {"type": "gpg", "recipients": ["ops@example.com"]}}`, backupBoth, false},
+		{`{"backupEncryption": {"type": "age", "recipients": ["age1example"]}}`, backupCopy, true},
+		{`{"patternKeys": "^ftp\\."}`, backupGit, true},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(configFile, []byte(tt.config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		backupMode = tt.mode
+		if err := checkGitBackupEncryption(); (err == nil) != tt.ok {
+			t.Errorf("checkGitBackupEncryption(%s, %s) = %v, want ok=%v", tt.mode, tt.config, err, tt.ok)
+		}
+		if tt.ok {
+			continue
+		}
+		if _, err := commitGitBackup(configFile, "config-matcher.json", "合并前"); err == nil {
+			t.Errorf("commitGitBackup(%s, %s) committed a plaintext copy", tt.mode, tt.config)
+		}
+	}
+}
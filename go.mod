@@ -0,0 +1,3 @@
+module github.com/pslinux/go-compare
+
+go 1.21
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// 本次运行创建的备份文件路径，供postMerge钩子通过环境变量读取
+var (
+	lastOldBackup string
+	lastNewBackup string
+)
+
+// loadHooks 返回本次运行的钩子命令，命令行参数优先于config-matcher.json中的hooks
+func loadHooks() (propmerge.Hooks, error) {
+	config, _, err := propmerge.LoadConfig(configFile)
+	if err != nil {
+		return propmerge.Hooks{}, err
+	}
+	hooks := config.Hooks
+	if preMergeHook != "" {
+		hooks.PreMerge = preMergeHook
+	}
+	if postMergeHook != "" {
+		hooks.PostMerge = postMergeHook
+	}
+	return hooks, nil
+}
+
+// runWithHooks 在merge前后执行钩子命令: preMerge失败时不执行合并；postMerge无论合并成功与否都会执行，
+// 通过MERGE_STATUS(0为成功，1为失败)与MERGE_ERROR获知合并结果，之后发送合并通知。预览、导出与拆分模式不执行钩子
+func runWithHooks(oldFile, newFile string, merge func() error) error {
+	if dryRun || convertTo != "" || splitMode || filterMode {
+		return merge()
+	}
+	hooks, err := loadHooks()
+	if err != nil {
+		return fmt.Errorf(tr("加载配置失败: %w"), err)
+	}
+
+	if err := runHook(runCtx, "preMerge", hooks.PreMerge, hookEnv(oldFile, newFile, nil)); err != nil {
+		return fmt.Errorf(tr("%w，未执行合并"), err)
+	}
+	mergeErr := merge()
+	// -timeout到期导致合并失败时postMerge仍需执行以获知失败，不受runCtx取消的影响
+	if err := runHook(context.WithoutCancel(runCtx), "postMerge", hooks.PostMerge, hookEnv(oldFile, newFile, mergeErr)); err != nil {
+		if mergeErr != nil {
+			warnf("%v", err)
+		} else {
+			// postMerge通常用于校验合并结果，其失败按校验失败处理
+			mergeErr = invalid(err)
+		}
+	}
+	notifyMerge(newNotice(newFile, changedKeys, conflictKeys, mergeErr))
+	return mergeErr
+}
+
+// hookEnv 返回传给钩子命令的环境变量；mergeErr仅对postMerge有意义
+func hookEnv(oldFile, newFile string, mergeErr error) []string {
+	status, message := "0", ""
+	if mergeErr != nil {
+		status, message = "1", mergeErr.Error()
+	}
+	return []string{
+		"MERGE_OLD_FILE=" + oldFile,
+		"MERGE_NEW_FILE=" + newFile,
+		"MERGE_BACKUP_DIR=" + backupDir,
+		"MERGE_OLD_BACKUP=" + lastOldBackup,
+		"MERGE_NEW_BACKUP=" + lastNewBackup,
+		"MERGE_STATUS=" + status,
+		"MERGE_ERROR=" + message,
+	}
+}
+
+// runHook 通过系统shell(Windows下为cmd)执行钩子命令，命令输出写入标准错误，命令为空时不执行；
+// ctx取消或超时后终止钩子命令
+func runHook(ctx context.Context, name, command string, env []string) error {
+	if command == "" {
+		return nil
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	debugf(tr("执行%s钩子: %s"), name, command, slog.String("hook", name))
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf(tr("%s钩子执行失败: %w"), name, err)
+	}
+	return nil
+}
@@ -0,0 +1,856 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// lang 为当前输出语言，由-lang指定，默认根据环境变量选择
+var lang = propmerge.LanguageZH
+
+// defaultLanguage 依次读取LC_ALL、LC_MESSAGES、LANG，取第一个非空值判断语言；
+// 以en开头时为英文，其余情况保持中文输出
+func defaultLanguage() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			if strings.HasPrefix(strings.ToLower(v), propmerge.LanguageEN) {
+				return propmerge.LanguageEN
+			}
+			return propmerge.LanguageZH
+		}
+	}
+	return propmerge.LanguageZH
+}
+
+// setLanguage 设置命令行与合并库的输出语言
+func setLanguage(value string) error {
+	switch value {
+	case propmerge.LanguageZH, propmerge.LanguageEN:
+	default:
+		return fmt.Errorf(tr("不支持的语言: %s (可选: zh|en)"), value)
+	}
+	lang = value
+	propmerge.SetLanguage(value)
+	return nil
+}
+
+// registerLangFlag 注册-lang选项，解析到该选项时立即切换语言，使随后输出的帮助信息也使用所选语言
+func registerLangFlag(fs *flag.FlagSet) {
+	fs.Func("lang", "输出语言: zh|en (默认根据LC_ALL/LC_MESSAGES/LANG环境变量选择)", setLanguage)
+}
+
+// printDefaults 以当前语言输出各选项的说明
+func printDefaults(fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) {
+		f.Usage = tr(f.Usage)
+	})
+	fs.PrintDefaults()
+}
+
+// tr 返回消息在当前语言下的文本，消息目录以中文原文为键，缺少译文时返回原文
+func tr(s string) string {
+	if lang == propmerge.LanguageEN {
+		if t, ok := messagesEN[s]; ok {
+			return t
+		}
+	}
+	return s
+}
+
+// messagesEN 为英文消息目录
+var messagesEN = map[string]string{
+	"打开归档失败: %w":       "failed to open archive: %w",
+	"读取归档条目 %s 失败: %w": "failed to read archive entry %s: %w",
+	"归档 %s 中不存在条目 %s":  "archive %s has no entry %s",
+	"复制归档条目 %s 失败: %w": "failed to copy archive entry %s: %w",
+	"写入归档条目 %s 失败: %w": "failed to write archive entry %s: %w",
+	"编码归档条目 %s 失败: %w": "failed to encode archive entry %s: %w",
+	"写入归档失败: %w":       "failed to write archive: %w",
+	"读取旧配置失败: %w":      "failed to read old config: %w",
+	"读取新配置失败: %w":      "failed to read new config: %w",
+	"合并失败: %w":         "merge failed: %w",
+	"写入更新文件失败: %w":     "failed to write updated file: %w",
+	"配置更新完成!已完全使用新文件内容,并保留以下参数在原位置:": "Config updated! The new file's content is used, with the following parameters kept in place:",
+	"创建备份目录失败: %w":                         "failed to create backup directory: %w",
+	"创建备份文件...":                            "creating backup files...",
+	"备份旧文件失败: %w":                          "failed to back up old file: %w",
+	"备份新文件失败: %w":                          "failed to back up new file: %w",
+	"打开源文件失败: %w":                          "failed to open source file: %w",
+	"创建目标文件失败: %w":                         "failed to create target file: %w",
+	"复制文件内容失败: %w":                         "failed to copy file content: %w",
+	"成功创建备份文件: %s":                         "created backup file: %s",
+	"\n本次创建的备份文件:":                         "\nBackup files created by this run:",
+	"  旧文件备份: %s\n":                        "  old file backup: %s\n",
+	"  新文件备份: %s\n":                        "  new file backup: %s\n",
+	"读取备份目录失败: %w":                         "failed to read backup directory: %w",
+	"备份目录 %s 中未找到 %s 的备份":                  "no backups of %[2]s found in backup directory %[1]s",
+	"用法: %s rollback [选项] 配置文件路径\n\n选项:\n": "Usage: %s rollback [options] config-file\n\nOptions:\n",
+	"将使用备份 %s (%s, %s) 覆盖 %s\n":            "Backup %s (%s, %s) will overwrite %s\n",
+	"已取消回滚":                                "rollback cancelled",
+	"备份当前文件失败: %w":                         "failed to back up current file: %w",
+	"恢复备份失败: %w":                           "failed to restore backup: %w",
+	"回滚完成!":                                "Rollback complete!",
+	"回滚前的文件已备份至: %s\n":                     "The file before rollback was backed up to: %s\n",
+	"%s 的可用备份:\n":                          "Available backups of %s:\n",
+	"共 %d 个备份\n":                           "%d backups in total\n",
+	"备份目录 %s 中没有备份\n":                      "No backups in backup directory %s\n",
+	"共 %d 个文件, %d 个备份\n":                   "%d files, %d backups in total\n",
+	"未找到时间戳为 %s 的备份":                       "no backup with timestamp %s",
+	"读取备份文件失败: %w":                         "failed to read backup file: %w",
+	"读取当前文件失败: %w":                         "failed to read current file: %w",
+	"审计文件: %s\n":                           "Audited file: %s\n",
+	"对比备份: %s\n":                           "Compared with backup: %s\n",
+	"共 %d 处键级变更\n":                         "%d key-level changes in total\n",
+	"读取目录失败: %w":                           "failed to read directory: %w",
+	"%s 不是目录":                              "%s is not a directory",
+	"旧目录匹配%d个文件, 新目录匹配%d个文件 (规则: %s)":      "%d files matched in old directory, %d in new directory (pattern: %s)",
+	"处理文件: %s":                             "processing file: %s",
+	"扫描目录 %s 失败: %w":                       "failed to scan directory %s: %w",
+	"加载配置失败: %w":                           "failed to load config: %w",
+	"读取旧文件失败: %w":                          "failed to read old file: %w",
+	"读取新文件失败: %w":                          "failed to read new file: %w",
+	"更新新文件失败: %w":                          "failed to update new file: %w",
+	"预览模式，未写入任何文件。":                        "Dry run, no files were written.",
+	"批量处理结果:":                              "Batch results:",
+	"%s %s: 保留%d个参数, %d个值发生变化\n":           "%s %s: %d parameters kept, %d values changed\n",
+	"共 %d 个文件: 合并 %d, 跳过 %d, 失败 %d\n":      "%d files: %d merged, %d skipped, %d failed\n",
+	"备份文件位于: %s\n":                         "Backup files are in: %s\n",
+	"%d 个文件处理失败":                           "%d files failed",
+	"子命令:":                                 "Subcommands:",
+	"使用 %s <子命令> -h 查看子命令的选项\n":            "Run %s <subcommand> -h to see a subcommand's options\n",
+	"用法: %s %s [选项] 旧配置文件路径 新配置文件路径\n\n%s\n\n选项:\n": "Usage: %s %s [options] old-config-file new-config-file\n\n%s\n\nOptions:\n",
+	"无效的地址 %s: %w":                    "invalid URL %s: %w",
+	"创建临时文件失败: %w":                    "failed to create temporary file: %w",
+	"无法从地址 %s 推断文件名，请使用-output指定":     "cannot infer a file name from URL %s, use -output",
+	"创建请求失败: %w":                      "failed to create request: %w",
+	"下载文件: %s -> %s":                  "downloading file: %s -> %s",
+	"下载 %s 失败: %w":                    "failed to download %s: %w",
+	"下载 %s 失败: %s":                    "failed to download %s: %s",
+	"下载完成: %s":                        "download complete: %s",
+	"%w，未执行合并":                        "%w, merge not run",
+	"执行%s钩子: %s":                      "running %s hook: %s",
+	"%s钩子执行失败: %w":                    "%s hook failed: %w",
+	"不支持的语言: %s (可选: zh|en)":          "unsupported language: %s (choose zh|en)",
+	"读取应答文件失败: %w":                    "failed to read responses file: %w",
+	"应答文件第%d行无效: %s=%s (应为y或n)":       "invalid responses file line %d: %s=%s (expected y or n)",
+	"从 %s 加载%d条应答":                    "loaded %[2]d responses from %[1]s",
+	"(新文件中不存在)":                       "(not in new file)",
+	"\n%s[行%d] %s\n":                  "\n%s[line %d] %s\n",
+	"  新文件: %s\n":                     "  new file: %s\n",
+	"  旧文件: %s\n":                     "  old file: %s\n",
+	"写入旧值? [y/n/a/q] (是/否/全部接受/退出): ": "Write old value? [y/n/a/q] (yes/no/accept all/quit): ",
+	"\n逐项确认结果:":                       "\nConfirmation results:",
+	"共 %d 项: 接受 %d, 拒绝 %d\n":          "%d items: %d accepted, %d rejected\n",
+	"# 逐项确认应答文件，可通过 -responses 回放":    "# Confirmation responses file, replay with -responses",
+	"写入应答文件失败: %w":                    "failed to write responses file: %w",
+	"应答已保存至: %s\n":                    "Responses saved to: %s\n",
+	"写入覆盖文件失败: %w":                    "failed to write override file: %w",
+	"写入模板文件失败: %w":                    "failed to write template file: %w",
+	"拆分完成!":                           "Split complete!",
+	"覆盖文件: %s (共%d行, %d个参数)\n":        "Override file: %s (%d lines, %d parameters)\n",
+	"模板文件: %s (共%d行)\n":               "Template file: %s (%d lines)\n",
+	"写入导出文件失败: %w":                    "failed to write export file: %w",
+	"导出完成! 共%d个保留参数已以%s格式写入: %s\n":    "Export complete! %d kept parameters written as %s to: %s\n",
+	"读取待修复文件失败: %w":                   "failed to read file to repair: %w",
+	"修复文件: %s\n":                      "Repairing file: %s\n",
+	"预览模式，未写入任何文件":                    "Dry run, no files were written",
+	"备份待修复文件失败: %w":                   "failed to back up file to repair: %w",
+	"修复前文件已备份至: %s\n":                 "The file before repair was backed up to: %s\n",
+	"写入修复文件失败: %w":                    "failed to write repaired file: %w",
+	"%s 必须指定文件路径，标准输出仅用于汇总信息":         "%s requires a file path, standard output is reserved for the summary",
+	"解析路径 %s 失败: %w":                  "failed to resolve path %s: %w",
+	"%s 的路径 %s 已被 %s 使用":              "path %[2]s of %[1]s is already used by %[3]s",
+	"创建输出目录失败: %w":                    "failed to create output directory: %w",
+	"创建输出文件失败: %w":                    "failed to create output file: %w",
+	"加载敏感键规则失败: %w":                   "failed to load sensitive key patterns: %w",
+	"\n共 %d 个参数的旧值与默认值相同，未保留\n":       "\n%d parameters have old values equal to their defaults and were not kept\n",
+	"未保留默认值参数: %s":                    "parameter equal to default not kept: %s",
+	"合并结果与新文件完全相同，无差异":                "The merge result is identical to the new file, no differences",
+	"预览模式，未写入任何文件。计划执行以下修改:":          "Dry run, no files were written. Planned changes:",
+	"(无)":                        "(none)",
+	"%s[行%d] %s: %s -> %s\n":     "%s[line %d] %s: %s -> %s\n",
+	"共 %d 项计划修改\n":               "%d planned changes in total\n",
+	"\n重命名冲突:":                   "\nRename collisions:",
+	"共 %d 处重命名冲突\n":              "%d rename collisions in total\n",
+	"\n三方合并冲突:":                  "\nThree-way merge conflicts:",
+	"%s: 旧值=%s, 新值=%s, %s (%s)":  "%s: old=%s, new=%s, %s (%s)",
+	"共 %d 处三方合并冲突\n":             "%d three-way merge conflicts in total\n",
+	"\n重复的键:":                    "\nDuplicate keys:",
+	"保留行%d":                      "kept line %d",
+	"%s %s: 行%s (%s)\n":          "%s %s: lines %s (%s)\n",
+	"共 %d 个重复的键\n":               "%d duplicate keys in total\n",
+	"\n值发生变化的参数列表:":              "\nParameters whose values changed:",
+	"%4d: %s: (新文件中不存在) -> %s\n": "%4d: %s: (not in new file) -> %s\n",
+	"共 %d 个参数值发生变化\n":            "%d parameter values changed in total\n",
+	"\n自动推导的保留参数(两文件中值不同的键):": "\nAuto-derived kept parameters (keys whose values differ between the files):",
+	"%4d: %s: %s (新文件: %s)\n": "%4d: %s: %s (new file: %s)\n",
+	"%4d: %s: %s (仅存在于旧文件)\n": "%4d: %s: %s (only in old file)\n",
+	"共自动保留 %d 个参数\n":          "%d parameters auto-kept in total\n",
+	"\n保留的参数列表:":              "\nKept parameters:",
+	"共保留 %d 个参数\n":            "%d parameters kept in total\n",
+	"无法显示匹配参数: %v":            "cannot show matched parameters: %v",
+	"开始显示匹配参数...":             "showing matched parameters...",
+	"使用匹配规则: %s":              "using pattern: %s",
+	"\n匹配的参数列表:":              "\nMatched parameters:",
+	"共找到 %d 个匹配参数\n":          "%d matched parameters found\n",
+	"显示匹配参数完成":                "finished showing matched parameters",
+	"处理文件: %s (环境: %s)":       "processing file: %s (env: %s)",
+	"无效的保留时间: %s":             "invalid retention age: %s",
+	"扫描备份目录失败: %w":            "failed to scan backup directory: %w",
+	"没有超出保留策略的备份":             "No backups exceed the retention policy",
+	"\n以下备份超出保留策略，将被删除:":      "\nThe following backups exceed the retention policy and will be deleted:",
+	"\n清理过期备份:":               "\nPruning expired backups:",
+	"删除备份失败: %v":              "failed to delete backup: %v",
+	"已删除: %s\n":               "Deleted: %s\n",
+	"共 %d 个备份将被删除\n":          "%d backups will be deleted\n",
+	"共删除 %d 个备份\n":            "%d backups deleted\n",
+	"用法: %s prune [选项]\n\n清理备份目录 %s 中超出保留策略的备份\n\n选项:\n": "Usage: %s prune [options]\n\nDelete the backups in backup directory %s that exceed the retention policy\n\nOptions:\n",
+	"写入JSON报告失败: %w":            "failed to write JSON report: %w",
+	"JSON报告已写入: %s":             "JSON report written: %s",
+	"计算校验和失败: %w":               "failed to compute checksum: %w",
+	"%s失败: %w":                  "%s failed: %w",
+	"配置文件更新工具 v%s (构建日期: %s)\n": "Config file update tool v%s (built: %s)\n",
+	"用法: %s [选项] 旧配置文件路径 新配置文件路径\n": "Usage: %s [options] old-config-file new-config-file\n",
+	"      %s <子命令> [选项] [参数]\n\n":  "       %s <subcommand> [options] [args]\n\n",
+	"\n选项:": "\nOptions:",
+	"\n示例:": "\nExamples:",
+	"  %s rollback [-list] [-ts 时间戳] [-y] new.properties\n": "  %s rollback [-list] [-ts timestamp] [-y] new.properties\n",
+	"配置文件更新工具 v%s\n":                                        "Config file update tool v%s\n",
+	"构建日期: %s\n":                                            "Built: %s\n",
+	"审计失败: %w":                                              "audit failed: %w",
+	"参数错误: 无效的合并方式: %s":                                     "invalid arguments: invalid merge mode: %s",
+	"参数错误: 不支持的文件格式: %s":                                    "invalid arguments: unsupported file format: %s",
+	"参数错误: 不支持的输出编码: %s":                                    "invalid arguments: unsupported output encoding: %s",
+	"参数错误: %v":                                              "invalid arguments: %v",
+	"清理备份失败: %v":                                            "failed to prune backups: %v",
+	"参数错误: 批量模式与Profile模式不支持HTTP/HTTPS地址":                   "invalid arguments: batch and profile modes do not support HTTP/HTTPS URLs",
+	"下载旧文件失败: %w":                                           "failed to download old file: %w",
+	"开始处理文件: 旧文件=%s, 新文件=%s":                                "processing files: old=%s, new=%s",
+	"下载新文件失败: %w":                                           "failed to download new file: %w",
+	"创建跟踪文件失败: %w":                                          "failed to create trace file: %w",
+	"写入跟踪文件失败: %v":                                          "failed to write trace file: %v",
+	"加载应答失败: %w":                                            "failed to load responses: %w",
+	"处理完成":                                                  "done",
+	"批量处理失败: %w":                                            "batch processing failed: %w",
+	"Profile模式处理失败: %w":                                     "profile mode failed: %w",
+	"导出保留参数失败: %w":                                          "failed to export kept parameters: %w",
+	"参数错误: %w":                                              "invalid arguments: %w",
+	"拆分文件失败: %w":                                            "failed to split file: %w",
+	"修复文件失败: %w":                                            "failed to repair file: %w",
+	"合并归档失败: %w":                                            "failed to merge archive: %w",
+	"合并%s文件失败: %w":                                          "failed to merge %s file: %w",
+	"配置文件 %s 不存在，使用默认匹配规则":                                  "config file %s does not exist, using the default pattern",
+	"从配置文件 %s 加载%d条结构化保留规则":                                 "loaded %[2]d structured keep rules from config file %[1]s",
+	"配置文件中未定义patternKeys，使用默认匹配规则":                          "patternKeys not defined in config file, using the default pattern",
+	"从配置文件 %s 加载匹配规则":                                       "loaded pattern from config file %s",
+	"合并环境 %s 的保留规则: %s":                                     "merged keep rules of env %s: %s",
+	"从配置文件 %s 加载%d条键重命名规则":                                  "loaded %[2]d key rename rules from config file %[1]s",
+	"从配置文件 %s 加载%d条值转换规则":                                   "loaded %[2]d value transforms from config file %[1]s",
+	"加载默认值失败: %w":                                           "failed to load defaults: %w",
+	"加载三方合并基线失败: %w":                                        "failed to load three-way merge base: %w",
+	"读取文件失败: %w":                                            "failed to read file: %w",
+	"从 %s 加载%d个键值":                                          "loaded %[2]d keys from %[1]s",
+	"生成合并计划失败: %w":                                          "failed to build merge plan: %w",
+	"更新新文件...":                                              "updating new file...",
+	"读取合并结果失败: %w":                                          "failed to read merge result: %w",
+	"用法: %s validate [选项] [配置文件路径...]\n\n校验 %s 与给定的配置文件，存在错误时以非零状态退出\n\n选项:\n": "Usage: %s validate [options] [config-file...]\n\nValidate %s and the given config files, exiting with a non-zero status on errors\n\nOptions:\n",
+	"保留规则无效: %w": "invalid keep rules: %w",
+	"规则文件: %s\n": "Rules file: %s\n",
+	"规则文件: %s 不存在，使用默认匹配规则\n": "Rules file: %s does not exist, using the default pattern\n",
+	"环境 %s 的保留规则: %s\n":       "Keep rules of env %s: %s\n",
+	"%s: 错误: %v\n":            "%s: error: %v\n",
+	"%d个文件未通过校验":              "%d files failed validation",
+	"校验通过":                    "Validation passed",
+	"%s: 格式%s, 编码%s, 共%d行, %d个参数, %d个命中保留规则\n":     "%s: format %s, encoding %s, %d lines, %d parameters, %d matching keep rules\n",
+	"%s: 编码%s, 共%d行, %d个参数, %d个命中保留规则\n":           "%s: encoding %s, %d lines, %d parameters, %d matching keep rules\n",
+	"%s: 重复的键 %s (行%s)\n":                          "%s: duplicate key %s (lines %s)\n",
+	"读取标准输入失败: %w":                                 "failed to read standard input: %w",
+	"共 %d 个键, %d 个命中保留规则\n":                        "%d keys, %d matching keep rules\n",
+	"用法: %s watch [选项] 模板目录 当前配置文件\n\n选项:\n":       "Usage: %s watch [options] template-dir current-config-file\n\nOptions:\n",
+	"无效的文件名规则 %s: %w":                              "invalid file name pattern %s: %w",
+	"读取当前配置文件失败: %w":                               "failed to read current config file: %w",
+	"开始监视 %s (规则: %s, 间隔: %s, 防抖: %s)，按 Ctrl+C 停止": "watching %s (pattern: %s, interval: %s, debounce: %s), press Ctrl+C to stop",
+	"停止监视": "stopped watching",
+	"检测到模板变化，等待写入完成: %s": "template change detected, waiting for writes to finish: %s",
+	"读取模板目录失败: %w":       "failed to read template directory: %w",
+	"模板已更新，开始合并: %s":     "template updated, merging: %s",
+	"合并失败: 加载配置失败: %v":   "merge failed: failed to load config: %v",
+	"合并失败: %v":           "merge failed: %v",
+	"合并失败: %s: %v":       "merge failed: %s: %v",
+	"合并完成: %s (保留%d个参数, %d个值发生变化, 备份: %s, %s)": "merge complete: %s (%d parameters kept, %d values changed, backups: %s, %s)",
+	"仅列出可用备份": "only list available backups",
+	"要恢复的备份时间戳(格式20060102150405)，默认恢复最新的备份": "timestamp of the backup to restore (format 20060102150405), defaults to the latest backup",
+	"跳过确认提示":   "skip the confirmation prompt",
+	"启用详细输出模式": "enable verbose output",
+	"显示版本信息":   "show version information",
+	"修复模式: 根据旧文件的保留参数修复被错误合并的新文件，第三个参数可指定新模板，此时以模板与保留参数重建文件":                                                          "repair mode: repair a wrongly merged new file using the old file's kept parameters; an optional third argument names the new template to rebuild the file from the template and the kept parameters",
+	"审计模式: 对比指定文件与其最近一次备份，显示键级变更":                                                                                     "audit mode: compare the given file with its latest backup and show key-level changes",
+	"按Spring Boot宽松绑定规则匹配键(kebab/驼峰/下划线/大写环境变量形式等价)，写回时使用新文件中的键名写法":                                                   "match keys using Spring Boot relaxed binding (kebab/camel/underscore/upper-case environment variable forms are equivalent), writing back with the new file's key spelling",
+	"汇总中仅列出合并后值实际发生变化的参数":                                                                                             "list only parameters whose values actually changed in the summary",
+	"将每个处理决策以JSONL格式记录到指定文件":                                                                                          "record every processing decision as JSONL to the given file",
+	"重命名后的键与新文件中已有键冲突时的处理策略: old-wins|new-wins|fail":                                                                  "policy when a renamed key collides with an existing key in the new file: old-wins|new-wins|fail",
+	"忽略匹配规则，自动保留两个文件中都存在且值不同的键":                                                                                       "ignore the patterns and automatically keep keys present in both files with different values",
+	"与-auto-preserve同时使用，额外保留仅存在于旧文件中的键":                                                                              "with -auto-preserve, also keep keys that exist only in the old file",
+	"拆分模式: 将旧文件拆分为保留参数覆盖文件和模板文件，参数为 旧文件 覆盖文件 模板文件":                                                                    "split mode: split the old file into an override file of kept parameters and a template file; arguments are old-file override-file template-file",
+	"导出模式: 将旧文件中的保留参数以指定格式(env|json|yaml)写入第二个参数指定的文件":                                                                "export mode: write the old file's kept parameters in the given format (env|json|yaml) to the file named by the second argument",
+	"key=default格式的默认值文件，旧值等于默认值的参数不予保留":                                                                              "defaults file in key=default format; parameters whose old value equals the default are not kept",
+	"在每个保留参数上方写入来源注释，重复运行时替换而不累加":                                                                                     "write a provenance comment above each kept parameter, replaced rather than repeated on re-runs",
+	"来源注释格式，支持{file}、{line}、{run}占位符":                                                                                 "provenance comment format, supports {file}, {line} and {run} placeholders",
+	"合并后输出新文件原始内容与合并结果的unified diff":                                                                                  "print a unified diff between the original new file and the merge result",
+	"批量模式: 参数为 旧发布目录 新发布目录，按相对路径配对并逐对合并":                                                                              "batch mode: arguments are old-release-dir new-release-dir; files are paired by relative path and merged pair by pair",
+	"Profile模式: 参数为 旧目录 新目录，按application-{profile}.properties约定逐对合并基础文件与各profile文件，profile文件使用env与profile同名的envRules": "profile mode: arguments are old-dir new-dir; merges the base file and each application-{profile}.properties file pair by pair, using the envRules whose env matches the profile",
+	"推送到ssh://远程文件前，在远程主机上以硬链接保留原文件(同目录下的.bak.<时间戳>)":                                                                 "before pushing to an ssh:// remote file, keep the original on the remote host as a hard link (.bak.<timestamp> in the same directory)",
+	"参数错误: 批量模式、Profile模式、拆分模式与导出模式不支持ssh://远程文件":                                                                     "invalid arguments: batch, profile, split and export modes do not support ssh:// remote files",
+	"无效的远程文件 %s，格式应为 ssh://用户@主机:/路径":                                                                                 "invalid remote file %s, expected ssh://user@host:/path",
+	"无效的远程文件 %s: 端口 %s 无效":                                                                                            "invalid remote file %s: invalid port %s",
+	"访问远程文件需要系统中的sftp命令: %w":                                                                                          "accessing remote files requires the sftp command: %w",
+	"sftp执行失败: %w: %s": "sftp failed: %w: %s",
+	"sftp执行失败: %w":     "sftp failed: %w",
+	"上传文件: %s -> %s":   "uploading file: %s -> %s",
+	"上传 %s 失败: %w":     "failed to upload %s: %w",
+	"创建临时目录失败: %w":     "failed to create temporary directory: %w",
+	"复制新文件失败: %w":      "failed to copy new file: %w",
+	"\n合并结果已推送到 %s\n":  "\nmerged result pushed to %s\n",
+	"远程备份: %s\n":       "remote backup: %s\n",
+	"批量模式下同时处理的文件数，每个文件使用独立加载的规则":                                                                     "number of files processed concurrently in batch mode; each file uses its own loaded rules",
+	"参数错误: -parallel必须大于0":                                                                            "invalid arguments: -parallel must be greater than 0",
+	"参数错误: -parallel不能与-interactive或-responses同时使用":                                                   "invalid arguments: -parallel cannot be combined with -interactive or -responses",
+	"批量模式下选择文件的相对路径规则，**匹配任意层级目录":                                                                     "relative path pattern selecting files in batch mode, ** matches any number of directories",
+	"逐项确认模式: 替换或插入每个参数前显示新旧值并询问[y/n/a/q]":                                                             "confirm mode: show the old and new values and ask [y/n/a/q] before replacing or inserting each parameter",
+	"从应答文件回放逐项确认的决定(key=y/n)":                                                                         "replay confirmation decisions from a responses file (key=y/n)",
+	"将逐项确认的决定保存到应答文件，供-responses回放":                                                                   "save confirmation decisions to a responses file for replay with -responses",
+	"三方合并: 上一版本的原始模板文件，作为旧文件与新文件的共同基线":                                                                "three-way merge: the previous release's original template, used as the common base of the old and new files",
+	"旧文件或新文件中存在重复键时的处理策略: first-wins|last-wins|error (默认读取config-matcher.json中的onDuplicate，均未指定时仅报告)": "policy for duplicate keys in the old or new file: first-wins|last-wins|error (defaults to onDuplicate in config-matcher.json; only reported when neither is set)",
+	"读取配置文件使用的编码: utf-8|utf-8-bom|gbk|iso-8859-1 (默认自动识别: 非UTF-8的内容能按GBK解码时视为GBK，否则按ISO-8859-1原样读写)":  "encoding for reading config files: utf-8|utf-8-bom|gbk|iso-8859-1 (auto-detected by default: non-UTF-8 content is treated as GBK when it decodes as GBK, otherwise read and written byte for byte as ISO-8859-1)",
+	"写入配置文件使用的编码: utf-8|utf-8-bom|gbk|iso-8859-1 (默认沿用目标文件原有的编码)":                                     "encoding for written config files: utf-8|utf-8-bom|gbk|iso-8859-1 (defaults to the target file's existing encoding)",
+	"参数错误: 不支持的输入编码: %s":                                                                              "invalid argument: unsupported input encoding: %s",
+	"%s与%s之间的转换需要系统中的iconv命令: %w":                                                                     "converting between %s and %s requires the iconv command: %w",
+	"%s转换为%s失败: %s": "failed to convert %s to %s: %s",
+	"将所有修改、备份路径、时间与校验和以JSON格式写入指定文件":                                                                                         "write all changes, backup paths, timings and checksums as JSON to the given file",
+	"替换已有参数的方式: line(整行使用旧文件的内容)|value(只写入旧值，保留新文件的格式、位置与行尾注释)":                                                              "how existing parameters are replaced: line (use the old file's whole line)|value (write only the old value, keeping the new file's formatting, position and trailing comment)",
+	"旧文件或新文件为JAR/WAR归档时配置文件的条目路径 (默认JAR为BOOT-INF/classes/application.properties，WAR为WEB-INF/classes/application.properties)": "entry path of the config file when the old or new file is a JAR/WAR archive (defaults to BOOT-INF/classes/application.properties for JAR and WEB-INF/classes/application.properties for WAR)",
+	"下载HTTP/HTTPS地址时使用的Basic认证，格式为 用户名:密码":                                                                                   "Basic authentication for HTTP/HTTPS downloads, as user:password",
+	"下载HTTP/HTTPS地址时使用的Bearer令牌":                                                                                             "Bearer token for HTTP/HTTPS downloads",
+	"下载HTTP/HTTPS地址的超时时间":                                                                                                    "timeout for HTTP/HTTPS downloads",
+	"运行结束后每个文件只保留最近几次运行的备份，0为不清理":                                                                                            "after the run, keep only each file's backups from the most recent runs, 0 disables pruning",
+	"运行结束后删除早于该时间的备份，如30d、12h":                                                                                               "after the run, delete backups older than this, e.g. 30d, 12h",
+	"不隐藏敏感参数的值(仅用于调试)，默认在日志与控制台输出中显示为****":                                                                                   "do not hide sensitive values (debugging only); by default they are shown as **** in logs and console output",
+	"合并前执行的shell命令，失败时不执行合并 (默认读取config-matcher.json中的hooks.preMerge)":                                                       "shell command run before the merge; the merge is skipped if it fails (defaults to hooks.preMerge in config-matcher.json)",
+	"合并后执行的shell命令，通过MERGE_STATUS等环境变量获知结果 (默认读取config-matcher.json中的hooks.postMerge)":                                       "shell command run after the merge, which sees the outcome through MERGE_STATUS and related environment variables (defaults to hooks.postMerge in config-matcher.json)",
+	"仅预览修改，不写入任何文件":                                                                                                          "preview changes only, do not write any files",
+	"当前环境名称，用于选择config-matcher.json中的envRules (默认读取APP_ENV)":                                                                 "current environment name, selects envRules in config-matcher.json (defaults to APP_ENV)",
+	"输出语言: zh|en (默认根据LC_ALL/LC_MESSAGES/LANG环境变量选择)":                                                                        "output language: zh|en (defaults from the LC_ALL/LC_MESSAGES/LANG environment variables)",
+	"每个文件保留最近几次运行的备份，0为不限制":                                                                                                  "keep each file's backups from this many recent runs, 0 means no limit",
+	"备份的最长保留时间，如30d、12h，为空时不限制":                                                                                              "maximum backup age, e.g. 30d, 12h; empty means no limit",
+	"仅列出将被删除的备份，不删除任何文件":                                                                                                     "only list the backups that would be deleted, do not delete anything",
+	"按Spring Boot宽松绑定规则匹配键":                                                                                                  "match keys using Spring Boot relaxed binding",
+	"轮询模板目录的间隔":   "interval for polling the template directory",
+	"模板文件名规则":     "template file name pattern",
+	"旧文件备份":       "old file",
+	"合并前":         "pre-merge",
+	"修复前":         "pre-repair",
+	"回滚前":         "pre-rollback",
+	"确认回滚?":       "Confirm rollback?",
+	"跳过(新目录中不存在)": "skipped (not in new directory)",
+	"跳过(旧目录中不存在)": "skipped (not in old directory)",
+	"失败":          "failed",
+	"已合并":         "merged",
+	"预览":          "dry run",
+	"替换":          "replace",
+	"插入":          "insert",
+	"追加":          "append",
+	"拒绝":          "rejected",
+	"接受":          "accepted",
+	"回放":          "replayed",
+	"默认":          "default",
+	"退出":          "quit",
+	"全部接受":        "accept all",
+	"交互":          "interactive",
+	"跳过":          "skip",
+	"旧文件":         "old file",
+	"新文件":         "new file",
+	"已写入旧值":       "old value written",
+	"已中止":         "aborted",
+	"未写入旧值":       "old value not written",
+	"保留新值":        "new value kept",
+	"未处理":         "not handled",
+	"旧配置文件":       "old config file",
+	"新配置文件":       "new config file",
+	"JSON报告":      "JSON report",
+	"跟踪文件":        "trace file",
+	"应答文件":        "responses file",
+	"模板文件":        "template file",
+	"将旧文件中的保留参数合并到新文件(与不带子命令的调用相同)": "merge the old file's kept parameters into the new file (same as running without a subcommand)",
+	"合并": "merge",
+	"输出合并结果与新文件的unified diff，不写入任何文件": "print a unified diff of the merge result against the new file without writing anything",
+	"预览合并计划，不写入任何文件":                  "preview the merge plan without writing anything",
+	"列出或恢复配置文件的备份":                    "list or restore a config file's backups",
+	"回滚":                              "rollback",
+	"查看备份: backups list|log [配置文件]":   "show backups: backups list|log [config-file]",
+	"查看备份":                            "list backups",
+	"按保留策略清理备份":                       "delete backups according to the retention policy",
+	"清理备份":                            "prune backups",
+	"校验config-matcher.json与配置文件":      "validate config-matcher.json and config files",
+	"校验": "validation",
+	"调试保留规则: rules test 键[=值]或配置文件...": "debug keep rules: rules test key[=value] or config file...",
+	"测试规则": "rule test",
+	"监视模板目录，新模板落地后自动合并": "watch a template directory and merge automatically when a new template lands",
+	"监视": "watch",
+	"将旧文件中的保留参数合并到新文件":                                           "merge the old file's kept parameters into the new file",
+	"无效的日志级别: %s (可选: debug|info|warn|error)":                    "invalid log level: %s (choose debug|info|warn|error)",
+	"无效的日志格式: %s (可选: text|json)":                                "invalid log format: %s (choose text|json)",
+	"日志级别: debug|info|warn|error (默认info，指定-v时为debug)":           "log level: debug|info|warn|error (defaults to info, or debug with -v)",
+	"日志格式: text|json，json时每条日志为一行JSON并附带file、key、line、action等字段": "log format: text|json; json writes one JSON object per line with fields such as file, key, line and action",
+	"\n与新模板取值不同的保留参数:":                                           "\nKept parameters whose values differ from the new template:",
+	"%4d: %s: 旧值=%s, 模板值=%s\n":                                   "%4d: %s: old=%s, template=%s\n",
+	"共 %d 个保留参数与新模板取值不同\n":                                       "%d kept parameters differ from the new template\n",
+	"合并后按校验规则文件检查结果(必需的键、非空、整数、布尔值、地址)，未通过时恢复合并前的备份并以非零状态退出":              "after merging, check the result against a rules file (required keys, non-empty, integer, boolean, URL); on failure restore the pre-merge backup and exit non-zero",
+	"合并后按校验规则文件检查结果，未通过时恢复合并前的备份":                                         "after merging, check the result against a rules file; on failure restore the pre-merge backup",
+	"同时按校验规则文件检查各配置文件(必需的键、非空、整数、布尔值、地址)，默认使用config-matcher.json中的checks": "also check each config file against a rules file (required keys, non-empty, integer, boolean, URL); defaults to checks in config-matcher.json",
+	"校验规则: %s (必需%d, 非空%d, 整数%d, 布尔值%d, 地址%d)\n":                          "Check rules: %s (required %d, non-empty %d, integer %d, boolean %d, URL %d)\n",
+	"解析 %s 失败: %w":               "failed to parse %s: %w",
+	"校验合并结果失败: %w":               "failed to check merged result: %w",
+	"合并结果通过校验: %s":               "merged result passed checks: %s",
+	"合并结果未通过校验(%d处)，且恢复备份失败: %w": "merged result failed %d checks and restoring the backup failed: %w",
+	"已从备份 %s 恢复 %s":              "restored %[2]s from backup %[1]s",
+	"合并结果未通过校验: %d处错误":           "merged result failed checks: %d errors",
+	"用法: %s backups list [配置文件路径]\n       %s backups log [选项] [配置文件路径]\n\n列出备份目录 %s 中的备份，指定配置文件时只列出该文件的备份；log显示Git备份仓库的提交历史\n": "Usage: %s backups list [config-file]\n       %s backups log [options] [config-file]\n\nList the backups in backup directory %s; with a config file, list only that file's backups. log shows the commit history of the Git backup repository\n",
+	"\n合并前后的新文件已提交到备份仓库: %s\n": "\nThe new file before and after the merge was committed to backup repository: %s\n",
+	"  备份仓库: %s\n":                                    "  Backup repository: %s\n",
+	"参数错误: 无效的备份方式: %s":                               "invalid argument: invalid backup mode: %s",
+	"无效的备份方式: %s":                                     "invalid backup mode: %s",
+	"Git备份需要系统中的git命令: %w":                            "Git backups require the git command: %w",
+	"git %s 执行失败: %w: %s":                             "git %s failed: %w: %s",
+	"git %s 执行失败: %w":                                 "git %s failed: %w",
+	"创建备份仓库失败: %w":                                    "failed to create backup repository: %w",
+	"已创建备份仓库: %s":                                     "Created backup repository: %s",
+	"%s 与备份仓库中的上一次提交相同，未提交":                           "%s is identical to the last commit in the backup repository, nothing committed",
+	"%s: %s\n\n文件: %s\n工具版本: %s\n规则哈希: %s\n操作人: %s\n": "%s: %s\n\nFile: %s\nTool version: %s\nRules hash: %s\nOperator: %s\n",
+	"已提交到备份仓库: %s %s (%s)":                            "Committed to backup repository: %s %s (%s)",
+	"提交合并前的文件失败: %w":                                  "failed to commit the file before merging: %w",
+	"提交合并结果失败: %w":                                    "failed to commit the merge result: %w",
+	"合并后":                                             "post-merge",
+	"用法: %s backups log [选项] [配置文件路径]\n\n显示备份仓库 %s 中的提交历史，指定配置文件时只显示该文件的历史\n\n选项:\n": "Usage: %s backups log [options] [config-file]\n\nShow the commit history of backup repository %s; with a config file, show only that file's history\n\nOptions:\n",
+	"备份仓库 %s 不存在，使用-backup-mode git或both合并后创建":                                       "backup repository %s does not exist; it is created by merging with -backup-mode git or both",
+	"备份仓库 %s 中未找到 %s 的提交": "no commits for %[2]s found in backup repository %[1]s",
+	"备份仓库 %s 中没有提交\n":     "Backup repository %s has no commits\n",
+	"备份方式: copy(带时间戳的文件副本)|git(将合并前后的新文件提交到备份目录下的Git仓库)|both": "backup mode: copy (timestamped file copies)|git (commit the new file before and after merging to a Git repository under the backup directory)|both",
+	"备份方式: copy|git|both": "backup mode: copy|git|both",
+	"最多显示的提交数，0为不限制":      "maximum number of commits to show, 0 for no limit",
+	"同时显示每次提交的修改内容":       "also show the changes of each commit",
+	"参数错误: 无效的插入策略: %s":   "invalid argument: invalid insert strategy: %s",
+	"新文件中不存在的保留参数的插入位置: line(旧文件中的行号)|anchor(与之共享最长点分前缀的最后一个参数之后，如spring.redis.*参数块)|append(文件末尾)": "where to insert preserved keys missing from the new file: line (old file's line number)|anchor (after the last key sharing the longest dotted prefix, e.g. the spring.redis.* block)|append (end of file)",
+	"将合并结果写入指定文件(-为标准输出)，不修改新文件、不创建备份；新文件为HTTP/HTTPS地址时为下载并写入合并结果的本地文件 (默认为当前目录下与地址同名的文件)":         "write the merge result to this file (- for stdout) without modifying the new file or creating backups; when the new file is an HTTP/HTTPS URL, the local file to download to and write the merge result into (defaults to the URL's file name in the current directory)",
+	"参数错误: 旧文件与新文件不能都从标准输入读取":                                                "invalid argument: the old and new files cannot both be read from stdin",
+	"参数错误: 标准输入输出与-output不能用于批量模式、Profile模式、拆分、导出、修复模式、ssh://远程文件与JAR/WAR归档": "invalid argument: stdin/stdout and -output cannot be used with batch mode, profile mode, split, convert, repair mode, ssh:// remote files or JAR/WAR archives",
+	"参数错误: 从标准输入读取配置时不能使用-interactive":                                       "invalid argument: -interactive cannot be used when reading a config from stdin",
+	"合并结果未通过校验: %d处错误，未写入合并结果":                                               "merge result failed validation: %d errors, result not written",
+	"写入标准输出失败: %w":       "failed to write to stdout: %w",
+	"写入输出文件失败: %w":       "failed to write output file: %w",
+	"合并结果已写入: %s\n":      "Merge result written to: %s\n",
+	"输出文件":               "output file",
+	"无效的Consul路径 %s: %w": "invalid Consul path %s: %w",
+	"无效的Consul路径 %s，格式应为 consul://主机:端口/前缀": "invalid Consul path %s, expected consul://host:port/prefix",
+	"读取 %s 失败: %w": "failed to read %s: %w",
+	"读取 %s 失败: %s": "failed to read %s: %s",
+	"从 %s 读取%d个键":  "Read %[2]d keys from %[1]s",
+	"需要修改%d个键，超过Consul单个事务的上限%d，将分多个事务提交": "%d keys need changes, more than Consul's limit of %d per transaction; committing in several transactions",
+	"写入 %s 失败: %w": "failed to write %s: %w",
+	"写入 %s 失败: 以下键在合并期间被修改，本次事务未写入: %s": "failed to write %s: these keys were modified during the merge, this transaction was not applied: %s",
+	"写入 %s 失败: %s: %s": "failed to write %s: %s: %s",
+	"写入 %s 失败: %s":     "failed to write %s: %s",
+	"\n合并结果已写入 %s (写入%d个键, 删除%d个键)\n":                      "\nMerge result written to %s (%d keys written, %d keys deleted)\n",
+	"访问consul://路径使用的ACL令牌 (默认读取CONSUL_HTTP_TOKEN)":        "ACL token for consul:// paths (defaults to CONSUL_HTTP_TOKEN)",
+	"参数错误: -report-json与-report-html不能与标准输入输出或-output同时使用": "invalid argument: -report-json and -report-html cannot be used with stdin/stdout or -output",
+	"写入HTML报告失败: %w": "failed to write HTML report: %w",
+	"HTML报告已写入: %s":  "HTML report written: %s",
+	"(无说明的规则)":       "(rule without comment)",
+	"… 省略%d行未变内容 …":  "… %d unchanged lines omitted …",
+	"生成包含并排差异、保留参数、规则命中与备份文件的HTML报告，可作为变更审批附件": "write an HTML report with a side-by-side diff, preserved keys, rule hits and backups, suitable as a change-management attachment",
+	"HTML报告":     "HTML report",
+	"配置合并报告":     "Configuration merge report",
+	"预览模式":       "dry run",
+	"主机":         "Host",
+	"操作人":        "Operator",
+	"开始时间":       "Started",
+	"结束时间":       "Finished",
+	"工具版本":       "Tool version",
+	"规则哈希":       "Rules hash",
+	"合并结果":       "Merged result",
+	"统计":         "Summary",
+	"重命名冲突":      "Rename collisions",
+	"三方合并冲突":     "Three-way conflicts",
+	"差异":         "Diff",
+	"合并前后内容相同":   "The merge did not change the file",
+	"保留参数":       "Preserved keys",
+	"键":          "Key",
+	"动作":         "Action",
+	"行":          "Line",
+	"新文件中的值":     "Value in new file",
+	"保留的值":       "Preserved value",
+	"规则":         "Rule",
+	"说明":         "Notes",
+	"重命名自":       "renamed from",
+	"转换前":        "before transform",
+	"未确认，已跳过":    "declined, skipped",
+	"没有保留参数":     "No preserved keys",
+	"规则命中":       "Rule hits",
+	"命中数":        "Hits",
+	"没有参数命中保留规则": "No keys matched a preservation rule",
+	"备份文件":       "Backups",
+	"路径":         "Path",
+	"未创建备份":      "No backups created",
+	"解密ENC(...)值的Jasypt密码，用于按明文校验与转换加密值，转换后重新加密 (默认读取JASYPT_ENCRYPTOR_PASSWORD)":                          "Jasypt password for ENC(...) values, used to validate and transform encrypted values as plaintext and re-encrypt them afterwards (defaults to JASYPT_ENCRYPTOR_PASSWORD)",
+	"Jasypt的PBE算法: PBEWITHHMACSHA512ANDAES_256(jasypt-spring-boot 3.x默认)|PBEWithMD5AndDES(Jasypt 1.x默认)等": "Jasypt PBE algorithm: PBEWITHHMACSHA512ANDAES_256 (jasypt-spring-boot 3.x default)|PBEWithMD5AndDES (Jasypt 1.x default), etc.",
+	"将值为ENC(...)的Jasypt加密值视为不透明的值，无论是否命中保留规则都予以保留":                                                        "treat ENC(...) Jasypt values as opaque and always preserve them, whether or not they match a preservation rule",
+	"已获得文件锁: %s":                  "acquired file lock: %s",
+	"创建锁文件失败: %w":                 "failed to create lock file: %w",
+	"%s 正被其他进程处理(%s)，%v内未能获得锁 %s": "%[1]s is being processed by another process (%[2]s); could not acquire lock %[4]s within %[3]v",
+	"%s 正被其他进程处理，等待锁 %s (最长%v)":   "%s is being processed by another process, waiting for lock %s (up to %v)",
+	"目标文件正被其他进程处理时等待锁(目标文件旁的.lock文件)的最长时间，超时仍未获得锁时不做任何修改并退出，0为不等待": "how long to wait for the lock (a .lock file next to the target) while another process is working on the target; exits without changes if it cannot be acquired, 0 means do not wait",
+	"目标文件正被其他进程处理时等待锁的最长时间，0为不等待":                                  "how long to wait for the target's lock while another process is working on it, 0 means do not wait",
+	"持有者未知": "holder unknown",
+	"%s 正被其他进程处理(%s)，未能获得锁 %s，可用-lock-timeout指定等待时间": "%[1]s is being processed by another process (%[2]s); could not acquire lock %[3]s, use -lock-timeout to wait for it",
+	"校验写入结果失败: %w":                  "failed to verify the written result: %w",
+	"%s: 写入后不存在":                    "%s: missing after writing",
+	"%s: 写入后的值为%q，应为%q":             "%s: value after writing is %q, expected %q",
+	"写入结果核对通过: %s (%d个参数)":          "Written result verified: %s (%d keys)",
+	"写入结果核对失败: %s":                  "Written result verification failed: %s",
+	"写入结果核对失败(%d处)，且恢复备份失败: %w":     "written result verification failed (%d problems), and restoring the backup failed: %w",
+	"写入结果核对失败: %d处参数与合并结果不一致，已恢复备份": "written result verification failed: %d keys do not match the merge result, backup restored",
+	"写入清单文件失败: %w":                  "failed to write manifest: %w",
+	"清单文件已写入: %s":                   "Manifest written: %s",
+	"将输入、备份与输出文件的SHA-256校验和写入JSON清单文件(如merge-manifest.json)": "write the SHA-256 checksums of the input, backup and output files to a JSON manifest (e.g. merge-manifest.json)",
+	"清单文件": "manifest",
+	"参数错误: Spring Cloud Config Server只能作为新文件":                               "invalid arguments: a Spring Cloud Config Server can only be the new file",
+	"参数错误: 批量模式、Profile模式、拆分模式与导出模式不支持Config Server地址":                      "invalid arguments: batch mode, profile mode, split mode and export mode do not support Config Server addresses",
+	"无效的Config Server地址 %s: %w":                                             "invalid Config Server address %s: %w",
+	"无效的Config Server地址 %s，格式应为 configserver://主机:端口/应用/profile[?label=分支]": "invalid Config Server address %s, expected configserver://host:port/app/profile[?label=branch]",
+	"解析 %s 的响应失败: %w":                                                       "failed to parse the response from %s: %w",
+	"%s 没有返回任何propertySource，请检查应用名、profile与label":                          "%s returned no propertySources, check the application name, profile and label",
+	"解析 %s 的响应失败: %s: %w":                                                   "failed to parse the response from %s: %s: %w",
+	"来自Spring Cloud Config Server: %s":                                      "From Spring Cloud Config Server: %s",
+	"从 %s 读取%d个键(%d个propertySource)":                                        "Read %[2]d keys from %[1]s (%[3]d propertySources)",
+	"source不是JSON对象":                                                        "source is not a JSON object",
+	"用法: %s rules test [选项] [键、key=value或配置文件...]\n\n判断各键或配置文件中的各行是否命中 %s 中的保留规则，未给出参数时从标准输入逐行读取\n\n选项:\n": "Usage: %s rules test [options] [key, key=value or config file...]\n\nCheck whether each key, or each line of a config file, matches the keep rules in %s; reads lines from standard input when no arguments are given\n\nOptions:\n",
+	"%s保留    %s (%s)\n": "%skeep    %s (%s)\n",
+	"%s不保留  %s\n":       "%sdrop    %s\n",
+	"加密值":               "encrypted value",
+	"rules第%d条 %s: %s":  "rule #%d %s: %s",
+	"读取期望结果文件失败: %w":    "failed to read expectations file: %w",
+	"%s:%d: 无效的期望结果，格式应为 \"+ 键[=值]\" 或 \"- 键[=值]\"": "%s:%d: invalid expectation, expected \"+ key[=value]\" or \"- key[=value]\"",
+	"符合预期: %s": "As expected: %s",
+	"%s:%d: %s 应保留，但未命中任何保留规则\n":                            "%s:%d: %s should be kept but matches no keep rule\n",
+	"%s:%d: %s 不应保留，但命中了 %s\n":                              "%s:%d: %s should not be kept but matches %s\n",
+	"共 %d 项期望, %d 项不符合\n":                                   "%d expectations, %d not met\n",
+	"保留规则测试未通过: %d项不符合预期":                                   "keep rule test failed: %d expectations not met",
+	"ENC(...)形式的Jasypt加密值视为命中保留规则":                          "treat ENC(...) Jasypt-encrypted values as matching the keep rules",
+	"期望结果文件: 每行为 \"+ 键[=值]\"(应保留) 或 \"- 键[=值]\"(不应保留)，逐项核对": "expectations file: each line is \"+ key[=value]\" (should be kept) or \"- key[=value]\" (should not be kept), checked one by one",
+	"参数错误: -jobs不接受文件参数，且不能与批量模式、Profile模式、拆分、导出、修复模式、-output、-report-html或-interactive同时使用": "invalid arguments: -jobs takes no file arguments and cannot be combined with batch mode, profile mode, split, export, repair mode, -output, -report-html or -interactive",
+	"执行任务清单失败: %w":             "failed to run jobs file: %w",
+	"写入结果核对失败: %d处参数与合并结果不一致":  "written result verification failed: %d keys do not match the merge result",
+	"读取任务清单失败: %w":             "failed to read jobs file: %w",
+	"解析任务清单 %s 失败: %w":         "failed to parse jobs file %s: %w",
+	"任务清单 %s 中没有任务":            "jobs file %s contains no jobs",
+	"任务清单 %s 的第%d个任务缺少old或new": "job %[2]d in jobs file %[1]s is missing old or new",
+	"第%d行: 列表项的缩进不一致":          "line %d: inconsistent list item indentation",
+	"第%d行: 应为\"- 字段: 值\"形式的任务": "line %d: expected a job of the form \"- field: value\"",
+	"第%d行: 应为\"字段: 值\"":        "line %d: expected \"field: value\"",
+	"第%d行: %w":                 "line %d: %w",
+	"未知的字段: %s":                "unknown field: %s",
+	"从 %s 加载%d个任务":             "Loaded %[2]d jobs from %[1]s",
+	"执行任务: %s":                 "Running job: %s",
+	"不支持的文件格式: %s":             "unsupported file format: %s",
+	"共编译%d组保留规则":               "Compiled %d sets of keep rules",
+	"任务清单模式: 依次执行YAML或JSON任务清单中的全部合并任务(旧文件、新文件、环境、格式、输出文件)，输出一份汇总": "jobs mode: run every merge job (old file, new file, env, format, output file) in a YAML or JSON jobs file and print one combined summary",
+	"不使用颜色输出(标准输出不是终端或设置了NO_COLOR环境变量时自动关闭)":                       "disable colored output (turned off automatically when stdout is not a terminal or NO_COLOR is set)",
+	"写入操作日志失败: %v":       "failed to write the operation journal: %v",
+	"读取操作日志失败: %w":       "failed to read the operation journal: %w",
+	"读取操作日志失败: 第%d行: %w": "failed to read the operation journal: line %d: %w",
+	"用法: %s undo [选项] 配置文件路径\n\n按操作日志 %s 撤销对文件的最近一次操作\n\n选项:\n": "Usage: %s undo [options] config-file\n\nUndo the most recent operation on the file using the journal %s\n\nOptions:\n",
+	"操作日志 %s 中没有 %s 的记录":                        "the journal %s has no entries for %s",
+	"%s 的所有操作都已撤销":                              "all operations on %s have already been undone",
+	"操作 %s (%s) 没有可用于恢复的备份，无法撤销":                "operation %s (%s) has no backup to restore and cannot be undone",
+	"操作 %s 的备份已不存在，无法撤销: %w":                    "the backup of operation %s no longer exists, cannot undo: %w",
+	"%s 在操作 %s 之后又被修改过，撤销会丢失这些修改；确认后使用-force撤销": "%s was modified after operation %s and undoing would lose those changes; use -force to undo anyway",
+	"%s 在操作 %s 之后又被修改过，仍然撤销":                    "%s was modified after operation %s, undoing anyway",
+	"将撤销 %s 的%s操作 (%s, %s)，使用备份 %s 覆盖 %s\n":     "Undoing the %[2]s operation of %[1]s (%[3]s, %[4]s): overwriting %[6]s with backup %[5]s\n",
+	"已取消撤销": "undo cancelled",
+	"文件已恢复，但写入操作日志失败: %w": "the file was restored, but writing the operation journal failed: %w",
+	"撤销完成!":            "Undo complete!",
+	"撤销前的文件已备份至: %s\n": "The file before undo was backed up to: %s\n",
+	"%s 的操作记录:\n":      "Journal entries for %s:\n",
+	"(已撤销)":            "(undone)",
+	"(撤销 %s)":          "(undoes %s)",
+	"共 %d 条记录\n":       "%d entries in total\n",
+	"仅列出操作日志中该文件的记录":   "only list the journal entries for the file",
+	"文件在该操作之后又被修改过时仍然撤销": "undo even if the file was modified after the operation",
+	"撤销前":   "pre-undo",
+	"确认撤销?": "Confirm undo?",
+	"按操作日志撤销对配置文件的最近一次操作": "undo the most recent operation on a config file using the journal",
+	"撤销": "undo",
+	"从配置文件 %s 加载%d条XML保留规则": "loaded %[2]d XML preservation rules from config file %[1]s",
+	"选用规则组 %s":              "using rule profile %s",
+	"无":                     "none",
+	"规则组: %s (选用: %s)\n":    "rule profiles: %s (selected: %s)\n",
+	"选用config-matcher.json中profiles下的规则组，如dev、test、prod (默认读取UPDATE_CONFIG_PROFILE)": "rule profile to use from profiles in config-matcher.json, e.g. dev, test, prod (defaults to UPDATE_CONFIG_PROFILE)",
+	"从配置文件 %s 加载%d条排除规则":                  "loaded %[2]d exclude rules from config file %[1]s",
+	"%s不保留  %s (%s，被excludeKeys第%d条排除)\n": "%sdrop    %s (%s, excluded by excludeKeys #%d)\n",
+	"用法: %s serve [选项]\n\n提供合并、规则与备份查询的HTTP API: POST /merge, GET /rules, GET /backups, GET /metrics\n\n选项:\n": "Usage: %s serve [options]\n\nServe an HTTP API for merging and querying rules and backups: POST /merge, GET /rules, GET /backups, GET /metrics\n\nOptions:\n",
+	"参数错误: 必须通过-token或%s指定API令牌":     "invalid arguments: an API token must be given with -token or %s",
+	"参数错误: -max-body必须大于0":           "invalid arguments: -max-body must be greater than 0",
+	"停止API服务":                        "stopping the API server",
+	"API服务监听 %s，按 Ctrl+C 停止":         "API server listening on %s, press Ctrl+C to stop",
+	"启动API服务失败: %w":                  "failed to start the API server: %w",
+	"不支持的请求方法: %s":                   "unsupported request method: %s",
+	"拒绝未授权的请求: %s %s (%s)":           "rejected unauthorized request: %s %s (%s)",
+	"未授权":                            "unauthorized",
+	"处理请求: %s %s (%s)":               "handling request: %s %s (%s)",
+	"请求体超过%d字节":                      "request body exceeds %d bytes",
+	"解析请求失败: %w":                     "failed to parse request: %w",
+	"请求须提供old与new，或者oldPath与newPath": "the request must provide either old and new, or oldPath and newPath",
+	"请求须同时提供old与new":                 "the request must provide both old and new",
+	"请求须同时提供oldPath与newPath":         "the request must provide both oldPath and newPath",
+	"服务未指定-root，不接受按路径合并的请求":         "the server was started without -root and does not accept merges by path",
+	"API合并失败: %v":                    "API merge failed: %v",
+	"API合并完成: %s (保留%d个参数)":          "API merge finished: %s (%d parameters kept)",
+	"路径 %s 不在允许的目录 %s 下":             "path %s is outside the allowed directory %s",
+	"写入响应失败: %v":                     "failed to write response: %v",
+	"监听地址":                           "listen address",
+	"API令牌，请求须携带 Authorization: Bearer <令牌> (默认读取UPDATE_CONFIG_API_TOKEN)": "API token; requests must send Authorization: Bearer <token> (defaults to UPDATE_CONFIG_API_TOKEN)",
+	"请求体的最大字节数": "maximum request body size in bytes",
+	"允许按路径合并的目录，路径方式的请求只能访问该目录下的文件；为空时只接受内容方式的请求": "directory allowed for merges by path; path requests may only access files under it. When empty only content requests are accepted",
+	"按路径合并后按校验规则文件检查结果，未通过时恢复合并前的备份":              "after merging by path, check the result against a check rules file and restore the pre-merge backup on failure",
+	"提供合并、规则与备份查询的HTTP API":                       "serve an HTTP API for merging and querying rules and backups",
+	"API服务":               "API server",
+	"在 %s/metrics 提供监控指标": "Serving metrics at %s/metrics",
+	"启动监控指标服务失败: %v":      "Failed to start metrics server: %v",
+	"在指定地址上提供Prometheus监控指标(/metrics)，为空时不提供": "serve Prometheus metrics (/metrics) on this address; disabled when empty",
+	"发送合并通知失败: %v":   "failed to send merge notification: %v",
+	"发送%s合并通知失败: %v": "failed to send %s merge notification: %v",
+	"已发送%s合并通知":      "Sent %s merge notification",
+	"HTTP状态 %s: %s":  "HTTP status %s: %s",
+	"错误码 %d: %s":     "error code %d: %s",
+	"配置合并失败: %s":     "Config merge failed: %s",
+	"配置合并完成: %s":     "Config merged: %s",
+	"- 主机: %s\n":     "- Host: %s\n",
+	"- 文件: %s\n":     "- File: %s\n",
+	"- 修改的参数: %d\n":  "- Changed keys: %d\n",
+	"- 冲突: %d\n":     "- Conflicts: %d\n",
+	"- 错误: %s\n":     "- Error: %s\n",
+	"- 报告: %s\n":     "- Report: %s\n",
+	"- 时间: %s\n":     "- Time: %s\n",
+	"将合并结果中每一行的来源(template新模板、replaced以旧值替换、inserted从旧文件插入)以JSON格式写入指定文件，供审阅混合来源的文件": "write the origin of every line of the merged file (template, replaced from the old value, inserted from the old file) to this file as JSON, for reviewing mixed-origin files",
+	"写入行来源文件失败: %w": "failed to write line origins file: %w",
+	"行来源已写入: %s":    "Line origins written: %s",
+	"行来源文件":         "line origins file",
+	"参数错误: -line-origins不能与标准输入输出或-output同时使用": "invalid arguments: -line-origins cannot be used with standard input/output or -output",
+	"无效的文件权限: %s，应为八进制数如0640":                  "invalid file mode: %s, expected an octal number such as 0640",
+	"无效的属组: %w": "invalid group: %w",
+	"无效的属主: %w": "invalid owner: %w",
+	"当前平台不支持数字形式的用户或组ID: %s": "numeric user or group IDs are not supported on this platform: %s",
+	"设置文件权限失败: %w":           "failed to set file mode: %w",
+	"设置属主失败: %w":             "failed to set owner: %w",
+	"设置SELinux安全上下文失败: %w":   "failed to set SELinux security context: %w",
+	"已设置文件属性: %s":            "File attributes set: %s",
+	"写入后将配置文件的权限设置为指定值，如0640 (默认沿用原文件的权限)":                                  "set the mode of written config files, e.g. 0640 (default: keep the original file's mode)",
+	"写入后将配置文件的属主与属组设置为指定值，格式为 用户[:组]，可使用名称或数字ID (默认沿用原文件的属主与属组)":            "set the owner and group of written config files as user[:group], by name or numeric ID (default: keep the original file's owner and group)",
+	"写入后将配置文件的SELinux安全上下文设置为指定值，如system_u:object_r:etc_t:s0 (默认沿用原文件的上下文)": "set the SELinux security context of written config files, e.g. system_u:object_r:etc_t:s0 (default: keep the original file's context)",
+	"注释指令": "comment directive",
+	"新文件中已不存在(可能已被上游删除)的保留参数的处理方式: insert(按-insert-strategy插入)|append(追加到文件末尾)|comment(注释掉后插入并注明)|drop(不写入，只在汇总与报告中列出)|ask(逐个询问)": "how to handle preserved parameters that no longer exist in the new file (possibly removed upstream): insert (per -insert-strategy)|append (at end of file)|comment (insert commented out with a note)|drop (do not write, only list in summary and report)|ask (ask for each)",
+	"\n新文件中已不存在: %s\n":                       "\nno longer in new file: %s\n",
+	"处理方式? [i/a/c/d] (插入/追加到末尾/注释后插入/不写入): ": "action? [i/a/c/d] (insert/append at end/insert commented out/drop): ",
+	"\n新文件中已不存在的参数:":                         "\nparameters no longer in new file:",
+	"%s = %s (已注释写入行%d)":                     "%s = %s (written commented out at line %d)",
+	"%s = %s (未写入)":                          "%s = %s (not written)",
+	"共 %d 个参数在新文件中已不存在\n":                    "%d parameter(s) no longer in new file\n",
+	"参数错误: 无效的已删除参数处理方式: %s":                 "invalid argument: invalid obsolete parameter policy: %s",
+	"参数错误: -parallel不能与-obsolete ask同时使用":    "invalid argument: -parallel cannot be combined with -obsolete ask",
+	"注释写入":       "commented",
+	"不写入":        "dropped",
+	"读取密钥失败: %w": "failed to read secrets: %w",
+	"Vault路径 %s 中没有字段 %s (参数 %s)":                                "Vault path %s has no field %s (parameter %s)",
+	"从Vault读取%d个参数的值":                                            "read values of %d parameter(s) from Vault",
+	"未指定Vault地址(vault.address或VAULT_ADDR)":                       "Vault address not specified (vault.address or VAULT_ADDR)",
+	"未指定Vault令牌(vault.token或VAULT_TOKEN)":                        "Vault token not specified (vault.token or VAULT_TOKEN)",
+	"AppRole认证需要roleId与secretId(或VAULT_ROLE_ID与VAULT_SECRET_ID)": "AppRole authentication requires roleId and secretId (or VAULT_ROLE_ID and VAULT_SECRET_ID)",
+	"Vault AppRole登录失败: %w":                                      "Vault AppRole login failed: %w",
+	"Vault AppRole登录失败: 响应中没有client_token":                       "Vault AppRole login failed: no client_token in response",
+	"已通过AppRole登录Vault":                                          "logged in to Vault via AppRole",
+	"读取Vault路径 %s 失败: %w":                                        "failed to read Vault path %s: %w",
+	"HTTP状态 %s":                                                  "HTTP status %s",
+	"解析响应失败: %w":                                                 "failed to parse response: %w",
+	"访问etcd://路径使用的用户名与密码，格式为 用户名:密码 (默认读取ETCDCTL_USER)":    "user name and password for etcd:// paths as user:password (defaults to ETCDCTL_USER)",
+	"写入etcd://路径的键附加一个新申请的租约，租约到期(未续约)时这些键被删除，如10m；0为不使用租约": "attach keys written to etcd:// paths to a newly granted lease; they are deleted when it expires unless renewed, e.g. 10m; 0 uses no lease",
+	"写入etcd://路径的键附加已有的租约ID(十进制)，由其他进程负责续约":                 "attach keys written to etcd:// paths to an existing lease ID (decimal) kept alive by another process",
+	"无效的etcd路径 %s: %w":                  "invalid etcd path %s: %w",
+	"无效的etcd路径 %s，格式应为 etcd://主机:端口/前缀": "invalid etcd path %s, expected etcd://host:port/prefix",
+	"etcd用户的格式应为 用户名:密码":                "the etcd user must be user:password",
+	"etcd认证失败: %w":                      "etcd authentication failed: %w",
+	"申请etcd租约失败: %w":                    "failed to grant etcd lease: %w",
+	"申请etcd租约失败: 无效的租约ID %q":            "failed to grant etcd lease: invalid lease ID %q",
+	"已申请etcd租约 %d (TTL %d秒)":            "granted etcd lease %d (TTL %d seconds)",
+	"写入 %s 失败: 以下键中有键在合并期间被修改，本次事务未写入: %s":                                                        "failed to write %s: one of these keys changed during the merge, nothing was written: %s",
+	"键值存储中的参数按properties格式合并，模板 %s 必须为properties文件":                                               "key-value store entries are merged as properties; template %s must be a properties file",
+	"键值存储中的参数按properties格式合并，旧文件 %s 必须为properties文件":                                              "key-value store entries are merged as properties; old file %s must be a properties file",
+	"参数错误: 标准输入输出与-output不能用于批量模式、Profile模式、拆分、导出、修复模式、ssh://远程文件、consul://与etcd://路径以及JAR/WAR归档": "invalid argument: stdin/stdout and -output cannot be used with batch mode, profile mode, split, convert, repair mode, ssh:// remote files, consul:// or etcd:// paths, or JAR/WAR archives",
+	"参数错误: Config Server地址不能与ssh://远程文件、consul://或etcd://路径同时使用":                                  "invalid arguments: a Config Server address cannot be combined with ssh:// remote files, consul:// or etcd:// paths",
+	"参数错误: 批量模式、Profile模式、拆分模式与导出模式不支持consul://与etcd://路径":                                        "invalid argument: batch mode, profile mode, split mode and convert mode do not support consul:// or etcd:// paths",
+	"参数错误: consul://与etcd://路径不能与ssh://远程文件同时使用":                                                  "invalid argument: consul:// and etcd:// paths cannot be combined with ssh:// remote files",
+	"参数错误: consul://路径不能与etcd://路径同时使用":                                                           "invalid argument: consul:// paths cannot be combined with etcd:// paths",
+	"参数错误: -etcd-ttl与-etcd-lease不能同时使用":                                                           "invalid argument: -etcd-ttl and -etcd-lease cannot be used together",
+	"properties合并结果中非ASCII字符的写法: keep(保持原样)|ascii(写作\\uXXXX转义)|utf8(将\\uXXXX转义还原为UTF-8原文)":        "how non-ASCII characters are written in merged properties: keep (as is)|ascii (as \\uXXXX escapes)|utf8 (decode \\uXXXX escapes to UTF-8)",
+	"参数错误: 无效的Unicode写法: %s":                                                                      "invalid argument: invalid unicode mode: %s",
+	"将合并结果中含有{{的值作为Go模板渲染，可引用环境变量(如{{ .DB_HOST }})与主机名({{ .Hostname }})，引用不存在的变量时不写入任何修改":         "render values containing {{ in the merge result as Go templates with access to environment variables (e.g. {{ .DB_HOST }}) and the hostname ({{ .Hostname }}); nothing is written if a variable is undefined",
+	"渲染值模板使用的变量文件(yaml|json|toml|properties|env)，指定时同时启用-templates":                               "variables file for value templates (yaml|json|toml|properties|env); implies -templates",
+	"加载模板数据失败: %w":     "failed to load template data: %w",
+	"读取变量文件失败: %w":     "failed to read values file: %w",
+	"%s 第%d行: %w":      "%s line %d: %w",
+	"从 %s 加载%d个模板变量":   "loaded %[2]d template variables from %[1]s",
+	"变量 %s 既是值又包含下级变量": "variable %s is both a value and a parent of other variables",
+	"终端界面: 合并前并排显示新模板与合并结果，逐项切换是否写入后应用或放弃": "terminal UI: show the template and the merge result side by side before writing, toggle individual changes, then apply or abort",
+	"参数错误: -tui需要在终端中运行(标准输入与标准输出均为终端)":    "invalid argument: -tui must run in a terminal (stdin and stdout must both be terminals)",
+	"没有需要确认的修改":            "no changes to review",
+	"-tui需要系统中的stty命令: %w": "-tui requires the stty command: %w",
+	"新模板: ":                "Template: ",
+	"↑↓/jk 选择修改  空格 切换  A 全部切换  PgUp/PgDn 翻页  a 应用  q 放弃":           "↑↓/jk select  space toggle  A toggle all  PgUp/PgDn scroll  a apply  q abort",
+	"参数错误: -tui不能与-interactive、-jobs、批量模式、Profile模式、拆分、导出或修复模式同时使用": "invalid argument: -tui cannot be combined with -interactive, -jobs, batch mode, profile mode, split, convert or repair mode",
+	"参数错误: -tui不能与标准输入输出或-output同时使用":                               "invalid argument: -tui cannot be used with stdin/stdout or -output",
+	"参数错误: -tui不支持JAR/WAR归档":                                        "invalid argument: -tui does not support JAR/WAR archives",
+	"已放弃合并，未写入任何修改":                                                 "merge aborted, nothing written",
+	"注释":                  "comment",
+	"终端界面":                "terminal UI",
+	"复制备份 %s 到 %s 失败: %w": "failed to copy backup %s to %s: %w",
+	"已复制备份 %s 到 %s":       "copied backup %s to %s",
+	"不支持的备份目的地: %s":       "unsupported backup destination: %s",
+	"无效的备份目的地 %s，格式应为 %s://桶/前缀":                                                    "invalid backup destination %s, expected %s://bucket/prefix",
+	"未指定S3凭据(accessKeyId与secretAccessKey或AWS_ACCESS_KEY_ID与AWS_SECRET_ACCESS_KEY)":  "no S3 credentials (accessKeyId and secretAccessKey, or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)",
+	"未指定OSS地址(endpoint或OSS_ENDPOINT)":                                               "no OSS endpoint (endpoint or OSS_ENDPOINT)",
+	"未指定OSS凭据(accessKeyId与secretAccessKey或OSS_ACCESS_KEY_ID与OSS_ACCESS_KEY_SECRET)": "no OSS credentials (accessKeyId and secretAccessKey, or OSS_ACCESS_KEY_ID and OSS_ACCESS_KEY_SECRET)",
+	"从配置文件 %s 加载%d个备份目的地":                                                           "loaded %[2]d backup destinations from config file %[1]s",
+	"-backup-dir不能为空":                                                               "-backup-dir must not be empty",
+	"-backup-template必须包含{name}与{ts}: %s":                                           "-backup-template must contain {name} and {ts}: %s",
+	"-backup-template只能是文件名，不能包含路径分隔符: %s":                                          "-backup-template must be a file name without path separators: %s",
+	"已指定-no-backup，不创建备份":                                                           "-no-backup given, not creating backups",
+	"\n已指定-no-backup，未创建备份":                                                         "\n-no-backup given, no backups were created",
+	"备份目录，相对路径相对于当前目录":                                                              "backup directory; relative paths are relative to the current directory",
+	"备份文件的命名模板: {name}为原文件名，{ts}为时间戳，{host}为主机名；须包含{name}与{ts}":                     "backup file naming template: {name} is the original file name, {ts} the timestamp, {host} the host name; must contain {name} and {ts}",
+	"合并前不创建任何备份，写入结果核对或-check-rules校验失败时无法恢复":                                       "create no backups before merging; the file cannot be restored if write verification or -check-rules fails",
+	"参数错误: -no-backup不能与-manifest同时使用(清单需要合并前的新文件备份)":                               "invalid arguments: -no-backup cannot be used with -manifest (the manifest needs the pre-merge backup of the new file)",
+	"保留参数的旧值与新值不同时的处理策略: old|new|newer-file(取修改时间较新的文件中的值)|prompt(逐个询问)|fail(不写入任何修改) (默认写入旧值且不视为冲突；三方合并中只处理旧值与新值都相对基线修改的参数，默认为old)": "how to handle preserved keys whose old and new values differ: old|new|newer-file (take the value from the file modified more recently)|prompt (ask for each key)|fail (write nothing) (default: write the old value without treating it as a conflict; in a three-way merge only keys changed from the base on both sides are affected, default old)",
+	"无效的冲突处理策略: %s (可选old、new、newer-file、prompt、fail)": "invalid conflict policy: %s (use old, new, newer-file, prompt or fail)",
+	"-on-conflict newer-file需要比较文件的修改时间: %w":           "-on-conflict newer-file needs the modification times of both files: %w",
+	"新文件 %s 较新，冲突时取新值":                                 "new file %s is newer, conflicts take the new value",
+	"旧文件 %s 较新，冲突时取旧值":                                 "old file %s is newer, conflicts take the old value",
+	"  冲突: %s\n":    "  conflict: %s\n",
+	"\n旧值与新值冲突的参数:": "\nKeys whose old and new values conflict:",
+	"共 %d 处冲突\n":    "%d conflicts in total\n",
+	"参数错误: -on-conflict prompt不能与-parallel或-tui同时使用": "invalid arguments: -on-conflict prompt cannot be used with -parallel or -tui",
+	"参数错误: 从标准输入读取配置时不能使用-on-conflict prompt":        "invalid arguments: -on-conflict prompt cannot be used when reading configuration from standard input",
+	"按任务清单中的cron表达式定时执行合并任务":                         "Run merge jobs from a manifest on cron schedules",
+	"定时任务": "schedule",
+	"无效的cron表达式 %q: 应为\"分 时 日 月 星期\"五个字段或@daily等简写": "invalid cron expression %q: expected five fields \"minute hour day month weekday\" or a shorthand such as @daily",
+	"无效的cron表达式 %q: %w": "invalid cron expression %q: %w",
+	"%s 不是%d到%d之间的值":    "%s is not a value between %d and %d",
+	"无效的步长: %s":         "invalid step: %s",
+	"无效的范围: %s":         "invalid range: %s",
+	"用法: %s daemon [选项] 任务清单\n\n按任务清单中各任务的schedule(cron表达式)定时执行合并，直到收到中断信号\n\n选项:\n": "Usage: %s daemon [options] manifest\n\nRun the merge jobs of a manifest on their schedule (cron expression) until interrupted\n\nOptions:\n",
+	"任务清单 %s 中的任务 %s 缺少schedule": "job %[2]s in manifest %[1]s has no schedule",
+	"任务 %s: %w": "job %s: %w",
+	"任务 %s 的schedule %q 永远不会触发":   "schedule %[2]q of job %[1]s never fires",
+	"从 %s 加载%d个定时任务，按 Ctrl+C 停止":  "Loaded %[2]d scheduled jobs from %[1]s, press Ctrl+C to stop",
+	"任务 %s (%s) 下次运行: %s":         "Job %s (%s) next run: %s",
+	"没有需要再次运行的任务":                 "No job is scheduled to run again",
+	"停止定时任务":                      "Stopping scheduled jobs",
+	"开始执行任务: %s":                  "Running job: %s",
+	"任务 %s 失败: %v":                "Job %s failed: %v",
+	"任务 %s 完成: 保留%d个参数, %d个值发生变化": "Job %s finished: %d keys preserved, %d values changed",
+	"任务 %s 下次运行: %s":              "Job %s next run: %s",
+	"在 %s/status 提供任务状态":          "Serving job status at %s/status",
+	"启动任务状态服务失败: %v":              "Failed to start the job status server: %v",
+	"在指定地址上提供任务状态(/status)与监控指标(/metrics)，为空时不提供": "serve job status (/status) and metrics (/metrics) on this address; disabled when empty",
+	"下载任务的source时使用的Basic认证，格式为 用户名:密码":           "Basic authentication for downloading job sources, as user:password",
+	"下载任务的source时使用的Bearer令牌":                     "Bearer token for downloading job sources",
+	"下载任务的source的超时时间":                            "timeout for downloading job sources",
+	"任务清单 %s 的第%d个任务的source不是HTTP/HTTPS地址: %s":    "job %[2]d in manifest %[1]s: source is not an HTTP/HTTPS URL: %[3]s",
+	"(任务 %s: %s)":     "(job %s: %s)",
+	"(任务 %s: %s, %s)": "(job %s: %s, %s)",
+	"不输出汇总等信息，只输出警告与错误；与-output-format json同时使用时标准输出中只有JSON汇总":                  "suppress the summary and other informational output, printing only warnings and errors; with -output-format json, stdout contains only the JSON summary",
+	"运行结束时汇总的格式: text|json，json时在标准输出写入一个包含状态、退出码、各动作统计与备份路径的JSON对象，其余信息写入标准错误": "format of the final summary: text|json; json writes one JSON object with the status, exit code, per-action counts and backup paths to stdout and sends other output to stderr",
+	"无效的输出格式: %s (可选text、json)": "invalid output format: %s (valid: text, json)",
+	"输出JSON汇总失败: %v":            "failed to write the JSON summary: %v",
+	"参数错误: -quiet与-output-format json不能与-interactive、-tui、-on-conflict prompt或-obsolete ask同时使用": "invalid arguments: -quiet and -output-format json cannot be combined with -interactive, -tui, -on-conflict prompt or -obsolete ask",
+	"参数错误: 合并结果写入标准输出时不能使用-output-format json":                                                   "invalid arguments: -output-format json cannot be used when the merge result is written to stdout",
+	"从配置文件 %s 加载%d条路径移动规则":                                                                       "loaded %[2]d path move rules from config file %[1]s",
+	"结构化规则: %d条, 排除规则: %d条, 环境规则: %d条, 键重命名: %d条, 路径移动: %d条\n":                                   "Structured rules: %d, exclude rules: %d, env rules: %d, key renames: %d, path moves: %d\n",
+	"解密age加密的备份使用的私钥文件，默认使用config-matcher.json中backupEncryption的identity":                        "age identity file for decrypting encrypted backups, defaults to backupEncryption.identity in config-matcher.json",
+	"加密备份失败: %w":      "failed to encrypt backup: %w",
+	"成功创建加密的备份文件: %s": "created encrypted backup file: %s",
+	"解密备份 %s 需要age私钥，请通过-identity或config-matcher.json中backupEncryption的identity指定": "decrypting backup %s requires an age identity; specify it with -identity or backupEncryption.identity in config-matcher.json",
+	"解密备份 %s 失败: %w": "failed to decrypt backup %s: %w",
+	"已解密备份 %s":       "decrypted backup %s",
+	"批量模式下合并前将新发布目录整体打包为快照，可用snapshot restore一次恢复所有文件": "in batch mode, archive the whole new release directory into a snapshot before merging; snapshot restore reverts all files at once",
+	"查看或恢复批量模式创建的目录快照: snapshot list|restore 快照ID":     "list or restore directory snapshots created in batch mode: snapshot list|restore ID",
+	"恢复快照":                           "Restore snapshot",
+	"写入快照清单失败: %w":                   "failed to write snapshot manifest: %w",
+	"已创建快照 %s: %s (%d个文件)":           "created snapshot %s: %s (%d files)",
+	"快照跳过非普通文件: %s":                  "snapshot skips non-regular file: %s",
+	"打包目录 %s 失败: %w":                 "failed to archive directory %s: %w",
+	"无效的快照ID: %s (格式20060102150405)": "invalid snapshot ID: %s (format 20060102150405)",
+	"快照目录 %s 中未找到快照 %s":              "snapshot %[2]s not found in snapshot directory %[1]s",
+	"读取快照清单失败: %w":                   "failed to read snapshot manifest: %w",
+	"解析快照清单失败: %w":                   "failed to parse snapshot manifest: %w",
+	"读取快照目录失败: %w":                   "failed to read snapshot directory: %w",
+	"读取快照失败: %w":                     "failed to read snapshot: %w",
+	"快照中的路径无效: %s":                   "invalid path in snapshot: %s",
+	"恢复 %s 失败: %w":                   "failed to restore %s: %w",
+	"用法: %s snapshot list\n       %s snapshot restore [选项] 快照ID\n\n列出批量模式(-snapshot)创建的目录快照，或将目录整体恢复到某个快照\n\n选项:\n": "Usage: %s snapshot list\n       %s snapshot restore [options] ID\n\nList directory snapshots created in batch mode (-snapshot), or restore a whole directory to a snapshot\n\nOptions:\n",
+	"快照目录 %s 中没有快照\n":                "No snapshots in snapshot directory %s\n",
+	"%s  %s  %d个文件  %s\n":            "%s  %s  %d files  %s\n",
+	"共 %d 个快照\n":                     "%d snapshots in total\n",
+	"快照对应的目录 %s 不存在":                 "directory %s of the snapshot does not exist",
+	"将使用快照 %s (%s, %d个文件) 恢复目录 %s\n": "Will restore directory %[4]s from snapshot %[1]s (%[2]s, %[3]d files)\n",
+	"确认恢复?":                          "Confirm restore?",
+	"已取消恢复":                          "Restore cancelled",
+	"为目录当前状态创建快照失败: %w":              "failed to snapshot the current state of the directory: %w",
+	"%w (已恢复%d个文件，可用 snapshot restore %s 回到本次恢复前的状态)": "%w (%d files restored; run snapshot restore %s to return to the state before this restore)",
+	"恢复完成!": "Restore complete!",
+	"共恢复 %d 个文件，恢复前的目录已保存为快照: %s\n":     "Restored %d files; the directory before the restore was saved as snapshot %s\n",
+	"参数错误: -snapshot只能在批量模式(-batch)下使用": "invalid arguments: -snapshot can only be used in batch mode (-batch)",
+	"创建快照失败: %w": "failed to create snapshot: %w",
+	"合并前的目录已保存为快照 %s，可用 snapshot restore %s 恢复\n": "The directory before merging was saved as snapshot %s; run snapshot restore %s to revert\n",
+	"  保留规则要求逐项确认(policy: prompt)":                "  the keep rule requires confirmation (policy: prompt)",
+	"%s不保留  %s (%s，rules第%d条要求取新文件中的值)\n":         "%sdrop    %s (%s, rules #%d requires the new file's value)\n",
+	"，逐项确认":     ", confirm each",
+	"，列表合并: %s": ", list merge: %s",
+	"配置文件格式: properties|yaml|toml|ini|json|hocon|env|xml (默认按扩展名自动识别，.env与.env.*为env，.ini与.cnf为ini，.conf与.hocon为hocon)": "config file format: properties|yaml|toml|ini|json|hocon|env|xml (detected from the file name by default; .env and .env.* are env, .ini and .cnf are ini, .conf and .hocon are hocon)",
+	"整次运行的时限，如5m；到期后中止下载、钩子、外部命令与文件合并，删除临时文件并以非零状态退出，不写入未完成的结果；0为不限制":                                                   "time limit for the whole run, e.g. 5m; when it expires, downloads, hooks, external commands and file merges are aborted, temporary files are removed and the tool exits with a non-zero status without writing unfinished results; 0 means no limit",
+	"已中止: %w": "aborted: %w",
+	"%w (超过-timeout指定的时限%v，已中止并清理临时文件)":                                                                   "%w (exceeded the -timeout limit of %v; aborted and removed temporary files)",
+	"旧文件的%d个键中有%d个(%d%%)在新文件中仍然存在":                                                                        "%[2]d of the old file's %[1]d keys (%[3]d%%) still exist in the new file",
+	"旧文件 %s 的%d个键中只有%d个(%d%%)在新文件 %s 中存在，低于-min-overlap指定的%d%%，因指定了-force仍然合并":                            "only %[3]d of the %[2]d keys in old file %[1]s (%[4]d%%) exist in new file %[5]s, below the %[6]d%% required by -min-overlap; merging anyway because -force is set",
+	"旧文件 %s 的%d个键中只有%d个(%d%%)在新文件 %s 中存在，低于-min-overlap指定的%d%%，可能传错了文件对；确认无误时使用-force继续合并":                "only %[3]d of the %[2]d keys in old file %[1]s (%[4]d%%) exist in new file %[5]s, below the %[6]d%% required by -min-overlap; the wrong file pair was probably passed, use -force to merge anyway",
+	"参数错误: -min-overlap必须在0到100之间":                                                                        "invalid arguments: -min-overlap must be between 0 and 100",
+	"config-matcher.json中配置了backupEncryption时不能使用-backup-mode %s: Git备份仓库中的文件无法加密保存，请使用-backup-mode copy": "-backup-mode %s cannot be used when backupEncryption is configured in config-matcher.json: files in the Git backup repository cannot be stored encrypted, use -backup-mode copy",
+	"将新文件原始内容与合并结果的unified diff写入指定文件而不是标准输出(不带颜色)，批量模式下依次写入各文件的差异":                                       "write the unified diff between the new file and the merge result to the given file instead of stdout (uncolored); in batch mode each file's diff is appended in turn",
+	"运行结束时将本次运行的合并结果、保留参数、冲突、校验失败与耗时以Prometheus文本格式写入指定文件，可供node_exporter的textfile收集器读取":                  "at the end of the run, write this run's merge result, preserved keys, conflicts, validation failures and duration to the given file in Prometheus text format (readable by the node_exporter textfile collector)",
+	"写入监控指标文件失败: %v":              "failed to write metrics file: %v",
+	"写入差异文件失败: %v":                "failed to write diff file: %v",
+	"读取模板文件失败: %w":                "failed to read template file: %w",
+	"按新模板重建: %s\n":                "Rebuilt from the new template: %s\n",
+	"删除参数[行%d]: %s\n":             "Removed parameter [line %d]: %s\n",
+	"共删除 %d 个重复或多余的参数, 修复后共%d行\n": "%d duplicate or extra parameters removed, %d lines after repair\n",
+	"参数错误: -interval必须大于0":        "invalid arguments: -interval must be greater than 0",
+	"参数错误: -debounce不能为负数":        "invalid arguments: -debounce must not be negative",
+	"模板的大小和修改时间保持不变至少该时长后才合并，避免合并写了一半的文件；上传较慢时可适当调大": "merge a template only after its size and modification time have stayed unchanged for at least this long, so partially written files are not merged; increase it for slow uploads",
+	"关闭目标文件失败: %w": "failed to close destination file: %w",
+	"已用 %s 恢复 %s":  "restored %[2]s from %[1]s",
+	"快照中的 %s 与快照清单中的校验和不一致，未恢复任何文件": "%s in the snapshot does not match the checksum in the snapshot manifest; no files were restored",
+	"旧文件的键在新文件中仍然存在的最低比例(百分比)，显式指定时低于该比例判定为传错了文件对并拒绝合并，0为不检查(旧文件少于5个键时不检查；未指定时按50只输出警告)":          "minimum percentage of old file keys that must still exist in the new file; when given explicitly, a lower overlap is treated as the wrong pair of files and the merge is refused; 0 disables the check (not checked when the old file has fewer than 5 keys; when not given, an overlap below 50 only warns)",
+	"确认文件对无误: 键重合比例低于-min-overlap时仍然合并，未指定-min-overlap时不再输出重合比例过低的警告":                             "confirm the file pair is correct: merge even when the key overlap is below -min-overlap, and without -min-overlap suppress the low-overlap warning",
+	"旧文件 %s 的%d个键中只有%d个(%d%%)在新文件 %s 中存在，低于%d%%，可能传错了文件对；指定-min-overlap时将拒绝合并，确认无误时可用-force关闭该警告": "only %[3]d of the %[2]d keys (%[4]d%%) of old file %[1]s exist in new file %[5]s, below %[6]d%%; the files may not belong together. With -min-overlap the merge would be refused; use -force to silence this warning if the pair is correct",
+}
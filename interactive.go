@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// stdin 为所有交互提示共用的标准输入，避免多个缓冲读取器相互吞掉输入
+var stdin = bufio.NewReader(os.Stdin)
+
+// keyDecision 记录交互模式下对单个参数的决定
+type keyDecision struct {
+	key      string
+	action   string
+	accepted bool
+	source   string // 交互、回放、终端界面、全部接受、退出
+}
+
+// keyConfirmer 实现-interactive模式下的逐项确认，支持从应答文件回放已有决定
+type keyConfirmer struct {
+	interactive bool
+	replay      map[string]bool
+	reviewed    map[string]bool // -tui中确认的取舍，优先于应答文件
+	conflicts   bool            // 为true时(-on-conflict prompt)未开启逐项确认也询问有冲突的参数
+	prompts     bool            // 为true时未开启逐项确认也询问命中policy为prompt的规则的参数
+	acceptAll   bool
+	quit        bool
+	decisions   []keyDecision
+}
+
+// newKeyConfirmer 创建逐项确认器，responses非空时从该文件加载应答。
+// interactive为false时只回放应答文件，文件中未列出的参数按默认行为写入
+func newKeyConfirmer(interactive bool, responses string) (*keyConfirmer, error) {
+	c := &keyConfirmer{interactive: interactive, replay: make(map[string]bool), reviewed: make(map[string]bool)}
+	if responses == "" {
+		return c, nil
+	}
+
+	lines, err := propmerge.ReadFile(responses)
+	if err != nil {
+		return nil, fmt.Errorf(tr("读取应答文件失败: %w"), err)
+	}
+	_, props := propmerge.ParseProperties(lines)
+	for key, p := range props {
+		switch strings.ToLower(p.Value) {
+		case "y", "yes":
+			c.replay[key] = true
+		case "n", "no":
+			c.replay[key] = false
+		default:
+			return nil, fmt.Errorf(tr("应答文件第%d行无效: %s=%s (应为y或n)"), p.Line, key, p.Value)
+		}
+	}
+	debugf(tr("从 %s 加载%d条应答"), responses, len(c.replay))
+	return c, nil
+}
+
+// Confirm 决定是否执行某个参数的替换或插入: 优先使用终端界面与应答文件中的决定，其余逐项询问
+func (c *keyConfirmer) Confirm(r propmerge.KeyResult) bool {
+	if accepted, ok := c.reviewed[r.Key]; ok {
+		return c.record(r, accepted, "终端界面")
+	}
+	if accepted, ok := c.replay[r.Key]; ok {
+		return c.record(r, accepted, "回放")
+	}
+	if !c.interactive && (!c.conflicts || r.Conflict == "") && (!c.prompts || !r.Prompt) {
+		return c.record(r, true, "默认")
+	}
+	if c.quit {
+		return c.record(r, false, "退出")
+	}
+	if c.acceptAll {
+		return c.record(r, true, "全部接受")
+	}
+
+	actions := map[string]string{"replace": "替换", "insert": "插入", "append": "追加"}
+	newValue := r.NewValue
+	if r.Action != propmerge.ActionReplace {
+		newValue = tr("(新文件中不存在)")
+	}
+	fmt.Printf(tr("\n%s[行%d] %s\n"), tr(actions[r.Action]), r.Line, r.Key)
+	fmt.Printf(tr("  新文件: %s\n"), masker.Value(r.Key, newValue))
+	fmt.Printf(tr("  旧文件: %s\n"), masker.Value(r.Key, r.OldValue))
+	if r.Conflict != "" {
+		fmt.Printf(tr("  冲突: %s\n"), r.Conflict)
+	}
+	if r.Prompt {
+		fmt.Println(tr("  保留规则要求逐项确认(policy: prompt)"))
+	}
+	for {
+		fmt.Print(tr("写入旧值? [y/n/a/q] (是/否/全部接受/退出): "))
+		answer, err := stdin.ReadString('\n')
+		if err != nil && answer == "" {
+			// 输入结束时视为退出，剩余参数均不写入
+			c.quit = true
+			return c.record(r, false, "退出")
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return c.record(r, true, "交互")
+		case "n", "no":
+			return c.record(r, false, "交互")
+		case "a", "all":
+			c.acceptAll = true
+			return c.record(r, true, "全部接受")
+		case "q", "quit":
+			c.quit = true
+			return c.record(r, false, "退出")
+		}
+	}
+}
+
+// promptRules 判断config-matcher.json的rules(含各规则组)中是否有policy为prompt的规则
+func promptRules() bool {
+	config, _, err := propmerge.LoadConfig(configFile)
+	if err != nil {
+		return false
+	}
+	rules := config.Rules
+	for _, p := range config.Profiles {
+		rules = append(rules, p.Rules...)
+	}
+	for _, r := range rules {
+		if r.Policy == propmerge.PolicyPrompt {
+			return true
+		}
+	}
+	return false
+}
+
+// askObsolete 实现-obsolete ask: 逐个询问新文件中已不存在的保留参数的处理方式，输入结束时按insert处理
+func askObsolete(r propmerge.KeyResult) string {
+	fmt.Printf(tr("\n新文件中已不存在: %s\n"), r.Key)
+	fmt.Printf(tr("  旧文件: %s\n"), masker.Value(r.Key, r.OldValue))
+	for {
+		fmt.Print(tr("处理方式? [i/a/c/d] (插入/追加到末尾/注释后插入/不写入): "))
+		answer, err := stdin.ReadString('\n')
+		if err != nil && answer == "" {
+			return propmerge.ObsoleteInsert
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "i", "insert":
+			return propmerge.ObsoleteInsert
+		case "a", "append":
+			return propmerge.ObsoleteAppend
+		case "c", "comment":
+			return propmerge.ObsoleteComment
+		case "d", "drop":
+			return propmerge.ObsoleteDrop
+		}
+	}
+}
+
+func (c *keyConfirmer) record(r propmerge.KeyResult, accepted bool, source string) bool {
+	c.decisions = append(c.decisions, keyDecision{key: r.Key, action: r.Action, accepted: accepted, source: source})
+	return accepted
+}
+
+// printSummary 输出逐项确认的结果汇总
+func (c *keyConfirmer) printSummary() {
+	fmt.Println(tr("\n逐项确认结果:"))
+	fmt.Println("----------------------------")
+	accepted := 0
+	for _, d := range c.decisions {
+		mark := "拒绝"
+		if d.accepted {
+			mark = "接受"
+			accepted++
+		}
+		fmt.Printf("%s: %s (%s)\n", tr(mark), d.key, tr(d.source))
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共 %d 项: 接受 %d, 拒绝 %d\n"), len(c.decisions), accepted, len(c.decisions)-accepted)
+}
+
+// save 将本次所有决定以key=y/n格式写入应答文件，供-responses回放
+func (c *keyConfirmer) save(filename string) error {
+	answers := make(map[string]bool, len(c.replay)+len(c.decisions))
+	for key, accepted := range c.replay {
+		answers[key] = accepted
+	}
+	for _, d := range c.decisions {
+		if d.source != "退出" {
+			answers[d.key] = d.accepted
+		}
+	}
+
+	keys := make([]string, 0, len(answers))
+	for key := range answers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := []string{tr("# 逐项确认应答文件，可通过 -responses 回放")}
+	for _, key := range keys {
+		answer := "n"
+		if answers[key] {
+			answer = "y"
+		}
+		lines = append(lines, key+"="+answer)
+	}
+	if err := propmerge.WriteFile(filename, lines); err != nil {
+		return fmt.Errorf(tr("写入应答文件失败: %w"), err)
+	}
+	fmt.Printf(tr("应答已保存至: %s\n"), filename)
+	return nil
+}
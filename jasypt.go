@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+var (
+	// preserveEncrypted 无论是否命中保留规则都保留ENC(...)加密值(-preserve-encrypted)
+	preserveEncrypted bool
+	// jasyptPassword 为解密与重新加密ENC(...)值的密码(-jasypt-password)，为空时读取JASYPT_ENCRYPTOR_PASSWORD
+	jasyptPassword string
+	// jasyptAlgorithm 为Jasypt的PBE算法(-jasypt-algorithm)
+	jasyptAlgorithm string
+)
+
+// registerJasyptFlags 在fs上注册Jasypt密码与算法选项
+func registerJasyptFlags(fs *flag.FlagSet) {
+	fs.StringVar(&jasyptPassword, "jasypt-password", "", "解密ENC(...)值的Jasypt密码，用于按明文校验与转换加密值，转换后重新加密 (默认读取JASYPT_ENCRYPTOR_PASSWORD)")
+	fs.StringVar(&jasyptAlgorithm, "jasypt-algorithm", propmerge.JasyptHMACSHA512AES, "Jasypt的PBE算法: PBEWITHHMACSHA512ANDAES_256(jasypt-spring-boot 3.x默认)|PBEWithMD5AndDES(Jasypt 1.x默认)等")
+}
+
+// jasyptCodec 按-jasypt-password或JASYPT_ENCRYPTOR_PASSWORD创建加解密器，均未设置时返回nil，不解密加密值
+func jasyptCodec() (*propmerge.Jasypt, error) {
+	password := jasyptPassword
+	if password == "" {
+		password = os.Getenv("JASYPT_ENCRYPTOR_PASSWORD")
+	}
+	if password == "" {
+		return nil, nil
+	}
+	return propmerge.NewJasypt(password, jasyptAlgorithm)
+}
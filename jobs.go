@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// jobsFile 为描述多个合并任务的任务清单(-jobs)，YAML或JSON格式
+var jobsFile string
+
+// mergeJob 是任务清单中的一个合并任务，未填写的字段使用命令行上的对应选项
+type mergeJob struct {
+	Name     string `json:"name"`     // 汇总与报告中显示的名称，默认为新文件路径
+	Old      string `json:"old"`      // 旧文件
+	New      string `json:"new"`      // 新文件
+	Env      string `json:"env"`      // 选择config-matcher.json中envRules的环境名称，默认为-env或APP_ENV
+	Profile  string `json:"profile"`  // 选用config-matcher.json中profiles下的规则组，默认为-profile或UPDATE_CONFIG_PROFILE
+	Format   string `json:"format"`   // 文件格式，默认按扩展名识别
+	Output   string `json:"output"`   // 合并结果的输出文件；为空时备份后写回新文件
+	Source   string `json:"source"`   // 执行前从该HTTP/HTTPS地址下载新文件，如制品服务器上的模板
+	Schedule string `json:"schedule"` // daemon模式下执行任务的cron表达式，-jobs模式忽略
+}
+
+// jobsManifest 是任务清单文件的内容
+type jobsManifest struct {
+	Jobs []mergeJob `json:"jobs"`
+}
+
+// jobReport 是汇总报告中一个任务的结果
+type jobReport struct {
+	Name     string                `json:"name"`
+	OldFile  string                `json:"oldFile"`
+	NewFile  string                `json:"newFile"`
+	Output   string                `json:"output,omitempty"`
+	Env      string                `json:"env,omitempty"`
+	Profile  string                `json:"profile,omitempty"`
+	Format   string                `json:"format"`
+	Status   string                `json:"status"` // merged、preview或failed
+	Error    string                `json:"error,omitempty"`
+	Summary  reportSummary         `json:"summary"`
+	NotInNew []string              `json:"notInNew"`
+	Keys     []propmerge.KeyResult `json:"keys"`
+}
+
+// jobsReport 是-jobs与-report-json同时使用时输出的汇总报告
+type jobsReport struct {
+	Tool       string      `json:"tool"`
+	StartedAt  string      `json:"startedAt"`
+	FinishedAt string      `json:"finishedAt"`
+	DryRun     bool        `json:"dryRun"`
+	Jobs       []jobReport `json:"jobs"`
+}
+
+// jobStatus 为汇总报告中任务状态的取值，与输出语言无关
+var jobStatus = map[string]string{"已合并": "merged", "预览": "preview", "失败": "failed"}
+
+// compiledRules 缓存按环境与规则组编译好的合并器及其对应的全局设置
+type compiledRules struct {
+	merger  *propmerge.Merger
+	masker  *propmerge.Masker
+	plugins []propmerge.FormatPlugin
+}
+
+// loadJobs 读取任务清单，扩展名为.yaml或.yml时按YAML解析，其余按JSON解析。
+// 任务中的相对路径相对于清单文件所在的目录
+func loadJobs(filename string) ([]mergeJob, error) {
+	lines, err := propmerge.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf(tr("读取任务清单失败: %w"), err)
+	}
+	var jobs []mergeJob
+	if propmerge.IsYAMLFile(filename) {
+		jobs, err = parseJobsYAML(lines)
+	} else {
+		var m jobsManifest
+		dec := json.NewDecoder(strings.NewReader(strings.Join(lines, "\n")))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&m)
+		jobs = m.Jobs
+	}
+	if err != nil {
+		return nil, fmt.Errorf(tr("解析任务清单 %s 失败: %w"), filename, err)
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf(tr("任务清单 %s 中没有任务"), filename)
+	}
+
+	dir := filepath.Dir(filename)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	for i := range jobs {
+		j := &jobs[i]
+		if j.Old == "" || j.New == "" {
+			return nil, fmt.Errorf(tr("任务清单 %s 的第%d个任务缺少old或new"), filename, i+1)
+		}
+		if j.Source != "" && !isURL(j.Source) {
+			return nil, fmt.Errorf(tr("任务清单 %s 的第%d个任务的source不是HTTP/HTTPS地址: %s"), filename, i+1, j.Source)
+		}
+		j.Old, j.New, j.Output = resolve(j.Old), resolve(j.New), resolve(j.Output)
+		if j.Name == "" {
+			j.Name = j.New
+		}
+	}
+	return jobs, nil
+}
+
+// parseJobsYAML 解析YAML格式的任务清单: 顶层为jobs键下的列表(或直接为列表)，
+// 每项为"- 字段: 值"开始的映射，值可以加单引号或双引号；#开始的注释与空行忽略
+func parseJobsYAML(lines []string) ([]mergeJob, error) {
+	var jobs []mergeJob
+	var job *mergeJob
+	itemIndent := -1
+	for i, raw := range lines {
+		line := stripYAMLComment(raw)
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		text := strings.TrimSpace(line)
+		if text == "jobs:" && indent == 0 {
+			continue
+		}
+
+		if rest, ok := strings.CutPrefix(text, "-"); ok && (rest == "" || rest[0] == ' ') {
+			if itemIndent >= 0 && indent != itemIndent {
+				return nil, fmt.Errorf(tr("第%d行: 列表项的缩进不一致"), i+1)
+			}
+			itemIndent = indent
+			jobs = append(jobs, mergeJob{})
+			job = &jobs[len(jobs)-1]
+			text = strings.TrimSpace(rest)
+			if text == "" {
+				continue
+			}
+		} else if job == nil || indent <= itemIndent {
+			return nil, fmt.Errorf(tr("第%d行: 应为\"- 字段: 值\"形式的任务"), i+1)
+		}
+
+		key, value, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, fmt.Errorf(tr("第%d行: 应为\"字段: 值\""), i+1)
+		}
+		value, err := unquoteYAML(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf(tr("第%d行: %w"), i+1, err)
+		}
+		if err := job.set(strings.TrimSpace(key), value); err != nil {
+			return nil, fmt.Errorf(tr("第%d行: %w"), i+1, err)
+		}
+	}
+	return jobs, nil
+}
+
+// stripYAMLComment 去掉引号之外、行首或空白之后的#注释
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquoteYAML 去掉标量值两侧的引号: 双引号按Go字符串的转义规则处理，单引号中”表示一个单引号
+func unquoteYAML(value string) (string, error) {
+	switch {
+	case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+		return strconv.Unquote(value)
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	}
+	return value, nil
+}
+
+// set 按字段名设置任务的字段
+func (j *mergeJob) set(key, value string) error {
+	switch key {
+	case "name":
+		j.Name = value
+	case "old":
+		j.Old = value
+	case "new":
+		j.New = value
+	case "env":
+		j.Env = value
+	case "profile":
+		j.Profile = value
+	case "format":
+		j.Format = value
+	case "output":
+		j.Output = value
+	case "source":
+		j.Source = value
+	case "schedule":
+		j.Schedule = value
+	default:
+		return fmt.Errorf(tr("未知的字段: %s"), key)
+	}
+	return nil
+}
+
+// runJobs 依次执行任务清单中的全部合并任务: 同一环境与规则组的任务共用编译好的保留规则，
+// 任一任务失败时继续执行其余任务，最后输出汇总，指定-report-json时写入一份汇总报告
+func runJobs(filename string) error {
+	jobs, err := loadJobs(filename)
+	if err != nil {
+		return invalid(err)
+	}
+	debugf(tr("从 %s 加载%d个任务"), filename, len(jobs))
+
+	report := jobsReport{
+		Tool:      "update_config v" + version,
+		StartedAt: time.Now().Format(time.RFC3339),
+		DryRun:    dryRun,
+	}
+	cache := make(map[string]compiledRules)
+	results := make([]batchResult, 0, len(jobs))
+	for _, job := range jobs {
+		result, entry := runJob(job, cache)
+		results = append(results, result)
+		report.Jobs = append(report.Jobs, entry)
+	}
+	debugf(tr("共编译%d组保留规则"), len(cache))
+
+	report.FinishedAt = time.Now().Format(time.RFC3339)
+	if err := writeJobsReport(report); err != nil {
+		return err
+	}
+	return printBatchSummary(results)
+}
+
+// runJob 执行一个任务并返回其结果与汇总报告中的记录: 按任务设置环境、规则组与格式(执行后恢复命令行上的值)，
+// 指定source时先下载新文件；环境与规则组相同的任务共用cache中编译好的保留规则
+func runJob(job mergeJob, cache map[string]compiledRules) (batchResult, jobReport) {
+	baseEnv, baseProfile, baseFormat := activeEnv, ruleProfile, formatFlag
+	defer func() { activeEnv, ruleProfile, formatFlag = baseEnv, baseProfile, baseFormat }()
+	if job.Env != "" {
+		activeEnv = job.Env
+	}
+	if job.Profile != "" {
+		ruleProfile = job.Profile
+	}
+	if job.Format != "" {
+		formatFlag = job.Format
+	}
+	debugf(tr("执行任务: %s"), job.Name, slog.String("file", job.New))
+
+	var result batchResult
+	newFile := job.New
+	if err := fetchJobSource(&job); err != nil {
+		result = batchResult{rel: job.Name, status: "失败", err: err}
+	} else {
+		if job.New != newFile {
+			defer os.Remove(job.New)
+		}
+		cacheKey := activeEnv + "\x00" + ruleProfile
+		rules, ok := cache[cacheKey]
+		if !ok {
+			m, err := newMerger(job.Old, job.New)
+			if err == nil {
+				rules = compiledRules{merger: m, masker: masker, plugins: formatPlugins}
+				cache[cacheKey] = rules
+			} else {
+				result = batchResult{rel: job.Name, status: "失败", err: fmt.Errorf(tr("加载配置失败: %w"), err)}
+			}
+		}
+		if result.err == nil {
+			masker, formatPlugins = rules.masker, rules.plugins
+			if !knownFormat(formatFlag) {
+				result = batchResult{rel: job.Name, status: "失败", err: invalid(fmt.Errorf(tr("不支持的文件格式: %s"), formatFlag))}
+			}
+		}
+		if result.err == nil {
+			merger := rules.merger.WithSource(job.Old, logger.With("file", job.New))
+			result = mergeInto(job.Name, merger, fileFormat(job.Old, job.New), job.Old, job.New, job.Output, jobBackupDir(job.New))
+		}
+	}
+	entry := jobReport{
+		Name: job.Name, OldFile: job.Old, NewFile: newFile, Output: job.Output,
+		Env: activeEnv, Profile: ruleProfile, Format: fileFormat(job.Old, job.New),
+		Status: jobStatus[result.status], Keys: maskedKeys(result.keys),
+	}
+	if result.err != nil {
+		entry.Error = result.err.Error()
+	}
+	entry.Summary, entry.NotInNew = summarizeKeys(result.keys)
+	return result, entry
+}
+
+// fetchJobSource 任务指定了source时下载新文件: 预览模式下下载到临时文件(由runJob删除)并以其作为新文件，
+// 否则覆盖任务的新文件
+func fetchJobSource(job *mergeJob) error {
+	switch {
+	case job.Source == "":
+		return nil
+	case dryRun:
+		tmp, err := downloadTemp(job.Source)
+		if err != nil {
+			return err
+		}
+		job.New = tmp
+		return nil
+	}
+	return downloadNew(job.Source, job.New)
+}
+
+// knownFormat 判断文件格式为空(按扩展名识别)、内置格式或已加载的格式插件
+func knownFormat(format string) bool {
+	switch format {
+	case "", formatProperties, formatYAML, formatTOML, formatINI, formatJSON, formatHOCON, formatEnv, formatXML:
+		return true
+	}
+	_, ok := findPlugin(format)
+	return ok
+}
+
+// jobBackupDir 返回任务的备份目录: 备份目录下与新文件所在目录的绝对路径对应的子目录，
+// 各任务中同名文件的备份不会相互覆盖
+func jobBackupDir(newFile string) string {
+	abs, err := filepath.Abs(newFile)
+	if err != nil {
+		abs = newFile
+	}
+	dir := strings.TrimPrefix(filepath.Dir(abs), filepath.VolumeName(abs))
+	return filepath.Join(backupDir, dir)
+}
+
+// writeJobsReport 指定了-report-json时写入汇总报告
+func writeJobsReport(report jobsReport) error {
+	if reportFile == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf(tr("写入JSON报告失败: %w"), err)
+	}
+	file, err := createOutputFile(reportFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf(tr("写入JSON报告失败: %w"), err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf(tr("写入JSON报告失败: %w"), err)
+	}
+	debugf(tr("JSON报告已写入: %s"), reportFile, slog.String("file", reportFile))
+	return nil
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// journalEntry 是操作日志中的一条记录: 一次运行中每个被写入的文件一条，撤销操作另记一条
+type journalEntry struct {
+	ID        string   `json:"id"`
+	Time      string   `json:"time"`
+	Operation string   `json:"operation"` // merge、repair、rollback、undo或job
+	Operator  string   `json:"operator"`
+	Args      []string `json:"args"`              // 命令行参数，令牌与密码已隐藏
+	OldFile   string   `json:"oldFile,omitempty"` // 旧文件(合并时)
+	File      string   `json:"file"`              // 被写入的文件
+	Backups   []string `json:"backups,omitempty"` // 本次操作创建的备份
+	Restore   string   `json:"restore,omitempty"` // 撤销时用于恢复File的备份，为空时无法撤销
+	SHA256    string   `json:"sha256,omitempty"`  // 写入后File的SHA-256校验和
+	Reverts   string   `json:"reverts,omitempty"` // 撤销操作所撤销的记录ID
+	Job       string   `json:"job,omitempty"`     // daemon模式下运行的任务名称(job记录)
+	Status    string   `json:"status,omitempty"`  // 任务运行的结果: merged、preview或failed(job记录)
+	Error     string   `json:"error,omitempty"`   // 任务失败的原因(job记录)
+}
+
+var (
+	journalMu  sync.Mutex
+	journalSeq int
+	// journalRun 为本次运行的标识，同一次运行(如批量模式)中的记录共用
+	journalRun = time.Now().Format("20060102150405") + "-" + fmt.Sprint(os.Getpid())
+)
+
+// journalPath 返回操作日志的路径，批量模式等写入备份子目录的操作也记录在同一份日志中
+func journalPath() string {
+	return filepath.Join(backupDir, "journal.jsonl")
+}
+
+// journalFlags 为值需要在操作日志中隐藏的选项
+var journalFlags = map[string]bool{"http-token": true, "consul-token": true, "etcd-user": true, "jasypt-password": true}
+
+// journalArgs 返回记入操作日志的命令行参数: 令牌与密码选项的值替换为****，地址中的用户信息隐藏
+func journalArgs() []string {
+	args := append([]string(nil), os.Args[1:]...)
+	for i := 0; i < len(args); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if strings.HasPrefix(args[i], "-") && journalFlags[name] {
+			if hasValue {
+				args[i] = args[i][:strings.Index(args[i], "=")+1] + "****"
+			} else if i+1 < len(args) {
+				i++
+				args[i] = "****"
+			}
+			continue
+		}
+		if u, err := url.Parse(args[i]); err == nil && u.User != nil {
+			args[i] = u.Redacted()
+		}
+	}
+	return args
+}
+
+// recordJournal 向操作日志追加一条记录，file为被写入的文件，restore为撤销时用于恢复的备份。
+// 操作日志只用于撤销，写入失败时给出警告，不影响已完成的操作
+func recordJournal(operation, oldFile, file, restore string, backups ...string) {
+	entry := journalEntry{
+		Time:      time.Now().Format(time.RFC3339),
+		Operation: operation,
+		Operator:  operator(),
+		Args:      journalArgs(),
+		OldFile:   absPath(oldFile),
+		File:      absPath(file),
+		Restore:   absPath(restore),
+	}
+	for _, b := range backups {
+		if b != "" {
+			entry.Backups = append(entry.Backups, absPath(b))
+		}
+	}
+	if sum, err := checksumFile(file); err == nil {
+		entry.SHA256 = sum.SHA256
+	}
+	if err := appendJournal(&entry); err != nil {
+		warnf(tr("写入操作日志失败: %v"), err, slog.String("file", file))
+	}
+}
+
+// appendJournal 为记录分配ID并追加到操作日志
+func appendJournal(entry *journalEntry) error {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	journalSeq++
+	entry.ID = fmt.Sprintf("%s-%d", journalRun, journalSeq)
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return fmt.Errorf(tr("创建备份目录失败: %w"), err)
+	}
+	file, err := os.OpenFile(journalPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// absPath 返回文件的绝对路径，使不同工作目录下的运行记录可以相互对应；空路径原样返回
+func absPath(filename string) string {
+	if filename == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(filename); err == nil {
+		return abs
+	}
+	return filename
+}
+
+// readJournal 读取操作日志中的全部记录，日志不存在时返回空；返回的第二个值为已被撤销的记录ID
+func readJournal() ([]journalEntry, map[string]bool, error) {
+	file, err := os.Open(journalPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf(tr("读取操作日志失败: %w"), err)
+	}
+	defer file.Close()
+
+	var entries []journalEntry
+	reverted := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, bufferSize), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, nil, fmt.Errorf(tr("读取操作日志失败: 第%d行: %w"), n, err)
+		}
+		entries = append(entries, e)
+		if e.Reverts != "" {
+			reverted[e.Reverts] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf(tr("读取操作日志失败: %w"), err)
+	}
+	return entries, reverted, nil
+}
+
+// runUndo 实现undo子命令: 按操作日志撤销对文件的最近一次未撤销的操作，用该操作记录的备份恢复文件。
+// 恢复前先备份当前状态，并在日志中追加一条撤销记录，将原记录标记为已撤销
+func runUndo(args []string) error {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	registerLangFlag(fs)
+	registerLogFlags(fs)
+	registerBackupFlags(fs)
+	list := fs.Bool("list", false, "仅列出操作日志中该文件的记录")
+	yes := fs.Bool("y", false, "跳过确认提示")
+	force := fs.Bool("force", false, "文件在该操作之后又被修改过时仍然撤销")
+	fs.StringVar(&backupIdentity, "identity", "", "解密age加密的备份使用的私钥文件，默认使用config-matcher.json中backupEncryption的identity")
+	fs.BoolVar(&verbose, "v", false, "启用详细输出模式")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), tr("用法: %s undo [选项] 配置文件路径\n\n按操作日志 %s 撤销对文件的最近一次操作\n\n选项:\n"), os.Args[0], journalPath())
+		printDefaults(fs)
+	}
+	parseFlags(fs, args)
+	if err := checkBackupFlags(); err != nil {
+		fatalf(tr("参数错误: %v"), err)
+	}
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	filename := fs.Arg(0)
+	target := absPath(filename)
+
+	entries, reverted, err := readJournal()
+	if err != nil {
+		return err
+	}
+	var history []journalEntry
+	for _, e := range entries {
+		if e.File == target {
+			history = append(history, e)
+		}
+	}
+	if len(history) == 0 {
+		return fmt.Errorf(tr("操作日志 %s 中没有 %s 的记录"), journalPath(), filename)
+	}
+	if *list {
+		printJournal(filename, history, reverted)
+		return nil
+	}
+
+	var last *journalEntry
+	for i := len(history) - 1; i >= 0; i-- {
+		// job记录只记载daemon模式下任务运行的结果，写入文件的合并另有merge记录
+		if e := &history[i]; e.Operation != "undo" && e.Operation != "job" && !reverted[e.ID] {
+			last = e
+			break
+		}
+	}
+	if last == nil {
+		return fmt.Errorf(tr("%s 的所有操作都已撤销"), filename)
+	}
+	if last.Restore == "" {
+		return fmt.Errorf(tr("操作 %s (%s) 没有可用于恢复的备份，无法撤销"), last.ID, last.Operation)
+	}
+	if _, err := os.Stat(last.Restore); err != nil {
+		return fmt.Errorf(tr("操作 %s 的备份已不存在，无法撤销: %w"), last.ID, err)
+	}
+	if sum, err := checksumFile(filename); err == nil && last.SHA256 != "" && sum.SHA256 != last.SHA256 {
+		if !*force {
+			return invalid(fmt.Errorf(tr("%s 在操作 %s 之后又被修改过，撤销会丢失这些修改；确认后使用-force撤销"), filename, last.ID))
+		}
+		warnf(tr("%s 在操作 %s 之后又被修改过，仍然撤销"), filename, last.ID, slog.String("file", filename))
+	}
+
+	fmt.Printf(tr("将撤销 %s 的%s操作 (%s, %s)，使用备份 %s 覆盖 %s\n"), last.Time, last.Operation, last.ID, strings.Join(last.Args, " "), last.Restore, filename)
+	if !*yes && !confirm("确认撤销?") {
+		fmt.Println(tr("已取消撤销"))
+		return nil
+	}
+
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return fmt.Errorf(tr("创建备份目录失败: %w"), err)
+	}
+	// 连续撤销多次时避免同一秒内的备份相互覆盖
+	now := time.Now()
+	current := filepath.Join(backupDir, backupName(filepath.Base(filename), "undo", now.Format("20060102150405")))
+	for backupExists(current) {
+		now = now.Add(time.Second)
+		current = filepath.Join(backupDir, backupName(filepath.Base(filename), "undo", now.Format("20060102150405")))
+	}
+	current, err = writeBackup(filename, current)
+	if err != nil {
+		return fmt.Errorf(tr("备份当前文件失败: %w"), err)
+	}
+	if err := restoreBackup(last.Restore, filename); err != nil {
+		return fmt.Errorf(tr("恢复备份失败: %w"), err)
+	}
+
+	entry := journalEntry{
+		Time:      time.Now().Format(time.RFC3339),
+		Operation: "undo",
+		Operator:  operator(),
+		Args:      journalArgs(),
+		File:      target,
+		Backups:   []string{absPath(current)},
+		Restore:   absPath(current),
+		Reverts:   last.ID,
+	}
+	if sum, err := checksumFile(filename); err == nil {
+		entry.SHA256 = sum.SHA256
+	}
+	if err := appendJournal(&entry); err != nil {
+		return fmt.Errorf(tr("文件已恢复，但写入操作日志失败: %w"), err)
+	}
+
+	fmt.Println(tr("撤销完成!"))
+	fmt.Printf(tr("撤销前的文件已备份至: %s\n"), current)
+	return nil
+}
+
+// printJournal 输出操作日志中一个文件的记录，从旧到新排列
+func printJournal(filename string, history []journalEntry, reverted map[string]bool) {
+	fmt.Printf(tr("%s 的操作记录:\n"), filename)
+	fmt.Println("----------------------------")
+	for _, e := range history {
+		state := ""
+		switch {
+		case reverted[e.ID]:
+			state = tr("(已撤销)")
+		case e.Reverts != "":
+			state = fmt.Sprintf(tr("(撤销 %s)"), e.Reverts)
+		case e.Operation == "job":
+			state = fmt.Sprintf(tr("(任务 %s: %s)"), e.Job, e.Status)
+			if e.Error != "" {
+				state = fmt.Sprintf(tr("(任务 %s: %s, %s)"), e.Job, e.Status, e.Error)
+			}
+		}
+		fmt.Printf("%s  %-8s  %s  %s %s\n", e.Time, e.Operation, e.ID, strings.Join(e.Args, " "), state)
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共 %d 条记录\n"), len(history))
+}
+
+// journalMerge 记录一次合并: 只使用Git备份时备份仓库中的文件随后会被合并结果覆盖，不能用于撤销
+func journalMerge(oldFile, newFile, oldBackup, newBackup string) {
+	restore := ""
+	if copyBackups() {
+		restore = newBackup
+	}
+	recordJournal("merge", oldFile, newFile, restore, oldBackup, newBackup)
+}
+
+// journalJob 记录daemon模式下一次任务运行的结果(包括失败与未写入文件的运行)，可用undo -list查看
+func journalJob(job mergeJob, report jobReport) {
+	file := job.New
+	if job.Output != "" {
+		file = job.Output
+	}
+	entry := journalEntry{
+		Time:      time.Now().Format(time.RFC3339),
+		Operation: "job",
+		Operator:  operator(),
+		Args:      journalArgs(),
+		OldFile:   absPath(job.Old),
+		File:      absPath(file),
+		Job:       job.Name,
+		Status:    report.Status,
+		Error:     report.Error,
+	}
+	if err := appendJournal(&entry); err != nil {
+		warnf(tr("写入操作日志失败: %v"), err, slog.String("file", file))
+	}
+}
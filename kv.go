@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// kvEntry 是键值存储中的一个键值及其版本
+type kvEntry struct {
+	key   string // 存储中的完整键名
+	value string
+	index uint64 // Consul的ModifyIndex或etcd的mod_revision
+}
+
+// kvPut 是写回时需要写入的一个参数
+type kvPut struct {
+	name  string   // 点分参数名
+	value string   // 未转义的值
+	old   *kvEntry // 读取时的键值，新增的键为nil
+}
+
+// kvStore 是以前缀下的键作为配置参数的键值存储(consul://、etcd://)
+type kvStore interface {
+	String() string
+	// base 返回前缀的最后一段，用作本地文件名
+	base() string
+	// list 读取前缀下的全部键值，返回以点分参数名为键的映射
+	list() (map[string]kvEntry, error)
+	// commit 写入与删除键，读取后被其他人修改过的键使写入失败
+	commit(puts []kvPut, deletes []kvEntry) error
+}
+
+// isKV 判断文件参数是否为键值存储路径
+func isKV(s string) bool {
+	return isConsul(s) || isEtcd(s)
+}
+
+// parseKV 解析consul://或etcd://形式的路径
+func parseKV(raw string) (kvStore, error) {
+	if isEtcd(raw) {
+		return parseEtcd(raw)
+	}
+	return parseConsul(raw)
+}
+
+// fetchKV 将前缀下的键值按参数名排序写入本地properties文件，返回读取到的键值
+func fetchKV(s kvStore, local string) (map[string]kvEntry, error) {
+	debugf(tr("下载文件: %s -> %s"), s, local, slog.String("file", local))
+	entries, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lines := make([]string, len(keys))
+	for i, key := range keys {
+		lines[i] = propmerge.EscapeKey(key, false) + "=" + propmerge.EscapeValue(entries[key].value, false)
+	}
+	if err := propmerge.WriteFile(local, lines); err != nil {
+		return nil, err
+	}
+	debugf(tr("从 %s 读取%d个键"), s, len(entries))
+	return entries, nil
+}
+
+// pushKV 将本地properties文件中的键值写回前缀: 值发生变化或新增的键写入，本地文件中已不存在的键删除。
+// snapshot为读取时的键值与版本，合并期间被其他人修改过的键使写入失败，避免覆盖并发写入。
+// 返回写入与删除的键数
+func pushKV(s kvStore, local string, snapshot map[string]kvEntry) (written, deleted int, err error) {
+	lines, err := propmerge.ReadFile(local)
+	if err != nil {
+		return 0, 0, err
+	}
+	keys, props := propmerge.ParseProperties(lines)
+
+	var puts []kvPut
+	for _, key := range keys {
+		value := props[key].Value
+		old, exists := snapshot[key]
+		if exists && strings.TrimRight(old.value, " \t\f") == value {
+			continue
+		}
+		put := kvPut{name: key, value: value}
+		if exists {
+			put.old = &old
+		}
+		puts = append(puts, put)
+	}
+	var removed []string
+	for key := range snapshot {
+		if _, ok := props[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	deletes := make([]kvEntry, len(removed))
+	for i, key := range removed {
+		deletes[i] = snapshot[key]
+	}
+
+	if len(puts) == 0 && len(deletes) == 0 {
+		return 0, 0, nil
+	}
+	if err := s.commit(puts, deletes); err != nil {
+		return 0, 0, err
+	}
+	return len(puts), len(deletes), nil
+}
+
+// kvSession 记录一次运行中使用的键值存储前缀: 键值先写入本地临时文件，合并完成后将结果写回target
+type kvSession struct {
+	dir      string
+	target   kvStore
+	snapshot map[string]kvEntry // 读取时target中的键值与版本
+	local    string             // 写回target的本地文件
+}
+
+// openKV 读取consul://或etcd://形式的旧文件或新文件到本地临时文件，返回合并使用的本地路径。
+// 与ssh://相同，合并结果写回新文件所在的前缀；只有旧文件为键值存储路径时，本地新文件作为模板，合并结果写回旧文件的前缀，
+// 模板中已不存在的键从存储中删除
+func openKV(oldFile, newFile string) (*kvSession, string, string, error) {
+	dir, err := os.MkdirTemp("", "update_config-kv-")
+	if err != nil {
+		return nil, "", "", fmt.Errorf(tr("创建临时目录失败: %w"), err)
+	}
+	s := &kvSession{dir: dir}
+	local := func(side string, store kvStore) (string, error) {
+		p := filepath.Join(dir, side, store.base()+".properties")
+		return p, os.MkdirAll(filepath.Dir(p), 0700)
+	}
+
+	if isKV(oldFile) {
+		store, err := parseKV(oldFile)
+		if err != nil {
+			return s, "", "", err
+		}
+		if oldFile, err = local("old", store); err != nil {
+			return s, "", "", fmt.Errorf(tr("创建临时目录失败: %w"), err)
+		}
+		if s.snapshot, err = fetchKV(store, oldFile); err != nil {
+			return s, "", "", fmt.Errorf(tr("读取旧文件失败: %w"), err)
+		}
+		s.target = store
+	}
+
+	if isKV(newFile) {
+		store, err := parseKV(newFile)
+		if err != nil {
+			return s, "", "", err
+		}
+		if newFile, err = local("new", store); err != nil {
+			return s, "", "", fmt.Errorf(tr("创建临时目录失败: %w"), err)
+		}
+		if s.snapshot, err = fetchKV(store, newFile); err != nil {
+			return s, "", "", fmt.Errorf(tr("读取新文件失败: %w"), err)
+		}
+		s.target = store
+	} else {
+		if fileFormat(newFile, newFile) != formatProperties {
+			return s, "", "", fmt.Errorf(tr("键值存储中的参数按properties格式合并，模板 %s 必须为properties文件"), newFile)
+		}
+		template := newFile
+		if newFile, err = local("new", s.target); err != nil {
+			return s, "", "", fmt.Errorf(tr("创建临时目录失败: %w"), err)
+		}
+		if err := backupFile(template, newFile); err != nil {
+			return s, "", "", fmt.Errorf(tr("复制新文件失败: %w"), err)
+		}
+	}
+	if !isKV(oldFile) && fileFormat(oldFile, oldFile) != formatProperties {
+		return s, "", "", fmt.Errorf(tr("键值存储中的参数按properties格式合并，旧文件 %s 必须为properties文件"), oldFile)
+	}
+	s.local = newFile
+	return s, oldFile, newFile, nil
+}
+
+// push 将合并结果写回键值存储
+func (s *kvSession) push() error {
+	written, deleted, err := pushKV(s.target, s.local, s.snapshot)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(tr("\n合并结果已写入 %s (写入%d个键, 删除%d个键)\n"), s.target, written, deleted)
+	return nil
+}
+
+// Close 删除本地临时目录
+func (s *kvSession) Close() {
+	os.RemoveAll(s.dir)
+}
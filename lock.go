@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// lockTimeout 为等待目标文件锁的最长时间(-lock-timeout)，为0时锁被占用立即放弃
+var lockTimeout time.Duration
+
+// lockRetry 为锁被占用时重试的间隔
+const lockRetry = 200 * time.Millisecond
+
+// errLocked 表示锁正被其他进程持有
+var errLocked = errors.New("locked")
+
+// fileLock 是目标文件旁<文件名>.lock上的咨询锁，防止多个部署任务同时改写同一个配置文件
+type fileLock struct {
+	file *os.File
+	path string
+}
+
+// lockTarget 对目标文件加排他锁: 锁被其他进程持有时每隔lockRetry重试，超过-lock-timeout仍未获得时返回错误。
+// 锁加在旁边的.lock文件而不是目标文件本身，原子写入替换目标文件后锁依然有效
+func lockTarget(target string) (*fileLock, error) {
+	path := target + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	waiting := false
+	for {
+		l, err := tryLock(path)
+		if err == nil {
+			host, _ := os.Hostname()
+			l.file.Truncate(0)
+			fmt.Fprintf(l.file, "pid=%d host=%s user=%s time=%s\n", os.Getpid(), host, operator(), time.Now().Format(time.RFC3339))
+			debugf(tr("已获得文件锁: %s"), path, slog.String("file", target))
+			return l, nil
+		}
+		if !errors.Is(err, errLocked) {
+			return nil, fmt.Errorf(tr("创建锁文件失败: %w"), err)
+		}
+		if err := canceled(); err != nil {
+			return nil, err
+		}
+		if !time.Now().Before(deadline) {
+			data, _ := os.ReadFile(path)
+			holder := strings.TrimSpace(string(data))
+			if holder == "" {
+				holder = tr("持有者未知")
+			}
+			if lockTimeout == 0 {
+				return nil, fmt.Errorf(tr("%s 正被其他进程处理(%s)，未能获得锁 %s，可用-lock-timeout指定等待时间"), target, holder, path)
+			}
+			return nil, fmt.Errorf(tr("%s 正被其他进程处理(%s)，%v内未能获得锁 %s"), target, holder, lockTimeout, path)
+		}
+		if !waiting {
+			infof(tr("%s 正被其他进程处理，等待锁 %s (最长%v)"), target, path, lockTimeout, slog.String("file", target))
+			waiting = true
+		}
+		time.Sleep(min(lockRetry, time.Until(deadline)))
+	}
+}
@@ -0,0 +1,36 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLock 打开(必要时创建)锁文件并以flock加非阻塞排他锁。进程退出时锁由内核自动释放，
+// 不会因进程异常终止而残留
+func tryLock(path string) (*fileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	return &fileLock{file: f, path: path}, nil
+}
+
+// release 释放锁，l为nil时不做任何操作。锁文件保留在原处: 删除后等待者持有的是已删除的文件，会与新创建的锁文件同时加锁成功
+func (l *fileLock) release() {
+	if l == nil {
+		return
+	}
+	// 清空持有者信息，避免其他工具持有锁时误报为本进程
+	l.file.Truncate(0)
+	l.file.Close()
+}
@@ -0,0 +1,27 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import "os"
+
+// tryLock 在不支持flock的平台上以独占创建锁文件的方式加锁，文件已存在即视为锁被占用。
+// 进程异常终止时锁文件会残留，需要手工删除
+func tryLock(path string) (*fileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, errLocked
+		}
+		return nil, err
+	}
+	return &fileLock{file: f, path: path}, nil
+}
+
+// release 释放锁并删除锁文件，l为nil时不做任何操作
+func (l *fileLock) release() {
+	if l == nil {
+		return
+	}
+	l.file.Close()
+	os.Remove(l.path)
+}
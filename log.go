@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// 日志格式
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+var (
+	logLevel  string
+	logFormat string
+	// logger 输出分级日志，解析参数后按-log-level与-log-format重新创建
+	logger = slog.New(newTextHandler(os.Stderr, slog.LevelInfo))
+)
+
+// logLevels 为-log-level支持的级别
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// registerLogFlags 注册日志级别与格式选项
+func registerLogFlags(fs *flag.FlagSet) {
+	fs.StringVar(&logLevel, "log-level", "", "日志级别: debug|info|warn|error (默认info，指定-v时为debug)")
+	fs.StringVar(&logFormat, "log-format", logFormatText, "日志格式: text|json，json时每条日志为一行JSON并附带file、key、line、action等字段")
+}
+
+// setupLogger 根据-log-level、-log-format与-v创建日志
+func setupLogger() error {
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	}
+	if logLevel != "" {
+		l, ok := logLevels[logLevel]
+		if !ok {
+			return fmt.Errorf(tr("无效的日志级别: %s (可选: debug|info|warn|error)"), logLevel)
+		}
+		level = l
+	}
+
+	switch logFormat {
+	case logFormatText:
+		logger = slog.New(newTextHandler(os.Stderr, level))
+	case logFormatJSON:
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	default:
+		return fmt.Errorf(tr("无效的日志格式: %s (可选: text|json)"), logFormat)
+	}
+	return nil
+}
+
+// logf 按级别输出日志: 参数中的slog.Attr作为结构化字段输出，其余参数按format格式化为消息
+func logf(level slog.Level, format string, v ...interface{}) {
+	if !logger.Enabled(context.Background(), level) {
+		return
+	}
+	args := make([]interface{}, 0, len(v))
+	var attrs []slog.Attr
+	for _, a := range v {
+		if attr, ok := a.(slog.Attr); ok {
+			attrs = append(attrs, attr)
+		} else {
+			args = append(args, a)
+		}
+	}
+	logger.LogAttrs(context.Background(), level, fmt.Sprintf(format, args...), attrs...)
+}
+
+func debugf(format string, v ...interface{}) { logf(slog.LevelDebug, format, v...) }
+func infof(format string, v ...interface{})  { logf(slog.LevelInfo, format, v...) }
+func warnf(format string, v ...interface{})  { logf(slog.LevelWarn, format, v...) }
+func errorf(format string, v ...interface{}) { logf(slog.LevelError, format, v...) }
+
+// fatalf 输出参数错误并以exitUsage退出
+func fatalf(format string, v ...interface{}) {
+	cleanupTemps()
+	errorf(format, v...)
+	writeRunSummary(exitUsage, fmt.Errorf(format, v...))
+	os.Exit(exitUsage)
+}
+
+// textHandler 以"时间 [级别] 消息"的格式输出便于阅读的日志，结构化字段仅在JSON格式中输出
+type textHandler struct {
+	w     io.Writer
+	level slog.Level
+	mu    *sync.Mutex
+}
+
+func newTextHandler(w io.Writer, level slog.Level) *textHandler {
+	return &textHandler{w: w, level: level, mu: new(sync.Mutex)}
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	line := fmt.Sprintf("%s [%s] %s\n", r.Time.Format("2006/01/02 15:04:05"), r.Level, r.Message)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, line)
+	return err
+}
+
+func (h *textHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h *textHandler) WithGroup(string) slog.Handler { return h }
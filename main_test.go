@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain 设置UPDATE_CONFIG_TEST_MAIN时作为命令本身运行，供runMain以子进程方式执行完整的命令行
+func TestMain(m *testing.M) {
+	if os.Getenv("UPDATE_CONFIG_TEST_MAIN") == "1" {
+		os.Args = append([]string{"update_config"}, os.Args[1:]...)
+		main()
+		os.Exit(exitChanged)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain 在dir中以args运行命令，返回标准输出、标准错误与退出码
+func runMain(t *testing.T, dir string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "UPDATE_CONFIG_TEST_MAIN=1", "NO_COLOR=1", "LC_ALL=zh_CN.UTF-8")
+	var out, errOut bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitCode()
+	case err != nil:
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), code
+}
+
+// writeFiles 在dir中写入测试文件，name为相对路径
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestAllOutputsInOneRun 同一次运行同时输出汇总、JSON报告、监控指标与diff文件时，
+// 标准输出只有汇总，各文件只有各自的内容
+func TestAllOutputsInOneRun(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		configFile:       `{"patternKeys": "^ftp\\."}`,
+		"old.properties": "ftp.host=10.0.0.1\nftp.password=secret1\n",
+		"new.properties": "ftp.host=1.2.3.4\nftp.password=template\nserver.port=8080\n",
+	})
+	stdout, stderr, code := runMain(t, dir, "-no-backup",
+		"-report-json", "report.json", "-metrics-file", "merge.prom", "-diff-file", "merge.diff",
+		"old.properties", "new.properties")
+	if code != exitChanged {
+		t.Fatalf("exit code = %d, want %d\nstdout:\n%s\nstderr:\n%s", code, exitChanged, stdout, stderr)
+	}
+
+	if !strings.Contains(stdout, "配置更新完成") || !strings.Contains(stdout, "ftp.host") {
+		t.Errorf("stdout is missing the summary:\n%s", stdout)
+	}
+	for _, other := range []string{"--- new.properties", "+++ ", "@@ ", "-ftp.host=1.2.3.4", "{", "update_config_", "secret1"} {
+		if strings.Contains(stdout, other) {
+			t.Errorf("stdout contains %q:\n%s", other, stdout)
+		}
+	}
+
+	var report struct {
+		OldFile struct{ Path string } `json:"oldFile"`
+	}
+	if err := json.Unmarshal([]byte(readFile(t, filepath.Join(dir, "report.json"))), &report); err != nil {
+		t.Errorf("report.json is not a JSON report: %v", err)
+	} else if report.OldFile.Path != "old.properties" {
+		t.Errorf("report.json oldFile.path = %q", report.OldFile.Path)
+	}
+
+	prom := readFile(t, filepath.Join(dir, "merge.prom"))
+	for _, line := range strings.Split(strings.TrimSpace(prom), "\n") {
+		if !strings.HasPrefix(line, "# ") && !strings.HasPrefix(line, "update_config_") {
+			t.Errorf("merge.prom contains a non-metric line %q", line)
+		}
+	}
+	for _, metric := range []string{`update_config_merges_total{result="success"} 1`, "update_config_keys_preserved_total 2", "update_config_merge_duration_seconds_count 1"} {
+		if !strings.Contains(prom, metric) {
+			t.Errorf("merge.prom is missing %q:\n%s", metric, prom)
+		}
+	}
+
+	diff := readFile(t, filepath.Join(dir, "merge.diff"))
+	if !strings.HasPrefix(diff, "--- new.properties\n+++ new.properties.merged\n") {
+		t.Errorf("merge.diff does not start with the diff header:\n%s", diff)
+	}
+	for _, line := range []string{"-ftp.host=1.2.3.4", "+ftp.host=10.0.0.1", " server.port=8080"} {
+		if !strings.Contains(diff, line+"\n") {
+			t.Errorf("merge.diff is missing %q:\n%s", line, diff)
+		}
+	}
+	for _, other := range []string{"配置更新完成", "\x1b[", "secret1", "{"} {
+		if strings.Contains(diff, other) {
+			t.Errorf("merge.diff contains %q:\n%s", other, diff)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// mergeDurationBuckets 为合并耗时直方图的桶上限(秒)
+var mergeDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// mergeMetrics 汇总serve与watch模式下的合并次数、保留参数、冲突、校验失败与耗时，
+// 以Prometheus文本格式在/metrics输出。为保持零第三方依赖，不使用Prometheus客户端库
+type mergeMetrics struct {
+	mu          sync.Mutex
+	merges      map[string]uint64 // 按结果(success、failure)统计的合并次数
+	preserved   uint64            // 写入旧值的保留参数数量
+	conflicts   uint64            // 重命名冲突、三方合并冲突与按error策略处理的重复键
+	validations uint64            // -check-rules校验未通过、规则或输入无效等校验失败的次数
+	buckets     []uint64          // 各桶的累计次数
+	durationSum float64
+	durationCnt uint64
+}
+
+var (
+	// metricsFile 为-metrics-file指定的文件: 运行结束时以Prometheus文本格式写入本次运行的统计，
+	// 供node_exporter的textfile收集器等读取
+	metricsFile string
+	// runStarted 为本次运行的开始时间，-metrics-file中整次运行记为一次合并
+	runStarted  = time.Now()
+	metricsDone bool
+)
+
+// metrics 为本进程的合并统计
+var metrics = &mergeMetrics{
+	merges:  map[string]uint64{"success": 0, "failure": 0},
+	buckets: make([]uint64, len(mergeDurationBuckets)),
+}
+
+// observe 记录一次合并: keys为各保留参数的处理结果，err为合并失败的原因
+func (m *mergeMetrics) observe(start time.Time, keys []propmerge.KeyResult, err error) {
+	seconds := time.Since(start).Seconds()
+	result := propmerge.Result{Keys: keys}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.merges["failure"]++
+	} else {
+		m.merges["success"]++
+	}
+	for _, k := range keys {
+		if k.Written() {
+			m.preserved++
+		}
+	}
+	m.conflicts += uint64(len(result.Collisions()) + len(result.Conflicts()))
+	if err != nil && errors.Is(err, propmerge.ErrDuplicate) {
+		m.conflicts++
+	}
+	if err != nil && exitCode(err) == exitInvalid {
+		m.validations++
+	}
+	for i, le := range mergeDurationBuckets {
+		if seconds <= le {
+			m.buckets[i]++
+		}
+	}
+	m.durationSum += seconds
+	m.durationCnt++
+}
+
+// ServeHTTP 以Prometheus文本格式输出统计
+func (m *mergeMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, m.text())
+}
+
+// text 返回Prometheus文本格式的统计
+func (m *mergeMetrics) text() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var b strings.Builder
+	fmt.Fprintln(&b, "# HELP update_config_merges_total Merges performed, by result.")
+	fmt.Fprintln(&b, "# TYPE update_config_merges_total counter")
+	for _, result := range []string{"success", "failure"} {
+		fmt.Fprintf(&b, "update_config_merges_total{result=%q} %d\n", result, m.merges[result])
+	}
+	fmt.Fprintln(&b, "# HELP update_config_keys_preserved_total Kept parameters written to merged files.")
+	fmt.Fprintln(&b, "# TYPE update_config_keys_preserved_total counter")
+	fmt.Fprintf(&b, "update_config_keys_preserved_total %d\n", m.preserved)
+	fmt.Fprintln(&b, "# HELP update_config_conflicts_total Rename collisions, three-way conflicts and duplicate key errors detected.")
+	fmt.Fprintln(&b, "# TYPE update_config_conflicts_total counter")
+	fmt.Fprintf(&b, "update_config_conflicts_total %d\n", m.conflicts)
+	fmt.Fprintln(&b, "# HELP update_config_validation_failures_total Merges rejected by check rules or invalid rules and input.")
+	fmt.Fprintln(&b, "# TYPE update_config_validation_failures_total counter")
+	fmt.Fprintf(&b, "update_config_validation_failures_total %d\n", m.validations)
+	fmt.Fprintln(&b, "# HELP update_config_merge_duration_seconds Time spent per merge.")
+	fmt.Fprintln(&b, "# TYPE update_config_merge_duration_seconds histogram")
+	for i, le := range mergeDurationBuckets {
+		fmt.Fprintf(&b, "update_config_merge_duration_seconds_bucket{le=\"%g\"} %d\n", le, m.buckets[i])
+	}
+	fmt.Fprintf(&b, "update_config_merge_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.durationCnt)
+	fmt.Fprintf(&b, "update_config_merge_duration_seconds_sum %g\n", m.durationSum)
+	fmt.Fprintf(&b, "update_config_merge_duration_seconds_count %d\n", m.durationCnt)
+	return b.String()
+}
+
+// writeMetricsFile 运行结束时将整次运行记为一次合并，以Prometheus文本格式原子地写入-metrics-file；
+// err为导致运行失败的错误，一次运行只写入一次
+func writeMetricsFile(err error) {
+	if metricsFile == "" || metricsDone {
+		return
+	}
+	metricsDone = true
+	metrics.observe(runStarted, runKeys, err)
+	if writeErr := propmerge.AtomicWrite(metricsFile, func(w io.Writer) error {
+		_, err := io.WriteString(w, metrics.text())
+		return err
+	}); writeErr != nil {
+		errorf(tr("写入监控指标文件失败: %v"), writeErr, slog.String("file", metricsFile))
+	}
+}
+
+// serveMetrics 在单独的地址上提供/metrics，供watch模式使用；启动失败时输出错误，不影响监视
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	infof(tr("在 %s/metrics 提供监控指标"), addr)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errorf(tr("启动监控指标服务失败: %v"), err)
+		}
+	}()
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// splitFile 将旧文件拆分为只包含命中规则参数的覆盖文件和包含其余内容的模板文件，
+// 紧邻参数上方的注释块随该参数进入同一侧
+func splitFile(merger *propmerge.Merger, oldFile, overlayFile, templateFile string) error {
+	lines, err := propmerge.ReadFile(oldFile)
+	if err != nil {
+		return fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+
+	overlay, template, kept := merger.Split(lines)
+	encoding := outputEncoding
+	if encoding == "" {
+		// 拆分结果沿用旧文件的编码
+		encoding = propmerge.DetectFileEncoding(oldFile)
+	}
+	if err := propmerge.WriteFileEncoding(overlayFile, overlay, encoding); err != nil {
+		return fmt.Errorf(tr("写入覆盖文件失败: %w"), err)
+	}
+	if err := propmerge.WriteFileEncoding(templateFile, template, encoding); err != nil {
+		return fmt.Errorf(tr("写入模板文件失败: %w"), err)
+	}
+
+	fmt.Println(tr("拆分完成!"))
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("覆盖文件: %s (共%d行, %d个参数)\n"), overlayFile, len(overlay), kept)
+	fmt.Printf(tr("模板文件: %s (共%d行)\n"), templateFile, len(template))
+	fmt.Println("----------------------------")
+	return nil
+}
+
+// convertFile 提取旧文件中的保留参数并以目标格式(env/json/yaml)导出，不回写到properties文件
+func convertFile(merger *propmerge.Merger, oldFile, outFile, format string) error {
+	oldLines, err := propmerge.ReadFile(oldFile)
+	if err != nil {
+		return fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+
+	lines, count, err := merger.Export(oldLines, format)
+	if err != nil {
+		return err
+	}
+
+	if err := propmerge.WriteFileEncoding(outFile, lines, outputEncoding); err != nil {
+		return fmt.Errorf(tr("写入导出文件失败: %w"), err)
+	}
+	fmt.Printf(tr("导出完成! 共%d个保留参数已以%s格式写入: %s\n"), count, format, outFile)
+	return nil
+}
+
+// repairFile 修复被旧版本错误合并的文件，写入前备份待修复文件。
+// 指定了新模板templateFile时以模板与保留参数重建文件，否则只合并重复的保留键并重新放置缺失的保留参数
+func repairFile(merger *propmerge.Merger, oldFile, filename, templateFile string) error {
+	oldLines, err := propmerge.ReadFile(oldFile)
+	if err != nil {
+		return fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+	lines, err := propmerge.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf(tr("读取待修复文件失败: %w"), err)
+	}
+
+	var result propmerge.Result
+	var removed []propmerge.RemovedLine
+	if templateFile != "" {
+		template, err := propmerge.ReadFile(templateFile)
+		if err != nil {
+			return fmt.Errorf(tr("读取模板文件失败: %w"), err)
+		}
+		result, removed = merger.RepairTemplate(oldLines, lines, template)
+	} else {
+		result, removed = merger.Repair(oldLines, lines)
+	}
+	recordResult(result)
+	if len(removed) > 0 || !slices.Equal(lines, result.Lines) {
+		mergeChanged = true
+	}
+
+	fmt.Printf(tr("修复文件: %s\n"), filename)
+	if templateFile != "" {
+		fmt.Printf(tr("按新模板重建: %s\n"), templateFile)
+	}
+	fmt.Println("----------------------------")
+	for _, r := range removed {
+		fmt.Printf(tr("删除参数[行%d]: %s\n"), r.Line, masker.Line(r.Text))
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共删除 %d 个重复或多余的参数, 修复后共%d行\n"), len(removed), len(result.Lines))
+
+	if dryRun {
+		fmt.Println(tr("预览模式，未写入任何文件"))
+		return nil
+	}
+
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return fmt.Errorf(tr("创建备份目录失败: %w"), err)
+	}
+	ts := time.Now().Format("20060102150405")
+	backup := filepath.Join(backupDir, backupName(filepath.Base(filename), "repair", ts))
+	backup, err = writeBackup(filename, backup)
+	if err != nil {
+		return fmt.Errorf(tr("备份待修复文件失败: %w"), err)
+	}
+	fmt.Printf(tr("修复前文件已备份至: %s\n"), backup)
+	lastNewBackup = backup
+	noteBackups(backup)
+
+	if err := propmerge.WriteFileEncoding(filename, result.Lines, outputEncoding); err != nil {
+		return fmt.Errorf(tr("写入修复文件失败: %w"), err)
+	}
+	recordJournal("repair", oldFile, filename, backup, backup)
+	return nil
+}
+
+// 配置文件格式
+const (
+	formatProperties = "properties"
+	formatYAML       = "yaml"
+	formatTOML       = "toml"
+	formatINI        = "ini"
+	formatJSON       = "json"
+	formatHOCON      = "hocon"
+	formatEnv        = "env"
+	formatXML        = "xml"
+)
+
+// formatPlugins 为config-matcher.json中注册的格式插件，由newMerger加载；插件优先于内置格式
+var formatPlugins []propmerge.FormatPlugin
+
+// findPlugin 返回名称为format的格式插件
+func findPlugin(format string) (propmerge.FormatPlugin, bool) {
+	for _, p := range formatPlugins {
+		if p.Name == format {
+			return p, true
+		}
+	}
+	return propmerge.FormatPlugin{}, false
+}
+
+// fileFormat 返回合并使用的文件格式: 指定了-format时使用该值，否则两个文件的扩展名同属某个格式插件时
+// 使用该插件，同为YAML、TOML、INI、JSON、HOCON或dotenv时按对应格式处理，其余按properties处理
+func fileFormat(oldFile, newFile string) string {
+	if formatFlag != "" {
+		return formatFlag
+	}
+	for _, p := range formatPlugins {
+		if p.Handles(oldFile) && p.Handles(newFile) {
+			return p.Name
+		}
+	}
+	switch {
+	case propmerge.IsYAMLFile(oldFile) && propmerge.IsYAMLFile(newFile):
+		return formatYAML
+	case propmerge.IsTOMLFile(oldFile) && propmerge.IsTOMLFile(newFile):
+		return formatTOML
+	case propmerge.IsINIFile(oldFile) && propmerge.IsINIFile(newFile):
+		return formatINI
+	case propmerge.IsJSONFile(oldFile) && propmerge.IsJSONFile(newFile):
+		return formatJSON
+	case propmerge.IsHOCONFile(oldFile) && propmerge.IsHOCONFile(newFile):
+		return formatHOCON
+	case propmerge.IsEnvFile(oldFile) && propmerge.IsEnvFile(newFile):
+		return formatEnv
+	case propmerge.IsXMLFile(oldFile) && propmerge.IsXMLFile(newFile):
+		return formatXML
+	}
+	return formatProperties
+}
+
+// pathMerge 返回YAML、TOML、INI、JSON与HOCON按点分路径合并、dotenv按键合并、XML按XPath合并及格式插件合并的函数，properties格式返回nil
+func pathMerge(merger *propmerge.Merger, format string) func(oldLines, newLines []string) (propmerge.Result, error) {
+	if p, ok := findPlugin(format); ok {
+		return func(oldLines, newLines []string) (propmerge.Result, error) {
+			return merger.MergePluginLinesContext(runCtx, p, oldLines, newLines)
+		}
+	}
+	switch format {
+	case formatYAML:
+		return merger.MergeYAMLLines
+	case formatTOML:
+		return merger.MergeTOMLLines
+	case formatINI:
+		return merger.MergeINILines
+	case formatJSON:
+		return merger.MergeJSONLines
+	case formatHOCON:
+		return merger.MergeHOCONLines
+	case formatEnv:
+		return merger.MergeEnvLines
+	case formatXML:
+		return merger.MergeXMLLines
+	}
+	return nil
+}
+
+// runPathMerge 按点分路径将旧YAML/TOML/JSON中的保留参数合并到新文件
+func runPathMerge(merger *propmerge.Merger, merge func(oldLines, newLines []string) (propmerge.Result, error), oldFile, newFile string) error {
+	var report *mergeReport
+	if wantReport() {
+		var err error
+		if report, err = startReport(merger, oldFile, newFile); err != nil {
+			return err
+		}
+	}
+
+	oldLines, err := propmerge.ReadFile(oldFile)
+	if err != nil {
+		return fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+	original, err := propmerge.ReadFile(newFile)
+	if err != nil {
+		return fmt.Errorf(tr("读取新文件失败: %w"), err)
+	}
+
+	result, err := merge(oldLines, original)
+	if err != nil {
+		if errors.Is(err, propmerge.ErrCollision) {
+			printCollisions(result.Keys)
+		}
+		return err
+	}
+	recordResult(result)
+
+	if dryRun {
+		printPlan(result.Keys)
+		printCollisions(result.Keys)
+		printDiverged(result.Keys)
+		if showDiff {
+			printDiff(newFile, original, result.Lines)
+		}
+		if err := writeLineOrigins(newFile, original, result.Lines, result.Keys); err != nil {
+			return err
+		}
+		if report != nil {
+			return report.finish(result, "")
+		}
+		return nil
+	}
+
+	if err := canceled(); err != nil {
+		return err
+	}
+	oldBackup, newBackup, err := createBackups(backupDir, oldFile, newFile)
+	if err != nil {
+		return err
+	}
+	if err := propmerge.WriteFileEncoding(newFile, result.Lines, outputEncoding); err != nil {
+		return fmt.Errorf(tr("写入更新文件失败: %w"), err)
+	}
+	if err := verifyWrite(oldFile, newFile, oldBackup, newBackup, result); err != nil {
+		return err
+	}
+
+	fmt.Println(tr("配置更新完成!已完全使用新文件内容,并保留以下参数在原位置:"))
+	if changedOnly {
+		printChangedParams(result.Keys)
+	} else {
+		printKeptResults(result.Keys)
+	}
+	printCollisions(result.Keys)
+	printDiverged(result.Keys)
+	printBackupPaths(oldBackup, newBackup)
+	if showDiff {
+		printDiff(newFile, original, result.Lines)
+	}
+	if err := writeLineOrigins(newFile, original, result.Lines, result.Keys); err != nil {
+		return err
+	}
+	if report != nil {
+		return report.finish(result, newFile, oldBackup, newBackup)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// notifyTimeout 为发送一条合并通知的超时时间
+const notifyTimeout = 10 * time.Second
+
+// mergeNotice 是合并完成后发送给webhook的摘要
+type mergeNotice struct {
+	Host      string `json:"host"`
+	File      string `json:"file"`
+	Status    string `json:"status"` // merged或failed
+	Error     string `json:"error,omitempty"`
+	Changed   int    `json:"changed"`   // 值发生变化的参数数量
+	Conflicts int    `json:"conflicts"` // 重命名冲突与三方合并冲突的数量
+	Report    string `json:"report,omitempty"`
+	Time      string `json:"time"`
+}
+
+// keyCounts 统计值发生变化的参数与冲突的数量
+func keyCounts(keys []propmerge.KeyResult) (changed, conflicts int) {
+	for _, k := range keys {
+		if k.Changed() {
+			changed++
+		}
+	}
+	result := propmerge.Result{Keys: keys}
+	return changed, len(result.Collisions()) + len(result.Conflicts())
+}
+
+// newNotice 按合并结果创建通知摘要，报告链接为-report-html或-report-json指定的文件
+func newNotice(file string, changed, conflicts int, err error) mergeNotice {
+	host, _ := os.Hostname()
+	n := mergeNotice{
+		Host:      host,
+		File:      file,
+		Status:    jobStatus["已合并"],
+		Changed:   changed,
+		Conflicts: conflicts,
+		Report:    reportHTMLFile,
+		Time:      time.Now().Format(time.RFC3339),
+	}
+	if n.Report == "" {
+		n.Report = reportFile
+	}
+	if err != nil {
+		n.Status, n.Error = jobStatus["失败"], err.Error()
+	}
+	return n
+}
+
+// notifyMerge 向config-matcher.json的notifications中的每个地址发送合并摘要。
+// 通知失败只输出警告，不影响合并结果
+func notifyMerge(n mergeNotice) {
+	config, _, err := propmerge.LoadConfig(configFile)
+	if err != nil {
+		warnf(tr("发送合并通知失败: %v"), err)
+		return
+	}
+	for _, target := range config.Notifications {
+		if err := sendNotice(target, n); err != nil {
+			// 钉钉与企业微信的地址中包含令牌，不写入日志
+			warnf(tr("发送%s合并通知失败: %v"), noticeType(target), err)
+			continue
+		}
+		debugf(tr("已发送%s合并通知"), noticeType(target))
+	}
+}
+
+// noticeType 返回通知的类型，未指定时为webhook
+func noticeType(target propmerge.Notification) string {
+	if target.Type == "" {
+		return propmerge.NotifyWebhook
+	}
+	return target.Type
+}
+
+// sendNotice 按通知类型组织请求体并POST到目标地址
+func sendNotice(target propmerge.Notification, n mergeNotice) error {
+	if target.ReportURL != "" {
+		n.Report = target.ReportURL
+	}
+	var body interface{} = n
+	switch noticeType(target) {
+	case propmerge.NotifyDingTalk:
+		body = map[string]interface{}{
+			"msgtype":  "markdown",
+			"markdown": map[string]string{"title": noticeTitle(n), "text": noticeMarkdown(n)},
+		}
+	case propmerge.NotifyWeCom:
+		body = map[string]interface{}{
+			"msgtype":  "markdown",
+			"markdown": map[string]string{"content": noticeMarkdown(n)},
+		}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	for name, value := range target.Headers {
+		req.Header.Set(name, value)
+	}
+	client := &http.Client{Timeout: notifyTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		// 错误信息中的地址可能包含令牌，只保留底层原因
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			return ue.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	payload, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf(tr("HTTP状态 %s: %s"), resp.Status, strings.TrimSpace(string(payload)))
+	}
+
+	// 钉钉与企业微信在HTTP 200的响应中以errcode表示失败
+	if noticeType(target) != propmerge.NotifyWebhook {
+		var r struct {
+			ErrCode int    `json:"errcode"`
+			ErrMsg  string `json:"errmsg"`
+		}
+		if err := json.Unmarshal(payload, &r); err == nil && r.ErrCode != 0 {
+			return fmt.Errorf(tr("错误码 %d: %s"), r.ErrCode, r.ErrMsg)
+		}
+	}
+	return nil
+}
+
+// noticeTitle 返回通知的标题
+func noticeTitle(n mergeNotice) string {
+	if n.Error != "" {
+		return fmt.Sprintf(tr("配置合并失败: %s"), n.File)
+	}
+	return fmt.Sprintf(tr("配置合并完成: %s"), n.File)
+}
+
+// noticeMarkdown 返回钉钉与企业微信机器人使用的markdown消息
+func noticeMarkdown(n mergeNotice) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n\n", noticeTitle(n))
+	fmt.Fprintf(&b, tr("- 主机: %s\n"), n.Host)
+	fmt.Fprintf(&b, tr("- 文件: %s\n"), n.File)
+	fmt.Fprintf(&b, tr("- 修改的参数: %d\n"), n.Changed)
+	fmt.Fprintf(&b, tr("- 冲突: %d\n"), n.Conflicts)
+	if n.Error != "" {
+		fmt.Fprintf(&b, tr("- 错误: %s\n"), n.Error)
+	}
+	if n.Report != "" {
+		fmt.Fprintf(&b, tr("- 报告: %s\n"), n.Report)
+	}
+	fmt.Fprintf(&b, tr("- 时间: %s\n"), n.Time)
+	return b.String()
+}
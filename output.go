@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// outputPaths 记录本次运行中已被占用的文件路径及其用途，防止各输出功能相互覆盖或覆盖输入文件
+var outputPaths = make(map[string]string)
+
+// claimPath 为输入文件或输出功能登记路径，路径已被占用时返回错误。
+// 标准输出保留给主汇总信息，其他输出必须写入各自指定的文件
+func claimPath(owner, path string) error {
+	if path == "" || path == "-" {
+		return fmt.Errorf(tr("%s 必须指定文件路径，标准输出仅用于汇总信息"), tr(owner))
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf(tr("解析路径 %s 失败: %w"), path, err)
+	}
+	if other, ok := outputPaths[abs]; ok {
+		return fmt.Errorf(tr("%s 的路径 %s 已被 %s 使用"), tr(owner), path, tr(other))
+	}
+	outputPaths[abs] = owner
+	return nil
+}
+
+// createOutputFile 创建已登记的输出文件，必要时创建上级目录
+func createOutputFile(path string) (*os.File, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf(tr("创建输出目录失败: %w"), err)
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf(tr("创建输出文件失败: %w"), err)
+	}
+	return file, nil
+}
+
+// traceWriter 将处理过程中的每个决策以JSONL格式写入文件，独立于日志输出级别
+type traceWriter struct {
+	mu   sync.Mutex // 批量模式并行处理时多个合并器同时记录
+	file *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+	err  error
+}
+
+var tracer *traceWriter
+
+// openTrace 创建跟踪文件
+func openTrace(path string) (*traceWriter, error) {
+	file, err := createOutputFile(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriterSize(file, bufferSize)
+	return &traceWriter{file: file, w: w, enc: json.NewEncoder(w)}, nil
+}
+
+// Record 记录一条决策，写入失败后不再继续记录
+func (t *traceWriter) Record(ev propmerge.TraceEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return
+	}
+	ev.Time = time.Now().Format(time.RFC3339Nano)
+	t.err = t.enc.Encode(ev)
+}
+
+// Close 刷新缓冲并关闭跟踪文件，返回写入过程中遇到的第一个错误
+func (t *traceWriter) Close() error {
+	if err := t.w.Flush(); err != nil && t.err == nil {
+		t.err = err
+	}
+	if err := t.file.Close(); err != nil && t.err == nil {
+		t.err = err
+	}
+	return t.err
+}
+
+// newMasker 根据配置文件中的sensitiveKeys创建敏感值掩码，指定-no-mask时返回nil
+func newMasker(config propmerge.Config) (*propmerge.Masker, error) {
+	if noMask {
+		return nil, nil
+	}
+	patterns := config.SensitivePatterns()
+	// 值来自Vault的参数总是视为敏感
+	for key := range config.Secrets {
+		patterns = append(patterns, "^"+regexp.QuoteMeta(key)+"$")
+	}
+	m, err := propmerge.NewMasker(patterns)
+	if err != nil {
+		return nil, fmt.Errorf(tr("加载敏感键规则失败: %w"), err)
+	}
+	return m, nil
+}
+
+// printSkippedDefaults 输出因值等于默认值而未保留的参数数量
+func printSkippedDefaults(skipped []string) {
+	if defaultsFile == "" {
+		return
+	}
+	fmt.Printf(tr("\n共 %d 个参数的旧值与默认值相同，未保留\n"), len(skipped))
+	for _, key := range skipped {
+		debugf(tr("未保留默认值参数: %s"), key, slog.String("key", key))
+	}
+}
+
+var (
+	// diffFile 为-diff-file指定的文件: 差异写入该文件而不是标准输出，批量模式下各文件的差异依次写入
+	diffFile string
+	diffMu   sync.Mutex
+	diffOut  *os.File
+)
+
+// printDiff 输出合并前后内容的unified diff，指定-diff-file时写入该文件
+func printDiff(filename string, before, after []string) {
+	diff := propmerge.UnifiedDiff(filename, filename+".merged", before, after)
+	if diffFile != "" {
+		if err := writeDiffFile(diff); err != nil {
+			warnf(tr("写入差异文件失败: %v"), err, slog.String("file", diffFile))
+		}
+		return
+	}
+	fmt.Println()
+	if diff == nil {
+		fmt.Println(tr("合并结果与新文件完全相同，无差异"))
+		return
+	}
+	for _, line := range diff {
+		if strings.HasPrefix(line, "---") || strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "@@") || line == "" {
+			fmt.Println(colorize(diffColor(line), line))
+			continue
+		}
+		fmt.Println(colorize(diffColor(line), line[:1]+masker.Line(line[1:])))
+	}
+}
+
+// writeDiffFile 将差异追加到-diff-file指定的文件，文件在第一次写入时创建；不带颜色，敏感值同样隐藏
+func writeDiffFile(diff []string) error {
+	diffMu.Lock()
+	defer diffMu.Unlock()
+	if diffOut == nil {
+		f, err := createOutputFile(diffFile)
+		if err != nil {
+			return err
+		}
+		diffOut = f
+	}
+	w := bufio.NewWriter(diffOut)
+	for _, line := range diff {
+		if line != "" && !strings.HasPrefix(line, "---") && !strings.HasPrefix(line, "+++") && !strings.HasPrefix(line, "@@") {
+			line = line[:1] + masker.Line(line[1:])
+		}
+		fmt.Fprintln(w, line)
+	}
+	return w.Flush()
+}
+
+// printPlan 输出预览模式下计划执行的修改
+func printPlan(results []propmerge.KeyResult) {
+	fmt.Println(tr("预览模式，未写入任何文件。计划执行以下修改:"))
+	fmt.Println("----------------------------")
+	actions := map[string]string{"replace": "替换", "insert": "插入", "append": "追加", "skip": "跳过", "comment": "注释写入", "drop": "不写入"}
+	for _, r := range results {
+		newValue := r.NewValue
+		if r.Action != propmerge.ActionReplace && r.Action != propmerge.ActionSkip {
+			newValue = tr("(无)")
+		}
+		fmt.Printf(tr("%s[行%d] %s: %s -> %s\n"), tr(actions[r.Action]), r.Line, r.Key, masker.Value(r.Key, newValue), masker.Value(r.Key, r.OldValue))
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共 %d 项计划修改\n"), len(results))
+}
+
+// printCollisions 输出重命名冲突及其处理方式
+func printCollisions(results []propmerge.KeyResult) {
+	found := propmerge.Result{Keys: results}.Collisions()
+	if len(found) == 0 {
+		return
+	}
+
+	fmt.Println(tr("\n重命名冲突:"))
+	fmt.Println("----------------------------")
+	for _, r := range found {
+		outcome := "已写入旧值"
+		if collisionPolicy == propmerge.CollisionFail {
+			outcome = "已中止"
+		} else if r.Action == propmerge.ActionSkip {
+			outcome = "未写入旧值"
+		}
+		fmt.Println(yellow(fmt.Sprintf("%s -> %s: %s (%s)", r.RenamedFrom, r.Key, r.Collision, tr(outcome))))
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共 %d 处重命名冲突\n"), len(found))
+}
+
+// printConflicts 输出三方合并冲突或-on-conflict检测到的旧值与新值的冲突及其处理方式
+func printConflicts(results []propmerge.KeyResult) {
+	found := propmerge.Result{Keys: results}.Conflicts()
+	if len(found) == 0 {
+		return
+	}
+
+	if baseFile != "" {
+		fmt.Println(tr("\n三方合并冲突:"))
+	} else {
+		fmt.Println(tr("\n旧值与新值冲突的参数:"))
+	}
+	fmt.Println("----------------------------")
+	for _, r := range found {
+		outcome := "已写入旧值"
+		if conflictPolicy == propmerge.CollisionFail {
+			outcome = "已中止"
+		} else if r.Action == propmerge.ActionSkip {
+			outcome = "保留新值"
+		}
+		fmt.Println(yellow(fmt.Sprintf(tr("%s: 旧值=%s, 新值=%s, %s (%s)"), r.Key, masker.Value(r.Key, r.OldValue), masker.Value(r.Key, r.NewValue), r.Conflict, tr(outcome))))
+	}
+	fmt.Println("----------------------------")
+	if baseFile != "" {
+		fmt.Printf(tr("共 %d 处三方合并冲突\n"), len(found))
+	} else {
+		fmt.Printf(tr("共 %d 处冲突\n"), len(found))
+	}
+}
+
+// printDiverged 输出旧值与新模板中的取值不同的保留参数，便于发现上游修改过、但被旧值覆盖的默认值
+func printDiverged(results []propmerge.KeyResult) {
+	found := propmerge.Result{Keys: results}.Diverged()
+	if len(found) == 0 {
+		return
+	}
+
+	fmt.Println(tr("\n与新模板取值不同的保留参数:"))
+	fmt.Println("----------------------------")
+	for _, r := range found {
+		fmt.Printf(tr("%4d: %s: 旧值=%s, 模板值=%s\n"), r.Line, r.Key, masker.Value(r.Key, r.OldValue), masker.Value(r.Key, r.NewValue))
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共 %d 个保留参数与新模板取值不同\n"), len(found))
+}
+
+// printDuplicates 输出重复的键及其处理方式
+func printDuplicates(dups []propmerge.Duplicate) {
+	if len(dups) == 0 {
+		return
+	}
+
+	sources := map[string]string{"old": "旧文件", "new": "新文件"}
+	fmt.Println(tr("\n重复的键:"))
+	fmt.Println("----------------------------")
+	for _, d := range dups {
+		lines := make([]string, len(d.Lines))
+		for i, n := range d.Lines {
+			lines[i] = fmt.Sprint(n)
+		}
+		outcome := "未处理"
+		switch {
+		case d.Kept != 0:
+			outcome = fmt.Sprintf(tr("保留行%d"), d.Kept)
+		case duplicatePolicy == propmerge.DuplicateError:
+			outcome = "已中止"
+		}
+		fmt.Printf(tr("%s %s: 行%s (%s)\n"), tr(sources[d.Source]), d.Key, strings.Join(lines, ","), tr(outcome))
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共 %d 个重复的键\n"), len(dups))
+}
+
+// printObsolete 输出新文件中已不存在、按-obsolete以注释形式写入或未写入的保留参数
+func printObsolete(results []propmerge.KeyResult) {
+	var found []propmerge.KeyResult
+	for _, r := range results {
+		if r.Action == propmerge.ActionComment || r.Action == propmerge.ActionDrop {
+			found = append(found, r)
+		}
+	}
+	if len(found) == 0 {
+		return
+	}
+
+	fmt.Println(tr("\n新文件中已不存在的参数:"))
+	fmt.Println("----------------------------")
+	for _, r := range found {
+		if r.Action == propmerge.ActionComment {
+			fmt.Println(yellow(fmt.Sprintf(tr("%s = %s (已注释写入行%d)"), r.Key, masker.Value(r.Key, r.OldValue), r.Line)))
+		} else {
+			fmt.Println(yellow(fmt.Sprintf(tr("%s = %s (未写入)"), r.Key, masker.Value(r.Key, r.OldValue))))
+		}
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共 %d 个参数在新文件中已不存在\n"), len(found))
+}
+
+// printChangedParams 仅输出合并后值实际发生变化的参数
+func printChangedParams(results []propmerge.KeyResult) {
+	fmt.Println(tr("\n值发生变化的参数列表:"))
+	fmt.Println("----------------------------")
+	count := 0
+	for _, r := range results {
+		if !r.Changed() {
+			continue
+		}
+		if r.Action == propmerge.ActionReplace {
+			fmt.Printf("%4d: %s: %s -> %s\n", r.Line, r.Key, masker.Value(r.Key, r.NewValue), masker.Value(r.Key, r.OldValue))
+		} else {
+			fmt.Printf(tr("%4d: %s: (新文件中不存在) -> %s\n"), r.Line, r.Key, masker.Value(r.Key, r.OldValue))
+		}
+		count++
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共 %d 个参数值发生变化\n"), count)
+}
+
+// printAutoPreserved 输出自动推导出的保留参数集合，便于人工复核
+func printAutoPreserved(results []propmerge.KeyResult) {
+	fmt.Println(tr("\n自动推导的保留参数(两文件中值不同的键):"))
+	fmt.Println("----------------------------")
+	for _, r := range results {
+		if r.Action == propmerge.ActionReplace {
+			fmt.Printf(tr("%4d: %s: %s (新文件: %s)\n"), r.Line, r.Key, masker.Value(r.Key, r.OldValue), masker.Value(r.Key, r.NewValue))
+		} else {
+			fmt.Printf(tr("%4d: %s: %s (仅存在于旧文件)\n"), r.Line, r.Key, masker.Value(r.Key, r.OldValue))
+		}
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共自动保留 %d 个参数\n"), len(results))
+}
+
+// printKeptResults 输出已保留的参数及其在合并结果中的行号
+func printKeptResults(results []propmerge.KeyResult) {
+	fmt.Println(tr("\n保留的参数列表:"))
+	fmt.Println("----------------------------")
+	count := 0
+	for _, r := range results {
+		if !r.Written() {
+			continue
+		}
+		fmt.Printf("%4d: %s: %s\n", r.Line, r.Key, masker.Value(r.Key, r.OldValue))
+		count++
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共保留 %d 个参数\n"), count)
+}
+
+// printMatchedParams 输出合并后文件中命中保留规则的参数，行号、键与值按列对齐
+func printMatchedParams(merger *propmerge.Merger, filename string) {
+	debugf(tr("开始显示匹配参数..."))
+	debugf(tr("使用匹配规则: %s"), merger.Options().Pattern)
+
+	type matchedRow struct {
+		line       int
+		key, value string
+	}
+	var rows []matchedRow
+	keyWidth := 0
+	err := propmerge.ScanProperties(filename, func(p propmerge.Property) error {
+		if merger.Matches(propmerge.EscapeKey(p.Key, false) + "=" + propmerge.EscapeValue(p.Value, false)) {
+			rows = append(rows, matchedRow{p.Line, p.Key, masker.Value(p.Key, p.Value)})
+			keyWidth = max(keyWidth, displayWidth(p.Key))
+		}
+		return nil
+	})
+	if err != nil {
+		warnf(tr("无法显示匹配参数: %v"), err)
+	}
+
+	fmt.Println(tr("\n匹配的参数列表:"))
+	fmt.Println("----------------------------")
+	for _, r := range rows {
+		fmt.Printf("%4d: %s = %s\n", r.line, padRight(r.key, keyWidth), green(r.value))
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共找到 %d 个匹配参数\n"), len(rows))
+
+	debugf(tr("显示匹配参数完成"))
+}
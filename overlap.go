@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+const (
+	// minOverlapKeys 为进行文件对检查所需的旧文件最少键数，键太少的文件重合比例没有参考意义
+	minOverlapKeys = 5
+	// defaultMinOverlap 为未指定-min-overlap时的最低比例，低于该比例只输出警告
+	defaultMinOverlap = 50
+)
+
+var (
+	// minOverlap 为旧文件的键在新文件中仍然存在的最低比例(百分比，-min-overlap)，为0时不检查
+	minOverlap = defaultMinOverlap
+	// minOverlapSet 为true时-min-overlap在命令行中显式指定，重合比例低于minOverlap时拒绝合并
+	minOverlapSet bool
+	// forceMerge 为true时(-force)确认文件对无误: 重合比例低于minOverlap时仍然合并，未显式指定-min-overlap时不再警告
+	forceMerge bool
+)
+
+// setMinOverlap 解析-min-overlap，并记录该选项已显式指定
+func setMinOverlap(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	minOverlap, minOverlapSet = n, true
+	return nil
+}
+
+// fileKeys 返回配置文件中的全部键，properties文件逐行读取，不将文件整个载入内存
+func fileKeys(filename, format string) ([]string, error) {
+	var keys []string
+	if format == formatProperties {
+		err := propmerge.ScanProperties(filename, func(p propmerge.Property) error {
+			keys = append(keys, p.Key)
+			return nil
+		})
+		return keys, err
+	}
+	lines, err := propmerge.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := parseConfig(filename, format, lines)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		keys = append(keys, e.Key)
+	}
+	return keys, nil
+}
+
+// checkOverlap 比较旧文件与新文件的键，旧文件的键在新文件中仍然存在的比例低于-min-overlap时
+// 判定为传错了文件对(如把前端的配置合并到后端的配置上)。显式指定了-min-overlap时返回参数错误，
+// 否则按默认比例只输出警告；指定-force时仍然合并
+func checkOverlap(merger *propmerge.Merger, format, oldFile, newFile string) error {
+	if minOverlap <= 0 {
+		return nil
+	}
+	oldKeys, err := fileKeys(oldFile, format)
+	if err != nil {
+		return fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+	newKeys, err := fileKeys(newFile, format)
+	if err != nil {
+		return fmt.Errorf(tr("读取新文件失败: %w"), err)
+	}
+	shared, total := merger.Overlap(oldKeys, newKeys)
+	if total < minOverlapKeys {
+		return nil
+	}
+	percent := shared * 100 / total
+	debugf(tr("旧文件的%d个键中有%d个(%d%%)在新文件中仍然存在"), total, shared, percent, slog.String("file", newFile))
+	if percent >= minOverlap {
+		return nil
+	}
+	if !minOverlapSet {
+		if !forceMerge {
+			warnf(tr("旧文件 %s 的%d个键中只有%d个(%d%%)在新文件 %s 中存在，低于%d%%，可能传错了文件对；指定-min-overlap时将拒绝合并，确认无误时可用-force关闭该警告"),
+				oldFile, total, shared, percent, newFile, minOverlap, slog.String("file", newFile))
+		}
+		return nil
+	}
+	if forceMerge {
+		warnf(tr("旧文件 %s 的%d个键中只有%d个(%d%%)在新文件 %s 中存在，低于-min-overlap指定的%d%%，因指定了-force仍然合并"),
+			oldFile, total, shared, percent, newFile, minOverlap, slog.String("file", newFile))
+		return nil
+	}
+	return invalid(fmt.Errorf(tr("旧文件 %s 的%d个键中只有%d个(%d%%)在新文件 %s 中存在，低于-min-overlap指定的%d%%，可能传错了文件对；确认无误时使用-force继续合并"),
+		oldFile, total, shared, percent, newFile, minOverlap))
+}
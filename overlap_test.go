@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMinOverlap 默认键重合比例低于50%时只警告；显式指定-min-overlap后重合比例过低的文件对被拒绝，-force时仍然合并
+func TestMinOverlap(t *testing.T) {
+	const (
+		oldFile = "ftp.host=10.0.0.1\nftp.port=21\nftp.user=u\nftp.timeout=30\nftp.mode=passive\n"
+		newFile = "server.port=8080\nserver.host=0.0.0.0\nspring.profiles.active=prod\nlogging.level.root=INFO\nmanagement.port=9090\nftp.host=1.2.3.4\n"
+	)
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		merged bool
+		warned bool
+	}{
+		{"default warns", nil, exitChanged, true, true},
+		{"-force silences the warning", []string{"-force"}, exitChanged, true, false},
+		{"-min-overlap 0", []string{"-min-overlap", "0"}, exitChanged, true, false},
+		{"-min-overlap 50", []string{"-min-overlap", "50"}, exitInvalid, false, false},
+		{"-min-overlap 50 -force", []string{"-min-overlap", "50", "-force"}, exitChanged, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				configFile:       `{"patternKeys": "^ftp\\.host"}`,
+				"old.properties": oldFile,
+				"new.properties": newFile,
+			})
+			args := append(append([]string{"-no-backup"}, tt.args...), "old.properties", "new.properties")
+			stdout, stderr, code := runMain(t, dir, args...)
+			if code != tt.code {
+				t.Fatalf("exit code = %d, want %d\nstdout:\n%s\nstderr:\n%s", code, tt.code, stdout, stderr)
+			}
+			got := readFile(t, filepath.Join(dir, "new.properties"))
+			if merged := got != newFile; merged != tt.merged {
+				t.Errorf("merged = %v, want %v; new.properties:\n%s", merged, tt.merged, got)
+			}
+			if warned := strings.Contains(stderr, "[WARN] 旧文件 old.properties"); warned != tt.warned {
+				t.Errorf("warned = %v, want %v\nstdout:\n%s\nstderr:\n%s", warned, tt.warned, stdout, stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package propmerge
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// AtomicWrite 原子地替换文件内容: write写入同目录下的临时文件，同步到磁盘后重命名覆盖目标文件，
+// 中途失败或崩溃时原文件保持不变。覆盖已有文件时沿用其权限、属主、属组与SELinux安全上下文；
+// 目标为符号链接时替换其指向的文件
+func AtomicWrite(filename string, write func(w io.Writer) error) error {
+	target := filename
+	if resolved, err := filepath.EvalSymlinks(filename); err == nil {
+		target = resolved
+	}
+	info, err := os.Stat(target)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf(tr("读取文件信息失败: %w"), err)
+	}
+
+	dir := filepath.Dir(target)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf(tr("创建临时文件失败: %w"), err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := write(tmp); err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0644)
+	if info != nil {
+		mode = info.Mode().Perm()
+		if err := chownLike(tmp, info); err != nil {
+			return fmt.Errorf(tr("设置属主失败: %w"), err)
+		}
+		// 重命名后的文件带有临时文件创建时按目录继承的上下文，需恢复为原文件的上下文
+		if context := SecurityContext(target); context != "" && SecurityContext(tmp.Name()) != context {
+			if err := SetSecurityContext(tmp.Name(), context); err != nil {
+				return fmt.Errorf(tr("设置SELinux安全上下文失败: %w"), err)
+			}
+		}
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return fmt.Errorf(tr("设置文件权限失败: %w"), err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf(tr("同步文件失败: %w"), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf(tr("关闭临时文件失败: %w"), err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf(tr("替换文件失败: %w"), err)
+	}
+	return syncDir(dir)
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package propmerge
+
+import "os"
+
+// chownLike 在不支持属主的平台上不做任何处理
+func chownLike(tmp *os.File, info os.FileInfo) error {
+	return nil
+}
+
+// syncDir 在不支持同步目录的平台上不做任何处理
+func syncDir(dir string) error {
+	return nil
+}
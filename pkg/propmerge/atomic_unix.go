@@ -0,0 +1,38 @@
+//go:build unix
+
+package propmerge
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// chownLike 将临时文件的属主与属组设置为与原文件一致，已一致时不做修改
+func chownLike(tmp *os.File, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	cur, err := tmp.Stat()
+	if err != nil {
+		return err
+	}
+	if c, ok := cur.Sys().(*syscall.Stat_t); ok && c.Uid == st.Uid && c.Gid == st.Gid {
+		return nil
+	}
+	return tmp.Chown(int(st.Uid), int(st.Gid))
+}
+
+// syncDir 同步目录，确保重命名操作已写入磁盘
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf(tr("同步目录失败: %w"), err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf(tr("同步目录失败: %w"), err)
+	}
+	return nil
+}
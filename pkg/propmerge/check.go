@@ -0,0 +1,205 @@
+package propmerge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CheckRules 定义合并结果的校验规则，从独立的规则文件或config-matcher.json的checks加载。各项均为键名，
+// 可使用*(不含'.'的任意字符)与**(任意字符)通配符，写法同RuleGlob
+type CheckRules struct {
+	Required []string `json:"required"` // 必须存在的键，含通配符时至少存在一个匹配的键
+	NonEmpty []string `json:"nonEmpty"` // 值不能为空的键
+	Integer  []string `json:"integer"`  // 值必须为整数的键
+	Boolean  []string `json:"boolean"`  // 值必须为true或false的键
+	URL      []string `json:"url"`      // 值必须为带scheme的地址的键，如https://host/、jdbc:mysql://host/db
+}
+
+// Violation 是一处未通过校验的参数
+type Violation struct {
+	Key     string `json:"key"`
+	Line    int    `json:"line,omitempty"`
+	Rule    string `json:"rule"` // required、nonEmpty、integer、boolean、url，或加密值无法解密时的encrypted
+	Message string `json:"message"`
+}
+
+// LoadCheckRules 读取并解析校验规则文件，未知的字段视为错误，避免规则名拼写错误时静默跳过校验
+func LoadCheckRules(path string) (CheckRules, error) {
+	var rules CheckRules
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rules, fmt.Errorf(tr("读取校验规则文件失败: %w"), err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rules); err != nil {
+		return rules, fmt.Errorf(tr("解析校验规则文件失败: %w"), err)
+	}
+	return rules, nil
+}
+
+// Check 按规则校验键值，返回所有未通过校验的参数。重复的键以最后一次出现为准
+func (c CheckRules) Check(entries []Entry) []Violation {
+	values := make(map[string]Entry, len(entries))
+	var keys []string
+	for _, e := range entries {
+		if _, ok := values[e.Key]; !ok {
+			keys = append(keys, e.Key)
+		}
+		values[e.Key] = e
+	}
+
+	var found []Violation
+	for _, pattern := range c.Required {
+		if !strings.Contains(pattern, "*") && !strings.Contains(pattern, "?") {
+			if _, ok := values[pattern]; !ok {
+				found = append(found, Violation{Key: pattern, Rule: "required", Message: tr("缺少必需的参数")})
+			}
+			continue
+		}
+		if len(matchingKeys(pattern, keys)) == 0 {
+			found = append(found, Violation{Key: pattern, Rule: "required", Message: tr("没有与之匹配的参数")})
+		}
+	}
+
+	checks := []struct {
+		rule     string
+		patterns []string
+		valid    func(string) bool
+		message  string
+	}{
+		{"nonEmpty", c.NonEmpty, func(v string) bool { return v != "" }, "值不能为空"},
+		{"integer", c.Integer, isInteger, "值不是整数: %s"},
+		{"boolean", c.Boolean, func(v string) bool { return v == "true" || v == "false" }, "值不是true或false: %s"},
+		{"url", c.URL, isURLValue, "值不是有效的地址: %s"},
+	}
+	for _, check := range checks {
+		for _, pattern := range check.patterns {
+			for _, key := range matchingKeys(pattern, keys) {
+				e := values[key]
+				if check.valid(e.Value) {
+					continue
+				}
+				message := tr(check.message)
+				if strings.Contains(message, "%s") {
+					message = fmt.Sprintf(message, e.Value)
+				}
+				found = append(found, Violation{Key: key, Line: e.Line, Rule: check.rule, Message: message})
+			}
+		}
+	}
+	return found
+}
+
+// matchingKeys 返回匹配键名通配符的键，不含通配符时只匹配同名的键
+func matchingKeys(pattern string, keys []string) []string {
+	re := regexp.MustCompile("^" + globPattern(pattern) + "$")
+	var matched []string
+	for _, key := range keys {
+		if re.MatchString(key) {
+			matched = append(matched, key)
+		}
+	}
+	return matched
+}
+
+func isInteger(v string) bool {
+	_, err := strconv.ParseInt(v, 10, 64)
+	return err == nil
+}
+
+// isURLValue 判断值是否为带scheme的地址，jdbc:mysql://这类嵌套scheme同样接受
+func isURLValue(v string) bool {
+	u, err := url.Parse(v)
+	if err != nil || u.Scheme == "" {
+		return false
+	}
+	return u.Host != "" || strings.Contains(u.Opaque, "://") || (u.Opaque == "" && u.Path != "")
+}
+
+// ParseEntries 将properties、yaml、toml、ini、json、hocon、env(dotenv)或xml格式的内容解析为键值，结构化格式的键为点分路径，
+// toml数组表中的键带有元素的序号(如servers[1].host)，xml的键为属性或元素文本的规范路径(如/Server/Service[@name='Catalina']/Connector[1]/@port)，
+// 带引号的字符串值去掉引号。properties与hocon中重复的键以最后一次出现为准，hocon值中的替换${...}展开为引用的值
+func ParseEntries(format string, lines []string) ([]Entry, error) {
+	var entries []Entry
+	switch format {
+	case "properties":
+		keys, props := ParseProperties(lines)
+		for _, key := range keys {
+			p := props[key]
+			entries = append(entries, Entry{Key: key, Value: p.Value, Line: p.Line})
+		}
+	case "yaml":
+		for _, n := range parseYAML(lines) {
+			if n.leaf {
+				entries = append(entries, Entry{Key: n.path, Value: unquoteScalar(n.value), Line: n.start + 1})
+			}
+		}
+	case "toml":
+		tomlEntries, _ := parseTOML(lines)
+		for _, e := range tomlEntries {
+			entries = append(entries, Entry{Key: e.id(), Value: unquoteScalar(e.value), Line: e.start + 1})
+		}
+	case "json":
+		text := strings.Join(lines, "\n")
+		nodes, err := parseJSON(text)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nodes {
+			if !n.object && n.path != "" {
+				entries = append(entries, Entry{Key: n.path, Value: unquoteScalar(text[n.start:n.end]), Line: lineAt(text, n.start)})
+			}
+		}
+	case "hocon":
+		hoconEntries, _ := parseHOCON(lines)
+		hoconEntries = effectiveHOCON(hoconEntries)
+		values := make(map[string]string, len(hoconEntries))
+		for _, e := range hoconEntries {
+			values[e.path] = e.value
+		}
+		for _, e := range hoconEntries {
+			entries = append(entries, Entry{Key: e.path, Value: resolveHOCON(values, e.value, 0), Line: e.start + 1})
+		}
+	case "ini":
+		iniEntries, _ := parseINI(lines)
+		for _, e := range iniEntries {
+			entries = append(entries, Entry{Key: e.path, Value: unquoteScalar(e.value), Line: e.start + 1})
+		}
+	case "env":
+		for _, e := range parseEnv(lines) {
+			entries = append(entries, Entry{Key: e.key, Value: e.value, Line: e.start + 1})
+		}
+	case "xml":
+		root, err := parseXML(strings.Join(lines, "\n"))
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range root.allTargets() {
+			entries = append(entries, Entry{Key: t.key, Value: t.value, Line: t.line})
+		}
+	default:
+		return nil, fmt.Errorf(tr("不支持的文件格式: %s"), format)
+	}
+	return entries, nil
+}
+
+// unquoteScalar 去掉字符串值两侧的引号，双引号字符串按JSON转义规则解码
+func unquoteScalar(v string) string {
+	if len(v) < 2 || (v[0] != '"' && v[0] != '\'') || v[len(v)-1] != v[0] {
+		return v
+	}
+	if v[0] == '"' {
+		var s string
+		if json.Unmarshal([]byte(v), &s) == nil {
+			return s
+		}
+	}
+	return v[1 : len(v)-1]
+}
@@ -0,0 +1,340 @@
+package propmerge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DefaultPattern 为未提供配置文件时使用的默认保留规则
+const DefaultPattern = `^(spring\.datasource|spring\.redis|web\.back\.upLoadPath|web\.front\.upLoadPath|token\.expireTime|ftp.userName|ftp.passWord|ftp.host|ftp.port|ftp.baseUrl|ftp.LocalDir|inco.system.xxmc|inco.system.maintitle|inco.person.xxdm|inco.security.login.checkcode)`
+
+// Config 定义配置文件(config-matcher.json)结构
+type Config struct {
+	Version          int                    `json:"version"`
+	PatternKeys      string                 `json:"patternKeys"`
+	Rules            []Rule                 `json:"rules"`
+	ExcludeKeys      []Rule                 `json:"excludeKeys"`
+	XMLPaths         []string               `json:"xmlPaths"`
+	EnvRules         []EnvRule              `json:"envRules"`
+	Profiles         map[string]RuleProfile `json:"profiles"`
+	Renames          map[string]string      `json:"renames"`
+	Moves            map[string]string      `json:"moves"`
+	Transforms       []Transform            `json:"transforms"`
+	PreserveComments bool                   `json:"preserveComments"`
+	SensitiveKeys    []string               `json:"sensitiveKeys"`
+	OnDuplicate      string                 `json:"onDuplicate"`
+	Hooks            Hooks                  `json:"hooks"`
+	Formats          []FormatPlugin         `json:"formats"`
+	Notifications    []Notification         `json:"notifications"`
+	Vault            Vault                  `json:"vault"`
+	Secrets          map[string]Secret      `json:"secrets"`
+	Checks           *CheckRules            `json:"checks"` // validate未指定-check-rules时使用的校验规则
+	BackupDir        BackupDestinations     `json:"backupDir"`
+	BackupEncryption BackupEncryption       `json:"backupEncryption"`
+}
+
+// 复制备份到目的地失败时的处理方式
+const (
+	BackupOnErrorWarn  = "warn"  // 输出警告并继续合并(默认)
+	BackupOnErrorAbort = "abort" // 中止合并，不写入任何修改
+)
+
+// BackupDestination 定义一个接收备份副本的目的地: url为file://目录(或本地目录)、sftp://[用户@]主机[:端口]/目录、
+// s3://桶/前缀或oss://桶/前缀。未指定的凭据分别读取AWS_*或OSS_*环境变量
+type BackupDestination struct {
+	URL             string `json:"url"`
+	OnError         string `json:"onError"`         // warn(默认)或abort
+	Region          string `json:"region"`          // S3的区域，默认读取AWS_REGION
+	Endpoint        string `json:"endpoint"`        // S3兼容存储(如MinIO)或OSS的地址，如oss-cn-hangzhou.aliyuncs.com
+	AccessKeyID     string `json:"accessKeyId"`     // S3或OSS的AccessKey ID
+	SecretAccessKey string `json:"secretAccessKey"` // S3的Secret Access Key或OSS的AccessKey Secret
+	SessionToken    string `json:"sessionToken"`    // 临时凭证的令牌
+	IdentityFile    string `json:"identityFile"`    // sftp使用的私钥文件
+}
+
+// 备份文件的加密方式
+const (
+	BackupCipherAge = "age"
+	BackupCipherGPG = "gpg"
+)
+
+// BackupEncryption 定义备份文件的加密方式，type为空时不加密。加密与解密调用系统中的age或gpg命令
+type BackupEncryption struct {
+	Type       string   `json:"type"`       // age或gpg
+	Recipients []string `json:"recipients"` // 接收者: age公钥(age1...或ssh-ed25519 ...)，或GPG的用户ID、邮箱或指纹
+	Identity   string   `json:"identity"`   // 解密age备份使用的私钥文件，恢复备份时使用；GPG使用自身密钥环中的私钥
+}
+
+// UnmarshalJSON 允许目的地直接写作字符串url
+func (d *BackupDestination) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		*d = BackupDestination{URL: url}
+		return nil
+	}
+	type plain BackupDestination
+	return json.Unmarshal(data, (*plain)(d))
+}
+
+// BackupDestinations 是config-matcher.json中的backupDir: 可以写作单个目的地，也可以写作列表
+type BackupDestinations []BackupDestination
+
+// UnmarshalJSON 允许backupDir写作单个字符串或对象
+func (b *BackupDestinations) UnmarshalJSON(data []byte) error {
+	var list []BackupDestination
+	if err := json.Unmarshal(data, &list); err == nil {
+		*b = list
+		return nil
+	}
+	var one BackupDestination
+	if err := json.Unmarshal(data, &one); err != nil {
+		return err
+	}
+	*b = BackupDestinations{one}
+	return nil
+}
+
+// Scheme 返回目的地的类型: file、sftp、s3或oss，不带scheme的路径为file
+func (d BackupDestination) Scheme() string {
+	scheme, _, ok := strings.Cut(d.URL, "://")
+	if !ok {
+		return "file"
+	}
+	if scheme == "ssh" {
+		return "sftp"
+	}
+	return scheme
+}
+
+// Vault的认证方式
+const (
+	VaultAuthToken   = "token"   // 使用token(或VAULT_TOKEN)
+	VaultAuthAppRole = "approle" // 使用roleId与secretId登录AppRole
+)
+
+// Vault 定义访问HashiCorp Vault的地址与认证方式，未指定的项分别读取VAULT_ADDR、VAULT_NAMESPACE、
+// VAULT_TOKEN、VAULT_ROLE_ID与VAULT_SECRET_ID环境变量
+type Vault struct {
+	Address      string `json:"address"`
+	Namespace    string `json:"namespace"`
+	Auth         string `json:"auth"` // token(默认)或approle
+	Token        string `json:"token"`
+	RoleID       string `json:"roleId"`
+	SecretID     string `json:"secretId"`
+	AppRoleMount string `json:"appRoleMount"` // AppRole认证的挂载路径，默认approle
+}
+
+// Secret 定义一个值从Vault读取的参数: path为API路径(不含/v1/)，如KV v2的secret/data/myapp/db，
+// field为该路径下数据中的字段名
+type Secret struct {
+	Path  string `json:"path"`
+	Field string `json:"field"`
+}
+
+// Hooks 定义合并前后执行的shell命令
+type Hooks struct {
+	PreMerge  string `json:"preMerge"`
+	PostMerge string `json:"postMerge"`
+}
+
+// 合并通知的类型
+const (
+	NotifyWebhook  = "webhook"  // 以JSON格式POST合并摘要
+	NotifyDingTalk = "dingtalk" // 钉钉群机器人
+	NotifyWeCom    = "wecom"    // 企业微信群机器人
+)
+
+// Notification 定义合并完成后接收摘要的webhook
+type Notification struct {
+	Type      string            `json:"type"`      // webhook(默认)、dingtalk或wecom
+	URL       string            `json:"url"`       // 接收通知的地址，钉钉与企业微信为机器人的webhook地址
+	Headers   map[string]string `json:"headers"`   // 额外的请求头，如认证信息
+	ReportURL string            `json:"reportUrl"` // 通知中的报告链接，为空时使用本地报告文件路径
+}
+
+// EnvRule 定义仅在指定环境下生效的保留规则，keys中每一项为键名的正则前缀
+type EnvRule struct {
+	Env  string   `json:"env"`
+	Keys []string `json:"keys"`
+}
+
+// RuleProfile 定义一组按名称选用的规则(如dev、test、prod)，选用后追加到顶层的共享规则之后:
+// patternKeys与顶层的patternKeys任一命中即保留，rules、excludeKeys与transforms依次排在顶层规则之后
+type RuleProfile struct {
+	PatternKeys string      `json:"patternKeys"`
+	Rules       []Rule      `json:"rules"`
+	ExcludeKeys []Rule      `json:"excludeKeys"`
+	Transforms  []Transform `json:"transforms"`
+}
+
+// LoadConfig 读取并解析配置文件，文件不存在时exists为false
+func LoadConfig(path string) (config Config, exists bool, err error) {
+	// 检查配置文件是否存在
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return config, false, nil
+	}
+
+	// 读取配置文件
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return config, true, fmt.Errorf(tr("读取配置文件失败: %w"), err)
+	}
+
+	if err := json.Unmarshal(file, &config); err != nil {
+		return config, true, fmt.Errorf(tr("解析配置文件失败: %w"), err)
+	}
+	if config.Version > 2 {
+		return config, true, fmt.Errorf(tr("不支持的配置文件版本: %d"), config.Version)
+	}
+	for from, to := range config.Moves {
+		if from == "" || to == "" {
+			return config, true, fmt.Errorf(tr("moves中的路径不能为空: %q -> %q"), from, to)
+		}
+	}
+	for i, f := range config.Formats {
+		if f.Name == "" || len(f.Command) == 0 {
+			return config, true, fmt.Errorf(tr("第%d个格式插件缺少name或command"), i+1)
+		}
+	}
+	for i, n := range config.Notifications {
+		switch n.Type {
+		case "", NotifyWebhook, NotifyDingTalk, NotifyWeCom:
+		default:
+			return config, true, fmt.Errorf(tr("第%d个通知的类型无效: %s"), i+1, n.Type)
+		}
+		if n.URL == "" {
+			return config, true, fmt.Errorf(tr("第%d个通知缺少url"), i+1)
+		}
+	}
+	switch config.Vault.Auth {
+	case "", VaultAuthToken, VaultAuthAppRole:
+	default:
+		return config, true, fmt.Errorf(tr("无效的Vault认证方式: %s"), config.Vault.Auth)
+	}
+	for key, secret := range config.Secrets {
+		if secret.Path == "" || secret.Field == "" {
+			return config, true, fmt.Errorf(tr("secrets中的%s缺少path或field"), key)
+		}
+	}
+	for i, d := range config.BackupDir {
+		if d.URL == "" {
+			return config, true, fmt.Errorf(tr("backupDir中第%d个目的地缺少url"), i+1)
+		}
+		switch d.Scheme() {
+		case "file", "sftp", "s3", "oss":
+		default:
+			return config, true, fmt.Errorf(tr("backupDir中第%d个目的地的类型不受支持: %s (可选file://、sftp://、s3://、oss://)"), i+1, d.URL)
+		}
+		switch d.OnError {
+		case "", BackupOnErrorWarn, BackupOnErrorAbort:
+		default:
+			return config, true, fmt.Errorf(tr("backupDir中第%d个目的地的onError无效: %s"), i+1, d.OnError)
+		}
+	}
+	switch config.BackupEncryption.Type {
+	case "":
+	case BackupCipherAge, BackupCipherGPG:
+		if len(config.BackupEncryption.Recipients) == 0 {
+			return config, true, errors.New(tr("backupEncryption缺少recipients"))
+		}
+	default:
+		return config, true, fmt.Errorf(tr("无效的备份加密方式: %s (可选age、gpg)"), config.BackupEncryption.Type)
+	}
+	return config, true, nil
+}
+
+// KeepPattern 返回指定环境下生效的正则保留规则: 全局patternKeys与该环境的envRules合并。
+// v1配置未定义patternKeys时使用DefaultPattern；v2配置的rules由Options.Rules单独传入，
+// 此时仅返回patternKeys与envRules部分(可能为空)
+func (c Config) KeepPattern(env string) string {
+	pattern := c.PatternKeys
+	if pattern == "" && len(c.Rules) == 0 {
+		pattern = DefaultPattern
+	}
+	envKeys := c.EnvPattern(env)
+	switch {
+	case envKeys == "":
+		return pattern
+	case pattern == "":
+		return envKeys
+	default:
+		return "(" + pattern + ")|" + envKeys
+	}
+}
+
+// FormatPlugin 返回名称为name的格式插件
+func (c Config) FormatPlugin(name string) (FormatPlugin, bool) {
+	for _, f := range c.Formats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return FormatPlugin{}, false
+}
+
+// SensitivePatterns 返回敏感键规则: 未定义sensitiveKeys时使用DefaultSensitiveKeys，
+// 定义为空数组时不隐藏任何值
+func (c Config) SensitivePatterns() []string {
+	if c.SensitiveKeys == nil {
+		return DefaultSensitiveKeys
+	}
+	return c.SensitiveKeys
+}
+
+// EnvPattern 返回指定环境下需要额外保留的键的匹配规则，无匹配环境时返回空串
+func (c Config) EnvPattern(env string) string {
+	if env == "" {
+		return ""
+	}
+
+	var keys []string
+	for _, rule := range c.EnvRules {
+		if rule.Env == env {
+			keys = append(keys, rule.Keys...)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	return "^(" + strings.Join(keys, "|") + ")"
+}
+
+// ProfileNames 返回配置文件中定义的规则组名称，按名称排序
+func (c Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithProfile 返回选用名为name的规则组后的配置: 顶层的共享规则保持不变，规则组中的规则追加在其后。
+// name为空时原样返回；配置文件中没有该规则组时返回错误
+func (c Config) WithProfile(name string) (Config, error) {
+	if name == "" {
+		return c, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return c, fmt.Errorf(tr("配置文件中没有定义profiles，无法选用规则组 %s"), name)
+		}
+		return c, fmt.Errorf(tr("配置文件中没有名为 %s 的规则组，可选: %s"), name, strings.Join(c.ProfileNames(), ", "))
+	}
+	switch {
+	case p.PatternKeys == "":
+	case c.PatternKeys == "":
+		c.PatternKeys = p.PatternKeys
+	default:
+		c.PatternKeys = "(" + c.PatternKeys + ")|(" + p.PatternKeys + ")"
+	}
+	c.Rules = append(append([]Rule(nil), c.Rules...), p.Rules...)
+	c.ExcludeKeys = append(append([]Rule(nil), c.ExcludeKeys...), p.ExcludeKeys...)
+	c.Transforms = append(append([]Transform(nil), c.Transforms...), p.Transforms...)
+	return c, nil
+}
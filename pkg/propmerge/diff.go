@@ -0,0 +1,253 @@
+package propmerge
+
+import "fmt"
+
+// diffOp 表示行级差异中的一行，kind为' '(未变)、'-'(删除)或'+'(新增)
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// diffMaxSteps 为bisect每个方向搜索的最大步数，超过时该部分按整体替换输出，
+// 避免两个差异很大的大文件耗时过长
+const diffMaxSteps = 2000
+
+// diffLines 使用线性空间的Myers算法计算两组行之间的最短编辑序列: 先去掉公共的首尾行，
+// 再以中间蛇(middle snake)把问题一分为二递归求解，内存占用为O(N+M)，与差异的大小无关。
+// 某一部分的编辑距离超过约2*diffMaxSteps时不再求最短序列，该部分先全部删除再全部新增
+func diffLines(a, b []string) []diffOp {
+	size := len(a) + len(b) + 3
+	d := differ{a: a, b: b, ops: make([]diffOp, 0, len(a)+len(b)), v1: make([]int, size), v2: make([]int, size)}
+	d.compare(0, len(a), 0, len(b))
+	return d.ops
+}
+
+// differ 保存diffLines的输入、结果与bisect复用的两个V数组
+type differ struct {
+	a, b   []string
+	ops    []diffOp
+	v1, v2 []int
+}
+
+// compare 将a[aLo:aHi]与b[bLo:bHi]的编辑序列按顺序追加到ops
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.ops = append(d.ops, diffOp{kind: ' ', text: d.a[aLo]})
+		aLo++
+		bLo++
+	}
+	aEnd, bEnd := aHi, bHi
+	for aLo < aEnd && bLo < bEnd && d.a[aEnd-1] == d.b[bEnd-1] {
+		aEnd--
+		bEnd--
+	}
+
+	if x, y, ok := d.bisect(aLo, aEnd, bLo, bEnd); ok {
+		d.compare(aLo, x, bLo, y)
+		d.compare(x, aEnd, y, bEnd)
+	} else {
+		// 一侧为空、两侧没有公共行或差异过大: 全部删除再全部新增
+		for _, line := range d.a[aLo:aEnd] {
+			d.ops = append(d.ops, diffOp{kind: '-', text: line})
+		}
+		for _, line := range d.b[bLo:bEnd] {
+			d.ops = append(d.ops, diffOp{kind: '+', text: line})
+		}
+	}
+
+	for i := aEnd; i < aHi; i++ {
+		d.ops = append(d.ops, diffOp{kind: ' ', text: d.a[i]})
+	}
+}
+
+// bisect 同时从两端搜索最短编辑路径，返回两个方向的路径重叠处(中间蛇)的位置，
+// 在该点把问题分为前后两半；一侧为空、两侧没有公共行或搜索超过diffMaxSteps步时返回ok=false
+func (d *differ) bisect(aLo, aHi, bLo, bHi int) (x, y int, ok bool) {
+	a, b := d.a[aLo:aHi], d.b[bLo:bHi]
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	length := 2*maxD + 2
+	v1, v2 := d.v1[:length], d.v2[:length]
+	for i := range v1 {
+		v1[i], v2[i] = -1, -1
+	}
+	v1[offset+1], v2[offset+1] = 0, 0
+	delta := n - m
+	// 总步数为奇数时路径在正向搜索中重叠，否则在反向搜索中重叠
+	front := delta%2 != 0
+	// 越过边界的对角线不再搜索
+	k1Start, k1End, k2Start, k2End := 0, 0, 0, 0
+	for step := 0; step < maxD && step < diffMaxSteps; step++ {
+		for k1 := -step + k1Start; k1 <= step-k1End; k1 += 2 {
+			i := offset + k1
+			var x1 int
+			if k1 == -step || (k1 != step && v1[i-1] < v1[i+1]) {
+				x1 = v1[i+1]
+			} else {
+				x1 = v1[i-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			v1[i] = x1
+			switch {
+			case x1 > n:
+				k1End += 2
+			case y1 > m:
+				k1Start += 2
+			case front:
+				if j := offset + delta - k1; j >= 0 && j < length && v2[j] != -1 && x1 >= n-v2[j] {
+					return aLo + x1, bLo + y1, true
+				}
+			}
+		}
+
+		for k2 := -step + k2Start; k2 <= step-k2End; k2 += 2 {
+			i := offset + k2
+			var x2 int
+			if k2 == -step || (k2 != step && v2[i-1] < v2[i+1]) {
+				x2 = v2[i+1]
+			} else {
+				x2 = v2[i-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			v2[i] = x2
+			switch {
+			case x2 > n:
+				k2End += 2
+			case y2 > m:
+				k2Start += 2
+			case !front:
+				if j := offset + delta - k2; j >= 0 && j < length && v1[j] != -1 {
+					x1 := v1[j]
+					y1 := offset + x1 - j
+					if x1 >= n-x2 {
+						return aLo + x1, bLo + y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// UnifiedDiff 生成标准unified diff格式的差异，每个变更块保留3行上下文；无差异时返回nil
+func UnifiedDiff(aName, bName string, a, b []string) []string {
+	const context = 3
+	ops := diffLines(a, b)
+
+	// 记录每个操作之前已消耗的新旧行数，用于计算块头中的行号
+	aPos := make([]int, len(ops)+1)
+	bPos := make([]int, len(ops)+1)
+	var changes []int
+	for i, op := range ops {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if op.kind != '+' {
+			aPos[i+1]++
+		}
+		if op.kind != '-' {
+			bPos[i+1]++
+		}
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	out := []string{"--- " + aName, "+++ " + bName}
+	for c := 0; c < len(changes); {
+		start := changes[c] - context
+		if start < 0 {
+			start = 0
+		}
+		last := changes[c]
+		for c++; c < len(changes) && changes[c]-last <= 2*context; c++ {
+			last = changes[c]
+		}
+		end := last + context + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		aCount, bCount := aPos[end]-aPos[start], bPos[end]-bPos[start]
+		aStart, bStart := aPos[start]+1, bPos[start]+1
+		if aCount == 0 {
+			aStart--
+		}
+		if bCount == 0 {
+			bStart--
+		}
+		out = append(out, fmt.Sprintf("@@ -%d,%d +%d,%d @@", aStart, aCount, bStart, bCount))
+		for _, op := range ops[start:end] {
+			out = append(out, string(op.kind)+op.text)
+		}
+	}
+	return out
+}
+
+// DiffRow 是并排差异中的一行，Kind为' '(未变)、'-'(删除)、'+'(新增)或'~'(修改)。
+// 行号从1开始，该侧没有对应的行时为0
+type DiffRow struct {
+	Kind    byte
+	OldLine int
+	OldText string
+	NewLine int
+	NewText string
+}
+
+// SideBySide 生成两组行的并排差异: 相邻的删除与新增两两配对为修改行，多出的部分单独成行
+func SideBySide(a, b []string) []DiffRow {
+	var rows []DiffRow
+	var removed, added []string
+	aLine, bLine := 0, 0
+	flush := func() {
+		for i := 0; i < len(removed) || i < len(added); i++ {
+			var row DiffRow
+			switch {
+			case i < len(removed) && i < len(added):
+				row.Kind = '~'
+			case i < len(removed):
+				row.Kind = '-'
+			default:
+				row.Kind = '+'
+			}
+			if i < len(removed) {
+				aLine++
+				row.OldLine, row.OldText = aLine, removed[i]
+			}
+			if i < len(added) {
+				bLine++
+				row.NewLine, row.NewText = bLine, added[i]
+			}
+			rows = append(rows, row)
+		}
+		removed, added = removed[:0], added[:0]
+	}
+	for _, op := range diffLines(a, b) {
+		switch op.kind {
+		case '-':
+			removed = append(removed, op.text)
+		case '+':
+			added = append(added, op.text)
+		default:
+			flush()
+			aLine++
+			bLine++
+			rows = append(rows, DiffRow{Kind: ' ', OldLine: aLine, OldText: op.text, NewLine: bLine, NewText: op.text})
+		}
+	}
+	flush()
+	return rows
+}
@@ -0,0 +1,111 @@
+package propmerge
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// lcsLength 以动态规划计算最长公共子序列的长度，作为最短编辑序列的参照
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] > cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// checkDiff 检查编辑序列能还原出a与b，并且编辑次数最少
+func checkDiff(t *testing.T, a, b []string) {
+	t.Helper()
+	if edits, want := diffEdits(t, a, b), len(a)+len(b)-2*lcsLength(a, b); edits != want {
+		t.Fatalf("diffLines(%q, %q) has %d edits, want %d", a, b, edits, want)
+	}
+}
+
+// diffEdits 检查编辑序列能还原出a与b，返回其中删除与新增的行数
+func diffEdits(t *testing.T, a, b []string) int {
+	t.Helper()
+	ops := diffLines(a, b)
+	var gotA, gotB []string
+	edits := 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			gotA = append(gotA, op.text)
+		}
+		if op.kind != '-' {
+			gotB = append(gotB, op.text)
+		}
+		if op.kind != ' ' {
+			edits++
+		}
+	}
+	if strings.Join(gotA, "\n") != strings.Join(a, "\n") || strings.Join(gotB, "\n") != strings.Join(b, "\n") {
+		t.Fatalf("diffLines(%d lines, %d lines) does not reproduce the inputs", len(a), len(b))
+	}
+	return edits
+}
+
+func TestDiffLinesMinimal(t *testing.T) {
+	cases := [][2][]string{
+		{nil, nil},
+		{{"a"}, nil},
+		{nil, {"a"}},
+		{{"a"}, {"b"}},
+		{{"a", "b", "c"}, {"a", "b", "c"}},
+		{{"a", "b", "c", "a", "b", "b", "a"}, {"c", "b", "a", "b", "a", "c"}},
+		{{"x", "a", "y"}, {"a"}},
+		{{"a"}, {"x", "a", "y"}},
+	}
+	for _, c := range cases {
+		checkDiff(t, c[0], c[1])
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, rnd.Intn(40))
+		for i := range lines {
+			lines[i] = string(rune('a' + rnd.Intn(4)))
+		}
+		return lines
+	}
+	for i := 0; i < 2000; i++ {
+		checkDiff(t, random(), random())
+	}
+}
+
+// TestDiffLinesLarge 大文件中分散的修改仍求出最短序列；几乎完全不同的大文件超过diffMaxSteps后整体替换
+func TestDiffLinesLarge(t *testing.T) {
+	a := make([]string, 20000)
+	b := make([]string, 20000)
+	for i := range a {
+		a[i] = fmt.Sprintf("key%d=%d", i, i)
+		b[i] = a[i]
+	}
+	for i := 0; i < len(b); i += 50 {
+		b[i] = fmt.Sprintf("key%d=changed", i)
+	}
+	if edits := diffEdits(t, a, b); edits != 2*400 {
+		t.Errorf("scattered changes: %d edits, want %d", edits, 2*400)
+	}
+
+	for i := range b {
+		b[i] = fmt.Sprintf("new.key%d=%d", i, i)
+	}
+	b[10000] = a[5000]
+	if edits := diffEdits(t, a, b); edits < len(a)+len(b)-2 {
+		t.Errorf("unrelated files: %d edits, want at least %d", edits, len(a)+len(b)-2)
+	}
+}
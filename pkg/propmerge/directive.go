@@ -0,0 +1,77 @@
+package propmerge
+
+import "strings"
+
+// 旧文件中强制保留参数的注释指令，无需修改config-matcher.json
+const (
+	DirectiveKeep      = "config-keep"       // 保留其下方的第一个参数
+	DirectiveKeepBegin = "config-keep-begin" // 保留到config-keep-end为止的全部参数
+	DirectiveKeepEnd   = "config-keep-end"
+)
+
+// directive 返回注释行中的保留指令，不是指令时返回空字符串
+func directive(line string) string {
+	if !isComment(line) {
+		return ""
+	}
+	text := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#!"))
+	switch text {
+	case DirectiveKeep, DirectiveKeepBegin, DirectiveKeepEnd:
+		return text
+	}
+	return ""
+}
+
+// keepDirectives 逐行跟踪旧文件中的保留指令
+type keepDirectives struct {
+	pending bool // 遇到config-keep，尚未遇到其后的参数
+	block   int  // 所在config-keep-begin块的起始行号，不在块内时为0
+}
+
+// forced 处理一行(lineNum从1开始)，返回该行是否为指令要求保留的参数行
+func (d *keepDirectives) forced(m *Merger, lineNum int, line string) bool {
+	switch directive(line) {
+	case DirectiveKeep:
+		d.pending = true
+		return false
+	case DirectiveKeepBegin:
+		if d.block > 0 {
+			m.warnf("第%d行的%s嵌套在第%d行开始的块中，已忽略", lineNum, DirectiveKeepBegin, d.block)
+			return false
+		}
+		d.block = lineNum
+		return false
+	case DirectiveKeepEnd:
+		if d.block == 0 {
+			m.warnf("第%d行的%s没有对应的%s，已忽略", lineNum, DirectiveKeepEnd, DirectiveKeepBegin)
+		}
+		d.block = 0
+		return false
+	}
+	if isComment(line) || separator(line) == -1 {
+		return false
+	}
+	forced := d.pending || d.block > 0
+	d.pending = false
+	return forced
+}
+
+// finish 在文件结束时检查未闭合的块
+func (d *keepDirectives) finish(m *Merger) {
+	if d.block > 0 {
+		m.warnf("第%d行的%s没有对应的%s，保留到文件末尾", d.block, DirectiveKeepBegin, DirectiveKeepEnd)
+	}
+}
+
+// DirectiveLines 返回注释指令要求保留的参数行的行号(从1开始)
+func (m *Merger) DirectiveLines(lines []string) map[int]bool {
+	forced := make(map[int]bool)
+	var d keepDirectives
+	for i, line := range foldLines(lines, continuedLine) {
+		if d.forced(m, i+1, line) {
+			forced[i+1] = true
+		}
+	}
+	d.finish(m)
+	return forced
+}
@@ -0,0 +1,254 @@
+package propmerge
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// IsEnvFile 根据文件名判断是否为dotenv文件: .env、.env.local、.env.production等，或扩展名为.env
+func IsEnvFile(filename string) bool {
+	base := strings.ToLower(filepath.Base(filename))
+	return base == ".env" || strings.HasPrefix(base, ".env.") || filepath.Ext(base) == ".env"
+}
+
+// envEntry 描述dotenv文件中的一个KEY=value
+type envEntry struct {
+	key     string
+	export  bool   // 是否带export前缀
+	indent  string // 行首空白
+	start   int    // 键所在行(从0开始)
+	end     int    // 值(含跨行的引号值)之后的第一行
+	raw     string // 等号之后的原始值文本(含引号，不含行尾注释)，跨行的值以换行连接
+	value   string // 去掉引号并处理转义后的值
+	comment string // 值之后的行尾注释(含前导空白)
+}
+
+// parseEnv 解析dotenv内容: 支持export前缀、单引号、双引号与反引号值(可跨行)，
+// 未加引号的值中空白之后的#开始行尾注释；无法识别的行忽略
+func parseEnv(lines []string) []envEntry {
+	var entries []envEntry
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		e := envEntry{indent: line[:len(line)-len(trimmed)], start: i, end: i + 1}
+		rest := trimmed
+		if after, ok := strings.CutPrefix(rest, "export"); ok && after != "" && (after[0] == ' ' || after[0] == '\t') {
+			e.export, rest = true, strings.TrimLeft(after, " \t")
+		}
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			continue
+		}
+		e.key = strings.TrimSpace(rest[:eq])
+		if !isEnvKey(e.key) {
+			continue
+		}
+		value := strings.TrimLeft(rest[eq+1:], " \t")
+
+		if value != "" && strings.ContainsRune("\"'`", rune(value[0])) {
+			if end, text, tail, ok := scanEnvQuoted(lines, i, value); ok {
+				e.end, e.raw, e.comment = end, text, tail
+				e.value = unquoteEnv(text)
+				entries = append(entries, e)
+				i = end - 1
+				continue
+			}
+		}
+		e.raw, e.comment = splitEnvComment(value)
+		e.value = e.raw
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// isEnvKey 判断是否为有效的dotenv键名
+func isEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r == '_' || r == '.' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// scanEnvQuoted 从第i行的value开始查找引号值的结束位置，引号未闭合时继续查找后续行。
+// 返回值之后的第一行、含引号的值文本与闭合引号之后的内容；到文件末尾仍未闭合时ok为false
+func scanEnvQuoted(lines []string, i int, value string) (end int, text, tail string, ok bool) {
+	q := value[0]
+	text = value
+	pos := 1
+	for {
+		for ; pos < len(text); pos++ {
+			if q == '"' && text[pos] == '\\' {
+				pos++
+				continue
+			}
+			if text[pos] == q {
+				return i + 1, text[:pos+1], text[pos+1:], true
+			}
+		}
+		if i+1 >= len(lines) {
+			return 0, "", "", false
+		}
+		i++
+		text += "\n" + strings.TrimSuffix(lines[i], "\r")
+	}
+}
+
+// splitEnvComment 将未加引号的值拆分为值与行尾注释: 值开头的#或空白之后的#开始注释
+func splitEnvComment(value string) (string, string) {
+	if strings.HasPrefix(value, "#") {
+		return "", value
+	}
+	for i := 1; i < len(value); i++ {
+		if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+			raw := strings.TrimRight(value[:i], " \t")
+			return raw, value[len(raw):]
+		}
+	}
+	return strings.TrimRight(value, " \t"), ""
+}
+
+// unquoteEnv 去掉值两侧的引号: 双引号中处理\n、\r、\t、\"与\\转义，单引号与反引号中的内容原样保留
+func unquoteEnv(text string) string {
+	inner := text[1 : len(text)-1]
+	if text[0] != '"' {
+		return inner
+	}
+	var b strings.Builder
+	for i := 0; i < len(inner); i++ {
+		if inner[i] != '\\' || i+1 == len(inner) {
+			b.WriteByte(inner[i])
+			continue
+		}
+		i++
+		switch inner[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\\':
+			b.WriteByte(inner[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(inner[i])
+		}
+	}
+	return b.String()
+}
+
+// quoteEnv 将转换后的值写成dotenv值文本: quote为旧值使用的引号(未加引号时为0)。
+// 不含空白、引号、#与换行的值不加引号；其余优先沿用原来的引号，无法用单引号表示时使用双引号并转义
+func quoteEnv(value string, quote byte) string {
+	if quote == 0 && value != "" && !strings.ContainsAny(value, " \t\"'`#\n\r") {
+		return value
+	}
+	if quote != '"' && !strings.ContainsAny(value, "'\n\r") {
+		return "'" + value + "'"
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(value) + `"`
+}
+
+// text 返回该键值对写回文件的各行，key为写入的键名，raw为值文本
+func (e envEntry) text(key, raw string) []string {
+	prefix := e.indent
+	if e.export {
+		prefix += "export "
+	}
+	return strings.Split(prefix+key+"="+raw+e.comment, "\n")
+}
+
+// findEnvEntry 按键名查找第一个匹配的键值对
+func findEnvEntry(entries []envEntry, key string) (envEntry, bool) {
+	for _, e := range entries {
+		if e.key == key {
+			return e, true
+		}
+	}
+	return envEntry{}, false
+}
+
+// MergeEnv 从旧dotenv文件中提取命中保留规则的键，写入新文件: 新文件中已存在的键只替换值，
+// 保留新文件的export前缀、缩进与行尾注释，旧值连同其引号原样移植；缺失的键连同export前缀追加到文件末尾
+func (m *Merger) MergeEnv(old io.Reader, new io.Reader) (Result, error) {
+	oldLines, err := ReadLines(old)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+	newLines, err := ReadLines(new)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取新文件失败: %w"), err)
+	}
+	return m.MergeEnvLines(oldLines, newLines)
+}
+
+// MergeEnvLines 与MergeEnv相同，但直接处理已读取的行
+func (m *Merger) MergeEnvLines(oldLines, newLines []string) (Result, error) {
+	lines := append([]string(nil), newLines...)
+	var kept []envEntry
+	oldKeys := make(map[string]bool)
+	for _, o := range parseEnv(oldLines) {
+		if m.Matches(o.key + "=" + o.value) {
+			kept = append(kept, o)
+			oldKeys[o.key] = true
+		}
+	}
+
+	var results []KeyResult
+	for _, o := range kept {
+		m.keyDebugf(o.key, o.start+1, "", "找到匹配参数[行%d]: %s", o.start+1, o.key)
+		result := KeyResult{Key: o.key, OldValue: o.value}
+		if !m.renamePath(&result, oldKeys) {
+			results = append(results, result)
+			continue
+		}
+		raw := o.raw
+		if m.transformResult(&result, false) {
+			var quote byte
+			if raw != "" && (raw[0] == '"' || raw[0] == '\'') {
+				quote = raw[0]
+			}
+			raw = quoteEnv(result.OldValue, quote)
+		}
+
+		entries := parseEnv(lines)
+		var block []string
+		if n, ok := findEnvEntry(entries, result.Key); ok {
+			result.Action = ActionReplace
+			result.Line = n.start + 1
+			result.NewValue = n.value
+			if !m.renameCollision(&result) || !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			block = n.text(n.key, raw)
+			lines = append(lines[:n.start], append(block, lines[n.end:]...)...)
+			m.keyDebugf(result.Key, result.Line, ActionReplace, "替换参数[行%d]: %s", result.Line, result.Key)
+		} else {
+			result.Action = ActionAppend
+			result.Line = len(lines) + 1
+			if !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			block = o.text(result.Key, raw)
+			lines = append(lines, block...)
+			m.keyDebugf(result.Key, result.Line, ActionAppend, "追加参数[行%d]: %s", result.Line, result.Key)
+		}
+		m.trace(TraceEvent{Event: "action", Line: result.Line, Key: result.Key, Text: strings.TrimSpace(block[0]), Result: result.Action})
+		results = append(results, result)
+	}
+	merged := Result{Lines: lines, Keys: results}
+	return merged, m.checkCollisions(merged)
+}
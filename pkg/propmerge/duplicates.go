@@ -0,0 +1,115 @@
+package propmerge
+
+import (
+	"strings"
+)
+
+// 重复键处理策略
+const (
+	DuplicateFirstWins = "first-wins" // 保留第一次出现的行
+	DuplicateLastWins  = "last-wins"  // 保留最后一次出现的行(与java.util.Properties加载时的生效值一致)
+	DuplicateError     = "error"      // 中止且不写入
+)
+
+// ErrDuplicate 在重复键策略为error且检测到重复的键时返回
+var ErrDuplicate error = message("重复的键")
+
+// Duplicate 记录在同一文件中出现多次的键
+type Duplicate struct {
+	Source string `json:"source"`         // old: 旧文件, new: 新文件
+	Key    string `json:"key"`            // 第一次出现时的键名
+	Lines  []int  `json:"lines"`          // 各次出现的行号(从1开始)
+	Kept   int    `json:"kept,omitempty"` // 按策略保留的行号，仅报告时为0
+}
+
+// FindDuplicates 返回lines中出现多次的键及其行号，按第一次出现的顺序排列，并按策略标记保留的行。
+// source作为各项的Source，开启SpringRelaxed时按宽松绑定后的键比较
+func (m *Merger) FindDuplicates(source string, lines []string) []Duplicate {
+	finder := m.newDupFinder(source)
+	for i, line := range foldLines(lines, continuedLine) {
+		finder.add(i+1, line)
+	}
+	return finder.duplicates()
+}
+
+// dupFinder 逐行记录各键出现的行号，供FindDuplicates与流式扫描共用
+type dupFinder struct {
+	m           *Merger
+	source      string
+	occurrences map[string]*Duplicate
+	order       []string
+}
+
+// newDupFinder 创建来源为source的dupFinder
+func (m *Merger) newDupFinder(source string) *dupFinder {
+	return &dupFinder{m: m, source: source, occurrences: make(map[string]*Duplicate)}
+}
+
+// add 记录一行，lineNum从1开始
+func (f *dupFinder) add(lineNum int, line string) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || isComment(trimmed) || separator(trimmed) == -1 {
+		return
+	}
+	// 复制键名，避免超长行的内容因被键名引用而无法回收
+	key := strings.Clone(LineKey(trimmed))
+	lookup := f.m.lookupKey(key)
+	d, ok := f.occurrences[lookup]
+	if !ok {
+		d = &Duplicate{Source: f.source, Key: key}
+		f.occurrences[lookup] = d
+		f.order = append(f.order, lookup)
+	}
+	d.Lines = append(d.Lines, lineNum)
+}
+
+// duplicates 返回出现多次的键，并按策略标记保留的行
+func (f *dupFinder) duplicates() []Duplicate {
+	var dups []Duplicate
+	for _, lookup := range f.order {
+		d := f.occurrences[lookup]
+		if len(d.Lines) < 2 {
+			continue
+		}
+		switch f.m.opts.DuplicatePolicy {
+		case DuplicateFirstWins:
+			d.Kept = d.Lines[0]
+		case DuplicateLastWins:
+			d.Kept = d.Lines[len(d.Lines)-1]
+		}
+		f.m.keyDebugf(d.Key, 0, "", "检测到重复的键: %s (%s, 行%v)", d.Key, f.source, d.Lines)
+		dups = append(dups, *d)
+	}
+	return dups
+}
+
+// discarded 返回按策略需要丢弃的行号(从1开始)
+func discarded(dups []Duplicate) map[int]bool {
+	lines := make(map[int]bool)
+	for _, d := range dups {
+		if d.Kept == 0 {
+			continue
+		}
+		for _, n := range d.Lines {
+			if n != d.Kept {
+				lines[n] = true
+			}
+		}
+	}
+	return lines
+}
+
+// dropDuplicateLines 删除按策略丢弃的重复行
+func dropDuplicateLines(lines []string, dups []Duplicate) []string {
+	drop := discarded(dups)
+	if len(drop) == 0 {
+		return lines
+	}
+	result := make([]string, 0, len(lines)-len(drop))
+	for i, line := range lines {
+		if !drop[i+1] {
+			result = append(result, line)
+		}
+	}
+	return result
+}
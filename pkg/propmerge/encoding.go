@@ -0,0 +1,174 @@
+package propmerge
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// 文件编码
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF8BOM = "utf-8-bom" // 以UTF-8 BOM开头的UTF-8
+	EncodingGBK     = "gbk"
+	EncodingLatin1  = "iso-8859-1" // java.util.Properties默认的编码，每个字节对应一个字符
+)
+
+// utf8BOM 为UTF-8的字节顺序标记
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// Transcoder 在UTF-8与from/to指定的编码(如"GBK")之间转换内容。
+// 库本身不调用外部命令，GBK的转换由调用方通过SetTranscoder提供(如命令行工具调用系统中的iconv)
+type Transcoder func(data []byte, from, to string) ([]byte, error)
+
+var (
+	transcoder    Transcoder
+	inputEncoding string
+)
+
+// SetTranscoder 设置GBK转换使用的Transcoder；未设置时不识别GBK，非UTF-8的内容按ISO-8859-1原样读写
+func SetTranscoder(t Transcoder) {
+	transcoder = t
+}
+
+// SetInputEncoding 设置读取文件时使用的编码，为空时自动识别(见DetectEncoding)
+func SetInputEncoding(encoding string) {
+	inputEncoding = encoding
+}
+
+// ValidEncoding 判断是否为支持的编码名称
+func ValidEncoding(encoding string) bool {
+	switch encoding {
+	case EncodingUTF8, EncodingUTF8BOM, EncodingGBK, EncodingLatin1:
+		return true
+	}
+	return false
+}
+
+// DetectEncoding 识别内容的编码: 以BOM开头时为EncodingUTF8BOM，是合法的UTF-8时为EncodingUTF8，
+// 否则能按GBK解码时为EncodingGBK(需要SetTranscoder)，仍不能时为EncodingLatin1。
+// ISO-8859-1的内容也可能恰好是合法的GBK，此时应通过SetInputEncoding指定编码
+func DetectEncoding(data []byte) string {
+	_, encoding, _ := detect(data)
+	return encoding
+}
+
+// detect 识别内容的编码，需要按GBK试解码时一并返回解码结果
+func detect(data []byte) ([]byte, string, error) {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return data[len(utf8BOM):], EncodingUTF8BOM, nil
+	case utf8.Valid(data):
+		return data, EncodingUTF8, nil
+	}
+	if transcoder != nil {
+		if decoded, err := transcoder(data, "GBK", "UTF-8"); err == nil {
+			return decoded, EncodingGBK, nil
+		}
+	}
+	return decodeLatin1(data), EncodingLatin1, nil
+}
+
+// DetectFileEncoding 返回文件的编码，文件不存在或无法读取时返回EncodingUTF8；指定了输入编码时返回该编码
+func DetectFileEncoding(filename string) string {
+	if inputEncoding != "" {
+		return inputEncoding
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return EncodingUTF8
+	}
+	return DetectEncoding(data)
+}
+
+// Decode 将内容解码为不带BOM的UTF-8，同时返回原始编码；通过SetInputEncoding指定了编码时按该编码解码
+func Decode(data []byte) ([]byte, string, error) {
+	switch inputEncoding {
+	case "":
+		return detect(data)
+	case EncodingUTF8, EncodingUTF8BOM:
+		return bytes.TrimPrefix(data, utf8BOM), inputEncoding, nil
+	case EncodingLatin1:
+		return decodeLatin1(data), inputEncoding, nil
+	case EncodingGBK:
+		decoded, err := transcode(data, "GBK", "UTF-8")
+		return decoded, inputEncoding, err
+	}
+	return nil, inputEncoding, fmt.Errorf(tr("不支持的编码: %s"), inputEncoding)
+}
+
+// Encode 将UTF-8内容编码为指定编码
+func Encode(data []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", EncodingUTF8:
+		return data, nil
+	case EncodingUTF8BOM:
+		return append(append([]byte(nil), utf8BOM...), data...), nil
+	case EncodingGBK:
+		return transcode(data, "UTF-8", "GBK")
+	case EncodingLatin1:
+		return encodeLatin1(data)
+	}
+	return nil, fmt.Errorf(tr("不支持的编码: %s"), encoding)
+}
+
+// EncodeLines 将各行以sep结尾拼接后编码为指定编码
+func EncodeLines(lines []string, sep, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeLines(&buf, lines, sep); err != nil {
+		return nil, err
+	}
+	return Encode(buf.Bytes(), encoding)
+}
+
+// transcode 使用SetTranscoder设置的Transcoder转换编码
+func transcode(data []byte, from, to string) ([]byte, error) {
+	if transcoder == nil {
+		return nil, fmt.Errorf(tr("不支持%s与%s之间的转换: 未设置Transcoder"), from, to)
+	}
+	return transcoder(data, from, to)
+}
+
+// decodeLatin1 将ISO-8859-1内容解码为UTF-8，每个字节对应U+0000到U+00FF中的一个字符
+func decodeLatin1(data []byte) []byte {
+	buf := make([]byte, 0, len(data)+len(data)/4)
+	for _, b := range data {
+		buf = utf8.AppendRune(buf, rune(b))
+	}
+	return buf
+}
+
+// encodeLatin1 将UTF-8内容编码为ISO-8859-1，含有超出该编码的字符时返回错误
+func encodeLatin1(data []byte) ([]byte, error) {
+	buf := make([]byte, 0, len(data))
+	for i, r := range string(data) {
+		if r > 0xFF {
+			return nil, fmt.Errorf(tr("字符%q(第%d字节)无法以ISO-8859-1编码，properties文件可使用-unicode ascii写为\\uXXXX"), r, i+1)
+		}
+		buf = append(buf, byte(r))
+	}
+	return buf, nil
+}
+
+// writeEncoded 将各行以sep结尾按指定编码写入w，UTF-8直接流式写入
+func writeEncoded(w io.Writer, lines []string, sep, encoding string) error {
+	switch encoding {
+	case "", EncodingUTF8:
+		return writeLines(w, lines, sep)
+	case EncodingUTF8BOM:
+		if _, err := w.Write(utf8BOM); err != nil {
+			return fmt.Errorf(tr("写入文件失败: %w"), err)
+		}
+		return writeLines(w, lines, sep)
+	}
+	data, err := EncodeLines(lines, sep, encoding)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf(tr("写入文件失败: %w"), err)
+	}
+	return nil
+}
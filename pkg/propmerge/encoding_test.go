@@ -0,0 +1,73 @@
+package propmerge
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// TestDetectEncodingFallsBackToLatin1 非UTF-8的内容只在Transcoder能按GBK解码时视为GBK，否则按ISO-8859-1原样读写
+func TestDetectEncodingFallsBackToLatin1(t *testing.T) {
+	defer SetTranscoder(nil)
+	latin1 := []byte("name=caf\xe9\nsign=\xa9 2024\n")
+	failing := func([]byte, string, string) ([]byte, error) { return nil, errors.New("illegal input sequence") }
+	gbk := func([]byte, string, string) ([]byte, error) { return []byte("name=中文\n"), nil }
+
+	tests := []struct {
+		name       string
+		transcoder Transcoder
+		encoding   string
+		decoded    string
+	}{
+		{"no transcoder", nil, EncodingLatin1, "name=café\nsign=© 2024\n"},
+		{"GBK decode fails", failing, EncodingLatin1, "name=café\nsign=© 2024\n"},
+		{"GBK decode succeeds", gbk, EncodingGBK, "name=中文\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetTranscoder(tt.transcoder)
+			decoded, encoding, err := Decode(latin1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encoding != tt.encoding || string(decoded) != tt.decoded {
+				t.Errorf("Decode = %q, %s; want %q, %s", decoded, encoding, tt.decoded, tt.encoding)
+			}
+		})
+	}
+}
+
+// TestLatin1RoundTrip ISO-8859-1解码后再编码与原内容逐字节相同，超出该编码的字符报错
+func TestLatin1RoundTrip(t *testing.T) {
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	encoded, err := Encode(decodeLatin1(data), EncodingLatin1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encoded, data) {
+		t.Errorf("round trip = %q, want %q", encoded, data)
+	}
+	if _, err := Encode([]byte("name=中文"), EncodingLatin1); err == nil {
+		t.Error("Encode(中文, iso-8859-1) succeeded, want an error")
+	}
+}
+
+// TestInputEncoding 指定输入编码时不再自动识别
+func TestInputEncoding(t *testing.T) {
+	defer SetInputEncoding("")
+	SetInputEncoding(EncodingLatin1)
+	// "Ã©"在UTF-8中是合法的两个字节，按ISO-8859-1读取时为两个字符
+	decoded, encoding, err := Decode([]byte("a=\xc3\xa9"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoding != EncodingLatin1 || string(decoded) != "a=Ã©" {
+		t.Errorf("Decode = %q, %s; want \"a=Ã©\", %s", decoded, encoding, EncodingLatin1)
+	}
+	if plainLine(0, "a=\xc3\xa9") {
+		t.Error("plainLine reported a non-ASCII line as plain UTF-8 with -input-encoding iso-8859-1")
+	}
+}
@@ -0,0 +1,260 @@
+package propmerge
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// 合并结果中非ASCII字符的写法(Options.Unicode)
+const (
+	UnicodeKeep  = "keep"  // 保持各行原有的写法
+	UnicodeASCII = "ascii" // 非ASCII字符写作\uXXXX，与java.util.Properties.store的输出相同
+	UnicodeUTF8  = "utf8"  // 以\uXXXX转义的非ASCII字符写作UTF-8原文
+)
+
+// separator 按java.util.Properties的规则返回分隔键与值的分隔符的起始位置: 跳过行首空白后，键在第一个未转义的
+// =、:或空白(空格、\t、\f)处结束；键之后的空白、其后可选的一个=或:以及再之后的空白共同构成分隔符。
+// 空行与只有键、键后没有任何分隔符的行返回-1
+func separator(line string) int {
+	i := len(line) - len(strings.TrimLeft(line, " \t\f"))
+	for ; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':', ' ', '\t', '\f':
+			return i
+		}
+	}
+	return -1
+}
+
+// valueStart 返回从sep开始的分隔符之后值的起始位置
+func valueStart(line string, sep int) int {
+	i := sep
+	for i < len(line) && isPropertySpace(line[i]) {
+		i++
+	}
+	if i < len(line) && (line[i] == '=' || line[i] == ':') {
+		i++
+	}
+	for i < len(line) && isPropertySpace(line[i]) {
+		i++
+	}
+	return i
+}
+
+// isPropertySpace 判断是否为java.util.Properties中分隔键与值的空白
+func isPropertySpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\f'
+}
+
+// escapedAt 判断s[i]之前是否有奇数个连续的反斜杠，即s[i]被转义
+func escapedAt(s string, i int) bool {
+	n := 0
+	for i > 0 && s[i-1] == '\\' {
+		n++
+		i--
+	}
+	return n%2 == 1
+}
+
+// trimEscaped 去掉首尾的空白，但保留以反斜杠转义的末尾空白(如"a\ ")
+func trimEscaped(s string) string {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	trimmed := strings.TrimRightFunc(s, unicode.IsSpace)
+	if len(trimmed) < len(s) && escapedAt(s, len(trimmed)) {
+		_, size := utf8.DecodeRuneInString(s[len(trimmed):])
+		trimmed = s[:len(trimmed)+size]
+	}
+	return trimmed
+}
+
+// unescape 按Java properties的规则还原转义: \uXXXX(含UTF-16代理对)、\t、\n、\r、\f，
+// 其余字符前的反斜杠直接去掉(如\=、\:、\ 、\\)。格式错误的\u保持原样
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if i+1 == len(s) {
+			// 末尾单独的反斜杠与java.util.Properties一样忽略
+			break
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			r, n, ok := decodeUnicodeEscape(s[i-1:])
+			if !ok {
+				b.WriteString(`\u`)
+				continue
+			}
+			b.WriteRune(r)
+			i += n - 2
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// decodeUnicodeEscape 解码s开头的\uXXXX，高代理项后紧跟低代理项的\uXXXX时合并为一个字符。
+// 返回字符与消耗的字节数
+func decodeUnicodeEscape(s string) (rune, int, bool) {
+	hex4 := func(s string) (rune, bool) {
+		if len(s) < 6 || s[0] != '\\' || s[1] != 'u' {
+			return 0, false
+		}
+		v, err := strconv.ParseUint(s[2:6], 16, 16)
+		return rune(v), err == nil
+	}
+	r, ok := hex4(s)
+	if !ok {
+		return 0, 0, false
+	}
+	if utf16.IsSurrogate(r) {
+		if low, ok := hex4(s[6:]); ok {
+			if pair := utf16.DecodeRune(r, low); pair != unicode.ReplacementChar {
+				return pair, 12, true
+			}
+		}
+	}
+	return r, 6, true
+}
+
+// writeUnicodeEscape 将字符写作\uXXXX，基本平面以外的字符写作代理对
+func writeUnicodeEscape(b *strings.Builder, r rune) {
+	if r > 0xFFFF {
+		hi, lo := utf16.EncodeRune(r)
+		fmt.Fprintf(b, `\u%04X\u%04X`, hi, lo)
+		return
+	}
+	fmt.Fprintf(b, `\u%04X`, r)
+}
+
+// EscapeValue 按Java properties的规则转义写入文件的值: 反斜杠、换行等控制字符与开头的空白；
+// ascii为true时非ASCII字符写作\uXXXX
+func EscapeValue(value string, ascii bool) string {
+	return escape(value, false, ascii)
+}
+
+// EscapeKey 与EscapeValue相同，同时转义键中的=、:、空白与开头的#、!
+func EscapeKey(key string, ascii bool) string {
+	return escape(key, true, ascii)
+}
+
+func escape(s string, key, ascii bool) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == ' ' && (key || i == 0):
+			b.WriteString(`\ `)
+		case key && (r == '=' || r == ':' || (i == 0 && (r == '#' || r == '!'))):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || (ascii && r > 0x7e):
+			writeUnicodeEscape(&b, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// normalizeUnicode 按mode改写一行(可为以"\n"连接的逻辑行)中非ASCII字符的写法。
+// UnicodeASCII转义包括注释在内的全部非ASCII字符；UnicodeUTF8只还原参数行中可打印的非ASCII字符，
+// ASCII字符的转义与注释保持不变
+func normalizeUnicode(line, mode string) string {
+	switch mode {
+	case UnicodeASCII:
+		if isASCII(line) {
+			return line
+		}
+		var b strings.Builder
+		for _, r := range line {
+			if r > 0x7e {
+				writeUnicodeEscape(&b, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+		return b.String()
+	case UnicodeUTF8:
+		if isComment(line) || !strings.Contains(line, `\u`) {
+			return line
+		}
+		var b strings.Builder
+		for i := 0; i < len(line); i++ {
+			if line[i] != '\\' || i+1 == len(line) {
+				b.WriteByte(line[i])
+				continue
+			}
+			if line[i+1] == 'u' {
+				if r, n, ok := decodeUnicodeEscape(line[i:]); ok && r > 0x7e && unicode.IsPrint(r) {
+					b.WriteRune(r)
+					i += n - 1
+					continue
+				}
+			}
+			// 其他转义原样保留
+			b.WriteString(line[i : i+2])
+			i++
+		}
+		return b.String()
+	}
+	return line
+}
+
+// isASCII 判断字符串是否只含ASCII字符
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize 按Options.Unicode改写合并结果中各行非ASCII字符的写法
+func (m *Merger) normalize(lines []string) {
+	if m.opts.Unicode == "" || m.opts.Unicode == UnicodeKeep {
+		return
+	}
+	for i, line := range lines {
+		lines[i] = normalizeUnicode(line, m.opts.Unicode)
+	}
+}
+
+// escapeASCII 判断写入的键与值是否需要将非ASCII字符转义
+func (m *Merger) escapeASCII() bool {
+	return m.opts.Unicode == UnicodeASCII
+}
@@ -0,0 +1,116 @@
+package propmerge
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Export 提取旧文件中的保留参数并转换为目标格式(env/json/yaml)的内容，返回内容与参数数量
+func (m *Merger) Export(oldLines []string, format string) ([]string, int, error) {
+	keep, _ := m.Extract(oldLines)
+	var props []Property
+	for _, lineNum := range sortedLineNums(keep) {
+		line := keep[lineNum]
+		if isComment(line) || separator(line) == -1 {
+			// 正则规则按整行匹配，可能命中注释或没有分隔符的行，这些行不是参数
+			continue
+		}
+		props = append(props, Property{Key: LineKey(line), Value: LineValue(line), Line: lineNum})
+	}
+
+	var lines []string
+	var err error
+	switch format {
+	case "env":
+		lines = formatEnv(props)
+	case "json":
+		lines, err = formatJSON(props)
+	case "yaml":
+		lines, err = formatYAML(props)
+	default:
+		return nil, 0, fmt.Errorf(tr("不支持的导出格式: %s"), format)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return lines, len(props), nil
+}
+
+// EnvKey 将属性键转换为环境变量名，如 spring.datasource.url -> SPRING_DATASOURCE_URL
+func EnvKey(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z':
+			b.WriteRune(r - ('a' - 'A'))
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// envValue 在值包含特殊字符时加双引号并转义，避免被shell或dotenv解析器改写
+func envValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\"'`$\\#=\n\r") {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`", "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(value) + `"`
+}
+
+func formatEnv(props []Property) []string {
+	lines := make([]string, 0, len(props))
+	for _, p := range props {
+		lines = append(lines, EnvKey(p.Key)+"="+envValue(p.Value))
+	}
+	return lines
+}
+
+// jsonString 按JSON规则编码字符串，不转义HTML字符
+func jsonString(value string) (string, error) {
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return "", fmt.Errorf(tr("编码值失败: %w"), err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// formatJSON 按旧文件中的顺序输出扁平的JSON对象
+func formatJSON(props []Property) ([]string, error) {
+	lines := []string{"{"}
+	for i, p := range props {
+		k, err := jsonString(p.Key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := jsonString(p.Value)
+		if err != nil {
+			return nil, err
+		}
+		sep := ","
+		if i == len(props)-1 {
+			sep = ""
+		}
+		lines = append(lines, "  "+k+": "+v+sep)
+	}
+	return append(lines, "}"), nil
+}
+
+// formatYAML 输出扁平的点分键YAML，值使用双引号字符串以避免类型推断和转义问题
+func formatYAML(props []Property) ([]string, error) {
+	lines := make([]string, 0, len(props))
+	for _, p := range props {
+		v, err := jsonString(p.Value)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, p.Key+": "+v)
+	}
+	return lines, nil
+}
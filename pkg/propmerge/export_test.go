@@ -0,0 +1,121 @@
+package propmerge
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// exportLines 为包含各种特殊字符的旧文件，续行、转义与unicode转义都按properties规则解析后再导出
+var exportLines = []string{
+	`quote=say "hi" 'x'`,
+	"dollar=$HOME ${X} `cmd`",
+	`newline=line1\nline2`,
+	`cr=a\rb`,
+	`unicode=\u4e2d\u6587`,
+	"raw=中文",
+	`path=C:\\dir\\x`,
+	`hash=a#b`,
+	`url=jdbc:mysql://h:3306/db?a=1&b=2`,
+	`continued=a \`,
+	`  b`,
+	`empty=`,
+	`spring.data-source.url=jdbc:h2:mem`,
+	`my\:key=v`,
+	"# comment=ignored",
+}
+
+func TestExportEnv(t *testing.T) {
+	m, err := New(Options{Pattern: `.`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, count, err := m.Export(exportLines, "env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`QUOTE="say \"hi\" 'x'"`,
+		"DOLLAR=\"\\$HOME \\${X} \\`cmd\\`\"",
+		`NEWLINE="line1\nline2"`,
+		`CR="a\rb"`,
+		"UNICODE=中文",
+		"RAW=中文",
+		`PATH="C:\\dir\\x"`,
+		`HASH="a#b"`,
+		`URL="jdbc:mysql://h:3306/db?a=1&b=2"`,
+		`CONTINUED="a b"`,
+		`EMPTY=""`,
+		"SPRING_DATA_SOURCE_URL=jdbc:h2:mem",
+		"MY_KEY=v",
+	}
+	if count != len(want) || strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Export(env) = %d keys\n%s\nwant %d keys\n%s", count, strings.Join(lines, "\n"), len(want), strings.Join(want, "\n"))
+	}
+	for _, line := range lines {
+		if strings.ContainsAny(line, "\n\r") {
+			t.Errorf("env line %q contains a raw line break", line)
+		}
+	}
+}
+
+func TestEnvKey(t *testing.T) {
+	tests := map[string]string{
+		"spring.datasource.url":   "SPRING_DATASOURCE_URL",
+		"server.max-http-header":  "SERVER_MAX_HTTP_HEADER",
+		"app.list[0].name":        "APP_LIST_0__NAME",
+		"my:key":                  "MY_KEY",
+		"Already_UPPER_9":         "ALREADY_UPPER_9",
+		"web.back.upLoadPath":     "WEB_BACK_UPLOADPATH",
+		"feature.toggle-name.on":  "FEATURE_TOGGLE_NAME_ON",
+		"中文.key":                  "___KEY",
+		"logging.level.org.x-y.z": "LOGGING_LEVEL_ORG_X_Y_Z",
+	}
+	for key, want := range tests {
+		if got := EnvKey(key); got != want {
+			t.Errorf("EnvKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	m, err := New(Options{Pattern: `.`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, count, err := m.Export(exportLines, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal([]byte(strings.Join(lines, "\n")), &got); err != nil {
+		t.Fatalf("Export(json) is not valid JSON: %v\n%s", err, strings.Join(lines, "\n"))
+	}
+	want := map[string]string{
+		"quote":                  `say "hi" 'x'`,
+		"dollar":                 "$HOME ${X} `cmd`",
+		"newline":                "line1\nline2",
+		"cr":                     "a\rb",
+		"unicode":                "中文",
+		"raw":                    "中文",
+		"path":                   `C:\dir\x`,
+		"hash":                   "a#b",
+		"url":                    "jdbc:mysql://h:3306/db?a=1&b=2",
+		"continued":              "a b",
+		"empty":                  "",
+		"spring.data-source.url": "jdbc:h2:mem",
+		"my:key":                 "v",
+	}
+	if count != len(want) || len(got) != len(want) {
+		t.Errorf("Export(json) = %d keys %v, want %d keys", count, got, len(want))
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("Export(json)[%q] = %q, want %q", key, got[key], value)
+		}
+	}
+	// 键按旧文件中的顺序输出，HTML字符不转义
+	if lines[1] != `  "quote": "say \"hi\" 'x'",` || !strings.Contains(strings.Join(lines, "\n"), "a=1&b=2") {
+		t.Errorf("Export(json) order or escaping changed:\n%s", strings.Join(lines, "\n"))
+	}
+}
@@ -0,0 +1,385 @@
+package propmerge
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// MergeFile 将旧文件中的保留参数合并到新文件并写回新文件。
+// 能够流式处理时(见streamMerge)无需将任一文件整个载入内存，内存占用只与键的数量和最长的行有关，此时Result.Lines为空
+func (m *Merger) MergeFile(oldFile, newFile string) (Result, error) {
+	return m.MergeFileContext(context.Background(), oldFile, newFile)
+}
+
+// MergeFileContext 与MergeFile相同，但在ctx取消或超时后中止合并并返回包含ctx.Err()的错误。
+// 扫描大文件时每隔cancelCheckLines行检查一次ctx；写入前中止时新文件保持不变，流式写入的临时文件随即删除
+func (m *Merger) MergeFileContext(ctx context.Context, oldFile, newFile string) (Result, error) {
+	var oldLines []string
+	var keep map[int]string
+	var skipped []string
+	if !m.opts.AutoPreserve {
+		var oldDups []Duplicate
+		var oldCount int
+		var plain bool
+		var err error
+		if keep, skipped, oldDups, oldCount, plain, err = m.extractFile(ctx, oldFile); err != nil {
+			return Result{}, fmt.Errorf(tr("读取旧文件失败: %w"), err)
+		}
+		if !plain {
+			// GBK或带BOM的旧文件先整体解码
+			if oldLines, err = ReadFile(oldFile); err != nil {
+				return Result{}, fmt.Errorf(tr("读取旧文件失败: %w"), err)
+			}
+			keep, skipped = m.Extract(oldLines)
+			oldDups = m.FindDuplicates("old", oldLines)
+			oldCount = len(oldLines)
+		}
+		keys, newDups, ok, err := m.streamMerge(ctx, newFile, m.withSecrets(keep, oldCount))
+		if err != nil {
+			return Result{}, err
+		}
+		if ok {
+			return Result{Keys: keys, SkippedDefaults: skipped, Duplicates: append(oldDups, newDups...)}, nil
+		}
+	}
+
+	if oldLines == nil {
+		var err error
+		if oldLines, err = ReadFile(oldFile); err != nil {
+			return Result{}, fmt.Errorf(tr("读取旧文件失败: %w"), err)
+		}
+	}
+	newLines, err := ReadFile(newFile)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取新文件失败: %w"), err)
+	}
+	if m.opts.AutoPreserve {
+		keep = m.AutoKeep(oldLines, newLines)
+	}
+	result, err := m.mergeKept(oldLines, newLines, keep, skipped)
+	if err != nil {
+		return result, err
+	}
+	if err := ctx.Err(); err != nil {
+		return result, fmt.Errorf(tr("合并已中止: %w"), err)
+	}
+
+	// 写入更新后的文件
+	if err := WriteFileEncoding(newFile, result.Lines, m.opts.OutputEncoding); err != nil {
+		return result, fmt.Errorf(tr("写入更新文件失败: %w"), err)
+	}
+	return result, nil
+}
+
+// cancelCheckLines 为流式扫描时检查ctx的间隔行数
+const cancelCheckLines = 4096
+
+// lineCanceled 在第i行(从0开始)需要检查时返回ctx的错误
+func lineCanceled(ctx context.Context, i int) error {
+	if i%cancelCheckLines != 0 {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// forEachLine 逐行读取r并调用fn(行下标从0开始)，行尾的\n与\r\n不包含在行内容中。
+// 与bufio.Scanner不同，单行长度不受缓冲区大小限制(如内嵌data URI的超长值)
+func forEachLine(r io.Reader, fn func(i int, line string) error) error {
+	reader := bufio.NewReaderSize(r, bufferSize)
+	for i := 0; ; i++ {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line == "" && err == io.EOF {
+			return nil
+		}
+		line = strings.TrimSuffix(line, "\n")
+		line = strings.TrimSuffix(line, "\r")
+		if ferr := fn(i, line); ferr != nil {
+			return ferr
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// ScanFile 打开文件并逐行调用fn(行下标从0开始)，不将文件整个载入内存，单行长度也不受限制。
+// 内容按原样传给fn，不做编码转换
+func ScanFile(filename string, fn func(i int, line string) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf(tr("打开文件失败: %w"), err)
+	}
+	defer file.Close()
+	if err := forEachLine(file, fn); err != nil {
+		return fmt.Errorf(tr("扫描文件失败: %w"), err)
+	}
+	return nil
+}
+
+// plainLine 判断第i行(从0开始)是否为不带BOM的UTF-8，不是时文件需要先整体解码。
+// 通过SetInputEncoding指定了其他编码时只有ASCII的行无需解码
+func plainLine(i int, line string) bool {
+	switch inputEncoding {
+	case "", EncodingUTF8:
+	default:
+		return isASCII(line)
+	}
+	return utf8.ValidString(line) && !(i == 0 && strings.HasPrefix(line, string(utf8BOM)))
+}
+
+// errNotPlain 在扫描中发现文件需要整体读取时中止扫描
+var errNotPlain = errors.New("not plain utf-8")
+
+// extractFile 流式扫描旧文件，返回保留参数、因等于默认值而跳过的键、旧文件中的重复键与旧文件的行数。
+// 文件不是不带BOM的UTF-8或含有以反斜杠续行的参数时plain为false，此时不做提取，由调用方整体读取后处理
+func (m *Merger) extractFile(ctx context.Context, filename string) (keep map[int]string, skipped []string, dups []Duplicate, count int, plain bool, err error) {
+	err = ScanFile(filename, func(i int, line string) error {
+		if err := lineCanceled(ctx, i); err != nil {
+			return err
+		}
+		if !plainLine(i, line) || continues(line) {
+			return errNotPlain
+		}
+		count = i + 1
+		return nil
+	})
+	if errors.Is(err, errNotPlain) {
+		return nil, nil, nil, 0, false, nil
+	}
+	if err != nil {
+		return nil, nil, nil, 0, false, err
+	}
+
+	x := m.newExtractor()
+	finder := m.newDupFinder("old")
+	err = ScanFile(filename, func(i int, line string) error {
+		if err := lineCanceled(ctx, i); err != nil {
+			return err
+		}
+		x.add(i+1, line)
+		finder.add(i+1, line)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, 0, false, err
+	}
+	keep, skipped = x.finish()
+	return keep, skipped, finder.duplicates(), count, true, nil
+}
+
+// indexKeys 扫描文件建立键到行号(从0开始)的索引，重复的键以第一次出现为准，并返回文件的行数与重复的键。
+// 文件不是不带BOM的UTF-8或含有以反斜杠续行的参数时plain为false，此时无法逐行原样写出
+func (m *Merger) indexKeys(ctx context.Context, filename string) (index map[string]int, count int, dups []Duplicate, plain bool, err error) {
+	index = make(map[string]int)
+	dupIndex := make(map[string]int)
+	plain = true
+	err = ScanFile(filename, func(i int, line string) error {
+		if err := lineCanceled(ctx, i); err != nil {
+			return err
+		}
+		count = i + 1
+		if !plainLine(i, line) || continues(line) {
+			plain = false
+		}
+		if separator(line) == -1 || isComment(line) {
+			return nil
+		}
+		// 复制键名，避免超长行的内容因被索引引用而无法回收
+		key := strings.Clone(m.lookupKey(LineKey(line)))
+		first, ok := index[key]
+		if !ok {
+			index[key] = i
+			return nil
+		}
+		if d, ok := dupIndex[key]; ok {
+			dups[d].Lines = append(dups[d].Lines, i+1)
+			return nil
+		}
+		dupIndex[key] = len(dups)
+		dups = append(dups, Duplicate{Source: "new", Key: strings.Clone(LineKey(line)), Lines: []int{first + 1, i + 1}})
+		return nil
+	})
+	if err != nil {
+		return nil, 0, nil, false, err
+	}
+	return index, count, dups, plain, nil
+}
+
+// streamInsert 是流式合并中插入的一行，pos为其在合并结果中的位置(从0开始)
+type streamInsert struct {
+	pos  int
+	line string
+}
+
+// streamMerge 是流式合并路径: 先扫描一遍新文件建立键索引，按旧文件行号从小到大(与apply相同的顺序)确定
+// 每个保留参数替换的行或插入的位置，再逐行读取新文件写入临时文件，在对应位置替换或插入，不构建整个行切片。
+// 需要重命名、来源注释、逐项确认、三方比较、检测冲突、处理重复键、转换编码、改写非ASCII字符的写法或渲染值模板，或者插入时需要随带注释、
+// 按同前缀参数定位，或者含有以反斜杠续行的参数时返回ok=false，由通用路径处理
+func (m *Merger) streamMerge(ctx context.Context, filename string, keep map[int]string) (results []KeyResult, dups []Duplicate, ok bool, err error) {
+	if m.opts.Provenance != nil || len(m.opts.Renames) > 0 || len(m.opts.Moves) > 0 || m.mergesLists() || m.opts.Confirm != nil || m.opts.Base != nil || m.opts.DetectConflicts || m.opts.DuplicatePolicy != "" {
+		return nil, nil, false, nil
+	}
+	if m.opts.OutputEncoding != "" && m.opts.OutputEncoding != EncodingUTF8 {
+		return nil, nil, false, nil
+	}
+	if (m.opts.Unicode != "" && m.opts.Unicode != UnicodeKeep) || m.opts.TemplateData != nil {
+		return nil, nil, false, nil
+	}
+
+	index, count, dups, plain, err := m.indexKeys(ctx, filename)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf(tr("读取新文件失败: %w"), err)
+	}
+	if !plain {
+		return nil, nil, false, nil
+	}
+
+	missing := make(map[string]bool)
+	for _, line := range keep {
+		if strings.Contains(line, "\n") {
+			// 以反斜杠续行的参数由通用路径按逻辑行写入
+			return nil, nil, false, nil
+		}
+		key := m.lookupKey(LineKey(line))
+		if _, found := index[key]; found {
+			continue
+		}
+		if missing[key] || m.opts.PreserveComments || m.opts.InsertStrategy == InsertAnchor {
+			return nil, nil, false, nil
+		}
+		missing[key] = true
+	}
+
+	replacements := make(map[int]string, len(keep))
+	var inserts []streamInsert
+	var replaced []int // 各替换结果在results中的下标
+	length := count
+	for _, oldLineNum := range sortedLineNums(keep) {
+		oldLine := keep[oldLineNum]
+		key := LineKey(oldLine)
+		result := KeyResult{Key: key, OldValue: LineValue(oldLine), Secret: m.isSecret(key)}
+		i, found := index[m.lookupKey(key)]
+		if !result.Secret && m.transformResult(&result, false) {
+			oldLine = withLineValue(oldLine, EscapeValue(result.OldValue, m.escapeASCII()))
+		}
+
+		if found {
+			// 行号为处理到该参数时其在结果中的位置，与通用路径一致
+			pos := i
+			for _, ins := range inserts {
+				if ins.pos <= pos {
+					pos++
+				}
+			}
+			result.Action, result.Line = ActionReplace, pos+1
+			replacements[i] = oldLine
+			replaced = append(replaced, len(results))
+			results = append(results, result)
+			continue
+		}
+
+		m.trace(TraceEvent{Event: "lookup", Key: key, Result: "not-found"})
+		policy := m.obsoletePolicy(result)
+		if policy == ObsoleteDrop {
+			m.drop(&result)
+			results = append(results, result)
+			continue
+		}
+		if policy == ObsoleteComment {
+			oldLine = obsoleteComment(oldLine)
+		}
+		pos := length
+		result.Action = ActionAppend
+		if m.opts.InsertStrategy != InsertAppend && policy != ObsoleteAppend && oldLineNum <= length {
+			pos = oldLineNum - 1
+			result.Action = ActionInsert
+		}
+		if policy == ObsoleteComment {
+			result.Action = ActionComment
+		}
+		m.keyDebugf(key, pos+1, result.Action, insertMessage(result.Action), pos+1, key)
+		for j := range inserts {
+			if inserts[j].pos >= pos {
+				inserts[j].pos++
+			}
+		}
+		inserts = append(inserts, streamInsert{pos: pos, line: oldLine})
+		sort.Slice(inserts, func(a, b int) bool { return inserts[a].pos < inserts[b].pos })
+		length++
+		result.Line = pos + 1
+		m.trace(TraceEvent{Event: "action", Line: result.Line, Key: key, Text: oldLine, Result: result.Action})
+		results = append(results, result)
+	}
+
+	m.debugf("使用流式合并: %s (替换%d个参数，插入%d个参数)", filename, len(replacements), len(inserts))
+	newValues, err := m.streamWrite(ctx, filename, replacements, inserts)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf(tr("写入更新文件失败: %w"), err)
+	}
+	for _, r := range replaced {
+		i := index[m.lookupKey(results[r].Key)]
+		results[r].NewValue = newValues[i]
+		m.trace(TraceEvent{Event: "lookup", Line: i + 1, Key: results[r].Key, Result: "found"})
+		m.trace(TraceEvent{Event: "action", Line: results[r].Line, Key: results[r].Key, Text: replacements[i], Result: ActionReplace})
+	}
+	return results, dups, true, nil
+}
+
+// streamWrite 逐行读取文件，替换指定行(新文件中的下标)并在指定位置插入行，经AtomicWrite写入临时文件后
+// 重命名覆盖原文件，ctx取消时中止且不覆盖原文件。返回被替换各行在新文件中的原值
+func (m *Merger) streamWrite(ctx context.Context, filename string, replacements map[int]string, inserts []streamInsert) (map[int]string, error) {
+	sep := DetectLineSeparator(filename)
+	newValues := make(map[int]string, len(replacements))
+	err := AtomicWrite(filename, func(w io.Writer) error {
+		writer := bufio.NewWriterSize(w, bufferSize)
+		out, next := 0, 0
+		write := func(line string) error {
+			if _, err := writer.WriteString(line + sep); err != nil {
+				return fmt.Errorf(tr("写入文件失败: %w"), err)
+			}
+			out++
+			return nil
+		}
+		err := ScanFile(filename, func(i int, line string) error {
+			if err := lineCanceled(ctx, i); err != nil {
+				return err
+			}
+			for ; next < len(inserts) && inserts[next].pos == out; next++ {
+				if err := write(inserts[next].line); err != nil {
+					return err
+				}
+			}
+			if replacement, ok := replacements[i]; ok {
+				newValues[i] = LineValue(line)
+				m.keyDebugf(LineKey(line), out+1, ActionReplace, "替换参数[行%d]: %s", out+1, LineKey(line))
+				line = m.replacementLine(line, replacement)
+			}
+			return write(line)
+		})
+		if err != nil {
+			return err
+		}
+		// 追加到末尾的参数
+		for ; next < len(inserts); next++ {
+			if err := write(inserts[next].line); err != nil {
+				return err
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return fmt.Errorf(tr("刷新缓冲区失败: %w"), err)
+		}
+		// 重命名覆盖原文件前最后检查一次，中止时临时文件由AtomicWrite删除
+		return ctx.Err()
+	})
+	return newValues, err
+}
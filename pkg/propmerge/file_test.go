@@ -0,0 +1,117 @@
+package propmerge
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// benchLines 为基准测试生成约5000行的Spring Boot风格配置: 每个模块带分节注释、空行、
+// 数据源、Redis、线程池与日志级别等参数，每个模块中有4个参数命中保留规则
+func benchLines(env string) []string {
+	var lines []string
+	for i := 0; len(lines) < 5000; i++ {
+		lines = append(lines,
+			"",
+			"#############################################",
+			fmt.Sprintf("# module-%d", i),
+			"#############################################",
+			fmt.Sprintf("module%d.spring.datasource.url=jdbc:mysql://%s-db-%d.internal:3306/app_%d?useUnicode=true&characterEncoding=utf8", i, env, i, i),
+			fmt.Sprintf("module%d.spring.datasource.username=app_%s_%d", i, env, i),
+			fmt.Sprintf("module%d.spring.datasource.password=%s-secret-%d", i, env, i),
+			fmt.Sprintf("module%d.spring.datasource.hikari.maximum-pool-size=%d", i, 10+i%20),
+			fmt.Sprintf("module%d.spring.redis.host=%s-redis-%d.internal", i, env, i),
+			fmt.Sprintf("module%d.spring.redis.port=6379", i),
+			fmt.Sprintf("module%d.server.tomcat.threads.max=200", i),
+			fmt.Sprintf("# module%d.feature.beta=false", i),
+			fmt.Sprintf("module%d.logging.level.com.example.module%d=INFO", i, i),
+			fmt.Sprintf("module%d.web.back.upLoadPath=/data/%s/upload/%d", i, env, i),
+		)
+	}
+	return lines
+}
+
+// benchmarkMergeFile 在每次迭代前恢复新文件，只计合并与写回的耗时
+func benchmarkMergeFile(b *testing.B, opts Options) {
+	dir := b.TempDir()
+	oldFile := filepath.Join(dir, "old.properties")
+	newFile := filepath.Join(dir, "new.properties")
+	template := []byte(strings.Join(benchLines("tmpl"), "\n") + "\n")
+	if err := os.WriteFile(oldFile, []byte(strings.Join(benchLines("prod"), "\n")+"\n"), 0o600); err != nil {
+		b.Fatal(err)
+	}
+	opts.Pattern = `^module\d+\.(spring\.datasource\.(url|username|password)|spring\.redis\.host)=`
+	m, err := New(opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(template)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		if err := os.WriteFile(newFile, template, 0o600); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		result, err := m.MergeFile(oldFile, newFile)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(result.Keys) == 0 {
+			b.Fatal("no preserved keys")
+		}
+	}
+}
+
+// BenchmarkMergeFileStream 所有保留参数都在新文件中原位替换，走流式路径
+func BenchmarkMergeFileStream(b *testing.B) {
+	benchmarkMergeFile(b, Options{})
+}
+
+// BenchmarkMergeFileGeneral 与BenchmarkMergeFileStream的输入相同，配置了(不会命中的)重命名规则，走通用路径
+func BenchmarkMergeFileGeneral(b *testing.B) {
+	benchmarkMergeFile(b, Options{Renames: map[string]string{"unused.key": "unused.renamed"}})
+}
+
+// TestMergeFileStreamMatchesGeneral 流式路径与通用路径对同一输入写出相同的结果
+func TestMergeFileStreamMatchesGeneral(t *testing.T) {
+	dir := t.TempDir()
+	oldFile := filepath.Join(dir, "old.properties")
+	if err := os.WriteFile(oldFile, []byte(strings.Join(benchLines("prod"), "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var outputs []string
+	for i, opts := range []Options{{}, {Renames: map[string]string{"unused.key": "unused.renamed"}}} {
+		newFile := filepath.Join(dir, fmt.Sprintf("new%d.properties", i))
+		if err := os.WriteFile(newFile, []byte(strings.Join(benchLines("tmpl"), "\n")+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		opts.Pattern = `^module\d+\.spring\.redis\.host=`
+		m, err := New(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := m.MergeFile(oldFile, newFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if streamed := result.Lines == nil; streamed != (i == 0) {
+			t.Fatalf("options %d: streamed = %v", i, streamed)
+		}
+		data, err := os.ReadFile(newFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, string(data))
+	}
+	if outputs[0] != outputs[1] {
+		t.Error("stream and general paths wrote different files")
+	}
+	if !strings.Contains(outputs[0], "module0.spring.redis.host=prod-redis-0.internal\n") || !strings.Contains(outputs[0], "module0.spring.redis.port=6379\n") {
+		t.Error("merged file is missing the preserved value or template lines")
+	}
+}
@@ -0,0 +1,547 @@
+package propmerge
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsHOCONFile 根据扩展名判断是否为HOCON文件: .conf(如Akka、Play的application.conf)或.hocon
+func IsHOCONFile(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".conf", ".hocon":
+		return true
+	}
+	return false
+}
+
+// HOCONOverridesHeader 为无法放入新文件结构中的保留参数在文件末尾追加的注释行，其后的赋值按HOCON的规则覆盖前面的同名路径
+const HOCONOverridesHeader = "# overrides preserved from the old config (no matching object in new template)"
+
+// hoconEntry 描述HOCON文件中的一次赋值，path为所在对象的路径与点分键连接的完整路径
+type hoconEntry struct {
+	path    string
+	keyText string // 行中键的原始文本(含引号)
+	start   int    // 键所在行(从0开始)
+	end     int    // 值(含多行字符串、多行数组与单行对象)之后的第一行
+	valueAt int    // 值在首行中的起始位置(=或:及其后的空白之后)
+	closeAt int    // 值之后关闭所在对象的右花括号在最后一行中的位置，没有时为-1
+	value   string // 去掉行尾注释与逗号后的值
+	appends bool   // +=追加赋值，不参与合并
+
+	occurrence int // 同一路径在文件中第几次赋值(从0开始)，如先写默认值再写 ${?ENV} 覆盖
+}
+
+// hoconObject 描述一个跨多行的对象，如 akka { ... }；整个文件包在花括号中时根对象的path为空
+type hoconObject struct {
+	path  string
+	start int // 左花括号所在行
+	close int // 右花括号所在行，未闭合时为-1
+}
+
+// isHOCONKeyChar 判断是否为不带引号的键允许的字符('.'为路径分隔符)
+func isHOCONKeyChar(c byte) bool {
+	return c > ' ' && !strings.ContainsRune("$\"{}[]:=,+#`^?!@*&\\.", rune(c))
+}
+
+// parseHOCONKey 解析点分键(支持不带引号与带双引号的键)，返回各段键名与键之后的内容
+func parseHOCONKey(s string) (segments []string, rest string, ok bool) {
+	for {
+		if s == "" {
+			return nil, "", false
+		}
+		if s[0] == '"' {
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			var seg string
+			if end >= len(s) || json.Unmarshal([]byte(s[:end+1]), &seg) != nil {
+				return nil, "", false
+			}
+			segments = append(segments, seg)
+			s = s[end+1:]
+		} else {
+			end := 0
+			for end < len(s) && isHOCONKeyChar(s[end]) && !strings.HasPrefix(s[end:], "//") {
+				end++
+			}
+			if end == 0 {
+				return nil, "", false
+			}
+			segments = append(segments, s[:end])
+			s = s[end:]
+		}
+		if !strings.HasPrefix(s, ".") {
+			return segments, strings.TrimLeft(s, " \t"), true
+		}
+		s = s[1:]
+	}
+}
+
+// hoconKeyText 将各段键名拼接为键文本，含特殊字符的段加双引号
+func hoconKeyText(segments []string) string {
+	parts := make([]string, len(segments))
+	for i, seg := range segments {
+		parts[i] = seg
+		for j := 0; j < len(seg); j++ {
+			if !isHOCONKeyChar(seg[j]) || strings.HasPrefix(seg[j:], "//") {
+				parts[i] = jsonQuote(seg)
+				break
+			}
+		}
+		if seg == "" {
+			parts[i] = `""`
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// isHOCONComment 判断是否为#或//开头的注释行
+func isHOCONComment(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//")
+}
+
+// isHOCONInclude 判断是否为include指令，如 include "base.conf"、include required(file("x.conf"))
+func isHOCONInclude(trimmed string) bool {
+	rest, ok := strings.CutPrefix(trimmed, "include")
+	if !ok || rest == "" || (rest[0] != ' ' && rest[0] != '\t' && rest[0] != '"') {
+		return false
+	}
+	rest = strings.TrimLeft(rest, " \t")
+	for _, p := range []string{`"`, "required(", "file(", "url(", "classpath("} {
+		if strings.HasPrefix(rest, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// opensHOCONObject 判断值是否为跨多行的对象，即左花括号之后只有注释
+func opensHOCONObject(value string) bool {
+	if !strings.HasPrefix(value, "{") {
+		return false
+	}
+	rest := strings.TrimSpace(value[1:])
+	return rest == "" || isHOCONComment(rest)
+}
+
+// hoconValueSpan 从第start行的第at个字节开始扫描值，跨越多行字符串("""...""")与多行数组、对象，
+// 返回值之后的第一行、去掉行尾注释与逗号的值文本，以及值之后关闭所在对象的右花括号的位置(没有时为-1)
+func hoconValueSpan(lines []string, start, at int) (end int, value string, closeAt int) {
+	var parts []string
+	depth := 0
+	multi := false
+	closeAt = -1
+	text, base := lines[start][at:], at
+	for i := start; ; {
+		j := 0
+	scan:
+		for j < len(text) {
+			c := text[j]
+			switch {
+			case multi:
+				if strings.HasPrefix(text[j:], `"""`) {
+					for j < len(text) && text[j] == '"' {
+						j++
+					}
+					multi = false
+					continue
+				}
+			case strings.HasPrefix(text[j:], `"""`):
+				multi = true
+				j += 3
+				continue
+			case c == '"':
+				k := j + 1
+				for k < len(text) && text[k] != '"' {
+					if text[k] == '\\' {
+						k++
+					}
+					k++
+				}
+				j = k + 1
+				continue
+			case c == '[' || c == '{':
+				depth++
+			case c == ']' || c == '}':
+				if depth == 0 {
+					if c == '}' {
+						closeAt = base + j
+					}
+					text = text[:j]
+					break scan
+				}
+				depth--
+			case c == '#' || strings.HasPrefix(text[j:], "//"):
+				text = text[:j]
+				break scan
+			}
+			j++
+		}
+		parts = append(parts, strings.TrimRight(text, " \t\r"))
+
+		i++
+		if (!multi && depth <= 0) || closeAt != -1 || i >= len(lines) {
+			value = strings.TrimSpace(strings.Join(parts, "\n"))
+			return i, strings.TrimSpace(strings.TrimSuffix(value, ",")), closeAt
+		}
+		text, base = lines[i], 0
+	}
+}
+
+// parseHOCON 解析HOCON文件中的赋值与跨多行的对象。include指令与注释不参与解析；
+// 单行对象(如 a { b = 1 })作为一个值处理，+=追加赋值标记为appends
+func parseHOCON(lines []string) ([]hoconEntry, []hoconObject) {
+	var entries []hoconEntry
+	var objects []hoconObject
+	var stack []int // 当前所在对象在objects中的下标
+	seen := make(map[string]int)
+	pop := func(line int) {
+		if len(stack) > 0 {
+			objects[stack[len(stack)-1]].close = line
+			stack = stack[:len(stack)-1]
+		}
+	}
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || isHOCONComment(trimmed) || isHOCONInclude(trimmed) {
+			continue
+		}
+		if trimmed[0] == '}' {
+			pop(i)
+			continue
+		}
+		if len(entries) == 0 && len(objects) == 0 && opensHOCONObject(trimmed) {
+			objects = append(objects, hoconObject{start: i, close: -1})
+			stack = append(stack, 0)
+			continue
+		}
+
+		segments, rest, ok := parseHOCONKey(trimmed)
+		if !ok {
+			continue
+		}
+		keyText := strings.TrimSpace(trimmed[:len(trimmed)-len(rest)])
+		appends := false
+		switch {
+		case strings.HasPrefix(rest, "+="):
+			appends, rest = true, rest[2:]
+		case strings.HasPrefix(rest, "=") || strings.HasPrefix(rest, ":"):
+			rest = rest[1:]
+		case strings.HasPrefix(rest, "{"):
+		default:
+			continue
+		}
+		rest = strings.TrimLeft(rest, " \t")
+		path := strings.Join(segments, ".")
+		if len(stack) > 0 && objects[stack[len(stack)-1]].path != "" {
+			path = objects[stack[len(stack)-1]].path + "." + path
+		}
+		if !appends && opensHOCONObject(rest) {
+			objects = append(objects, hoconObject{path: path, start: i, close: -1})
+			stack = append(stack, len(objects)-1)
+			continue
+		}
+
+		indent := len(lines[i]) - len(strings.TrimLeft(lines[i], " \t"))
+		valueAt := indent + len(trimmed) - len(rest)
+		end, value, closeAt := hoconValueSpan(lines, i, valueAt)
+		e := hoconEntry{path: path, keyText: keyText, start: i, end: end, valueAt: valueAt, closeAt: closeAt, value: value, appends: appends}
+		if !appends {
+			e.occurrence = seen[path]
+			seen[path]++
+		}
+		entries = append(entries, e)
+		if closeAt != -1 {
+			pop(end - 1)
+		}
+		i = end - 1
+	}
+	return entries, objects
+}
+
+// effectiveHOCON 返回各路径最后一次赋值(HOCON中后面的赋值覆盖前面的)，+=追加赋值不计入
+func effectiveHOCON(entries []hoconEntry) []hoconEntry {
+	last := make(map[string]int)
+	for i, e := range entries {
+		if !e.appends {
+			last[e.path] = i
+		}
+	}
+	var effective []hoconEntry
+	for i, e := range entries {
+		if !e.appends && last[e.path] == i {
+			effective = append(effective, e)
+		}
+	}
+	return effective
+}
+
+// hoconValueText 返回赋值中的原始值文本(含行尾注释与后续行)，去掉值之后关闭所在对象的右花括号
+func hoconValueText(lines []string, e hoconEntry) []string {
+	block := append([]string(nil), lines[e.start:e.end]...)
+	block[0] = block[0][e.valueAt:]
+	if e.closeAt != -1 {
+		last := len(block) - 1
+		cut := e.closeAt
+		if last == 0 {
+			cut -= e.valueAt
+		}
+		block[last] = strings.TrimRight(strings.TrimSpace(block[last][:cut]), ",")
+	}
+	return block
+}
+
+// resolveHOCON 将值中的替换${path}与${?path}展开为同一文件中该路径的值，路径不存在时使用同名环境变量；
+// 无法解析的可选替换去掉，必选替换原样保留。带引号的部分去掉引号，数组与对象原样返回
+func resolveHOCON(values map[string]string, value string, depth int) string {
+	if strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); {
+		switch {
+		case strings.HasPrefix(value[i:], `"""`):
+			end := strings.Index(value[i+3:], `"""`)
+			if end == -1 {
+				b.WriteString(value[i:])
+				return b.String()
+			}
+			b.WriteString(value[i+3 : i+3+end])
+			i += end + 6
+		case value[i] == '"':
+			k := i + 1
+			for k < len(value) && value[k] != '"' {
+				if value[k] == '\\' {
+					k++
+				}
+				k++
+			}
+			var s string
+			if k >= len(value) || json.Unmarshal([]byte(value[i:k+1]), &s) != nil {
+				b.WriteString(value[i:min(k+1, len(value))])
+			} else {
+				b.WriteString(s)
+			}
+			i = k + 1
+		case strings.HasPrefix(value[i:], "${"):
+			end := strings.IndexByte(value[i:], '}')
+			if end == -1 {
+				b.WriteString(value[i:])
+				return b.String()
+			}
+			ref := strings.TrimSpace(value[i+2 : i+end])
+			optional := strings.HasPrefix(ref, "?")
+			ref = strings.TrimSpace(strings.TrimPrefix(ref, "?"))
+			if v, ok := values[ref]; ok && depth < 10 {
+				b.WriteString(resolveHOCON(values, v, depth+1))
+			} else if env, ok := os.LookupEnv(ref); ok {
+				b.WriteString(env)
+			} else if !optional {
+				b.WriteString(value[i : i+end+1])
+			}
+			i += end + 1
+		default:
+			b.WriteByte(value[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// MergeHOCON 从旧HOCON中提取命中保留规则的值(以各层对象的键与点分键连接的路径匹配，如akka.loglevel)，
+// 写入新HOCON的对应位置。同一路径多次赋值时按出现次序与新文件中的各次赋值对应；新文件中已存在的赋值只替换值，
+// 保留新文件的键写法；缺失的路径插入到新文件中路径最长的已有对象末尾，没有可用的对象时以完整路径
+// 追加到文件末尾的覆盖块(HOCONOverridesHeader)中。include指令、替换与注释原样保留，+=追加赋值不参与合并
+func (m *Merger) MergeHOCON(old io.Reader, new io.Reader) (Result, error) {
+	oldLines, err := ReadLines(old)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+	newLines, err := ReadLines(new)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取新文件失败: %w"), err)
+	}
+	return m.MergeHOCONLines(oldLines, newLines)
+}
+
+// MergeHOCONLines 与MergeHOCON相同，但直接处理已读取的行
+func (m *Merger) MergeHOCONLines(oldLines, newLines []string) (Result, error) {
+	lines := append([]string(nil), newLines...)
+	oldEntries, _ := parseHOCON(oldLines)
+	var kept []hoconEntry
+	oldPaths := make(map[string]bool)
+	for _, o := range oldEntries {
+		if !o.appends && m.Matches(o.path+"="+o.value) {
+			kept = append(kept, o)
+			oldPaths[o.path] = true
+		}
+	}
+
+	var results []KeyResult
+	for _, o := range kept {
+		m.keyDebugf(o.path, o.start+1, "", "找到匹配参数[行%d]: %s", o.start+1, o.path)
+
+		block := hoconValueText(oldLines, o)
+		entries, objects := parseHOCON(lines)
+		result := KeyResult{Key: o.path, OldValue: o.value}
+		if !m.renamePath(&result, oldPaths) {
+			results = append(results, result)
+			continue
+		}
+		o.path = result.Key
+		if len(block) == 1 && m.transformResult(&result, true) {
+			block[0] = strings.Replace(block[0], result.TransformedFrom, result.OldValue, 1)
+		}
+
+		if n, ok := findHOCONEntry(entries, o.path, o.occurrence); ok {
+			result.Action = ActionReplace
+			result.Line = n.start + 1
+			result.NewValue = n.value
+			if !m.renameCollision(&result) || !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			replacement := append([]string(nil), block...)
+			replacement[0] = lines[n.start][:n.valueAt] + replacement[0]
+			if n.closeAt != -1 {
+				// 新文件中该赋值之后紧跟对象的右花括号，替换后保留
+				last := len(replacement) - 1
+				replacement[last] = strings.TrimRight(replacement[last], " \t") + " " + lines[n.end-1][n.closeAt:]
+			}
+			lines = append(lines[:n.start], append(replacement, lines[n.end:]...)...)
+			m.keyDebugf(o.path, n.start+1, ActionReplace, "替换参数[行%d]: %s", n.start+1, o.path)
+		} else if hasHOCONObject(objects, o.path) {
+			m.keyWarnf(o.path, 0, ActionSkip, "新文件中 %s 是对象，无法写入旧文件中的值，已跳过", o.path)
+			result.Action = ActionSkip
+			results = append(results, result)
+			continue
+		} else {
+			var inserted []string
+			inserted, result.Line, result.Action = insertHOCONEntry(lines, entries, objects, o.path, block)
+			if !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			lines = inserted
+			m.keyDebugf(o.path, result.Line, result.Action, insertMessage(result.Action), result.Line, o.path)
+		}
+		m.trace(TraceEvent{Event: "action", Line: result.Line, Key: o.path, Text: strings.TrimSpace(o.keyText + " = " + block[0]), Result: result.Action})
+		results = append(results, result)
+	}
+	merged := Result{Lines: lines, Keys: results}
+	return merged, m.checkCollisions(merged)
+}
+
+// findHOCONEntry 按路径与出现次序查找赋值，+=追加赋值除外
+func findHOCONEntry(entries []hoconEntry, path string, occurrence int) (hoconEntry, bool) {
+	for _, e := range entries {
+		if e.path == path && !e.appends && e.occurrence == occurrence {
+			return e, true
+		}
+	}
+	return hoconEntry{}, false
+}
+
+// lastHOCONEntry 返回路径的最后一次赋值，+=追加赋值除外
+func lastHOCONEntry(entries []hoconEntry, path string) (hoconEntry, bool) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if e := entries[i]; e.path == path && !e.appends {
+			return e, true
+		}
+	}
+	return hoconEntry{}, false
+}
+
+// hasHOCONObject 判断路径是否为跨多行的对象
+func hasHOCONObject(objects []hoconObject, path string) bool {
+	for _, o := range objects {
+		if o.path == path && path != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// insertHOCONEntry 插入新文件中不存在的赋值: 同一路径已有较早的赋值时插入到其最后一次赋值之后，沿用其键写法；
+// 否则插入到路径最长的已有对象中该对象的右花括号之前，键为路径的剩余部分；没有可用的对象时追加到文件末尾
+// (整个文件包在花括号中时为根对象的右花括号之前)，多段路径写在HOCONOverridesHeader注释之后
+func insertHOCONEntry(lines []string, entries []hoconEntry, objects []hoconObject, path string, block []string) ([]string, int, string) {
+	if n, ok := lastHOCONEntry(entries, path); ok {
+		indent := lines[n.start][:len(lines[n.start])-len(strings.TrimLeft(lines[n.start], " \t"))]
+		leaf := append([]string{indent + n.keyText + " = " + block[0]}, block[1:]...)
+		return insertLines(lines, n.end, leaf), n.end + 1, ActionInsert
+	}
+	segments := strings.Split(path, ".")
+	for j := len(segments) - 1; j > 0; j-- {
+		name := strings.Join(segments[:j], ".")
+		for k := len(objects) - 1; k >= 0; k-- {
+			if o := objects[k]; o.path == name && o.close != -1 {
+				leaf := append([]string{hoconChildIndent(lines, o) + hoconKeyText(segments[j:]) + " = " + block[0]}, block[1:]...)
+				return insertLines(lines, o.close, leaf), o.close + 1, ActionInsert
+			}
+		}
+	}
+
+	at := len(lines)
+	indent := ""
+	if len(objects) > 0 && objects[0].path == "" && objects[0].close != -1 {
+		at, indent = objects[0].close, hoconChildIndent(lines, objects[0])
+	}
+	var inserted []string
+	if len(segments) > 1 && !containsTrimmedLine(lines, HOCONOverridesHeader) {
+		if at > 0 && strings.TrimSpace(lines[at-1]) != "" {
+			inserted = append(inserted, "")
+		}
+		inserted = append(inserted, indent+HOCONOverridesHeader)
+	}
+	line := at + len(inserted) + 1
+	inserted = append(inserted, indent+hoconKeyText(segments)+" = "+block[0])
+	inserted = append(inserted, block[1:]...)
+	return insertLines(lines, at, inserted), line, ActionAppend
+}
+
+// hoconChildIndent 返回对象中成员的缩进: 取对象内缩进最小的非空行，对象为空时在右花括号的缩进上加两个空格
+func hoconChildIndent(lines []string, o hoconObject) string {
+	indent := ""
+	found := false
+	for i := o.start + 1; i < o.close; i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		lead := lines[i][:len(lines[i])-len(strings.TrimLeft(lines[i], " \t"))]
+		if !found || len(lead) < len(indent) {
+			indent, found = lead, true
+		}
+	}
+	if !found {
+		closing := lines[o.close]
+		indent = closing[:len(closing)-len(strings.TrimLeft(closing, " \t"))] + "  "
+	}
+	return indent
+}
+
+// insertLines 在第at行之前插入若干行
+func insertLines(lines []string, at int, inserted []string) []string {
+	result := make([]string, 0, len(lines)+len(inserted))
+	result = append(result, lines[:at]...)
+	result = append(result, inserted...)
+	return append(result, lines[at:]...)
+}
+
+// containsTrimmedLine 判断是否有去掉两侧空白后等于text的行
+func containsTrimmedLine(lines []string, text string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) == text {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package propmerge
+
+// 输出语言
+const (
+	LanguageZH = "zh"
+	LanguageEN = "en"
+)
+
+// language 为当前输出语言，默认为中文
+var language = LanguageZH
+
+// SetLanguage 设置错误信息与日志使用的语言，不支持的语言按中文处理
+func SetLanguage(lang string) {
+	language = lang
+}
+
+// tr 返回消息在当前语言下的文本，消息目录以中文原文为键，缺少译文时返回原文
+func tr(s string) string {
+	if language == LanguageEN {
+		if t, ok := messagesEN[s]; ok {
+			return t
+		}
+	}
+	return s
+}
+
+// message 是在输出时才按当前语言翻译的错误，用于包级别的错误变量
+type message string
+
+func (e message) Error() string {
+	return tr(string(e))
+}
+
+// messagesEN 为英文消息目录
+var messagesEN = map[string]string{
+	"读取文件信息失败: %w":                 "failed to stat file: %w",
+	"创建临时文件失败: %w":                 "failed to create temporary file: %w",
+	"设置属主失败: %w":                   "failed to set owner: %w",
+	"设置文件权限失败: %w":                 "failed to set file mode: %w",
+	"同步文件失败: %w":                   "failed to sync file: %w",
+	"关闭临时文件失败: %w":                 "failed to close temporary file: %w",
+	"替换文件失败: %w":                   "failed to replace file: %w",
+	"同步目录失败: %w":                   "failed to sync directory: %w",
+	"读取配置文件失败: %w":                 "failed to read config file: %w",
+	"解析配置文件失败: %w":                 "failed to parse config file: %w",
+	"不支持的配置文件版本: %d":               "unsupported config file version: %d",
+	"重复的键":                         "duplicate key",
+	"检测到重复的键: %s (%s, 行%v)":        "duplicate key detected: %s (%s, lines %v)",
+	"不支持的编码: %s":                   "unsupported encoding: %s",
+	"不支持%s与%s之间的转换: 未设置Transcoder": "conversion between %s and %s is not supported: no Transcoder set",
+	"字符%q(第%d字节)无法以ISO-8859-1编码，properties文件可使用-unicode ascii写为\\uXXXX": "character %q (byte %d) cannot be encoded as ISO-8859-1; use -unicode ascii to write \\uXXXX in properties files",
+	"写入文件失败: %w":             "failed to write file: %w",
+	"不支持的导出格式: %s":           "unsupported export format: %s",
+	"编码值失败: %w":              "failed to encode value: %w",
+	"读取旧文件失败: %w":            "failed to read old file: %w",
+	"读取新文件失败: %w":            "failed to read new file: %w",
+	"写入更新文件失败: %w":           "failed to write updated file: %w",
+	"打开文件失败: %w":             "failed to open file: %w",
+	"扫描文件失败: %w":             "failed to scan file: %w",
+	"读取文件失败: %w":             "failed to read file: %w",
+	"刷新缓冲区失败: %w":            "failed to flush buffer: %w",
+	"替换参数[行%d]: %s":          "replaced parameter [line %d]: %s",
+	"解析JSON失败(行%d): %w":      "failed to parse JSON (line %d): %w",
+	"解析JSON失败: %w":           "failed to parse JSON: %w",
+	"旧文件%w":                  "old file: %w",
+	"新文件%w":                  "new file: %w",
+	"找到匹配参数[行%d]: %s":        "matched parameter [line %d]: %s",
+	"跳过参数(新文件中的父节点不是对象): %s": "skipped parameter (parent in new file is not an object): %s",
+	"插入参数[行%d]: %s":          "inserted parameter [line %d]: %s",
+	"编译敏感键规则失败: %w":          "failed to compile sensitive key patterns: %w",
+	"旧文件中同时存在目标键，以旧文件中的目标键为准":  "target key also exists in old file, the old file's target key wins",
+	"新文件中已存在 %s=%s":            "new file already has %s=%s",
+	"基线=%s, 旧值与新值均已修改":         "base=%s, both old and new values changed",
+	"基线中不存在, 旧文件与新文件均新增了该键":    "missing from base, key added in both old and new files",
+	"使用匹配规则: %s":               "using pattern: %s",
+	"使用%d条结构化保留规则":             "using %d structured keep rules",
+	"跳过与默认值相同的参数[行%d]: %s":     "skipped parameter equal to default [line %d]: %s",
+	"找到匹配参数[行%d]: %s (规则: %s)": "matched parameter [line %d]: %s (rule: %s)",
+	"共找到%d个需要保留的参数":            "found %d parameters to keep",
+	"自动保留参数[行%d]: %s":          "auto-kept parameter [line %d]: %s",
+	"自动推导出%d个需要保留的参数":          "derived %d parameters to keep",
+	"重命名参数: %s -> %s":          "renamed parameter: %s -> %s",
+	"追加参数[行%d]: %s":            "appended parameter [line %d]: %s",
+	"旧值与基线相同，采用新文件: %s":        "old value equals base, taking new file: %s",
+	"三方合并冲突: %s (%s)":          "three-way merge conflict: %s (%s)",
+	"跳过参数(未确认): %s":            "skipped parameter (not confirmed): %s",
+	"删除重复参数[行%d]: %s":          "removed duplicate parameter [line %d]: %s",
+	"删除模板中不存在的参数[行%d]: %s":     "removed parameter not in the template [line %d]: %s",
+	"解码文件失败: %w":               "failed to decode file: %w",
+	"在行%d找到键(宽松绑定): %s":        "found key at line %d (relaxed binding): %s",
+	"未找到键(宽松绑定): %s":           "key not found (relaxed binding): %s",
+	"在行%d找到键: %s":              "found key at line %d: %s",
+	"未找到键: %s":                 "key not found: %s",
+	"无效的冲突处理策略: %s":            "invalid collision policy: %s",
+	"无效的三方合并冲突策略: %s":          "invalid three-way conflict policy: %s",
+	"无效的重复键处理策略: %s":           "invalid duplicate key policy: %s",
+	"不支持的输出编码: %s":             "unsupported output encoding: %s",
+	"编译正则表达式失败: %w":            "failed to compile regular expression: %w",
+	"编译保留规则失败: %w":             "failed to compile keep rules: %w",
+	"%w: 检测到%d个重复的键，未写入任何修改":   "%w: %d duplicate keys detected, nothing written",
+	"%w: 检测到%d处重命名冲突，未写入任何修改":  "%w: %d rename collisions detected, nothing written",
+	"%w: 检测到%d处冲突，未写入任何修改":     "%w: %d conflicts detected, nothing written",
+	"警告: ":         "warning: ",
+	"重命名冲突":        "rename collision",
+	"合并冲突":         "merge conflict",
+	"开始更新文件(共%d行)": "updating file (%d lines)",
+	"文件更新完成，共处理%d个参数":                   "file updated, %d parameters processed",
+	"第%d条规则未定义keys":                     "rule %d does not define keys",
+	"第%d条规则无效: %w":                      "rule %d is invalid: %w",
+	"第%d条规则的exclude无效: %w":              "rule %d has an invalid exclude: %w",
+	"不支持的规则类型: %s":                      "unsupported rule type: %s",
+	"新文件中 %s 是嵌套映射，无法写入旧文件中的值，已跳过":      "%s is a nested mapping in the new file, cannot write the old value, skipped",
+	"旧文件中 %s 所在的文档(%s)在新文件中没有对应的文档，已跳过": "the document of %s in the old file (%s) has no matching document in the new file, skipped",
+	"无profile #%d":                     "no profile #%d",
+	"格式插件 %s 未定义command":               "format plugin %s has no command",
+	"格式插件 %s 执行%s失败: %w: %s":           "format plugin %s failed to %s: %w: %s",
+	"格式插件 %s 执行%s失败: %w":               "format plugin %s failed to %s: %w",
+	"格式插件 %s 的%s响应无效: %w":              "format plugin %s returned an invalid %s response: %w",
+	"格式插件 %s 执行%s失败: %s":               "format plugin %s failed to %s: %s",
+	"插入参数: %s":                         "inserted parameter: %s",
+	"解析旧文件失败: %w":                      "failed to parse old file: %w",
+	"解析新文件失败: %w":                      "failed to parse new file: %w",
+	"第%d个格式插件缺少name或command":           "format plugin %d is missing name or command",
+	"第%d条转换规则未定义keys":                  "transform %d does not define keys",
+	"第%d条转换规则未定义match、template或factor": "transform %d defines none of match, template or factor",
+	"第%d条转换规则无效: %w":                   "transform %d is invalid: %w",
+	"第%d条转换规则的match无效: %w":             "transform %d has an invalid match: %w",
+	"参数值不是数字，未按系数%v缩放: %s":             "value is not a number, not scaled by %v: %s",
+	"转换参数值: %s: %s -> %s":              "transformed value: %s: %s -> %s",
+	"编译值转换规则失败: %w":                    "failed to compile value transforms: %w",
+	"读取校验规则文件失败: %w":                   "failed to read check rules file: %w",
+	"解析校验规则文件失败: %w":                   "failed to parse check rules file: %w",
+	"缺少必需的参数":                          "required key is missing",
+	"没有与之匹配的参数":                        "no key matches this pattern",
+	"值不能为空":                            "value must not be empty",
+	"值不是整数: %s":                        "value is not an integer: %s",
+	"值不是true或false: %s":                "value is not true or false: %s",
+	"值不是有效的地址: %s":                     "value is not a valid URL: %s",
+	"不支持的文件格式: %s":                     "unsupported file format: %s",
+	"无效的插入策略: %s":                      "invalid insert strategy: %s",
+	"新文件中没有与之共享前缀的参数，追加到末尾: %s":        "no key in the new file shares a prefix, appending to the end: %s",
+	"按同前缀的参数[行%d]定位插入位置: %s":           "inserting after the key sharing its prefix [line %d]: %s",
+	"不支持的Jasypt算法: %s":                 "unsupported Jasypt algorithm: %s",
+	"密文不是有效的Base64: %w":                "ciphertext is not valid Base64: %w",
+	"生成随机盐失败: %w":                      "failed to generate random salt: %w",
+	"无法解密: %v":                         "cannot decrypt: %v",
+	"Jasypt密码不能为空":                     "Jasypt password must not be empty",
+	"密文长度无效":                           "invalid ciphertext length",
+	"解密失败，密码或算法不正确":                    "decryption failed: wrong password or algorithm",
+	"加密值": "encrypted value",
+	"无法解密参数值，原样保留: %s: %v":                                          "cannot decrypt value, preserving it unchanged: %s: %v",
+	"重新加密参数值失败，原样保留: %s: %v":                                        "failed to re-encrypt value, preserving it unchanged: %s: %v",
+	"XPath表达式为空":                                                    "empty XPath expression",
+	"XPath表达式 %s 无效: 步骤之间应以/分隔":                                     "invalid XPath expression %s: steps must be separated by /",
+	"XPath表达式 %s 无效: @属性只能作为最后一步":                                   "invalid XPath expression %s: @attribute can only be the last step",
+	"XPath表达式 %s 无效: text()只能作为最后一步":                                "invalid XPath expression %s: text() can only be the last step",
+	"XPath表达式 %s 无效: 无效的元素名%q":                                      "invalid XPath expression %s: invalid element name %q",
+	"XPath表达式 %s 无效: 谓词缺少]":                                         "invalid XPath expression %s: predicate is missing ]",
+	"XPath表达式 %s 无效: %w":                                            "invalid XPath expression %s: %w",
+	"XPath表达式 %s 无效: 缺少元素步骤":                                        "invalid XPath expression %s: no element step",
+	"序号必须从1开始: %s":                                                  "positions start at 1: %s",
+	"不支持的谓词: [%s]":                                                  "unsupported predicate: [%s]",
+	"谓词中的值需要加引号: [%s]":                                              "predicate values must be quoted: [%s]",
+	"第%d行: 结束标签</%s>与开始标签不匹配":                                       "line %d: end tag </%s> does not match the start tag",
+	"元素<%s>未闭合":                                                     "element <%s> is not closed",
+	"没有根元素":                                                         "no root element",
+	"未定义xmlPaths，XML文件中没有需要保留的参数":                                   "xmlPaths is not defined, nothing to preserve in the XML file",
+	"编译xmlPaths失败: %w":                                              "failed to compile xmlPaths: %w",
+	"新文件中不存在该节点，XML文件不插入新节点: %s":                                    "node not found in the new file, XML files never get new nodes inserted: %s",
+	"%w (如需通配符，可在rules中直接写字符串\"%s\"或使用type: glob)":                  "%w (for wildcards, write the string \"%s\" directly in rules or use type: glob)",
+	"配置文件中没有定义profiles，无法选用规则组 %s":                                  "no profiles are defined in the config file, cannot use rule profile %s",
+	"配置文件中没有名为 %s 的规则组，可选: %s":                                      "no rule profile named %s in the config file, available: %s",
+	"编译excludeKeys失败: %w":                                           "failed to compile excludeKeys: %w",
+	"参数命中保留规则，但被excludeKeys第%d条排除: %s":                              "key matches a keep rule but is excluded by excludeKeys #%d: %s",
+	"第%d个通知的类型无效: %s":                                               "notification #%d has an invalid type: %s",
+	"第%d个通知缺少url":                                                   "notification #%d is missing url",
+	"当前平台不支持SELinux安全上下文":                                           "SELinux security contexts are not supported on this platform",
+	"设置SELinux安全上下文失败: %w":                                          "failed to set SELinux security context: %w",
+	"使用流式合并: %s (替换%d个参数，插入%d个参数)":                                  "Using streaming merge: %s (%d replaced, %d inserted)",
+	"按注释指令保留参数[行%d]: %s":                                            "preserving parameter by comment directive [line %d]: %s",
+	"第%d行的%s嵌套在第%d行开始的块中，已忽略":                                       "%[2]s on line %[1]d is nested in the block starting on line %[3]d, ignored",
+	"第%d行的%s没有对应的%s，已忽略":                                            "%[2]s on line %[1]d has no matching %[3]s, ignored",
+	"第%d行的%s没有对应的%s，保留到文件末尾":                                        "%[2]s on line %[1]d has no matching %[3]s, preserving to end of file",
+	"处理策略为%s时必须提供AskObsolete":                                       "AskObsolete is required when the policy is %s",
+	"无效的已删除参数处理策略: %s":                                              "invalid obsolete parameter policy: %s",
+	"新文件中已不存在，不予写入: %s":                                             "no longer in new file, not written: %s",
+	"以注释形式写入已删除的参数[行%d]: %s":                                        "wrote removed parameter commented out [line %d]: %s",
+	"无效的Vault认证方式: %s":                                              "invalid Vault auth method: %s",
+	"secrets中的%s缺少path或field":                                       "%s in secrets is missing path or field",
+	"使用密钥管理中的值代替旧值[行%d]: %s":                                        "using value from secret store instead of old value [line %d]: %s",
+	"旧文件中没有该参数，使用密钥管理中的值: %s":                                       "parameter not in old file, using value from secret store: %s",
+	"无效的Unicode写法: %s":                                              "invalid unicode mode: %s",
+	"值模板渲染失败":                                                       "value template rendering failed",
+	"%w: %d个参数的值模板无法渲染，未写入任何修改:\n  %s":                              "%w: templates of %d parameters could not be rendered, nothing written:\n  %s",
+	"渲染参数值模板: %s":                                                   "rendered value template: %s",
+	"backupDir中第%d个目的地缺少url":                                        "backupDir destination #%d has no url",
+	"backupDir中第%d个目的地的类型不受支持: %s (可选file://、sftp://、s3://、oss://)": "backupDir destination #%d has an unsupported type: %s (use file://, sftp://, s3:// or oss://)",
+	"backupDir中第%d个目的地的onError无效: %s":                               "backupDir destination #%d has an invalid onError: %s",
+	"旧值与新值不同":                                                       "old and new values differ",
+	"冲突: %s (%s)":                                                   "conflict: %s (%s)",
+	"moves中的路径不能为空: %q -> %q":                                       "Paths in moves must not be empty: %q -> %q",
+	"backupEncryption缺少recipients":                                  "backupEncryption is missing recipients",
+	"无效的备份加密方式: %s (可选age、gpg)":                                     "invalid backup encryption type: %s (valid: age, gpg)",
+	"参数命中保留规则，但rules第%d条要求取新文件中的值: %s":                              "key matches a keep rule, but rules #%d requires the new file's value: %s",
+	"第%d条规则的policy无效: %s (可选keep-old、force-new、prompt)":             "invalid policy in rule #%d: %s (valid: keep-old, force-new, prompt)",
+	"第%d条规则的mergeList无效: %s (可选union、old、new)":                      "invalid mergeList in rule #%d: %s (valid: union, old, new)",
+	"合并列表值: %s: %s -> %s":                                           "merged list value: %s: %s -> %s",
+	"新文件中 %s 是对象，无法写入旧文件中的值，已跳过":                                    "%s is an object in the new file, cannot write the old value, skipped",
+	"合并已中止: %w":                                                     "merge aborted: %w",
+	"新文件中没有第%d个[[%s]]，旧文件中的 %s 已跳过":                                 "the new file has no [[%[2]s]] #%[1]d; %[3]s from the old file was skipped",
+}
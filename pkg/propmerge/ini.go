@@ -0,0 +1,267 @@
+package propmerge
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// IsINIFile 根据扩展名判断是否为INI文件: .ini(如php.ini)或.cnf(如MySQL的my.cnf)
+func IsINIFile(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ini", ".cnf":
+		return true
+	}
+	return false
+}
+
+// iniEntry 描述INI文件中的一个键，path为节名与键以点连接的完整路径，节之前的键只有键名
+type iniEntry struct {
+	path       string
+	section    string
+	key        string
+	start      int    // 键所在行(从0开始)
+	bare       bool   // 没有等号的键，如my.cnf中的skip-name-resolve
+	valueAt    int    // 值在行中的起始位置(等号与其后的空白之后)，bare时为行尾
+	value      string // 去掉行尾注释后的值
+	occurrence int    // 同一路径在文件中第几次出现(从0开始)，如php.ini中多行extension=
+}
+
+// iniSection 描述一个[section]节头
+type iniSection struct {
+	name  string
+	start int // 节头所在行
+}
+
+// parseINI 解析INI文件中的节与键: ;与#开头的行为注释，!include等指令忽略；
+// 值中空白之后的;或#(不在引号内)开始行尾注释
+func parseINI(lines []string) ([]iniEntry, []iniSection) {
+	var entries []iniEntry
+	var sections []iniSection
+	section := ""
+	seen := make(map[string]int)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.ContainsRune(";#!", rune(trimmed[0])) {
+			continue
+		}
+		if trimmed[0] == '[' {
+			if end := strings.IndexByte(trimmed, ']'); end != -1 {
+				section = strings.TrimSpace(trimmed[1:end])
+				sections = append(sections, iniSection{name: section, start: i})
+			}
+			continue
+		}
+
+		e := iniEntry{section: section, start: i}
+		if eq := strings.IndexByte(line, '='); eq != -1 {
+			e.key = strings.TrimSpace(line[:eq])
+			e.valueAt = eq + 1 + len(line[eq+1:]) - len(strings.TrimLeft(line[eq+1:], " \t"))
+			e.value = iniValueText(line[e.valueAt:])
+		} else {
+			e.key, e.bare, e.valueAt = trimmed, true, len(line)
+		}
+		if e.key == "" || (e.bare && strings.ContainsAny(e.key, " \t")) {
+			continue
+		}
+		e.path = e.key
+		if section != "" {
+			e.path = section + "." + e.key
+		}
+		e.occurrence = seen[e.path]
+		seen[e.path]++
+		entries = append(entries, e)
+	}
+	return entries, sections
+}
+
+// iniValueText 去掉值的行尾注释与两侧空白
+func iniValueText(text string) string {
+	var quote byte
+	for j := 0; j < len(text); j++ {
+		c := text[j]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case (c == ';' || c == '#') && j > 0 && (text[j-1] == ' ' || text[j-1] == '\t'):
+			return strings.TrimSpace(text[:j])
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+// iniLine 返回键名为key、值取自旧行的INI行: prefix为新文件中该键所在行等号之前(含等号与空白)的内容，
+// 为空时沿用旧行(键未重命名时)或按"键 = 值"生成；旧键没有等号时只写键名
+func iniLine(indent, prefix, key string, o iniEntry, oldLine string) string {
+	switch {
+	case o.bare:
+		return indent + key
+	case prefix != "":
+		// 新文件中的值为空(如password =)时等号后没有空白，按等号前的写法补上
+		if strings.HasSuffix(prefix, " =") || strings.HasSuffix(prefix, "\t=") {
+			prefix += " "
+		}
+		return prefix + oldLine[o.valueAt:]
+	case key == o.key:
+		return indent + strings.TrimLeft(oldLine, " \t")
+	}
+	return indent + key + " = " + oldLine[o.valueAt:]
+}
+
+// MergeINI 从旧INI中提取命中保留规则的键(以"节名.键"的点分路径匹配，如mysqld.max_connections)，
+// 写入新INI中对应节的同名键，保留新文件的键写法与缩进；同一节中重复出现的键(如extension)按出现顺序一一对应。
+// 缺失的键插入到所属节最后一个键之后，节不存在时在文件末尾追加该节；新文件其余内容和注释保持不变
+func (m *Merger) MergeINI(old io.Reader, new io.Reader) (Result, error) {
+	oldLines, err := ReadLines(old)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+	newLines, err := ReadLines(new)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取新文件失败: %w"), err)
+	}
+	return m.MergeINILines(oldLines, newLines)
+}
+
+// MergeINILines 与MergeINI相同，但直接处理已读取的行
+func (m *Merger) MergeINILines(oldLines, newLines []string) (Result, error) {
+	lines := append([]string(nil), newLines...)
+	oldEntries, _ := parseINI(oldLines)
+	var kept []iniEntry
+	oldPaths := make(map[string]bool)
+	for _, o := range oldEntries {
+		if m.Matches(o.path + "=" + o.value) {
+			kept = append(kept, o)
+			oldPaths[o.path] = true
+		}
+	}
+
+	var results []KeyResult
+	for _, o := range kept {
+		m.keyDebugf(o.path, o.start+1, "", "找到匹配参数[行%d]: %s", o.start+1, o.path)
+
+		oldLine := oldLines[o.start]
+		entries, sections := parseINI(lines)
+		result := KeyResult{Key: o.path, OldValue: o.value}
+		if !m.renamePath(&result, oldPaths) {
+			results = append(results, result)
+			continue
+		}
+		section, key := o.section, o.key
+		if result.RenamedFrom != "" {
+			section, key = splitINIPath(result.Key, sections)
+		}
+		if !o.bare && m.transformResult(&result, true) {
+			oldLine = oldLine[:o.valueAt] + strings.Replace(oldLine[o.valueAt:], result.TransformedFrom, result.OldValue, 1)
+		}
+
+		var line string
+		if n, ok := findINIEntry(entries, result.Key, o.occurrence); ok {
+			result.Action = ActionReplace
+			result.Line = n.start + 1
+			result.NewValue = n.value
+			if !m.renameCollision(&result) || !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			current := lines[n.start]
+			indent := current[:len(current)-len(strings.TrimLeft(current, " \t"))]
+			prefix := ""
+			if !n.bare {
+				prefix = current[:n.valueAt]
+			}
+			line = iniLine(indent, prefix, n.key, o, oldLine)
+			lines[n.start] = line
+			m.keyDebugf(result.Key, result.Line, ActionReplace, "替换参数[行%d]: %s", result.Line, result.Key)
+		} else {
+			line = iniLine("", "", key, o, oldLine)
+			var inserted []string
+			inserted, result.Line, result.Action = insertINIEntry(lines, entries, sections, result.Key, section, line)
+			if !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			lines = inserted
+			m.keyDebugf(result.Key, result.Line, result.Action, insertMessage(result.Action), result.Line, result.Key)
+		}
+		m.trace(TraceEvent{Event: "action", Line: result.Line, Key: result.Key, Text: strings.TrimSpace(line), Result: result.Action})
+		results = append(results, result)
+	}
+	merged := Result{Lines: lines, Keys: results}
+	return merged, m.checkCollisions(merged)
+}
+
+// findINIEntry 按路径与出现次序查找键
+func findINIEntry(entries []iniEntry, path string, occurrence int) (iniEntry, bool) {
+	for _, e := range entries {
+		if e.path == path && e.occurrence == occurrence {
+			return e, true
+		}
+	}
+	return iniEntry{}, false
+}
+
+// splitINIPath 将重命名后的路径拆分为节名与键: 优先取新文件中名称最长的前缀节，
+// 否则第一段为节名，没有点时为节之前的键
+func splitINIPath(path string, sections []iniSection) (string, string) {
+	best := ""
+	for _, s := range sections {
+		if strings.HasPrefix(path, s.name+".") && len(s.name) > len(best) {
+			best = s.name
+		}
+	}
+	if best != "" {
+		return best, path[len(best)+1:]
+	}
+	if section, key, ok := strings.Cut(path, "."); ok {
+		return section, key
+	}
+	return "", path
+}
+
+// insertINIEntry 将新文件中不存在的键插入到所属节: 同一路径已有较早出现的行时插入到其最后一行之后，
+// 否则插入到该节最后一个键之后(节中没有键时紧接节头)；节不存在时在文件末尾追加节头与该键。
+// 节之前的键插入到第一个节头之前的最后一个键之后
+func insertINIEntry(lines []string, entries []iniEntry, sections []iniSection, path, section, line string) ([]string, int, string) {
+	at, found := -1, section == ""
+	for _, s := range sections {
+		if s.name == section {
+			at, found = s.start+1, true
+			break
+		}
+	}
+	if !found {
+		inserted := []string{"[" + section + "]", line}
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			inserted = append([]string{""}, inserted...)
+		}
+		return append(lines, inserted...), len(lines) + len(inserted), ActionAppend
+	}
+
+	// 节中的最后一个键与同一路径的最后一行
+	last, lastSame := at, -1
+	for _, e := range entries {
+		if e.section != section {
+			continue
+		}
+		last = e.start + 1
+		if e.path == path {
+			lastSame = e.start + 1
+		}
+	}
+	at = max(last, 0)
+	if lastSame != -1 {
+		at = lastSame
+	}
+
+	result := make([]string, 0, len(lines)+1)
+	result = append(result, lines[:at]...)
+	result = append(result, line)
+	result = append(result, lines[at:]...)
+	return result, at + 1, ActionInsert
+}
@@ -0,0 +1,205 @@
+package propmerge
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// Jasypt标准PBE算法
+const (
+	JasyptMD5DES        = "PBEWithMD5AndDES"            // Jasypt 1.x与jasypt-spring-boot 2.x的默认算法
+	JasyptHMACSHA512AES = "PBEWITHHMACSHA512ANDAES_256" // jasypt-spring-boot 3.x的默认算法
+)
+
+// JasyptIterations 为Jasypt默认的密钥派生迭代次数
+const JasyptIterations = 1000
+
+// IsEncrypted 判断值是否为ENC(...)形式的Jasypt加密值，YAML等格式中带引号的值先去掉引号
+func IsEncrypted(value string) bool {
+	value = unquoteScalar(strings.TrimSpace(value))
+	return len(value) > len("ENC()") && strings.HasPrefix(value, "ENC(") && strings.HasSuffix(value, ")")
+}
+
+// Jasypt 按Jasypt StandardPBEStringEncryptor的格式加密与解密ENC(...)值，
+// 密文为Base64编码的盐、初始向量(仅AES)与PKCS#5填充的密文
+type Jasypt struct {
+	password   []byte
+	iterations int
+	aes        bool
+	keyLen     int              // AES密钥长度(字节)
+	prf        func() hash.Hash // PBKDF2使用的HMAC哈希
+}
+
+// NewJasypt 创建使用指定密码与算法的加解密器，algorithm为空时使用JasyptHMACSHA512AES。
+// 支持PBEWithMD5AndDES与PBEWITHHMACSHA{1,224,256,384,512}ANDAES_{128,256}，算法名不区分大小写
+func NewJasypt(password, algorithm string) (*Jasypt, error) {
+	if password == "" {
+		return nil, message("Jasypt密码不能为空")
+	}
+	if algorithm == "" {
+		algorithm = JasyptHMACSHA512AES
+	}
+	j := &Jasypt{password: []byte(password), iterations: JasyptIterations}
+	name := strings.ToUpper(algorithm)
+	if name == strings.ToUpper(JasyptMD5DES) {
+		return j, nil
+	}
+
+	prfs := map[string]func() hash.Hash{
+		"SHA1": sha1.New, "SHA224": sha256.New224, "SHA256": sha256.New,
+		"SHA384": sha512.New384, "SHA512": sha512.New,
+	}
+	var digest, bits string
+	if rest, ok := strings.CutPrefix(name, "PBEWITHHMAC"); ok {
+		digest, bits, ok = strings.Cut(rest, "ANDAES_")
+		if ok && prfs[digest] != nil && (bits == "128" || bits == "256") {
+			j.aes, j.prf = true, prfs[digest]
+			j.keyLen = 16
+			if bits == "256" {
+				j.keyLen = 32
+			}
+			return j, nil
+		}
+	}
+	return nil, fmt.Errorf(tr("不支持的Jasypt算法: %s"), algorithm)
+}
+
+// Decrypt 解密ENC(...)值或其中的Base64密文，返回明文
+func (j *Jasypt) Decrypt(value string) (string, error) {
+	value = unquoteScalar(strings.TrimSpace(value))
+	if IsEncrypted(value) {
+		value = value[len("ENC(") : len(value)-1]
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return "", fmt.Errorf(tr("密文不是有效的Base64: %w"), err)
+	}
+
+	block, saltLen := des.BlockSize, des.BlockSize
+	if j.aes {
+		block, saltLen = aes.BlockSize, aes.BlockSize
+	}
+	head := saltLen
+	if j.aes {
+		head += aes.BlockSize
+	}
+	if len(data) < head+block || (len(data)-head)%block != 0 {
+		return "", message("密文长度无效")
+	}
+	salt, iv, text := data[:saltLen], data[saltLen:head], data[head:]
+	mode, err := j.cipher(salt, iv, false)
+	if err != nil {
+		return "", err
+	}
+	plain := make([]byte, len(text))
+	mode.CryptBlocks(plain, text)
+	n := int(plain[len(plain)-1])
+	if n == 0 || n > block || !bytes.Equal(plain[len(plain)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return "", message("解密失败，密码或算法不正确")
+	}
+	return string(plain[:len(plain)-n]), nil
+}
+
+// Encrypt 使用随机盐(与初始向量)加密明文，返回ENC(...)形式的值
+func (j *Jasypt) Encrypt(plain string) (string, error) {
+	block := des.BlockSize
+	if j.aes {
+		block = aes.BlockSize
+	}
+	head := make([]byte, block)
+	if j.aes {
+		head = make([]byte, 2*aes.BlockSize)
+	}
+	if _, err := rand.Read(head); err != nil {
+		return "", fmt.Errorf(tr("生成随机盐失败: %w"), err)
+	}
+	mode, err := j.cipher(head[:block], head[block:], true)
+	if err != nil {
+		return "", err
+	}
+	n := block - len(plain)%block
+	text := append([]byte(plain), bytes.Repeat([]byte{byte(n)}, n)...)
+	mode.CryptBlocks(text, text)
+	return "ENC(" + base64.StdEncoding.EncodeToString(append(head, text...)) + ")", nil
+}
+
+// cipher 由密码与盐派生密钥，返回CBC模式的加密或解密器。
+// PBEWithMD5AndDES按PKCS#5 v1.5(PBKDF1)派生密钥与初始向量，AES按PBKDF2派生密钥并使用密文中的初始向量
+func (j *Jasypt) cipher(salt, iv []byte, encrypt bool) (cipher.BlockMode, error) {
+	var b cipher.Block
+	var err error
+	if j.aes {
+		b, err = aes.NewCipher(pbkdf2(j.prf, j.password, salt, j.iterations, j.keyLen))
+	} else {
+		dk := append(append([]byte(nil), j.password...), salt...)
+		for i := 0; i < j.iterations; i++ {
+			sum := md5.Sum(dk)
+			dk = sum[:]
+		}
+		b, err = des.NewCipher(dk[:8])
+		iv = dk[8:16]
+	}
+	if err != nil {
+		return nil, err
+	}
+	if encrypt {
+		return cipher.NewCBCEncrypter(b, iv), nil
+	}
+	return cipher.NewCBCDecrypter(b, iv), nil
+}
+
+// pbkdf2 按RFC 8018派生长度为keyLen的密钥
+func pbkdf2(prf func() hash.Hash, password, salt []byte, iterations, keyLen int) []byte {
+	mac := hmac.New(prf, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		mac.Reset()
+		mac.Write(salt)
+		mac.Write(binary.BigEndian.AppendUint32(nil, block))
+		u := mac.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			mac.Reset()
+			mac.Write(u)
+			u = mac.Sum(u[:0])
+			for k := range t {
+				t[k] ^= u[k]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// DecryptEntries 返回加密值替换为明文后的键值，供校验规则检查实际的值；
+// 无法解密的参数原样保留，并作为rule为encrypted的未通过项返回
+func DecryptEntries(entries []Entry, j *Jasypt) ([]Entry, []Violation) {
+	out := make([]Entry, len(entries))
+	var failed []Violation
+	for i, e := range entries {
+		out[i] = e
+		if !IsEncrypted(e.Value) {
+			continue
+		}
+		plain, err := j.Decrypt(e.Value)
+		if err != nil {
+			failed = append(failed, Violation{Key: e.Key, Line: e.Line, Rule: "encrypted", Message: fmt.Sprintf(tr("无法解密: %v"), err)})
+			continue
+		}
+		out[i].Value = plain
+	}
+	return out, failed
+}
@@ -0,0 +1,61 @@
+package propmerge
+
+import "testing"
+
+// jasyptVectors 为已知的密文与明文。PBEWithMD5AndDES的两条取自Jasypt的文档(jasypt.org的CLI示例与
+// jasypt-spring-boot的README)，由Jasypt本身生成；AES的各条按Jasypt的格式(16字节盐、16字节初始向量与密文)
+// 以Python hashlib的PBKDF2(1000次迭代)派生密钥、openssl enc加密生成，与本实现相互独立
+var jasyptVectors = []struct {
+	algorithm, password, ciphertext, plain string
+}{
+	{JasyptMD5DES, "MYPAS_WORD", "k1AwOd5XuW4VfPQtEXEdVlMnaNn19hivMbn1G4JQgq/jArjtKqryXksYX4Hl6A0e", "This is my message to be encrypted"},
+	{JasyptMD5DES, "password", "ENC(nrmZtkF7T0kjG/VodDvBw93Ct8EgjCA+)", "chupacabras"},
+	{JasyptHMACSHA512AES, "password", "ENC(AAECAwQFBgcICQoLDA0OD/Dh0sO0pZaHeGlaSzwtHg9XbahSsPLD4qZqXk3Rpuw3Y27el6wHbatZGT4EbbU0OQcdRVbPbyHOsOXh6rA+gQQ=)", "jdbc:mysql://db.internal:3306/app"},
+	{"PBEWithHmacSHA256AndAES_128", "s3cr3t!", "iJmqu8zd7v8AESIzRFVmdw8ODQwLCgkIBwYFBAMCAQA0dxoajCkeSSwObPSXWTnK", "10.0.0.1"},
+	{"PBEWITHHMACSHA1ANDAES_256", "中文密码", "E1eb3wJGis4TV5vfAkaKzv/u3cy7qpmId2ZVRDMiEQCQK/j6AiWE5lp0lwzPztiyksPdwp3SgzlSLGcix/jpqg==", "16-byte-plain!!!"},
+}
+
+func TestJasyptKnownAnswers(t *testing.T) {
+	for _, v := range jasyptVectors {
+		j, err := NewJasypt(v.password, v.algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := j.Decrypt(v.ciphertext)
+		if err != nil || plain != v.plain {
+			t.Errorf("%s: Decrypt(%s) = %q, %v; want %q", v.algorithm, v.ciphertext, plain, err, v.plain)
+		}
+
+		wrong, err := NewJasypt(v.password+"x", v.algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plain, err := wrong.Decrypt(v.ciphertext); err == nil {
+			t.Errorf("%s: Decrypt with a wrong password = %q, want an error", v.algorithm, plain)
+		}
+	}
+}
+
+func TestJasyptRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{JasyptMD5DES, JasyptHMACSHA512AES, "PBEWITHHMACSHA256ANDAES_128", "PBEWITHHMACSHA384ANDAES_256"} {
+		j, err := NewJasypt("password", algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, plain := range []string{"", "a", "exactly 16 bytes", "数据库密码"} {
+			enc, err := j.Encrypt(plain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsEncrypted(enc) {
+				t.Errorf("%s: Encrypt(%q) = %s, want ENC(...)", algorithm, plain, enc)
+			}
+			if got, err := j.Decrypt(enc); err != nil || got != plain {
+				t.Errorf("%s: Decrypt(Encrypt(%q)) = %q, %v", algorithm, plain, got, err)
+			}
+		}
+	}
+	if _, err := NewJasypt("password", "PBEWITHHMACSHA512ANDAES_192"); err == nil {
+		t.Error("NewJasypt accepted AES_192, want an error")
+	}
+}
@@ -0,0 +1,327 @@
+package propmerge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// IsJSONFile 根据扩展名判断是否为JSON文件
+func IsJSONFile(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == ".json"
+}
+
+// jsonNode 描述JSON文档中的一个值，path为从根开始以点连接的键路径，根值的path为空。
+// 数组作为整体处理，不记录其中的元素
+type jsonNode struct {
+	path       string
+	start      int  // 值的起始偏移
+	end        int  // 值之后的偏移
+	object     bool // 是否为对象
+	lastKey    int  // 对象最后一个成员的键的起始偏移，空对象为-1
+	lastMember int  // 对象最后一个成员的值之后的偏移，空对象为-1
+}
+
+// jsonParser 记录JSON文本中各值的位置，调用前文本已通过语法校验
+type jsonParser struct {
+	text  string
+	pos   int
+	nodes []jsonNode
+}
+
+// parseJSON 校验JSON文本并返回其中所有对象与非对象值的位置
+func parseJSON(text string) ([]jsonNode, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			return nil, fmt.Errorf(tr("解析JSON失败(行%d): %w"), lineAt(text, int(syntax.Offset)), err)
+		}
+		return nil, fmt.Errorf(tr("解析JSON失败: %w"), err)
+	}
+	p := &jsonParser{text: text}
+	p.value("")
+	return p.nodes, nil
+}
+
+// lineAt 返回偏移所在的行号(从1开始)
+func lineAt(text string, offset int) int {
+	if offset > len(text) {
+		offset = len(text)
+	}
+	return strings.Count(text[:offset], "\n") + 1
+}
+
+func (p *jsonParser) skipSpace() {
+	for p.pos < len(p.text) && strings.IndexByte(" \t\r\n", p.text[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *jsonParser) peek() byte {
+	if p.pos < len(p.text) {
+		return p.text[p.pos]
+	}
+	return 0
+}
+
+// value 解析一个值并记录其位置
+func (p *jsonParser) value(path string) {
+	p.skipSpace()
+	start := p.pos
+	switch p.peek() {
+	case '{':
+		p.object(path)
+		return
+	case '[':
+		p.array()
+	case '"':
+		p.str()
+	default:
+		for p.pos < len(p.text) && strings.IndexByte(",]} \t\r\n", p.text[p.pos]) < 0 {
+			p.pos++
+		}
+	}
+	p.nodes = append(p.nodes, jsonNode{path: path, start: start, end: p.pos, lastKey: -1, lastMember: -1})
+}
+
+// object 解析对象，先记录对象本身再记录各成员
+func (p *jsonParser) object(path string) {
+	idx := len(p.nodes)
+	p.nodes = append(p.nodes, jsonNode{path: path, start: p.pos, object: true, lastKey: -1, lastMember: -1})
+	p.pos++
+	for {
+		p.skipSpace()
+		if p.peek() == '}' {
+			p.pos++
+			p.nodes[idx].end = p.pos
+			return
+		}
+		if p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		keyStart := p.pos
+		key := p.str()
+		p.skipSpace()
+		p.pos++ // 冒号
+		child := key
+		if path != "" {
+			child = path + "." + key
+		}
+		p.value(child)
+		p.nodes[idx].lastKey, p.nodes[idx].lastMember = keyStart, p.pos
+	}
+}
+
+// array 跳过数组，不记录其中的元素
+func (p *jsonParser) array() {
+	saved := len(p.nodes)
+	p.pos++
+	for {
+		p.skipSpace()
+		switch p.peek() {
+		case ']':
+			p.pos++
+			p.nodes = p.nodes[:saved]
+			return
+		case ',':
+			p.pos++
+		default:
+			p.value("")
+		}
+	}
+}
+
+// str 解析字符串并返回解码后的内容
+func (p *jsonParser) str() string {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.text) && p.text[p.pos] != '"' {
+		if p.text[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	p.pos++
+	var s string
+	json.Unmarshal([]byte(p.text[start:p.pos]), &s)
+	return s
+}
+
+// findJSONNode 按路径查找值
+func findJSONNode(nodes []jsonNode, path string) (jsonNode, bool) {
+	for _, n := range nodes {
+		if n.path == path {
+			return n, true
+		}
+	}
+	return jsonNode{}, false
+}
+
+// jsonQuote 将键名编码为JSON字符串
+func jsonQuote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// jsonLineIndent 返回偏移所在行的行首缩进；该行在偏移之前还有其他内容(紧凑写法)时ok为false
+func jsonLineIndent(text string, offset int) (string, bool) {
+	start := strings.LastIndexByte(text[:offset], '\n') + 1
+	prefix := text[start:offset]
+	rest := strings.TrimLeft(prefix, " \t")
+	return prefix[:len(prefix)-len(rest)], rest == ""
+}
+
+// jsonIndentUnit 返回文件使用的缩进单位，取第一个以缩进开头的成员行，无法判断时为两个空格
+func jsonIndentUnit(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != line && strings.HasPrefix(trimmed, `"`) {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
+
+// jsonNested 为缺失的中间层级生成嵌套对象，multiline为false时生成紧凑写法
+func jsonNested(segments []string, value, indent, unit string, multiline bool) string {
+	if len(segments) == 0 {
+		return value
+	}
+	if !multiline {
+		return "{" + jsonQuote(segments[0]) + ": " + jsonNested(segments[1:], value, indent, unit, false) + "}"
+	}
+	inner := indent + unit
+	return "{\n" + inner + jsonQuote(segments[0]) + ": " + jsonNested(segments[1:], value, inner, unit, true) + "\n" + indent + "}"
+}
+
+// MergeJSON 从旧JSON中提取命中保留规则的值(以点分路径匹配，如server.port)，写入新JSON的对应位置。
+// 新文件中已存在的值原位替换，缺失的值作为最后一个成员插入到最深的已有父对象中，
+// 新文件的结构、键顺序与缩进保持不变；数组作为整体保留
+func (m *Merger) MergeJSON(old io.Reader, new io.Reader) (Result, error) {
+	oldLines, err := ReadLines(old)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+	newLines, err := ReadLines(new)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取新文件失败: %w"), err)
+	}
+	return m.MergeJSONLines(oldLines, newLines)
+}
+
+// MergeJSONLines 与MergeJSON相同，但直接处理已读取的行
+func (m *Merger) MergeJSONLines(oldLines, newLines []string) (Result, error) {
+	oldText := strings.Join(oldLines, "\n")
+	text := strings.Join(newLines, "\n")
+	oldNodes, err := parseJSON(oldText)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("旧文件%w"), err)
+	}
+	nodes, err := parseJSON(text)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("新文件%w"), err)
+	}
+
+	var kept []jsonNode
+	oldPaths := make(map[string]bool)
+	for _, o := range oldNodes {
+		if !o.object && o.path != "" && m.Matches(o.path+"="+oldText[o.start:o.end]) {
+			kept = append(kept, o)
+			oldPaths[o.path] = true
+		}
+	}
+
+	unit := jsonIndentUnit(text)
+	var results []KeyResult
+	for _, o := range kept {
+		value := oldText[o.start:o.end]
+		m.keyDebugf(o.path, lineAt(oldText, o.start), "", "找到匹配参数[行%d]: %s", lineAt(oldText, o.start), o.path)
+
+		result := KeyResult{Key: o.path, OldValue: value}
+		if !m.renamePath(&result, oldPaths) {
+			results = append(results, result)
+			continue
+		}
+		if m.transformResult(&result, true) {
+			value = result.OldValue
+		}
+
+		if n, ok := findJSONNode(nodes, result.Key); ok {
+			result.Action = ActionReplace
+			result.Line = lineAt(text, n.start)
+			result.NewValue = text[n.start:n.end]
+			if !m.renameCollision(&result) || !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			text = text[:n.start] + value + text[n.end:]
+			m.keyDebugf(result.Key, result.Line, ActionReplace, "替换参数[行%d]: %s", result.Line, result.Key)
+		} else {
+			inserted, line, ok := insertJSONMember(text, nodes, result.Key, value, unit)
+			if !ok {
+				m.keyDebugf(result.Key, 0, ActionSkip, "跳过参数(新文件中的父节点不是对象): %s", result.Key)
+				result.Action = ActionSkip
+				results = append(results, result)
+				continue
+			}
+			result.Action, result.Line = ActionInsert, line
+			if !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			text = inserted
+			m.keyDebugf(result.Key, result.Line, ActionInsert, "插入参数[行%d]: %s", result.Line, result.Key)
+		}
+		// 每次修改后重新定位各值，保证后续替换与插入的偏移正确
+		nodes, _ = parseJSON(text)
+		m.trace(TraceEvent{Event: "action", Line: result.Line, Key: result.Key, Text: result.Key + "=" + value, Result: result.Action})
+		results = append(results, result)
+	}
+	merged := Result{Lines: strings.Split(text, "\n"), Keys: results}
+	return merged, m.checkCollisions(merged)
+}
+
+// insertJSONMember 将值作为最后一个成员插入到路径最长的已有父对象中，缺失的中间层级以嵌套对象创建，
+// 返回插入后的文本与键所在行号；最近的已有父节点不是对象时ok为false
+func insertJSONMember(text string, nodes []jsonNode, path, value, unit string) (string, int, bool) {
+	segments := strings.Split(path, ".")
+	var parent jsonNode
+	depth := -1
+	for j := len(segments) - 1; j >= 0 && depth < 0; j-- {
+		if n, ok := findJSONNode(nodes, strings.Join(segments[:j], ".")); ok {
+			if !n.object {
+				return text, 0, false
+			}
+			parent, depth = n, j
+		}
+	}
+	if depth < 0 {
+		return text, 0, false
+	}
+
+	outer, _ := jsonLineIndent(text, parent.start)
+	indent, multiline := outer+unit, true
+	at := parent.end - 1
+	if parent.lastKey >= 0 {
+		indent, multiline = jsonLineIndent(text, parent.lastKey)
+		at = parent.lastMember
+	}
+	member := jsonQuote(segments[depth]) + ": " + jsonNested(segments[depth+1:], value, indent, unit, multiline)
+
+	var prefix, suffix string
+	switch {
+	case parent.lastKey >= 0 && multiline:
+		prefix = ",\n" + indent
+	case parent.lastKey >= 0:
+		prefix = ", "
+	default:
+		prefix, suffix = "\n"+indent, "\n"+outer
+	}
+	line := lineAt(text, at) + strings.Count(prefix, "\n")
+	return text[:at] + prefix + member + suffix + text[at:], line, true
+}
@@ -0,0 +1,106 @@
+package propmerge
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// mergesLists 判断是否有保留规则设置了mergeList
+func (m *Merger) mergesLists() bool {
+	for _, r := range m.rules {
+		if r.rule.MergeList != "" && r.rule.MergeList != ListOld {
+			return true
+		}
+	}
+	return false
+}
+
+// listMerge 返回键命中的第一条结构化保留规则的mergeList，未命中或未设置时为空
+func (m *Merger) listMerge(key string) string {
+	candidates := []string{key}
+	if m.opts.SpringRelaxed {
+		candidates = append(candidates, SpringKebab(key), SpringCanonical(key))
+	}
+	for _, k := range candidates {
+		if r, ok := m.matchRule(k); ok {
+			return r.rule.MergeList
+		}
+	}
+	return ""
+}
+
+// mergeListResult 按保留规则的mergeList合并新文件中已存在的参数的列表值，改写前的旧值记录在result.TransformedFrom中。
+// quoted为true时值为YAML中的原始文本，带引号时合并引号内的列表，结果沿用旧值的引号。返回值是否被改写
+func (m *Merger) mergeListResult(result *KeyResult, quoted bool) bool {
+	key := result.Key
+	if result.RenamedFrom != "" {
+		key = result.RenamedFrom
+	}
+	if result.Secret || len(m.rules) == 0 {
+		return false
+	}
+	var merged string
+	switch m.listMerge(key) {
+	case ListNew:
+		merged = result.NewValue
+	case ListUnion:
+		oldValue, newValue, quote := result.OldValue, result.NewValue, ""
+		if quoted {
+			oldValue, quote = unquoteValue(oldValue)
+			newValue, _ = unquoteValue(newValue)
+		}
+		merged = unionList(newValue, oldValue)
+		switch quote {
+		case `"`:
+			merged = jsonQuote(merged)
+		case "'":
+			merged = "'" + merged + "'"
+		}
+	default:
+		return false
+	}
+	if merged == result.OldValue {
+		return false
+	}
+	m.keyDebugf(result.Key, result.Line, "", "合并列表值: %s: %s -> %s", result.Key, m.opts.Mask.Value(result.Key, result.OldValue), m.opts.Mask.Value(result.Key, merged))
+	if result.TransformedFrom == "" {
+		result.TransformedFrom = result.OldValue
+	}
+	result.OldValue = merged
+	return true
+}
+
+// unionList 合并两个逗号分隔的列表: first的各项在前，second中first没有的项依次追加在后，
+// 去掉各项两侧的空白、空项与重复项；first中逗号后带空格时结果同样以", "分隔
+func unionList(first, second string) string {
+	sep := ","
+	if strings.Contains(first, ", ") {
+		sep = ", "
+	}
+	seen := make(map[string]bool)
+	var items []string
+	for _, list := range []string{first, second} {
+		for _, item := range strings.Split(list, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" || seen[item] {
+				continue
+			}
+			seen[item] = true
+			items = append(items, item)
+		}
+	}
+	return strings.Join(items, sep)
+}
+
+// unquoteValue 去掉YAML等格式中值两侧的引号，返回引号内的文本与使用的引号，未加引号时quote为空
+func unquoteValue(value string) (text, quote string) {
+	if len(value) < 2 || (value[0] != '"' && value[0] != '\'') || value[len(value)-1] != value[0] {
+		return value, ""
+	}
+	quote = value[:1]
+	if quote == `"` {
+		json.Unmarshal([]byte(value), &value)
+		return value, quote
+	}
+	return value[1 : len(value)-1], quote
+}
@@ -0,0 +1,64 @@
+package propmerge
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultSensitiveKeys 为配置文件未定义sensitiveKeys时视为敏感的键名片段
+var DefaultSensitiveKeys = []string{"password", "secret", "token", "key"}
+
+// MaskedValue 为敏感参数的值在日志与控制台输出中的显示内容
+const MaskedValue = "****"
+
+// Masker 在日志、跟踪记录与控制台输出中隐藏敏感参数的值，nil *Masker 原样返回所有内容
+type Masker struct {
+	re *regexp.Regexp
+}
+
+// NewMasker 创建Masker，patterns中每一项为不区分大小写的键名正则，命中键名任意部分即视为敏感；
+// patterns为空时返回nil，即不隐藏任何值
+func NewMasker(patterns []string) (*Masker, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	re, err := regexp.Compile("(?i)(?:" + strings.Join(patterns, "|") + ")")
+	if err != nil {
+		return nil, fmt.Errorf(tr("编译敏感键规则失败: %w"), err)
+	}
+	return &Masker{re: re}, nil
+}
+
+// Sensitive 判断键是否为敏感参数
+func (k *Masker) Sensitive(key string) bool {
+	return k != nil && k.re.MatchString(key)
+}
+
+// Value 返回用于显示的值: 敏感参数的非空值替换为MaskedValue
+func (k *Masker) Value(key, value string) string {
+	if value == "" || !k.Sensitive(key) {
+		return value
+	}
+	return MaskedValue
+}
+
+// Line 返回用于显示的配置行: 敏感参数的值替换为MaskedValue，保留键名、分隔符两侧的空白与行尾注释。
+// 同时支持key=value与YAML的key: value，注释行与空值原样返回
+func (k *Masker) Line(line string) string {
+	if k == nil || isComment(line) {
+		return line
+	}
+	idx := strings.IndexAny(line, "=:")
+	if idx == -1 || !k.Sensitive(strings.TrimSpace(line[:idx])) {
+		return line
+	}
+
+	rest := line[idx+1:]
+	lead := rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]
+	value, comment := splitInlineComment(rest[len(lead):])
+	if strings.TrimSpace(value) == "" {
+		return line
+	}
+	return line[:idx+1] + lead + MaskedValue + comment
+}
@@ -0,0 +1,708 @@
+package propmerge
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Extract 返回旧文件中命中保留规则的行(键为从1开始的行号)，以及因旧值等于默认值而跳过的键。
+// 以反斜杠续行的参数作为一个逻辑行返回，各物理行以"\n"连接，键为其第一行的行号
+func (m *Merger) Extract(lines []string) (map[int]string, []string) {
+	x := m.newExtractor()
+	for i, line := range foldLines(lines, continuedLine) {
+		x.add(i+1, line)
+	}
+	return x.finish()
+}
+
+// extractor 逐行收集旧文件中的保留参数，供Extract与流式扫描共用
+type extractor struct {
+	m          *Merger
+	keep       map[int]string
+	skipped    []string
+	directives keepDirectives
+}
+
+// newExtractor 创建extractor并输出使用的规则
+func (m *Merger) newExtractor() *extractor {
+	if m.opts.Pattern != "" {
+		m.debugf("使用匹配规则: %s", m.opts.Pattern)
+	}
+	if len(m.rules) > 0 {
+		m.debugf("使用%d条结构化保留规则", len(m.rules))
+	}
+	return &extractor{m: m, keep: make(map[int]string)}
+}
+
+// add 处理旧文件中的一行，lineNum从1开始
+func (x *extractor) add(lineNum int, line string) {
+	m := x.m
+	if line == continuedLine {
+		// 已并入上一逻辑行的续行，宽泛的规则(如.)也不能把占位当作参数
+		return
+	}
+	if x.directives.forced(m, lineNum, line) {
+		// 注释指令优先于匹配规则、排除规则与默认值
+		x.keep[lineNum] = strings.TrimSuffix(line, "\r")
+		m.keyDebugf(LineKey(line), lineNum, "", "按注释指令保留参数[行%d]: %s", lineNum, m.opts.Mask.Line(line))
+		m.trace(TraceEvent{Event: "scan", File: m.opts.SourceName, Line: lineNum, Key: LineKey(line), Text: line, Result: "directive"})
+		return
+	}
+	comment, matched := m.match(line)
+	if matched && m.isDefaultValue(line) {
+		x.skipped = append(x.skipped, LineKey(line))
+		m.keyDebugf(LineKey(line), lineNum, ActionSkip, "跳过与默认值相同的参数[行%d]: %s", lineNum, m.opts.Mask.Line(line))
+		m.trace(TraceEvent{Event: "scan", File: m.opts.SourceName, Line: lineNum, Key: LineKey(line), Text: line, Result: "default"})
+	} else if matched {
+		x.keep[lineNum] = strings.TrimSuffix(line, "\r")
+		if comment != "" {
+			m.keyDebugf(LineKey(line), lineNum, "", "找到匹配参数[行%d]: %s (规则: %s)", lineNum, m.opts.Mask.Line(line), comment)
+		} else {
+			m.keyDebugf(LineKey(line), lineNum, "", "找到匹配参数[行%d]: %s", lineNum, m.opts.Mask.Line(line))
+		}
+		m.trace(TraceEvent{Event: "scan", File: m.opts.SourceName, Line: lineNum, Key: LineKey(line), Text: line, Result: "match"})
+	} else {
+		m.trace(TraceEvent{Event: "scan", File: m.opts.SourceName, Line: lineNum, Text: line, Result: "skip"})
+	}
+}
+
+// finish 返回收集到的保留参数与跳过的键
+func (x *extractor) finish() (map[int]string, []string) {
+	x.directives.finish(x.m)
+	x.m.debugf("共找到%d个需要保留的参数", len(x.keep))
+	return x.keep, x.skipped
+}
+
+// isDefaultValue 判断配置行的值是否与配置的默认值相同
+func (m *Merger) isDefaultValue(line string) bool {
+	if len(m.opts.Defaults) == 0 || separator(line) == -1 {
+		return false
+	}
+	def, ok := m.opts.Defaults[LineKey(line)]
+	return ok && def == LineValue(line)
+}
+
+// AutoKeep 不使用匹配规则，自动将两个文件中都存在且值不同的键视为需要保留的本地定制参数；
+// 开启AutoPreserveOldOnly时同时保留仅存在于旧文件中的键，开启PreserveEncrypted时同时保留仅存在于旧文件中的加密值
+func (m *Merger) AutoKeep(oldLines, newLines []string) map[int]string {
+	oldLines = foldLines(oldLines, continuedLine)
+	_, oldProps := ParseProperties(oldLines)
+	_, newProps := ParseProperties(newLines)
+	keep := make(map[int]string)
+	for _, p := range oldProps {
+		n, inNew := newProps[p.Key]
+		encrypted := m.opts.PreserveEncrypted && IsEncrypted(p.Value)
+		if (inNew && n.Value != p.Value) || (!inNew && (m.opts.AutoPreserveOldOnly || encrypted)) {
+			if index, excluded := m.excluded(p.Key); excluded {
+				m.keyDebugf(p.Key, p.Line, ActionSkip, "参数命中保留规则，但被excludeKeys第%d条排除: %s", index, p.Key)
+				continue
+			}
+			if index, forced := m.forcedNew(p.Key); forced {
+				m.keyDebugf(p.Key, p.Line, ActionSkip, "参数命中保留规则，但rules第%d条要求取新文件中的值: %s", index, p.Key)
+				continue
+			}
+			line := strings.TrimSuffix(oldLines[p.Line-1], "\r")
+			keep[p.Line] = line
+			m.keyDebugf(p.Key, p.Line, "", "自动保留参数[行%d]: %s", p.Line, m.opts.Mask.Line(line))
+			m.trace(TraceEvent{Event: "scan", File: m.opts.SourceName, Line: p.Line, Key: p.Key, Text: line, Result: "auto"})
+		}
+	}
+
+	forced := m.DirectiveLines(oldLines)
+	for i := range oldLines {
+		lineNum := i + 1
+		if _, ok := keep[lineNum]; ok || !forced[lineNum] {
+			continue
+		}
+		line := strings.TrimSuffix(oldLines[i], "\r")
+		keep[lineNum] = line
+		m.keyDebugf(LineKey(line), lineNum, "", "按注释指令保留参数[行%d]: %s", lineNum, m.opts.Mask.Line(line))
+		m.trace(TraceEvent{Event: "scan", File: m.opts.SourceName, Line: lineNum, Key: LineKey(line), Text: line, Result: "directive"})
+	}
+
+	m.debugf("自动推导出%d个需要保留的参数", len(keep))
+	return keep
+}
+
+// commentsAbove 返回每个保留参数在旧文件中紧邻上方的连续注释行(不含来源注释)，
+// 未开启PreserveComments时返回nil
+func (m *Merger) commentsAbove(oldLines []string, keep map[int]string) map[int][]string {
+	if !m.opts.PreserveComments {
+		return nil
+	}
+
+	comments := make(map[int][]string)
+	for lineNum := range keep {
+		if lineNum > len(oldLines) {
+			// Secrets中旧文件没有的参数
+			continue
+		}
+		start := lineNum - 1
+		for start > 0 && isComment(oldLines[start-1]) {
+			start--
+		}
+		var block []string
+		for _, line := range oldLines[start : lineNum-1] {
+			line = strings.TrimSuffix(line, "\r")
+			if m.provenanceRe != nil && m.provenanceRe.MatchString(line) {
+				continue
+			}
+			block = append(block, line)
+		}
+		if len(block) > 0 {
+			comments[lineNum] = block
+		}
+	}
+	return comments
+}
+
+// insertComments 在index处插入注释块，新文件中该位置上方已有相同注释时不重复插入。返回插入的行数
+func insertComments(lines []string, index int, block []string) ([]string, int) {
+	if index >= len(block) && equalLines(lines[index-len(block):index], block) {
+		return lines, 0
+	}
+	for i, line := range block {
+		lines = insertLine(lines, index+i, line)
+	}
+	return lines, len(block)
+}
+
+// equalLines 判断两组行内容是否相同(忽略首尾空白)
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if strings.TrimSpace(a[i]) != strings.TrimSpace(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// renameTarget 返回键按重命名与移动规则改写后的键名，ok表示键名发生了变化。
+// renames按完整键名匹配，优先于moves；moves取能匹配的最长路径，路径本身及其下以.或[继续的键一起移动
+func (m *Merger) renameTarget(key string) (target string, ok bool) {
+	if target, ok := m.opts.Renames[key]; ok {
+		return target, target != key
+	}
+	from, found := "", false
+	for prefix := range m.opts.Moves {
+		if len(prefix) < len(from) || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if rest := key[len(prefix):]; rest == "" || rest[0] == '.' || rest[0] == '[' {
+			from, found = prefix, true
+		}
+	}
+	if !found {
+		return key, false
+	}
+	target = m.opts.Moves[from] + key[len(from):]
+	return target, target != key
+}
+
+// renamePath 按重命名与移动规则把YAML/TOML/JSON的旧路径改写为新路径，记录到result.RenamedFrom。
+// 旧文件中同时存在目标路径时记录冲突并返回false，此时以旧文件中的目标路径为准
+func (m *Merger) renamePath(result *KeyResult, oldPaths map[string]bool) bool {
+	target, ok := m.renameTarget(result.Key)
+	if !ok {
+		return true
+	}
+	result.RenamedFrom, result.Key = result.Key, target
+	m.keyDebugf(target, 0, "", "重命名参数: %s -> %s", result.RenamedFrom, target)
+	if oldPaths[target] {
+		result.Collision = tr("旧文件中同时存在目标键，以旧文件中的目标键为准")
+		result.Action = ActionSkip
+		m.trace(TraceEvent{Event: "action", Key: target, Result: "collision"})
+		return false
+	}
+	return true
+}
+
+// renameCollision 重命名后的键在新文件中已存在且值不同时记录冲突，策略为new-wins时跳过该参数并返回false
+func (m *Merger) renameCollision(result *KeyResult) bool {
+	if result.RenamedFrom == "" || result.NewValue == result.OldValue {
+		return true
+	}
+	result.Collision = fmt.Sprintf(tr("新文件中已存在 %s=%s"), result.Key, m.opts.Mask.Value(result.Key, result.NewValue))
+	if m.opts.CollisionPolicy != CollisionNewWins {
+		return true
+	}
+	result.Action = ActionSkip
+	m.trace(TraceEvent{Event: "action", Line: result.Line, Key: result.Key, Result: "collision"})
+	return false
+}
+
+// apply 将保留参数应用到新文件内容上，按旧文件行号从小到大处理以保证插入位置稳定。
+// comments为各保留参数需要随插入一起写入的注释块
+func (m *Merger) apply(lines []string, keep map[int]string, comments map[int][]string) ([]string, []KeyResult) {
+	directKeys := make(map[string]bool, len(keep))
+	for _, line := range keep {
+		directKeys[LineKey(line)] = true
+	}
+
+	results := make([]KeyResult, 0, len(keep))
+	for _, oldLineNum := range sortedLineNums(keep) {
+		oldLine := keep[oldLineNum]
+		key := LineKey(oldLine)
+		result := KeyResult{Key: key, OldValue: LineValue(oldLine), Secret: m.isSecret(key)}
+
+		// 按重命名规则将旧键的值写到新键名下
+		if target, ok := m.renameTarget(key); ok {
+			result.RenamedFrom = key
+			result.Key = target
+			oldLine = EscapeKey(target, m.escapeASCII()) + oldLine[separator(oldLine):]
+			key = target
+			m.keyDebugf(target, 0, "", "重命名参数: %s -> %s", result.RenamedFrom, target)
+			if directKeys[target] {
+				result.Collision = tr("旧文件中同时存在目标键，以旧文件中的目标键为准")
+				result.Action = ActionSkip
+				m.trace(TraceEvent{Event: "action", Key: key, Text: oldLine, Result: "collision"})
+				results = append(results, result)
+				continue
+			}
+		}
+
+		newLineNum := m.findKey(lines, key)
+		if newLineNum != -1 {
+			m.trace(TraceEvent{Event: "lookup", Line: newLineNum + 1, Key: key, Result: "found"})
+		} else {
+			m.trace(TraceEvent{Event: "lookup", Key: key, Result: "not-found"})
+		}
+
+		if newLineNum != -1 {
+			result.Line = newLineNum + 1
+			result.NewValue = LineValue(lines[newLineNum])
+		}
+		if m.threeWay(&result, newLineNum != -1) {
+			m.trace(TraceEvent{Event: "action", Line: result.Line, Key: key, Text: oldLine, Result: "base"})
+			results = append(results, result)
+			continue
+		}
+		if !result.Secret && m.transformResult(&result, false) {
+			oldLine = withLineValue(oldLine, EscapeValue(result.OldValue, m.escapeASCII()))
+		}
+
+		if newLineNum != -1 {
+			if result.RenamedFrom != "" && result.NewValue != result.OldValue {
+				result.Collision = fmt.Sprintf(tr("新文件中已存在 %s=%s"), key, m.opts.Mask.Value(key, result.NewValue))
+				if m.opts.CollisionPolicy == CollisionNewWins {
+					result.Action = ActionSkip
+					m.trace(TraceEvent{Event: "action", Line: result.Line, Key: key, Text: oldLine, Result: "collision"})
+					results = append(results, result)
+					continue
+				}
+			}
+
+			if m.mergeListResult(&result, false) {
+				oldLine = withLineValue(oldLine, EscapeValue(result.OldValue, m.escapeASCII()))
+			}
+
+			result.Action = ActionReplace
+			if !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			m.keyDebugf(key, newLineNum+1, ActionReplace, "替换参数[行%d]: %s", newLineNum+1, key)
+			oldLine = m.replacementLine(lines[newLineNum], oldLine)
+			lines[newLineNum] = oldLine
+		} else {
+			policy := m.obsoletePolicy(result)
+			if policy == ObsoleteDrop {
+				m.drop(&result)
+				results = append(results, result)
+				continue
+			}
+			if policy == ObsoleteComment {
+				oldLine = obsoleteComment(oldLine)
+			}
+			insertAt, ok := 0, false
+			if policy != ObsoleteAppend {
+				insertAt, ok = m.insertIndex(lines, key, oldLineNum)
+			}
+			if ok {
+				result.Action = ActionInsert
+				result.Line = insertAt + 1
+				if policy == ObsoleteComment {
+					result.Action = ActionComment
+				} else if !m.confirm(&result) {
+					results = append(results, result)
+					continue
+				}
+				m.keyDebugf(key, insertAt+1, result.Action, insertMessage(result.Action), insertAt+1, key)
+				var n int
+				lines, n = insertComments(lines, insertAt, comments[oldLineNum])
+				result.Line = insertAt + n + 1
+				lines = insertLine(lines, insertAt+n, oldLine)
+			} else {
+				result.Action = ActionAppend
+				result.Line = len(lines) + 1
+				if policy == ObsoleteComment {
+					result.Action = ActionComment
+				} else if !m.confirm(&result) {
+					results = append(results, result)
+					continue
+				}
+				lines, _ = insertComments(lines, len(lines), comments[oldLineNum])
+				m.keyDebugf(key, len(lines)+1, result.Action, insertMessage(result.Action), len(lines)+1, key)
+				result.Line = len(lines) + 1
+				lines = append(lines, oldLine)
+			}
+		}
+		if m.provenanceRe != nil && result.Action != ActionComment && !result.Secret {
+			var inserted bool
+			lines, inserted = m.applyProvenance(lines, result.Line-1, oldLineNum, result.Action == ActionReplace)
+			if inserted {
+				result.Line++
+			}
+		}
+		m.trace(TraceEvent{Event: "action", Line: result.Line, Key: key, Text: oldLine, Result: result.Action})
+		results = append(results, result)
+	}
+	return lines, results
+}
+
+// obsoletePolicy 返回新文件中已不存在的保留参数的处理策略，ObsoleteAsk时询问AskObsolete
+func (m *Merger) obsoletePolicy(result KeyResult) string {
+	policy := m.opts.ObsoletePolicy
+	if policy == ObsoleteAsk {
+		policy = m.opts.AskObsolete(result)
+	}
+	switch policy {
+	case ObsoleteAppend, ObsoleteComment, ObsoleteDrop:
+		return policy
+	}
+	return ObsoleteInsert
+}
+
+// drop 按ObsoleteDrop不写入新文件中已不存在的参数
+func (m *Merger) drop(result *KeyResult) {
+	result.Action = ActionDrop
+	m.keyDebugf(result.Key, 0, ActionDrop, "新文件中已不存在，不予写入: %s", result.Key)
+	m.trace(TraceEvent{Event: "action", Key: result.Key, Result: ActionDrop})
+}
+
+// obsoleteComment 返回按ObsoleteComment写入的注释行，续行的各物理行分别注释掉
+func obsoleteComment(line string) string {
+	return ObsoleteMarker + strings.ReplaceAll(line, "\n", "\n# ")
+}
+
+// insertMessage 返回插入类动作的日志格式
+func insertMessage(action string) string {
+	switch action {
+	case ActionAppend:
+		return "追加参数[行%d]: %s"
+	case ActionComment:
+		return "以注释形式写入已删除的参数[行%d]: %s"
+	}
+	return "插入参数[行%d]: %s"
+}
+
+// insertIndex 按插入策略返回新文件中不存在的保留参数的插入位置(行切片下标)，ok为false时追加到文件末尾
+func (m *Merger) insertIndex(lines []string, key string, oldLineNum int) (int, bool) {
+	switch m.opts.InsertStrategy {
+	case InsertAppend:
+		return 0, false
+	case InsertAnchor:
+		anchor := m.anchorLine(lines, key)
+		if anchor == -1 {
+			m.keyDebugf(key, 0, "", "新文件中没有与之共享前缀的参数，追加到末尾: %s", key)
+			return 0, false
+		}
+		m.keyDebugf(key, anchor+1, "", "按同前缀的参数[行%d]定位插入位置: %s", anchor+1, key)
+		return anchor + 1, true
+	}
+
+	if oldLineNum > len(lines) {
+		return 0, false
+	}
+	insertAt := oldLineNum - 1
+	if m.provenanceRe != nil && insertAt > 0 && m.provenanceRe.MatchString(lines[insertAt-1]) {
+		// 不要插入到其他参数与其来源注释之间
+		insertAt--
+	}
+	return insertAt, true
+}
+
+// anchorLine 返回与键共享最长点分前缀(至少一段)的最后一个参数所在的行，如spring.redis.timeout定位到
+// spring.redis.*参数块的最后一行；没有共享前缀的参数时返回-1
+func (m *Merger) anchorLine(lines []string, key string) int {
+	parts := strings.Split(m.lookupKey(key), ".")
+	best, anchor := 0, -1
+	for i, line := range lines {
+		if isComment(line) || separator(line) == -1 {
+			continue
+		}
+		other := strings.Split(m.lookupKey(LineKey(line)), ".")
+		n := 0
+		for n < len(parts)-1 && n < len(other) && parts[n] == other[n] {
+			n++
+		}
+		if n > 0 && n >= best {
+			best, anchor = n, i
+		}
+	}
+	return anchor
+}
+
+// threeWay 设置了基线时按三方比较决定保留参数的去留，返回true表示采用新文件(跳过该参数):
+// 旧值与基线相同说明本地未修改，直接采用新文件；旧值与新值均偏离基线且互不相同时为真正的冲突，
+// 按ConflictPolicy处理并记录在Conflict中
+func (m *Merger) threeWay(result *KeyResult, inNew bool) bool {
+	if m.opts.Base == nil {
+		return false
+	}
+
+	baseKey := result.Key
+	if result.RenamedFrom != "" {
+		baseKey = result.RenamedFrom
+	}
+	base, inBase := m.opts.Base[baseKey]
+	if inBase && base == result.OldValue {
+		m.keyDebugf(result.Key, 0, ActionSkip, "旧值与基线相同，采用新文件: %s", result.Key)
+		result.Action = ActionSkip
+		return true
+	}
+	if !inNew || result.NewValue == result.OldValue || (inBase && result.NewValue == base) {
+		return false
+	}
+
+	if inBase {
+		result.Conflict = fmt.Sprintf(tr("基线=%s, 旧值与新值均已修改"), base)
+	} else {
+		result.Conflict = tr("基线中不存在, 旧文件与新文件均新增了该键")
+	}
+	m.keyWarnf(result.Key, result.Line, "", "三方合并冲突: %s (%s)", result.Key, result.Conflict)
+	if m.opts.ConflictPolicy == CollisionNewWins {
+		result.Action = ActionSkip
+		return true
+	}
+	return false
+}
+
+// valueConflict 在设置了DetectConflicts且未设置Base时检查要替换的参数旧值与新值是否不同，
+// 不同时记录冲突，策略为CollisionNewWins时将结果标记为跳过并返回true
+func (m *Merger) valueConflict(result *KeyResult) bool {
+	if !m.opts.DetectConflicts || m.opts.Base != nil || result.Action != ActionReplace || result.NewValue == result.OldValue {
+		return false
+	}
+	result.Conflict = tr("旧值与新值不同")
+	m.keyDebugf(result.Key, result.Line, "", "冲突: %s (%s)", result.Key, result.Conflict)
+	if m.opts.ConflictPolicy == CollisionNewWins {
+		m.trace(TraceEvent{Event: "action", Line: result.Line, Key: result.Key, Result: "conflict"})
+		result.Action = ActionSkip
+		return true
+	}
+	return false
+}
+
+// confirm 设置了Confirm回调时询问是否执行计划的动作，被拒绝时将结果标记为跳过。
+// 替换前先按DetectConflicts检查旧值与新值的冲突；命中policy为prompt的规则且会改变值时标记Prompt
+func (m *Merger) confirm(result *KeyResult) bool {
+	if m.valueConflict(result) {
+		return false
+	}
+	key := result.Key
+	if result.RenamedFrom != "" {
+		key = result.RenamedFrom
+	}
+	result.Prompt = result.Changed() && m.prompted(key)
+	if m.opts.Confirm == nil || m.opts.Confirm(*result) {
+		return true
+	}
+	m.keyDebugf(result.Key, result.Line, ActionSkip, "跳过参数(未确认): %s", result.Key)
+	m.trace(TraceEvent{Event: "action", Line: result.Line, Key: result.Key, Result: "declined"})
+	result.Action = ActionSkip
+	result.Declined = true
+	return false
+}
+
+// provenanceComment 按格式生成来源注释，支持 {file}、{line}、{run} 占位符；
+// 格式不以注释符开头时自动加上"# "，保证写入properties文件后仍是合法注释
+func provenanceComment(format, file string, line int, run string) string {
+	comment := strings.NewReplacer("{file}", file, "{line}", fmt.Sprint(line), "{run}", run).Replace(format)
+	if !strings.HasPrefix(comment, "#") && !strings.HasPrefix(comment, "!") {
+		comment = "# " + comment
+	}
+	return comment
+}
+
+// provenancePattern 根据注释格式生成用于识别已有来源注释的正则
+func provenancePattern(format string) *regexp.Regexp {
+	comment := provenanceComment(format, "\x00", 0, "\x00")
+	quoted := regexp.QuoteMeta(comment)
+	quoted = strings.ReplaceAll(quoted, "\x00", ".*")
+	quoted = strings.ReplaceAll(quoted, "0", "[0-9]+")
+	return regexp.MustCompile("^" + quoted + "$")
+}
+
+// applyProvenance 在index处的保留参数上方写入来源注释。替换已有参数时，其上方已存在的
+// 来源注释会被原地更新而不重复累加。返回更新后的内容以及是否新插入了注释行
+func (m *Merger) applyProvenance(lines []string, index, oldLineNum int, replaced bool) ([]string, bool) {
+	p := m.opts.Provenance
+	comment := provenanceComment(p.Format, p.Source, oldLineNum, p.Run)
+	if replaced && index > 0 && m.provenanceRe.MatchString(lines[index-1]) {
+		lines[index-1] = comment
+		return lines, false
+	}
+	return insertLine(lines, index, comment), true
+}
+
+// RemovedLine 记录修复时被删除的行
+type RemovedLine struct {
+	Line int
+	Text string
+}
+
+// Repair 修复被旧版本错误合并的文件: 合并重复的保留键(保留写有旧文件中的值的那一处)，
+// 删除错位插入的多余副本，再按当前插入策略重新放置缺失的保留参数
+func (m *Merger) Repair(oldLines, current []string) (Result, []RemovedLine) {
+	oldLines = foldLines(oldLines, continuedLine)
+	keep, skipped := m.Extract(oldLines)
+	keep = m.withSecrets(keep, len(oldLines))
+	repaired, removed := m.dedupeKeepKeys(foldLines(current, continuedLine), keep)
+	lines, keys := m.apply(repaired, keep, m.commentsAbove(oldLines, keep))
+	m.normalize(lines)
+	result := Result{Lines: lines, Keys: keys, SkippedDefaults: skipped}
+	unfoldResult(&result)
+	return result, removed
+}
+
+// RepairTemplate 以新模板template为基础重建被错误合并的文件current: 模板中的参数沿用current中的值
+// (合并后手工修改的值不会丢失)，保留参数按当前插入策略重新放置；current中既不在模板中也不是保留参数的键
+// 与保留参数的重复副本记录在removed中
+func (m *Merger) RepairTemplate(oldLines, current, template []string) (Result, []RemovedLine) {
+	oldLines = foldLines(oldLines, continuedLine)
+	keep, skipped := m.Extract(oldLines)
+	keep = m.withSecrets(keep, len(oldLines))
+	keys := keepKeys(keep)
+	current = foldLines(current, continuedLine)
+	// 保留参数的重复副本与Repair相同地记录，保留参数本身由apply按模板与插入策略重新放置
+	_, removed := m.dedupeKeepKeys(current, keep)
+
+	template = foldLines(template, continuedLine)
+	inTemplate := make(map[string]bool, len(template))
+	for _, line := range template {
+		if line != continuedLine && !isComment(line) && separator(line) != -1 {
+			inTemplate[LineKey(line)] = true
+		}
+	}
+
+	edited := make(map[string]string)
+	for i, line := range current {
+		if line == continuedLine || isComment(line) || separator(line) == -1 {
+			continue
+		}
+		key := LineKey(line)
+		switch {
+		case keys[key]:
+			// 保留参数不沿用current中的位置与值
+		case !inTemplate[key]:
+			m.keyDebugf(key, i+1, "", "删除模板中不存在的参数[行%d]: %s", i+1, key)
+			removed = append(removed, RemovedLine{Line: i + 1, Text: line})
+		default:
+			if _, ok := edited[key]; !ok {
+				edited[key] = line
+			}
+		}
+	}
+
+	rebuilt := make([]string, len(template))
+	for i, line := range template {
+		rebuilt[i] = line
+		if line == continuedLine || isComment(line) || separator(line) == -1 {
+			continue
+		}
+		if e, ok := edited[LineKey(line)]; ok {
+			rebuilt[i] = e
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Line < removed[j].Line })
+	lines, results := m.apply(rebuilt, keep, m.commentsAbove(oldLines, keep))
+	m.normalize(lines)
+	result := Result{Lines: lines, Keys: results, SkippedDefaults: skipped}
+	unfoldResult(&result)
+	return result, removed
+}
+
+// keepKeys 返回保留参数的键集合
+func keepKeys(keep map[int]string) map[string]bool {
+	keys := make(map[string]bool, len(keep))
+	for _, line := range keep {
+		keys[LineKey(line)] = true
+	}
+	return keys
+}
+
+// dedupeKeepKeys 对每个保留键只保留一处: 优先保留值与旧文件相同的那一处(错误合并时写入旧值的副本)，
+// 没有时保留第一次出现的位置，删除其余重复行
+func (m *Merger) dedupeKeepKeys(lines []string, keep map[int]string) ([]string, []RemovedLine) {
+	values := make(map[string]string, len(keep))
+	for _, lineNum := range sortedLineNums(keep) {
+		// 旧文件中同一个键出现多次时与apply相同，以最后一处的值为准
+		values[LineKey(keep[lineNum])] = LineValue(keep[lineNum])
+	}
+
+	chosen := make(map[string]int, len(values))
+	for i, line := range lines {
+		if line == continuedLine || separator(line) == -1 {
+			continue
+		}
+		key := LineKey(line)
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		if first, seen := chosen[key]; !seen || (LineValue(line) == value && LineValue(lines[first]) != value) {
+			chosen[key] = i
+		}
+	}
+
+	result := make([]string, 0, len(lines))
+	var removed []RemovedLine
+	for i, line := range lines {
+		if line != continuedLine && separator(line) != -1 {
+			key := LineKey(line)
+			if at, ok := chosen[key]; ok && at != i {
+				m.keyDebugf(key, i+1, "", "删除重复参数[行%d]: %s", i+1, key)
+				removed = append(removed, RemovedLine{Line: i + 1, Text: line})
+				continue
+			}
+		}
+		result = append(result, line)
+	}
+	return result, removed
+}
+
+// Split 将旧文件拆分为只包含命中规则参数的覆盖内容和包含其余内容的模板，
+// 紧邻参数上方的注释块随该参数进入同一侧。kept为覆盖内容中的参数数量
+func (m *Merger) Split(lines []string) (overlay, template []string, kept int) {
+	lines = foldLines(lines, continuedLine)
+	keep, _ := m.Extract(lines)
+	var pending []string
+	for i, line := range lines {
+		if line == continuedLine {
+			continue
+		}
+		if isComment(line) {
+			pending = append(pending, line)
+			continue
+		}
+
+		if _, ok := keep[i+1]; ok {
+			overlay = append(overlay, pending...)
+			overlay = append(overlay, line)
+		} else {
+			template = append(template, pending...)
+			template = append(template, line)
+		}
+		pending = nil
+	}
+	template = append(template, pending...)
+	overlay, _ = unfoldLines(overlay)
+	template, _ = unfoldLines(template)
+	return overlay, template, len(keep)
+}
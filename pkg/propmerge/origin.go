@@ -0,0 +1,50 @@
+package propmerge
+
+// 合并结果中各行的来源
+const (
+	OriginTemplate = "template" // 新模板中原有、未被修改的行
+	OriginReplaced = "replaced" // 以旧文件中的值替换了模板中的行
+	OriginInserted = "inserted" // 从旧文件插入或追加的行，包括随之写入的注释
+)
+
+// LineOrigin 记录合并结果中一行的来源
+type LineOrigin struct {
+	Line   int    `json:"line"` // 合并结果中的行号(从1开始)
+	Origin string `json:"origin"`
+	Key    string `json:"key,omitempty"` // 来自旧文件的参数行的键名
+}
+
+// LineOrigins 对比合并前的新模板与合并结果，返回合并结果中每一行的来源。
+// 与模板相同的行视为来自模板；其余参数行按keys中的处理结果区分替换与插入，
+// 不在keys中的行(如注释)与模板中的行配对修改时视为替换，否则视为插入
+func LineOrigins(template, merged []string, keys []KeyResult) []LineOrigin {
+	actions := make(map[string]string, len(keys))
+	for _, k := range keys {
+		actions[k.Key] = k.Action
+	}
+
+	origins := make([]LineOrigin, 0, len(merged))
+	for _, row := range SideBySide(template, merged) {
+		if row.NewLine == 0 {
+			continue
+		}
+		o := LineOrigin{Line: row.NewLine, Origin: OriginTemplate}
+		if row.Kind != ' ' {
+			o.Origin = OriginInserted
+			if row.Kind == '~' {
+				o.Origin = OriginReplaced
+			}
+			if !isComment(row.NewText) && separator(row.NewText) != -1 {
+				key := LineKey(row.NewText)
+				switch actions[key] {
+				case ActionReplace:
+					o.Origin, o.Key = OriginReplaced, key
+				case ActionInsert, ActionAppend:
+					o.Origin, o.Key = OriginInserted, key
+				}
+			}
+		}
+		origins = append(origins, o)
+	}
+	return origins
+}
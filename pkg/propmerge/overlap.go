@@ -0,0 +1,25 @@
+package propmerge
+
+// Overlap 统计旧文件的键中有多少在新文件中仍然存在，用于发现传错的文件对(如把前端的配置合并到后端的配置上)。
+// 宽松绑定模式下按规范形式比较键，按重命名与移动规则改写后存在于新文件中的旧键同样计入；重复的键只计一次
+func (m *Merger) Overlap(oldKeys, newKeys []string) (shared, total int) {
+	present := make(map[string]bool, len(newKeys))
+	for _, key := range newKeys {
+		present[m.lookupKey(key)] = true
+	}
+	seen := make(map[string]bool, len(oldKeys))
+	for _, key := range oldKeys {
+		lookup := m.lookupKey(key)
+		if seen[lookup] {
+			continue
+		}
+		seen[lookup] = true
+		total++
+		if present[lookup] {
+			shared++
+		} else if target, ok := m.renameTarget(key); ok && present[m.lookupKey(target)] {
+			shared++
+		}
+	}
+	return shared, total
+}
@@ -0,0 +1,216 @@
+package propmerge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// FormatPlugin 定义在config-matcher.json的formats中注册的外部格式处理程序，用于合并工具不认识的配置格式。
+// 每次调用时工具启动command，向其标准输入写入一个PluginRequest，并从标准输出读取一个PluginResponse
+type FormatPlugin struct {
+	Name       string   `json:"name"`
+	Extensions []string `json:"extensions"` // 由该插件处理的扩展名，如[".ini"]
+	Command    []string `json:"command"`    // 程序及其参数
+}
+
+// Entry 是与格式无关的键值模型: 插件把配置文件解析为Entry列表，合并引擎据此计算需要的修改
+type Entry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Line  int    `json:"line,omitempty"` // 键所在行号(从1开始)，未知时为0
+}
+
+// Change 是合并引擎要求插件写入新文件的一处修改，Action为replace或insert
+type Change struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Action string `json:"action"`
+}
+
+// 插件请求的操作
+const (
+	PluginParse  = "parse"  // 解析content，返回其中全部键值
+	PluginRender = "render" // 将changes应用到content，返回修改后的内容
+)
+
+// PluginRequest 为发送给插件的请求
+type PluginRequest struct {
+	Op      string   `json:"op"`
+	Content string   `json:"content"`
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// PluginResponse 为插件返回的响应: parse返回entries；render返回content，
+// 同时返回修改后内容的entries时用于确定插入参数的行号。处理失败时返回error
+type PluginResponse struct {
+	Entries []Entry `json:"entries,omitempty"`
+	Content string  `json:"content,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Handles 判断插件是否处理该文件
+func (p FormatPlugin) Handles(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, e := range p.Extensions {
+		if strings.ToLower(e) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse 调用插件将配置内容解析为键值
+func (p FormatPlugin) Parse(lines []string) ([]Entry, error) {
+	return p.ParseContext(context.Background(), lines)
+}
+
+// ParseContext 与Parse相同，ctx取消或超时后终止插件进程
+func (p FormatPlugin) ParseContext(ctx context.Context, lines []string) ([]Entry, error) {
+	resp, err := p.call(ctx, PluginRequest{Op: PluginParse, Content: strings.Join(lines, "\n")})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Entries, nil
+}
+
+// Render 调用插件将修改应用到配置内容，返回修改后的各行及插件返回的键值(可能为空)
+func (p FormatPlugin) Render(lines []string, changes []Change) ([]string, []Entry, error) {
+	return p.RenderContext(context.Background(), lines, changes)
+}
+
+// RenderContext 与Render相同，ctx取消或超时后终止插件进程
+func (p FormatPlugin) RenderContext(ctx context.Context, lines []string, changes []Change) ([]string, []Entry, error) {
+	resp, err := p.call(ctx, PluginRequest{Op: PluginRender, Content: strings.Join(lines, "\n"), Changes: changes})
+	if err != nil {
+		return nil, nil, err
+	}
+	return strings.Split(strings.TrimSuffix(resp.Content, "\n"), "\n"), resp.Entries, nil
+}
+
+// call 启动插件程序完成一次请求，ctx取消或超时后终止插件进程
+func (p FormatPlugin) call(ctx context.Context, req PluginRequest) (PluginResponse, error) {
+	var resp PluginResponse
+	if len(p.Command) == 0 {
+		return resp, fmt.Errorf(tr("格式插件 %s 未定义command"), p.Name)
+	}
+	input, err := json.Marshal(req)
+	if err != nil {
+		return resp, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Command[0], p.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return resp, fmt.Errorf(tr("格式插件 %s 执行%s失败: %w: %s"), p.Name, req.Op, err, msg)
+		}
+		return resp, fmt.Errorf(tr("格式插件 %s 执行%s失败: %w"), p.Name, req.Op, err)
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return resp, fmt.Errorf(tr("格式插件 %s 的%s响应无效: %w"), p.Name, req.Op, err)
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf(tr("格式插件 %s 执行%s失败: %s"), p.Name, req.Op, resp.Error)
+	}
+	return resp, nil
+}
+
+// MergeEntries 在与格式无关的键值模型上计算合并: 旧键值中命中保留规则的，新键值中已存在时替换，
+// 否则插入；返回需要写入新文件的修改与每个保留参数的处理结果
+func (m *Merger) MergeEntries(oldEntries, newEntries []Entry) ([]Change, []KeyResult) {
+	index := make(map[string]Entry, len(newEntries))
+	for _, n := range newEntries {
+		if _, ok := index[n.Key]; !ok {
+			index[n.Key] = n
+		}
+	}
+
+	var kept []Entry
+	oldKeys := make(map[string]bool)
+	for _, o := range oldEntries {
+		if m.Matches(o.Key + "=" + o.Value) {
+			kept = append(kept, o)
+			oldKeys[o.Key] = true
+		}
+	}
+
+	var changes []Change
+	var results []KeyResult
+	for _, o := range kept {
+		m.keyDebugf(o.Key, o.Line, "", "找到匹配参数[行%d]: %s", o.Line, o.Key)
+		result := KeyResult{Key: o.Key, OldValue: o.Value}
+		if !m.renamePath(&result, oldKeys) {
+			results = append(results, result)
+			continue
+		}
+		m.transformResult(&result, false)
+
+		if n, ok := index[result.Key]; ok {
+			result.Action, result.Line, result.NewValue = ActionReplace, n.Line, n.Value
+			if !m.renameCollision(&result) || !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			m.keyDebugf(result.Key, result.Line, ActionReplace, "替换参数[行%d]: %s", result.Line, result.Key)
+		} else {
+			result.Action = ActionInsert
+			if !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			m.keyDebugf(result.Key, 0, ActionInsert, "插入参数: %s", result.Key)
+		}
+		changes = append(changes, Change{Key: result.Key, Value: result.OldValue, Action: result.Action})
+		m.trace(TraceEvent{Event: "action", Line: result.Line, Key: result.Key, Text: result.Key + "=" + result.OldValue, Result: result.Action})
+		results = append(results, result)
+	}
+	return changes, results
+}
+
+// MergePluginLines 通过格式插件解析旧文件与新文件，在键值模型上合并后由插件写回新文件的内容
+func (m *Merger) MergePluginLines(p FormatPlugin, oldLines, newLines []string) (Result, error) {
+	return m.MergePluginLinesContext(context.Background(), p, oldLines, newLines)
+}
+
+// MergePluginLinesContext 与MergePluginLines相同，ctx取消或超时后终止插件进程并返回包含ctx.Err()的错误
+func (m *Merger) MergePluginLinesContext(ctx context.Context, p FormatPlugin, oldLines, newLines []string) (Result, error) {
+	oldEntries, err := p.ParseContext(ctx, oldLines)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("解析旧文件失败: %w"), err)
+	}
+	newEntries, err := p.ParseContext(ctx, newLines)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("解析新文件失败: %w"), err)
+	}
+
+	changes, results := m.MergeEntries(oldEntries, newEntries)
+	merged := Result{Lines: newLines, Keys: results}
+	if err := m.checkCollisions(merged); err != nil || len(changes) == 0 {
+		return merged, err
+	}
+
+	lines, entries, err := p.RenderContext(ctx, newLines, changes)
+	if err != nil {
+		return Result{Keys: results}, err
+	}
+	// 插件返回了修改后内容的键值时，据此补全插入参数的行号
+	lineOf := make(map[string]int, len(entries))
+	for _, e := range entries {
+		if _, ok := lineOf[e.Key]; !ok {
+			lineOf[e.Key] = e.Line
+		}
+	}
+	for i, r := range results {
+		if r.Action == ActionInsert && lineOf[r.Key] > 0 {
+			results[i].Line = lineOf[r.Key]
+		}
+	}
+	return Result{Lines: lines, Keys: results}, nil
+}
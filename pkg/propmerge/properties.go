@@ -0,0 +1,459 @@
+package propmerge
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// LineSeparator 为新建文件或无法判断原文件换行符时使用的换行符
+	LineSeparator = "\n"
+	bufferSize    = 64 * 1024 // 64KB buffer
+)
+
+// LineKey 提取配置行中分隔符(第一个未转义的=、:或空白)前的键名，按Java properties的规则还原转义(如\uXXXX、\=、\ )
+func LineKey(line string) string {
+	if i := separator(line); i != -1 {
+		line = line[:i]
+	}
+	return unescape(trimEscaped(line))
+}
+
+// LineValue 提取配置行中分隔符后的值并还原转义，不含行尾注释；以反斜杠续行的逻辑行返回拼接后的值
+func LineValue(line string) string {
+	p, ok := splitProperty(line)
+	if !ok {
+		return ""
+	}
+	value := p.value
+	if strings.Contains(value, "\n") {
+		value = joinContinued(value)
+	}
+	return unescape(trimEscaped(value))
+}
+
+// propertyLine 是一个参数行拆分出的各部分，依次拼接即为原行
+type propertyLine struct {
+	key     string // 分隔符之前的内容，含缩进，未还原转义
+	sep     string // 分隔符: 键后的空白、可选的=或:及其后的空白
+	value   string // 值的原始写法，不含行尾注释
+	comment string // 值之后以空白分隔的#或!开始的行尾注释(含前导空白)，续行的值不识别行尾注释
+}
+
+// splitProperty 将参数行拆分为键、分隔符、值与行尾注释，没有分隔符(见separator)时返回false。
+// 分隔符之后紧接的#或!属于值(如color = #fff)，只有值之后空白分隔的#或!才开始行尾注释
+func splitProperty(line string) (propertyLine, bool) {
+	i := separator(line)
+	if i == -1 {
+		return propertyLine{}, false
+	}
+	v := valueStart(line, i)
+	p := propertyLine{key: line[:i], sep: line[i:v], value: line[v:]}
+	if !strings.Contains(p.value, "\n") {
+		p.value, p.comment = splitInlineComment(p.value)
+	}
+	return p, true
+}
+
+// String 返回拼接后的参数行
+func (p propertyLine) String() string {
+	return p.key + p.sep + p.value + p.comment
+}
+
+// joinContinued 将跨多行的值拼接为逻辑值: 去掉各行末尾的续行反斜杠与后续各行的前导空白
+func joinContinued(value string) string {
+	segments := strings.Split(value, "\n")
+	for i, s := range segments {
+		if i > 0 {
+			s = strings.TrimLeft(s, " \t\f")
+		}
+		if i < len(segments)-1 {
+			s = strings.TrimSuffix(s, `\`)
+		}
+		segments[i] = s
+	}
+	return strings.Join(segments, "")
+}
+
+// continuedLine 为合并过程中被并入上一逻辑行的续行所占的位置，写出前由unfoldLines删除
+const continuedLine = "\x00"
+
+// trailingBackslash 判断行是否以未转义的反斜杠结尾
+func trailingBackslash(line string) bool {
+	n := len(line) - len(strings.TrimRight(line, `\`))
+	return n%2 == 1
+}
+
+// continues 判断行的下一行是否为其值的延续: 非注释行以未转义的反斜杠结尾
+func continues(line string) bool {
+	return trailingBackslash(line) && !isComment(line)
+}
+
+// FoldLines 将以反斜杠续行的参数合并为一个逻辑行: 返回与lines等长的切片，逻辑行的各物理行以"\n"连接后
+// 放在其第一行的位置，被并入的后续行为空字符串，行号因此保持不变。注释行不续行
+func FoldLines(lines []string) []string {
+	return foldLines(lines, "")
+}
+
+// foldLines 与FoldLines相同，被并入的后续行替换为placeholder；没有续行时直接返回lines
+func foldLines(lines []string, placeholder string) []string {
+	var folded []string
+	for i := 0; i < len(lines); i++ {
+		if !continues(lines[i]) || i+1 == len(lines) {
+			continue
+		}
+		if folded == nil {
+			folded = append([]string(nil), lines...)
+		}
+		start := i
+		var b strings.Builder
+		b.WriteString(lines[i])
+		for trailingBackslash(lines[i]) && i+1 < len(lines) {
+			i++
+			b.WriteString("\n")
+			b.WriteString(lines[i])
+			folded[i] = placeholder
+		}
+		folded[start] = b.String()
+	}
+	if folded == nil {
+		return lines
+	}
+	return folded
+}
+
+// unfoldLines 将逻辑行拆回物理行并删除continuedLine占位，同时返回每个逻辑行在结果中的行号(从1开始)
+func unfoldLines(lines []string) ([]string, []int) {
+	out := make([]string, 0, len(lines))
+	starts := make([]int, len(lines))
+	for i, line := range lines {
+		starts[i] = len(out) + 1
+		switch {
+		case line == continuedLine:
+		case strings.Contains(line, "\n"):
+			out = append(out, strings.Split(line, "\n")...)
+		default:
+			out = append(out, line)
+		}
+	}
+	return out, starts
+}
+
+// isComment 判断是否为properties注释行
+func isComment(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "!")
+}
+
+// Property 表示配置文件中的一个键值对
+type Property struct {
+	Key   string
+	Value string
+	Line  int
+}
+
+// ParseProperties 解析配置行，忽略注释与空行，以反斜杠续行的参数按一个逻辑行解析(行号为其第一行)；
+// 返回按首次出现顺序排列的键，重复的键以最后一次出现为准
+func ParseProperties(lines []string) ([]string, map[string]Property) {
+	var keys []string
+	props := make(map[string]Property)
+	for i, line := range FoldLines(lines) {
+		p, ok := parseProperty(i, line)
+		if !ok {
+			continue
+		}
+		if _, ok := props[p.Key]; !ok {
+			keys = append(keys, p.Key)
+		}
+		props[p.Key] = p
+	}
+	return keys, props
+}
+
+// parseProperty 解析第i行(从0开始)，注释、空行与没有分隔符的行返回false
+func parseProperty(i int, line string) (Property, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || isComment(trimmed) || separator(trimmed) == -1 {
+		return Property{}, false
+	}
+	return Property{Key: LineKey(trimmed), Value: LineValue(trimmed), Line: i + 1}, true
+}
+
+// ScanProperties 逐行读取UTF-8的properties文件，按出现顺序对每个键值对调用fn，不将文件整个载入内存。
+// 解析规则与ParseProperties相同，重复的键每次出现都会调用fn
+func ScanProperties(filename string, fn func(p Property) error) error {
+	var pending strings.Builder // 尚未结束的续行
+	start := -1
+	err := ScanFile(filename, func(i int, line string) error {
+		switch {
+		case start >= 0:
+			pending.WriteString("\n" + line)
+			if trailingBackslash(line) {
+				return nil
+			}
+			line, i, start = pending.String(), start, -1
+			pending.Reset()
+		case continues(line):
+			start = i
+			pending.WriteString(line)
+			return nil
+		}
+		if p, ok := parseProperty(i, line); ok {
+			return fn(p)
+		}
+		return nil
+	})
+	if err == nil && start >= 0 {
+		// 文件以续行结尾
+		if p, ok := parseProperty(start, pending.String()); ok {
+			err = fn(p)
+		}
+	}
+	return err
+}
+
+// KeyChange 描述某个键在两个文件之间的变化
+type KeyChange struct {
+	Op     string // +: 新增, -: 删除, ~: 修改
+	Key    string
+	Before string
+	After  string
+}
+
+// DiffProperties 计算两组配置之间的键级差异
+func DiffProperties(before, after []string) []KeyChange {
+	beforeKeys, beforeProps := ParseProperties(before)
+	afterKeys, afterProps := ParseProperties(after)
+
+	var changes []KeyChange
+	for _, key := range beforeKeys {
+		b := beforeProps[key]
+		a, ok := afterProps[key]
+		if !ok {
+			changes = append(changes, KeyChange{Op: "-", Key: key, Before: b.Value})
+		} else if a.Value != b.Value {
+			changes = append(changes, KeyChange{Op: "~", Key: key, Before: b.Value, After: a.Value})
+		}
+	}
+	for _, key := range afterKeys {
+		if _, ok := beforeProps[key]; !ok {
+			changes = append(changes, KeyChange{Op: "+", Key: key, After: afterProps[key].Value})
+		}
+	}
+	return changes
+}
+
+// ReadLines 按行读取全部内容，GBK或带BOM的内容先解码为UTF-8
+func ReadLines(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf(tr("读取文件失败: %w"), err)
+	}
+	if data, _, err = Decode(data); err != nil {
+		return nil, fmt.Errorf(tr("解码文件失败: %w"), err)
+	}
+
+	var lines []string
+	err = forEachLine(bytes.NewReader(data), func(_ int, line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf(tr("读取文件失败: %w"), err)
+	}
+	return lines, nil
+}
+
+// ReadFile 按行读取文件
+func ReadFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf(tr("打开文件失败: %w"), err)
+	}
+	defer file.Close()
+	return ReadLines(file)
+}
+
+// DetectLineSeparator 返回文件使用的换行符: 第一个换行为CRLF时返回"\r\n"，
+// 文件不存在或没有换行时返回LineSeparator
+func DetectLineSeparator(filename string) string {
+	file, err := os.Open(filename)
+	if err != nil {
+		return LineSeparator
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, bufferSize)
+	line, err := reader.ReadSlice('\n')
+	for err == bufio.ErrBufferFull {
+		line, err = reader.ReadSlice('\n')
+	}
+	if err == nil && len(line) > 1 && line[len(line)-2] == '\r' {
+		return "\r\n"
+	}
+	return LineSeparator
+}
+
+// WriteLines 将各行以LineSeparator结尾写入w
+func WriteLines(w io.Writer, lines []string) error {
+	return writeLines(w, lines, LineSeparator)
+}
+
+// writeLines 将各行以sep结尾写入w
+func writeLines(w io.Writer, lines []string, sep string) error {
+	writer := bufio.NewWriterSize(w, bufferSize)
+	for _, line := range lines {
+		if _, err := writer.WriteString(line + sep); err != nil {
+			return fmt.Errorf(tr("写入文件失败: %w"), err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf(tr("刷新缓冲区失败: %w"), err)
+	}
+	return nil
+}
+
+// WriteFile 将各行原子地写入文件；覆盖已有文件时沿用其原有的换行符(LF或CRLF)、编码、权限、属主与属组
+func WriteFile(filename string, lines []string) error {
+	return WriteFileEncoding(filename, lines, "")
+}
+
+// WriteFileEncoding 与WriteFile相同，但以指定编码写入；encoding为空时沿用原文件的编码，新文件使用UTF-8
+func WriteFileEncoding(filename string, lines []string, encoding string) error {
+	sep := DetectLineSeparator(filename)
+	if encoding == "" {
+		encoding = DetectFileEncoding(filename)
+	}
+	return AtomicWrite(filename, func(w io.Writer) error {
+		return writeEncoded(w, lines, sep, encoding)
+	})
+}
+
+// sortedLineNums 返回按升序排列的保留参数行号
+func sortedLineNums(keep map[int]string) []int {
+	nums := make([]int, 0, len(keep))
+	for n := range keep {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// insertLine 在index处插入一行，index越界时插入到开头或末尾
+func insertLine(lines []string, index int, line string) []string {
+	if index < 0 {
+		index = 0
+	} else if index > len(lines) {
+		index = len(lines)
+	}
+
+	// 更安全的插入方式，避免潜在的切片问题
+	result := make([]string, 0, len(lines)+1)
+	result = append(result, lines[:index]...)
+	result = append(result, line)
+	result = append(result, lines[index:]...)
+	return result
+}
+
+// findKey 查找键在各行中第一次出现的位置，未找到时返回-1
+func (m *Merger) findKey(lines []string, key string) int {
+	if len(lines) == 0 {
+		return -1
+	}
+
+	if m.opts.SpringRelaxed {
+		canonical := SpringCanonical(key)
+		for i, line := range lines {
+			if isComment(line) || separator(line) == -1 {
+				continue
+			}
+			if SpringCanonical(LineKey(line)) == canonical {
+				m.keyDebugf(key, i+1, "", "在行%d找到键(宽松绑定): %s", i+1, key)
+				return i
+			}
+		}
+		m.keyDebugf(key, 0, "", "未找到键(宽松绑定): %s", key)
+		return -1
+	}
+
+	// 按还原转义后的键比较，a\u0062c=与abc=视为同一个键
+	for i, line := range lines {
+		if isComment(line) || separator(line) == -1 {
+			continue
+		}
+		if LineKey(line) == key {
+			m.keyDebugf(key, i+1, "", "在行%d找到键: %s", i+1, key)
+			return i
+		}
+	}
+
+	m.keyDebugf(key, 0, "", "未找到键: %s", key)
+	return -1
+}
+
+// inlineCommentPattern 匹配值后以空白分隔的行尾注释
+var inlineCommentPattern = regexp.MustCompile(`\s+[#!]`)
+
+// splitInlineComment 将等号后的内容拆分为值与行尾注释(含前导空白)，以反斜杠转义的空白不分隔注释
+func splitInlineComment(s string) (value, comment string) {
+	for _, loc := range inlineCommentPattern.FindAllStringIndex(s, -1) {
+		if !escapedAt(s, loc[0]) {
+			return s[:loc[0]], s[loc[0]:]
+		}
+	}
+	return s, ""
+}
+
+// transplantValue 将旧行的值写入新行: 保留新行的键、等号两侧的空白与行尾注释，只替换值本身
+func transplantValue(newLine, oldLine string) string {
+	n, ok := splitProperty(newLine)
+	o, oldOK := splitProperty(oldLine)
+	if !ok || !oldOK {
+		return oldLine
+	}
+	n.value = trimEscaped(o.value)
+	switch {
+	case n.value == "":
+		// 空值之后的注释会被读作值
+		n.comment = ""
+	case strings.HasPrefix(n.sep, " ") && !strings.HasSuffix(n.sep, " "):
+		// 新文件中的值为空(如key =)时等号后没有空白，按等号前的写法补上
+		n.sep += " "
+	}
+	return n.String()
+}
+
+// withTemplateComment 整行使用旧行时保留新文件该行的行尾注释: 旧行自身没有行尾注释时接在旧值之后
+func withTemplateComment(line, newLine string) string {
+	n, ok := splitProperty(newLine)
+	if !ok || n.comment == "" {
+		return line
+	}
+	o, ok := splitProperty(line)
+	if !ok || o.comment != "" || strings.Contains(o.value, "\n") {
+		return line
+	}
+	if o.value = trimEscaped(o.value); o.value == "" {
+		return line
+	}
+	o.comment = n.comment
+	return o.String()
+}
+
+// replacementLine 返回替换新文件中已有参数时写入的行
+func (m *Merger) replacementLine(newLine, oldLine string) string {
+	switch {
+	case m.opts.ValueOnly:
+		return transplantValue(newLine, oldLine)
+	case m.opts.SpringRelaxed:
+		return withTemplateComment(relaxedReplacement(newLine, oldLine), newLine)
+	}
+	return withTemplateComment(oldLine, newLine)
+}
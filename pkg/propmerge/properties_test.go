@@ -0,0 +1,72 @@
+package propmerge
+
+import "testing"
+
+func TestSplitPropertySeparators(t *testing.T) {
+	tests := []struct {
+		line              string
+		key, value        string
+		rawKey, sep, tail string
+	}{
+		{"a=b", "a", "b", "a", "=", "b"},
+		{"a = b", "a", "b", "a", " = ", "b"},
+		{"a:b", "a", "b", "a", ":", "b"},
+		{"ftp.host : 1.2.3.4", "ftp.host", "1.2.3.4", "ftp.host", " : ", "1.2.3.4"},
+		{"a b", "a", "b", "a", " ", "b"},
+		{"a\tb", "a", "b", "a", "\t", "b"},
+		{"a\f:\fb", "a", "b", "a", "\f:\f", "b"},
+		{"  indented=x", "indented", "x", "  indented", "=", "x"},
+		{"a  b = c", "a", "b = c", "a", "  ", "b = c"},
+		{"a==b", "a", "=b", "a", "=", "=b"},
+		{"a:=b", "a", "=b", "a", ":", "=b"},
+		{`a\ b\:c\=d = e`, "a b:c=d", "e", `a\ b\:c\=d`, " = ", "e"},
+		{"url=http://x:8080/y", "url", "http://x:8080/y", "url", "=", "http://x:8080/y"},
+		{"empty =", "empty", "", "empty", " =", ""},
+		{"empty ", "empty", "", "empty", " ", ""},
+	}
+	for _, tt := range tests {
+		p, ok := splitProperty(tt.line)
+		if !ok {
+			t.Errorf("splitProperty(%q) found no separator", tt.line)
+			continue
+		}
+		if p.key != tt.rawKey || p.sep != tt.sep || p.value != tt.tail {
+			t.Errorf("splitProperty(%q) = %q, %q, %q, want %q, %q, %q", tt.line, p.key, p.sep, p.value, tt.rawKey, tt.sep, tt.tail)
+		}
+		if p.String() != tt.line {
+			t.Errorf("splitProperty(%q).String() = %q", tt.line, p.String())
+		}
+		if key := LineKey(tt.line); key != tt.key {
+			t.Errorf("LineKey(%q) = %q, want %q", tt.line, key, tt.key)
+		}
+		if value := LineValue(tt.line); value != tt.value {
+			t.Errorf("LineValue(%q) = %q, want %q", tt.line, value, tt.value)
+		}
+	}
+
+	for _, line := range []string{"", "   ", "keyonly", `key\ only`} {
+		if i := separator(line); i != -1 {
+			t.Errorf("separator(%q) = %d, want -1", line, i)
+		}
+	}
+}
+
+func TestMergeLinesColonSeparator(t *testing.T) {
+	m, err := New(Options{Pattern: `^ftp\.host`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := m.MergeLines([]string{"ftp.host=10.0.0.1"}, []string{"ftp.host : 1.2.3.4", "ftp.port 21"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ftp.host=10.0.0.1", "ftp.port 21"}
+	if len(result.Lines) != len(want) {
+		t.Fatalf("MergeLines = %q, want %q", result.Lines, want)
+	}
+	for i := range want {
+		if result.Lines[i] != want[i] {
+			t.Fatalf("MergeLines = %q, want %q", result.Lines, want)
+		}
+	}
+}
@@ -0,0 +1,544 @@
+// Package propmerge 实现配置文件更新工具的核心逻辑: 按保留规则从旧配置中提取参数，
+// 合并到新模板中并输出结果，供命令行工具和其他部署工具直接嵌入使用。
+package propmerge
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"regexp"
+	"time"
+)
+
+// 单个保留参数的处理动作
+const (
+	ActionReplace = "replace" // 替换新文件中已有的键
+	ActionInsert  = "insert"  // 插入到新文件中(按旧文件行号或同前缀参数的位置)
+	ActionAppend  = "append"  // 追加到文件末尾
+	ActionSkip    = "skip"    // 因冲突等原因未写入
+	ActionComment = "comment" // 新文件中已不存在，按ObsoletePolicy以注释形式写入
+	ActionDrop    = "drop"    // 新文件中已不存在，按ObsoletePolicy不予写入
+)
+
+// 新文件中不存在的保留参数的插入策略
+const (
+	InsertLine   = "line"   // 按旧文件中的行号插入，超出新文件行数时追加到末尾
+	InsertAnchor = "anchor" // 插入到与键共享最长点分前缀的最后一个参数之后，没有同前缀的参数时追加到末尾
+	InsertAppend = "append" // 一律追加到文件末尾
+)
+
+// 新文件中已不存在的保留参数(可能已被上游有意删除)的处理策略
+const (
+	ObsoleteInsert  = "insert"  // 按InsertStrategy插入
+	ObsoleteAppend  = "append"  // 一律追加到文件末尾
+	ObsoleteComment = "comment" // 按InsertStrategy的位置插入注释掉的旧行
+	ObsoleteDrop    = "drop"    // 不写入，只在处理结果中列出
+	ObsoleteAsk     = "ask"     // 逐个调用Options.AskObsolete决定
+)
+
+// ObsoleteMarker 为ObsoleteComment写入的注释行的前缀，其后为旧文件中的原行
+const ObsoleteMarker = "# obsolete (not in new template): "
+
+// 重命名冲突处理策略
+const (
+	CollisionOldWins = "old-wins"
+	CollisionNewWins = "new-wins"
+	CollisionFail    = "fail"
+)
+
+// ErrCollision 在冲突策略为fail且检测到重命名冲突时返回
+var ErrCollision error = message("重命名冲突")
+
+// ErrConflict 在冲突策略为fail且检测到三方合并冲突或(DetectConflicts时)旧值与新值的冲突时返回
+var ErrConflict error = message("合并冲突")
+
+// Logger 是处理过程中的日志输出接口，*log.Logger 即满足该接口
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Provenance 定义写入保留参数上方的来源注释
+type Provenance struct {
+	Format string // 注释格式，支持{file}、{line}、{run}占位符
+	Source string // 旧文件名称
+	Run    string // 本次运行标识
+}
+
+// TraceEvent 是一条处理决策记录
+type TraceEvent struct {
+	Time   string `json:"time"`
+	Event  string `json:"event"` // scan: 扫描行, lookup: 查找键, action: 执行动作
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Text   string `json:"text,omitempty"`
+	Result string `json:"result"`
+}
+
+// Options 控制合并行为
+type Options struct {
+	// Pattern 为保留规则正则，旧文件中命中的行会被保留；Pattern与Rules均为空时使用DefaultPattern
+	Pattern string
+	// Rules 为结构化保留规则，键命中任一规则(或命中Pattern)的行会被保留
+	Rules []Rule
+	// ExcludeKeys 为排除规则，写法与Rules相同。命中保留规则(含加密值与AutoPreserve)但同时命中任一排除规则的键不予保留，
+	// 取新文件中的值
+	ExcludeKeys []Rule
+	// XMLPaths 为XML文件的保留规则，每项为一个XPath表达式，选中的属性值与元素文本会被保留。
+	// 支持的子集: /与//连接的元素步骤、[@属性='值']、[@属性]与[序号]谓词，末尾为/@属性或/text()
+	XMLPaths []string
+	// Renames 为旧键名到新键名的映射
+	Renames map[string]string
+	// Moves 为旧路径到新路径的映射，按路径前缀匹配，路径下的所有键随之移动，
+	// 如spring.redis -> spring.data.redis会把spring.redis.host移到spring.data.redis.host
+	Moves map[string]string
+	// Transforms 为值转换规则，按定义顺序改写保留参数写入新文件的值(键名为重命名后的键)
+	Transforms []Transform
+	// CollisionPolicy 为重命名冲突处理策略，默认CollisionOldWins
+	CollisionPolicy string
+	// Base 为上一版本原始模板中的键值(三方合并的共同基线)，为nil时不做三方比较
+	Base map[string]string
+	// ConflictPolicy 为三方合并中旧值与新值都相对基线发生修改时的处理策略，取值同CollisionPolicy，默认CollisionOldWins
+	ConflictPolicy string
+	// DetectConflicts 为true时未设置Base也检测冲突: 保留参数在新文件中存在且值与要写入的旧值不同即为冲突，
+	// 同样按ConflictPolicy处理并记录在Conflict中
+	DetectConflicts bool
+	// DuplicatePolicy 为旧文件或新文件中存在重复键时的处理策略(DuplicateFirstWins/DuplicateLastWins/DuplicateError)，
+	// 为空时只在Result.Duplicates中报告，不做处理
+	DuplicatePolicy string
+	// OutputEncoding 为MergeFile写入新文件时使用的编码(EncodingUTF8/EncodingUTF8BOM/EncodingGBK)，为空时沿用新文件原有的编码
+	OutputEncoding string
+	// Unicode 为properties合并结果中非ASCII字符的写法(UnicodeKeep/UnicodeASCII/UnicodeUTF8)，默认UnicodeKeep
+	Unicode string
+	// InsertStrategy 为新文件中不存在的保留参数的插入策略(InsertLine/InsertAnchor/InsertAppend)，默认InsertLine。
+	// 只作用于properties文件，YAML/TOML/JSON中的参数总是插入到其父节点下
+	InsertStrategy string
+	// ObsoletePolicy 为新文件中已不存在的保留参数的处理策略(ObsoleteInsert/ObsoleteAppend/ObsoleteComment/
+	// ObsoleteDrop/ObsoleteAsk)，默认ObsoleteInsert。只作用于properties文件
+	ObsoletePolicy string
+	// AskObsolete 在ObsoletePolicy为ObsoleteAsk时为每个新文件中已不存在的参数调用，返回ObsoleteAsk以外的一种策略
+	AskObsolete func(KeyResult) string
+	// ValueOnly 替换已有参数时只把旧值写到新文件的对应行中，保留新文件的键名写法、空白与行尾注释
+	ValueOnly bool
+	// SpringRelaxed 按Spring Boot宽松绑定规则匹配键
+	SpringRelaxed bool
+	// Secrets 为从密钥管理系统(如HashiCorp Vault)取得的键值，这些键写入该值而不是旧文件中的值，也不经过值转换；
+	// 旧文件中没有的键同样写入，新文件中存在时替换，否则按插入策略写入(位置相当于旧文件末尾之后)。只作用于properties文件
+	Secrets map[string]string
+	// TemplateData 非nil时将合并结果中含有{{的值(保留的旧值与新模板中的默认值)作为Go模板渲染，TemplateData为模板的数据(.)；
+	// 引用了不存在的变量时合并失败并返回ErrTemplate，不写入任何修改。只作用于properties文件
+	TemplateData map[string]interface{}
+	// Defaults 为框架默认值，旧值等于默认值的参数不予保留
+	Defaults map[string]string
+	// AutoPreserve 忽略Pattern，自动保留两文件中都存在且值不同的键
+	AutoPreserve bool
+	// AutoPreserveOldOnly 与AutoPreserve同时使用，额外保留仅存在于旧文件中的键
+	AutoPreserveOldOnly bool
+	// PreserveEncrypted 将值为ENC(...)的参数视为不透明的加密值，无论是否命中保留规则都予以保留
+	PreserveEncrypted bool
+	// Jasypt 非空时转换规则作用于ENC(...)值解密后的明文，改写后重新加密；无法解密的加密值输出警告并原样保留
+	Jasypt *Jasypt
+	// PreserveComments 插入或追加保留参数时，连同其在旧文件中紧邻上方的注释块一起写入
+	PreserveComments bool
+	// Provenance 非空时在每个保留参数上方写入来源注释
+	Provenance *Provenance
+	// SourceName 为旧文件名称，仅用于跟踪记录
+	SourceName string
+	// Logger 接收处理日志，为空时输出到标准错误
+	Logger Logger
+	// Verbose 输出详细处理日志
+	Verbose bool
+	// Log 非空时代替Logger与Verbose输出分级的结构化日志，处理单个参数的日志附带key、line、action字段
+	Log *slog.Logger
+	// Trace 非空时接收每一个处理决策
+	Trace func(TraceEvent)
+	// Confirm 非空时在替换或插入每个保留参数前调用，返回false则跳过该参数
+	Confirm func(KeyResult) bool
+	// Mask 非空时隐藏日志、冲突说明与跟踪记录中敏感参数的值
+	Mask *Masker
+}
+
+// KeyResult 记录单个保留参数的处理结果
+type KeyResult struct {
+	Key             string `json:"key"`
+	Action          string `json:"action"`
+	Line            int    `json:"line"`
+	OldValue        string `json:"oldValue"`                  // 旧文件中的值
+	NewValue        string `json:"newValue"`                  // 新文件中原有的值，插入或追加时为空
+	RenamedFrom     string `json:"renamedFrom,omitempty"`     // 经重命名写入时的旧键名
+	TransformedFrom string `json:"transformedFrom,omitempty"` // 经转换规则改写或按mergeList合并列表时改写前的旧值，此时OldValue为实际写入的值
+	Collision       string `json:"collision,omitempty"`       // 重命名冲突说明
+	Declined        bool   `json:"declined,omitempty"`        // 是否因未通过确认而跳过
+	Conflict        string `json:"conflict,omitempty"`        // 三方合并冲突说明
+	Secret          bool   `json:"secret,omitempty"`          // 写入的值是否来自Options.Secrets
+	Prompt          bool   `json:"prompt,omitempty"`          // 命中policy为prompt的规则，写入前须经Confirm逐项确认
+}
+
+// Changed 判断合并后该键的实际值是否发生变化
+func (r KeyResult) Changed() bool {
+	if !r.Written() {
+		return false
+	}
+	return r.Action != ActionReplace || r.OldValue != r.NewValue
+}
+
+// Written 判断旧值是否作为有效的参数写入了合并结果(替换、插入或追加)
+func (r KeyResult) Written() bool {
+	return r.Action == ActionReplace || r.Action == ActionInsert || r.Action == ActionAppend
+}
+
+// Result 是一次合并的结果
+type Result struct {
+	// Lines 为合并后的内容；MergeFile走流式快速路径时为空
+	Lines []string
+	// Keys 为每个保留参数的处理结果
+	Keys []KeyResult
+	// SkippedDefaults 为因旧值等于默认值而未保留的键
+	SkippedDefaults []string
+	// Duplicates 为旧文件与新文件中出现多次的键
+	Duplicates []Duplicate
+}
+
+// Collisions 返回所有发生重命名冲突的结果
+func (r Result) Collisions() []KeyResult {
+	var found []KeyResult
+	for _, k := range r.Keys {
+		if k.Collision != "" {
+			found = append(found, k)
+		}
+	}
+	return found
+}
+
+// Changed 判断合并是否修改了新文件中的任何值: 有保留参数的值发生变化、被插入或追加，
+// 或新文件中的重复行按策略被删除
+func (r Result) Changed() bool {
+	for _, k := range r.Keys {
+		if k.Changed() {
+			return true
+		}
+	}
+	for _, d := range r.Duplicates {
+		if d.Source == "new" && d.Kept != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Diverged 返回已写入旧值、且旧值与新文件中原有取值不同的结果，
+// 即新模板中被旧值覆盖的取值，便于发现上游修改过的默认值；值来自Secrets的参数不计在内
+func (r Result) Diverged() []KeyResult {
+	var found []KeyResult
+	for _, k := range r.Keys {
+		if k.Action == ActionReplace && k.OldValue != k.NewValue && !k.Secret {
+			found = append(found, k)
+		}
+	}
+	return found
+}
+
+// Conflicts 返回所有三方合并冲突的结果
+func (r Result) Conflicts() []KeyResult {
+	var found []KeyResult
+	for _, k := range r.Keys {
+		if k.Conflict != "" {
+			found = append(found, k)
+		}
+	}
+	return found
+}
+
+// WriteTo 将合并后的内容写入w
+func (r Result) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := WriteLines(cw, r.Lines)
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Merger 持有编译后的规则，可对多组文件重复执行合并
+type Merger struct {
+	opts         Options
+	re           *regexp.Regexp
+	rules        []compiledRule
+	excludes     []compiledRule
+	transforms   []compiledTransform
+	xpaths       []*xpathExpr
+	provenanceRe *regexp.Regexp
+}
+
+// New 校验选项并编译保留规则
+func New(opts Options) (*Merger, error) {
+	if opts.Pattern == "" && len(opts.Rules) == 0 {
+		opts.Pattern = DefaultPattern
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	switch opts.CollisionPolicy {
+	case "":
+		opts.CollisionPolicy = CollisionOldWins
+	case CollisionOldWins, CollisionNewWins, CollisionFail:
+	default:
+		return nil, fmt.Errorf(tr("无效的冲突处理策略: %s"), opts.CollisionPolicy)
+	}
+	switch opts.ConflictPolicy {
+	case "":
+		opts.ConflictPolicy = CollisionOldWins
+	case CollisionOldWins, CollisionNewWins, CollisionFail:
+	default:
+		return nil, fmt.Errorf(tr("无效的三方合并冲突策略: %s"), opts.ConflictPolicy)
+	}
+	switch opts.DuplicatePolicy {
+	case "", DuplicateFirstWins, DuplicateLastWins, DuplicateError:
+	default:
+		return nil, fmt.Errorf(tr("无效的重复键处理策略: %s"), opts.DuplicatePolicy)
+	}
+	switch opts.InsertStrategy {
+	case "":
+		opts.InsertStrategy = InsertLine
+	case InsertLine, InsertAnchor, InsertAppend:
+	default:
+		return nil, fmt.Errorf(tr("无效的插入策略: %s"), opts.InsertStrategy)
+	}
+	switch opts.ObsoletePolicy {
+	case "":
+		opts.ObsoletePolicy = ObsoleteInsert
+	case ObsoleteInsert, ObsoleteAppend, ObsoleteComment, ObsoleteDrop:
+	case ObsoleteAsk:
+		if opts.AskObsolete == nil {
+			return nil, fmt.Errorf(tr("处理策略为%s时必须提供AskObsolete"), ObsoleteAsk)
+		}
+	default:
+		return nil, fmt.Errorf(tr("无效的已删除参数处理策略: %s"), opts.ObsoletePolicy)
+	}
+	switch opts.Unicode {
+	case "":
+		opts.Unicode = UnicodeKeep
+	case UnicodeKeep, UnicodeASCII, UnicodeUTF8:
+	default:
+		return nil, fmt.Errorf(tr("无效的Unicode写法: %s"), opts.Unicode)
+	}
+	if opts.OutputEncoding != "" && !ValidEncoding(opts.OutputEncoding) {
+		return nil, fmt.Errorf(tr("不支持的输出编码: %s"), opts.OutputEncoding)
+	}
+
+	m := &Merger{opts: opts}
+	if opts.Pattern != "" {
+		re, err := m.compilePattern(opts.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf(tr("编译正则表达式失败: %w"), globHint(opts.Pattern, err))
+		}
+		m.re = re
+	}
+	rules, err := m.compileRules(opts.Rules)
+	if err != nil {
+		return nil, fmt.Errorf(tr("编译保留规则失败: %w"), err)
+	}
+	m.rules = rules
+	if m.excludes, err = m.compileRules(opts.ExcludeKeys); err != nil {
+		return nil, fmt.Errorf(tr("编译excludeKeys失败: %w"), err)
+	}
+	if m.transforms, err = m.compileTransforms(opts.Transforms); err != nil {
+		return nil, fmt.Errorf(tr("编译值转换规则失败: %w"), err)
+	}
+	for _, text := range opts.XMLPaths {
+		x, err := compileXPath(text)
+		if err != nil {
+			return nil, fmt.Errorf(tr("编译xmlPaths失败: %w"), err)
+		}
+		m.xpaths = append(m.xpaths, x)
+	}
+
+	if opts.Provenance != nil {
+		if opts.Provenance.Run == "" {
+			opts.Provenance.Run = time.Now().UTC().Format("20060102T150405Z")
+		}
+		m.provenanceRe = provenancePattern(opts.Provenance.Format)
+	}
+	return m, nil
+}
+
+// WithSource 返回共享已编译规则、只替换旧文件名称与日志的合并器，依次合并多对文件时避免重复编译规则；
+// log为空时沿用原来的日志
+func (m *Merger) WithSource(source string, log *slog.Logger) *Merger {
+	c := *m
+	c.opts.SourceName = source
+	if log != nil {
+		c.opts.Log = log
+	}
+	if m.opts.Provenance != nil {
+		p := *m.opts.Provenance
+		p.Source = source
+		c.opts.Provenance = &p
+	}
+	return &c
+}
+
+// Merge 使用给定选项将旧配置中的保留参数合并到新配置中
+func Merge(old io.Reader, new io.Reader, opts Options) (Result, error) {
+	m, err := New(opts)
+	if err != nil {
+		return Result{}, err
+	}
+	return m.Merge(old, new)
+}
+
+// Merge 将旧配置中的保留参数合并到新配置中，结果仅保存在内存里。
+// 冲突策略为fail且存在重命名冲突时，返回的Result中仍包含各参数的处理结果
+func (m *Merger) Merge(old io.Reader, new io.Reader) (Result, error) {
+	oldLines, err := ReadLines(old)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+	newLines, err := ReadLines(new)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取新文件失败: %w"), err)
+	}
+	return m.MergeLines(oldLines, newLines)
+}
+
+// MergeLines 与Merge相同，但直接处理已读取的行
+func (m *Merger) MergeLines(oldLines, newLines []string) (Result, error) {
+	var keep map[int]string
+	var skipped []string
+	if m.opts.AutoPreserve {
+		keep = m.AutoKeep(oldLines, newLines)
+	} else {
+		keep, skipped = m.Extract(oldLines)
+	}
+	return m.mergeKept(oldLines, newLines, keep, skipped)
+}
+
+// mergeKept 将已提取的保留参数写入新文件内容
+func (m *Merger) mergeKept(oldLines, newLines []string, keep map[int]string, skipped []string) (Result, error) {
+	result := Result{SkippedDefaults: skipped}
+	// 以反斜杠续行的参数按逻辑行处理，写出前再拆回物理行
+	oldLines, newLines = foldLines(oldLines, continuedLine), foldLines(newLines, continuedLine)
+
+	// 旧文件中的重复键只保留策略选中的一行，新文件中的重复行在合并前删除，保证合并结果中每个键只出现一次
+	oldDups := m.FindDuplicates("old", oldLines)
+	for n := range discarded(oldDups) {
+		delete(keep, n)
+	}
+	keep = m.withSecrets(keep, len(oldLines))
+	newDups := m.FindDuplicates("new", newLines)
+	result.Duplicates = append(oldDups, newDups...)
+
+	m.debugf("开始更新文件(共%d行)", len(newLines))
+	lines := dropDuplicateLines(append([]string(nil), newLines...), newDups)
+	result.Lines, result.Keys = m.apply(lines, keep, m.commentsAbove(oldLines, keep))
+	if err := m.render(result.Lines); err != nil {
+		return result, err
+	}
+	m.normalize(result.Lines)
+	unfoldResult(&result)
+	if err := m.checkCollisions(result); err != nil {
+		return result, err
+	}
+	m.debugf("文件更新完成，共处理%d个参数", len(keep))
+	return result, nil
+}
+
+// unfoldResult 将合并结果中的逻辑行拆回物理行，并把各参数的行号改为其在拆分后结果中的行号
+func unfoldResult(result *Result) {
+	lines, starts := unfoldLines(result.Lines)
+	for i, k := range result.Keys {
+		if k.Line > 0 && k.Line <= len(starts) {
+			result.Keys[i].Line = starts[k.Line-1]
+		}
+	}
+	result.Lines = lines
+}
+
+// checkCollisions 在冲突策略为fail时检查是否存在重命名冲突或三方合并冲突，
+// 在重复键策略为error时检查是否存在重复的键
+func (m *Merger) checkCollisions(result Result) error {
+	if m.opts.DuplicatePolicy == DuplicateError && len(result.Duplicates) > 0 {
+		return fmt.Errorf(tr("%w: 检测到%d个重复的键，未写入任何修改"), ErrDuplicate, len(result.Duplicates))
+	}
+	if m.opts.CollisionPolicy == CollisionFail {
+		if found := result.Collisions(); len(found) > 0 {
+			return fmt.Errorf(tr("%w: 检测到%d处重命名冲突，未写入任何修改"), ErrCollision, len(found))
+		}
+	}
+	if m.opts.ConflictPolicy == CollisionFail {
+		if found := result.Conflicts(); len(found) > 0 {
+			return fmt.Errorf(tr("%w: 检测到%d处冲突，未写入任何修改"), ErrConflict, len(found))
+		}
+	}
+	return nil
+}
+
+// Options 返回合并器使用的选项(已填充默认值)
+func (m *Merger) Options() Options {
+	return m.opts
+}
+
+// logf 按级别输出日志。设置了Log时交由其按级别过滤并附带attrs字段，
+// 否则调试日志仅在详细模式下输出，警告日志加上"警告: "前缀
+func (m *Merger) logf(level slog.Level, attrs []slog.Attr, format string, v ...interface{}) {
+	if m.opts.Log != nil {
+		m.opts.Log.LogAttrs(context.Background(), level, fmt.Sprintf(tr(format), v...), attrs...)
+		return
+	}
+	switch {
+	case level >= slog.LevelWarn:
+		m.opts.Logger.Printf(tr("警告: ")+tr(format), v...)
+	case level >= slog.LevelInfo || m.opts.Verbose:
+		m.opts.Logger.Printf(tr(format), v...)
+	}
+}
+
+// debugf 输出调试日志
+func (m *Merger) debugf(format string, v ...interface{}) {
+	m.logf(slog.LevelDebug, nil, format, v...)
+}
+
+// warnf 输出警告日志
+func (m *Merger) warnf(format string, v ...interface{}) {
+	m.logf(slog.LevelWarn, nil, format, v...)
+}
+
+// keyDebugf 输出处理单个参数的调试日志，line为0、action为空时不附带对应字段
+func (m *Merger) keyDebugf(key string, line int, action, format string, v ...interface{}) {
+	m.logf(slog.LevelDebug, keyAttrs(key, line, action), format, v...)
+}
+
+// keyWarnf 输出处理单个参数的警告日志
+func (m *Merger) keyWarnf(key string, line int, action, format string, v ...interface{}) {
+	m.logf(slog.LevelWarn, keyAttrs(key, line, action), format, v...)
+}
+
+func keyAttrs(key string, line int, action string) []slog.Attr {
+	attrs := []slog.Attr{slog.String("key", key)}
+	if line > 0 {
+		attrs = append(attrs, slog.Int("line", line))
+	}
+	if action != "" {
+		attrs = append(attrs, slog.String("action", action))
+	}
+	return attrs
+}
+
+// trace 记录一条处理决策
+func (m *Merger) trace(ev TraceEvent) {
+	if m.opts.Trace != nil {
+		ev.Text = m.opts.Mask.Line(ev.Text)
+		m.opts.Trace(ev)
+	}
+}
@@ -0,0 +1,212 @@
+package propmerge
+
+import (
+	"regexp"
+	"strings"
+)
+
+// compilePattern 编译保留规则，宽松绑定模式下忽略大小写
+func (m *Merger) compilePattern(pattern string) (*regexp.Regexp, error) {
+	if m.opts.SpringRelaxed {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// Matches 判断配置行是否命中保留规则，宽松绑定模式下同时尝试键的kebab与紧凑形式
+func (m *Merger) Matches(line string) bool {
+	_, ok := m.match(line)
+	return ok
+}
+
+// Match 与Matches相同，命中结构化规则时同时返回该规则的comment
+func (m *Merger) Match(line string) (comment string, ok bool) {
+	return m.match(line)
+}
+
+// RuleHit 描述配置行命中的保留规则
+type RuleHit struct {
+	Pattern   bool   // 命中patternKeys正则
+	Index     int    // 命中的结构化规则在Options.Rules中的序号(从1开始)，未命中结构化规则时为0
+	Rule      Rule   // 命中的结构化规则
+	Key       string // 命中结构化规则的键名写法，宽松绑定模式下可能为kebab或紧凑形式
+	Encrypted bool   // 未命中规则，因PreserveEncrypted按加密值保留
+	Excluded  int    // 命中保留规则但被ExcludeKeys中的这一条(从1开始)排除，此时不予保留
+	ForcedNew int    // 命中保留规则但同时命中Rules中policy为force-new的这一条(从1开始)，此时不予保留
+}
+
+// MatchRule 与Matches相同，同时返回命中的是patternKeys、哪一条结构化规则还是加密值，供调试与测试保留规则。
+// 命中保留规则但被排除时返回false，Excluded为排除规则的序号；同时命中policy为force-new的规则时ForcedNew为该规则的序号
+func (m *Merger) MatchRule(line string) (RuleHit, bool) {
+	hit, ok := m.matchKeep(line)
+	if !ok || separator(line) == -1 {
+		return hit, ok
+	}
+	key := LineKey(line)
+	if index, excluded := m.excluded(key); excluded {
+		hit.Excluded = index
+		return hit, false
+	}
+	if index, forced := m.forcedNew(key); forced {
+		hit.ForcedNew = index
+		return hit, false
+	}
+	return hit, true
+}
+
+// excluded 判断键是否命中ExcludeKeys，返回命中的第一条排除规则的序号
+func (m *Merger) excluded(key string) (int, bool) {
+	return m.firstMatch(m.excludes, key, "")
+}
+
+// forcedNew 判断键是否命中policy为force-new的结构化规则，返回命中的第一条的序号
+func (m *Merger) forcedNew(key string) (int, bool) {
+	return m.firstMatch(m.rules, key, PolicyForceNew)
+}
+
+// prompted 判断键是否命中policy为prompt的结构化规则
+func (m *Merger) prompted(key string) bool {
+	_, ok := m.firstMatch(m.rules, key, PolicyPrompt)
+	return ok
+}
+
+// firstMatch 返回rules中命中键的第一条规则的序号，policy非空时只看该策略的规则；宽松绑定模式下同时尝试kebab与紧凑形式
+func (m *Merger) firstMatch(rules []compiledRule, key, policy string) (int, bool) {
+	candidates := []string{key}
+	if m.opts.SpringRelaxed {
+		candidates = append(candidates, SpringKebab(key), SpringCanonical(key))
+	}
+	for _, k := range candidates {
+		for _, r := range rules {
+			if policy != "" && r.rule.Policy != policy {
+				continue
+			}
+			if r.keys.MatchString(k) && (r.exclude == nil || !r.exclude.MatchString(k)) {
+				return r.index, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// matchKeep 判断配置行是否命中保留规则，不考虑ExcludeKeys
+func (m *Merger) matchKeep(line string) (RuleHit, bool) {
+	if m.re != nil && m.re.MatchString(line) {
+		return RuleHit{Pattern: true}, true
+	}
+	if separator(line) == -1 || isComment(line) {
+		return RuleHit{}, false
+	}
+	if normalized := LineKey(line) + "=" + LineValue(line); m.re != nil && normalized != line && m.re.MatchString(normalized) {
+		// 正则规则同时按还原转义、去掉等号两侧的空白与行尾注释后的键值匹配
+		return RuleHit{Pattern: true}, true
+	}
+
+	key := LineKey(line)
+	candidates := []string{key}
+	if m.opts.SpringRelaxed {
+		candidates = append(candidates, SpringKebab(key), SpringCanonical(key))
+		if m.re != nil {
+			value := line[valueStart(line, separator(line)):]
+			for _, k := range candidates[1:] {
+				if m.re.MatchString(k + "=" + value) {
+					return RuleHit{Pattern: true}, true
+				}
+			}
+		}
+	}
+	for _, k := range candidates {
+		if r, ok := m.matchRule(k); ok {
+			return RuleHit{Index: r.index, Rule: r.rule, Key: k}, true
+		}
+	}
+	if m.opts.PreserveEncrypted && IsEncrypted(LineValue(line)) {
+		return RuleHit{Encrypted: true}, true
+	}
+	return RuleHit{}, false
+}
+
+// match 判断配置行是否命中正则保留规则或结构化规则，命中结构化规则时同时返回该规则的说明
+func (m *Merger) match(line string) (string, bool) {
+	hit, ok := m.MatchRule(line)
+	if hit.Excluded > 0 {
+		m.keyDebugf(LineKey(line), 0, ActionSkip, "参数命中保留规则，但被excludeKeys第%d条排除: %s", hit.Excluded, LineKey(line))
+	}
+	if hit.ForcedNew > 0 {
+		m.keyDebugf(LineKey(line), 0, ActionSkip, "参数命中保留规则，但rules第%d条要求取新文件中的值: %s", hit.ForcedNew, LineKey(line))
+	}
+	if hit.Encrypted {
+		return tr("加密值"), ok
+	}
+	return hit.Rule.Comment, ok
+}
+
+// springEnvKey 判断键是否为Spring环境变量形式，如 ACME_MYPROJECT_PERSON_FIRSTNAME:
+// 不含'.'与小写字母、含有'_'，其中的'_'对应'.'
+func springEnvKey(key string) bool {
+	return strings.Contains(key, "_") && !strings.ContainsAny(key, ".-") && key == strings.ToUpper(key)
+}
+
+// SpringCanonical 返回键在Spring宽松绑定下的比较形式: 全小写并去掉'-'与'_'，
+// 使 first-name、firstName、first_name、FIRST-NAME 视为同一个键；
+// 环境变量形式的键(如 ACME_MYPROJECT_PERSON_FIRSTNAME)先将'_'还原为'.'
+func SpringCanonical(key string) string {
+	if springEnvKey(key) {
+		return strings.ToLower(strings.ReplaceAll(key, "_", "."))
+	}
+	var b strings.Builder
+	b.Grow(len(key))
+	for _, r := range strings.ToLower(key) {
+		if r == '-' || r == '_' {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// SpringKebab 将键的每一段转换为Spring推荐的小写kebab形式，如 upLoadPath -> up-load-path；
+// 环境变量形式的键转换为小写的点分形式，如 SPRING_REDIS_HOST -> spring.redis.host
+func SpringKebab(key string) string {
+	if springEnvKey(key) {
+		return SpringCanonical(key)
+	}
+	var b strings.Builder
+	b.Grow(len(key) + 4)
+	prevLower := false
+	for _, r := range key {
+		switch {
+		case r == '_':
+			b.WriteRune('-')
+			prevLower = false
+		case r >= 'A' && r <= 'Z':
+			if prevLower {
+				b.WriteRune('-')
+			}
+			b.WriteRune(r + ('a' - 'A'))
+			prevLower = false
+		default:
+			b.WriteRune(r)
+			prevLower = r >= 'a' && r <= 'z' || r >= '0' && r <= '9'
+		}
+	}
+	return b.String()
+}
+
+// lookupKey 返回用于比较的键形式
+func (m *Merger) lookupKey(key string) string {
+	if m.opts.SpringRelaxed {
+		return SpringCanonical(key)
+	}
+	return key
+}
+
+// relaxedReplacement 使用新文件中键的写法和旧文件中的值生成替换行
+func relaxedReplacement(newLine, oldLine string) string {
+	newIdx := separator(newLine)
+	oldIdx := separator(oldLine)
+	if newIdx == -1 || oldIdx == -1 {
+		return oldLine
+	}
+	return newLine[:newIdx] + oldLine[oldIdx:]
+}
@@ -0,0 +1,86 @@
+package propmerge
+
+import (
+	"strings"
+	"testing"
+)
+
+// springForms 对应Spring Boot文档中宽松绑定的示例: 同一个属性的kebab、驼峰、下划线与环境变量写法
+var springForms = []struct {
+	name  string
+	forms []string
+}{
+	{"acme.my-project.person.first-name", []string{
+		"acme.my-project.person.first-name", // kebab，推荐写法
+		"acme.myProject.person.firstName",   // 驼峰
+		"acme.my_project.person.first_name", // 下划线
+		"ACME_MYPROJECT_PERSON_FIRSTNAME",   // 环境变量
+		"Acme.My-Project.Person.First-Name", // kebab形式忽略大小写
+	}},
+	{"spring.jpa.database-platform", []string{
+		"spring.jpa.database-platform",
+		"spring.jpa.databasePlatform",
+		"spring.jpa.database_platform",
+		"SPRING_JPA_DATABASEPLATFORM",
+	}},
+	{"web.back.up-load-path", []string{
+		"web.back.up-load-path",
+		"web.back.upLoadPath",
+		"web.back.up_load_path",
+		"WEB_BACK_UPLOADPATH",
+	}},
+}
+
+func TestSpringCanonicalMatrix(t *testing.T) {
+	for _, group := range springForms {
+		want := SpringCanonical(group.name)
+		for _, form := range group.forms {
+			if got := SpringCanonical(form); got != want {
+				t.Errorf("SpringCanonical(%q) = %q, want %q (same as %q)", form, got, want, group.name)
+			}
+		}
+	}
+	for _, other := range []string{"acme.my-project.person.last-name", "acme.myproject.personfirst.name.x", "ACME_MYPROJECT_PERSON"} {
+		if SpringCanonical(other) == SpringCanonical("acme.my-project.person.first-name") {
+			t.Errorf("SpringCanonical(%q) should differ from acme.my-project.person.first-name", other)
+		}
+	}
+}
+
+func TestSpringKebab(t *testing.T) {
+	tests := map[string]string{
+		"web.back.upLoadPath":               "web.back.up-load-path",
+		"acme.my_project.person.first_name": "acme.my-project.person.first-name",
+		"acme.my-project.person.first-name": "acme.my-project.person.first-name",
+		"SPRING_REDIS_HOST":                 "spring.redis.host",
+	}
+	for key, want := range tests {
+		if got := SpringKebab(key); got != want {
+			t.Errorf("SpringKebab(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+// TestSpringRelaxedMergeMatrix 旧文件与新文件分别使用各种写法时都能找到同一个键，
+// 保留旧值并沿用新文件中键的写法
+func TestSpringRelaxedMergeMatrix(t *testing.T) {
+	for _, group := range springForms {
+		m, err := New(Options{Rules: []Rule{{Type: RuleGlob, Keys: []string{group.name}}}, SpringRelaxed: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, oldForm := range group.forms {
+			for _, newForm := range group.forms {
+				result, err := m.MergeLines([]string{oldForm + "=old"}, []string{"other=1", newForm + "=new"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := strings.Join(result.Lines, "\n")
+				want := "other=1\n" + newForm + "=old"
+				if got != want {
+					t.Errorf("old %q, new %q: merged\n%s\nwant\n%s", oldForm, newForm, got, want)
+				}
+			}
+		}
+	}
+}
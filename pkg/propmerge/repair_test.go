@@ -0,0 +1,66 @@
+package propmerge
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 被错误合并的文件: extra.key插在了文件开头，ftp.host在模板位置保留了模板值、旧值被插在了别处，
+// y在合并后被手工改为5，stale.key既不在模板中也不是保留参数
+var (
+	repairOld      = []string{"a=1", "ftp.host=10.0.0.1", "b=2", "extra.key=e"}
+	repairTemplate = []string{"# header", "x=1", "ftp.host=tmpl", "y=2", "", "# tail", "z=3"}
+	repairCurrent  = []string{"extra.key=e", "# header", "x=1", "ftp.host=tmpl", "y=5", "ftp.host=10.0.0.1", "", "# tail", "z=3", "stale.key=9"}
+)
+
+func TestRepairKeepsPreservedValue(t *testing.T) {
+	m, err := New(Options{Pattern: `^(ftp|extra)\.`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, removed := m.Repair(repairOld, repairCurrent)
+	want := []string{"extra.key=e", "# header", "x=1", "y=5", "ftp.host=10.0.0.1", "", "# tail", "z=3", "stale.key=9"}
+	if !reflect.DeepEqual(result.Lines, want) {
+		t.Errorf("Repair = %q, want %q", result.Lines, want)
+	}
+	if wantRemoved := []RemovedLine{{Line: 4, Text: "ftp.host=tmpl"}}; !reflect.DeepEqual(removed, wantRemoved) {
+		t.Errorf("removed = %v, want %v", removed, wantRemoved)
+	}
+
+	// 没有一处写有旧值时保留第一处，缺失的保留参数按插入策略补上
+	result, removed = m.Repair(repairOld, []string{"ftp.host=a", "x=1", "ftp.host=b"})
+	if want := []string{"ftp.host=10.0.0.1", "x=1", "extra.key=e"}; !reflect.DeepEqual(result.Lines, want) {
+		t.Errorf("Repair without the old value = %q, want %q", result.Lines, want)
+	}
+	if len(removed) != 1 || removed[0].Line != 3 {
+		t.Errorf("removed = %v, want line 3", removed)
+	}
+}
+
+func TestRepairTemplateRebuilds(t *testing.T) {
+	m, err := New(Options{Pattern: `^(ftp|extra)\.`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, removed := m.RepairTemplate(repairOld, repairCurrent, repairTemplate)
+	want := []string{"# header", "x=1", "ftp.host=10.0.0.1", "extra.key=e", "y=5", "", "# tail", "z=3"}
+	if !reflect.DeepEqual(result.Lines, want) {
+		t.Errorf("RepairTemplate = %q, want %q", result.Lines, want)
+	}
+	wantRemoved := []RemovedLine{{Line: 4, Text: "ftp.host=tmpl"}, {Line: 10, Text: "stale.key=9"}}
+	if !reflect.DeepEqual(removed, wantRemoved) {
+		t.Errorf("removed = %v, want %v", removed, wantRemoved)
+	}
+
+	// 重建结果与对模板直接合并相同(current中没有手工修改时)
+	merged, err := m.MergeLines(repairOld, repairTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := append([]string(nil), repairCurrent...)
+	current[4] = "y=2"
+	result, _ = m.RepairTemplate(repairOld, current, repairTemplate)
+	if !reflect.DeepEqual(result.Lines, merged.Lines) {
+		t.Errorf("RepairTemplate = %q, want the same as MergeLines %q", result.Lines, merged.Lines)
+	}
+}
@@ -0,0 +1,169 @@
+package propmerge
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 保留规则类型
+const (
+	RulePrefix = "prefix" // 键以keys中任一项开头
+	RuleExact  = "exact"  // 键与keys中任一项完全相同
+	RuleRegex  = "regex"  // 键匹配keys中任一正则
+	RuleGlob   = "glob"   // 键匹配keys中任一通配符，*匹配不含'.'的任意字符，**匹配任意字符
+)
+
+// 结构化规则的处理策略(policy)
+const (
+	PolicyKeepOld  = "keep-old"  // 保留旧值(默认)
+	PolicyForceNew = "force-new" // 始终取新文件中的值，即使键同时命中patternKeys或其他规则
+	PolicyPrompt   = "prompt"    // 写入的旧值与新值不同时先经Options.Confirm确认，见KeyResult.Prompt
+)
+
+// 结构化规则的列表合并方式(mergeList): 值为逗号分隔的列表(如spring.profiles.include、IP白名单)且新文件中已有该键时，
+// 旧值与新值如何合并
+const (
+	ListOld   = "old"   // 写入旧值(默认)
+	ListNew   = "new"   // 取新文件中的值
+	ListUnion = "union" // 新值的各项在前，旧值中新值没有的项依次追加在后，去除重复项
+)
+
+// Rule 是config-matcher.json v2中的一条结构化保留规则。exclude与keys使用相同的类型，
+// 命中keys但同时命中exclude的键不予保留；policy为命中键的处理策略，为空时同keep-old；
+// mergeList为列表值的合并方式，为空时同old
+type Rule struct {
+	Type      string   `json:"type"`
+	Keys      []string `json:"keys"`
+	Exclude   []string `json:"exclude,omitempty"`
+	Comment   string   `json:"comment,omitempty"`
+	Policy    string   `json:"policy,omitempty"`
+	MergeList string   `json:"mergeList,omitempty"`
+}
+
+// UnmarshalJSON 允许rules中的一项直接写作字符串，如"spring.datasource.**"，按通配符规则(glob)处理，
+// 可以与对象形式的regex等规则写在同一个列表中
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	var glob string
+	if err := json.Unmarshal(data, &glob); err == nil {
+		*r = Rule{Type: RuleGlob, Keys: []string{glob}}
+		return nil
+	}
+	type plain Rule
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// compiledRule 是编译后的保留规则
+type compiledRule struct {
+	rule    Rule
+	index   int // 在Options.Rules中的序号(从1开始)
+	keys    *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// compileRules 将结构化规则编译为键匹配正则
+func (m *Merger) compileRules(rules []Rule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for i, rule := range rules {
+		if len(rule.Keys) == 0 {
+			return nil, fmt.Errorf(tr("第%d条规则未定义keys"), i+1)
+		}
+		keys, err := m.rulePattern(rule.Type, rule.Keys)
+		if err != nil {
+			return nil, fmt.Errorf(tr("第%d条规则无效: %w"), i+1, err)
+		}
+		switch rule.Policy {
+		case "", PolicyKeepOld, PolicyForceNew, PolicyPrompt:
+		default:
+			return nil, fmt.Errorf(tr("第%d条规则的policy无效: %s (可选keep-old、force-new、prompt)"), i+1, rule.Policy)
+		}
+		switch rule.MergeList {
+		case "", ListOld, ListNew, ListUnion:
+		default:
+			return nil, fmt.Errorf(tr("第%d条规则的mergeList无效: %s (可选union、old、new)"), i+1, rule.MergeList)
+		}
+		c := compiledRule{rule: rule, index: i + 1, keys: keys}
+		if len(rule.Exclude) > 0 {
+			if c.exclude, err = m.rulePattern(rule.Type, rule.Exclude); err != nil {
+				return nil, fmt.Errorf(tr("第%d条规则的exclude无效: %w"), i+1, err)
+			}
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// rulePattern 按规则类型将键列表转换为一个正则。宽松绑定模式下prefix、exact与glob规则同时匹配键的规范形式
+// (见SpringCanonical)，使环境变量形式等无法还原为kebab形式的键也能命中
+func (m *Merger) rulePattern(typ string, keys []string) (*regexp.Regexp, error) {
+	if m.opts.SpringRelaxed && typ != RuleRegex {
+		all := append([]string(nil), keys...)
+		for _, key := range keys {
+			if canonical := SpringCanonical(key); canonical != key {
+				all = append(all, canonical)
+			}
+		}
+		keys = all
+	}
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		switch typ {
+		case RulePrefix, "":
+			parts[i] = "^" + regexp.QuoteMeta(key)
+		case RuleExact:
+			parts[i] = "^" + regexp.QuoteMeta(key) + "$"
+		case RuleRegex:
+			if _, err := regexp.Compile(key); err != nil {
+				return nil, globHint(key, err)
+			}
+			parts[i] = "(?:" + key + ")"
+		case RuleGlob:
+			parts[i] = "^" + globPattern(key) + "$"
+		default:
+			return nil, fmt.Errorf(tr("不支持的规则类型: %s"), typ)
+		}
+	}
+	return m.compilePattern(strings.Join(parts, "|"))
+}
+
+// globHint 在无法编译的正则含有*或?时提示改用通配符: 把ftp.*误写成*.password这类正则是最常见的错误
+func globHint(pattern string, err error) error {
+	if strings.ContainsAny(pattern, "*?") {
+		return fmt.Errorf(tr("%w (如需通配符，可在rules中直接写字符串\"%s\"或使用type: glob)"), err, pattern)
+	}
+	return err
+}
+
+// globPattern 将键名通配符转换为正则
+func globPattern(glob string) string {
+	var b strings.Builder
+	runes := []rune(glob)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; {
+		case c == '*' && i+1 < len(runes) && runes[i+1] == '*':
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString(`[^.]*`)
+		case c == '?':
+			b.WriteString(`[^.]`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// matchRule 返回命中键的第一条保留规则，policy为force-new的规则不是保留规则
+func (m *Merger) matchRule(key string) (compiledRule, bool) {
+	for _, r := range m.rules {
+		if r.rule.Policy == PolicyForceNew {
+			continue
+		}
+		if r.keys.MatchString(key) && (r.exclude == nil || !r.exclude.MatchString(key)) {
+			return r, true
+		}
+	}
+	return compiledRule{}, false
+}
@@ -0,0 +1,53 @@
+package propmerge
+
+import "sort"
+
+// withSecrets 返回以Options.Secrets中的值代替旧值后的保留参数: 旧文件中已保留的键改写其值，
+// 旧文件中没有(或未保留)的键作为旧文件末尾之后的行加入，新文件中存在时替换，否则按插入策略写入。
+// oldCount为旧文件的行数
+func (m *Merger) withSecrets(keep map[int]string, oldCount int) map[int]string {
+	if len(m.opts.Secrets) == 0 {
+		return keep
+	}
+
+	lookup := make(map[string]string, len(m.opts.Secrets))
+	for key := range m.opts.Secrets {
+		lookup[m.lookupKey(key)] = key
+	}
+	out := make(map[int]string, len(keep)+len(m.opts.Secrets))
+	found := make(map[string]bool)
+	for n, line := range keep {
+		if key, ok := lookup[m.lookupKey(LineKey(line))]; ok {
+			line = withLineValue(line, m.opts.Secrets[key])
+			found[key] = true
+			m.keyDebugf(key, n, "", "使用密钥管理中的值代替旧值[行%d]: %s", n, key)
+		}
+		out[n] = line
+	}
+
+	var missing []string
+	for key := range m.opts.Secrets {
+		if !found[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for i, key := range missing {
+		out[oldCount+i+1] = EscapeKey(key, false) + "=" + m.opts.Secrets[key]
+		m.keyDebugf(key, 0, "", "旧文件中没有该参数，使用密钥管理中的值: %s", key)
+	}
+	return out
+}
+
+// isSecret 判断键的值是否来自Options.Secrets
+func (m *Merger) isSecret(key string) bool {
+	if len(m.opts.Secrets) == 0 {
+		return false
+	}
+	for k := range m.opts.Secrets {
+		if m.lookupKey(k) == m.lookupKey(key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+//go:build linux
+
+package propmerge
+
+import (
+	"strings"
+	"syscall"
+)
+
+// securityXattr 为保存SELinux安全上下文的扩展属性
+const securityXattr = "security.selinux"
+
+// SecurityContext 返回文件的SELinux安全上下文，系统未启用SELinux或文件没有上下文时返回空串
+func SecurityContext(path string) string {
+	size, err := syscall.Getxattr(path, securityXattr, nil)
+	if err != nil || size <= 0 {
+		return ""
+	}
+	buf := make([]byte, size)
+	n, err := syscall.Getxattr(path, securityXattr, buf)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(buf[:n]), "\x00")
+}
+
+// SetSecurityContext 设置文件的SELinux安全上下文，如system_u:object_r:etc_t:s0
+func SetSecurityContext(path, context string) error {
+	return syscall.Setxattr(path, securityXattr, []byte(context), 0)
+}
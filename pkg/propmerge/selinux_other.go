@@ -0,0 +1,15 @@
+//go:build !linux
+
+package propmerge
+
+import "errors"
+
+// SecurityContext 在不支持SELinux的平台上返回空串
+func SecurityContext(path string) string {
+	return ""
+}
+
+// SetSecurityContext 在不支持SELinux的平台上返回错误
+func SetSecurityContext(path, context string) error {
+	return errors.New(tr("当前平台不支持SELinux安全上下文"))
+}
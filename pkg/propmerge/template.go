@@ -0,0 +1,51 @@
+package propmerge
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// ErrTemplate 在Options.TemplateData非nil且有值模板无法渲染(如引用了不存在的变量)时返回
+var ErrTemplate error = message("值模板渲染失败")
+
+// render 将合并结果中含有{{的参数值作为Go模板渲染并写回，保留键与等号两侧的写法。
+// 全部参数都尝试渲染，有失败的参数时返回列出全部失败参数的错误
+func (m *Merger) render(lines []string) error {
+	if m.opts.TemplateData == nil {
+		return nil
+	}
+	var failed []string
+	for i, line := range lines {
+		if isComment(line) || separator(line) == -1 || !strings.Contains(line, "{{") {
+			continue
+		}
+		key, value := LineKey(line), LineValue(line)
+		rendered, err := renderValue(key, value, m.opts.TemplateData)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", key, err))
+			continue
+		}
+		if rendered != value {
+			lines[i] = withLineValue(line, EscapeValue(rendered, m.escapeASCII()))
+			m.keyDebugf(key, 0, "", "渲染参数值模板: %s", key)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf(tr("%w: %d个参数的值模板无法渲染，未写入任何修改:\n  %s"), ErrTemplate, len(failed), strings.Join(failed, "\n  "))
+	}
+	return nil
+}
+
+// renderValue 以data渲染值模板，引用不存在的键时返回错误而不是写入<no value>
+func renderValue(key, value string, data map[string]interface{}) (string, error) {
+	t, err := template.New(key).Option("missingkey=error").Parse(value)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,379 @@
+package propmerge
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// IsTOMLFile 根据扩展名判断是否为TOML文件
+func IsTOMLFile(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == ".toml"
+}
+
+// tomlEntry 描述TOML文件中的一个键值对，path为表名与键以点连接的完整路径
+type tomlEntry struct {
+	path    string
+	table   string // 所属的表，根表为空
+	keyText string // 行中等号之前的原始键文本(含引号)
+	start   int    // 键所在行(从0开始)
+	end     int    // 值(含多行字符串与多行数组)之后的第一行
+	value   string // 去掉行尾注释后的值
+	index   int    // 所属数组表[[table]]的序号(从0开始)，不在数组表中时为-1
+}
+
+// id 返回在文件中唯一定位该键的路径，数组表中的键带有所属元素的序号，如servers[1].host
+func (e tomlEntry) id() string {
+	if e.index < 0 {
+		return e.path
+	}
+	return fmt.Sprintf("%s[%d]%s", e.table, e.index, e.path[len(e.table):])
+}
+
+// tomlTable 描述一个[table]或[[table]]表头
+type tomlTable struct {
+	name  string
+	start int // 表头所在行
+	index int // 数组表中第几个同名的[[table]](从0开始)，普通表为-1
+}
+
+// parseTOMLKey 解析点分键(支持裸键与带引号的键)，返回各段键名与键之后的内容
+func parseTOMLKey(s string) (segments []string, rest string, ok bool) {
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return nil, "", false
+		}
+		switch q := s[0]; q {
+		case '"', '\'':
+			end := 1
+			for end < len(s) && s[end] != q {
+				if q == '"' && s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, "", false
+			}
+			segments = append(segments, s[1:end])
+			s = s[end+1:]
+		default:
+			end := 0
+			for end < len(s) && isTOMLBareKeyChar(s[end]) {
+				end++
+			}
+			if end == 0 {
+				return nil, "", false
+			}
+			segments = append(segments, s[:end])
+			s = s[end:]
+		}
+		s = strings.TrimLeft(s, " \t")
+		if !strings.HasPrefix(s, ".") {
+			return segments, s, true
+		}
+		s = s[1:]
+	}
+}
+
+// isTOMLBareKeyChar 判断是否为裸键允许的字符
+func isTOMLBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// tomlKeyText 将各段键名拼接为键文本，非裸键的段加双引号
+func tomlKeyText(segments []string) string {
+	parts := make([]string, len(segments))
+	for i, seg := range segments {
+		parts[i] = seg
+		for j := 0; j < len(seg); j++ {
+			if !isTOMLBareKeyChar(seg[j]) {
+				parts[i] = `"` + strings.ReplaceAll(seg, `"`, `\"`) + `"`
+				break
+			}
+		}
+		if seg == "" {
+			parts[i] = `""`
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// parseTOMLHeader 解析[table]表头，数组表[[table]]的array为true
+func parseTOMLHeader(trimmed string) (name string, array, ok bool) {
+	if !strings.HasPrefix(trimmed, "[") {
+		return "", false, false
+	}
+	s := trimmed[1:]
+	if strings.HasPrefix(s, "[") {
+		array = true
+		s = s[1:]
+	}
+	segments, rest, ok := parseTOMLKey(s)
+	if !ok {
+		return "", false, false
+	}
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(rest, closing) {
+		return "", false, false
+	}
+	return strings.Join(segments, "."), array, true
+}
+
+// tomlValueSpan 从等号之后的内容开始扫描值，跨越多行字符串与多行数组，
+// 返回值之后的第一行以及去掉行尾注释的值文本
+func tomlValueSpan(lines []string, start int, rest string) (int, string) {
+	var parts []string
+	depth := 0
+	multi := "" // 当前所在多行字符串的定界符
+	text := rest
+	for i := start; ; {
+		j := 0
+	scan:
+		for j < len(text) {
+			c := text[j]
+			switch {
+			case multi != "":
+				if multi == `"""` && c == '\\' {
+					j += 2
+					continue
+				}
+				if strings.HasPrefix(text[j:], multi) {
+					j += 3
+					multi = ""
+					continue
+				}
+			case strings.HasPrefix(text[j:], `"""`) || strings.HasPrefix(text[j:], `'''`):
+				multi = text[j : j+3]
+				j += 3
+				continue
+			case c == '"' || c == '\'':
+				k := j + 1
+				for k < len(text) && text[k] != c {
+					if c == '"' && text[k] == '\\' {
+						k++
+					}
+					k++
+				}
+				j = k + 1
+				continue
+			case c == '[' || c == '{':
+				depth++
+			case c == ']' || c == '}':
+				depth--
+			case c == '#':
+				text = text[:j]
+				break scan
+			}
+			j++
+		}
+		parts = append(parts, strings.TrimRight(text, " \t\r"))
+
+		i++
+		if (multi == "" && depth <= 0) || i >= len(lines) {
+			return i, strings.TrimSpace(strings.Join(parts, "\n"))
+		}
+		text = lines[i]
+	}
+}
+
+// parseTOML 解析TOML文件中的表与键值对。数组表[[table]]按同名表头出现的顺序编号，
+// 其中的键以路径与序号定位
+func parseTOML(lines []string) ([]tomlEntry, []tomlTable) {
+	var entries []tomlEntry
+	var tables []tomlTable
+	table, index := "", -1
+	counts := make(map[string]int)
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if name, array, ok := parseTOMLHeader(trimmed); ok {
+			table, index = name, -1
+			if array {
+				index = counts[name]
+				counts[name]++
+			}
+			tables = append(tables, tomlTable{name: name, start: i, index: index})
+			continue
+		}
+
+		segments, rest, ok := parseTOMLKey(trimmed)
+		if !ok || !strings.HasPrefix(rest, "=") {
+			continue
+		}
+		end, value := tomlValueSpan(lines, i, rest[1:])
+		path := strings.Join(segments, ".")
+		if table != "" {
+			path = table + "." + path
+		}
+		keyText := strings.TrimSpace(trimmed[:len(trimmed)-len(rest)])
+		entries = append(entries, tomlEntry{path: path, table: table, keyText: keyText, start: i, end: end, value: value, index: index})
+		i = end - 1
+	}
+	return entries, tables
+}
+
+// tomlValueText 返回键值对首行中等号及其之后的原始内容(等号后统一保留一个空格)
+func tomlValueText(line string) string {
+	_, rest, _ := parseTOMLKey(strings.TrimSpace(line))
+	return "= " + strings.TrimLeft(rest[1:], " \t")
+}
+
+// MergeTOML 从旧TOML中提取命中保留规则的键(以"表名.键"的点分路径匹配)，写入新TOML的对应位置。
+// 新文件中已存在的键只替换值，保留新文件的键写法与缩进；缺失的键插入到所属表的末尾，
+// 表不存在时在文件末尾追加该表；新文件其余内容和注释保持不变。
+// 数组表[[table]]中的键按位置对应: 旧文件第n个[[table]]中的键写入新文件第n个同名数组表，
+// 新文件中没有对应的元素时跳过并给出警告
+func (m *Merger) MergeTOML(old io.Reader, new io.Reader) (Result, error) {
+	oldLines, err := ReadLines(old)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+	newLines, err := ReadLines(new)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取新文件失败: %w"), err)
+	}
+	return m.MergeTOMLLines(oldLines, newLines)
+}
+
+// MergeTOMLLines 与MergeTOML相同，但直接处理已读取的行
+func (m *Merger) MergeTOMLLines(oldLines, newLines []string) (Result, error) {
+	lines := append([]string(nil), newLines...)
+	oldEntries, _ := parseTOML(oldLines)
+	var kept []tomlEntry
+	oldPaths := make(map[string]bool)
+	for _, o := range oldEntries {
+		if m.Matches(o.path + "=" + o.value) {
+			kept = append(kept, o)
+			oldPaths[o.path] = true
+		}
+	}
+
+	var results []KeyResult
+	for _, o := range kept {
+		m.keyDebugf(o.path, o.start+1, "", "找到匹配参数[行%d]: %s", o.start+1, o.path)
+
+		block := append([]string(nil), oldLines[o.start:o.end]...)
+		entries, tables := parseTOML(lines)
+		result := KeyResult{Key: o.id(), OldValue: o.value}
+		// 数组表中的键按位置对应，不参与重命名
+		if o.index < 0 && !m.renamePath(&result, oldPaths) {
+			results = append(results, result)
+			continue
+		}
+		if result.RenamedFrom != "" {
+			// 重命名后的键不再属于旧文件中的表，按完整路径确定插入位置
+			o.path, o.table, o.keyText = result.Key, "", tomlKeyText(strings.Split(result.Key, "."))
+		}
+		if len(block) == 1 && m.transformResult(&result, true) {
+			block[0] = withInlineValue(block[0], result.TransformedFrom, result.OldValue)
+		}
+
+		if n, ok := findTOMLEntry(entries, o.path, o.index); ok {
+			result.Action = ActionReplace
+			result.Line = n.start + 1
+			result.NewValue = n.value
+			if !m.renameCollision(&result) || !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			indent := lines[n.start][:len(lines[n.start])-len(strings.TrimLeft(lines[n.start], " \t"))]
+			block[0] = indent + n.keyText + " " + tomlValueText(block[0])
+			lines = append(lines[:n.start], append(block, lines[n.end:]...)...)
+			m.keyDebugf(o.path, n.start+1, ActionReplace, "替换参数[行%d]: %s", n.start+1, o.path)
+		} else if o.index >= 0 && !hasTOMLTable(tables, o.table, o.index) {
+			m.keyWarnf(o.id(), o.start+1, ActionSkip, "新文件中没有第%d个[[%s]]，旧文件中的 %s 已跳过", o.index+1, o.table, o.id())
+			result.Action = ActionSkip
+			results = append(results, result)
+			continue
+		} else {
+			var inserted []string
+			inserted, result.Line, result.Action = insertTOMLEntry(lines, entries, tables, o, block)
+			if !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			lines = inserted
+			m.keyDebugf(o.path, result.Line, ActionInsert, "插入参数[行%d]: %s", result.Line, o.path)
+		}
+		m.trace(TraceEvent{Event: "action", Line: result.Line, Key: o.path, Text: strings.TrimSpace(block[0]), Result: result.Action})
+		results = append(results, result)
+	}
+	merged := Result{Lines: lines, Keys: results}
+	return merged, m.checkCollisions(merged)
+}
+
+// findTOMLEntry 按路径与所属数组表的序号查找第一个匹配的键值对
+func findTOMLEntry(entries []tomlEntry, path string, index int) (tomlEntry, bool) {
+	for _, e := range entries {
+		if e.path == path && e.index == index {
+			return e, true
+		}
+	}
+	return tomlEntry{}, false
+}
+
+// hasTOMLTable 判断是否存在指定名称与序号的表
+func hasTOMLTable(tables []tomlTable, name string, index int) bool {
+	for _, t := range tables {
+		if t.name == name && t.index == index {
+			return true
+		}
+	}
+	return false
+}
+
+// insertTOMLEntry 将新文件中不存在的键插入到路径最长的已有表中该表最后一个键之后；
+// 没有可用的表时，根表中的键插入到根表末尾，其余在文件末尾追加旧文件中所属的表。
+// 数组表中的键插入到对应序号的元素中(调用方已确认其存在)
+func insertTOMLEntry(lines []string, entries []tomlEntry, tables []tomlTable, o tomlEntry, block []string) ([]string, int, string) {
+	segments := strings.Split(o.path, ".")
+	table, found := "", false
+	at := 0
+	for j := len(segments) - 1; j > 0 && !found; j-- {
+		name := strings.Join(segments[:j], ".")
+		for _, t := range tables {
+			if t.name == name && (o.index < 0 && t.index < 0 || name == o.table && t.index == o.index) {
+				table, found, at = name, true, t.start+1
+				break
+			}
+		}
+	}
+	if !found && o.table != "" {
+		// 所属的表不存在，在文件末尾追加表头
+		inserted := []string{"[" + tomlKeyText(strings.Split(o.table, ".")) + "]", o.keyText + " " + tomlValueText(block[0])}
+		inserted = append(inserted, block[1:]...)
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			inserted = append([]string{""}, inserted...)
+		}
+		return append(lines, inserted...), len(lines) + len(inserted) - len(block) + 1, ActionAppend
+	}
+
+	for _, e := range entries {
+		if e.table == table && e.index == o.index && e.end > at {
+			at = e.end
+		}
+	}
+	keyText := o.keyText
+	if table != o.table {
+		prefix := 0
+		if table != "" {
+			prefix = len(strings.Split(table, "."))
+		}
+		keyText = tomlKeyText(segments[prefix:])
+	}
+	leaf := append([]string{keyText + " " + tomlValueText(block[0])}, block[1:]...)
+
+	result := make([]string, 0, len(lines)+len(leaf))
+	result = append(result, lines[:at]...)
+	result = append(result, leaf...)
+	result = append(result, lines[at:]...)
+	return result, at + 1, ActionInsert
+}
@@ -0,0 +1,81 @@
+package propmerge
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestMergeTOMLArrayTables 数组表[[table]]中的键按位置写入新文件中同序号的元素，没有对应元素时跳过并记入结果
+func TestMergeTOMLArrayTables(t *testing.T) {
+	old := []string{
+		"[server]",
+		"host = \"10.0.0.1\"",
+		"",
+		"[[upstream]]",
+		"name = \"a\"",
+		"host = \"10.0.1.1\"",
+		"",
+		"[[upstream]]",
+		"name = \"b\"",
+		"host = \"10.0.1.2\"",
+		"",
+		"[[upstream]]",
+		"name = \"c\"",
+		"host = \"10.0.1.3\"",
+	}
+	template := []string{
+		"[server]",
+		"host = \"0.0.0.0\"",
+		"",
+		"[[upstream]]",
+		"name = \"a\"",
+		"host = \"127.0.0.1\"",
+		"",
+		"[[upstream]]",
+		"name = \"b\"",
+		"weight = 2",
+	}
+	m, err := New(Options{Pattern: `^(server|upstream)\.host=`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := m.MergeTOMLLines(old, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"[server]",
+		"host = \"10.0.0.1\"",
+		"",
+		"[[upstream]]",
+		"name = \"a\"",
+		"host = \"10.0.1.1\"",
+		"",
+		"[[upstream]]",
+		"name = \"b\"",
+		"weight = 2",
+		"host = \"10.0.1.2\"",
+	}
+	if strings.Join(result.Lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("merged:\n%s\nwant:\n%s", strings.Join(result.Lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	actions := map[string]string{}
+	for _, k := range result.Keys {
+		actions[k.Key] = k.Action
+	}
+	wantActions := map[string]string{
+		"server.host":      ActionReplace,
+		"upstream[0].host": ActionReplace,
+		"upstream[1].host": ActionInsert,
+		"upstream[2].host": ActionSkip,
+	}
+	for key, action := range wantActions {
+		if actions[key] != action {
+			t.Errorf("action for %s = %q, want %q (all: %v)", key, actions[key], action, actions)
+		}
+	}
+	if len(actions) != len(wantActions) {
+		t.Errorf("keys = %v, want %v", actions, wantActions)
+	}
+}
@@ -0,0 +1,166 @@
+package propmerge
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Transform 是config-matcher.json中的一条值转换规则，在合并时改写键命中keys的保留参数的值，
+// 如把旧的JDBC主机名改为新集群的VIP、把token.expireTime放大若干倍。
+// 同一条规则中依次执行正则替换(match/replace)、模板改写(template)与数值缩放(factor)，
+// 多条规则按定义顺序依次作用于上一条的结果
+type Transform struct {
+	Type     string   `json:"type,omitempty"`     // 键的匹配方式，取值同Rule.Type，默认为prefix
+	Keys     []string `json:"keys"`               // 需要转换的键
+	Match    string   `json:"match,omitempty"`    // 匹配值的正则
+	Replace  string   `json:"replace,omitempty"`  // 替换内容，可用$1、${name}引用match中的分组
+	Template string   `json:"template,omitempty"` // 改写模板，{value}为当前值，{key}为键名
+	Factor   float64  `json:"factor,omitempty"`   // 数值缩放系数，值不是数字时不做缩放
+	Comment  string   `json:"comment,omitempty"`
+}
+
+// compiledTransform 是编译后的值转换规则
+type compiledTransform struct {
+	transform Transform
+	keys      *regexp.Regexp
+	match     *regexp.Regexp
+}
+
+// compileTransforms 编译值转换规则
+func (m *Merger) compileTransforms(transforms []Transform) ([]compiledTransform, error) {
+	compiled := make([]compiledTransform, 0, len(transforms))
+	for i, t := range transforms {
+		if len(t.Keys) == 0 {
+			return nil, fmt.Errorf(tr("第%d条转换规则未定义keys"), i+1)
+		}
+		if t.Match == "" && t.Template == "" && t.Factor == 0 {
+			return nil, fmt.Errorf(tr("第%d条转换规则未定义match、template或factor"), i+1)
+		}
+		keys, err := m.rulePattern(t.Type, t.Keys)
+		if err != nil {
+			return nil, fmt.Errorf(tr("第%d条转换规则无效: %w"), i+1, err)
+		}
+		c := compiledTransform{transform: t, keys: keys}
+		if t.Match != "" {
+			if c.match, err = regexp.Compile(t.Match); err != nil {
+				return nil, fmt.Errorf(tr("第%d条转换规则的match无效: %w"), i+1, err)
+			}
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// transformValue 按转换规则依次改写键的值，每处改写都输出改写前后的值；secret为true时值为解密后的明文，
+// 日志与跟踪记录中一律隐藏
+func (m *Merger) transformValue(key, value string, line int, secret bool) string {
+	show := func(v string) string {
+		if secret {
+			return MaskedValue
+		}
+		return m.opts.Mask.Value(key, v)
+	}
+	for _, t := range m.transforms {
+		if !t.keys.MatchString(key) {
+			continue
+		}
+		before := value
+		if t.match != nil {
+			value = t.match.ReplaceAllString(value, t.transform.Replace)
+		}
+		if t.transform.Template != "" {
+			value = strings.NewReplacer("{value}", value, "{key}", key).Replace(t.transform.Template)
+		}
+		if t.transform.Factor != 0 {
+			scaled, ok := scaleValue(value, t.transform.Factor)
+			if !ok {
+				m.keyWarnf(key, line, "", "参数值不是数字，未按系数%v缩放: %s", t.transform.Factor, key)
+			}
+			value = scaled
+		}
+		if value != before {
+			m.logf(slog.LevelInfo, keyAttrs(key, line, ""), "转换参数值: %s: %s -> %s", key, show(before), show(value))
+			m.trace(TraceEvent{Event: "action", Line: line, Key: key, Text: key + "=" + show(value), Result: "transform"})
+		}
+	}
+	return value
+}
+
+// scaleValue 将数值乘以系数，整数乘以后仍为整数时按整数写出；值不是数字时ok为false并返回原值
+func scaleValue(value string, factor float64) (string, bool) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if scaled := float64(n) * factor; scaled == math.Trunc(scaled) && math.Abs(scaled) < 1<<63 {
+			return strconv.FormatInt(int64(scaled), 10), true
+		}
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value, false
+	}
+	return strconv.FormatFloat(f*factor, 'f', -1, 64), true
+}
+
+// transformResult 按转换规则改写保留参数的旧值，改写前的值记录在result.TransformedFrom中。
+// quoted为true时值为YAML/TOML/JSON中的原始文本，带引号的字符串先去掉引号再转换，转换后重新加上引号。
+// 设置了Jasypt时加密值先解密再转换，改写后重新加密，同时校验每个加密值都能用给定的密码解密。
+// 返回值是否被改写
+func (m *Merger) transformResult(result *KeyResult, quoted bool) bool {
+	encrypted := m.opts.Jasypt != nil && IsEncrypted(result.OldValue)
+	if len(m.transforms) == 0 && !encrypted {
+		return false
+	}
+	value, quote := result.OldValue, ""
+	if quoted {
+		value, quote = unquoteValue(value)
+	}
+	var transformed string
+	if encrypted {
+		plain, err := m.opts.Jasypt.Decrypt(value)
+		if err != nil {
+			m.keyWarnf(result.Key, result.Line, "", "无法解密参数值，原样保留: %s: %v", result.Key, err)
+			return false
+		}
+		if transformed = m.transformValue(result.Key, plain, result.Line, true); transformed == plain {
+			return false
+		}
+		if transformed, err = m.opts.Jasypt.Encrypt(transformed); err != nil {
+			m.keyWarnf(result.Key, result.Line, "", "重新加密参数值失败，原样保留: %s: %v", result.Key, err)
+			return false
+		}
+	} else if transformed = m.transformValue(result.Key, value, result.Line, false); transformed == value {
+		return false
+	}
+	switch quote {
+	case `"`:
+		transformed = jsonQuote(transformed)
+	case "'":
+		transformed = "'" + transformed + "'"
+	}
+	result.TransformedFrom, result.OldValue = result.OldValue, transformed
+	return true
+}
+
+// withLineValue 将配置行中等号后的值替换为value，保留键、等号两侧的写法与行尾注释
+func withLineValue(line, value string) string {
+	p, ok := splitProperty(line)
+	if !ok {
+		return line
+	}
+	p.value = value
+	return p.String()
+}
+
+// withInlineValue 将YAML/TOML单行键值中冒号或等号之后的值文本old替换为value，保留键的写法与行尾注释
+func withInlineValue(line, old, value string) string {
+	start := strings.IndexAny(line, ":=") + 1
+	i := strings.Index(line[start:], old)
+	if old == "" || i == -1 {
+		return line
+	}
+	i += start
+	return line[:i] + value + line[i+len(old):]
+}
@@ -0,0 +1,356 @@
+package propmerge
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IsXMLFile 根据扩展名判断是否为XML文件
+func IsXMLFile(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == ".xml"
+}
+
+// xmlNode 描述XML文件中的一个元素，位置均为在文件内容中的字节偏移
+type xmlNode struct {
+	name        string // 元素名，带命名空间前缀时为"前缀:名称"
+	parent      *xmlNode
+	children    []*xmlNode
+	attrs       []xmlAttr
+	start       int    // 开始标签的位置
+	inner       [2]int // 开始标签与结束标签之间的内容
+	selfClosing bool
+	text        string // 元素直接包含的文本(含CDATA)，已解码
+	line        int    // 开始标签所在行(从1开始)
+	innerLine   int    // 内容开始处所在行
+}
+
+// xmlAttr 描述元素的一个属性
+type xmlAttr struct {
+	name       string
+	value      string // 解码后的值
+	start, end int    // 值文本(不含引号)的位置
+	quote      byte
+	line       int
+}
+
+// xmlTarget 是可保留的一个值: 属性值，或不含子元素的元素的文本
+type xmlTarget struct {
+	key        string // 规范路径，如/Server/Service[@name='Catalina']/Connector[1]/@port
+	value      string // 解码后的值，元素文本去掉首尾空白
+	start, end int    // 值文本在文件中的位置，元素文本为整个内容
+	quote      byte   // 属性值使用的引号，元素文本为0
+	line       int
+}
+
+// parseXML 解析XML内容，返回以文档元素为唯一子元素的根节点
+func parseXML(text string) (*xmlNode, error) {
+	d := xml.NewDecoder(strings.NewReader(text))
+	// 内容已按文件编码读取为UTF-8，忽略XML声明中的encoding
+	d.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	root := &xmlNode{}
+	cur := root
+	line := 1
+	for {
+		start := int(d.InputOffset())
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		end := int(d.InputOffset())
+		raw := text[start:end]
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: xmlQName(t.Name), parent: cur, start: start, line: line}
+			n.attrs = scanXMLAttrs(raw, start, line, t.Attr)
+			n.inner = [2]int{end, end}
+			n.innerLine = line + strings.Count(raw, "\n")
+			n.selfClosing = strings.HasSuffix(raw, "/>")
+			cur.children = append(cur.children, n)
+			cur = n
+		case xml.EndElement:
+			if cur == root || xmlQName(t.Name) != cur.name {
+				return nil, fmt.Errorf(tr("第%d行: 结束标签</%s>与开始标签不匹配"), line, xmlQName(t.Name))
+			}
+			if !cur.selfClosing {
+				cur.inner[1] = start
+			}
+			cur = cur.parent
+		case xml.CharData:
+			if cur != root {
+				cur.text += string(t)
+			}
+		}
+		line += strings.Count(raw, "\n")
+	}
+	if cur != root {
+		return nil, fmt.Errorf(tr("元素<%s>未闭合"), cur.name)
+	}
+	if len(root.children) == 0 {
+		return nil, errors.New(tr("没有根元素"))
+	}
+	return root, nil
+}
+
+// xmlQName 返回文件中书写的元素名或属性名
+func xmlQName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+// scanXMLAttrs 在开始标签的原始文本中定位各属性值，decoded为解码器按同样顺序给出的属性
+func scanXMLAttrs(tag string, offset, line int, decoded []xml.Attr) []xmlAttr {
+	space := func(c byte) bool { return c == ' ' || c == '\t' || c == '\r' || c == '\n' }
+	i := 1
+	for i < len(tag) && !space(tag[i]) && tag[i] != '>' && tag[i] != '/' {
+		i++
+	}
+	var attrs []xmlAttr
+	for k := 0; k < len(decoded); k++ {
+		for i < len(tag) && space(tag[i]) {
+			i++
+		}
+		nameStart := i
+		for i < len(tag) && tag[i] != '=' && !space(tag[i]) {
+			i++
+		}
+		name := tag[nameStart:i]
+		for i < len(tag) && tag[i] != '"' && tag[i] != '\'' {
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		q := tag[i]
+		i++
+		valueStart := i
+		for i < len(tag) && tag[i] != q {
+			i++
+		}
+		attrs = append(attrs, xmlAttr{
+			name:  name,
+			value: decoded[k].Value,
+			start: offset + valueStart,
+			end:   offset + i,
+			quote: q,
+			line:  line + strings.Count(tag[:valueStart], "\n"),
+		})
+		i++
+	}
+	return attrs
+}
+
+// attr 按名称查找属性
+func (n *xmlNode) attr(name string) (xmlAttr, bool) {
+	for _, a := range n.attrs {
+		if matchName(name, a.name) {
+			return a, true
+		}
+	}
+	return xmlAttr{}, false
+}
+
+// descendantsOrSelf 按文档顺序返回元素自身及其全部后代
+func (n *xmlNode) descendantsOrSelf() []*xmlNode {
+	nodes := []*xmlNode{n}
+	for _, c := range n.children {
+		nodes = append(nodes, c.descendantsOrSelf()...)
+	}
+	return nodes
+}
+
+// path 返回元素的规范路径: 带id或name属性的元素以该属性区分，否则同名的兄弟元素以序号区分，
+// 使旧文件与新文件中的同一元素得到相同的路径
+func (n *xmlNode) path() string {
+	var steps []string
+	for c := n; c.parent != nil; c = c.parent {
+		steps = append(steps, c.step())
+	}
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return "/" + strings.Join(steps, "/")
+}
+
+// step 返回元素在规范路径中的一步
+func (n *xmlNode) step() string {
+	for _, key := range []string{"id", "name"} {
+		if a, ok := n.attr(key); ok {
+			q := "'"
+			if strings.Contains(a.value, "'") {
+				q = `"`
+			}
+			return n.name + "[@" + key + "=" + q + a.value + q + "]"
+		}
+	}
+	same, pos := 0, 0
+	for _, s := range n.parent.children {
+		if s.name == n.name {
+			same++
+			if s == n {
+				pos = same
+			}
+		}
+	}
+	if same > 1 {
+		return fmt.Sprintf("%s[%d]", n.name, pos)
+	}
+	return n.name
+}
+
+// attrTarget 返回属性值对应的可保留值
+func (n *xmlNode) attrTarget(a xmlAttr) xmlTarget {
+	return xmlTarget{key: n.path() + "/@" + a.name, value: a.value, start: a.start, end: a.end, quote: a.quote, line: a.line}
+}
+
+// textTarget 返回元素文本对应的可保留值，含子元素或自闭合的元素没有可替换的文本
+func (n *xmlNode) textTarget() (xmlTarget, bool) {
+	if len(n.children) > 0 || n.selfClosing {
+		return xmlTarget{}, false
+	}
+	return xmlTarget{key: n.path(), value: strings.TrimSpace(n.text), start: n.inner[0], end: n.inner[1], line: n.innerLine}, true
+}
+
+// allTargets 按文档顺序返回全部属性值与元素文本
+func (n *xmlNode) allTargets() []xmlTarget {
+	var found []xmlTarget
+	for _, e := range n.descendantsOrSelf() {
+		if e.parent == nil {
+			continue
+		}
+		for _, a := range e.attrs {
+			found = append(found, e.attrTarget(a))
+		}
+		if t, ok := e.textTarget(); ok {
+			found = append(found, t)
+		}
+	}
+	return found
+}
+
+// escapeXML 将值转义后写作属性值(quote为所用的引号)或元素文本(quote为0)
+func escapeXML(value string, quote byte) string {
+	pairs := []string{"&", "&amp;", "<", "&lt;"}
+	switch quote {
+	case '"':
+		pairs = append(pairs, `"`, "&quot;")
+	case '\'':
+		pairs = append(pairs, "'", "&apos;")
+	default:
+		pairs = append(pairs, ">", "&gt;")
+	}
+	return strings.NewReplacer(pairs...).Replace(value)
+}
+
+// XMLPreserved 返回XML内容中命中XMLPaths的值: 规范路径到选中它的XPath表达式的映射
+func (m *Merger) XMLPreserved(lines []string) (map[string]string, error) {
+	root, err := parseXML(strings.Join(lines, "\n"))
+	if err != nil {
+		return nil, err
+	}
+	preserved := make(map[string]string)
+	for _, x := range m.xpaths {
+		for _, t := range x.targets(root) {
+			if _, ok := preserved[t.key]; !ok {
+				preserved[t.key] = x.text
+			}
+		}
+	}
+	return preserved, nil
+}
+
+// MergeXML 按XMLPaths中的XPath表达式从旧XML文件中选出属性值与元素文本，写入新文件中路径相同的位置
+func (m *Merger) MergeXML(old io.Reader, new io.Reader) (Result, error) {
+	oldLines, err := ReadLines(old)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+	newLines, err := ReadLines(new)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取新文件失败: %w"), err)
+	}
+	return m.MergeXMLLines(oldLines, newLines)
+}
+
+// MergeXMLLines 与MergeXML相同，但直接处理已读取的行。只替换值文本本身，新文件的缩进、属性顺序、
+// 注释与其余内容保持不变；旧文件中的原始值文本(含实体与CDATA)原样移植。新文件中不存在的节点不会插入，
+// 记为跳过并输出警告
+func (m *Merger) MergeXMLLines(oldLines, newLines []string) (Result, error) {
+	if len(m.xpaths) == 0 {
+		m.warnf("未定义xmlPaths，XML文件中没有需要保留的参数")
+	}
+	oldText, newText := strings.Join(oldLines, "\n"), strings.Join(newLines, "\n")
+	oldRoot, err := parseXML(oldText)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("解析旧文件失败: %w"), err)
+	}
+	newRoot, err := parseXML(newText)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("解析新文件失败: %w"), err)
+	}
+	index := make(map[string]xmlTarget)
+	for _, t := range newRoot.allTargets() {
+		if _, ok := index[t.key]; !ok {
+			index[t.key] = t
+		}
+	}
+
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	var results []KeyResult
+	seen := make(map[string]bool)
+	for _, x := range m.xpaths {
+		for _, o := range x.targets(oldRoot) {
+			if seen[o.key] {
+				continue
+			}
+			seen[o.key] = true
+			m.keyDebugf(o.key, o.line, "", "找到匹配参数[行%d]: %s", o.line, o.key)
+			result := KeyResult{Key: o.key, OldValue: o.value}
+			n, ok := index[o.key]
+			if !ok {
+				result.Action = ActionSkip
+				m.keyWarnf(o.key, o.line, ActionSkip, "新文件中不存在该节点，XML文件不插入新节点: %s", o.key)
+				results = append(results, result)
+				continue
+			}
+			result.Action, result.Line, result.NewValue = ActionReplace, n.line, n.value
+
+			raw := oldText[o.start:o.end]
+			if m.transformResult(&result, false) {
+				raw = escapeXML(result.OldValue, n.quote)
+			} else if o.quote != n.quote {
+				raw = escapeXML(o.value, n.quote)
+			}
+			if !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			edits = append(edits, edit{n.start, n.end, raw})
+			m.keyDebugf(o.key, n.line, ActionReplace, "替换参数[行%d]: %s", n.line, o.key)
+			m.trace(TraceEvent{Event: "action", Line: n.line, Key: o.key, Text: o.key + "=" + result.OldValue, Result: ActionReplace})
+			results = append(results, result)
+		}
+	}
+
+	// 从后向前替换，前面的位置不受影响
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		newText = newText[:e.start] + e.text + newText[e.end:]
+	}
+	merged := Result{Lines: strings.Split(newText, "\n"), Keys: results}
+	return merged, m.checkCollisions(merged)
+}
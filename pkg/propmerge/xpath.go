@@ -0,0 +1,246 @@
+package propmerge
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// xpathExpr 是编译后的XPath表达式，支持的子集为:
+// 以/或//连接的元素名(或*)步骤，步骤可带[@属性='值']、[@属性]与[序号]谓词，
+// 末尾为/@属性时选择属性值，为/text()或元素时选择元素的文本。不以/开头的表达式按//处理
+type xpathExpr struct {
+	text  string
+	steps []xpathStep
+	attr  string // 末尾@属性的属性名，为空时选择元素文本
+}
+
+// xpathStep 是路径中的一个元素步骤
+type xpathStep struct {
+	descendant bool // 由//引入，匹配任意深度的后代
+	name       string
+	preds      []xpathPred
+}
+
+// xpathPred 是步骤上的一个谓词: index非0时按序号(从1开始)选择，否则按属性过滤
+type xpathPred struct {
+	attr     string
+	value    string
+	hasValue bool
+	index    int
+}
+
+// compileXPath 解析XPath表达式
+func compileXPath(text string) (*xpathExpr, error) {
+	expr := &xpathExpr{text: text}
+	s := strings.TrimSpace(text)
+	if s == "" {
+		return nil, errors.New(tr("XPath表达式为空"))
+	}
+	if !strings.HasPrefix(s, "/") {
+		s = "//" + s
+	}
+	for s != "" {
+		step := xpathStep{}
+		switch {
+		case strings.HasPrefix(s, "//"):
+			step.descendant, s = true, s[2:]
+		case strings.HasPrefix(s, "/"):
+			s = s[1:]
+		default:
+			return nil, fmt.Errorf(tr("XPath表达式 %s 无效: 步骤之间应以/分隔"), text)
+		}
+
+		end := strings.IndexAny(s, "/[")
+		if end < 0 {
+			end = len(s)
+		}
+		name := strings.TrimSpace(s[:end])
+		s = s[end:]
+		switch {
+		case strings.HasPrefix(name, "@"):
+			if s != "" || step.descendant || len(name) == 1 {
+				return nil, fmt.Errorf(tr("XPath表达式 %s 无效: @属性只能作为最后一步"), text)
+			}
+			expr.attr = name[1:]
+			return expr.finish()
+		case name == "text()":
+			if s != "" || step.descendant {
+				return nil, fmt.Errorf(tr("XPath表达式 %s 无效: text()只能作为最后一步"), text)
+			}
+			return expr.finish()
+		case name == "" || !validXMLName(name):
+			return nil, fmt.Errorf(tr("XPath表达式 %s 无效: 无效的元素名%q"), text, name)
+		}
+		step.name = name
+
+		for strings.HasPrefix(s, "[") {
+			close := predicateEnd(s)
+			if close < 0 {
+				return nil, fmt.Errorf(tr("XPath表达式 %s 无效: 谓词缺少]"), text)
+			}
+			pred, err := parseXPathPred(strings.TrimSpace(s[1:close]))
+			if err != nil {
+				return nil, fmt.Errorf(tr("XPath表达式 %s 无效: %w"), text, err)
+			}
+			step.preds = append(step.preds, pred)
+			s = s[close+1:]
+		}
+		expr.steps = append(expr.steps, step)
+	}
+	return expr.finish()
+}
+
+// finish 检查表达式至少包含一个元素步骤
+func (e *xpathExpr) finish() (*xpathExpr, error) {
+	if len(e.steps) == 0 {
+		return nil, fmt.Errorf(tr("XPath表达式 %s 无效: 缺少元素步骤"), e.text)
+	}
+	return e, nil
+}
+
+// predicateEnd 返回以[开始的谓词对应的]的位置，跳过引号中的内容
+func predicateEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseXPathPred 解析谓词内容: 序号、@属性或@属性='值'
+func parseXPathPred(s string) (xpathPred, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 {
+			return xpathPred{}, fmt.Errorf(tr("序号必须从1开始: %s"), s)
+		}
+		return xpathPred{index: n}, nil
+	}
+	if !strings.HasPrefix(s, "@") {
+		return xpathPred{}, fmt.Errorf(tr("不支持的谓词: [%s]"), s)
+	}
+	name, value, hasValue := strings.Cut(s[1:], "=")
+	name = strings.TrimSpace(name)
+	if !validXMLName(name) {
+		return xpathPred{}, fmt.Errorf(tr("不支持的谓词: [%s]"), s)
+	}
+	pred := xpathPred{attr: name, hasValue: hasValue}
+	if hasValue {
+		value = strings.TrimSpace(value)
+		if len(value) < 2 || (value[0] != '\'' && value[0] != '"') || value[len(value)-1] != value[0] {
+			return xpathPred{}, fmt.Errorf(tr("谓词中的值需要加引号: [%s]"), s)
+		}
+		pred.value = value[1 : len(value)-1]
+	}
+	return pred, nil
+}
+
+// validXMLName 判断是否为XPath中可用的元素名或属性名(可带命名空间前缀)，*匹配任意元素
+func validXMLName(name string) bool {
+	if name == "*" {
+		return true
+	}
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if !(r == '_' || r == ':' || r == '-' || r == '.' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 0x7f || i > 0 && r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// matchName 判断元素名或属性名是否匹配: 表达式中不带前缀时忽略文件中的命名空间前缀
+func matchName(pattern, name string) bool {
+	if pattern == "*" || pattern == name {
+		return true
+	}
+	if !strings.Contains(pattern, ":") {
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			return name[i+1:] == pattern
+		}
+	}
+	return false
+}
+
+// selectNodes 在文档中按表达式选择元素，按文档顺序返回
+func (e *xpathExpr) selectNodes(root *xmlNode) []*xmlNode {
+	context := []*xmlNode{root}
+	for _, step := range e.steps {
+		seen := make(map[*xmlNode]bool)
+		var next []*xmlNode
+		for _, c := range context {
+			parents := []*xmlNode{c}
+			if step.descendant {
+				parents = c.descendantsOrSelf()
+			}
+			for _, p := range parents {
+				for _, n := range step.filter(p.children) {
+					if !seen[n] {
+						seen[n] = true
+						next = append(next, n)
+					}
+				}
+			}
+		}
+		sort.Slice(next, func(i, j int) bool { return next[i].start < next[j].start })
+		context = next
+	}
+	return context
+}
+
+// filter 从同一父元素的子元素中选出匹配步骤的元素，序号谓词按依次过滤后的位置计算
+func (s xpathStep) filter(children []*xmlNode) []*xmlNode {
+	var matched []*xmlNode
+	for _, c := range children {
+		if matchName(s.name, c.name) {
+			matched = append(matched, c)
+		}
+	}
+	for _, p := range s.preds {
+		if p.index > 0 {
+			if p.index > len(matched) {
+				return nil
+			}
+			matched = matched[p.index-1 : p.index]
+			continue
+		}
+		var kept []*xmlNode
+		for _, n := range matched {
+			if a, ok := n.attr(p.attr); ok && (!p.hasValue || a.value == p.value) {
+				kept = append(kept, n)
+			}
+		}
+		matched = kept
+	}
+	return matched
+}
+
+// targets 返回表达式选中的值: 属性值，或不含子元素的元素的文本
+func (e *xpathExpr) targets(root *xmlNode) []xmlTarget {
+	var found []xmlTarget
+	for _, n := range e.selectNodes(root) {
+		if e.attr != "" {
+			for _, a := range n.attrs {
+				if matchName(e.attr, a.name) {
+					found = append(found, n.attrTarget(a))
+				}
+			}
+		} else if t, ok := n.textTarget(); ok {
+			found = append(found, t)
+		}
+	}
+	return found
+}
@@ -0,0 +1,441 @@
+package propmerge
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// IsYAMLFile 根据扩展名判断是否为YAML文件
+func IsYAMLFile(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".yml" || ext == ".yaml"
+}
+
+// yamlNode 描述YAML文件中的一个映射键，path为从根开始以点连接的键路径
+type yamlNode struct {
+	path   string
+	key    string
+	indent int
+	start  int    // 键所在行(从0开始)
+	end    int    // 键及其子内容之后的第一行
+	leaf   bool   // 是否为叶子(标量、列表或块标量)，否则为嵌套映射
+	value  string // 行内值，不含行尾注释
+}
+
+// indentOf 返回行首空格数
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// isYAMLBlankOrComment 判断是否为空行或注释行
+func isYAMLBlankOrComment(line string) bool {
+	t := strings.TrimSpace(line)
+	return t == "" || strings.HasPrefix(t, "#")
+}
+
+// isYAMLSeqItem 判断是否为列表项
+func isYAMLSeqItem(trimmed string) bool {
+	return trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+}
+
+// splitYAMLKey 将"key: value"形式的内容拆分为键和冒号之后的部分
+func splitYAMLKey(trimmed string) (key, rest string, ok bool) {
+	if trimmed == "" || strings.ContainsAny(trimmed[:1], "#-{[&*!|>%@`") || trimmed == "---" || trimmed == "..." {
+		return "", "", false
+	}
+
+	if q := trimmed[0]; q == '"' || q == '\'' {
+		end := strings.IndexByte(trimmed[1:], q)
+		if end == -1 || !strings.HasPrefix(trimmed[end+2:], ":") {
+			return "", "", false
+		}
+		return trimmed[1 : end+1], trimmed[end+3:], true
+	}
+
+	for i := 0; i < len(trimmed); i++ {
+		if trimmed[i] == ':' && (i == len(trimmed)-1 || trimmed[i+1] == ' ') {
+			return strings.TrimSpace(trimmed[:i]), trimmed[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// yamlValue 去掉行内值两侧空白及行尾注释
+func yamlValue(rest string) string {
+	rest = strings.TrimSpace(rest)
+	if strings.HasPrefix(rest, "#") {
+		return ""
+	}
+	if i := strings.Index(rest, " #"); i != -1 && !strings.HasPrefix(rest, `"`) && !strings.HasPrefix(rest, "'") {
+		rest = strings.TrimSpace(rest[:i])
+	}
+	return rest
+}
+
+// parseYAML 解析YAML块映射结构，返回所有键节点。列表与块标量作为其所属键的值整体处理，
+// 不支持流式映射和锚点引用
+func parseYAML(lines []string) []yamlNode {
+	type frame struct {
+		indent int
+		path   string
+	}
+	var stack []frame
+	var nodes []yamlNode
+
+	for i := 0; i < len(lines); i++ {
+		if isYAMLBlankOrComment(lines[i]) {
+			continue
+		}
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "---" {
+			stack = stack[:0]
+			continue
+		}
+		key, rest, ok := splitYAMLKey(trimmed)
+		if !ok {
+			continue
+		}
+
+		indent := indentOf(lines[i])
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		path := key
+		if len(stack) > 0 {
+			path = stack[len(stack)-1].path + "." + key
+		}
+		value := yamlValue(rest)
+
+		// 计算该键覆盖的行范围，允许与键同级缩进的列表项
+		end := i + 1
+		for end < len(lines) {
+			if isYAMLBlankOrComment(lines[end]) {
+				end++
+				continue
+			}
+			lineIndent := indentOf(lines[end])
+			if lineIndent > indent || (lineIndent == indent && value == "" && isYAMLSeqItem(strings.TrimSpace(lines[end]))) {
+				end++
+				continue
+			}
+			break
+		}
+		for end > i+1 && isYAMLBlankOrComment(lines[end-1]) {
+			end--
+		}
+
+		node := yamlNode{path: path, key: key, indent: indent, start: i, end: end, value: value, leaf: true}
+		if value == "" {
+			for j := i + 1; j < end; j++ {
+				if isYAMLBlankOrComment(lines[j]) {
+					continue
+				}
+				t := strings.TrimSpace(lines[j])
+				if _, _, isKey := splitYAMLKey(t); isKey && !isYAMLSeqItem(t) {
+					node.leaf = false
+				}
+				break
+			}
+		}
+		nodes = append(nodes, node)
+
+		if node.leaf {
+			i = end - 1
+		} else {
+			stack = append(stack, frame{indent: indent, path: path})
+		}
+	}
+	return nodes
+}
+
+// findYAMLNode 按路径查找第一个匹配的节点
+func findYAMLNode(nodes []yamlNode, path string) (yamlNode, bool) {
+	for _, n := range nodes {
+		if n.path == path {
+			return n, true
+		}
+	}
+	return yamlNode{}, false
+}
+
+// yamlIndentUnit 推断文件使用的缩进宽度，默认2个空格
+func yamlIndentUnit(nodes []yamlNode) int {
+	unit := 0
+	for _, n := range nodes {
+		if n.indent > 0 && (unit == 0 || n.indent < unit) {
+			unit = n.indent
+		}
+	}
+	if unit == 0 {
+		return 2
+	}
+	return unit
+}
+
+// reindent 将一组行整体增加或减少缩进
+func reindent(lines []string, delta int) []string {
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		switch {
+		case delta > 0 && strings.TrimSpace(line) != "":
+			line = strings.Repeat(" ", delta) + line
+		case delta < 0:
+			remove := -delta
+			if n := indentOf(line); n < remove {
+				remove = n
+			}
+			line = line[remove:]
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// MergeYAML 从旧YAML中提取命中保留规则的叶子键，按点分路径写入新YAML的对应层级。
+// 新文件中已存在的键整体替换(按新文件的缩进)，缺失的键插入到最深的已有父节点下，
+// 必要时补齐中间层级；新文件其余内容、注释和缩进保持不变
+func (m *Merger) MergeYAML(old io.Reader, new io.Reader) (Result, error) {
+	oldLines, err := ReadLines(old)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+	newLines, err := ReadLines(new)
+	if err != nil {
+		return Result{}, fmt.Errorf(tr("读取新文件失败: %w"), err)
+	}
+	return m.MergeYAMLLines(oldLines, newLines)
+}
+
+// MergeYAMLLines 与MergeYAML相同，但直接处理已读取的行。
+// 多文档YAML(以---分隔，如按spring.config.activate.on-profile区分的各profile)按文档分别合并:
+// 旧文件与新文件的文档按profile对应，同一profile(或都没有profile)的多个文档按先后顺序对应；
+// 新文件中没有对应文档的旧文档中的保留参数不写入其他文档，记为跳过并输出警告
+func (m *Merger) MergeYAMLLines(oldLines, newLines []string) (Result, error) {
+	oldDocs, newDocs := splitYAMLDocuments(oldLines), splitYAMLDocuments(newLines)
+	pair := make(map[string]yamlDocument, len(oldDocs))
+	for _, d := range oldDocs {
+		pair[d.id] = d
+	}
+
+	var lines []string
+	var results []KeyResult
+	for _, d := range newDocs {
+		if d.sep >= 0 {
+			lines = append(lines, newLines[d.sep])
+		}
+		doc := newLines[d.start:d.end]
+		if o, ok := pair[d.id]; ok && d.id != "" {
+			delete(pair, d.id)
+			var keys []KeyResult
+			doc, keys = m.mergeYAMLDocument(oldLines[o.start:o.end], doc)
+			for _, k := range keys {
+				if k.Line > 0 {
+					k.Line += len(lines)
+				}
+				results = append(results, k)
+			}
+		}
+		lines = append(lines, doc...)
+	}
+
+	// 新文件中没有对应文档的旧文档
+	for _, o := range oldDocs {
+		if _, ok := pair[o.id]; !ok || o.id == "" {
+			continue
+		}
+		kept, _ := m.keptYAMLNodes(oldLines[o.start:o.end])
+		for _, n := range kept {
+			m.keyWarnf(n.path, o.start+n.start+1, ActionSkip, "旧文件中 %s 所在的文档(%s)在新文件中没有对应的文档，已跳过", n.path, o.describe())
+			results = append(results, KeyResult{Key: n.path, OldValue: n.value, Action: ActionSkip})
+		}
+	}
+	merged := Result{Lines: lines, Keys: results}
+	return merged, m.checkCollisions(merged)
+}
+
+// keptYAMLNodes 返回一个文档中命中保留规则的叶子键及其路径集合
+func (m *Merger) keptYAMLNodes(lines []string) ([]yamlNode, map[string]bool) {
+	var kept []yamlNode
+	paths := make(map[string]bool)
+	for _, o := range parseYAML(lines) {
+		if o.leaf && m.Matches(o.path+"="+o.value) {
+			kept = append(kept, o)
+			paths[o.path] = true
+		}
+	}
+	return kept, paths
+}
+
+// yamlProfileKeys 为标识文档所属profile的键，依次为Spring Boot 2.4+与旧版本的写法
+var yamlProfileKeys = []string{"spring.config.activate.on-profile", "spring.profiles"}
+
+// yamlDocument 为多文档YAML中的一个文档，内容为lines[start:end]，sep为其前面的---分隔行(第一个文档为-1)。
+// id由profile与同一profile中的序号组成，用于对应旧文件与新文件中的文档；没有任何键的文档id为空，不参与对应
+type yamlDocument struct {
+	start, end int
+	sep        int
+	profile    string
+	index      int
+	id         string
+}
+
+// describe 返回文档的说明，用于日志
+func (d yamlDocument) describe() string {
+	if d.profile != "" {
+		return fmt.Sprintf("profile %s #%d", d.profile, d.index+1)
+	}
+	return fmt.Sprintf(tr("无profile #%d"), d.index+1)
+}
+
+// splitYAMLDocuments 按行首的---将YAML拆分为文档
+func splitYAMLDocuments(lines []string) []yamlDocument {
+	var docs []yamlDocument
+	start, sep := 0, -1
+	flush := func(end int) {
+		docs = append(docs, yamlDocument{start: start, end: end, sep: sep})
+	}
+	for i, line := range lines {
+		if line == "---" || strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "---\t") {
+			flush(i)
+			start, sep = i+1, i
+		}
+	}
+	flush(len(lines))
+
+	seen := make(map[string]int)
+	for i := range docs {
+		d := &docs[i]
+		nodes := parseYAML(lines[d.start:d.end])
+		if len(nodes) == 0 {
+			continue
+		}
+		for _, key := range yamlProfileKeys {
+			if n, ok := findYAMLNode(nodes, key); ok && n.leaf {
+				d.profile = unquoteScalar(n.value)
+				break
+			}
+		}
+		d.index = seen[d.profile]
+		seen[d.profile]++
+		d.id = fmt.Sprintf("%s#%d", d.profile, d.index)
+	}
+	return docs
+}
+
+// mergeYAMLDocument 将旧文件中一个文档的保留参数合并到新文件中对应的文档，返回合并后的文档内容
+func (m *Merger) mergeYAMLDocument(oldLines, newLines []string) ([]string, []KeyResult) {
+	lines := append([]string(nil), newLines...)
+	kept, oldPaths := m.keptYAMLNodes(oldLines)
+
+	var results []KeyResult
+	for _, o := range kept {
+		m.keyDebugf(o.path, o.start+1, "", "找到匹配参数[行%d]: %s", o.start+1, o.path)
+
+		block := oldLines[o.start:o.end]
+		nodes := parseYAML(lines)
+		result := KeyResult{Key: o.path, OldValue: o.value}
+		if !m.renamePath(&result, oldPaths) {
+			results = append(results, result)
+			continue
+		}
+		o.path = result.Key
+		if len(block) == 1 && m.transformResult(&result, true) {
+			block = []string{withInlineValue(block[0], result.TransformedFrom, result.OldValue)}
+		}
+
+		if n, ok := findYAMLNode(nodes, o.path); ok {
+			if !n.leaf {
+				m.keyWarnf(o.path, 0, ActionSkip, "新文件中 %s 是嵌套映射，无法写入旧文件中的值，已跳过", o.path)
+				result.Action = ActionSkip
+				results = append(results, result)
+				continue
+			}
+			result.Action = ActionReplace
+			result.Line = n.start + 1
+			result.NewValue = n.value
+			if !m.renameCollision(&result) {
+				results = append(results, result)
+				continue
+			}
+			if prev := result.OldValue; len(block) == 1 && m.mergeListResult(&result, true) {
+				block = []string{withInlineValue(block[0], prev, result.OldValue)}
+			}
+			if !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			replacement := reindent(block, n.indent-o.indent)
+			replacement[0] = strings.Repeat(" ", n.indent) + yamlKeyText(lines[n.start]) + yamlRestText(block[0])
+			lines = append(lines[:n.start], append(replacement, lines[n.end:]...)...)
+			m.keyDebugf(o.path, n.start+1, ActionReplace, "替换参数[行%d]: %s", n.start+1, o.path)
+		} else {
+			var inserted []string
+			inserted, result.Line, result.Action = insertYAMLPath(append([]string(nil), lines...), nodes, o, block)
+			if !m.confirm(&result) {
+				results = append(results, result)
+				continue
+			}
+			lines = inserted
+			m.keyDebugf(o.path, result.Line, ActionInsert, "插入参数[行%d]: %s", result.Line, o.path)
+		}
+		m.trace(TraceEvent{Event: "action", Line: result.Line, Key: o.path, Text: strings.TrimSpace(block[0]), Result: result.Action})
+		results = append(results, result)
+	}
+	return lines, results
+}
+
+// yamlKeyText 返回键行中冒号之前的原始键文本(含引号)
+func yamlKeyText(line string) string {
+	trimmed := strings.TrimSpace(line)
+	key, rest, _ := splitYAMLKey(trimmed)
+	if key == "" {
+		return trimmed
+	}
+	return trimmed[:len(trimmed)-len(rest)-1]
+}
+
+// yamlRestText 返回键行中冒号及其之后的原始内容
+func yamlRestText(line string) string {
+	_, rest, _ := splitYAMLKey(strings.TrimSpace(line))
+	return ":" + rest
+}
+
+// insertYAMLPath 将新文件中不存在的键插入到最深的已有父映射下，补齐缺失的中间层级；
+// 没有任何已有父节点时追加到文件末尾
+func insertYAMLPath(lines []string, nodes []yamlNode, o yamlNode, block []string) ([]string, int, string) {
+	segments := strings.Split(o.path, ".")
+	unit := yamlIndentUnit(nodes)
+
+	at, indent, depth, action := len(lines), 0, 0, ActionAppend
+	for j := len(segments) - 1; j > 0; j-- {
+		parent, ok := findYAMLNode(nodes, strings.Join(segments[:j], "."))
+		if !ok || parent.leaf {
+			continue
+		}
+		at, indent, depth, action = parent.end, parent.indent+unit, j, ActionInsert
+		for _, n := range nodes {
+			if n.start > parent.start && n.start < parent.end {
+				indent = n.indent
+				break
+			}
+		}
+		break
+	}
+
+	var inserted []string
+	for _, seg := range segments[depth : len(segments)-1] {
+		inserted = append(inserted, strings.Repeat(" ", indent)+seg+":")
+		indent += unit
+	}
+	leaf := reindent(block, indent-o.indent)
+	leaf[0] = strings.Repeat(" ", indent) + segments[len(segments)-1] + yamlRestText(block[0])
+	inserted = append(inserted, leaf...)
+
+	result := make([]string, 0, len(lines)+len(inserted))
+	result = append(result, lines[:at]...)
+	result = append(result, inserted...)
+	result = append(result, lines[at:]...)
+	return result, at + 1, action
+}
@@ -0,0 +1,109 @@
+package propmerge
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestMergeYAMLMultiDocument 多文档YAML按profile对应文档合并，prod文档的值不会写入默认文档
+func TestMergeYAMLMultiDocument(t *testing.T) {
+	old := []string{
+		"spring:",
+		"  datasource:",
+		"    url: jdbc:old",
+		"---",
+		"spring:",
+		"  config:",
+		"    activate:",
+		"      on-profile: dev",
+		"  datasource:",
+		"    url: jdbc:dev-old",
+		"---",
+		"spring:",
+		"  config:",
+		"    activate:",
+		"      on-profile: prod",
+		"  datasource:",
+		"    url: jdbc:prod-old",
+	}
+	// 新文件中prod排在dev之前，且去掉了dev文档
+	template := []string{
+		"# default",
+		"spring:",
+		"  datasource:",
+		"    url: jdbc:new",
+		"    pool: 10",
+		"---",
+		"spring:",
+		"  config:",
+		"    activate:",
+		"      on-profile: \"prod\"",
+		"  datasource:",
+		"    url: jdbc:prod-new",
+	}
+	m, err := New(Options{Pattern: `^spring\.datasource\.url=`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := m.MergeYAMLLines(old, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"# default",
+		"spring:",
+		"  datasource:",
+		"    url: jdbc:old",
+		"    pool: 10",
+		"---",
+		"spring:",
+		"  config:",
+		"    activate:",
+		"      on-profile: \"prod\"",
+		"  datasource:",
+		"    url: jdbc:prod-old",
+	}
+	if strings.Join(result.Lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("merged:\n%s\nwant:\n%s", strings.Join(result.Lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	lines := map[string]int{}
+	var skipped []string
+	for _, k := range result.Keys {
+		switch k.Action {
+		case ActionReplace:
+			lines[k.OldValue] = k.Line
+		case ActionSkip:
+			skipped = append(skipped, k.OldValue)
+		}
+	}
+	if lines["jdbc:old"] != 4 || lines["jdbc:prod-old"] != 12 {
+		t.Errorf("replaced lines = %v, want jdbc:old at 4 and jdbc:prod-old at 12", lines)
+	}
+	// dev文档在新文件中不存在，其保留参数记为跳过而不是写入其他文档
+	if len(skipped) != 1 || skipped[0] != "jdbc:dev-old" {
+		t.Errorf("skipped = %v, want [jdbc:dev-old]", skipped)
+	}
+}
+
+func TestSplitYAMLDocuments(t *testing.T) {
+	lines := []string{
+		"# leading comment only",
+		"---",
+		"a: 1",
+		"--- # second",
+		"spring.profiles: prod",
+		"b: 2",
+		"---",
+		"c: 3",
+	}
+	docs := splitYAMLDocuments(lines)
+	var ids []string
+	for _, d := range docs {
+		ids = append(ids, d.id)
+	}
+	// 只有注释的文档不参与对应，没有profile的文档按顺序编号
+	if got, want := strings.Join(ids, ","), ",#0,prod#0,#1"; got != want {
+		t.Errorf("document ids = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// profileFilePattern 匹配Spring Boot约定的配置文件名，第一个子匹配为profile名称(基础文件为空)
+var profileFilePattern = regexp.MustCompile(`^application(?:-([^./]+))?\.(properties|ya?ml|toml)$`)
+
+// runProfiles 按application-{profile}.properties约定，逐对合并旧目录与新目录中的基础文件和各profile文件。
+// 基础文件使用当前环境(-env或APP_ENV)的保留规则，profile文件使用env与profile同名的envRules，
+// 例如只在prod的profile文件中保留数据源配置
+func runProfiles(oldDir, newDir string) error {
+	for _, dir := range []string{oldDir, newDir} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf(tr("读取目录失败: %w"), err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf(tr("%s 不是目录"), dir)
+		}
+	}
+
+	oldFiles, err := profileFiles(oldDir)
+	if err != nil {
+		return err
+	}
+	newFiles, err := profileFiles(newDir)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(oldFiles)+len(newFiles))
+	for name := range newFiles {
+		names = append(names, name)
+	}
+	for name := range oldFiles {
+		if _, ok := newFiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	baseEnv := activeEnv
+	defer func() { activeEnv = baseEnv }()
+
+	var results []batchResult
+	for _, name := range names {
+		profile, inNew := newFiles[name]
+		if _, inOld := oldFiles[name]; !inOld {
+			results = append(results, batchResult{rel: name, status: "跳过(旧目录中不存在)"})
+			continue
+		}
+		if !inNew {
+			results = append(results, batchResult{rel: name, status: "跳过(新目录中不存在)"})
+			continue
+		}
+
+		activeEnv = baseEnv
+		if profile != "" {
+			activeEnv = profile
+		}
+		if activeEnv != "" {
+			debugf(tr("处理文件: %s (环境: %s)"), name, activeEnv, slog.String("file", name))
+		} else {
+			debugf(tr("处理文件: %s"), name, slog.String("file", name))
+		}
+		results = append(results, mergePair(name, filepath.Join(oldDir, name), filepath.Join(newDir, name)))
+	}
+
+	return printBatchSummary(results)
+}
+
+// profileFiles 返回目录中符合application-{profile}约定的文件名及其profile名称
+func profileFiles(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf(tr("扫描目录 %s 失败: %w"), dir, err)
+	}
+
+	files := make(map[string]string)
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		if m := profileFilePattern.FindStringSubmatch(e.Name()); m != nil {
+			files[e.Name()] = m[1]
+		}
+	}
+	return files, nil
+}
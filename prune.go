@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retention 描述备份目录的保留策略，字段为零值时不按该项清理
+type retention struct {
+	keep   int           // 每个文件保留最近几次运行的备份
+	maxAge time.Duration // 备份的最长保留时间
+}
+
+// parseAge 解析保留时间，在time.ParseDuration的基础上支持以d为单位的天数，如30d
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf(tr("无效的保留时间: %s"), s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf(tr("无效的保留时间: %s"), s)
+	}
+	return d, nil
+}
+
+// parseBackupName 按命名模板从备份文件名中解析原文件名、备份类型与时间戳，不是备份文件时ok为false
+func parseBackupName(name string) (base, kind, ts string, ok bool) {
+	re := backupNamePattern()
+	m := re.FindStringSubmatch(trimCipherSuffix(name))
+	if m == nil {
+		return "", "", "", false
+	}
+	base, kind, ts = m[re.SubexpIndex("name")], "bak", m[re.SubexpIndex("ts")]
+	if !isBackupTimestamp(ts) {
+		return "", "", "", false
+	}
+	for _, k := range []string{"new", "repair", "rollback", "undo"} {
+		if b, found := strings.CutSuffix(base, "."+k); found && b != "" {
+			return b, k, ts, true
+		}
+	}
+	return base, kind, ts, true
+}
+
+// groupBackups 扫描备份目录(含批量模式按相对路径创建的子目录)，按所在目录与原文件名分组，
+// 各组内按时间戳从新到旧排序；备份目录不存在时返回空
+func groupBackups(dir string) (map[string][]backupEntry, error) {
+	groups := make(map[string][]backupEntry)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && p == gitBackupDir() {
+			// Git备份仓库中的文件不是带时间戳的备份
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		base, kind, ts, ok := parseBackupName(d.Name())
+		if ok {
+			key := filepath.Join(filepath.Dir(p), base)
+			groups[key] = append(groups[key], backupEntry{path: p, kind: kind, ts: ts})
+		}
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf(tr("扫描备份目录失败: %w"), err)
+	}
+	for _, backups := range groups {
+		sort.Slice(backups, func(i, j int) bool { return backups[i].ts > backups[j].ts })
+	}
+	return groups, nil
+}
+
+// expiredBackups 返回备份目录中超出保留策略的备份文件。
+// 同一文件同一次运行的各类备份时间戳相同，按运行次数而非文件数计算保留数量
+func expiredBackups(dir string, r retention, now time.Time) ([]backupEntry, error) {
+	groups, err := groupBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []backupEntry
+	for _, backups := range groups {
+		runs := 0
+		for i, b := range backups {
+			if i == 0 || b.ts != backups[i-1].ts {
+				runs++
+			}
+			t, _ := time.ParseInLocation("20060102150405", b.ts, time.Local)
+			if (r.keep > 0 && runs > r.keep) || (r.maxAge > 0 && now.Sub(t) > r.maxAge) {
+				expired = append(expired, b)
+			}
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].path < expired[j].path })
+	return expired, nil
+}
+
+// pruneBackups 删除超出保留策略的备份文件，预览时只列出将被删除的文件
+func pruneBackups(r retention, preview bool) error {
+	expired, err := expiredBackups(backupDir, r, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(expired) == 0 {
+		debugf(tr("没有超出保留策略的备份"))
+		return nil
+	}
+
+	if preview {
+		fmt.Println(tr("\n以下备份超出保留策略，将被删除:"))
+	} else {
+		fmt.Println(tr("\n清理过期备份:"))
+	}
+	fmt.Println("----------------------------")
+	removed := 0
+	for _, b := range expired {
+		if preview {
+			fmt.Printf("%s  %-8s  %s\n", b.ts, tr(backupKindNames[b.kind]), b.path)
+			continue
+		}
+		if err := os.Remove(b.path); err != nil {
+			warnf(tr("删除备份失败: %v"), err)
+			continue
+		}
+		fmt.Printf(tr("已删除: %s\n"), b.path)
+		removed++
+	}
+	fmt.Println("----------------------------")
+	if preview {
+		fmt.Printf(tr("共 %d 个备份将被删除\n"), len(expired))
+	} else {
+		fmt.Printf(tr("共删除 %d 个备份\n"), removed)
+	}
+	return nil
+}
+
+// runPrune 实现prune子命令: 按保留策略清理备份目录
+func runPrune(args []string) error {
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	registerLangFlag(fs)
+	registerLogFlags(fs)
+	registerBackupFlags(fs)
+	keep := fs.Int("keep", 0, "每个文件保留最近几次运行的备份，0为不限制")
+	maxAge := fs.String("max-age", "", "备份的最长保留时间，如30d、12h，为空时不限制")
+	preview := fs.Bool("dry-run", false, "仅列出将被删除的备份，不删除任何文件")
+	fs.BoolVar(&verbose, "v", false, "启用详细输出模式")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), tr("用法: %s prune [选项]\n\n清理备份目录 %s 中超出保留策略的备份\n\n选项:\n"), os.Args[0], backupDir)
+		printDefaults(fs)
+	}
+	parseFlags(fs, args)
+	if err := checkBackupFlags(); err != nil {
+		fatalf(tr("参数错误: %v"), err)
+	}
+
+	age, err := parseAge(*maxAge)
+	if err != nil {
+		return err
+	}
+	if *keep <= 0 && age == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	return pruneBackups(retention{keep: *keep, maxAge: age}, *preview)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// fileChecksum 记录报告中涉及的文件及其SHA-256校验和
+type fileChecksum struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// reportSummary 按动作统计保留参数
+type reportSummary struct {
+	Replaced   int `json:"replaced"`
+	Inserted   int `json:"inserted"`
+	Appended   int `json:"appended"`
+	Skipped    int `json:"skipped"`
+	Commented  int `json:"commented"` // 新文件中已不存在，按-obsolete以注释形式写入
+	Dropped    int `json:"dropped"`   // 新文件中已不存在，按-obsolete未写入
+	Collisions int `json:"collisions"`
+	Conflicts  int `json:"conflicts"`
+}
+
+// mergeReport 是-report-json输出的机器可读报告，供部署审计系统使用
+type mergeReport struct {
+	Tool       string                `json:"tool"`
+	StartedAt  string                `json:"startedAt"`
+	FinishedAt string                `json:"finishedAt"`
+	DryRun     bool                  `json:"dryRun"`
+	OldFile    fileChecksum          `json:"oldFile"`
+	NewFile    fileChecksum          `json:"newFile"`          // 合并前的新文件
+	Result     *fileChecksum         `json:"result,omitempty"` // 合并后的新文件，预览模式下为空
+	Backups    []fileChecksum        `json:"backups,omitempty"`
+	Summary    reportSummary         `json:"summary"`
+	NotInNew   []string              `json:"notInNew"` // 新文件中不存在而被插入或追加的键
+	Skipped    []string              `json:"skippedDefaults,omitempty"`
+	Duplicates []propmerge.Duplicate `json:"duplicates,omitempty"`
+	Keys       []propmerge.KeyResult `json:"keys"`
+
+	rules  *propmerge.Merger // 用于HTML报告中标注每个参数命中的规则
+	before []string          // 合并前的新文件内容，仅在需要HTML报告时读取
+}
+
+// wantReport 是否需要生成JSON或HTML报告
+func wantReport() bool {
+	return reportFile != "" || reportHTMLFile != ""
+}
+
+// startReport 在合并前记录开始时间与输入文件的校验和
+func startReport(merger *propmerge.Merger, oldFile, newFile string) (*mergeReport, error) {
+	r := &mergeReport{
+		Tool:      "update_config v" + version,
+		StartedAt: time.Now().Format(time.RFC3339),
+		DryRun:    dryRun,
+		rules:     merger,
+	}
+	var err error
+	if r.OldFile, err = checksumFile(oldFile); err != nil {
+		return nil, err
+	}
+	if r.NewFile, err = checksumFile(newFile); err != nil {
+		return nil, err
+	}
+	if reportHTMLFile != "" {
+		if r.before, err = propmerge.ReadFile(newFile); err != nil {
+			return nil, fmt.Errorf(tr("读取新文件失败: %w"), err)
+		}
+	}
+	return r, nil
+}
+
+// finish 填入合并结果、结果文件与备份文件的校验和，并写入JSON报告与HTML报告
+func (r *mergeReport) finish(result propmerge.Result, resultFile string, backups ...string) error {
+	r.Keys = maskedKeys(result.Keys)
+	r.Skipped = result.SkippedDefaults
+	r.Duplicates = result.Duplicates
+	r.Summary, r.NotInNew = summarizeKeys(result.Keys)
+
+	if resultFile != "" {
+		sum, err := checksumFile(resultFile)
+		if err != nil {
+			return err
+		}
+		r.Result = &sum
+	}
+	for _, b := range backups {
+		if b == "" {
+			continue
+		}
+		sum, err := checksumFile(b)
+		if err != nil {
+			return err
+		}
+		r.Backups = append(r.Backups, sum)
+	}
+	r.FinishedAt = time.Now().Format(time.RFC3339)
+
+	if reportHTMLFile != "" {
+		after := result.Lines
+		if resultFile != "" {
+			var err error
+			if after, err = propmerge.ReadFile(resultFile); err != nil {
+				return fmt.Errorf(tr("读取合并结果失败: %w"), err)
+			}
+		}
+		if err := r.writeHTML(reportHTMLFile, result.Keys, after); err != nil {
+			return err
+		}
+	}
+	if reportFile == "" {
+		return nil
+	}
+
+	file, err := createOutputFile(reportFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return fmt.Errorf(tr("写入JSON报告失败: %w"), err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf(tr("写入JSON报告失败: %w"), err)
+	}
+	debugf(tr("JSON报告已写入: %s"), reportFile, slog.String("file", reportFile))
+	return nil
+}
+
+// maskedKeys 返回敏感参数的值已隐藏的处理结果副本
+func maskedKeys(keys []propmerge.KeyResult) []propmerge.KeyResult {
+	masked := make([]propmerge.KeyResult, len(keys))
+	for i, k := range keys {
+		k.OldValue = masker.Value(k.Key, k.OldValue)
+		k.NewValue = masker.Value(k.Key, k.NewValue)
+		masked[i] = k
+	}
+	return masked
+}
+
+// summarizeKeys 按动作统计保留参数，并返回新文件中不存在而被插入或追加的键
+func summarizeKeys(keys []propmerge.KeyResult) (reportSummary, []string) {
+	var s reportSummary
+	notInNew := []string{}
+	for _, k := range keys {
+		switch k.Action {
+		case propmerge.ActionReplace:
+			s.Replaced++
+		case propmerge.ActionInsert:
+			s.Inserted++
+			notInNew = append(notInNew, k.Key)
+		case propmerge.ActionAppend:
+			s.Appended++
+			notInNew = append(notInNew, k.Key)
+		case propmerge.ActionSkip:
+			s.Skipped++
+		case propmerge.ActionComment:
+			s.Commented++
+		case propmerge.ActionDrop:
+			s.Dropped++
+		}
+		if k.Collision != "" {
+			s.Collisions++
+		}
+		if k.Conflict != "" {
+			s.Conflicts++
+		}
+	}
+	return s, notInNew
+}
+
+// checksumFile 计算文件的SHA-256校验和
+func checksumFile(filename string) (fileChecksum, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fileChecksum{}, fmt.Errorf(tr("计算校验和失败: %w"), err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.CopyBuffer(h, file, make([]byte, bufferSize)); err != nil {
+		return fileChecksum{}, fmt.Errorf(tr("计算校验和失败: %w"), err)
+	}
+	return fileChecksum{Path: filename, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// lineOriginsReport 是-line-origins输出的行来源映射
+type lineOriginsReport struct {
+	File  string                 `json:"file"`
+	Lines []propmerge.LineOrigin `json:"lines"`
+}
+
+// writeLineOrigins 指定了-line-origins时，将合并结果中每一行相对于合并前新文件的来源以JSON格式写入指定文件
+func writeLineOrigins(newFile string, template, merged []string, keys []propmerge.KeyResult) error {
+	if lineOriginsFile == "" {
+		return nil
+	}
+	file, err := createOutputFile(lineOriginsFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(lineOriginsReport{File: newFile, Lines: propmerge.LineOrigins(template, merged, keys)}); err != nil {
+		return fmt.Errorf(tr("写入行来源文件失败: %w"), err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf(tr("写入行来源文件失败: %w"), err)
+	}
+	debugf(tr("行来源已写入: %s"), lineOriginsFile, slog.String("file", lineOriginsFile))
+	return nil
+}
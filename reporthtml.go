@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// htmlDiffContext 为HTML报告的并排差异中每处修改前后保留的未变行数
+const htmlDiffContext = 3
+
+// htmlKey 是HTML报告中保留参数表格的一行
+type htmlKey struct {
+	propmerge.KeyResult
+	Action string // 已翻译的动作名称
+	Rule   string // 命中的规则，未命中任何规则时为空
+}
+
+// htmlRuleHit 统计命中同一规则的保留参数
+type htmlRuleHit struct {
+	Rule string
+	Keys []string
+}
+
+// htmlDiffRow 是并排差异中的一行，Gap为省略的未变行数，大于0时该行只作为分隔显示
+type htmlDiffRow struct {
+	propmerge.DiffRow
+	Class string
+	Gap   int
+}
+
+// htmlReport 是HTML报告模板的数据
+type htmlReport struct {
+	*mergeReport
+	Lang      string
+	Host      string
+	Operator  string
+	RulesHash string
+	Keys      []htmlKey
+	RuleHits  []htmlRuleHit
+	Diff      []htmlDiffRow
+	Changed   bool
+}
+
+// writeHTML 将报告写成不依赖外部资源的HTML文件: 元数据、统计、合并前后新文件的并排差异、
+// 保留参数与其命中的规则以及备份文件。keys为未隐藏敏感值的处理结果，用于匹配规则，显示时同样隐藏
+func (r *mergeReport) writeHTML(path string, keys []propmerge.KeyResult, after []string) error {
+	host, _ := os.Hostname()
+	data := htmlReport{
+		mergeReport: r,
+		Lang:        "zh-CN",
+		Host:        host,
+		Operator:    operator(),
+		RulesHash:   rulesHash(),
+	}
+	if lang == propmerge.LanguageEN {
+		data.Lang = "en"
+	}
+
+	actions := map[string]string{"replace": "替换", "insert": "插入", "append": "追加", "skip": "跳过", "comment": "注释写入", "drop": "不写入"}
+	hits := make(map[string][]string)
+	for _, k := range keys {
+		row := htmlKey{KeyResult: k, Action: tr(actions[k.Action]), Rule: r.ruleOf(k)}
+		row.OldValue = masker.Value(k.Key, k.OldValue)
+		row.NewValue = masker.Value(k.Key, k.NewValue)
+		row.TransformedFrom = masker.Value(k.Key, k.TransformedFrom)
+		data.Keys = append(data.Keys, row)
+		if row.Rule != "" {
+			hits[row.Rule] = append(hits[row.Rule], k.Key)
+		}
+	}
+	for rule, keys := range hits {
+		data.RuleHits = append(data.RuleHits, htmlRuleHit{Rule: rule, Keys: keys})
+	}
+	sort.Slice(data.RuleHits, func(i, j int) bool {
+		a, b := data.RuleHits[i], data.RuleHits[j]
+		if len(a.Keys) != len(b.Keys) {
+			return len(a.Keys) > len(b.Keys)
+		}
+		return a.Rule < b.Rule
+	})
+	data.Diff, data.Changed = htmlDiff(r.before, after)
+
+	file, err := createOutputFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := htmlTemplate.Execute(file, data); err != nil {
+		return fmt.Errorf(tr("写入HTML报告失败: %w"), err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf(tr("写入HTML报告失败: %w"), err)
+	}
+	debugf(tr("HTML报告已写入: %s"), path, slog.String("file", path))
+	return nil
+}
+
+// ruleOf 返回保留参数命中的规则说明: 经重命名或转换写入时按旧键名与旧值匹配，
+// 命中正则规则或未填写comment的结构化规则时返回统一的说明，未命中任何规则(如自动保留)时返回空字符串
+func (r *mergeReport) ruleOf(k propmerge.KeyResult) string {
+	if r.rules == nil {
+		return ""
+	}
+	key, value := k.Key, k.OldValue
+	if k.RenamedFrom != "" {
+		key = k.RenamedFrom
+	}
+	if k.TransformedFrom != "" {
+		value = k.TransformedFrom
+	}
+	comment, ok := r.rules.Match(key + "=" + value)
+	if !ok {
+		return ""
+	}
+	if comment == "" {
+		return tr("(无说明的规则)")
+	}
+	return comment
+}
+
+// htmlDiff 生成合并前后的并排差异，只保留每处修改前后htmlDiffContext行，其余未变行折叠为分隔行
+func htmlDiff(before, after []string) ([]htmlDiffRow, bool) {
+	rows := propmerge.SideBySide(before, after)
+	keep := make([]bool, len(rows))
+	changed := false
+	for i, row := range rows {
+		if row.Kind == ' ' {
+			continue
+		}
+		changed = true
+		for j := max(0, i-htmlDiffContext); j <= min(len(rows)-1, i+htmlDiffContext); j++ {
+			keep[j] = true
+		}
+	}
+
+	classes := map[byte]string{' ': "same", '-': "del", '+': "add", '~': "mod"}
+	var out []htmlDiffRow
+	gap := 0
+	for i, row := range rows {
+		if !keep[i] {
+			gap++
+			continue
+		}
+		if gap > 0 {
+			out = append(out, htmlDiffRow{Gap: gap})
+			gap = 0
+		}
+		row.OldText = masker.Line(row.OldText)
+		row.NewText = masker.Line(row.NewText)
+		out = append(out, htmlDiffRow{DiffRow: row, Class: classes[row.Kind]})
+	}
+	if gap > 0 && changed {
+		out = append(out, htmlDiffRow{Gap: gap})
+	}
+	return out, changed
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"tr": tr,
+	"time": func(s string) string {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return s
+		}
+		return t.Format("2006-01-02 15:04:05 -07:00")
+	},
+	"lineNo": func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return fmt.Sprint(n)
+	},
+	"gap": func(n int) string { return fmt.Sprintf(tr("… 省略%d行未变内容 …"), n) },
+}).Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<title>{{tr "配置合并报告"}}: {{.NewFile.Path}}</title>
+<style>
+body{font-family:-apple-system,"Segoe UI","PingFang SC","Microsoft YaHei",sans-serif;margin:24px;color:#1f2328;font-size:14px}
+h1{font-size:22px;margin:0 0 16px}
+h2{font-size:17px;margin:28px 0 8px;border-bottom:1px solid #d0d7de;padding-bottom:4px}
+table{border-collapse:collapse;width:100%}
+th,td{border:1px solid #d0d7de;padding:4px 8px;text-align:left;vertical-align:top}
+th{background:#f6f8fa}
+table.meta th{width:160px}
+code,.diff td{font-family:ui-monospace,SFMono-Regular,Consolas,"Liberation Mono",monospace;font-size:12px}
+.diff{table-layout:fixed}
+.diff td{white-space:pre-wrap;word-break:break-all;border-top:none;border-bottom:none}
+.diff .num{width:48px;text-align:right;color:#6e7781;background:#f6f8fa;user-select:none}
+.diff tr.del td.old,.diff tr.mod td.old{background:#ffebe9}
+.diff tr.add td.new,.diff tr.mod td.new{background:#e6ffec}
+.diff tr.gap td{background:#ddf4ff;color:#57606a;text-align:center;border:1px solid #d0d7de}
+.badge{display:inline-block;padding:0 6px;border-radius:10px;background:#eaeef2;font-size:12px}
+.warn{color:#bc4c00}
+.muted{color:#6e7781}
+</style>
+</head>
+<body>
+<h1>{{tr "配置合并报告"}}{{if .DryRun}} <span class="badge">{{tr "预览模式"}}</span>{{end}}</h1>
+
+<table class="meta">
+<tr><th>{{tr "主机"}}</th><td>{{.Host}}</td></tr>
+<tr><th>{{tr "操作人"}}</th><td>{{.Operator}}</td></tr>
+<tr><th>{{tr "开始时间"}}</th><td>{{time .StartedAt}}</td></tr>
+<tr><th>{{tr "结束时间"}}</th><td>{{time .FinishedAt}}</td></tr>
+<tr><th>{{tr "工具版本"}}</th><td>{{.Tool}}</td></tr>
+{{- if .RulesHash}}
+<tr><th>{{tr "规则哈希"}}</th><td><code>{{.RulesHash}}</code></td></tr>
+{{- end}}
+<tr><th>{{tr "旧文件"}}</th><td>{{.OldFile.Path}}<br><code class="muted">{{.OldFile.SHA256}}</code></td></tr>
+<tr><th>{{tr "新文件"}}</th><td>{{.NewFile.Path}}<br><code class="muted">{{.NewFile.SHA256}}</code></td></tr>
+<tr><th>{{tr "合并结果"}}</th><td>{{with .Result}}{{.Path}}<br><code class="muted">{{.SHA256}}</code>{{else}}{{tr "预览模式，未写入任何文件"}}{{end}}</td></tr>
+</table>
+
+<h2>{{tr "统计"}}</h2>
+<table>
+<tr><th>{{tr "替换"}}</th><th>{{tr "插入"}}</th><th>{{tr "追加"}}</th><th>{{tr "跳过"}}</th><th>{{tr "重命名冲突"}}</th><th>{{tr "三方合并冲突"}}</th></tr>
+<tr><td>{{.Summary.Replaced}}</td><td>{{.Summary.Inserted}}</td><td>{{.Summary.Appended}}</td><td>{{.Summary.Skipped}}</td><td>{{.Summary.Collisions}}</td><td>{{.Summary.Conflicts}}</td></tr>
+</table>
+
+<h2>{{tr "差异"}}</h2>
+{{- if .Changed}}
+<table class="diff">
+<tr><th class="num"></th><th>{{tr "合并前"}}</th><th class="num"></th><th>{{tr "合并后"}}</th></tr>
+{{- range .Diff}}
+{{- if .Gap}}
+<tr class="gap"><td colspan="4">{{gap .Gap}}</td></tr>
+{{- else}}
+<tr class="{{.Class}}"><td class="num">{{lineNo .OldLine}}</td><td class="old">{{.OldText}}</td><td class="num">{{lineNo .NewLine}}</td><td class="new">{{.NewText}}</td></tr>
+{{- end}}
+{{- end}}
+</table>
+{{- else}}
+<p class="muted">{{tr "合并前后内容相同"}}</p>
+{{- end}}
+
+<h2>{{tr "保留参数"}}</h2>
+{{- if .Keys}}
+<table>
+<tr><th>{{tr "键"}}</th><th>{{tr "动作"}}</th><th>{{tr "行"}}</th><th>{{tr "新文件中的值"}}</th><th>{{tr "保留的值"}}</th><th>{{tr "规则"}}</th><th>{{tr "说明"}}</th></tr>
+{{- range .Keys}}
+<tr><td><code>{{.Key}}</code></td><td>{{.Action}}</td><td>{{.Line}}</td><td><code>{{.NewValue}}</code></td><td><code>{{.OldValue}}</code></td><td>{{.Rule}}</td><td>
+{{- if .RenamedFrom}}{{tr "重命名自"}} <code>{{.RenamedFrom}}</code><br>{{end}}
+{{- if .TransformedFrom}}{{tr "转换前"}} <code>{{.TransformedFrom}}</code><br>{{end}}
+{{- if .Declined}}{{tr "未确认，已跳过"}}<br>{{end}}
+{{- if .Collision}}<span class="warn">{{.Collision}}</span><br>{{end}}
+{{- if .Conflict}}<span class="warn">{{.Conflict}}</span>{{end}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p class="muted">{{tr "没有保留参数"}}</p>
+{{- end}}
+
+<h2>{{tr "规则命中"}}</h2>
+{{- if .RuleHits}}
+<table>
+<tr><th>{{tr "规则"}}</th><th>{{tr "命中数"}}</th><th>{{tr "键"}}</th></tr>
+{{- range .RuleHits}}
+<tr><td>{{.Rule}}</td><td>{{len .Keys}}</td><td>{{range $i, $k := .Keys}}{{if $i}}, {{end}}<code>{{$k}}</code>{{end}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p class="muted">{{tr "没有参数命中保留规则"}}</p>
+{{- end}}
+
+<h2>{{tr "备份文件"}}</h2>
+{{- if .Backups}}
+<table>
+<tr><th>{{tr "路径"}}</th><th>SHA-256</th></tr>
+{{- range .Backups}}
+<tr><td>{{.Path}}</td><td><code>{{.SHA256}}</code></td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p class="muted">{{tr "未创建备份"}}</p>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// apiTokenEnv 为未指定-token时读取API令牌的环境变量
+const apiTokenEnv = "UPDATE_CONFIG_API_TOKEN"
+
+// apiServer 是serve子命令的HTTP API，供部署平台直接调用合并逻辑
+type apiServer struct {
+	token   string // 请求须在Authorization头中以Bearer方式携带的令牌
+	maxBody int64  // 请求体的最大字节数
+	root    string // 按路径合并时允许访问的目录，为空时只接受内容方式
+	mu      sync.Mutex
+}
+
+// mergeRequest 是POST /merge的请求体: 直接提供旧文件与新文件的内容(old/new)，
+// 或者提供服务器上的文件路径(oldPath/newPath)，后者在备份后将合并结果写回新文件
+type mergeRequest struct {
+	Old     *string `json:"old"`
+	New     *string `json:"new"`
+	OldPath string  `json:"oldPath"`
+	NewPath string  `json:"newPath"`
+	Env     string  `json:"env"`     // 选择envRules的环境名称
+	Profile string  `json:"profile"` // 选用的规则组
+	Format  string  `json:"format"`  // 文件格式，内容方式下默认为properties，路径方式下默认按扩展名识别
+	DryRun  bool    `json:"dryRun"`  // 路径方式下只返回合并计划，不写入文件
+}
+
+// mergeResponse 是POST /merge的响应
+type mergeResponse struct {
+	Status     string                `json:"status"` // merged、preview或failed
+	Error      string                `json:"error,omitempty"`
+	Content    *string               `json:"content,omitempty"` // 内容方式下的合并结果
+	Summary    reportSummary         `json:"summary"`
+	NotInNew   []string              `json:"notInNew"`
+	Keys       []propmerge.KeyResult `json:"keys"`
+	Duplicates []propmerge.Duplicate `json:"duplicates,omitempty"`
+	Backups    []string              `json:"backups,omitempty"`
+
+	raw []propmerge.KeyResult // 未隐藏敏感值的处理结果，用于监控指标与合并通知
+}
+
+// backupGroup 是GET /backups响应中一个文件的全部备份
+type backupGroup struct {
+	File    string       `json:"file"`
+	Backups []backupInfo `json:"backups"`
+}
+
+// backupInfo 描述一个备份文件
+type backupInfo struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"` // bak、new、repair、rollback或undo
+	Time string `json:"time"` // 备份文件名中的时间戳
+}
+
+// runServe 实现serve子命令: 在指定地址上提供HTTP API，直到收到中断信号
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	registerLangFlag(fs)
+	registerLogFlags(fs)
+	registerBackupFlags(fs)
+	addr := fs.String("addr", "127.0.0.1:8080", "监听地址")
+	token := fs.String("token", "", "API令牌，请求须携带 Authorization: Bearer <令牌> (默认读取UPDATE_CONFIG_API_TOKEN)")
+	maxBody := fs.Int64("max-body", 8<<20, "请求体的最大字节数")
+	root := fs.String("root", "", "允许按路径合并的目录，路径方式的请求只能访问该目录下的文件；为空时只接受内容方式的请求")
+	fs.StringVar(&checkRulesFile, "check-rules", "", "按路径合并后按校验规则文件检查结果，未通过时恢复合并前的备份")
+	fs.StringVar(&backupMode, "backup-mode", backupCopy, "备份方式: copy|git|both")
+	fs.DurationVar(&lockTimeout, "lock-timeout", 0, "目标文件正被其他进程处理时等待锁的最长时间，0为不等待")
+	fs.BoolVar(&verbose, "v", false, "启用详细输出模式")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), tr("用法: %s serve [选项]\n\n提供合并、规则与备份查询的HTTP API: POST /merge, GET /rules, GET /backups, GET /metrics\n\n选项:\n"), os.Args[0])
+		printDefaults(fs)
+	}
+	parseFlags(fs, args)
+	if err := checkBackupFlags(); err != nil {
+		fatalf(tr("参数错误: %v"), err)
+	}
+	if *token == "" {
+		*token = os.Getenv(apiTokenEnv)
+	}
+	if *token == "" {
+		fatalf(tr("参数错误: 必须通过-token或%s指定API令牌"), apiTokenEnv)
+	}
+	if *maxBody <= 0 {
+		fatalf(tr("参数错误: -max-body必须大于0"))
+	}
+	if !validBackupMode(backupMode) {
+		fatalf(tr("参数错误: 无效的备份方式: %s"), backupMode)
+	}
+	s := &apiServer{token: *token, maxBody: *maxBody}
+	if *root != "" {
+		abs, err := filepath.Abs(*root)
+		if err != nil {
+			return fmt.Errorf(tr("解析路径 %s 失败: %w"), *root, err)
+		}
+		s.root = abs
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/merge", s.auth(http.MethodPost, s.handleMerge))
+	mux.HandleFunc("/rules", s.auth(http.MethodGet, s.handleRules))
+	mux.HandleFunc("/backups", s.auth(http.MethodGet, s.handleBackups))
+	mux.Handle("/metrics", metrics)
+	server := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	go func() {
+		<-stop
+		infof(tr("停止API服务"))
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	infof(tr("API服务监听 %s，按 Ctrl+C 停止"), *addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf(tr("启动API服务失败: %w"), err)
+	}
+	return nil
+}
+
+// auth 包装处理函数: 检查请求方法与令牌，并限制请求体大小
+func (s *apiServer) auth(method string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf(tr("不支持的请求方法: %s"), r.Method))
+			return
+		}
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) != 1 {
+			warnf(tr("拒绝未授权的请求: %s %s (%s)"), r.Method, r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, errors.New(tr("未授权")))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxBody)
+		debugf(tr("处理请求: %s %s (%s)"), r.Method, r.URL.Path, r.RemoteAddr)
+		h(w, r)
+	}
+}
+
+// handleMerge 处理POST /merge
+func (s *apiServer) handleMerge(w http.ResponseWriter, r *http.Request) {
+	var req mergeRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Errorf(tr("请求体超过%d字节"), tooLarge.Limit))
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf(tr("解析请求失败: %w"), err))
+		return
+	}
+
+	byContent := req.Old != nil || req.New != nil
+	byPath := req.OldPath != "" || req.NewPath != ""
+	switch {
+	case byContent == byPath:
+		writeJSONError(w, http.StatusBadRequest, errors.New(tr("请求须提供old与new，或者oldPath与newPath")))
+		return
+	case byContent && (req.Old == nil || req.New == nil):
+		writeJSONError(w, http.StatusBadRequest, errors.New(tr("请求须同时提供old与new")))
+		return
+	case byPath && (req.OldPath == "" || req.NewPath == ""):
+		writeJSONError(w, http.StatusBadRequest, errors.New(tr("请求须同时提供oldPath与newPath")))
+		return
+	case byPath && s.root == "":
+		writeJSONError(w, http.StatusForbidden, errors.New(tr("服务未指定-root，不接受按路径合并的请求")))
+		return
+	}
+	if byPath {
+		for _, p := range []*string{&req.OldPath, &req.NewPath} {
+			abs, err := s.resolve(*p)
+			if err != nil {
+				writeJSONError(w, http.StatusForbidden, err)
+				return
+			}
+			*p = abs
+		}
+	}
+
+	// 合并器依据环境与规则组设置掩码、格式插件等全局状态，各请求依次处理
+	s.mu.Lock()
+	start := time.Now()
+	resp, err := s.merge(req, byContent)
+	metrics.observe(start, resp.raw, err)
+	s.mu.Unlock()
+	if byPath && !req.DryRun {
+		changed, conflicts := keyCounts(resp.raw)
+		notifyMerge(newNotice(req.NewPath, changed, conflicts, err))
+	}
+	if err != nil {
+		resp.Status = jobStatus["失败"]
+		resp.Error = err.Error()
+		warnf(tr("API合并失败: %v"), err)
+		writeJSON(w, httpStatus(err), resp)
+		return
+	}
+	infof(tr("API合并完成: %s (保留%d个参数)"), resp.Status, len(resp.Keys), slog.String("file", req.NewPath))
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// merge 按请求的环境、规则组与格式执行一次合并
+func (s *apiServer) merge(req mergeRequest, byContent bool) (mergeResponse, error) {
+	baseEnv, baseProfile, baseFormat := activeEnv, ruleProfile, formatFlag
+	defer func() { activeEnv, ruleProfile, formatFlag = baseEnv, baseProfile, baseFormat }()
+	activeEnv, ruleProfile, formatFlag = req.Env, req.Profile, req.Format
+
+	resp := mergeResponse{Status: jobStatus["已合并"], NotInNew: []string{}}
+	merger, err := newMerger(req.OldPath, req.NewPath)
+	if err != nil {
+		return resp, fmt.Errorf(tr("加载配置失败: %w"), err)
+	}
+	if !knownFormat(formatFlag) {
+		return resp, invalid(fmt.Errorf(tr("不支持的文件格式: %s"), formatFlag))
+	}
+
+	var result propmerge.Result
+	switch {
+	case byContent || req.DryRun:
+		var oldLines, newLines []string
+		if byContent {
+			oldLines, err = propmerge.ReadLines(strings.NewReader(*req.Old))
+			if err == nil {
+				newLines, err = propmerge.ReadLines(strings.NewReader(*req.New))
+			}
+		} else {
+			oldLines, err = propmerge.ReadFile(req.OldPath)
+			if err == nil {
+				newLines, err = propmerge.ReadFile(req.NewPath)
+			}
+		}
+		if err != nil {
+			return resp, err
+		}
+		merge := pathMerge(merger, fileFormat(req.OldPath, req.NewPath))
+		if merge == nil {
+			merge = merger.MergeLines
+		}
+		if result, err = merge(oldLines, newLines); err != nil {
+			resp.raw, resp.Keys = result.Keys, maskedKeys(result.Keys)
+			return resp, err
+		}
+		if byContent {
+			content := strings.Join(result.Lines, propmerge.LineSeparator)
+			if len(result.Lines) > 0 {
+				content += propmerge.LineSeparator
+			}
+			resp.Content = &content
+		}
+		if req.DryRun {
+			resp.Status = jobStatus["预览"]
+		}
+	default:
+		r := mergeInto(req.NewPath, merger, fileFormat(req.OldPath, req.NewPath), req.OldPath, req.NewPath, "", jobBackupDir(req.NewPath))
+		if r.err != nil {
+			resp.raw, resp.Keys = r.keys, maskedKeys(r.keys)
+			return resp, r.err
+		}
+		result.Keys = r.keys
+		for _, b := range []string{lastOldBackup, lastNewBackup} {
+			if b != "" {
+				resp.Backups = append(resp.Backups, b)
+			}
+		}
+	}
+	resp.raw, resp.Keys = result.Keys, maskedKeys(result.Keys)
+	resp.Duplicates = result.Duplicates
+	resp.Summary, resp.NotInNew = summarizeKeys(result.Keys)
+	return resp, nil
+}
+
+// resolve 返回请求中的路径在-root下的真实路径，相对路径相对于-root；路径中的符号链接先解析，
+// 解析后不在-root下(如指向-root之外的符号链接)时返回错误
+func (s *apiServer) resolve(p string) (string, error) {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(s.root, p)
+	}
+	p = filepath.Clean(p)
+	root, err := realPath(s.root)
+	if err != nil {
+		return "", fmt.Errorf(tr("解析路径 %s 失败: %w"), s.root, err)
+	}
+	target, err := realPath(p)
+	if err != nil {
+		return "", fmt.Errorf(tr("解析路径 %s 失败: %w"), p, err)
+	}
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf(tr("路径 %s 不在允许的目录 %s 下"), p, s.root)
+	}
+	return target, nil
+}
+
+// realPath 解析路径中的符号链接；文件尚不存在时解析其最近的已存在的上级目录，再拼接其余部分
+func realPath(p string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(p)
+	if err == nil {
+		return resolved, nil
+	}
+	// 指向不存在文件的符号链接同样视为错误，不按链接本身的位置判断
+	if _, lerr := os.Lstat(p); !os.IsNotExist(err) || lerr == nil {
+		return "", err
+	}
+	parent := filepath.Dir(p)
+	if parent == p {
+		return "", err
+	}
+	dir, err := realPath(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(p)), nil
+}
+
+// ruleConfig 是GET /rules响应中的config: 只包含config-matcher.json中的保留规则，
+// 不返回Vault令牌、备份目的地的AccessKey、通知地址与请求头等凭据
+type ruleConfig struct {
+	Version       int                              `json:"version"`
+	PatternKeys   string                           `json:"patternKeys"`
+	Rules         []propmerge.Rule                 `json:"rules"`
+	ExcludeKeys   []propmerge.Rule                 `json:"excludeKeys"`
+	XMLPaths      []string                         `json:"xmlPaths"`
+	EnvRules      []propmerge.EnvRule              `json:"envRules"`
+	Profiles      map[string]propmerge.RuleProfile `json:"profiles"`
+	Renames       map[string]string                `json:"renames"`
+	Moves         map[string]string                `json:"moves"`
+	Transforms    []propmerge.Transform            `json:"transforms"`
+	SensitiveKeys []string                         `json:"sensitiveKeys"`
+	OnDuplicate   string                           `json:"onDuplicate"`
+}
+
+// rulesOf 从配置中取出保留规则
+func rulesOf(config propmerge.Config) ruleConfig {
+	return ruleConfig{
+		Version:       config.Version,
+		PatternKeys:   config.PatternKeys,
+		Rules:         config.Rules,
+		ExcludeKeys:   config.ExcludeKeys,
+		XMLPaths:      config.XMLPaths,
+		EnvRules:      config.EnvRules,
+		Profiles:      config.Profiles,
+		Renames:       config.Renames,
+		Moves:         config.Moves,
+		Transforms:    config.Transforms,
+		SensitiveKeys: config.SensitiveKeys,
+		OnDuplicate:   config.OnDuplicate,
+	}
+}
+
+// handleRules 处理GET /rules: 返回config-matcher.json中的保留规则，?profile=指定时返回选用该规则组后的规则
+func (s *apiServer) handleRules(w http.ResponseWriter, r *http.Request) {
+	config, exists, err := propmerge.LoadConfig(configFile)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	profile := r.URL.Query().Get("profile")
+	if config, err = config.WithProfile(profile); err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"file":     configFile,
+		"exists":   exists,
+		"profile":  profile,
+		"profiles": config.ProfileNames(),
+		"pattern":  config.KeepPattern(r.URL.Query().Get("env")),
+		"config":   rulesOf(config),
+	})
+}
+
+// handleBackups 处理GET /backups: 列出备份目录中按原文件分组的备份，?file=指定时只列出该文件的备份
+func (s *apiServer) handleBackups(w http.ResponseWriter, r *http.Request) {
+	groups := make(map[string][]backupEntry)
+	if file := r.URL.Query().Get("file"); file != "" {
+		backups, err := findBackups(file)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+		groups[file] = backups
+	} else {
+		var err error
+		if groups, err = groupBackups(backupDir); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	list := make([]backupGroup, 0, len(groups))
+	for name, backups := range groups {
+		g := backupGroup{File: name, Backups: make([]backupInfo, len(backups))}
+		for i, b := range backups {
+			g.Backups[i] = backupInfo{Path: b.path, Kind: b.kind, Time: b.ts}
+		}
+		list = append(list, g)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].File < list[j].File })
+	writeJSON(w, http.StatusOK, list)
+}
+
+// httpStatus 按错误类型返回HTTP状态码，与命令行的退出码对应
+func httpStatus(err error) int {
+	switch exitCode(err) {
+	case exitConflict:
+		return http.StatusConflict
+	case exitInvalid:
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// writeJSON 以JSON格式写入响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		warnf(tr("写入响应失败: %v"), err)
+	}
+}
+
+// writeJSONError 以{"error": "..."}形式写入错误响应
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
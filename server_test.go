@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleRulesOmitsCredentials(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, configFile)
+	config := `{
+  "patternKeys": "^ftp\\.",
+  "rules": ["spring.datasource.**"],
+  "envRules": [{"env": "prod", "keys": ["^redis\\."]}],
+  "vault": {"address": "https://vault.example.com", "token": "s.SUPERSECRET", "roleId": "ROLE-ID-1", "secretId": "SECRET-ID-1"},
+  "backupDir": [{"url": "s3://bucket/prefix", "accessKeyId": "AKIAEXAMPLE", "secretAccessKey": "AWS-SECRET-KEY", "sessionToken": "SESSION-TOKEN"}],
+  "notifications": [{"type": "dingtalk", "url": "https://oapi.dingtalk.com/robot/send?access_token=DING-TOKEN", "headers": {"Authorization": "Bearer HEADER-TOKEN"}}]
+}`
+	if err := os.WriteFile(file, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, dir)
+
+	rec := httptest.NewRecorder()
+	(&apiServer{}).handleRules(rec, httptest.NewRequest(http.MethodGet, "/rules?env=prod", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body: %s", rec.Code, rec.Body)
+	}
+	body := rec.Body.String()
+	for _, secret := range []string{"s.SUPERSECRET", "ROLE-ID-1", "SECRET-ID-1", "AKIAEXAMPLE", "AWS-SECRET-KEY", "SESSION-TOKEN", "DING-TOKEN", "HEADER-TOKEN", "vault.example.com", "s3://bucket"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("response contains %q:\n%s", secret, body)
+		}
+	}
+	for _, rule := range []string{`^ftp\\.`, "spring.datasource.**", `^redis\\.`} {
+		if !strings.Contains(body, rule) {
+			t.Errorf("response is missing rule %q:\n%s", rule, body)
+		}
+	}
+}
+
+// chdir 切换到dir，测试结束后切换回原目录(configFile等路径相对于当前目录)
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// TestResolveSymlinks 指向-root之外的符号链接(文件或上级目录)被拒绝，-root内的链接与尚不存在的文件仍可访问
+func TestResolveSymlinks(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{root, outside, filepath.Join(root, "conf")} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFiles(t, base, map[string]string{
+		"outside/secret.properties": "password=1\n",
+		"root/conf/app.properties":  "a=1\n",
+	})
+	links := map[string]string{
+		"root/secret.properties": "../outside/secret.properties",
+		"root/out":               "../outside",
+		"root/dangling":          "../outside/missing.properties",
+		"root/app.properties":    "conf/app.properties",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(base, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &apiServer{root: root}
+	tests := []struct {
+		path string
+		want string // 为空时应返回错误
+	}{
+		{"conf/app.properties", filepath.Join(root, "conf/app.properties")},
+		{"app.properties", filepath.Join(root, "conf/app.properties")},
+		{"conf/new.properties", filepath.Join(root, "conf/new.properties")},
+		{"new/dir/new.properties", filepath.Join(root, "new/dir/new.properties")},
+		{"secret.properties", ""},
+		{"out/secret.properties", ""},
+		{"out/new.properties", ""},
+		{"dangling", ""},
+		{"../outside/secret.properties", ""},
+		{filepath.Join(outside, "secret.properties"), ""},
+	}
+	for _, tt := range tests {
+		got, err := s.resolve(tt.path)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("resolve(%q) = %q, want an error", tt.path, got)
+		case tt.want != "" && (err != nil || got != tt.want):
+			t.Errorf("resolve(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
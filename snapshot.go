@@ -0,0 +1,401 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// snapshotMode 为true时批量模式在合并前为新发布目录创建快照(-snapshot)
+var snapshotMode bool
+
+// snapshotManifest 是一个快照的清单，与归档一起保存为<id>.json
+type snapshotManifest struct {
+	ID       string         `json:"id"`
+	Time     string         `json:"time"`
+	Operator string         `json:"operator"`
+	Args     []string       `json:"args"`
+	Dir      string         `json:"dir"`     // 打包的目录(绝对路径)
+	Archive  string         `json:"archive"` // 归档的文件名，加密时带.age或.gpg后缀
+	Files    []snapshotFile `json:"files"`
+}
+
+// snapshotFile 描述快照中的一个文件
+type snapshotFile struct {
+	Path   string `json:"path"` // 相对于dir的路径，以/分隔
+	Size   int64  `json:"size"`
+	Mode   string `json:"mode"`
+	SHA256 string `json:"sha256"`
+}
+
+// snapshotDir 返回快照所在的目录
+func snapshotDir() string {
+	return filepath.Join(backupDir, "snapshots")
+}
+
+// createSnapshot 将目录dir(不含其中的备份目录)打包为tar.gz快照，连同清单写入快照目录并复制到backupDir中的目的地。
+// 配置了backupEncryption时归档经管道直接加密保存，明文的归档不写入磁盘
+func createSnapshot(dir string) (snapshotManifest, error) {
+	dir = absPath(dir)
+	if err := os.MkdirAll(snapshotDir(), 0755); err != nil {
+		return snapshotManifest{}, fmt.Errorf(tr("创建备份目录失败: %w"), err)
+	}
+	// 同一秒内创建多个快照(如恢复前为当前状态创建快照)时顺延
+	now := time.Now()
+	id := now.Format("20060102150405")
+	for _, err := os.Stat(snapshotManifestPath(id)); err == nil; _, err = os.Stat(snapshotManifestPath(id)) {
+		now = now.Add(time.Second)
+		id = now.Format("20060102150405")
+	}
+	manifest := snapshotManifest{
+		ID:       id,
+		Time:     time.Now().Format(time.RFC3339),
+		Operator: operator(),
+		Args:     journalArgs(),
+		Dir:      dir,
+		Files:    []snapshotFile{},
+	}
+
+	archive, err := writeBackupStream(filepath.Join(snapshotDir(), id+".tar.gz"), func(w io.Writer) error {
+		return writeSnapshotArchive(w, dir, &manifest)
+	})
+	if err != nil {
+		return manifest, err
+	}
+	manifest.Archive = filepath.Base(archive)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := os.WriteFile(snapshotManifestPath(id), append(data, '\n'), 0644); err != nil {
+		os.Remove(archive)
+		return manifest, fmt.Errorf(tr("写入快照清单失败: %w"), err)
+	}
+	if err := replicateBackups(archive, snapshotManifestPath(id)); err != nil {
+		return manifest, err
+	}
+	batchMu.Lock()
+	noteBackups(archive)
+	batchMu.Unlock()
+	debugf(tr("已创建快照 %s: %s (%d个文件)"), id, archive, len(manifest.Files), slog.String("file", dir))
+	return manifest, nil
+}
+
+// snapshotManifestPath 返回快照清单的路径
+func snapshotManifestPath(id string) string {
+	return filepath.Join(snapshotDir(), id+".json")
+}
+
+// writeSnapshotArchive 将目录中的子目录与普通文件写入tar.gz归档并记入清单；备份目录位于dir中时跳过，
+// 符号链接等其他类型的文件不打包
+func writeSnapshotArchive(w io.Writer, dir string, manifest *snapshotManifest) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	skip := absPath(backupDir)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		if d.IsDir() && p == skip {
+			return filepath.SkipDir
+		}
+		// 合并时为目标文件创建的锁文件不打包
+		if target, ok := strings.CutSuffix(p, ".lock"); ok && !d.IsDir() {
+			if _, err := os.Stat(target); err == nil {
+				return nil
+			}
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && !info.Mode().IsRegular() {
+			debugf(tr("快照跳过非普通文件: %s"), rel)
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = rel
+		if d.IsDir() {
+			header.Name += "/"
+			return tw.WriteHeader(header)
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(tw, h), f); err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, snapshotFile{Path: rel, Size: info.Size(), Mode: info.Mode().Perm().String(), SHA256: hex.EncodeToString(h.Sum(nil))})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf(tr("打包目录 %s 失败: %w"), dir, err)
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// loadSnapshot 读取指定ID的快照清单
+func loadSnapshot(id string) (snapshotManifest, error) {
+	var manifest snapshotManifest
+	if !isBackupTimestamp(id) {
+		return manifest, fmt.Errorf(tr("无效的快照ID: %s (格式20060102150405)"), id)
+	}
+	data, err := os.ReadFile(snapshotManifestPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return manifest, fmt.Errorf(tr("快照目录 %s 中未找到快照 %s"), snapshotDir(), id)
+		}
+		return manifest, fmt.Errorf(tr("读取快照清单失败: %w"), err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf(tr("解析快照清单失败: %w"), err)
+	}
+	return manifest, nil
+}
+
+// listSnapshots 返回快照目录中的所有快照，按ID从新到旧排序
+func listSnapshots() ([]snapshotManifest, error) {
+	entries, err := os.ReadDir(snapshotDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf(tr("读取快照目录失败: %w"), err)
+	}
+	var snapshots []snapshotManifest
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() || !isBackupTimestamp(id) {
+			continue
+		}
+		manifest, err := loadSnapshot(id)
+		if err != nil {
+			warnf("%v", err)
+			continue
+		}
+		snapshots = append(snapshots, manifest)
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].ID > snapshots[j].ID })
+	return snapshots, nil
+}
+
+// snapshotEntry 是快照中待恢复的一项，普通文件已解压到临时目录中的staged
+type snapshotEntry struct {
+	name   string
+	dir    bool
+	mode   os.FileMode
+	staged string
+}
+
+// restoreSnapshot 将快照中的文件写回其目录: 先把所有文件解压到临时目录并按清单核对校验和，
+// 全部一致后才逐个原子地替换目录中的文件，快照损坏时目录保持不变。快照之后新增的文件保持不变
+func restoreSnapshot(manifest snapshotManifest) ([]string, error) {
+	staging, err := os.MkdirTemp("", "snapshot-*")
+	if err != nil {
+		return nil, fmt.Errorf(tr("创建临时目录失败: %w"), err)
+	}
+	defer os.RemoveAll(staging)
+	entries, err := stageSnapshot(manifest, staging)
+	if err != nil {
+		return nil, err
+	}
+
+	var restored []string
+	for _, e := range entries {
+		target := filepath.Join(manifest.Dir, filepath.FromSlash(e.name))
+		if e.dir {
+			if err := os.MkdirAll(target, e.mode); err != nil {
+				return restored, err
+			}
+			continue
+		}
+		if err := restoreFile(e.staged, target); err != nil {
+			return restored, fmt.Errorf(tr("恢复 %s 失败: %w"), target, err)
+		}
+		restored = append(restored, target)
+	}
+	return restored, nil
+}
+
+// stageSnapshot 将快照归档中的目录与普通文件解压到staging，返回按归档顺序排列的各项；
+// 路径无效或文件与清单中的校验和不一致时返回错误
+func stageSnapshot(manifest snapshotManifest, staging string) ([]snapshotEntry, error) {
+	plain, cleanup, err := openBackup(filepath.Join(snapshotDir(), manifest.Archive))
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	f, err := os.Open(plain)
+	if err != nil {
+		return nil, fmt.Errorf(tr("读取快照失败: %w"), err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf(tr("读取快照失败: %w"), err)
+	}
+	sums := make(map[string]string, len(manifest.Files))
+	for _, file := range manifest.Files {
+		sums[file.Path] = file.SHA256
+	}
+
+	var entries []snapshotEntry
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf(tr("读取快照失败: %w"), err)
+		}
+		name := strings.TrimSuffix(header.Name, "/")
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return nil, fmt.Errorf(tr("快照中的路径无效: %s"), header.Name)
+		}
+		e := snapshotEntry{name: name, mode: header.FileInfo().Mode().Perm()}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			e.dir = true
+			entries = append(entries, e)
+			continue
+		case tar.TypeReg:
+		default:
+			continue
+		}
+
+		e.staged = filepath.Join(staging, strconv.Itoa(len(entries)))
+		sum, err := stageFile(archive, e.staged, e.mode)
+		if err != nil {
+			return nil, fmt.Errorf(tr("读取快照失败: %w"), err)
+		}
+		if sums[name] != "" && sum != sums[name] {
+			return nil, fmt.Errorf(tr("快照中的 %s 与快照清单中的校验和不一致，未恢复任何文件"), name)
+		}
+		entries = append(entries, e)
+	}
+}
+
+// stageFile 将r的内容写入新文件path，返回内容的SHA-256
+func stageFile(r io.Reader, path string, mode os.FileMode) (string, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// runSnapshot 实现snapshot子命令: snapshot list列出快照，snapshot restore <id>将快照对应的目录
+// 整体恢复到创建快照时的状态，恢复前先为目录的当前状态创建快照
+func runSnapshot(args []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	registerLangFlag(fs)
+	registerLogFlags(fs)
+	registerBackupFlags(fs)
+	yes := fs.Bool("y", false, "跳过确认提示")
+	fs.StringVar(&backupIdentity, "identity", "", "解密age加密的备份使用的私钥文件，默认使用config-matcher.json中backupEncryption的identity")
+	fs.BoolVar(&verbose, "v", false, "启用详细输出模式")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), tr("用法: %s snapshot list\n       %s snapshot restore [选项] 快照ID\n\n列出批量模式(-snapshot)创建的目录快照，或将目录整体恢复到某个快照\n\n选项:\n"), os.Args[0], os.Args[0])
+		printDefaults(fs)
+	}
+	if len(args) == 0 || (args[0] != "list" && args[0] != "restore") {
+		fs.Usage()
+		os.Exit(1)
+	}
+	parseFlags(fs, args[1:])
+	if err := checkBackupFlags(); err != nil {
+		fatalf(tr("参数错误: %v"), err)
+	}
+
+	if args[0] == "list" {
+		snapshots, err := listSnapshots()
+		if err != nil {
+			return err
+		}
+		if len(snapshots) == 0 {
+			fmt.Printf(tr("快照目录 %s 中没有快照\n"), snapshotDir())
+			return nil
+		}
+		fmt.Println("----------------------------")
+		for _, s := range snapshots {
+			fmt.Printf(tr("%s  %s  %d个文件  %s\n"), s.ID, s.Operator, len(s.Files), s.Dir)
+		}
+		fmt.Println("----------------------------")
+		fmt.Printf(tr("共 %d 个快照\n"), len(snapshots))
+		return nil
+	}
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	manifest, err := loadSnapshot(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(manifest.Dir); err != nil || !info.IsDir() {
+		return fmt.Errorf(tr("快照对应的目录 %s 不存在"), manifest.Dir)
+	}
+	fmt.Printf(tr("将使用快照 %s (%s, %d个文件) 恢复目录 %s\n"), manifest.ID, manifest.Time, len(manifest.Files), manifest.Dir)
+	if !*yes && !confirm("确认恢复?") {
+		fmt.Println(tr("已取消恢复"))
+		return nil
+	}
+
+	current, err := createSnapshot(manifest.Dir)
+	if err != nil {
+		return fmt.Errorf(tr("为目录当前状态创建快照失败: %w"), err)
+	}
+	restored, err := restoreSnapshot(manifest)
+	for _, file := range restored {
+		recordJournal("snapshot", "", file, "")
+	}
+	if err != nil {
+		return fmt.Errorf(tr("%w (已恢复%d个文件，可用 snapshot restore %s 回到本次恢复前的状态)"), err, len(restored), current.ID)
+	}
+	fmt.Println(tr("恢复完成!"))
+	fmt.Printf(tr("共恢复 %d 个文件，恢复前的目录已保存为快照: %s\n"), len(restored), current.ID)
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRestoreSnapshotVerifiesFirst 快照中任一文件与清单的校验和不一致时不恢复任何文件
+func TestRestoreSnapshotVerifiesFirst(t *testing.T) {
+	dir := t.TempDir()
+	chdir(t, dir)
+	if err := os.Mkdir(filepath.Join(dir, "release"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{
+		"release/a.properties": "a=1\n",
+		"release/b.properties": "b=1\n",
+	})
+	manifest, err := createSnapshot("release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, map[string]string{
+		"release/a.properties": "a=2\n",
+		"release/b.properties": "b=2\n",
+	})
+
+	tampered := manifest
+	tampered.Files = append([]snapshotFile(nil), manifest.Files...)
+	for i := range tampered.Files {
+		if tampered.Files[i].Path == "b.properties" {
+			tampered.Files[i].SHA256 = "0000"
+		}
+	}
+	if restored, err := restoreSnapshot(tampered); err == nil || len(restored) != 0 {
+		t.Fatalf("restoreSnapshot with a bad checksum = %v, %v; want an error and nothing restored", restored, err)
+	}
+	for name, want := range map[string]string{"a.properties": "a=2\n", "b.properties": "b=2\n"} {
+		if got := readFile(t, filepath.Join(dir, "release", name)); got != want {
+			t.Errorf("%s = %q after a failed restore, want %q", name, got, want)
+		}
+	}
+
+	if _, err := restoreSnapshot(manifest); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"a.properties": "a=1\n", "b.properties": "b=1\n"} {
+		if got := readFile(t, filepath.Join(dir, "release", name)); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
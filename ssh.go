@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// isSSH 判断文件参数是否为ssh://或sftp://形式的远程文件
+func isSSH(s string) bool {
+	return strings.HasPrefix(s, "ssh://") || strings.HasPrefix(s, "sftp://")
+}
+
+// remoteFile 描述远程主机上的一个文件，通过系统中的sftp命令读写
+type remoteFile struct {
+	user string
+	host string
+	port int
+	path string
+	// identity 为-i传给sftp的私钥文件，空时使用ssh的默认设置
+	identity string
+}
+
+// parseSSH 解析ssh://[用户@]主机[:端口]:/路径 或 ssh://[用户@]主机[:端口]/路径 形式的远程文件
+func parseSSH(raw string) (remoteFile, error) {
+	_, rest, _ := strings.Cut(raw, "://")
+	slash := strings.Index(rest, "/")
+	if slash <= 0 || slash == len(rest)-1 {
+		return remoteFile{}, fmt.Errorf(tr("无效的远程文件 %s，格式应为 ssh://用户@主机:/路径"), raw)
+	}
+	r := remoteFile{path: rest[slash:]}
+	authority := strings.TrimSuffix(rest[:slash], ":")
+	if at := strings.LastIndex(authority, "@"); at != -1 {
+		r.user, authority = authority[:at], authority[at+1:]
+	}
+	if host, port, ok := strings.Cut(authority, ":"); ok {
+		n, err := strconv.Atoi(port)
+		if err != nil || n <= 0 || n > 65535 {
+			return remoteFile{}, fmt.Errorf(tr("无效的远程文件 %s: 端口 %s 无效"), raw, port)
+		}
+		authority, r.port = host, n
+	}
+	if authority == "" {
+		return remoteFile{}, fmt.Errorf(tr("无效的远程文件 %s，格式应为 ssh://用户@主机:/路径"), raw)
+	}
+	r.host = authority
+	return r, nil
+}
+
+// String 返回ssh://形式的地址
+func (r remoteFile) String() string {
+	s := "ssh://"
+	if r.user != "" {
+		s += r.user + "@"
+	}
+	s += r.host
+	if r.port != 0 {
+		s += ":" + strconv.Itoa(r.port)
+	}
+	return s + ":" + r.path
+}
+
+// sftp 以批处理模式执行sftp命令，任一命令失败时中止并返回错误
+func (r remoteFile) sftp(commands ...string) error {
+	args := []string{"-q", "-b", "-"}
+	if r.port != 0 {
+		args = append(args, "-P", strconv.Itoa(r.port))
+	}
+	if r.identity != "" {
+		args = append(args, "-i", r.identity)
+	}
+	dest := r.host
+	if r.user != "" {
+		dest = r.user + "@" + r.host
+	}
+	args = append(args, dest)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(runCtx, "sftp", args...)
+	cmd.Stdin = strings.NewReader(strings.Join(commands, "\n") + "\n")
+	cmd.Stdout, cmd.Stderr = io.Discard, &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf(tr("访问远程文件需要系统中的sftp命令: %w"), err)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf(tr("sftp执行失败: %w: %s"), err, msg)
+		}
+		return fmt.Errorf(tr("sftp执行失败: %w"), err)
+	}
+	return nil
+}
+
+// sftpQuote 为sftp批处理命令中的路径加上引号
+func sftpQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// fetch 通过SFTP将远程文件下载到本地，保留其权限与修改时间
+func (r remoteFile) fetch(local string) error {
+	debugf(tr("下载文件: %s -> %s"), r, local, slog.String("file", local))
+	if err := r.sftp("get -p " + sftpQuote(r.path) + " " + sftpQuote(local)); err != nil {
+		return fmt.Errorf(tr("下载 %s 失败: %w"), r, err)
+	}
+	return nil
+}
+
+// push 将本地文件上传到远程文件所在目录下的临时文件，再重命名覆盖远程文件，保证远程文件不会处于写了一半的状态。
+// backup为true时先以硬链接在远程保留原文件，返回远程备份的路径
+func (r remoteFile) push(local string, backup bool) (string, error) {
+	dir, name := path.Split(r.path)
+	tmp := path.Join(dir, fmt.Sprintf(".%s.tmp.%d", name, os.Getpid()))
+	commands := []string{"put -p " + sftpQuote(local) + " " + sftpQuote(tmp)}
+	backupPath := ""
+	if backup {
+		backupPath = r.path + ".bak." + time.Now().Format("20060102150405")
+		commands = append(commands, "ln "+sftpQuote(r.path)+" "+sftpQuote(backupPath))
+	}
+	commands = append(commands, "rename "+sftpQuote(tmp)+" "+sftpQuote(r.path))
+
+	debugf(tr("上传文件: %s -> %s"), local, r, slog.String("file", local))
+	if err := r.sftp(commands...); err != nil {
+		// 尽量清理残留的临时文件，忽略清理失败
+		r.sftp("-rm " + sftpQuote(tmp))
+		return "", fmt.Errorf(tr("上传 %s 失败: %w"), r, err)
+	}
+	return backupPath, nil
+}
+
+// remoteTarget 是合并结果需要写回远程位置(ssh://、consul://、etcd://)的一次运行
+type remoteTarget interface {
+	push() error
+	Close()
+}
+
+// remoteSession 记录一次运行中使用的远程文件: 远程文件先下载到本地临时目录，合并完成后将结果推送到target
+type remoteSession struct {
+	dir    string
+	target remoteFile
+	local  string // 推送到target的本地文件
+}
+
+// openRemote 下载ssh://形式的旧文件或新文件，返回合并使用的本地路径。
+// 合并结果推送到新文件所在的远程主机；只有旧文件为远程文件时，本地新文件作为模板复制到临时目录中合并，
+// 合并结果推送回旧文件所在的路径，本地模板保持不变，可用于依次更新多台服务器
+func openRemote(oldFile, newFile string) (*remoteSession, string, string, error) {
+	dir, err := os.MkdirTemp("", "update_config-ssh-")
+	if err != nil {
+		return nil, "", "", fmt.Errorf(tr("创建临时目录失败: %w"), err)
+	}
+	s := &remoteSession{dir: dir}
+	local := func(side, name string) (string, error) {
+		p := filepath.Join(dir, side, name)
+		return p, os.MkdirAll(filepath.Dir(p), 0700)
+	}
+
+	if isSSH(oldFile) {
+		r, err := parseSSH(oldFile)
+		if err != nil {
+			return s, "", "", err
+		}
+		if oldFile, err = local("old", path.Base(r.path)); err != nil {
+			return s, "", "", fmt.Errorf(tr("创建临时目录失败: %w"), err)
+		}
+		if err := r.fetch(oldFile); err != nil {
+			return s, "", "", fmt.Errorf(tr("下载旧文件失败: %w"), err)
+		}
+		s.target = r
+	}
+
+	if isSSH(newFile) {
+		r, err := parseSSH(newFile)
+		if err != nil {
+			return s, "", "", err
+		}
+		if newFile, err = local("new", path.Base(r.path)); err != nil {
+			return s, "", "", fmt.Errorf(tr("创建临时目录失败: %w"), err)
+		}
+		if err := r.fetch(newFile); err != nil {
+			return s, "", "", fmt.Errorf(tr("下载新文件失败: %w"), err)
+		}
+		s.target = r
+	} else {
+		template := newFile
+		if newFile, err = local("new", filepath.Base(template)); err != nil {
+			return s, "", "", fmt.Errorf(tr("创建临时目录失败: %w"), err)
+		}
+		if err := backupFile(template, newFile); err != nil {
+			return s, "", "", fmt.Errorf(tr("复制新文件失败: %w"), err)
+		}
+		// 推送时沿用远程原文件的权限
+		if info, err := os.Stat(oldFile); err == nil {
+			os.Chmod(newFile, info.Mode().Perm())
+		}
+	}
+	s.local = newFile
+	return s, oldFile, newFile, nil
+}
+
+// push 将合并结果推送到远程主机
+func (s *remoteSession) push() error {
+	backup, err := s.target.push(s.local, remoteBackup)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(tr("\n合并结果已推送到 %s\n"), s.target)
+	if backup != "" {
+		fmt.Printf(tr("远程备份: %s\n"), backup)
+	}
+	return nil
+}
+
+// Close 删除本地临时目录
+func (s *remoteSession) Close() {
+	os.RemoveAll(s.dir)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// -output-format的取值
+const (
+	outputText = "text" // 供人阅读的汇总
+	outputJSON = "json" // 结束时在标准输出写入一个JSON对象
+)
+
+var (
+	quiet        bool
+	outputFormat string
+
+	// 本次运行中各次合并的统计与创建的备份，用于-output-format json；并行批量处理时由batchMu保护
+	runKeySummary  reportSummary
+	createdBackups []string
+	summaryDone    bool
+)
+
+// runSummary 是-output-format json时标准输出中唯一的JSON对象
+type runSummary struct {
+	Tool     string `json:"tool"`
+	Status   string `json:"status"` // changed、unchanged、conflict或failed
+	ExitCode int    `json:"exitCode"`
+	DryRun   bool   `json:"dryRun"`
+	Changed  int    `json:"changed"` // 值发生变化的保留参数数量
+	reportSummary
+	BackupPaths []string `json:"backupPaths"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// summaryStatus 为各退出码在JSON汇总中的状态
+var summaryStatus = map[int]string{exitChanged: "changed", exitUnchanged: "unchanged", exitConflict: "conflict"}
+
+// add 累加另一次合并的统计
+func (s *reportSummary) add(o reportSummary) {
+	s.Replaced += o.Replaced
+	s.Inserted += o.Inserted
+	s.Appended += o.Appended
+	s.Skipped += o.Skipped
+	s.Commented += o.Commented
+	s.Dropped += o.Dropped
+	s.Collisions += o.Collisions
+	s.Conflicts += o.Conflicts
+}
+
+// noteBackups 记录本次运行创建的备份，调用方持有batchMu
+func noteBackups(paths ...string) {
+	for _, p := range paths {
+		if p != "" {
+			createdBackups = append(createdBackups, absPath(p))
+		}
+	}
+}
+
+// setupOutput 按-quiet与-output-format调整控制台输出: 输出JSON时汇总等信息改为写入标准错误，
+// 使标准输出只有JSON对象；-quiet时不输出这些信息，警告与错误仍写入标准错误
+func setupOutput() error {
+	switch outputFormat {
+	case outputText, outputJSON:
+	default:
+		return fmt.Errorf(tr("无效的输出格式: %s (可选text、json)"), outputFormat)
+	}
+	switch {
+	case quiet:
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		os.Stdout = devNull
+	case outputFormat == outputJSON:
+		os.Stdout = os.Stderr
+	}
+	setupColor()
+	return nil
+}
+
+// writeRunSummary 指定-output-format json时在标准输出写入本次运行的JSON汇总，指定-metrics-file时写入监控指标，
+// err为导致运行失败的错误；一次运行只写入一次
+func writeRunSummary(code int, err error) {
+	batchMu.Lock()
+	defer batchMu.Unlock()
+	writeMetricsFile(err)
+	if outputFormat != outputJSON || summaryDone {
+		return
+	}
+	summaryDone = true
+
+	s := runSummary{
+		Tool:          "update_config v" + version,
+		Status:        summaryStatus[code],
+		ExitCode:      code,
+		DryRun:        dryRun,
+		Changed:       changedKeys,
+		reportSummary: runKeySummary,
+		BackupPaths:   append([]string{}, createdBackups...),
+	}
+	if s.Status == "" {
+		s.Status = "failed"
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	data, jsonErr := json.Marshal(s)
+	if jsonErr != nil {
+		errorf(tr("输出JSON汇总失败: %v"), jsonErr)
+		return
+	}
+	resultOut.Write(append(data, '\n'))
+}
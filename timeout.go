@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	// runTimeout 为-timeout指定的整次运行的时限，为0时不限制
+	runTimeout time.Duration
+	// runCtx 为本次运行中远程下载、钩子、外部命令与文件合并使用的上下文，-timeout到期后取消
+	runCtx = context.Background()
+
+	// tempFiles 为本次运行创建的临时文件，正常结束时由各自的调用方删除，出错退出时由cleanupTemps删除
+	tempMu    sync.Mutex
+	tempFiles = make(map[string]bool)
+)
+
+// startTimeout 按-timeout设置runCtx，返回释放计时器的函数
+func startTimeout() context.CancelFunc {
+	if runTimeout <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+	runCtx = ctx
+	return cancel
+}
+
+// canceled 在runCtx已取消或超时时返回错误，用于在写入文件等不可中断的步骤之前中止
+func canceled() error {
+	if err := runCtx.Err(); err != nil {
+		return fmt.Errorf(tr("已中止: %w"), err)
+	}
+	return nil
+}
+
+// timeoutHint 为-timeout到期导致的错误补充说明，被终止的外部命令返回的错误(如signal: killed)同样补充
+func timeoutHint(err error) error {
+	if runTimeout > 0 && (errors.Is(err, context.DeadlineExceeded) || errors.Is(runCtx.Err(), context.DeadlineExceeded)) {
+		return fmt.Errorf(tr("%w (超过-timeout指定的时限%v，已中止并清理临时文件)"), err, runTimeout)
+	}
+	return err
+}
+
+// trackTemp 登记临时文件，返回删除并注销它的函数
+func trackTemp(path string) func() {
+	tempMu.Lock()
+	tempFiles[path] = true
+	tempMu.Unlock()
+	return func() {
+		tempMu.Lock()
+		delete(tempFiles, path)
+		tempMu.Unlock()
+		os.Remove(path)
+	}
+}
+
+// cleanupTemps 删除所有尚未删除的临时文件，在出错退出前调用(os.Exit不执行defer)
+func cleanupTemps() {
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	for path := range tempFiles {
+		os.Remove(path)
+		delete(tempFiles, path)
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// tuiMode 为true时合并前在终端界面中并排显示新模板与合并结果，逐项确认后再写入(-tui)
+var tuiMode bool
+
+// reviewAborted 为true时操作员在终端界面中放弃了合并，未写入任何修改
+var reviewAborted bool
+
+// reviewItem 是终端界面中可以切换的一项修改
+type reviewItem struct {
+	result   propmerge.KeyResult // 全部接受时的处理结果
+	accepted bool
+}
+
+// reviewer 是-tui的终端界面状态
+type reviewer struct {
+	name     string                                                 // 新模板的文件名
+	format   string                                                 // 文件格式
+	template []string                                               // 合并前的新模板
+	merge    func(reject map[string]bool) (propmerge.Result, error) // 按当前的取舍重新合并
+	items    []reviewItem
+	selected int
+	focusRow int // 选中的修改在差异视图中的行下标，没有对应的行时为-1
+	top      int // 差异视图中第一行的下标
+
+	rows    []propmerge.DiffRow
+	origins map[int]string // 合并结果中各行的来源
+	out     *bufio.Writer
+}
+
+// reviewChanges 实现-tui: 预览合并结果，在终端界面中逐项切换是否写入，应用时将取舍交给confirmer，
+// 随后的合并按取舍写入；放弃时返回false
+func reviewChanges(merger *propmerge.Merger, format, oldFile, newFile string) (bool, error) {
+	oldLines, err := propmerge.ReadFile(oldFile)
+	if err != nil {
+		return false, fmt.Errorf(tr("读取旧文件失败: %w"), err)
+	}
+	newLines, err := propmerge.ReadFile(newFile)
+	if err != nil {
+		return false, fmt.Errorf(tr("读取新文件失败: %w"), err)
+	}
+
+	opts := merger.Options()
+	r := &reviewer{name: newFile, format: format, template: newLines, out: bufio.NewWriter(os.Stdout)}
+	r.merge = func(reject map[string]bool) (propmerge.Result, error) {
+		opts.Confirm = func(k propmerge.KeyResult) bool { return !reject[k.Key] }
+		// 预览时不输出日志，避免打乱界面
+		opts.Log = slog.New(slog.NewTextHandler(io.Discard, nil))
+		opts.Trace = nil
+		m, err := propmerge.New(opts)
+		if err != nil {
+			return propmerge.Result{}, err
+		}
+		if fn := pathMerge(m, format); fn != nil {
+			return fn(oldLines, newLines)
+		}
+		return m.MergeLines(oldLines, newLines)
+	}
+
+	preview, err := r.merge(nil)
+	if err != nil {
+		return false, err
+	}
+	for _, k := range preview.Keys {
+		if k.Changed() || k.Action == propmerge.ActionComment {
+			accepted, ok := confirmer.replay[k.Key]
+			r.items = append(r.items, reviewItem{result: k, accepted: accepted || !ok})
+		}
+	}
+	if len(r.items) == 0 {
+		fmt.Println(tr("没有需要确认的修改"))
+		return true, nil
+	}
+
+	restore, err := rawTerminal()
+	if err != nil {
+		return false, err
+	}
+	// 使用备用屏幕并隐藏光标，退出时恢复原有的终端内容
+	r.out.WriteString("\x1b[?1049h\x1b[?25l")
+	apply, err := r.loop()
+	r.out.WriteString("\x1b[?25h\x1b[?1049l")
+	r.out.Flush()
+	restore()
+	if err != nil || !apply {
+		return false, err
+	}
+
+	for _, it := range r.items {
+		confirmer.reviewed[it.result.Key] = it.accepted
+	}
+	return true, nil
+}
+
+// rawTerminal 通过stty将终端切换为逐键读取且不回显，返回恢复原设置的函数
+func rawTerminal() (func(), error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf(tr("-tui需要系统中的stty命令: %w"), err)
+	}
+	if _, err := stty("-icanon", "-echo", "-isig", "min", "1"); err != nil {
+		return nil, fmt.Errorf(tr("-tui需要系统中的stty命令: %w"), err)
+	}
+	return func() { stty(strings.TrimSpace(saved)) }, nil
+}
+
+// stty 以标准输入所在的终端执行stty
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// terminalSize 返回终端的行数与列数，无法取得时为24x80
+func terminalSize() (int, int) {
+	out, err := stty("size")
+	if err == nil {
+		if f := strings.Fields(out); len(f) == 2 {
+			rows, err1 := strconv.Atoi(f[0])
+			cols, err2 := strconv.Atoi(f[1])
+			if err1 == nil && err2 == nil && rows > 5 && cols > 20 {
+				return rows, cols
+			}
+		}
+	}
+	return 24, 80
+}
+
+// 终端界面中的按键
+const (
+	keyUp = iota + 256
+	keyDown
+	keyPageUp
+	keyPageDown
+)
+
+// readKey 读取一个按键，方向键与翻页键的转义序列转换为对应的常量
+func readKey() (int, error) {
+	b, err := stdin.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0x1b || stdin.Buffered() == 0 {
+		return int(b), nil
+	}
+	// 单独的Esc之后没有缓冲的输入，方向键等转义序列则一次到达
+	if next, _ := stdin.ReadByte(); next != '[' && next != 'O' {
+		return int(next), nil
+	}
+	seq, _ := stdin.ReadByte()
+	switch seq {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case '5', '6':
+		stdin.ReadByte() // 结尾的~
+		if seq == '5' {
+			return keyPageUp, nil
+		}
+		return keyPageDown, nil
+	}
+	return 0, nil
+}
+
+// loop 处理按键直到应用(返回true)或放弃
+func (r *reviewer) loop() (bool, error) {
+	if err := r.refresh(); err != nil {
+		return false, err
+	}
+	r.focus()
+	for {
+		height, width := terminalSize()
+		r.draw(height, width)
+		key, err := readKey()
+		if err != nil {
+			return false, nil
+		}
+		page := max(height-4, 1)
+		switch key {
+		case keyUp, 'k':
+			if r.selected > 0 {
+				r.selected--
+				r.focus()
+			}
+		case keyDown, 'j', '\t':
+			if r.selected < len(r.items)-1 {
+				r.selected++
+				r.focus()
+			}
+		case keyPageUp:
+			r.top = max(r.top-page, 0)
+		case keyPageDown:
+			r.top = max(min(r.top+page, len(r.rows)-page), 0)
+		case ' ', '\r', '\n':
+			r.items[r.selected].accepted = !r.items[r.selected].accepted
+			if err := r.refresh(); err != nil {
+				return false, err
+			}
+			r.focus()
+		case 'A':
+			// 全部接受，已全部接受时全部拒绝
+			all := true
+			for _, it := range r.items {
+				all = all && it.accepted
+			}
+			for i := range r.items {
+				r.items[i].accepted = !all
+			}
+			if err := r.refresh(); err != nil {
+				return false, err
+			}
+			r.focus()
+		case 'a', 'y':
+			return true, nil
+		case 'q', 0x1b, 0x03:
+			return false, nil
+		}
+	}
+}
+
+// refresh 按当前的取舍重新合并，更新差异视图
+func (r *reviewer) refresh() error {
+	reject := make(map[string]bool)
+	for _, it := range r.items {
+		if !it.accepted {
+			reject[it.result.Key] = true
+		}
+	}
+	result, err := r.merge(reject)
+	if err != nil && !errors.Is(err, propmerge.ErrCollision) && !errors.Is(err, propmerge.ErrConflict) && !errors.Is(err, propmerge.ErrDuplicate) {
+		return err
+	}
+	r.rows = propmerge.SideBySide(r.template, result.Lines)
+	r.origins = make(map[int]string)
+	for _, o := range propmerge.LineOrigins(r.template, result.Lines, result.Keys) {
+		r.origins[o.Line] = o.Origin
+	}
+	return nil
+}
+
+// focus 找到选中的修改在差异视图中所在的行(优先取有差异的行)，并滚动视图使其位于中部。
+// YAML等按路径合并的格式中只按键的最后一段查找
+func (r *reviewer) focus() {
+	needle := r.items[r.selected].result.Key
+	if r.format != formatProperties {
+		needle = needle[strings.LastIndex(needle, ".")+1:]
+	}
+	r.focusRow = -1
+	for i, row := range r.rows {
+		if !strings.Contains(row.NewText, needle) && !strings.Contains(row.OldText, needle) {
+			continue
+		}
+		if r.focusRow == -1 || row.Kind != ' ' {
+			r.focusRow = i
+		}
+		if row.Kind != ' ' {
+			break
+		}
+	}
+	if r.focusRow == -1 {
+		return
+	}
+	height, _ := terminalSize()
+	r.top = max(r.focusRow-(height-4)/2, 0)
+}
+
+// draw 绘制整个界面: 标题、并排的新模板与合并结果、选中项的说明与按键提示
+func (r *reviewer) draw(height, width int) {
+	out := r.out
+	out.WriteString("\x1b[H\x1b[2J")
+	half := (width - 3) / 2
+	title := padRight(truncateWidth(tr("新模板: ")+r.name, half), half) + " | " + truncateWidth(tr("合并结果"), half)
+	out.WriteString("\x1b[1m" + title + "\x1b[0m\r\n")
+
+	view := height - 4
+	for i := r.top; i < r.top+view; i++ {
+		if i >= len(r.rows) {
+			out.WriteString("\r\n")
+			continue
+		}
+		row := r.rows[i]
+		left := r.cell(row.OldLine, row.OldText, half)
+		right := r.cell(row.NewLine, row.NewText, half)
+		var leftColor, rightColor string
+		if row.Kind == '-' || row.Kind == '~' {
+			leftColor = "31"
+		}
+		switch r.origins[row.NewLine] {
+		case propmerge.OriginReplaced, propmerge.OriginInserted:
+			rightColor = "32"
+		}
+		if i == r.focusRow {
+			// 选中的修改反色显示
+			leftColor, rightColor = "7;"+firstNonEmpty(leftColor, "0"), "7;"+firstNonEmpty(rightColor, "0")
+		}
+		out.WriteString(paint(leftColor, padRight(left, half)) + " | " + paint(rightColor, padRight(right, half)) + "\r\n")
+	}
+
+	it := r.items[r.selected]
+	mark := "[x]"
+	if !it.accepted {
+		mark = "[ ]"
+	}
+	actions := map[string]string{propmerge.ActionReplace: "替换", propmerge.ActionInsert: "插入", propmerge.ActionAppend: "追加", propmerge.ActionComment: "注释"}
+	status := fmt.Sprintf("%d/%d %s %s %s: %s -> %s", r.selected+1, len(r.items), mark, tr(actions[it.result.Action]), it.result.Key,
+		masker.Value(it.result.Key, it.result.NewValue), masker.Value(it.result.Key, it.result.OldValue))
+	out.WriteString("\x1b[1m" + truncateWidth(status, width) + "\x1b[0m\r\n")
+	out.WriteString(truncateWidth(tr("↑↓/jk 选择修改  空格 切换  A 全部切换  PgUp/PgDn 翻页  a 应用  q 放弃"), width))
+	out.Flush()
+}
+
+// cell 返回差异视图中一侧的内容: 行号与隐藏了敏感值的行
+func (r *reviewer) cell(line int, text string, width int) string {
+	if line == 0 {
+		return ""
+	}
+	return truncateWidth(fmt.Sprintf("%4d %s", line, masker.Line(strings.ReplaceAll(text, "\t", "    "))), width)
+}
+
+// paint 用ANSI颜色包裹终端界面中的文本，与-no-color无关
+func paint(code, s string) string {
+	if code == "" {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// truncateWidth 截断文本使其显示宽度不超过width
+func truncateWidth(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+	w := 0
+	for i, r := range s {
+		rw := displayWidth(string(r))
+		if w+rw > width {
+			return s[:i]
+		}
+		w += rw
+	}
+	return s
+}
@@ -1,421 +1,921 @@
 // root@inco71:~/go# cat update_config-application.properties.go
 // ... 其他代码保持不变 ...
 
-// 在文件顶部添加编译指令
-//go:build linux
-
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"os"
-	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
 )
 
 const (
-	backupDir     = "./config_backup"
-	lineSeparator = "\n"
-	tmpSuffix     = ".tmp"
-	bufferSize    = 64 * 1024 // 64KB buffer
-	configFile    = "config-matcher.json"
-	version       = "1.1.0"
-	buildDate     = "2023-11-20"
+	bufferSize = 64 * 1024 // 64KB buffer
+	configFile = "config-matcher.json"
+	version    = "1.1.0"
+	buildDate  = "2023-11-20"
 )
 
-// Config 定义配置文件结构
-type Config struct {
-	PatternKeys string `json:"patternKeys"`
-}
-
-// loadConfig 加载配置文件
-func loadConfig() (string, error) {
-	// 默认配置
-	defaultPattern := `^(spring\.datasource|spring\.redis|web\.back\.upLoadPath|web\.front\.upLoadPath|token\.expireTime|ftp.userName|ftp.passWord|ftp.host|ftp.port|ftp.baseUrl|ftp.LocalDir|inco.system.xxmc|inco.system.maintitle|inco.person.xxdm|inco.security.login.checkcode)`
-
-	// 检查配置文件是否存在
-	if _, err := os.Stat(configFile); os.IsNotExist(err) {
-		if verbose {
-			logger.Printf("配置文件 %s 不存在，使用默认匹配规则", configFile)
-		}
-		return defaultPattern, nil
-	}
-
-	// 读取配置文件
-	file, err := os.ReadFile(configFile)
-	if err != nil {
-		return "", fmt.Errorf("读取配置文件失败: %w", err)
-	}
-
-	var config Config
-	if err := json.Unmarshal(file, &config); err != nil {
-		return "", fmt.Errorf("解析配置文件失败: %w", err)
-	}
-
-	if config.PatternKeys == "" {
-		if verbose {
-			logger.Printf("配置文件中未定义patternKeys，使用默认匹配规则")
-		}
-		return defaultPattern, nil
-	}
-
-	if verbose {
-		logger.Printf("从配置文件 %s 加载匹配规则", configFile)
-	}
-	return config.PatternKeys, nil
-}
-
 var (
-	verbose     bool
-	showVersion bool
-	logger      = log.New(os.Stderr, "", log.LstdFlags)
+	verbose             bool
+	showVersion         bool
+	activeEnv           string
+	ruleProfile         string
+	repairMode          bool
+	auditFile           string
+	springRelaxed       bool
+	changedOnly         bool
+	traceFile           string
+	collisionPolicy     string
+	autoPreserve        bool
+	autoPreserveOldOnly bool
+	splitMode           bool
+	convertTo           string
+	dryRun              bool
+	defaultsFile        string
+	valuesFile          string
+	renderTemplates     bool
+	provenance          bool
+	provenanceFormat    string
+	showDiff            bool
+	lineOriginsFile     string
+	batchMode           bool
+	batchGlob           string
+	batchParallel       int
+	remoteBackup        bool
+	interactiveMode     bool
+	responsesFile       string
+	saveResponsesFile   string
+	confirmer           *keyConfirmer
+	baseFile            string
+	conflictPolicy      string
+	reportFile          string
+	reportHTMLFile      string
+	mergeMode           string
+	insertStrategy      string
+	obsoletePolicy      string
+	archiveEntry        string
+	noMask              bool
+	formatFlag          string
+	profileMode         bool
+	outputFile          string
+	filterMode          bool
+	httpUser            string
+	httpToken           string
+	httpTimeout         time.Duration
+	backupKeep          int
+	backupMaxAge        string
+	duplicatePolicy     string
+	outputEncoding      string
+	inputEncoding       string
+	unicodeMode         string
+	preMergeHook        string
+	postMergeHook       string
+	masker              *propmerge.Masker
 )
 
 func main() {
-	flag.BoolVar(&verbose, "v", false, "启用详细输出模式")
-	flag.BoolVar(&showVersion, "version", false, "显示版本信息")
+	setLanguage(defaultLanguage())
+	propmerge.SetTranscoder(iconv)
+	if len(os.Args) > 1 {
+		if cmd, ok := findSubcommand(os.Args[1]); ok {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fail(fmt.Errorf(tr("%s失败: %w"), tr(cmd.action), err))
+			}
+			return
+		}
+	}
+
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	registerMergeFlags(flag.CommandLine)
 	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "配置文件更新工具 v%s (构建日期: %s)\n", version, buildDate)
-		fmt.Fprintf(flag.CommandLine.Output(), "用法: %s [选项] 旧配置文件路径 新配置文件路径\n\n", os.Args[0])
-		fmt.Fprintln(flag.CommandLine.Output(), "选项:")
-		flag.PrintDefaults()
-		fmt.Fprintln(flag.CommandLine.Output(), "\n示例:")
+		fmt.Fprintf(flag.CommandLine.Output(), tr("配置文件更新工具 v%s (构建日期: %s)\n"), version, buildDate)
+		fmt.Fprintf(flag.CommandLine.Output(), tr("用法: %s [选项] 旧配置文件路径 新配置文件路径\n"), os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), tr("      %s <子命令> [选项] [参数]\n\n"), os.Args[0])
+		printSubcommands(flag.CommandLine.Output())
+		fmt.Fprintln(flag.CommandLine.Output(), tr("\n选项:"))
+		printDefaults(flag.CommandLine)
+		fmt.Fprintln(flag.CommandLine.Output(), tr("\n示例:"))
 		fmt.Fprintf(flag.CommandLine.Output(), "  %s old.properties new.properties\n", os.Args[0])
 		fmt.Fprintf(flag.CommandLine.Output(), "  %s -v old.properties new.properties\n", os.Args[0])
-	}
-	flag.Parse()
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -env prod old.properties new.properties\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -repair -dry-run old.properties new.properties\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -audit new.properties\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s diff old.properties new.properties\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s backups list new.properties\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s validate old.properties new.properties\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s rules test spring.datasource.url ftp.host=10.0.0.1\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), tr("  %s rollback [-list] [-ts 时间戳] [-y] new.properties\n"), os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s undo [-list] [-y] new.properties\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s prune [-keep 10] [-max-age 30d] [-dry-run]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s watch [-interval 2s] [-name 'application*.properties'] templates/ application.properties\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -split old.properties overlay.properties template.properties\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -convert-to env old.properties preserved.env\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -interactive -save-responses answers.txt old.properties new.properties\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s old-app.jar new-app.jar\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -http-token $TOKEN -output new.properties old.properties https://artifacts.example.com/app/application.properties\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s old.properties 'configserver://config.example.com:8888/myapp/prod?label=main'\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -remote-backup ssh://root@10.0.0.21:/opt/app/application.properties new.properties\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -format toml old.conf new.conf\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -profiles release-old/config/ release-new/config/\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -batch -glob '**/application*.properties' release-old/ release-new/\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s -batch -parallel 8 release-old/ release-new/\n", os.Args[0])
+	}
+	parseFlags(flag.CommandLine, os.Args[1:])
+	code := run(flag.CommandLine)
+	writeRunSummary(code, nil)
+	os.Exit(code)
+}
 
+// run 按解析后的参数执行合并及各模式并返回退出码，不带子命令的调用与merge、diff、dry-run子命令共用
+func run(fs *flag.FlagSet) int {
+	defer startTimeout()()
 	if showVersion {
-		fmt.Printf("配置文件更新工具 v%s\n", version)
-		fmt.Printf("构建日期: %s\n", buildDate)
+		fmt.Printf(tr("配置文件更新工具 v%s\n"), version)
+		fmt.Printf(tr("构建日期: %s\n"), buildDate)
 		os.Exit(0)
 	}
 
-	if flag.NArg() < 2 {
-		flag.Usage()
-		os.Exit(1)
+	if auditFile != "" {
+		if err := auditAgainstBackup(auditFile); err != nil {
+			fail(fmt.Errorf(tr("审计失败: %w"), err))
+		}
+		return exitChanged
 	}
 
-	oldFile := flag.Arg(0)
-	newFile := flag.Arg(1)
-
-	if verbose {
-		logger.Printf("开始处理文件: 旧文件=%s, 新文件=%s", oldFile, newFile)
+	if jobsFile != "" {
+		if fs.NArg() > 0 || batchMode || profileMode || splitMode || convertTo != "" || repairMode || outputFile != "" || reportHTMLFile != "" || interactiveMode {
+			fatalf(tr("参数错误: -jobs不接受文件参数，且不能与批量模式、Profile模式、拆分、导出、修复模式、-output、-report-html或-interactive同时使用"))
+		}
+	} else if fs.NArg() < 2 || (splitMode && fs.NArg() < 3) {
+		fs.Usage()
+		os.Exit(1)
 	}
 
-	// 创建备份目录
-	if err := os.MkdirAll(backupDir, 0755); err != nil {
-		logger.Fatalf("创建备份目录失败: %v", err)
+	envDefaults()
+	if mergeMode != "line" && mergeMode != "value" {
+		fatalf(tr("参数错误: 无效的合并方式: %s"), mergeMode)
 	}
-
-	// 生成备份文件
-	ts := time.Now().Format("20060102150405")
-	if verbose {
-		logger.Printf("创建备份文件...")
+	switch insertStrategy {
+	case propmerge.InsertLine, propmerge.InsertAnchor, propmerge.InsertAppend:
+	default:
+		fatalf(tr("参数错误: 无效的插入策略: %s"), insertStrategy)
 	}
-	if err := backupFile(oldFile, filepath.Join(backupDir, filepath.Base(oldFile)+".bak."+ts)); err != nil {
-		logger.Fatalf("备份旧文件失败: %v", err)
+	switch obsoletePolicy {
+	case propmerge.ObsoleteInsert, propmerge.ObsoleteAppend, propmerge.ObsoleteComment, propmerge.ObsoleteDrop, propmerge.ObsoleteAsk:
+	default:
+		fatalf(tr("参数错误: 无效的已删除参数处理方式: %s"), obsoletePolicy)
 	}
-	if err := backupFile(newFile, filepath.Join(backupDir, filepath.Base(newFile)+".new.bak."+ts)); err != nil {
-		logger.Fatalf("备份新文件失败: %v", err)
+	switch formatFlag {
+	case "", formatProperties, formatYAML, formatTOML, formatINI, formatJSON, formatHOCON, formatEnv, formatXML:
+	default:
+		if config, _, err := propmerge.LoadConfig(configFile); err != nil {
+			fail(invalid(err))
+		} else if _, ok := config.FormatPlugin(formatFlag); !ok {
+			fatalf(tr("参数错误: 不支持的文件格式: %s"), formatFlag)
+		}
 	}
-
-	// 步骤1：提取保留参数
-	if verbose {
-		logger.Printf("从旧文件中提取保留参数...")
+	if batchParallel < 1 {
+		fatalf(tr("参数错误: -parallel必须大于0"))
 	}
-	keepParams, err := extractKeepParams(oldFile)
+	if minOverlap < 0 || minOverlap > 100 {
+		fatalf(tr("参数错误: -min-overlap必须在0到100之间"))
+	}
+	if snapshotMode && !batchMode {
+		fatalf(tr("参数错误: -snapshot只能在批量模式(-batch)下使用"))
+	}
+	if batchParallel > 1 && (interactiveMode || responsesFile != "") {
+		fatalf(tr("参数错误: -parallel不能与-interactive或-responses同时使用"))
+	}
+	policy, err := parseConflictPolicy(conflictPolicy)
 	if err != nil {
-		logger.Fatalf("提取保留参数失败: %v", err)
+		fatalf(tr("参数错误: %v"), err)
 	}
-
-	// 步骤2：更新新文件
-	if verbose {
-		logger.Printf("更新新文件...")
+	conflictPolicy = policy
+	if conflictPolicy == conflictPrompt && (batchParallel > 1 || tuiMode) {
+		fatalf(tr("参数错误: -on-conflict prompt不能与-parallel或-tui同时使用"))
 	}
-	if err := updateNewFile(newFile, keepParams); err != nil {
-		logger.Fatalf("更新新文件失败: %v", err)
+	if tuiMode && (interactiveMode || jobsFile != "" || batchMode || profileMode || splitMode || convertTo != "" || repairMode) {
+		fatalf(tr("参数错误: -tui不能与-interactive、-jobs、批量模式、Profile模式、拆分、导出或修复模式同时使用"))
 	}
-
-	fmt.Println("配置更新完成!已完全使用新文件内容,并保留以下参数在原位置:")
-	printMatchedParams(newFile)
-
-	if verbose {
-		logger.Printf("处理完成")
+	if tuiMode && (!isTerminal(os.Stdin) || !isTerminal(os.Stdout)) {
+		fatalf(tr("参数错误: -tui需要在终端中运行(标准输入与标准输出均为终端)"))
 	}
-}
-
-func backupFile(src, dst string) error {
-	srcFile, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("打开源文件失败: %w", err)
+	if batchParallel > 1 && obsoletePolicy == propmerge.ObsoleteAsk {
+		fatalf(tr("参数错误: -parallel不能与-obsolete ask同时使用"))
 	}
-	defer srcFile.Close()
-
-	dstFile, err := os.Create(dst)
-	if err != nil {
-		return fmt.Errorf("创建目标文件失败: %w", err)
+	if !validBackupMode(backupMode) {
+		fatalf(tr("参数错误: 无效的备份方式: %s"), backupMode)
 	}
-	defer dstFile.Close()
-
-	buf := make([]byte, bufferSize)
-	if _, err := io.CopyBuffer(dstFile, srcFile, buf); err != nil {
-		return fmt.Errorf("复制文件内容失败: %w", err)
+	if err := checkBackupFlags(); err != nil {
+		fatalf(tr("参数错误: %v"), err)
 	}
-
-	if verbose {
-		logger.Printf("成功创建备份文件: %s", dst)
+	if noBackup && manifestFile != "" {
+		fatalf(tr("参数错误: -no-backup不能与-manifest同时使用(清单需要合并前的新文件备份)"))
 	}
-	return nil
-}
-
-func extractKeepParams(filename string) (map[int]string, error) {
-	file, err := os.Open(filename)
+	if outputEncoding != "" && !propmerge.ValidEncoding(outputEncoding) {
+		fatalf(tr("参数错误: 不支持的输出编码: %s"), outputEncoding)
+	}
+	if inputEncoding != "" && !propmerge.ValidEncoding(inputEncoding) {
+		fatalf(tr("参数错误: 不支持的输入编码: %s"), inputEncoding)
+	}
+	propmerge.SetInputEncoding(inputEncoding)
+	switch unicodeMode {
+	case propmerge.UnicodeKeep, propmerge.UnicodeASCII, propmerge.UnicodeUTF8:
+	default:
+		fatalf(tr("参数错误: 无效的Unicode写法: %s"), unicodeMode)
+	}
+	attrs, err := parseFileAttrs()
 	if err != nil {
-		return nil, fmt.Errorf("打开文件失败: %w", err)
+		fatalf(tr("参数错误: %v"), err)
 	}
-	defer file.Close()
-
-	pattern, err := loadConfig()
+	outputAttrs = attrs
+	maxAge, err := parseAge(backupMaxAge)
 	if err != nil {
-		return nil, fmt.Errorf("加载配置失败: %w", err)
+		fatalf(tr("参数错误: %v"), err)
+	}
+	if (quiet || outputFormat == outputJSON) && (interactiveMode || tuiMode || conflictPolicy == conflictPrompt || obsoletePolicy == propmerge.ObsoleteAsk) {
+		fatalf(tr("参数错误: -quiet与-output-format json不能与-interactive、-tui、-on-conflict prompt或-obsolete ask同时使用"))
+	}
+	if err := setupOutput(); err != nil {
+		fatalf(tr("参数错误: %v"), err)
+	}
+	if backupKeep > 0 || maxAge > 0 {
+		// 各模式正常结束后按保留策略清理备份，预览模式下只列出将被删除的备份
+		defer func() {
+			if err := pruneBackups(retention{keep: backupKeep, maxAge: maxAge}, dryRun); err != nil {
+				warnf(tr("清理备份失败: %v"), err)
+			}
+		}()
 	}
 
-	re, err := regexp.Compile(pattern)
-	if err != nil {
-		return nil, fmt.Errorf("编译正则表达式失败: %w", err)
+	if jobsFile != "" {
+		if reportFile != "" {
+			if err := claimPath("JSON报告", reportFile); err != nil {
+				fatalf(tr("参数错误: %v"), err)
+			}
+		}
+		if manifestFile != "" {
+			if err := claimPath("清单文件", manifestFile); err != nil {
+				fatalf(tr("参数错误: %v"), err)
+			}
+		}
+		if err := runJobs(jobsFile); err != nil {
+			fail(fmt.Errorf(tr("执行任务清单失败: %w"), err))
+		}
+		if err := writeManifest(); err != nil {
+			fail(err)
+		}
+		return resultCode()
 	}
 
-	keepParams := make(map[int]string)
-	scanner := bufio.NewScanner(file)
-	lineNum := 1
+	oldFile := fs.Arg(0)
+	newFile := fs.Arg(1)
 
-	if verbose {
-		logger.Printf("开始扫描文件: %s", filename)
-		logger.Printf("使用匹配规则: %s", pattern)
+	if filterMode = isFilter(oldFile, newFile); filterMode {
+		if oldFile == stdio && newFile == stdio {
+			fatalf(tr("参数错误: 旧文件与新文件不能都从标准输入读取"))
+		}
+		if batchMode || profileMode || splitMode || convertTo != "" || repairMode || isSSH(oldFile) || isSSH(newFile) || isKV(oldFile) || isKV(newFile) || isArchive(oldFile) || isArchive(newFile) {
+			fatalf(tr("参数错误: 标准输入输出与-output不能用于批量模式、Profile模式、拆分、导出、修复模式、ssh://远程文件、consul://与etcd://路径以及JAR/WAR归档"))
+		}
+		if reportFile != "" || reportHTMLFile != "" {
+			fatalf(tr("参数错误: -report-json与-report-html不能与标准输入输出或-output同时使用"))
+		}
+		if lineOriginsFile != "" {
+			fatalf(tr("参数错误: -line-origins不能与标准输入输出或-output同时使用"))
+		}
+		if interactiveMode && (oldFile == stdio || newFile == stdio) {
+			fatalf(tr("参数错误: 从标准输入读取配置时不能使用-interactive"))
+		}
+		if conflictPolicy == conflictPrompt && (oldFile == stdio || newFile == stdio) {
+			fatalf(tr("参数错误: 从标准输入读取配置时不能使用-on-conflict prompt"))
+		}
+		if tuiMode {
+			fatalf(tr("参数错误: -tui不能与标准输入输出或-output同时使用"))
+		}
+		if filterOutput() == stdio {
+			if outputFormat == outputJSON {
+				fatalf(tr("参数错误: 合并结果写入标准输出时不能使用-output-format json"))
+			}
+			// 标准输出只写入合并结果，汇总信息改为写入标准错误(-quiet时不输出)
+			if !quiet {
+				os.Stdout = os.Stderr
+			}
+			setupColor()
+		} else if err := claimPath("输出文件", filterOutput()); err != nil {
+			fatalf(tr("参数错误: %v"), err)
+		}
 	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if re.MatchString(line) {
-			keepParams[lineNum] = strings.TrimSuffix(line, "\r")
-			if verbose {
-				logger.Printf("找到匹配参数[行%d]: %s", lineNum, line)
+	if tuiMode && (isArchive(oldFile) || isArchive(newFile)) {
+		fatalf(tr("参数错误: -tui不支持JAR/WAR归档"))
+	}
+	if (batchMode || profileMode) && (isURL(oldFile) || isURL(newFile)) {
+		fatalf(tr("参数错误: 批量模式与Profile模式不支持HTTP/HTTPS地址"))
+	}
+	if isConfigServer(oldFile) || isConfigServer(newFile) {
+		if isConfigServer(oldFile) {
+			fatalf(tr("参数错误: Spring Cloud Config Server只能作为新文件"))
+		}
+		if batchMode || profileMode || splitMode || convertTo != "" {
+			fatalf(tr("参数错误: 批量模式、Profile模式、拆分模式与导出模式不支持Config Server地址"))
+		}
+		if isSSH(oldFile) || isKV(oldFile) {
+			fatalf(tr("参数错误: Config Server地址不能与ssh://远程文件、consul://或etcd://路径同时使用"))
+		}
+		if _, err := parseConfigServer(newFile); err != nil {
+			fatalf(tr("参数错误: %v"), err)
+		}
+	}
+	if isSSH(oldFile) || isSSH(newFile) {
+		if batchMode || profileMode || splitMode || convertTo != "" {
+			fatalf(tr("参数错误: 批量模式、Profile模式、拆分模式与导出模式不支持ssh://远程文件"))
+		}
+		for _, f := range []string{oldFile, newFile} {
+			if _, err := parseSSH(f); isSSH(f) && err != nil {
+				fatalf(tr("参数错误: %v"), err)
 			}
 		}
-		lineNum++
 	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("扫描文件失败: %w", err)
+	if isKV(oldFile) || isKV(newFile) {
+		if batchMode || profileMode || splitMode || convertTo != "" {
+			fatalf(tr("参数错误: 批量模式、Profile模式、拆分模式与导出模式不支持consul://与etcd://路径"))
+		}
+		if isSSH(oldFile) || isSSH(newFile) {
+			fatalf(tr("参数错误: consul://与etcd://路径不能与ssh://远程文件同时使用"))
+		}
+		if isKV(oldFile) && isKV(newFile) && isConsul(oldFile) != isConsul(newFile) {
+			fatalf(tr("参数错误: consul://路径不能与etcd://路径同时使用"))
+		}
+		if etcdTTL > 0 && etcdLease != 0 {
+			fatalf(tr("参数错误: -etcd-ttl与-etcd-lease不能同时使用"))
+		}
+		for _, f := range []string{oldFile, newFile} {
+			if _, err := parseKV(f); isKV(f) && err != nil {
+				fatalf(tr("参数错误: %v"), err)
+			}
+		}
 	}
-
-	if verbose {
-		logger.Printf("共找到%d个需要保留的参数", len(keepParams))
+	var remote remoteTarget
+	if isSSH(oldFile) || isSSH(newFile) {
+		s, localOld, localNew, err := openRemote(oldFile, newFile)
+		if err != nil {
+			s.Close()
+			fail(err)
+		}
+		defer s.Close()
+		remote, oldFile, newFile = s, localOld, localNew
+	}
+	if isKV(oldFile) || isKV(newFile) {
+		s, localOld, localNew, err := openKV(oldFile, newFile)
+		if err != nil {
+			s.Close()
+			fail(err)
+		}
+		defer s.Close()
+		remote, oldFile, newFile = s, localOld, localNew
+	}
+	if isURL(oldFile) {
+		local, err := downloadTemp(oldFile)
+		if err != nil {
+			fail(fmt.Errorf(tr("下载旧文件失败: %w"), err))
+		}
+		defer trackTemp(local)()
+		oldFile = local
+	}
+	newURL := ""
+	if isURL(newFile) && filterMode {
+		local, err := downloadTemp(newFile)
+		if err != nil {
+			fail(fmt.Errorf(tr("下载新文件失败: %w"), err))
+		}
+		defer trackTemp(local)()
+		newFile = local
+	} else if isURL(newFile) {
+		local, err := downloadTarget(newFile)
+		if err != nil {
+			fatalf(tr("参数错误: %v"), err)
+		}
+		newURL, newFile = newFile, local
+	}
+	var configSource *configServer
+	if isConfigServer(newFile) {
+		c, _ := parseConfigServer(newFile)
+		configSource = &c
+		if filterMode {
+			tmp, err := os.CreateTemp("", "update_config-new-*.properties")
+			if err != nil {
+				fail(fmt.Errorf(tr("创建临时文件失败: %w"), err))
+			}
+			tmp.Close()
+			defer trackTemp(tmp.Name())()
+			newFile = tmp.Name()
+		} else if outputFile != "" {
+			newFile = outputFile
+		} else {
+			newFile = c.localName()
+		}
 	}
-	return keepParams, nil
-}
 
-func updateNewFile(filename string, keepParams map[int]string) error {
-	// 读取新文件内容
-	lines, err := readLines(filename)
-	if err != nil {
-		return fmt.Errorf("读取新文件失败: %w", err)
+	debugf(tr("开始处理文件: 旧文件=%s, 新文件=%s"), oldFile, newFile, slog.String("file", newFile))
+
+	if oldFile != stdio {
+		if err := claimPath("旧配置文件", oldFile); err != nil {
+			fatalf(tr("参数错误: %v"), err)
+		}
+	}
+	if newFile != stdio {
+		if err := claimPath("新配置文件", newFile); err != nil {
+			fatalf(tr("参数错误: %v"), err)
+		}
+	}
+	if newURL != "" {
+		if err := downloadNew(newURL, newFile); err != nil {
+			fail(fmt.Errorf(tr("下载新文件失败: %w"), err))
+		}
+	}
+	if configSource != nil {
+		if err := configSource.fetch(newFile); err != nil {
+			fail(fmt.Errorf(tr("下载新文件失败: %w"), err))
+		}
 	}
 
-	if verbose {
-		logger.Printf("开始更新文件: %s (共%d行)", filename, len(lines))
+	if reportFile != "" {
+		if err := claimPath("JSON报告", reportFile); err != nil {
+			fatalf(tr("参数错误: %v"), err)
+		}
+	}
+	if reportHTMLFile != "" {
+		if err := claimPath("HTML报告", reportHTMLFile); err != nil {
+			fatalf(tr("参数错误: %v"), err)
+		}
+	}
+	if lineOriginsFile != "" {
+		if err := claimPath("行来源文件", lineOriginsFile); err != nil {
+			fatalf(tr("参数错误: %v"), err)
+		}
+	}
+	if manifestFile != "" {
+		if err := claimPath("清单文件", manifestFile); err != nil {
+			fatalf(tr("参数错误: %v"), err)
+		}
+	}
+	if diffFile != "" {
+		if err := claimPath("差异文件", diffFile); err != nil {
+			fatalf(tr("参数错误: %v"), err)
+		}
+		showDiff = true
+	}
+	if metricsFile != "" {
+		if err := claimPath("监控指标文件", metricsFile); err != nil {
+			fatalf(tr("参数错误: %v"), err)
+		}
 	}
 
-	// 应用保留参数
-	for oldLineNum, oldLine := range keepParams {
-		key := strings.SplitN(oldLine, "=", 2)[0]
-		newLineNum := findKeyInLines(lines, key)
+	if traceFile != "" {
+		if err := claimPath("跟踪文件", traceFile); err != nil {
+			fatalf(tr("参数错误: %v"), err)
+		}
+		t, err := openTrace(traceFile)
+		if err != nil {
+			fail(fmt.Errorf(tr("创建跟踪文件失败: %w"), err))
+		}
+		tracer = t
+		defer func() {
+			if err := tracer.Close(); err != nil {
+				warnf(tr("写入跟踪文件失败: %v"), err)
+			}
+		}()
+	}
 
-		if newLineNum != -1 {
-			if verbose {
-				logger.Printf("替换参数[行%d]: %s", newLineNum+1, key)
+	// policy为prompt的规则只在终端中逐个询问，并行处理、-quiet或输出JSON时按keep-old处理
+	prompts := batchParallel == 1 && !quiet && outputFormat != outputJSON && isTerminal(os.Stdin) && promptRules()
+	if interactiveMode || responsesFile != "" || tuiMode || conflictPolicy == conflictPrompt || prompts {
+		if saveResponsesFile != "" {
+			if err := claimPath("应答文件", saveResponsesFile); err != nil {
+				fatalf(tr("参数错误: %v"), err)
 			}
-			lines[newLineNum] = oldLine
-		} else {
-			if oldLineNum <= len(lines) {
-				if verbose {
-					logger.Printf("插入参数[行%d]: %s", oldLineNum, key)
-				}
-				lines = insertLine(lines, oldLineNum-1, oldLine)
-			} else {
-				if verbose {
-					logger.Printf("追加参数[行%d]: %s", len(lines)+1, key)
+		}
+		c, err := newKeyConfirmer(interactiveMode, responsesFile)
+		if err != nil {
+			fail(fmt.Errorf(tr("加载应答失败: %w"), err))
+		}
+		c.conflicts = conflictPolicy == conflictPrompt
+		c.prompts = prompts
+		confirmer = c
+		defer func() {
+			if reviewAborted {
+				return
+			}
+			confirmer.printSummary()
+			if saveResponsesFile != "" {
+				if err := confirmer.save(saveResponsesFile); err != nil {
+					warnf("%v", err)
 				}
-				lines = append(lines, oldLine)
 			}
-		}
+		}()
 	}
 
-	// 写入更新后的文件
-	if err := writeLines(filename, lines); err != nil {
-		return fmt.Errorf("写入更新文件失败: %w", err)
+	run := func() error {
+		if err := execute(fs, oldFile, newFile); err != nil {
+			return err
+		}
+		if !dryRun && !batchMode && !profileMode && !splitMode && convertTo == "" && !filterMode && !reviewAborted {
+			if err := checkMerged(newFile, lastNewBackup); err != nil {
+				return err
+			}
+			if err := gitBackupMerged(newFile); err != nil {
+				return err
+			}
+		}
+		if remote != nil && !dryRun {
+			return remote.push()
+		}
+		return nil
 	}
-
-	if verbose {
-		logger.Printf("文件更新完成，共处理%d个参数", len(keepParams))
+	var lock *fileLock
+	if !dryRun && !filterMode && !batchMode && !profileMode && remote == nil {
+		// 批量模式与Profile模式逐个文件加锁，远程文件与consul://、etcd://路径的本地副本无需加锁
+		if lock, err = lockTarget(newFile); err != nil {
+			fail(err)
+		}
 	}
-	return nil
-}
-
-func readLines(filename string) ([]string, error) {
-	file, err := os.Open(filename)
+	err = runWithHooks(oldFile, newFile, run)
+	lock.release()
 	if err != nil {
-		return nil, fmt.Errorf("打开文件失败: %w", err)
+		fail(err)
+	}
+	if err := writeManifest(); err != nil {
+		fail(err)
 	}
-	defer file.Close()
 
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	buf := make([]byte, 0, bufferSize)
-	scanner.Buffer(buf, bufferSize)
+	debugf(tr("处理完成"))
+	return resultCode()
+}
 
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+// execute 按参数选择的模式处理旧文件与新文件
+func execute(fs *flag.FlagSet, oldFile, newFile string) error {
+	if batchMode {
+		if snapshotMode && !dryRun {
+			snapshot, err := createSnapshot(newFile)
+			if err != nil {
+				return fmt.Errorf(tr("创建快照失败: %w"), err)
+			}
+			fmt.Printf(tr("合并前的目录已保存为快照 %s，可用 snapshot restore %s 恢复\n"), snapshot.ID, snapshot.ID)
+		}
+		if err := runBatch(oldFile, newFile); err != nil {
+			return fmt.Errorf(tr("批量处理失败: %w"), err)
+		}
+		return nil
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("读取文件失败: %w", err)
+	if profileMode {
+		if err := runProfiles(oldFile, newFile); err != nil {
+			return fmt.Errorf(tr("Profile模式处理失败: %w"), err)
+		}
+		return nil
 	}
 
-	if verbose {
-		logger.Printf("读取文件完成: %s (共%d行)", filename, len(lines))
+	merger, err := newMerger(oldFile, newFile)
+	if err != nil {
+		return fmt.Errorf(tr("加载配置失败: %w"), err)
 	}
-	return lines, nil
-}
 
-func writeLines(filename string, lines []string) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("创建文件失败: %w", err)
+	if filterMode {
+		return runFilter(merger, oldFile, newFile)
 	}
-	defer file.Close()
 
-	writer := bufio.NewWriterSize(file, bufferSize)
-	for _, line := range lines {
-		if _, err := writer.WriteString(line + lineSeparator); err != nil {
-			return fmt.Errorf("写入文件失败: %w", err)
+	if convertTo != "" {
+		if err := convertFile(merger, oldFile, newFile, convertTo); err != nil {
+			return fmt.Errorf(tr("导出保留参数失败: %w"), err)
 		}
+		return nil
 	}
 
-	if err := writer.Flush(); err != nil {
-		return fmt.Errorf("刷新缓冲区失败: %w", err)
+	if splitMode {
+		templateFile := fs.Arg(2)
+		if err := claimPath("模板文件", templateFile); err != nil {
+			return fmt.Errorf(tr("参数错误: %w"), err)
+		}
+		if err := splitFile(merger, oldFile, newFile, templateFile); err != nil {
+			return fmt.Errorf(tr("拆分文件失败: %w"), err)
+		}
+		return nil
 	}
 
-	if verbose {
-		logger.Printf("写入文件完成: %s (共%d行)", filename, len(lines))
+	if repairMode {
+		if err := repairFile(merger, oldFile, newFile, fs.Arg(2)); err != nil {
+			return fmt.Errorf(tr("修复文件失败: %w"), err)
+		}
+		return nil
 	}
-	return nil
-}
 
-func findKeyInLines(lines []string, key string) int {
-	if len(lines) == 0 {
-		return -1
+	if isArchive(oldFile) || isArchive(newFile) {
+		if err := runArchiveMerge(merger, oldFile, newFile); err != nil {
+			return fmt.Errorf(tr("合并归档失败: %w"), err)
+		}
+		return nil
 	}
 
-	pattern := `^\s*` + regexp.QuoteMeta(key) + `\s*=`
-	re, err := regexp.Compile(pattern)
-	if err != nil {
-		if verbose {
-			logger.Printf("警告: 编译正则表达式失败: %v", err)
+	format := fileFormat(oldFile, newFile)
+	if err := checkOverlap(merger, format, oldFile, newFile); err != nil {
+		return err
+	}
+	if tuiMode {
+		apply, err := reviewChanges(merger, format, oldFile, newFile)
+		if err != nil {
+			return err
+		}
+		if !apply {
+			reviewAborted = true
+			mergeRan = true
+			fmt.Println(tr("已放弃合并，未写入任何修改"))
+			return nil
 		}
-		return -1
 	}
 
-	for i, line := range lines {
-		if re.MatchString(line) {
-			if verbose {
-				logger.Printf("在行%d找到键: %s", i+1, key)
-			}
-			return i
+	if format != formatProperties {
+		if err := runPathMerge(merger, pathMerge(merger, format), oldFile, newFile); err != nil {
+			return fmt.Errorf(tr("合并%s文件失败: %w"), strings.ToUpper(format), err)
 		}
+		return nil
 	}
 
-	if verbose {
-		logger.Printf("未找到键: %s", key)
-	}
-	return -1
+	return runMerge(merger, oldFile, newFile)
 }
 
-func insertLine(lines []string, index int, line string) []string {
-	if index < 0 {
-		index = 0
-	} else if index > len(lines) {
-		index = len(lines)
+// envDefaults 未指定-env与-profile时分别读取APP_ENV与UPDATE_CONFIG_PROFILE环境变量
+func envDefaults() {
+	if activeEnv == "" {
+		activeEnv = os.Getenv("APP_ENV")
 	}
-
-	if verbose {
-		logger.Printf("在位置%d插入新行", index+1)
+	if ruleProfile == "" {
+		ruleProfile = os.Getenv("UPDATE_CONFIG_PROFILE")
 	}
-
-	// 更安全的插入方式，避免潜在的切片问题
-	result := make([]string, 0, len(lines)+1)
-	result = append(result, lines[:index]...)
-	result = append(result, line)
-	result = append(result, lines[index:]...)
-	return result
 }
 
-func printMatchedParams(filename string) {
-	pattern, err := loadConfig()
+// newMerger 根据命令行参数与config-matcher.json创建合并器，newFile非空时作为日志的file字段
+func newMerger(oldFile, newFile string) (*propmerge.Merger, error) {
+	config, exists, err := propmerge.LoadConfig(configFile)
 	if err != nil {
-		logger.Printf("警告: 加载匹配规则失败: %v", err)
-		return
+		return nil, err
+	}
+	if config, err = config.WithProfile(ruleProfile); err != nil {
+		return nil, invalid(err)
+	}
+	if ruleProfile != "" {
+		debugf(tr("选用规则组 %s"), ruleProfile)
+	}
+	if masker, err = newMasker(config); err != nil {
+		return nil, invalid(err)
+	}
+	switch {
+	case !exists:
+		debugf(tr("配置文件 %s 不存在，使用默认匹配规则"), configFile)
+	case len(config.Rules) > 0:
+		debugf(tr("从配置文件 %s 加载%d条结构化保留规则"), configFile, len(config.Rules))
+	case config.PatternKeys == "":
+		debugf(tr("配置文件中未定义patternKeys，使用默认匹配规则"))
+	default:
+		debugf(tr("从配置文件 %s 加载匹配规则"), configFile)
+	}
+	if envKeys := config.EnvPattern(activeEnv); envKeys != "" {
+		debugf(tr("合并环境 %s 的保留规则: %s"), activeEnv, envKeys)
+	}
+	if len(config.ExcludeKeys) > 0 {
+		debugf(tr("从配置文件 %s 加载%d条排除规则"), configFile, len(config.ExcludeKeys))
+	}
+	if len(config.XMLPaths) > 0 {
+		debugf(tr("从配置文件 %s 加载%d条XML保留规则"), configFile, len(config.XMLPaths))
+	}
+	if len(config.Renames) > 0 {
+		debugf(tr("从配置文件 %s 加载%d条键重命名规则"), configFile, len(config.Renames))
+	}
+	if len(config.Moves) > 0 {
+		debugf(tr("从配置文件 %s 加载%d条路径移动规则"), configFile, len(config.Moves))
+	}
+	if len(config.Transforms) > 0 {
+		debugf(tr("从配置文件 %s 加载%d条值转换规则"), configFile, len(config.Transforms))
+	}
+	if len(config.BackupDir) > 0 {
+		debugf(tr("从配置文件 %s 加载%d个备份目的地"), configFile, len(config.BackupDir))
 	}
 
-	re, err := regexp.Compile(pattern)
+	if duplicatePolicy == "" {
+		duplicatePolicy = config.OnDuplicate
+	}
+	jasypt, err := jasyptCodec()
 	if err != nil {
-		logger.Printf("警告: 编译正则表达式失败: %v", err)
-		return
+		return nil, invalid(err)
+	}
+	formatPlugins = config.Formats
+	backupTargets = config.BackupDir
+	secrets, err := loadSecrets(config)
+	if err != nil {
+		return nil, fmt.Errorf(tr("读取密钥失败: %w"), err)
+	}
+
+	opts := propmerge.Options{
+		Pattern:             config.KeepPattern(activeEnv),
+		Rules:               config.Rules,
+		ExcludeKeys:         config.ExcludeKeys,
+		XMLPaths:            config.XMLPaths,
+		Renames:             config.Renames,
+		Moves:               config.Moves,
+		Transforms:          config.Transforms,
+		CollisionPolicy:     collisionPolicy,
+		DetectConflicts:     conflictPolicy != "" && baseFile == "",
+		DuplicatePolicy:     duplicatePolicy,
+		OutputEncoding:      outputEncoding,
+		Unicode:             unicodeMode,
+		SpringRelaxed:       springRelaxed,
+		ValueOnly:           mergeMode == "value",
+		InsertStrategy:      insertStrategy,
+		ObsoletePolicy:      obsoletePolicy,
+		Secrets:             secrets,
+		AutoPreserve:        autoPreserve,
+		AutoPreserveOldOnly: autoPreserveOldOnly,
+		PreserveEncrypted:   preserveEncrypted,
+		Jasypt:              jasypt,
+		PreserveComments:    config.PreserveComments,
+		SourceName:          oldFile,
+		Log:                 logger,
+		Mask:                masker,
+	}
+	if newFile != "" {
+		opts.Log = logger.With("file", newFile)
+	}
+	if tracer != nil {
+		opts.Trace = tracer.Record
+	}
+	if opts.ConflictPolicy, err = effectiveConflictPolicy(oldFile, newFile); err != nil {
+		return nil, invalid(err)
+	}
+	if confirmer != nil {
+		opts.Confirm = confirmer.Confirm
+	}
+	if obsoletePolicy == propmerge.ObsoleteAsk {
+		opts.AskObsolete = askObsolete
+	}
+	if provenance {
+		opts.Provenance = &propmerge.Provenance{
+			Format: provenanceFormat,
+			Source: oldFile,
+			Run:    time.Now().UTC().Format("20060102T150405Z"),
+		}
+	}
+	if defaultsFile != "" {
+		if opts.Defaults, err = loadPropertyValues(defaultsFile); err != nil {
+			return nil, fmt.Errorf(tr("加载默认值失败: %w"), err)
+		}
+	}
+	if renderTemplates || valuesFile != "" {
+		if opts.TemplateData, err = loadTemplateData(valuesFile); err != nil {
+			return nil, invalid(fmt.Errorf(tr("加载模板数据失败: %w"), err))
+		}
 	}
+	if baseFile != "" {
+		if opts.Base, err = loadPropertyValues(baseFile); err != nil {
+			return nil, fmt.Errorf(tr("加载三方合并基线失败: %w"), err)
+		}
+	}
+	m, err := propmerge.New(opts)
+	if err != nil {
+		return nil, invalid(err)
+	}
+	return m, nil
+}
 
-	file, err := os.Open(filename)
+// loadPropertyValues 从key=value格式的文件加载键值，用于默认值文件与三方合并基线
+func loadPropertyValues(filename string) (map[string]string, error) {
+	lines, err := propmerge.ReadFile(filename)
 	if err != nil {
-		logger.Printf("警告: 无法打开文件显示匹配参数: %v", err)
-		return
+		return nil, fmt.Errorf(tr("读取文件失败: %w"), err)
 	}
-	defer file.Close()
 
-	if verbose {
-		logger.Printf("开始显示匹配参数...")
-		logger.Printf("使用匹配规则: %s", pattern)
+	_, props := propmerge.ParseProperties(lines)
+	values := make(map[string]string, len(props))
+	for key, p := range props {
+		values[key] = p.Value
 	}
+	debugf(tr("从 %s 加载%d个键值"), filename, len(values))
+	return values, nil
+}
 
-	scanner := bufio.NewScanner(file)
-	lineNum := 1
-	matchedCount := 0
+// runMerge 执行properties文件的合并: 预览或备份后写入，并输出汇总
+func runMerge(merger *propmerge.Merger, oldFile, newFile string) error {
+	var report *mergeReport
+	if wantReport() {
+		var err error
+		if report, err = startReport(merger, oldFile, newFile); err != nil {
+			return err
+		}
+	}
 
-	fmt.Println("\n匹配的参数列表:")
-	fmt.Println("----------------------------")
-	for scanner.Scan() {
-		line := scanner.Text()
-		if re.MatchString(line) {
-			fmt.Printf("%4d: %s\n", lineNum, line)
-			matchedCount++
+	var original []string
+	if showDiff || dryRun || lineOriginsFile != "" {
+		var err error
+		if original, err = propmerge.ReadFile(newFile); err != nil {
+			return fmt.Errorf(tr("读取新文件失败: %w"), err)
 		}
-		lineNum++
 	}
 
-	if err := scanner.Err(); err != nil {
-		logger.Printf("警告: 扫描文件失败: %v", err)
+	if dryRun {
+		oldLines, err := propmerge.ReadFile(oldFile)
+		if err != nil {
+			return fmt.Errorf(tr("读取旧文件失败: %w"), err)
+		}
+		result, err := merger.MergeLines(oldLines, original)
+		if err != nil {
+			if errors.Is(err, propmerge.ErrCollision) {
+				printCollisions(result.Keys)
+			}
+			if errors.Is(err, propmerge.ErrConflict) {
+				printConflicts(result.Keys)
+			}
+			printDuplicates(result.Duplicates)
+			return fmt.Errorf(tr("生成合并计划失败: %w"), err)
+		}
+		recordResult(result)
+		printPlan(result.Keys)
+		printCollisions(result.Keys)
+		printConflicts(result.Keys)
+		printDiverged(result.Keys)
+		printDuplicates(result.Duplicates)
+		printSkippedDefaults(result.SkippedDefaults)
+		printObsolete(result.Keys)
+		if showDiff {
+			printDiff(newFile, original, result.Lines)
+		}
+		if err := writeLineOrigins(newFile, original, result.Lines, result.Keys); err != nil {
+			return err
+		}
+		if report != nil {
+			return report.finish(result, "")
+		}
+		return nil
 	}
 
-	fmt.Println("----------------------------")
-	fmt.Printf("共找到 %d 个匹配参数\n", matchedCount)
+	oldBackup, newBackup, err := createBackups(backupDir, oldFile, newFile)
+	if err != nil {
+		return err
+	}
 
-	if verbose {
-		logger.Printf("显示匹配参数完成")
+	// 更新新文件
+	debugf(tr("更新新文件..."))
+	result, err := merger.MergeFileContext(runCtx, oldFile, newFile)
+	if err != nil {
+		if errors.Is(err, propmerge.ErrCollision) {
+			printCollisions(result.Keys)
+		}
+		if errors.Is(err, propmerge.ErrConflict) {
+			printConflicts(result.Keys)
+		}
+		printDuplicates(result.Duplicates)
+		return fmt.Errorf(tr("更新新文件失败: %w"), err)
+	}
+	recordResult(result)
+	if err := verifyWrite(oldFile, newFile, oldBackup, newBackup, result); err != nil {
+		return err
+	}
+
+	fmt.Println(green(tr("配置更新完成!已完全使用新文件内容,并保留以下参数在原位置:")))
+	if changedOnly {
+		printChangedParams(result.Keys)
+	} else if autoPreserve {
+		printAutoPreserved(result.Keys)
+	} else {
+		printMatchedParams(merger, newFile)
+	}
+	printCollisions(result.Keys)
+	printConflicts(result.Keys)
+	printDiverged(result.Keys)
+	printDuplicates(result.Duplicates)
+	printSkippedDefaults(result.SkippedDefaults)
+	printObsolete(result.Keys)
+	printBackupPaths(oldBackup, newBackup)
+
+	if showDiff || lineOriginsFile != "" {
+		merged, err := propmerge.ReadFile(newFile)
+		if err != nil {
+			return fmt.Errorf(tr("读取合并结果失败: %w"), err)
+		}
+		if showDiff {
+			printDiff(newFile, original, merged)
+		}
+		if err := writeLineOrigins(newFile, original, merged, result.Keys); err != nil {
+			return err
+		}
+	}
+	if report != nil {
+		return report.finish(result, newFile, oldBackup, newBackup)
 	}
+	return nil
 }
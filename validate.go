@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// runValidate 实现validate子命令: 校验config-matcher.json能否加载、规则能否编译，
+// 并检查给定配置文件能否解析、命中保留规则的参数、重复的键，以及校验规则中的必需键与值类型。
+// 不做合并，可作为服务启动前的检查；任一文件存在问题时返回校验错误
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	registerLangFlag(fs)
+	registerLogFlags(fs)
+	fs.StringVar(&activeEnv, "env", "", "当前环境名称，用于选择config-matcher.json中的envRules (默认读取APP_ENV)")
+	fs.StringVar(&ruleProfile, "profile", "", "选用config-matcher.json中profiles下的规则组，如dev、test、prod (默认读取UPDATE_CONFIG_PROFILE)")
+	fs.StringVar(&checkRulesFile, "check-rules", "", "同时按校验规则文件检查各配置文件(必需的键、非空、整数、布尔值、地址)，默认使用config-matcher.json中的checks")
+	registerJasyptFlags(fs)
+	fs.BoolVar(&verbose, "v", false, "启用详细输出模式")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), tr("用法: %s validate [选项] [配置文件路径...]\n\n校验 %s 与给定的配置文件，存在错误时以非零状态退出\n\n选项:\n"), os.Args[0], configFile)
+		printDefaults(fs)
+	}
+	parseFlags(fs, args)
+	envDefaults()
+
+	config, exists, err := propmerge.LoadConfig(configFile)
+	if err != nil {
+		return invalid(err)
+	}
+	if config, err = config.WithProfile(ruleProfile); err != nil {
+		return invalid(err)
+	}
+	merger, err := newMerger("", "")
+	if err != nil {
+		return fmt.Errorf(tr("保留规则无效: %w"), err)
+	}
+
+	if exists {
+		fmt.Printf(tr("规则文件: %s\n"), configFile)
+	} else {
+		fmt.Printf(tr("规则文件: %s 不存在，使用默认匹配规则\n"), configFile)
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("结构化规则: %d条, 排除规则: %d条, 环境规则: %d条, 键重命名: %d条, 路径移动: %d条\n"), len(config.Rules), len(config.ExcludeKeys), len(config.EnvRules), len(config.Renames), len(config.Moves))
+	if envKeys := config.EnvPattern(activeEnv); envKeys != "" {
+		fmt.Printf(tr("环境 %s 的保留规则: %s\n"), activeEnv, envKeys)
+	}
+	if names := config.ProfileNames(); len(names) > 0 {
+		selected := ruleProfile
+		if selected == "" {
+			selected = tr("无")
+		}
+		fmt.Printf(tr("规则组: %s (选用: %s)\n"), strings.Join(names, ", "), selected)
+	}
+	var rules *propmerge.CheckRules
+	switch {
+	case checkRulesFile != "":
+		r, err := propmerge.LoadCheckRules(checkRulesFile)
+		if err != nil {
+			return invalid(err)
+		}
+		rules = &r
+		printCheckRules(checkRulesFile, r)
+	case config.Checks != nil:
+		rules = config.Checks
+		printCheckRules(configFile+" (checks)", *rules)
+	}
+	fmt.Println("----------------------------")
+
+	failed := 0
+	for _, filename := range fs.Args() {
+		problems, err := validateFile(merger, filename)
+		if err != nil {
+			fmt.Printf(tr("%s: 错误: %v\n"), filename, err)
+			failed++
+			continue
+		}
+		if rules != nil {
+			n, err := checkFile(*rules, filename)
+			if err != nil {
+				fmt.Printf(tr("%s: 错误: %v\n"), filename, err)
+				failed++
+				continue
+			}
+			problems += n
+		}
+		if problems > 0 {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return invalid(fmt.Errorf(tr("%d个文件未通过校验"), failed))
+	}
+	fmt.Println(tr("校验通过"))
+	return nil
+}
+
+// printCheckRules 输出校验规则的来源与各项规则的数量
+func printCheckRules(source string, r propmerge.CheckRules) {
+	fmt.Printf(tr("校验规则: %s (必需%d, 非空%d, 整数%d, 布尔值%d, 地址%d)\n"), source, len(r.Required), len(r.NonEmpty), len(r.Integer), len(r.Boolean), len(r.URL))
+}
+
+// validateFile 读取并解析配置文件，输出编码、参数数量与命中保留规则的参数数量；
+// properties文件中重复的键视为问题逐个输出。返回发现的问题数量，文件无法读取或解析时返回错误
+func validateFile(merger *propmerge.Merger, filename string) (int, error) {
+	lines, err := propmerge.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	encoding := propmerge.DetectFileEncoding(filename)
+	format := fileFormat(filename, filename)
+	if format != formatProperties {
+		entries, err := parseConfig(filename, format, lines)
+		if err != nil {
+			return 0, err
+		}
+		kept := 0
+		if format == formatXML {
+			preserved, err := merger.XMLPreserved(lines)
+			if err != nil {
+				return 0, fmt.Errorf(tr("解析 %s 失败: %w"), filename, err)
+			}
+			kept = len(preserved)
+		} else {
+			for _, e := range entries {
+				if _, ok := merger.MatchRule(e.Key + "=" + e.Value); ok {
+					kept++
+				}
+			}
+		}
+		fmt.Printf(tr("%s: 格式%s, 编码%s, 共%d行, %d个参数, %d个命中保留规则\n"), filename, format, encoding, len(lines), len(entries), kept)
+		return 0, nil
+	}
+
+	keys, _ := propmerge.ParseProperties(lines)
+	kept, _ := merger.Extract(lines)
+	fmt.Printf(tr("%s: 编码%s, 共%d行, %d个参数, %d个命中保留规则\n"), filename, encoding, len(lines), len(keys), len(kept))
+	dups := merger.FindDuplicates("", lines)
+	for _, d := range dups {
+		nums := make([]string, len(d.Lines))
+		for i, n := range d.Lines {
+			nums[i] = fmt.Sprint(n)
+		}
+		fmt.Printf(tr("%s: 重复的键 %s (行%s)\n"), filename, d.Key, strings.Join(nums, ","))
+	}
+	return len(dups), nil
+}
+
+// rulesExpect 为rules test的期望结果文件(-expect)
+var rulesExpect string
+
+// runRules 实现rules test子命令: 逐个判断键、key=value行或配置文件中的每一行是否命中保留规则，
+// 输出命中的规则与提取的键名。指定-expect时按期望结果文件逐项核对，有不符合预期的项时返回校验错误，
+// 便于在CI中对规则的修改做回归测试
+func runRules(args []string) error {
+	fs := flag.NewFlagSet("rules test", flag.ContinueOnError)
+	registerLangFlag(fs)
+	registerLogFlags(fs)
+	fs.StringVar(&activeEnv, "env", "", "当前环境名称，用于选择config-matcher.json中的envRules (默认读取APP_ENV)")
+	fs.StringVar(&ruleProfile, "profile", "", "选用config-matcher.json中profiles下的规则组，如dev、test、prod (默认读取UPDATE_CONFIG_PROFILE)")
+	fs.BoolVar(&springRelaxed, "spring-relaxed", false, "按Spring Boot宽松绑定规则匹配键")
+	fs.BoolVar(&preserveEncrypted, "preserve-encrypted", false, "ENC(...)形式的Jasypt加密值视为命中保留规则")
+	fs.StringVar(&rulesExpect, "expect", "", "期望结果文件: 每行为 \"+ 键[=值]\"(应保留) 或 \"- 键[=值]\"(不应保留)，逐项核对")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), tr("用法: %s rules test [选项] [键、key=value或配置文件...]\n\n判断各键或配置文件中的各行是否命中 %s 中的保留规则，未给出参数时从标准输入逐行读取\n\n选项:\n"), os.Args[0], configFile)
+		printDefaults(fs)
+	}
+	if len(args) == 0 || args[0] != "test" {
+		fs.Usage()
+		os.Exit(1)
+	}
+	parseFlags(fs, args[1:])
+	envDefaults()
+
+	merger, err := newMerger("", "")
+	if err != nil {
+		return fmt.Errorf(tr("保留规则无效: %w"), err)
+	}
+
+	if rulesExpect != "" {
+		return testExpectations(merger, rulesExpect)
+	}
+
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				inputs = append(inputs, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf(tr("读取标准输入失败: %w"), err)
+		}
+	}
+
+	total, matched := 0, 0
+	for _, input := range inputs {
+		if info, err := os.Stat(input); err == nil && !info.IsDir() && !strings.Contains(input, "=") {
+			n, m, err := testRulesFile(merger, input)
+			if err != nil {
+				return err
+			}
+			total, matched = total+n, matched+m
+			continue
+		}
+		line := input
+		if !strings.Contains(line, "=") {
+			line += "="
+		}
+		total++
+		if printRuleHit(merger, "", line) {
+			matched++
+		}
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共 %d 个键, %d 个命中保留规则\n"), total, matched)
+	return nil
+}
+
+// testRulesFile 判断配置文件中的每个参数是否命中保留规则: properties文件逐行判断，
+// 其他格式按解析出的键值判断；注释与空行不输出。返回参数数量与命中数量
+func testRulesFile(merger *propmerge.Merger, filename string) (int, int, error) {
+	lines, _, _, err := readConfigLines(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	format := fileFormat(filename, filename)
+	fmt.Printf("%s:\n", filename)
+	total, matched := 0, 0
+	if format == formatProperties {
+		forced := merger.DirectiveLines(lines)
+		for i, line := range propmerge.FoldLines(lines) {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "!") {
+				continue
+			}
+			total++
+			if forced[i+1] {
+				fmt.Printf(tr("%s保留    %s (%s)\n"), fmt.Sprintf("%4d  ", i+1), propmerge.LineKey(trimmed), tr("注释指令"))
+				matched++
+				continue
+			}
+			if printRuleHit(merger, fmt.Sprintf("%4d  ", i+1), trimmed) {
+				matched++
+			}
+		}
+		return total, matched, nil
+	}
+
+	entries, err := parseConfig(filename, format, lines)
+	if err != nil {
+		return 0, 0, err
+	}
+	if format == formatXML {
+		return testXMLPaths(merger, filename, lines, entries)
+	}
+	for _, e := range entries {
+		total++
+		if printRuleHit(merger, fmt.Sprintf("%4d  ", e.Line), e.Key+"="+e.Value) {
+			matched++
+		}
+	}
+	return total, matched, nil
+}
+
+// testXMLPaths 逐个输出XML文件中的属性值与元素文本是否命中xmlPaths及命中的XPath表达式
+func testXMLPaths(merger *propmerge.Merger, filename string, lines []string, entries []propmerge.Entry) (int, int, error) {
+	preserved, err := merger.XMLPreserved(lines)
+	if err != nil {
+		return 0, 0, fmt.Errorf(tr("解析 %s 失败: %w"), filename, err)
+	}
+	matched := 0
+	for _, e := range entries {
+		prefix := fmt.Sprintf("%4d  ", e.Line)
+		if expr, ok := preserved[e.Key]; ok {
+			fmt.Printf(tr("%s保留    %s (%s)\n"), prefix, e.Key, "xmlPaths: "+expr)
+			matched++
+		} else {
+			fmt.Printf(tr("%s不保留  %s\n"), prefix, e.Key)
+		}
+	}
+	return len(entries), matched, nil
+}
+
+// printRuleHit 输出一行的判断结果、提取的键名与命中的规则，prefix为行号等前缀；返回是否命中
+func printRuleHit(merger *propmerge.Merger, prefix, line string) bool {
+	key := propmerge.LineKey(line)
+	hit, ok := merger.MatchRule(line)
+	switch {
+	case ok:
+		fmt.Printf(tr("%s保留    %s (%s)\n"), prefix, key, describeHit(hit))
+	case hit.Excluded > 0:
+		fmt.Printf(tr("%s不保留  %s (%s，被excludeKeys第%d条排除)\n"), prefix, key, describeHit(hit), hit.Excluded)
+	case hit.ForcedNew > 0:
+		fmt.Printf(tr("%s不保留  %s (%s，rules第%d条要求取新文件中的值)\n"), prefix, key, describeHit(hit), hit.ForcedNew)
+	default:
+		fmt.Printf(tr("%s不保留  %s\n"), prefix, key)
+	}
+	return ok
+}
+
+// describeHit 返回命中规则的说明: patternKeys、加密值或结构化规则的序号、类型、键与comment
+func describeHit(hit propmerge.RuleHit) string {
+	switch {
+	case hit.Pattern:
+		return "patternKeys"
+	case hit.Encrypted:
+		return tr("加密值")
+	}
+	typ := hit.Rule.Type
+	if typ == "" {
+		typ = propmerge.RulePrefix
+	}
+	desc := fmt.Sprintf(tr("rules第%d条 %s: %s"), hit.Index, typ, strings.Join(hit.Rule.Keys, ", "))
+	if hit.Rule.Policy == propmerge.PolicyPrompt {
+		desc += tr("，逐项确认")
+	}
+	if list := hit.Rule.MergeList; list != "" && list != propmerge.ListOld {
+		desc += fmt.Sprintf(tr("，列表合并: %s"), list)
+	}
+	if hit.Rule.Comment != "" {
+		desc += " - " + hit.Rule.Comment
+	}
+	return desc
+}
+
+// testExpectations 按期望结果文件核对保留规则: "+ "开头的行应命中，"- "开头的行不应命中，
+// 只有键名时按空值判断；#开头的行与空行忽略。有不符合预期的项时返回校验错误
+func testExpectations(merger *propmerge.Merger, filename string) error {
+	lines, err := propmerge.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf(tr("读取期望结果文件失败: %w"), err)
+	}
+	total, failed := 0, 0
+	for i, raw := range lines {
+		text := strings.TrimSpace(raw)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if len(text) < 2 || (text[0] != '+' && text[0] != '-') || (text[1] != ' ' && text[1] != '\t') {
+			return invalid(fmt.Errorf(tr("%s:%d: 无效的期望结果，格式应为 \"+ 键[=值]\" 或 \"- 键[=值]\""), filename, i+1))
+		}
+		want := text[0] == '+'
+		line := strings.TrimSpace(text[1:])
+		if !strings.Contains(line, "=") {
+			line += "="
+		}
+		key := propmerge.LineKey(line)
+		hit, ok := merger.MatchRule(line)
+		total++
+		switch {
+		case ok == want:
+			debugf(tr("符合预期: %s"), key)
+		case want:
+			failed++
+			fmt.Printf(tr("%s:%d: %s 应保留，但未命中任何保留规则\n"), filename, i+1, key)
+		default:
+			failed++
+			fmt.Printf(tr("%s:%d: %s 不应保留，但命中了 %s\n"), filename, i+1, key, describeHit(hit))
+		}
+	}
+	fmt.Println("----------------------------")
+	fmt.Printf(tr("共 %d 项期望, %d 项不符合\n"), total, failed)
+	if failed > 0 {
+		return invalid(fmt.Errorf(tr("保留规则测试未通过: %d项不符合预期"), failed))
+	}
+	return nil
+}
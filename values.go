@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// loadTemplateData 返回渲染值模板使用的数据: 顶层为全部环境变量与-values文件中的变量(同名时以文件为准)，
+// 文件中的点分键展开为嵌套的映射(如db.host写作{{ .db.host }})；另有Env(仅环境变量)、Values(仅文件中的变量)与Hostname
+func loadTemplateData(filename string) (map[string]interface{}, error) {
+	env := make(map[string]interface{})
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok && name != "" {
+			env[name] = value
+		}
+	}
+	values := make(map[string]interface{})
+	if filename != "" {
+		lines, err := propmerge.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf(tr("读取变量文件失败: %w"), err)
+		}
+		entries, err := parseConfig(filename, valuesFormat(filename), lines)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if err := setNested(values, strings.Split(e.Key, "."), e.Value); err != nil {
+				return nil, fmt.Errorf(tr("%s 第%d行: %w"), filename, e.Line, err)
+			}
+		}
+		debugf(tr("从 %s 加载%d个模板变量"), filename, len(entries))
+	}
+	hostname, _ := os.Hostname()
+
+	data := make(map[string]interface{}, len(env)+len(values)+3)
+	for k, v := range env {
+		data[k] = v
+	}
+	for k, v := range values {
+		data[k] = v
+	}
+	data["Env"] = env
+	data["Values"] = values
+	data["Hostname"] = hostname
+	return data, nil
+}
+
+// valuesFormat 按扩展名判断变量文件的格式，不受-format影响
+func valuesFormat(filename string) string {
+	switch {
+	case propmerge.IsYAMLFile(filename):
+		return formatYAML
+	case propmerge.IsJSONFile(filename):
+		return formatJSON
+	case propmerge.IsTOMLFile(filename):
+		return formatTOML
+	case propmerge.IsINIFile(filename):
+		return formatINI
+	case propmerge.IsHOCONFile(filename):
+		return formatHOCON
+	case propmerge.IsEnvFile(filename):
+		return formatEnv
+	}
+	return formatProperties
+}
+
+// setNested 按路径将值写入嵌套的映射，路径的中间一段已是标量时返回错误
+func setNested(m map[string]interface{}, path []string, value string) error {
+	for i, name := range path[:len(path)-1] {
+		child, exists := m[name]
+		if !exists {
+			next := make(map[string]interface{})
+			m[name] = next
+			m = next
+			continue
+		}
+		next, ok := child.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf(tr("变量 %s 既是值又包含下级变量"), strings.Join(path[:i+1], "."))
+		}
+		m = next
+	}
+	last := path[len(path)-1]
+	if _, ok := m[last].(map[string]interface{}); ok {
+		return fmt.Errorf(tr("变量 %s 既是值又包含下级变量"), strings.Join(path, "."))
+	}
+	m[last] = value
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// vaultClient 访问HashiCorp Vault的HTTP API
+type vaultClient struct {
+	addr      string
+	namespace string
+	token     string
+	client    *http.Client
+}
+
+var (
+	// secretsMu保护secretCache，批量模式下多个文件并行合并时只读取一次
+	secretsMu   sync.Mutex
+	secretCache map[string]string
+)
+
+// loadSecrets 按config-matcher.json中的secrets从Vault读取各参数的值，已按properties的写法转义。
+// 同一路径只读取一次，结果在本次运行中缓存
+func loadSecrets(config propmerge.Config) (map[string]string, error) {
+	if len(config.Secrets) == 0 {
+		return nil, nil
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if secretCache != nil {
+		return secretCache, nil
+	}
+
+	v, err := newVaultClient(config.Vault)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(config.Secrets))
+	for key := range config.Secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	data := make(map[string]map[string]interface{})
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		s := config.Secrets[key]
+		fields, ok := data[s.Path]
+		if !ok {
+			if fields, err = v.read(s.Path); err != nil {
+				return nil, err
+			}
+			data[s.Path] = fields
+		}
+		raw, ok := fields[s.Field]
+		if !ok {
+			return nil, fmt.Errorf(tr("Vault路径 %s 中没有字段 %s (参数 %s)"), s.Path, s.Field, key)
+		}
+		value, ok := raw.(string)
+		if !ok {
+			text, _ := json.Marshal(raw)
+			value = string(text)
+		}
+		values[key] = propmerge.EscapeValue(value, false)
+	}
+	debugf(tr("从Vault读取%d个参数的值"), len(values))
+	secretCache = values
+	return values, nil
+}
+
+// newVaultClient 按配置与环境变量创建客户端并完成认证
+func newVaultClient(c propmerge.Vault) (*vaultClient, error) {
+	v := &vaultClient{
+		addr:      strings.TrimSuffix(firstNonEmpty(c.Address, os.Getenv("VAULT_ADDR")), "/"),
+		namespace: firstNonEmpty(c.Namespace, os.Getenv("VAULT_NAMESPACE")),
+		client:    &http.Client{Timeout: httpTimeout},
+	}
+	if v.addr == "" {
+		return nil, errors.New(tr("未指定Vault地址(vault.address或VAULT_ADDR)"))
+	}
+
+	if c.Auth != propmerge.VaultAuthAppRole {
+		if v.token = firstNonEmpty(c.Token, os.Getenv("VAULT_TOKEN")); v.token == "" {
+			return nil, errors.New(tr("未指定Vault令牌(vault.token或VAULT_TOKEN)"))
+		}
+		return v, nil
+	}
+
+	roleID := firstNonEmpty(c.RoleID, os.Getenv("VAULT_ROLE_ID"))
+	secretID := firstNonEmpty(c.SecretID, os.Getenv("VAULT_SECRET_ID"))
+	if roleID == "" || secretID == "" {
+		return nil, errors.New(tr("AppRole认证需要roleId与secretId(或VAULT_ROLE_ID与VAULT_SECRET_ID)"))
+	}
+	mount := firstNonEmpty(c.AppRoleMount, "approle")
+	body, _ := json.Marshal(map[string]string{"role_id": roleID, "secret_id": secretID})
+	var login struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if err := v.do(http.MethodPost, "auth/"+strings.Trim(mount, "/")+"/login", body, &login); err != nil {
+		return nil, fmt.Errorf(tr("Vault AppRole登录失败: %w"), err)
+	}
+	if login.Auth.ClientToken == "" {
+		return nil, errors.New(tr("Vault AppRole登录失败: 响应中没有client_token"))
+	}
+	v.token = login.Auth.ClientToken
+	debugf(tr("已通过AppRole登录Vault"))
+	return v, nil
+}
+
+// read 读取路径下的数据: KV v2的数据位于data.data，KV v1与其他引擎位于data
+func (v *vaultClient) read(path string) (map[string]interface{}, error) {
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := v.do(http.MethodGet, strings.Trim(path, "/"), nil, &resp); err != nil {
+		return nil, fmt.Errorf(tr("读取Vault路径 %s 失败: %w"), path, err)
+	}
+	if inner, ok := resp.Data["data"].(map[string]interface{}); ok {
+		if _, versioned := resp.Data["metadata"]; versioned {
+			return inner, nil
+		}
+	}
+	return resp.Data, nil
+}
+
+// do 发送请求到/v1/下的API路径并解析JSON响应
+func (v *vaultClient) do(method, path string, body []byte, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(runCtx, method, v.addr+"/v1/"+path, reader)
+	if err != nil {
+		return fmt.Errorf(tr("创建请求失败: %w"), err)
+	}
+	req.Header.Set("User-Agent", "update_config/"+version)
+	if v.token != "" {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	payload, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		// Vault的错误响应为{"errors": [...]}，不包含令牌等敏感内容
+		var e struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(payload, &e) == nil && len(e.Errors) > 0 {
+			return fmt.Errorf(tr("HTTP状态 %s: %s"), resp.Status, strings.Join(e.Errors, "; "))
+		}
+		return fmt.Errorf(tr("HTTP状态 %s"), resp.Status)
+	}
+	if err := json.Unmarshal(payload, out); err != nil {
+		return fmt.Errorf(tr("解析响应失败: %w"), err)
+	}
+	return nil
+}
+
+// firstNonEmpty 返回第一个非空的字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
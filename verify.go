@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// manifestFile 为记录输入、备份与输出文件校验和的清单文件(-manifest)
+var manifestFile string
+
+// manifestEntry 记录一对文件合并前后的校验和
+type manifestEntry struct {
+	OldFile  fileChecksum   `json:"oldFile"`
+	NewFile  fileChecksum   `json:"newFile"` // 合并前的新文件
+	Backups  []fileChecksum `json:"backups"`
+	Output   fileChecksum   `json:"output"`       // 写入并通过校验后的新文件
+	Verified int            `json:"verifiedKeys"` // 写入后逐个核对过的保留参数数量
+}
+
+// mergeManifest 是-manifest输出的校验和清单，批量模式下每对文件一项
+type mergeManifest struct {
+	Tool     string          `json:"tool"`
+	Time     string          `json:"time"`
+	Host     string          `json:"host"`
+	Operator string          `json:"operator"`
+	Files    []manifestEntry `json:"files"`
+}
+
+var (
+	manifestMu      sync.Mutex
+	manifestEntries []manifestEntry
+)
+
+// verifyMerged 按-file-mode等选项设置写入后文件的属性，再重新读取该文件，核对每个写入的保留参数都存在且值与合并结果一致。
+// 合并结果未给出完整内容(流式快速路径)时按旧值核对。核对失败时用合并前的备份恢复该文件
+// (写入单独的输出文件时backup为空，不做恢复)，返回核对过的参数数量
+func verifyMerged(filename, backup string, result propmerge.Result) (int, error) {
+	if err := applyFileAttrs(filename); err != nil {
+		return 0, err
+	}
+	format := fileFormat(filename, filename)
+	actual, err := writtenValues(filename, format, result)
+	if err != nil {
+		return 0, fmt.Errorf(tr("校验写入结果失败: %w"), err)
+	}
+	var expected map[string][]string
+	if result.Lines != nil {
+		entries, err := parseConfig(filename, format, result.Lines)
+		if err != nil {
+			return 0, fmt.Errorf(tr("校验写入结果失败: %w"), err)
+		}
+		expected = entryValues(entries)
+	}
+
+	var problems []string
+	verified := 0
+	for _, k := range result.Keys {
+		if !k.Written() {
+			continue
+		}
+		key := verifyKey(k.Key)
+		want := strings.TrimSpace(k.OldValue)
+		if values := expected[key]; len(values) > 0 {
+			want = values[len(values)-1]
+		}
+		got := actual[key]
+		switch {
+		case len(got) == 0:
+			problems = append(problems, fmt.Sprintf(tr("%s: 写入后不存在"), k.Key))
+		case !slices.Contains(got, want):
+			problems = append(problems, fmt.Sprintf(tr("%s: 写入后的值为%q，应为%q"), k.Key, masker.Value(k.Key, got[len(got)-1]), masker.Value(k.Key, want)))
+		default:
+			verified++
+		}
+	}
+	if len(problems) == 0 {
+		debugf(tr("写入结果核对通过: %s (%d个参数)"), filename, verified, slog.String("file", filename))
+		return verified, nil
+	}
+
+	for _, p := range problems {
+		errorf(tr("写入结果核对失败: %s"), p, slog.String("file", filename))
+	}
+	if backup == "" {
+		return 0, fmt.Errorf(tr("写入结果核对失败: %d处参数与合并结果不一致"), len(problems))
+	}
+	if err := restoreBackup(backup, filename); err != nil {
+		return 0, fmt.Errorf(tr("写入结果核对失败(%d处)，且恢复备份失败: %w"), len(problems), err)
+	}
+	warnf(tr("已从备份 %s 恢复 %s"), backup, filename)
+	return 0, fmt.Errorf(tr("写入结果核对失败: %d处参数与合并结果不一致，已恢复备份"), len(problems))
+}
+
+// verifyWrite 核对写入新文件的结果，记入操作日志并将各文件的校验和记入清单，各合并模式写入新文件后调用
+func verifyWrite(oldFile, newFile, oldBackup, newBackup string, result propmerge.Result) error {
+	verified, err := verifyMerged(newFile, newBackup, result)
+	if err != nil {
+		return err
+	}
+	journalMerge(oldFile, newFile, oldBackup, newBackup)
+	return recordManifest(oldFile, newFile, oldBackup, newBackup, verified)
+}
+
+// writtenValues 读取写入后的文件，返回各键的值。流式合并的properties文件可能很大，
+// 此时逐行扫描并只记录需要核对的键，不将文件整个载入内存
+func writtenValues(filename, format string, result propmerge.Result) (map[string][]string, error) {
+	_, plugin := findPlugin(format)
+	if result.Lines != nil || format != "properties" || plugin || isArchive(filename) {
+		lines, _, _, err := readConfigLines(filename)
+		if err != nil {
+			return nil, err
+		}
+		written, err := parseConfig(filename, format, lines)
+		if err != nil {
+			return nil, err
+		}
+		return entryValues(written), nil
+	}
+
+	wanted := make(map[string]bool, len(result.Keys))
+	for _, k := range result.Keys {
+		wanted[verifyKey(k.Key)] = true
+	}
+	values := make(map[string][]string, len(wanted))
+	err := propmerge.ScanProperties(filename, func(p propmerge.Property) error {
+		// 与ParseProperties一致，重复的键以最后一次出现为准
+		if key := verifyKey(p.Key); wanted[key] {
+			values[key] = []string{p.Value}
+		}
+		return nil
+	})
+	return values, err
+}
+
+// entryValues 返回以键名为键的值，同一键出现多次(如新文件中的重复键)时按出现顺序保留每个值
+func entryValues(entries []propmerge.Entry) map[string][]string {
+	values := make(map[string][]string, len(entries))
+	for _, e := range entries {
+		key := verifyKey(e.Key)
+		values[key] = append(values[key], e.Value)
+	}
+	return values
+}
+
+// verifyKey 返回核对时使用的键名: 按宽松绑定匹配时写回的是新文件中的键名写法，按规范形式比较
+func verifyKey(key string) string {
+	if springRelaxed {
+		return propmerge.SpringCanonical(key)
+	}
+	return key
+}
+
+// recordManifest 计算一对文件的校验和并加入清单: 合并前的新文件取自其备份，
+// 未指定-manifest时不做任何事
+func recordManifest(oldFile, newFile, oldBackup, newBackup string, verified int) error {
+	if manifestFile == "" {
+		return nil
+	}
+	entry := manifestEntry{Verified: verified}
+	var err error
+	if entry.OldFile, err = checksumFile(oldFile); err != nil {
+		return err
+	}
+	if entry.NewFile, err = checksumFile(newBackup); err != nil {
+		return err
+	}
+	entry.NewFile.Path = newFile
+	for _, b := range []string{oldBackup, newBackup} {
+		if b == "" {
+			continue
+		}
+		sum, err := checksumFile(b)
+		if err != nil {
+			return err
+		}
+		entry.Backups = append(entry.Backups, sum)
+	}
+	if entry.Output, err = checksumFile(newFile); err != nil {
+		return err
+	}
+
+	manifestMu.Lock()
+	manifestEntries = append(manifestEntries, entry)
+	manifestMu.Unlock()
+	return nil
+}
+
+// writeManifest 将本次运行记录的校验和写入清单文件，没有写入任何文件时不生成清单
+func writeManifest() error {
+	if manifestFile == "" || len(manifestEntries) == 0 {
+		return nil
+	}
+	host, _ := os.Hostname()
+	m := mergeManifest{
+		Tool:     "update_config v" + version,
+		Time:     time.Now().Format(time.RFC3339),
+		Host:     host,
+		Operator: operator(),
+		Files:    manifestEntries,
+	}
+	// 批量模式并行处理，按文件路径排序使清单内容稳定
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Output.Path < m.Files[j].Output.Path })
+
+	file, err := createOutputFile(manifestFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	enc := json.NewEncoder(file)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return fmt.Errorf(tr("写入清单文件失败: %w"), err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf(tr("写入清单文件失败: %w"), err)
+	}
+	debugf(tr("清单文件已写入: %s"), manifestFile, slog.String("file", manifestFile))
+	return nil
+}
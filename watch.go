@@ -0,0 +1,222 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/pslinux/go-compare/pkg/propmerge"
+)
+
+// fileState 记录被监视文件的大小与修改时间
+type fileState struct {
+	size    int64
+	modTime int64 // 修改时间(纳秒)
+}
+
+// pendingFile 记录发生变化、等待写入完成的模板: state为最近一次轮询到的状态，since为首次轮询到该状态的时间
+type pendingFile struct {
+	state fileState
+	since time.Time
+}
+
+// runWatch 实现watch子命令: 轮询模板目录，当有新的或被更新的模板文件落地且写入完成后，
+// 以当前配置文件为旧文件、模板为新文件执行合并并记录结果，直到收到中断信号。
+// 为保持零第三方依赖，使用os.Stat轮询而不是inotify等文件系统事件通知；
+// 模板的大小和修改时间在连续两次轮询之间不变、且保持不变至少-debounce时长后才认为写入完成
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	registerLangFlag(fs)
+	registerLogFlags(fs)
+	registerBackupFlags(fs)
+	interval := fs.Duration("interval", 2*time.Second, "轮询模板目录的间隔")
+	debounce := fs.Duration("debounce", 2*time.Second, "模板的大小和修改时间保持不变至少该时长后才合并，避免合并写了一半的文件；上传较慢时可适当调大")
+	name := fs.String("name", "application*.properties", "模板文件名规则")
+	fs.StringVar(&checkRulesFile, "check-rules", "", "合并后按校验规则文件检查结果，未通过时恢复合并前的备份")
+	fs.StringVar(&backupMode, "backup-mode", backupCopy, "备份方式: copy|git|both")
+	fs.DurationVar(&lockTimeout, "lock-timeout", 0, "目标文件正被其他进程处理时等待锁的最长时间，0为不等待")
+	metricsAddr := fs.String("metrics-addr", "", "在指定地址上提供Prometheus监控指标(/metrics)，为空时不提供")
+	fs.BoolVar(&verbose, "v", false, "启用详细输出模式")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), tr("用法: %s watch [选项] 模板目录 当前配置文件\n\n选项:\n"), os.Args[0])
+		printDefaults(fs)
+	}
+	parseFlags(fs, args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	templateDir, liveConfig := fs.Arg(0), fs.Arg(1)
+	if *interval <= 0 {
+		fatalf(tr("参数错误: -interval必须大于0"))
+	}
+	if *debounce < 0 {
+		fatalf(tr("参数错误: -debounce不能为负数"))
+	}
+	if !validBackupMode(backupMode) {
+		return fmt.Errorf(tr("无效的备份方式: %s"), backupMode)
+	}
+	if err := checkBackupFlags(); err != nil {
+		return err
+	}
+	if _, err := filepath.Match(*name, ""); err != nil {
+		return fmt.Errorf(tr("无效的文件名规则 %s: %w"), *name, err)
+	}
+	if _, err := os.Stat(liveConfig); err != nil {
+		return fmt.Errorf(tr("读取当前配置文件失败: %w"), err)
+	}
+
+	// 启动时已存在的模板视为已处理，只响应之后落地或更新的文件
+	seen, err := scanTemplates(templateDir, *name)
+	if err != nil {
+		return err
+	}
+	pending := make(map[string]pendingFile)
+	if *metricsAddr != "" {
+		serveMetrics(*metricsAddr)
+	}
+	infof(tr("开始监视 %s (规则: %s, 间隔: %s, 防抖: %s)，按 Ctrl+C 停止"), templateDir, *name, *interval, *debounce)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			infof(tr("停止监视"))
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := scanTemplates(templateDir, *name)
+		if err != nil {
+			warnf("%v", err)
+			continue
+		}
+		for path, state := range settledTemplates(current, seen, pending, *debounce, time.Now()) {
+			seen[path] = watchMerge(liveConfig, path, state)
+		}
+	}
+}
+
+// settledTemplates 根据本次轮询的结果更新pending，返回已写入完成、需要合并的模板:
+// 与上次合并后的状态(seen)不同，大小和修改时间与上一次轮询相同，且保持不变已至少debounce时长。
+// 已删除的模板同时从seen与pending中移除
+func settledTemplates(current, seen map[string]fileState, pending map[string]pendingFile, debounce time.Duration, now time.Time) map[string]fileState {
+	settled := make(map[string]fileState)
+	for path, state := range current {
+		if seen[path] == state {
+			delete(pending, path)
+			continue
+		}
+		p, ok := pending[path]
+		if !ok || p.state != state {
+			pending[path] = pendingFile{state: state, since: now}
+			debugf(tr("检测到模板变化，等待写入完成: %s"), path)
+			continue
+		}
+		if now.Sub(p.since) < debounce {
+			continue
+		}
+		delete(pending, path)
+		settled[path] = state
+	}
+	for path := range seen {
+		if _, ok := current[path]; !ok {
+			delete(seen, path)
+		}
+	}
+	for path := range pending {
+		if _, ok := current[path]; !ok {
+			delete(pending, path)
+		}
+	}
+	return settled
+}
+
+// scanTemplates 返回目录中匹配规则的模板文件及其状态
+func scanTemplates(dir, pattern string) (map[string]fileState, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf(tr("读取模板目录失败: %w"), err)
+	}
+
+	states := make(map[string]fileState)
+	for _, e := range entries {
+		if ok, _ := filepath.Match(pattern, e.Name()); !ok || !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		states[filepath.Join(dir, e.Name())] = fileState{size: info.Size(), modTime: info.ModTime().UnixNano()}
+	}
+	return states, nil
+}
+
+// watchMerge 将当前配置中的保留参数合并到新落地的模板并记录结果，返回合并后模板的状态，
+// 避免本次写入再次触发合并
+func watchMerge(liveConfig, template string, state fileState) fileState {
+	infof(tr("模板已更新，开始合并: %s"), template, slog.String("file", template))
+	var (
+		result propmerge.Result
+		err    error
+	)
+	defer func(start time.Time) {
+		metrics.observe(start, result.Keys, err)
+		changed, conflicts := keyCounts(result.Keys)
+		notifyMerge(newNotice(template, changed, conflicts, err))
+	}(time.Now())
+
+	merger, err := newMerger(liveConfig, template)
+	if err != nil {
+		errorf(tr("合并失败: 加载配置失败: %v"), err, slog.String("file", template))
+		return state
+	}
+
+	lock, err := lockTarget(template)
+	if err != nil {
+		errorf(tr("合并失败: %v"), err, slog.String("file", template))
+		return state
+	}
+	defer lock.release()
+
+	oldBackup, newBackup, err := createBackups(backupDir, liveConfig, template)
+	if err != nil {
+		errorf(tr("合并失败: %v"), err, slog.String("file", template))
+		return state
+	}
+	result, err = merger.MergeFileContext(runCtx, liveConfig, template)
+	if err != nil {
+		errorf(tr("合并失败: %s: %v"), template, err, slog.String("file", template))
+		return state
+	}
+	if _, err = verifyMerged(template, newBackup, result); err != nil {
+		errorf(tr("合并失败: %s: %v"), template, err, slog.String("file", template))
+		return state
+	}
+	if err = checkMerged(template, newBackup); err != nil {
+		errorf(tr("合并失败: %s: %v"), template, err, slog.String("file", template))
+		return state
+	}
+	journalMerge(liveConfig, template, oldBackup, newBackup)
+	if err := gitBackupMerged(template); err != nil {
+		warnf("%v", err, slog.String("file", template))
+	}
+
+	changed, _ := keyCounts(result.Keys)
+	infof(tr("合并完成: %s (保留%d个参数, %d个值发生变化, 备份: %s, %s)"), template, len(result.Keys), changed, oldBackup, newBackup, slog.String("file", template))
+
+	info, statErr := os.Stat(template)
+	if statErr != nil {
+		return state
+	}
+	return fileState{size: info.Size(), modTime: info.ModTime().UnixNano()}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSettledTemplatesDebounce 模板在轮询之间仍在变化或保持不变不足-debounce时长时不合并
+func TestSettledTemplatesDebounce(t *testing.T) {
+	const path = "templates/application.properties"
+	start := time.Date(2024, 5, 20, 10, 0, 0, 0, time.UTC)
+	seen := map[string]fileState{}
+	pending := map[string]pendingFile{}
+	poll := func(after time.Duration, state fileState) bool {
+		t.Helper()
+		settled := settledTemplates(map[string]fileState{path: state}, seen, pending, 5*time.Second, start.Add(after))
+		if got, ok := settled[path]; ok {
+			if got != state {
+				t.Fatalf("settled state = %v, want %v", got, state)
+			}
+			seen[path] = state
+			return true
+		}
+		return false
+	}
+
+	partial := fileState{size: 100, modTime: 1}
+	complete := fileState{size: 200, modTime: 2}
+	steps := []struct {
+		after time.Duration
+		state fileState
+		merge bool
+	}{
+		{0, partial, false},                 // 首次看到
+		{2 * time.Second, partial, false},   // 不变但不足5秒
+		{4 * time.Second, complete, false},  // 仍在写入，重新计时
+		{6 * time.Second, complete, false},  // 不变2秒
+		{9 * time.Second, complete, true},   // 不变5秒，合并
+		{11 * time.Second, complete, false}, // 合并后不再重复合并
+	}
+	for _, s := range steps {
+		if got := poll(s.after, s.state); got != s.merge {
+			t.Fatalf("poll at %s (%v): merged = %v, want %v", s.after, s.state, got, s.merge)
+		}
+	}
+
+	// 模板被删除后从seen与pending中移除，重新落地时再次合并
+	settledTemplates(map[string]fileState{}, seen, pending, 5*time.Second, start.Add(12*time.Second))
+	if len(seen) != 0 || len(pending) != 0 {
+		t.Fatalf("seen = %v, pending = %v after the template was removed", seen, pending)
+	}
+	if poll(13*time.Second, complete) || !poll(18*time.Second, complete) {
+		t.Fatal("re-created template was not merged after the debounce")
+	}
+
+	// debounce为0时与原来相同: 连续两次轮询不变即合并
+	delete(seen, path)
+	settledTemplates(map[string]fileState{path: partial}, seen, pending, 0, start)
+	if settled := settledTemplates(map[string]fileState{path: partial}, seen, pending, 0, start); len(settled) != 1 {
+		t.Fatalf("debounce 0: settled = %v, want the template", settled)
+	}
+}